@@ -21,6 +21,20 @@ const (
 	ReasonExecute = "Execute"
 	// ReasonGenerate is the reason for generating a workflow
 	ReasonGenerate = "Generate"
+	// ReasonExport is the reason for exporting the outputs of a workflow
+	ReasonExport = "Export"
+	// ReasonGC is the reason for deleting the resources applied by a workflow
+	ReasonGC = "GC"
+	// ReasonRecover is the reason for recovering a stale workflow run after the controller restarts
+	ReasonRecover = "Recover"
+	// ReasonTerminate is the reason for terminating a workflow gracefully
+	ReasonTerminate = "Terminate"
+	// ReasonApprovalTimeout is the reason for a suspend step which is not resumed in the timeout
+	ReasonApprovalTimeout = "ApprovalTimeout"
+	// ReasonStepFailureIgnored is the reason for a failed step whose failure is ignored by its onFailure policy
+	ReasonStepFailureIgnored = "StepFailureIgnored"
+	// ReasonNotify is the reason for delivering the notifications of a finished workflow
+	ReasonNotify = "Notify"
 )
 
 const (
@@ -34,4 +48,22 @@ const (
 	MessageFailedGenerate = "fail to generate workflow runners"
 	// MessageFailedExecute is the message for failed to execute
 	MessageFailedExecute = "fail to execute"
+	// MessageFailedExport is the message for failed to export outputs
+	MessageFailedExport = "fail to export outputs"
+	// MessageFailedGC is the message for failed to delete the applied resources
+	MessageFailedGC = "fail to delete the applied resources"
+	// MessageRecovered is the message for recovering a stale workflow run after the controller restarts
+	MessageRecovered = "recovered after controller restart"
+	// MessageTerminatedGracefully is the message for terminated after the running steps finish
+	MessageTerminatedGracefully = "WorkflowRun terminated gracefully after the running steps finished"
+	// MessageForcedTermination is the message for interrupting the running steps after the grace period
+	MessageForcedTermination = "WorkflowRun termination is forced as the running steps are not finished in the grace period"
+	// MessageApprovalTimeout is the message for a suspend step which is not resumed in the timeout
+	MessageApprovalTimeout = "step %s is not resumed in the timeout, the step is %s"
+	// MessageSkippedContextSource is the message for an optional source of spec.contextFrom which is not found
+	MessageSkippedContextSource = "skip the optional source %s of spec.contextFrom: %s"
+	// MessageStepFailureIgnored is the message for a failed step whose failure is ignored by its onFailure policy
+	MessageStepFailureIgnored = "the failure of step %s is ignored: %s"
+	// MessageFailedNotify is the message for failed to deliver the notifications
+	MessageFailedNotify = "fail to deliver the notifications"
 )
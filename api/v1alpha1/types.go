@@ -31,6 +31,9 @@ import (
 // +kubebuilder:resource:categories={oam},shortName={wr}
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="PHASE",type=string,JSONPath=`.status.status`
+// +kubebuilder:printcolumn:name="STEPS",type=string,JSONPath=`.status.progress`
+// +kubebuilder:printcolumn:name="SUMMARY",type=string,JSONPath=`.status.summary`
+// +kubebuilder:printcolumn:name="MESSAGE",type=string,JSONPath=`.status.message`,priority=1
 // +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=".metadata.creationTimestamp"
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -76,6 +79,106 @@ type WorkflowRunSpec struct {
 	Mode         *WorkflowExecuteMode  `json:"mode,omitempty"`
 	WorkflowSpec *WorkflowSpec         `json:"workflowSpec,omitempty"`
 	WorkflowRef  string                `json:"workflowRef,omitempty"`
+	// ExportOutputs exports the outputs to a config map or secret after the workflow run succeeds
+	ExportOutputs *ExportOutputs `json:"exportOutputs,omitempty"`
+	// ResourceGC deletes the objects applied by the workflow run when the workflow run is deleted
+	ResourceGC bool `json:"resourceGC,omitempty"`
+	// ResourceLabels are merged into the labels of the objects applied by the steps, the name of the step is
+	// labeled on the objects as well if it's set
+	ResourceLabels map[string]string `json:"resourceLabels,omitempty"`
+	// ResourceAnnotations are merged into the annotations of the objects applied by the steps
+	ResourceAnnotations map[string]string `json:"resourceAnnotations,omitempty"`
+	// ResourceMetadataOverride overrides the existing labels and annotations of the objects with the resource labels
+	// and annotations, the existing values are kept by default
+	ResourceMetadataOverride bool `json:"resourceMetadataOverride,omitempty"`
+	// Vars are the CUE expressions evaluated once when the workflow run starts, the values are exposed to the steps as context.var
+	Vars map[string]string `json:"var,omitempty"`
+	// ContextFrom imports the keys of the ConfigMaps and the Secrets as context.var before the first step runs,
+	// the vars in spec.var take precedence over them
+	ContextFrom []ContextFromSource `json:"contextFrom,omitempty"`
+	// Timeout is the timeout of the workflow run since it starts, the unfinished steps are failed when it's exceeded
+	Timeout string `json:"timeout,omitempty"`
+	// ProviderOverrides override the endpoints dialed by the providers for this run only, e.g. to route the requests
+	// to a mock server in the sandbox environments
+	ProviderOverrides *ProviderOverrides `json:"providerOverrides,omitempty"`
+	// Notifications POST the summary of the workflow run to the targets when it finishes, the failures of the
+	// deliveries are recorded in the Notification condition without changing the phase of the run
+	Notifications []RunNotification `json:"notifications,omitempty"`
+	// Priority orders the queued workflow runs in the namespace when the concurrent runs are limited, the runs with
+	// the higher priority are executed first and the runs with the same priority are executed by the creation time
+	Priority int `json:"priority,omitempty"`
+}
+
+// RunNotification is a target to POST the summary of the workflow run when it finishes, either URL or SecretRef is required
+type RunNotification struct {
+	// URL is the endpoint to POST the summary
+	URL string `json:"url,omitempty"`
+	// SecretRef refers to the key of the Secret in the namespace of the workflow run holding the endpoint
+	SecretRef *corev1.SecretKeySelector `json:"secretRef,omitempty"`
+	// Events are the phases of the finished run to notify, e.g. Succeeded, Failed and Terminated, all of them are
+	// notified if it's empty
+	Events []WorkflowRunPhase `json:"events,omitempty"`
+	// IncludeOutputs includes the outputs of the steps in the summary
+	IncludeOutputs bool `json:"includeOutputs,omitempty"`
+	// Headers are added to the request
+	Headers map[string]string `json:"headers,omitempty"`
+	// SigningSecretRef refers to the key of the Secret in the namespace of the workflow run holding the HMAC key,
+	// the summary is signed with HMAC-SHA256 in the X-Workflow-Signature header
+	SigningSecretRef *corev1.SecretKeySelector `json:"signingSecretRef,omitempty"`
+}
+
+// ContextFromSource is a ConfigMap or a Secret in the namespace of the workflow run to import as context vars
+type ContextFromSource struct {
+	ConfigMapRef *corev1.LocalObjectReference `json:"configMapRef,omitempty"`
+	// SecretRef refers to the Secret whose values are sensitive, they are not stored in the context ConfigMap
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+	// Keys are the keys to import, all the keys are imported if it's empty
+	Keys []string `json:"keys,omitempty"`
+	// Prefix is prepended to the keys to make the names of the vars
+	Prefix string `json:"prefix,omitempty"`
+	// Optional skips the source or the keys not found instead of failing the workflow run
+	Optional bool `json:"optional,omitempty"`
+}
+
+// ProviderOverrides override the endpoints of the providers
+type ProviderOverrides struct {
+	HTTP         *HTTPOverride         `json:"http,omitempty"`
+	Email        *EmailOverride        `json:"email,omitempty"`
+	Notification *NotificationOverride `json:"notification,omitempty"`
+}
+
+// HTTPOverride rewrites the urls of the http requests
+type HTTPOverride struct {
+	// BaseURLRewrite replaces the prefix of the urls, the first matched rule is applied
+	BaseURLRewrite []URLRewrite `json:"baseURLRewrite,omitempty"`
+}
+
+// URLRewrite replaces the url prefix From with To
+type URLRewrite struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// EmailOverride replaces the smtp server of the emails
+type EmailOverride struct {
+	SMTPHost string `json:"smtpHost,omitempty"`
+	SMTPPort int    `json:"smtpPort,omitempty"`
+}
+
+// NotificationOverride replaces the targets of the notifications sent by the http requests
+type NotificationOverride struct {
+	// SlackURL replaces the urls of the slack incoming webhooks
+	SlackURL string `json:"slackURL,omitempty"`
+}
+
+// ExportOutputs defines the target and the keys of the exported outputs, only one of the ConfigMapName and SecretName can be set
+type ExportOutputs struct {
+	// ConfigMapName is the name of the config map in the namespace of the workflow run to write the outputs
+	ConfigMapName string `json:"configMapName,omitempty"`
+	// SecretName is the name of the secret in the namespace of the workflow run to write the outputs
+	SecretName string `json:"secretName,omitempty"`
+	// Keys are the names of the step outputs or the paths of the context vars to export
+	Keys []string `json:"keys"`
 }
 
 // WorkflowRunStatus record the status of workflow run
@@ -85,18 +188,39 @@ type WorkflowRunStatus struct {
 	Mode    WorkflowExecuteMode `json:"mode"`
 	Phase   WorkflowRunPhase    `json:"status"`
 	Message string              `json:"message,omitempty"`
+	// Progress is the number of the finished steps out of all the steps, e.g. 3/7
+	Progress string `json:"progress,omitempty"`
+	// Summary is the compact description of the progress, e.g. "3/7 steps, running: deploy-canary", it's capped in length
+	Summary string `json:"summary,omitempty"`
 
 	Suspend      bool   `json:"suspend"`
 	SuspendState string `json:"suspendState,omitempty"`
 
 	Terminated bool `json:"terminated"`
 	Finished   bool `json:"finished"`
+	// DryRun indicates the workflow run is executed in the dry-run mode, it's decided when the workflow run starts
+	DryRun bool `json:"dryRun,omitempty"`
+	// GracefulTermination is set when the workflow run is terminated gracefully, the running steps are allowed to
+	// finish before the workflow run is terminated
+	GracefulTermination *GracefulTermination `json:"gracefulTermination,omitempty"`
 
 	ContextBackend *corev1.ObjectReference `json:"contextBackend,omitempty"`
 	Steps          []WorkflowStepStatus    `json:"steps,omitempty"`
 
 	StartTime metav1.Time `json:"startTime,omitempty"`
 	EndTime   metav1.Time `json:"endTime,omitempty"`
+	// Timeout is the effective timeout of the workflow run, which is the one in the spec or the default of the controller
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// GracefulTermination records the graceful termination of the workflow run
+type GracefulTermination struct {
+	// StartTime is the time when the termination is requested
+	StartTime metav1.Time `json:"startTime"`
+	// GracePeriod is the period after which the running steps are interrupted
+	GracePeriod metav1.Duration `json:"gracePeriod"`
+	// Forced indicates the running steps are interrupted as they are not finished in the grace period
+	Forced bool `json:"forced,omitempty"`
 }
 
 // WorkflowSpec defines workflow steps and other attributes
@@ -114,6 +238,8 @@ type WorkflowExecuteMode struct {
 type WorkflowRunPhase string
 
 const (
+	// WorkflowStateQueued means the workflow run is waiting for the concurrent runs in the namespace to finish
+	WorkflowStateQueued WorkflowRunPhase = "queued"
 	// WorkflowStateInitializing means the workflow run is initializing
 	WorkflowStateInitializing WorkflowRunPhase = "initializing"
 	// WorkflowStateExecuting means the workflow run is executing
@@ -167,8 +293,8 @@ type WorkflowStepMeta struct {
 
 // WorkflowStepBase defines the workflow step base
 type WorkflowStepBase struct {
-	// Name is the unique name of the workflow step.
-	Name string `json:"name"`
+	// Name is the unique name of the workflow step, it's generated from the type and the index of the step if omitted.
+	Name string `json:"name,omitempty"`
 	// Type is the type of the workflow step.
 	Type string `json:"type"`
 	// Meta is the meta data of the workflow step.
@@ -177,8 +303,15 @@ type WorkflowStepBase struct {
 	If string `json:"if,omitempty"`
 	// Timeout is the timeout of the step
 	Timeout string `json:"timeout,omitempty"`
+	// ExecuteWindow is the window of the time in which the step is executed, the step waits outside the window
+	ExecuteWindow *ExecuteWindow `json:"executeWindow,omitempty"`
+	// CUEProfile is the profile of the CUE evaluation of the step
+	CUEProfile string `json:"cueProfile,omitempty"`
 	// DependsOn is the dependency of the step
 	DependsOn []string `json:"dependsOn,omitempty"`
+	// OnFailure is the policy applied when the step fails, it defaults to Fail.
+	// The sub steps without the policy inherit the one of their step group.
+	OnFailure StepFailurePolicy `json:"onFailure,omitempty"`
 	// Inputs is the inputs of the step
 	Inputs StepInputs `json:"inputs,omitempty"`
 	// Outputs is the outputs of the step
@@ -189,6 +322,32 @@ type WorkflowStepBase struct {
 	Properties *runtime.RawExtension `json:"properties,omitempty"`
 }
 
+// ExecuteWindow is the daily window of the time in which the step is executed
+type ExecuteWindow struct {
+	// Start is the start of the window in the format of HH:MM
+	Start string `json:"start"`
+	// End is the end of the window in the format of HH:MM, the window spans midnight if it's not after the start
+	End string `json:"end"`
+	// TimeZone is the IANA name of the time zone of the window, e.g. Europe/Berlin, it defaults to UTC
+	TimeZone string `json:"timeZone,omitempty"`
+	// Days is the days of the week on which the window starts, e.g. [Sat, Sun], the window starts every day if empty
+	Days []string `json:"days,omitempty"`
+}
+
+// StepFailurePolicy describes how the failure of a step affects the workflow run
+// +kubebuilder:validation:Enum=Ignore;Fail;Terminate
+type StepFailurePolicy string
+
+const (
+	// StepFailurePolicyIgnore records the step as failed but lets the workflow run proceed,
+	// the run can still succeed if the other steps succeed
+	StepFailurePolicyIgnore StepFailurePolicy = "Ignore"
+	// StepFailurePolicyFail fails the workflow run after the step fails and exhausts its retries
+	StepFailurePolicyFail StepFailurePolicy = "Fail"
+	// StepFailurePolicyTerminate terminates the workflow run on the first failure of the step without retrying it
+	StepFailurePolicyTerminate StepFailurePolicy = "Terminate"
+)
+
 // WorkflowMode describes the mode of workflow
 type WorkflowMode string
 
@@ -209,10 +368,58 @@ type StepStatus struct {
 	Message string `json:"message,omitempty"`
 	// A brief CamelCase message indicating details about why the workflowStep is in this state.
 	Reason string `json:"reason,omitempty"`
+	// FailedOp is the op which produced the failure of this step.
+	FailedOp *StepOp `json:"failedOp,omitempty"`
 	// FirstExecuteTime is the first time this step execution.
 	FirstExecuteTime metav1.Time `json:"firstExecuteTime,omitempty"`
 	// LastExecuteTime is the last time this step execution.
 	LastExecuteTime metav1.Time `json:"lastExecuteTime,omitempty"`
+	// PropertiesHash is the hash of the properties of the step when it's first executed.
+	PropertiesHash string `json:"propertiesHash,omitempty"`
+	// ConsecutiveFailures is the number of the consecutive failed executions of this step.
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+	// DefinitionRevision is the revision of the step definition used by this step as `type@revision`,
+	// the step keeps using it for the lifetime of the run.
+	DefinitionRevision string `json:"definitionRevision,omitempty"`
+	// Logs is the name of the config map storing the logs collected from the pods of this step.
+	Logs string `json:"logs,omitempty"`
+	// MessageHistory is the last distinct messages of this step, the latest one is also recorded in Message.
+	MessageHistory []StepMessage `json:"messageHistory,omitempty"`
+	// Outputs is the small outputs of this step inlined when it succeeds, the larger ones are only kept in the
+	// workflow context.
+	Outputs []StepOutput `json:"outputs,omitempty"`
+	// Timeout is the effective timeout of this step, which is the one in the spec or the default of the controller.
+	Timeout string `json:"timeout,omitempty"`
+	// Watches is the objects watched by the waiting step, the changes of them trigger the reconcile of the run.
+	// They are cleared when the step completes or the run terminates.
+	Watches []WatchedObject `json:"watches,omitempty"`
+}
+
+// StepOp is the identity of an op executed in the workflow step
+type StepOp struct {
+	Provider string `json:"provider"`
+	Op       string `json:"op"`
+}
+
+// StepOutput is an output of the workflow step with its value
+type StepOutput struct {
+	Name string `json:"name"`
+	// Value is the value of the output serialized as JSON.
+	Value string `json:"value"`
+}
+
+// WatchedObject is the identity of an object watched by the waiting step
+type WatchedObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// StepMessage is a message of the workflow step with the time it's recorded
+type StepMessage struct {
+	Message string      `json:"message"`
+	Time    metav1.Time `json:"time,omitempty"`
 }
 
 // WorkflowStepStatus record the status of a workflow step, include step status and subStep status
@@ -234,6 +441,33 @@ func (wr *WorkflowRun) GetCondition(t condition.ConditionType) condition.Conditi
 // WorkflowRunConditionType is a valid condition type for a WorkflowRun
 const WorkflowRunConditionType string = "WorkflowRun"
 
+// WorkflowRunBackupConditionType is the condition type for the backup of a WorkflowRun
+const WorkflowRunBackupConditionType string = "Backup"
+
+// WorkflowRunTerminatedConditionType is the condition type describing why a WorkflowRun ends without success
+const WorkflowRunTerminatedConditionType string = "Terminated"
+
+// WorkflowRunNotificationConditionType is the condition type for the delivery of the notifications of a WorkflowRun
+const WorkflowRunNotificationConditionType string = "Notification"
+
+// WorkflowRunDryRunCompletedConditionType is the condition type for the WorkflowRun finished in the dry-run mode,
+// whose step results are the ones it would have without mutating the cluster and the external systems
+const WorkflowRunDryRunCompletedConditionType string = "DryRunCompleted"
+
+// WorkflowRunQueuedConditionType is the condition type for the position of a WorkflowRun waiting in the queue of its namespace
+const WorkflowRunQueuedConditionType string = "Queued"
+
+const (
+	// TerminatedReasonUserTerminated means the workflow run is terminated by the user
+	TerminatedReasonUserTerminated = "UserTerminated"
+	// TerminatedReasonStepBreak means the workflow run is terminated by a step with op.#Break
+	TerminatedReasonStepBreak = "StepBreak"
+	// TerminatedReasonStepFailed means the workflow run is terminated because of a failed step
+	TerminatedReasonStepFailed = "StepFailed"
+	// TerminatedReasonTimeout means the workflow run is terminated because of a timed out step
+	TerminatedReasonTimeout = "Timeout"
+)
+
 // WorkflowStepPhase describes the phase of a workflow step.
 type WorkflowStepPhase string
 
@@ -256,11 +490,21 @@ const (
 type StepOutputs []outputItem
 
 // StepInputs defines variable input of WorkflowStep
-type StepInputs []inputItem
+type StepInputs []InputItem
 
-type inputItem struct {
-	ParameterKey string `json:"parameterKey"`
+// InputItem defines an input of WorkflowStep
+type InputItem struct {
+	ParameterKey string `json:"parameterKey,omitempty"`
 	From         string `json:"from"`
+	// Patch applies the input to the properties of the step instead of filling the parameter key, an object is
+	// applied as a JSON merge patch and a list is applied as a JSON patch of RFC6902
+	Patch bool `json:"patch,omitempty"`
+	// Optional indicates the input is not filled if the source is missing
+	Optional bool `json:"optional,omitempty"`
+	// Default is filled if the source is missing
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Default *runtime.RawExtension `json:"default,omitempty"`
 }
 
 type outputItem struct {
@@ -26,12 +26,238 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContextFromSource) DeepCopyInto(out *ContextFromSource) {
+	*out = *in
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContextFromSource.
+func (in *ContextFromSource) DeepCopy() *ContextFromSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ContextFromSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmailOverride) DeepCopyInto(out *EmailOverride) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmailOverride.
+func (in *EmailOverride) DeepCopy() *EmailOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(EmailOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExecuteWindow) DeepCopyInto(out *ExecuteWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExecuteWindow.
+func (in *ExecuteWindow) DeepCopy() *ExecuteWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(ExecuteWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExportOutputs) DeepCopyInto(out *ExportOutputs) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExportOutputs.
+func (in *ExportOutputs) DeepCopy() *ExportOutputs {
+	if in == nil {
+		return nil
+	}
+	out := new(ExportOutputs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GracefulTermination) DeepCopyInto(out *GracefulTermination) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	out.GracePeriod = in.GracePeriod
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GracefulTermination.
+func (in *GracefulTermination) DeepCopy() *GracefulTermination {
+	if in == nil {
+		return nil
+	}
+	out := new(GracefulTermination)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPOverride) DeepCopyInto(out *HTTPOverride) {
+	*out = *in
+	if in.BaseURLRewrite != nil {
+		in, out := &in.BaseURLRewrite, &out.BaseURLRewrite
+		*out = make([]URLRewrite, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPOverride.
+func (in *HTTPOverride) DeepCopy() *HTTPOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InputItem) DeepCopyInto(out *InputItem) {
+	*out = *in
+	if in.Default != nil {
+		in, out := &in.Default, &out.Default
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InputItem.
+func (in *InputItem) DeepCopy() *InputItem {
+	if in == nil {
+		return nil
+	}
+	out := new(InputItem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationOverride) DeepCopyInto(out *NotificationOverride) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationOverride.
+func (in *NotificationOverride) DeepCopy() *NotificationOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProviderOverrides) DeepCopyInto(out *ProviderOverrides) {
+	*out = *in
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(HTTPOverride)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Email != nil {
+		in, out := &in.Email, &out.Email
+		*out = new(EmailOverride)
+		**out = **in
+	}
+	if in.Notification != nil {
+		in, out := &in.Notification, &out.Notification
+		*out = new(NotificationOverride)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProviderOverrides.
+func (in *ProviderOverrides) DeepCopy() *ProviderOverrides {
+	if in == nil {
+		return nil
+	}
+	out := new(ProviderOverrides)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunNotification) DeepCopyInto(out *RunNotification) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Events != nil {
+		in, out := &in.Events, &out.Events
+		*out = make([]WorkflowRunPhase, len(*in))
+		copy(*out, *in)
+	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SigningSecretRef != nil {
+		in, out := &in.SigningSecretRef, &out.SigningSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunNotification.
+func (in *RunNotification) DeepCopy() *RunNotification {
+	if in == nil {
+		return nil
+	}
+	out := new(RunNotification)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in StepInputs) DeepCopyInto(out *StepInputs) {
 	{
 		in := &in
 		*out = make(StepInputs, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -64,11 +290,79 @@ func (in StepOutputs) DeepCopy() StepOutputs {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepMessage) DeepCopyInto(out *StepMessage) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepMessage.
+func (in *StepMessage) DeepCopy() *StepMessage {
+	if in == nil {
+		return nil
+	}
+	out := new(StepMessage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepOp) DeepCopyInto(out *StepOp) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepOp.
+func (in *StepOp) DeepCopy() *StepOp {
+	if in == nil {
+		return nil
+	}
+	out := new(StepOp)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepOutput) DeepCopyInto(out *StepOutput) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepOutput.
+func (in *StepOutput) DeepCopy() *StepOutput {
+	if in == nil {
+		return nil
+	}
+	out := new(StepOutput)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StepStatus) DeepCopyInto(out *StepStatus) {
 	*out = *in
+	if in.FailedOp != nil {
+		in, out := &in.FailedOp, &out.FailedOp
+		*out = new(StepOp)
+		**out = **in
+	}
 	in.FirstExecuteTime.DeepCopyInto(&out.FirstExecuteTime)
 	in.LastExecuteTime.DeepCopyInto(&out.LastExecuteTime)
+	if in.MessageHistory != nil {
+		in, out := &in.MessageHistory, &out.MessageHistory
+		*out = make([]StepMessage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make([]StepOutput, len(*in))
+		copy(*out, *in)
+	}
+	if in.Watches != nil {
+		in, out := &in.Watches, &out.Watches
+		*out = make([]WatchedObject, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepStatus.
@@ -81,6 +375,36 @@ func (in *StepStatus) DeepCopy() *StepStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *URLRewrite) DeepCopyInto(out *URLRewrite) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new URLRewrite.
+func (in *URLRewrite) DeepCopy() *URLRewrite {
+	if in == nil {
+		return nil
+	}
+	out := new(URLRewrite)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WatchedObject) DeepCopyInto(out *WatchedObject) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WatchedObject.
+func (in *WatchedObject) DeepCopy() *WatchedObject {
+	if in == nil {
+		return nil
+	}
+	out := new(WatchedObject)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Workflow) DeepCopyInto(out *Workflow) {
 	*out = *in
@@ -231,6 +555,51 @@ func (in *WorkflowRunSpec) DeepCopyInto(out *WorkflowRunSpec) {
 		*out = new(WorkflowSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ExportOutputs != nil {
+		in, out := &in.ExportOutputs, &out.ExportOutputs
+		*out = new(ExportOutputs)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ResourceLabels != nil {
+		in, out := &in.ResourceLabels, &out.ResourceLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ResourceAnnotations != nil {
+		in, out := &in.ResourceAnnotations, &out.ResourceAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Vars != nil {
+		in, out := &in.Vars, &out.Vars
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ContextFrom != nil {
+		in, out := &in.ContextFrom, &out.ContextFrom
+		*out = make([]ContextFromSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ProviderOverrides != nil {
+		in, out := &in.ProviderOverrides, &out.ProviderOverrides
+		*out = new(ProviderOverrides)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = make([]RunNotification, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowRunSpec.
@@ -248,6 +617,11 @@ func (in *WorkflowRunStatus) DeepCopyInto(out *WorkflowRunStatus) {
 	*out = *in
 	in.ConditionedStatus.DeepCopyInto(&out.ConditionedStatus)
 	out.Mode = in.Mode
+	if in.GracefulTermination != nil {
+		in, out := &in.GracefulTermination, &out.GracefulTermination
+		*out = new(GracefulTermination)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ContextBackend != nil {
 		in, out := &in.ContextBackend, &out.ContextBackend
 		*out = new(v1.ObjectReference)
@@ -327,6 +701,11 @@ func (in *WorkflowStepBase) DeepCopyInto(out *WorkflowStepBase) {
 		*out = new(WorkflowStepMeta)
 		**out = **in
 	}
+	if in.ExecuteWindow != nil {
+		in, out := &in.ExecuteWindow, &out.ExecuteWindow
+		*out = new(ExecuteWindow)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.DependsOn != nil {
 		in, out := &in.DependsOn, &out.DependsOn
 		*out = make([]string, len(*in))
@@ -30,25 +30,48 @@ import (
 
 	"github.com/crossplane/crossplane-runtime/pkg/event"
 	flag "github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	velaclient "github.com/kubevela/pkg/controller/client"
 	"github.com/kubevela/pkg/multicluster"
 
 	"github.com/kubevela/workflow/api/v1alpha1"
 	"github.com/kubevela/workflow/controllers"
+	"github.com/kubevela/workflow/pkg/apiserver"
+	"github.com/kubevela/workflow/pkg/backup"
 	"github.com/kubevela/workflow/pkg/common"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/correlation"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
 	"github.com/kubevela/workflow/pkg/cue/packages"
+	stepdefinition "github.com/kubevela/workflow/pkg/definition"
+	"github.com/kubevela/workflow/pkg/executor"
 	"github.com/kubevela/workflow/pkg/features"
+	"github.com/kubevela/workflow/pkg/generator"
+	"github.com/kubevela/workflow/pkg/history"
+	"github.com/kubevela/workflow/pkg/hooks"
+	"github.com/kubevela/workflow/pkg/logs"
 	"github.com/kubevela/workflow/pkg/monitor/watcher"
+	"github.com/kubevela/workflow/pkg/progress"
+	"github.com/kubevela/workflow/pkg/providers/kube"
+	"github.com/kubevela/workflow/pkg/providers/workspace"
+	"github.com/kubevela/workflow/pkg/tasks/template"
 	"github.com/kubevela/workflow/pkg/types"
+	"github.com/kubevela/workflow/pkg/webhook/definition"
+	"github.com/kubevela/workflow/pkg/webhook/workflowrun"
 	"github.com/kubevela/workflow/version"
 	//+kubebuilder:scaffold:imports
 )
@@ -65,14 +88,19 @@ func init() {
 }
 
 func main() {
-	var metricsAddr, logFilePath, probeAddr, pprofAddr, leaderElectionResourceLock string
-	var backupStrategy, backupIgnoreStrategy, backupPersistType, groupByLabel string
-	var enableLeaderElection, logDebug, backupCleanOnBackup bool
+	var metricsAddr, logFilePath, probeAddr, pprofAddr, apiAddr, apiCertFile, apiKeyFile, leaderElectionResourceLock, certDir string
+	var backupStrategy, backupIgnoreStrategy, backupPersistType, backupSecret, groupByLabel string
+	var enableLeaderElection, logDebug, backupCleanOnBackup, useWebhook bool
 	var qps float64
 	var logFileMaxSize uint64
 	var burst, webhookPort int
-	var leaseDuration, renewDeadline, retryPeriod time.Duration
+	var leaseDuration, renewDeadline, retryPeriod, drainTimeout time.Duration
 	var controllerArgs controllers.Args
+	var correlationKeys, backupPhases, backupTerminatedReasons []string
+	var backupLabelSelector, backupRetentionGroupLabel string
+	var backupRetentionCount int
+	var cuePackageDir, cuePackageNamespace, auditLog string
+	var watchNamespaces []string
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -89,18 +117,64 @@ func main() {
 		"The duration that the acting controlplane will retry refreshing leadership before giving up")
 	flag.DurationVar(&retryPeriod, "leader-election-retry-period", 2*time.Second,
 		"The duration the LeaderElector clients should wait between tries of actions")
+	flag.DurationVar(&drainTimeout, "drain-timeout", 30*time.Second,
+		"The duration to wait for the in-flight reconciles to finish on shutdown, the steps that are not started are not started while draining")
 	flag.IntVar(&webhookPort, "webhook-port", 9443, "admission webhook listen address")
+	flag.BoolVar(&useWebhook, "use-webhook", false, "Enable the admission webhooks of the workflow runs, which rejects the changes of spec.context after the run is started and stamps the creator of the run, and of the workflow step definitions, which validates their templates against the ops of the providers")
+	flag.StringVar(&certDir, "webhook-cert-dir", "/k8s-webhook-server/serving-certs", "The directory that contains the server key and certificate of the admission webhook")
+	flag.DurationVar(&controllerArgs.StaleRunThreshold, "stale-run-threshold", 10*time.Minute, "Set the duration after which the executing workflow runs not transited are re-queued with an event when the controller is started as the leader, 0 disables it, default is 10m")
+	flag.IntVar(&controllerArgs.MaxConcurrentRuns, "max-concurrent-runs-per-namespace", 0, "Set the max number of the concurrent executing workflow runs in a namespace, the runs beyond it are queued by spec.priority and the creation time until the executing runs finish, suspend or terminate, default is 0 which means no limit")
+	flag.StringVar(&controllerArgs.ConcurrencyConfigMap, "run-concurrency-configmap", "", "Set the ConfigMap(namespace/name) overriding the max-concurrent-runs-per-namespace per namespace, the data is keyed by the namespace with the limit as the value, the namespace is vela-system if not specified, default is empty")
 	flag.IntVar(&controllerArgs.ConcurrentReconciles, "concurrent-reconciles", 4, "concurrent-reconciles is the concurrent reconcile number of the controller. The default value is 4")
 	flag.Float64Var(&qps, "kube-api-qps", 50, "the qps for reconcile clients. Low qps may lead to low throughput. High qps may give stress to api-server. Raise this value if concurrent-reconciles is set to be high.")
 	flag.IntVar(&burst, "kube-api-burst", 100, "the burst for reconcile clients. Recommend setting it qps*2.")
+	flag.StringVar(&apiAddr, "api-addr", "", "The address for the http api to list, inspect, resume, terminate and retry the workflow runs to listen on, the requests are authenticated by the bearer tokens with TokenReview. The default value is empty which means do not expose it.")
+	flag.StringVar(&apiCertFile, "api-tls-cert-file", "", "The tls certificate file to serve the http api, it's required if the api-addr is set since the requests carry the bearer tokens.")
+	flag.StringVar(&apiKeyFile, "api-tls-key-file", "", "The tls key file to serve the http api, it's required if the api-addr is set since the requests carry the bearer tokens.")
 	flag.StringVar(&pprofAddr, "pprof-addr", "", "The address for pprof to use while exporting profiling results. The default value is empty which means do not expose it. Set it to address like :6666 to expose it.")
 	flag.IntVar(&types.MaxWorkflowWaitBackoffTime, "max-workflow-wait-backoff-time", 60, "Set the max workflow wait backoff time, default is 60")
 	flag.IntVar(&types.MaxWorkflowFailedBackoffTime, "max-workflow-failed-backoff-time", 300, "Set the max workflow wait backoff time, default is 300")
+	flag.IntVar(&types.MaxWatchesPerRun, "max-watches-per-run", 16, "Set the max number of the objects watched by the waiting steps of a workflow run, the changes of the watched objects trigger the reconcile of the run, the steps whose watches are beyond it are reconciled with the backoff, 0 disables the watches, default is 16")
+	flag.DurationVar(&types.WatchResyncPeriod, "watch-resync-period", 0, "Set the period to reconcile the workflow runs whose running steps are all watching the objects, it replaces the shorter backoff of the waiting steps, 0 keeps the backoff, default is 0")
+	flag.StringSliceVar(&kube.PruneAllowedKinds, "prune-allowed-kinds", kube.PruneAllowedKinds, "Set the kinds of the objects allowed to be pruned by the label selectors of the prune op, the steps pruning the other kinds are failed, the kinds are qualified by the groups as Kind.group, * allows all kinds, default is ConfigMap,Secret,Service,Deployment.apps,StatefulSet.apps,DaemonSet.apps,Job.batch,CronJob.batch,Ingress.networking.k8s.io")
+	flag.BoolVar(&value.DefaultStrictUnmarshal, "strict-unmarshal", false, "Set the default of the strict flag of the ops, the unknown fields in the parameters of the ops are rejected if it's enabled, default is false")
+	flag.StringVar(&value.DefaultProfile, "default-cue-profile", value.ProfileV06Compat, "Set the default cue profile for the steps that do not declare one, default is v0.6-compat")
+	flag.DurationVar(&progress.Interval, "live-progress-interval", time.Second, "Set the min interval between two writes of the live progress of a workflow run, default is 1s")
+	flag.StringSliceVar(&correlationKeys, "correlation-annotation-keys", nil, "Set the annotation keys of the workflow run to propagate as correlation ids into the provider calls, default is empty")
+	flag.IntVar(&template.DefinitionCacheSize, "definition-cache-size", 1000, "Set the max number of the step definition templates cached, the cache entry is invalidated when the definition is updated, 0 disables the cache, default is 1000")
+	flag.IntVar(&template.RevisionHistoryLimit, "definition-revision-history-limit", 10, "Set the max number of the revisions kept for each step definition, the oldest revisions beyond it are deleted when a new revision is created, 0 keeps all of them, default is 10")
+	flag.StringVar(&cuePackageDir, "cue-package-dir", "", "Set the directory to load the custom cue packages from, the cue files in each sub directory are loaded as a package imported by the relative path of the sub directory, default is empty")
+	flag.StringVar(&template.BuiltinOverridesConfigMap, "builtin-template-overrides", "", "Set the ConfigMap(namespace/name) whose keys override the builtin step templates of the same names, it's hot reloaded when changed and the overrides failing the validation are rejected with an event on the ConfigMap, the namespace is vela-system if not specified, default is empty which disables it")
+	flag.StringVar(&cuePackageNamespace, "cue-package-namespace", "", "Set the namespace of the ConfigMaps labeled with cue.oam.dev/package=true to load and hot reload the custom cue packages from, default is empty which disables it")
 	flag.IntVar(&types.MaxWorkflowStepErrorRetryTimes, "max-workflow-step-error-retry-times", 10, "Set the max workflow step error retry times, default is 10")
-	flag.StringVar(&backupStrategy, "backup-strategy", "RemainLatestFailedRecord", "Set the strategy for backup workflow records, default is RemainLatestFailedRecord")
+	flag.IntVar(&types.MaxConsecutiveFailures, "max-consecutive-failures", 0, "Set the consecutive failures of a step to suspend the workflow run until it's resumed manually, it can be overridden by the annotation workflowrun.oam.dev/max-consecutive-failures of the run, default is 0 which disables it")
+	flag.IntVar(&types.MaxStepMessageHistory, "max-step-message-history", 10, "Set the max number of the distinct messages kept in the message history of a step, 0 disables the message history, default is 10")
+	flag.IntVar(&types.MaxStepMessageSize, "max-step-message-size", 1024, "Set the max size in bytes of the message of a step, the message beyond it is truncated and the full message is stored in the overflow ConfigMap of the workflow context, 0 means no limit, default is 1024")
+	flag.BoolVar(&types.AllowProviderOverrides, "allow-provider-overrides", true, "Set whether the workflow runs are allowed to override the endpoints of the providers with spec.providerOverrides, the runs with the overrides are rejected by the webhook and the overrides are ignored if it's disabled, it should be disabled in the production clusters, default is true")
+	flag.BoolVar(&types.DryRunMode, "dry-run-mode", false, "Set whether to execute all the workflow runs in the dry-run mode, the writes to the cluster are dry-run by the server, the http requests, the emails and the notifications are skipped and the workflow contexts are kept in memory, the runs annotated with workflowrun.oam.dev/dry-run=true are executed in the dry-run mode even if it's disabled, default is false")
+	flag.BoolVar(&workspace.RedactSelf, "redact-read-self", true, "Set whether to redact the urls and the headers of the notifications and the properties of the other steps in the workflow run read by the read-self op, default is true")
+	flag.StringSliceVar(&generator.DefaultStepTypePolicy.Allowed, "allowed-step-types", nil, "Set the glob patterns of the step types allowed in the workflow runs, the runs with the other step types are failed, default is empty which means all types")
+	flag.StringSliceVar(&generator.DefaultStepTypePolicy.Denied, "denied-step-types", nil, "Set the glob patterns of the step types denied in the workflow runs, the runs with the denied step types are failed, default is empty")
+	flag.StringVar(&generator.StepTypePolicyConfigMap, "step-type-policy-configmap", "", "Set the ConfigMap(namespace/name) overriding the allowed and denied step types per namespace, the data is keyed by the namespace with the value like {\"allowed\":[\"*\"],\"denied\":[]}, the namespace is vela-system if not specified, default is empty")
+	flag.StringSliceVar(&watchNamespaces, "watch-namespaces", nil, "Set the namespaces to watch and cache the resources in, the namespaces of the ConfigMaps and the Secrets read by the controller must be included, default is empty which means all namespaces")
+	flag.DurationVar(&generator.DefaultStepTimeout, "default-step-timeout", 0, "Set the timeout of the steps that do not declare one, the suspend steps and the step groups are excluded, 0 means no timeout, default is 0")
+	flag.DurationVar(&generator.DefaultWorkflowTimeout, "default-workflow-timeout", 0, "Set the timeout of the workflow runs that do not declare one, the unfinished steps are failed when exceeded, 0 means no timeout, default is 0")
+	flag.StringVar(&auditLog, "audit-log", "", "Set the destination of the audit log recording every op executed by the steps as JSON lines, stdout or the path of the file to append to, default is empty which disables it")
+	flag.BoolVar(&executor.EnableFaultInjection, "enable-fault-injection", false, "Enable the annotations workflowrun.oam.dev/inject-failure and workflowrun.oam.dev/inject-latency of the workflow runs to inject the failures and the latencies into the steps, it's only for testing and must not be enabled in production, default is false")
+	flag.IntVar(&hooks.MaxOutputSize, "max-output-size", 0, "Set the max size in bytes of a step output kept in the workflow context, the outputs exceeding it are handled by the output-overflow-strategy, default is 0 which means no limit")
+	flag.IntVar(&logs.MaxSize, "max-collected-logs-size", 256*1024, "Set the max size in bytes of the logs collected from the pods of a step by collect-logs, the logs beyond it are truncated, default is 262144")
+	flag.StringVar(&hooks.OutputOverflowStrategy, "output-overflow-strategy", hooks.OutputOverflowConfigMap, "Set how the outputs exceeding the max-output-size are stored, configmap moves them to the overflow ConfigMap owned by the run and keeps the references, truncate keeps them as the truncated strings, default is configmap")
+	flag.IntVar(&wfContext.MaxOverflowSize, "max-overflow-size", 900*1024, "Set the max size in bytes of the values stored in the overflow ConfigMap of a workflow run, the values beyond it are refused as the size of a ConfigMap is limited, 0 means no limit, default is 921600")
+	flag.StringVar(&backupStrategy, "backup-strategy", "RemainLatestFailedRecord", "Set the strategy for backup workflow records, BackupFinishedRecord or s3 which persists the records to the S3-compatible object storage configured by the backup-secret, default is RemainLatestFailedRecord")
 	flag.StringVar(&backupIgnoreStrategy, "backup-ignore-strategy", "IgnoreLatestFailedRecord", "Set the strategy for ignore backup workflow records, default is IgnoreLatestFailedRecord")
 	flag.StringVar(&backupPersistType, "backup-persist-type", "", "Set the persist type for backup workflow records, default is empty")
+	flag.StringVar(&backupSecret, "backup-secret", "", "Set the secret(namespace/name) contains the config of the persister for backup workflow records, the namespace is vela-system if not specified, default is empty")
 	flag.StringVar(&groupByLabel, "backup-group-by-label", "", "Set the label for group by, default is empty")
+	flag.StringVar(&backupLabelSelector, "backup-label-selector", "", "Set the label selector for the workflow runs to backup, changing it does not delete the records backed up before, default is empty which means all runs")
+	flag.StringSliceVar(&backupPhases, "backup-phases", nil, "Set the phases of the workflow runs to backup, e.g. Failed,Terminated, changing it does not delete the records backed up before, default is empty which means all phases")
+	flag.StringSliceVar(&backupTerminatedReasons, "backup-terminated-reasons", nil, "Set the reasons of the Terminated condition of the workflow runs to backup, e.g. UserTerminated,StepBreak,StepFailed,Timeout, default is empty which means all runs")
+	flag.IntVar(&backupRetentionCount, "backup-retention-count", 0, "Set the max number of the backed up workflow records kept in each group, the oldest records beyond it are deleted after a new record is persisted, default is 0 which means no limit")
+	flag.StringVar(&backupRetentionGroupLabel, "backup-retention-group-label", "", "Set the label of the workflow runs to group the backed up records for retention, e.g. workflowrun.oam.dev/name, default is empty which means grouping by namespace")
 	flag.BoolVar(&backupCleanOnBackup, "backup-clean-on-backup", false, "Set the auto clean for backup workflow records, default is false")
 	multicluster.AddClusterGatewayClientFlags(flag.CommandLine)
 	feature.DefaultMutableFeatureGate.AddFlag(flag.CommandLine)
@@ -113,6 +187,21 @@ func main() {
 		_ = flag.Set("v", strconv.Itoa(int(common.LogDebug)))
 	}
 
+	if err := correlation.SetKeys(correlationKeys); err != nil {
+		klog.Error(err, "invalid correlation annotation keys")
+		os.Exit(1)
+	}
+
+	if hooks.OutputOverflowStrategy != hooks.OutputOverflowConfigMap && hooks.OutputOverflowStrategy != hooks.OutputOverflowTruncate {
+		klog.Errorf("invalid output overflow strategy %s, it should be %s or %s", hooks.OutputOverflowStrategy, hooks.OutputOverflowConfigMap, hooks.OutputOverflowTruncate)
+		os.Exit(1)
+	}
+
+	if _, err := value.GetProfile(""); err != nil {
+		klog.Error(err, "invalid default cue profile")
+		os.Exit(1)
+	}
+
 	if pprofAddr != "" {
 		// Start pprof server if enabled
 		mux := http.NewServeMux()
@@ -166,10 +255,11 @@ func main() {
 	)
 
 	leaderElectionID := fmt.Sprintf("workflow-%s", strings.ToLower(strings.ReplaceAll(version.VelaVersion, ".", "-")))
-	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+	options := ctrl.Options{
 		Scheme:                     scheme,
 		MetricsBindAddress:         metricsAddr,
 		Port:                       webhookPort,
+		CertDir:                    certDir,
 		HealthProbeBindAddress:     probeAddr,
 		LeaderElection:             enableLeaderElection,
 		LeaderElectionID:           leaderElectionID,
@@ -177,8 +267,16 @@ func main() {
 		LeaseDuration:              &leaseDuration,
 		RenewDeadline:              &renewDeadline,
 		RetryPeriod:                &retryPeriod,
+		GracefulShutdownTimeout:    &drainTimeout,
 		NewClient:                  velaclient.DefaultNewControllerClient,
-	})
+	}
+	switch {
+	case len(watchNamespaces) == 1:
+		options.Namespace = watchNamespaces[0]
+	case len(watchNamespaces) > 1:
+		options.NewCache = cache.MultiNamespacedCacheBuilder(watchNamespaces)
+	}
+	mgr, err := ctrl.NewManager(restConfig, options)
 	if err != nil {
 		klog.Error(err, "unable to start manager")
 		os.Exit(1)
@@ -191,27 +289,134 @@ func main() {
 			os.Exit(1)
 		}
 	}
+	if cuePackageDir != "" {
+		if err := pd.LoadCustomPackagesFromDir(cuePackageDir); err != nil {
+			klog.Error(err, "Failed to load the custom cue packages", "dir", cuePackageDir)
+			os.Exit(1)
+		}
+	}
+	if cuePackageNamespace != "" {
+		kubeClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			klog.Error(err, "Failed to create the client to watch the custom cue packages")
+			os.Exit(1)
+		}
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return pd.WatchCustomPackages(ctx, kubeClient, cuePackageNamespace)
+		})); err != nil {
+			klog.Error(err, "Failed to watch the custom cue packages")
+			os.Exit(1)
+		}
+	}
+	if template.BuiltinOverridesConfigMap != "" {
+		kubeClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			klog.Error(err, "Failed to create the client to watch the builtin template overrides")
+			os.Exit(1)
+		}
+		validator := stepdefinition.NewValidator(pd, nil)
+		recorder := event.NewAPIRecorder(mgr.GetEventRecorderFor("WorkflowStepTemplate"))
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return template.WatchBuiltinOverrides(ctx, kubeClient, func(templ string) error { return validator.Validate(templ) }, recorder)
+		})); err != nil {
+			klog.Error(err, "Failed to watch the builtin template overrides")
+			os.Exit(1)
+		}
+	}
 
+	logsConfig := rest.CopyConfig(mgr.GetConfig())
+	logsConfig.Wrap(multicluster.NewTransportWrapper())
+	logsClient, err := kubernetes.NewForConfig(logsConfig)
+	if err != nil {
+		klog.Error(err, "Failed to create the client to collect the logs of the pods")
+		os.Exit(1)
+	}
+	var auditSink types.AuditSink
+	if auditLog != "" {
+		if auditSink, err = executor.OpenAuditSink(auditLog); err != nil {
+			klog.Error(err, "Failed to open the audit log", "path", auditLog)
+			os.Exit(1)
+		}
+	}
+	gate := executor.NewGate(clock.RealClock{})
 	if err = (&controllers.WorkflowRunReconciler{
 		Client:          mgr.GetClient(),
 		Scheme:          mgr.GetScheme(),
 		PackageDiscover: pd,
 		Recorder:        event.NewAPIRecorder(mgr.GetEventRecorderFor("WorkflowRun")),
+		Gate:            gate,
+		KubeClient:      logsClient,
+		AuditSink:       auditSink,
 		Args:            controllerArgs,
 	}).SetupWithManager(mgr); err != nil {
 		klog.Error(err, "unable to create controller", "controller", "WorkflowRun")
 		os.Exit(1)
 	}
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		klog.Info("Draining the workflow runs")
+		if !gate.Drain(drainTimeout) {
+			klog.Warning("The in-flight reconciles are not finished before the drain timeout")
+		}
+		return nil
+	})); err != nil {
+		klog.Error(err, "Failed to add the drain of the workflow runs")
+		os.Exit(1)
+	}
+
+	if apiAddr != "" {
+		if apiCertFile == "" || apiKeyFile == "" {
+			klog.Error("The api-tls-cert-file and the api-tls-key-file are required to serve the http api")
+			os.Exit(1)
+		}
+		kubeClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			klog.Error(err, "Failed to create the client to review the tokens of the api")
+			os.Exit(1)
+		}
+		if err := mgr.Add(apiserver.New(apiAddr, apiCertFile, apiKeyFile, mgr.GetClient(), kubeClient)); err != nil {
+			klog.Error(err, "Failed to add the api server")
+			os.Exit(1)
+		}
+	}
+
+	if useWebhook {
+		if err = workflowrun.Register(mgr); err != nil {
+			klog.Error(err, "unable to register webhook", "webhook", "WorkflowRun")
+			os.Exit(1)
+		}
+		definition.Register(mgr, pd)
+	}
 
 	if feature.DefaultMutableFeatureGate.Enabled(features.EnableBackupWorkflowRecord) {
+		var selector labels.Selector
+		if backupLabelSelector != "" {
+			if selector, err = labels.Parse(backupLabelSelector); err != nil {
+				klog.Error(err, "invalid backup label selector")
+				os.Exit(1)
+			}
+		}
+		var phases []v1alpha1.WorkflowRunPhase
+		for _, phase := range backupPhases {
+			phases = append(phases, v1alpha1.WorkflowRunPhase(strings.ToLower(strings.TrimSpace(phase))))
+		}
 		if err = (&controllers.BackupReconciler{
 			Client: mgr.GetClient(),
 			Scheme: mgr.GetScheme(),
 			BackupArgs: controllers.BackupArgs{
-				BackupStrategy: backupStrategy,
-				IgnoreStrategy: backupIgnoreStrategy,
-				CleanOnBackup:  backupCleanOnBackup,
-				GroupByLabel:   groupByLabel,
+				PersistType:       backup.PersistType(backupPersistType),
+				PersistSecret:     backupSecret,
+				BackupStrategy:    backupStrategy,
+				IgnoreStrategy:    backupIgnoreStrategy,
+				CleanOnBackup:     backupCleanOnBackup,
+				GroupByLabel:      groupByLabel,
+				LabelSelector:     selector,
+				Phases:            phases,
+				TerminatedReasons: backupTerminatedReasons,
+				Retention: backup.Retention{
+					Count:        backupRetentionCount,
+					GroupByLabel: backupRetentionGroupLabel,
+				},
 			},
 			Args: controllerArgs,
 		}).SetupWithManager(mgr); err != nil {
@@ -237,6 +442,11 @@ func main() {
 	}
 	watcher.StartWorkflowRunMetricsWatcher(informer)
 
+	// the history of the finished runs is kept in memory, rebuild it before the runs are reconciled
+	if err := history.Rebuild(context.Background(), mgr.GetAPIReader(), history.DefaultStore); err != nil {
+		klog.Error(err, "Failed to rebuild the history of the workflow runs")
+	}
+
 	klog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		klog.Error(err, "problem running manager")
@@ -19,11 +19,15 @@ package controllers
 import (
 	"context"
 	"errors"
-	"reflect"
 	"sort"
+	"strings"
+	"sync"
 
+	pkgerrors "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -33,26 +37,73 @@ import (
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
+	"github.com/kubevela/workflow/api/condition"
 	"github.com/kubevela/workflow/api/v1alpha1"
 	"github.com/kubevela/workflow/pkg/backup"
 	"github.com/kubevela/workflow/pkg/types"
 )
 
+// MaxBackupAttempts is the max number of the failed attempts to persist a workflow run, the run cleaned on backup
+// is deleted after the attempts are exhausted so that the failed uploads don't block its deletion
+var MaxBackupAttempts = 5
+
 // BackupReconciler reconciles a WorkflowRun object
 type BackupReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 	BackupArgs
 	Args
+	// failures is the number of the failed attempts to persist the runs keyed by the uid
+	failures sync.Map
 }
 
 // BackupArgs is the args for backup
 type BackupArgs struct {
-	PersistType    backup.PersistType
+	PersistType backup.PersistType
+	// PersistSecret is the secret(namespace/name) contains the config of the persister
+	PersistSecret  string
 	BackupStrategy string
 	IgnoreStrategy string
 	GroupByLabel   string
 	CleanOnBackup  bool
+	// LabelSelector filters the workflow runs to backup, all runs are matched if it's nil
+	LabelSelector labels.Selector
+	// Phases filters the phases of the workflow runs to backup, all phases are matched if it's empty
+	Phases []v1alpha1.WorkflowRunPhase
+	// TerminatedReasons filters the reasons of the Terminated condition of the workflow runs to backup,
+	// e.g. UserTerminated, all runs are matched if it's empty
+	TerminatedReasons []string
+	// Retention is the retention policy of the persisted records
+	Retention backup.Retention
+}
+
+func (args BackupArgs) matches(run *v1alpha1.WorkflowRun) bool {
+	// the runs in the dry-run mode have nothing to keep
+	if types.IsRunDryRun(run) {
+		return false
+	}
+	if args.LabelSelector != nil && !args.LabelSelector.Matches(labels.Set(run.Labels)) {
+		return false
+	}
+	if len(args.TerminatedReasons) > 0 {
+		cond := run.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunTerminatedConditionType))
+		matched := false
+		for _, reason := range args.TerminatedReasons {
+			matched = matched || strings.EqualFold(reason, string(cond.Reason))
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(args.Phases) == 0 {
+		return true
+	}
+	for _, phase := range args.Phases {
+		if strings.EqualFold(string(phase), string(run.Status.Phase)) {
+			return true
+		}
+	}
+	return false
 }
 
 const (
@@ -60,6 +111,9 @@ const (
 	StrategyIgnoreLatestFailed string = "IgnoreLatestFailedRecord"
 	// StrategyBackupFinishedRecord is the backup strategy to backup all finished workflowrun
 	StrategyBackupFinishedRecord string = "BackupFinishedRecord"
+	// StrategyObjectStorage is the backup strategy to backup all finished workflowrun to the S3-compatible object
+	// storage configured by the backup secret, the persist type is implied
+	StrategyObjectStorage string = "s3"
 )
 
 // Reconcile reconciles the WorkflowRun object
@@ -91,9 +145,13 @@ func (r *BackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		logCtx.Info("WorkflowRun is not finished, skip reconcile")
 		return ctrl.Result{}, nil
 	}
+	if !r.matches(run) {
+		logCtx.Info("WorkflowRun does not match the backup filter, skip reconcile")
+		return ctrl.Result{}, nil
+	}
 
 	switch r.BackupStrategy {
-	case StrategyBackupFinishedRecord:
+	case StrategyBackupFinishedRecord, StrategyObjectStorage:
 		if r.IgnoreStrategy == StrategyIgnoreLatestFailed {
 			latest, failedList, err := isLatestFailedRecord(ctx, r.Client, run, r.GroupByLabel)
 			if err != nil {
@@ -108,6 +166,9 @@ func (r *BackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			}
 			if len(failedList) > 1 {
 				for _, item := range failedList[1:] {
+					if !r.matches(&item) {
+						continue
+					}
 					if err := r.backup(logCtx, r.Client, &item); err != nil {
 						logCtx.Error(err, "failed to backup workflowrun", "workflowrun", run.Name)
 						return ctrl.Result{}, err
@@ -130,21 +191,85 @@ func (r *BackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 }
 
 func (r *BackupReconciler) backup(ctx monitorContext.Context, cli client.Client, run *v1alpha1.WorkflowRun) error {
-	persister := backup.NewPersister(r.PersistType)
+	config, err := r.getPersistConfig(ctx, cli)
+	if err != nil {
+		return err
+	}
+	persistType := r.PersistType
+	if r.BackupStrategy == StrategyObjectStorage {
+		persistType = backup.PersistTypeS3
+	}
+	persister, err := backup.NewPersister(cli, persistType, config, r.Retention)
+	if err != nil {
+		return err
+	}
+	persisted := persister != nil
 	if persister != nil {
 		if err := persister.Store(ctx, run); err != nil {
-			return err
+			r.setBackupCondition(ctx, cli, run, condition.ErrorCondition(v1alpha1.WorkflowRunBackupConditionType, err))
+			// the failed upload is retried by the requeue of the run with backoff
+			if !r.CleanOnBackup || !r.attemptsExhausted(run) {
+				return err
+			}
+			ctx.Error(err, "Give up backing up workflowrun after the attempts are exhausted", "workflowrun", run.Name, "attempts", MaxBackupAttempts)
+			persisted = false
+		} else {
+			r.failures.Delete(run.UID)
+			if cond := run.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunBackupConditionType)); cond.Status == corev1.ConditionFalse {
+				r.setBackupCondition(ctx, cli, run, condition.ReadyCondition(v1alpha1.WorkflowRunBackupConditionType))
+			}
 		}
 	}
 	if r.CleanOnBackup {
+		r.failures.Delete(run.UID)
 		if err := cli.Delete(ctx, run); err != nil && !kerrors.IsNotFound(err) {
 			return err
 		}
+	} else if persisted && run.Annotations[types.AnnotationWorkflowRunBackedUp] != "true" {
+		patch := client.MergeFrom(run.DeepCopy())
+		metav1.SetMetaDataAnnotation(&run.ObjectMeta, types.AnnotationWorkflowRunBackedUp, "true")
+		if err := cli.Patch(ctx, run, patch); err != nil {
+			return pkgerrors.WithMessage(err, "mark workflowrun as backed up")
+		}
+	}
+	if persisted {
+		ctx.Info("Successfully backup workflowrun", "workflowrun", run.Name)
 	}
-	ctx.Info("Successfully backup workflowrun", "workflowrun", run.Name)
 	return nil
 }
 
+// attemptsExhausted records a failed attempt to persist the run, and returns whether the attempts are exhausted
+func (r *BackupReconciler) attemptsExhausted(run *v1alpha1.WorkflowRun) bool {
+	attempts := 1
+	if v, ok := r.failures.Load(run.UID); ok {
+		attempts = v.(int) + 1
+	}
+	r.failures.Store(run.UID, attempts)
+	return attempts >= MaxBackupAttempts
+}
+
+func (r *BackupReconciler) getPersistConfig(ctx context.Context, cli client.Client) (map[string][]byte, error) {
+	if r.PersistSecret == "" {
+		return nil, nil
+	}
+	key := client.ObjectKey{Namespace: types.DefaultKubeVelaNS, Name: r.PersistSecret}
+	if ns, name, found := strings.Cut(r.PersistSecret, "/"); found {
+		key = client.ObjectKey{Namespace: ns, Name: name}
+	}
+	secret := &corev1.Secret{}
+	if err := cli.Get(ctx, key, secret); err != nil {
+		return nil, pkgerrors.WithMessage(err, "get backup secret")
+	}
+	return secret.Data, nil
+}
+
+func (r *BackupReconciler) setBackupCondition(ctx monitorContext.Context, cli client.Client, run *v1alpha1.WorkflowRun, cond condition.Condition) {
+	run.Status.SetConditions(cond)
+	if err := cli.Status().Patch(ctx, run, client.Merge); err != nil {
+		ctx.Error(err, "failed to set backup condition", "workflowrun", run.Name)
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *BackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -155,24 +280,27 @@ func (r *BackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			// filter the changes in workflow status
 			// let workflow handle its reconcile
 			UpdateFunc: func(e ctrlEvent.UpdateEvent) bool {
-				new := e.ObjectNew.DeepCopyObject().(*v1alpha1.WorkflowRun)
-				old := e.ObjectOld.DeepCopyObject().(*v1alpha1.WorkflowRun)
-				// if the workflow is not finished, skip the reconcile
-				if !new.Status.Finished {
-					return false
-				}
-
-				return !reflect.DeepEqual(old, new)
+				return r.backupTriggered(e.ObjectOld.(*v1alpha1.WorkflowRun), e.ObjectNew.(*v1alpha1.WorkflowRun))
 			},
 			CreateFunc: func(e ctrlEvent.CreateEvent) bool {
 				run := e.Object.DeepCopyObject().(*v1alpha1.WorkflowRun)
-				return run.Status.Finished
+				return run.Status.Finished && r.matches(run)
 			},
 		}).
 		For(&v1alpha1.WorkflowRun{}).
 		Complete(r)
 }
 
+// backupTriggered returns whether the update of the run triggers the backup, which is the run just finished or just
+// matched by the filter. The other updates of the finished run, e.g. the backup condition and the annotation set by
+// the backup itself, are skipped so that the run is not uploaded again.
+func (r *BackupReconciler) backupTriggered(old, new *v1alpha1.WorkflowRun) bool {
+	if !new.Status.Finished || !r.matches(new) {
+		return false
+	}
+	return !old.Status.Finished || !r.matches(old)
+}
+
 func isLatestFailedRecord(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun, groupByLabel string) (bool, []v1alpha1.WorkflowRun, error) {
 	if run.Status.Phase != v1alpha1.WorkflowStateFailed {
 		return false, nil, nil
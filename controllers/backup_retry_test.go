@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/condition"
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/backup"
+)
+
+func TestBackupTriggered(t *testing.T) {
+	r := require.New(t)
+	reconciler := &BackupReconciler{BackupArgs: BackupArgs{Phases: []v1alpha1.WorkflowRunPhase{v1alpha1.WorkflowStateFailed}}}
+	running := &v1alpha1.WorkflowRun{Status: v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateExecuting}}
+	failed := &v1alpha1.WorkflowRun{Status: v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateFailed, Finished: true}}
+	succeeded := &v1alpha1.WorkflowRun{Status: v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateSucceeded, Finished: true}}
+	r.True(reconciler.backupTriggered(running, failed))
+	r.True(reconciler.backupTriggered(succeeded, failed))
+	r.False(reconciler.backupTriggered(running, succeeded))
+	r.False(reconciler.backupTriggered(failed, running))
+
+	// the backup condition set by the backup doesn't trigger it again
+	withCondition := failed.DeepCopy()
+	withCondition.Status.SetConditions(condition.ReadyCondition(v1alpha1.WorkflowRunBackupConditionType))
+	r.False(reconciler.backupTriggered(failed, withCondition))
+}
+
+func TestBackupRetry(t *testing.T) {
+	defer func(attempts int) { MaxBackupAttempts = attempts }(MaxBackupAttempts)
+	MaxBackupAttempts = 2
+	r := require.New(t)
+	uploads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		uploads++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "wr", Namespace: "default", UID: "uid"},
+		Status:     v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateSucceeded, Finished: true},
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(run, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "vela-system"},
+		Data: map[string][]byte{
+			backup.S3EndpointKey:        []byte(server.URL),
+			backup.S3BucketKey:          []byte("records"),
+			backup.S3AccessKeyIDKey:     []byte("id"),
+			backup.S3SecretAccessKeyKey: []byte("secret"),
+		},
+	}).Build()
+	reconciler := &BackupReconciler{
+		Client: cli,
+		BackupArgs: BackupArgs{
+			PersistSecret:  "backup",
+			BackupStrategy: StrategyObjectStorage,
+			CleanOnBackup:  true,
+		},
+	}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	key := client.ObjectKeyFromObject(run)
+
+	// the failed upload is recorded in the condition and retried by the requeue
+	r.NoError(cli.Get(ctx, key, run))
+	r.Error(reconciler.backup(ctx, cli, run))
+	r.Equal(1, uploads)
+	r.NoError(cli.Get(ctx, key, run))
+	cond := run.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunBackupConditionType))
+	r.Equal(corev1.ConditionFalse, cond.Status)
+
+	// the run is cleaned after the attempts are exhausted
+	r.NoError(reconciler.backup(ctx, cli, run))
+	r.Equal(2, uploads)
+	r.Error(cli.Get(ctx, key, run))
+}
@@ -26,9 +26,11 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/kubevela/workflow/api/condition"
 	"github.com/kubevela/workflow/api/v1alpha1"
 	"github.com/kubevela/workflow/pkg/utils"
 )
@@ -117,6 +119,87 @@ var _ = Describe("Test Backup", func() {
 		}, wrObj)).Should(utils.NotFoundMatcher{})
 	})
 
+	It("workflow not matched with backup filter", func() {
+		selector, err := labels.Parse("backup=true")
+		Expect(err).Should(BeNil())
+		backupReconciler := &BackupReconciler{
+			Client: k8sClient,
+			Scheme: testScheme,
+			BackupArgs: BackupArgs{
+				BackupStrategy: StrategyBackupFinishedRecord,
+				CleanOnBackup:  true,
+				LabelSelector:  selector,
+				Phases:         []v1alpha1.WorkflowRunPhase{v1alpha1.WorkflowStateFailed},
+			},
+		}
+		wr := wrTemplate.DeepCopy()
+		wr.Name = "not-matched"
+		wr.Labels = map[string]string{"backup": "true"}
+		Expect(k8sClient.Create(ctx, wr)).Should(BeNil())
+		wr.Status.Finished = true
+		wr.Status.Phase = v1alpha1.WorkflowStateSucceeded
+		Expect(k8sClient.Status().Update(ctx, wr)).Should(BeNil())
+
+		tryReconcileBackup(backupReconciler, wr.Name, wr.Namespace)
+		wrObj := &v1alpha1.WorkflowRun{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{
+			Name:      wr.Name,
+			Namespace: wr.Namespace,
+		}, wrObj)).Should(BeNil())
+
+		wrObj.Status.Phase = v1alpha1.WorkflowStateFailed
+		Expect(k8sClient.Status().Update(ctx, wrObj)).Should(BeNil())
+		tryReconcileBackup(backupReconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, client.ObjectKey{
+			Name:      wr.Name,
+			Namespace: wr.Namespace,
+		}, wrObj)).Should(utils.NotFoundMatcher{})
+	})
+
+	It("workflow not matched with backup terminated reasons", func() {
+		backupReconciler := &BackupReconciler{
+			Client: k8sClient,
+			Scheme: testScheme,
+			BackupArgs: BackupArgs{
+				BackupStrategy:    StrategyBackupFinishedRecord,
+				CleanOnBackup:     true,
+				TerminatedReasons: []string{v1alpha1.TerminatedReasonStepFailed},
+			},
+		}
+		wr := wrTemplate.DeepCopy()
+		wr.Name = "not-matched-reason"
+		Expect(k8sClient.Create(ctx, wr)).Should(BeNil())
+		wr.Status.Finished = true
+		wr.Status.Phase = v1alpha1.WorkflowStateTerminated
+		wr.SetConditions(condition.Condition{
+			Type:               condition.ConditionType(v1alpha1.WorkflowRunTerminatedConditionType),
+			Status:             corev1.ConditionTrue,
+			Reason:             v1alpha1.TerminatedReasonUserTerminated,
+			LastTransitionTime: metav1.Now(),
+		})
+		Expect(k8sClient.Status().Update(ctx, wr)).Should(BeNil())
+
+		tryReconcileBackup(backupReconciler, wr.Name, wr.Namespace)
+		wrObj := &v1alpha1.WorkflowRun{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{
+			Name:      wr.Name,
+			Namespace: wr.Namespace,
+		}, wrObj)).Should(BeNil())
+
+		wrObj.SetConditions(condition.Condition{
+			Type:               condition.ConditionType(v1alpha1.WorkflowRunTerminatedConditionType),
+			Status:             corev1.ConditionTrue,
+			Reason:             v1alpha1.TerminatedReasonStepFailed,
+			LastTransitionTime: metav1.Now(),
+		})
+		Expect(k8sClient.Status().Update(ctx, wrObj)).Should(BeNil())
+		tryReconcileBackup(backupReconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, client.ObjectKey{
+			Name:      wr.Name,
+			Namespace: wr.Namespace,
+		}, wrObj)).Should(utils.NotFoundMatcher{})
+	})
+
 	It("no strategy specified", func() {
 		backupReconciler := &BackupReconciler{
 			Client: k8sClient,
@@ -0,0 +1,60 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubevela/workflow/api/condition"
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/dryrun"
+	"github.com/kubevela/workflow/pkg/notification"
+)
+
+// reasonDryRun is the reason of the conditions of the run finished in the dry-run mode
+const reasonDryRun = "DryRun"
+
+// dryRunCompletedCondition returns the condition of the run finished in the dry-run mode, the step results in the
+// status are the ones the run would have
+func dryRunCompletedCondition(run *v1alpha1.WorkflowRun) condition.Condition {
+	return condition.Condition{
+		Type:               condition.ConditionType(v1alpha1.WorkflowRunDryRunCompletedConditionType),
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reasonDryRun,
+		Message:            fmt.Sprintf("the run would be %s, the cluster and the external systems are not changed", run.Status.Phase),
+	}
+}
+
+// skippedNotificationCondition returns the Notification condition of the run finished in the dry-run mode, the
+// notifications matching its phase are not delivered. It returns false if no target matches.
+func skippedNotificationCondition(run *v1alpha1.WorkflowRun) (condition.Condition, bool) {
+	targets := notification.Targets(run)
+	if len(targets) == 0 {
+		return condition.Condition{}, false
+	}
+	return condition.Condition{
+		Type:               condition.ConditionType(v1alpha1.WorkflowRunNotificationConditionType),
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reasonDryRun,
+		Message:            fmt.Sprintf("%s: %d notifications", dryrun.SkippedMessage, len(targets)),
+	}, true
+}
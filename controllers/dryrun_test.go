@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/condition"
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/history"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestDryRunFinish(t *testing.T) {
+	r := require.New(t)
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	reconciler := &WorkflowRunReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+
+	store := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "workflow-shadow-context", Namespace: "default"}}
+	shard := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "workflow-shadow-context-components-0", Namespace: "default"}}
+	wfContext.MemStore.CreateInMemoryContext(store)
+	wfContext.MemStore.CreateInMemoryContext(shard)
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "shadow",
+			Namespace:   "default",
+			Annotations: map[string]string{types.AnnotationWorkflowRunDryRun: "true"},
+		},
+		Spec: v1alpha1.WorkflowRunSpec{
+			Notifications: []v1alpha1.RunNotification{
+				{URL: "http://127.0.0.1:1/hooks"},
+				{URL: "http://127.0.0.1:1/failures", Events: []v1alpha1.WorkflowRunPhase{v1alpha1.WorkflowStateFailed}},
+			},
+		},
+		Status: v1alpha1.WorkflowRunStatus{
+			Phase:          v1alpha1.WorkflowStateSucceeded,
+			ContextBackend: &corev1.ObjectReference{Name: store.Name, Namespace: store.Namespace},
+		},
+	}
+	reconciler.doWorkflowFinish(ctx, run)
+	r.True(run.Status.Finished)
+	r.Nil(wfContext.MemStore.GetInMemoryContext(store.Name, store.Namespace))
+	r.Nil(wfContext.MemStore.GetInMemoryContext(shard.Name, shard.Namespace))
+
+	cond := run.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunDryRunCompletedConditionType))
+	r.Equal(corev1.ConditionTrue, cond.Status)
+	r.Equal("the run would be succeeded, the cluster and the external systems are not changed", cond.Message)
+	cond = run.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunNotificationConditionType))
+	r.Equal(corev1.ConditionTrue, cond.Status)
+	r.Equal("skipped: dry-run: 1 notifications", cond.Message)
+}
+
+func TestDryRunLatched(t *testing.T) {
+	r := require.New(t)
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	reconciler := &WorkflowRunReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	defer func(store history.Store) { history.DefaultStore = store }(history.DefaultStore)
+	history.DefaultStore = history.NewStore()
+
+	// the annotation removed after the run starts doesn't take effect
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "latched", Namespace: "dry-run"},
+		Status: v1alpha1.WorkflowRunStatus{
+			Phase:     v1alpha1.WorkflowStateSucceeded,
+			StartTime: metav1.Now(),
+			DryRun:    true,
+		},
+	}
+	r.True(types.IsRunDryRun(run))
+	reconciler.doWorkflowFinish(ctx, run)
+	cond := run.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunDryRunCompletedConditionType))
+	r.Equal(corev1.ConditionTrue, cond.Status)
+	r.Empty(history.DefaultStore.List(history.Query{Namespace: "dry-run", Count: 10}))
+	r.False(BackupArgs{}.matches(run))
+
+	// the annotation added after the run starts doesn't take effect either
+	run = &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "started",
+			Namespace:   "dry-run",
+			Annotations: map[string]string{types.AnnotationWorkflowRunDryRun: "true"},
+		},
+		Status: v1alpha1.WorkflowRunStatus{
+			Phase:     v1alpha1.WorkflowStateSucceeded,
+			StartTime: metav1.Now(),
+		},
+	}
+	r.False(types.IsRunDryRun(run))
+	reconciler.doWorkflowFinish(ctx, run)
+	r.Len(history.DefaultStore.List(history.Query{Namespace: "dry-run", Count: 10}), 1)
+	r.True(BackupArgs{}.matches(run))
+}
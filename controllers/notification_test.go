@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/condition"
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/history"
+	"github.com/kubevela/workflow/pkg/notification"
+)
+
+func TestNotifyAfterFinish(t *testing.T) {
+	r := require.New(t)
+	defer func(store history.Store) { history.DefaultStore = store }(history.DefaultStore)
+	history.DefaultStore = history.NewStore()
+	var posts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		posts++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "notified", Namespace: "default"},
+		Spec: v1alpha1.WorkflowRunSpec{
+			Notifications: []v1alpha1.RunNotification{{URL: server.URL}},
+		},
+		Status: v1alpha1.WorkflowRunStatus{
+			Phase:     v1alpha1.WorkflowStateSucceeded,
+			StartTime: metav1.Now(),
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(run).Build()
+	reconciler := &WorkflowRunReconciler{Client: cli}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+
+	// the notifications are pending until the finished status is persisted
+	reconciler.doWorkflowFinish(ctx, run)
+	r.True(notification.Pending(run))
+	r.Equal(0, posts)
+	r.NoError(cli.Status().Update(ctx, run))
+
+	// the notifications are delivered once by the reconcile of the finished run
+	req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(run)}
+	for i := 0; i < 2; i++ {
+		_, err := reconciler.Reconcile(context.Background(), req)
+		r.NoError(err)
+	}
+	r.Equal(1, posts)
+	r.NoError(cli.Get(ctx, req.NamespacedName, run))
+	cond := run.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunNotificationConditionType))
+	r.Equal(corev1.ConditionTrue, cond.Status)
+	r.Equal("delivered 1 notifications", cond.Message)
+}
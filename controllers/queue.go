@@ -0,0 +1,167 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/condition"
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// reasonQueued is the reason of the Queued condition when the run waits for a free slot in its namespace
+const reasonQueued = "Queued"
+
+// QueueRecheckInterval is the interval to check whether the queued workflow runs can start executing
+var QueueRecheckInterval = 5 * time.Second
+
+// maxConcurrentRuns returns the max number of the concurrent executing runs in the namespace, the override in
+// ConcurrencyConfigMap keyed by the namespace replaces MaxConcurrentRuns, 0 means no limit.
+func (r *WorkflowRunReconciler) maxConcurrentRuns(ctx context.Context, namespace string) (int, error) {
+	if r.ConcurrencyConfigMap == "" {
+		return r.MaxConcurrentRuns, nil
+	}
+	key := client.ObjectKey{Namespace: types.DefaultKubeVelaNS, Name: r.ConcurrencyConfigMap}
+	if i := strings.Index(r.ConcurrencyConfigMap, "/"); i >= 0 {
+		key.Namespace, key.Name = r.ConcurrencyConfigMap[:i], r.ConcurrencyConfigMap[i+1:]
+	}
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, key, cm); err != nil {
+		if kerrors.IsNotFound(err) {
+			return r.MaxConcurrentRuns, nil
+		}
+		return 0, errors.WithMessage(err, "get the concurrency limits")
+	}
+	override, ok := cm.Data[namespace]
+	if !ok {
+		return r.MaxConcurrentRuns, nil
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(override))
+	if err != nil || limit < 0 {
+		return 0, errors.Errorf("invalid concurrency limit %q of the namespace %s", override, namespace)
+	}
+	return limit, nil
+}
+
+// isStarted returns true if the run has been admitted to execute
+func isStarted(run *v1alpha1.WorkflowRun) bool {
+	return run.Status.Phase != "" && run.Status.Phase != v1alpha1.WorkflowStateQueued
+}
+
+// isResuming returns true if the run is resumed after it's suspended, it takes a slot again once it's admitted
+func isResuming(run *v1alpha1.WorkflowRun) bool {
+	status := run.Status
+	return status.Phase == v1alpha1.WorkflowStateSuspending && !status.Suspend && !status.Terminated && !status.Finished
+}
+
+// holdsSlot returns true if the run counts against the concurrency limit of its namespace, the finished, the
+// suspended and the terminated runs release their slots.
+func holdsSlot(run *v1alpha1.WorkflowRun) bool {
+	status := run.Status
+	return isStarted(run) && !status.Finished && !status.Suspend && !status.Terminated &&
+		status.Phase != v1alpha1.WorkflowStateSuspending && run.DeletionTimestamp.IsZero()
+}
+
+// admitRun returns whether the run can start executing under the concurrency limit of its namespace, the 1-based
+// position of the run in the queue is returned if it's not admitted. The queued runs are ordered by the priority
+// and then the creation time, the suspended and the terminated runs are admitted directly as they hold no slot. The
+// resumed runs are queued again with the runs not started.
+func (r *WorkflowRunReconciler) admitRun(ctx context.Context, run *v1alpha1.WorkflowRun) (bool, int, error) {
+	if (isStarted(run) && !isResuming(run)) || run.Status.Suspend || run.Status.Terminated {
+		return true, 0, nil
+	}
+	limit, err := r.maxConcurrentRuns(ctx, run.Namespace)
+	if err != nil || limit <= 0 {
+		return true, 0, err
+	}
+	runs := &v1alpha1.WorkflowRunList{}
+	if err := r.List(ctx, runs, client.InNamespace(run.Namespace)); err != nil {
+		return false, 0, errors.WithMessage(err, "list the workflow runs in the namespace")
+	}
+	active := 0
+	var queue []*v1alpha1.WorkflowRun
+	for i := range runs.Items {
+		item := &runs.Items[i]
+		if item.Name == run.Name {
+			continue
+		}
+		switch {
+		case holdsSlot(item):
+			active++
+		case (!isStarted(item) || isResuming(item)) && !item.Status.Suspend && !item.Status.Terminated && item.DeletionTimestamp.IsZero():
+			queue = append(queue, item)
+		}
+	}
+	queue = append(queue, run)
+	sort.SliceStable(queue, func(i, j int) bool {
+		if queue[i].Spec.Priority != queue[j].Spec.Priority {
+			return queue[i].Spec.Priority > queue[j].Spec.Priority
+		}
+		if !queue[i].CreationTimestamp.Equal(&queue[j].CreationTimestamp) {
+			return queue[i].CreationTimestamp.Before(&queue[j].CreationTimestamp)
+		}
+		return queue[i].Name < queue[j].Name
+	})
+	free := limit - active
+	if free < 0 {
+		free = 0
+	}
+	for i, item := range queue {
+		if item.Name == run.Name {
+			if i < free {
+				return true, 0, nil
+			}
+			return false, i + 1 - free, nil
+		}
+	}
+	return true, 0, nil
+}
+
+// clearQueuedCondition removes the Queued condition of the admitted run, which is left by the resumed run queued
+// again since its status is kept
+func clearQueuedCondition(run *v1alpha1.WorkflowRun) {
+	var conditions []condition.Condition
+	for _, c := range run.Status.Conditions {
+		if c.Type != condition.ConditionType(v1alpha1.WorkflowRunQueuedConditionType) {
+			conditions = append(conditions, c)
+		}
+	}
+	run.Status.Conditions = conditions
+}
+
+// queuedCondition describes the position of the run in the queue of its namespace
+func queuedCondition(run *v1alpha1.WorkflowRun, position int) condition.Condition {
+	return condition.Condition{
+		Type:               condition.ConditionType(v1alpha1.WorkflowRunQueuedConditionType),
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reasonQueued,
+		Message:            fmt.Sprintf("position %d in the queue of the namespace %s", position, run.Namespace),
+	}
+}
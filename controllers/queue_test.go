@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/condition"
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+func TestAdmitRun(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	created := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	newRun := func(name string, age time.Duration, priority int, status v1alpha1.WorkflowRunStatus) *v1alpha1.WorkflowRun {
+		return &v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", CreationTimestamp: metav1.NewTime(created.Add(-age))},
+			Spec:       v1alpha1.WorkflowRunSpec{Priority: priority},
+			Status:     status,
+		}
+	}
+	runs := []*v1alpha1.WorkflowRun{
+		newRun("executing", time.Hour, 0, v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateExecuting}),
+		newRun("suspended", time.Hour, 0, v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateSuspending, Suspend: true}),
+		newRun("finished", time.Hour, 0, v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateSucceeded, Finished: true}),
+		newRun("terminated", time.Hour, 0, v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateExecuting, Terminated: true}),
+		newRun("oldest", 3*time.Minute, 0, v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateQueued}),
+		newRun("older", 2*time.Minute, 0, v1alpha1.WorkflowRunStatus{}),
+		newRun("urgent", time.Minute, 10, v1alpha1.WorkflowRunStatus{}),
+		newRun("suspended-in-queue", time.Minute, 0, v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateQueued, Suspend: true}),
+		newRun("resumed", 90*time.Second, 0, v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateSuspending}),
+	}
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for _, run := range runs {
+		builder = builder.WithObjects(run)
+	}
+	builder = builder.WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "concurrency", Namespace: "vela-system"},
+		Data:       map[string]string{"default": "3"},
+	})
+	reconciler := &WorkflowRunReconciler{Client: builder.Build()}
+
+	admit := func(name string) (bool, int) {
+		for _, run := range runs {
+			if run.Name == name {
+				admitted, position, err := reconciler.admitRun(ctx, run)
+				r.NoError(err)
+				return admitted, position
+			}
+		}
+		r.Failf("run not found", name)
+		return false, 0
+	}
+
+	// no limit
+	admitted, _ := admit("older")
+	r.True(admitted)
+
+	// the executing run holds one of the two slots, the one with the highest priority takes the other
+	reconciler.MaxConcurrentRuns = 2
+	admitted, _ = admit("urgent")
+	r.True(admitted)
+	admitted, position := admit("oldest")
+	r.False(admitted)
+	r.Equal(1, position)
+	admitted, position = admit("older")
+	r.False(admitted)
+	r.Equal(2, position)
+	// the resumed run is queued again by its creation time
+	admitted, position = admit("resumed")
+	r.False(admitted)
+	r.Equal(3, position)
+	// the started, the suspended and the terminated runs are not queued
+	for _, name := range []string{"executing", "suspended", "terminated", "suspended-in-queue"} {
+		admitted, _ = admit(name)
+		r.True(admitted, name)
+	}
+
+	// the override of the namespace replaces the default limit
+	reconciler.ConcurrencyConfigMap = "concurrency"
+	admitted, _ = admit("oldest")
+	r.True(admitted)
+	admitted, position = admit("older")
+	r.False(admitted)
+	r.Equal(1, position)
+	admitted, position = admit("resumed")
+	r.False(admitted)
+	r.Equal(2, position)
+	reconciler.ConcurrencyConfigMap = "vela-system/concurrency"
+	limit, err := reconciler.maxConcurrentRuns(ctx, "other")
+	r.NoError(err)
+	r.Equal(2, limit)
+
+	// the Queued condition of the resumed run is removed once it's admitted
+	resumed := runs[len(runs)-1]
+	resumed.SetConditions(queuedCondition(resumed, 2), condition.ReadyCondition(v1alpha1.WorkflowRunConditionType))
+	clearQueuedCondition(resumed)
+	r.Len(resumed.Status.Conditions, 1)
+	r.Equal(condition.ConditionType(v1alpha1.WorkflowRunConditionType), resumed.Status.Conditions[0].Type)
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlEvent "sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/tasks/custom"
+)
+
+// staleRunRecovery re-queues the executing workflow runs left stale by the previous leader once the controller
+// is started as the leader, e.g. the runs whose steps are executed but whose status is never written.
+type staleRunRecovery struct {
+	client.Client
+	Recorder  event.Recorder
+	Threshold time.Duration
+	Clock     clock.Clock
+	Events    chan<- ctrlEvent.GenericEvent
+}
+
+// NeedLeaderElection implements the LeaderElectionRunnable interface, the runs are recovered by the leader.
+func (s *staleRunRecovery) NeedLeaderElection() bool {
+	return true
+}
+
+// Start lists the executing workflow runs and re-queues the stale ones.
+func (s *staleRunRecovery) Start(ctx context.Context) error {
+	runs := &v1alpha1.WorkflowRunList{}
+	if err := s.List(ctx, runs); err != nil {
+		return errors.WithMessage(err, "list the workflow runs to recover")
+	}
+	for i := range runs.Items {
+		run := &runs.Items[i]
+		reason, err := s.staleReason(ctx, run)
+		if err != nil {
+			klog.ErrorS(err, "Failed to check the stale workflow run", "workflowrun", client.ObjectKeyFromObject(run))
+			continue
+		}
+		if reason == "" {
+			continue
+		}
+		klog.InfoS("Recover the stale workflow run", "workflowrun", client.ObjectKeyFromObject(run), "reason", reason)
+		s.Recorder.Event(run, event.Normal(v1alpha1.ReasonRecover, fmt.Sprintf("%s: %s", v1alpha1.MessageRecovered, reason)))
+		select {
+		case s.Events <- ctrlEvent.GenericEvent{Object: run}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// staleReason returns why the executing run is stale, it's empty if the run is not stale. The run is stale if it's
+// not transited for the threshold, the reason tells whether the ops of the steps not in the status are committed
+// in the context, or some of the steps in the status are not finished.
+func (s *staleRunRecovery) staleReason(ctx context.Context, run *v1alpha1.WorkflowRun) (string, error) {
+	status := run.Status
+	if status.Phase != v1alpha1.WorkflowStateExecuting || status.Finished || status.Suspend || status.Terminated || !run.DeletionTimestamp.IsZero() {
+		return "", nil
+	}
+	last := lastTransitionTime(status)
+	if last.IsZero() || s.Clock.Since(last) < s.Threshold {
+		return "", nil
+	}
+	ids := map[string]bool{}
+	var unfinished []string
+	for _, ss := range status.Steps {
+		for _, step := range append([]v1alpha1.StepStatus{ss.StepStatus}, ss.SubStepsStatus...) {
+			ids[step.ID] = true
+			if step.Phase == v1alpha1.WorkflowStepPhaseRunning || step.Phase == v1alpha1.WorkflowStepPhasePending {
+				unfinished = append(unfinished, step.Name)
+			}
+		}
+	}
+	if status.ContextBackend != nil {
+		cm := &corev1.ConfigMap{}
+		if err := s.Get(ctx, client.ObjectKey{Namespace: status.ContextBackend.Namespace, Name: status.ContextBackend.Name}, cm); err != nil && !kerrors.IsNotFound(err) {
+			return "", errors.WithMessage(err, "get the workflow context")
+		}
+		if uid := status.ContextBackend.UID; uid != "" && cm.UID != "" && cm.UID != uid {
+			// the stale context left by a previous run with the same name
+			cm.Data = nil
+		}
+		var orphans []string
+		for id := range custom.OpMarkerStepIDs(cm.Data) {
+			if !ids[id] {
+				orphans = append(orphans, id)
+			}
+		}
+		if len(orphans) > 0 {
+			sort.Strings(orphans)
+			return fmt.Sprintf("the ops of the steps %s are committed without the status", strings.Join(orphans, ", ")), nil
+		}
+	}
+	if len(unfinished) > 0 {
+		return fmt.Sprintf("the steps %s are not transited since %s", strings.Join(unfinished, ", "), last.Format(time.RFC3339)), nil
+	}
+	return fmt.Sprintf("no step is started since %s", last.Format(time.RFC3339)), nil
+}
+
+// lastTransitionTime returns the last execution time of the steps, or the start time of the run if no step is executed
+func lastTransitionTime(status v1alpha1.WorkflowRunStatus) time.Time {
+	last := status.StartTime.Time
+	for _, ss := range status.Steps {
+		for _, step := range append([]v1alpha1.StepStatus{ss.StepStatus}, ss.SubStepsStatus...) {
+			if step.LastExecuteTime.After(last) {
+				last = step.LastExecuteTime.Time
+			}
+		}
+	}
+	return last
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	ctrlEvent "sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+type recordedEvents []event.Event
+
+func (r *recordedEvents) Event(_ runtime.Object, e event.Event) {
+	*r = append(*r, e)
+}
+
+func (r *recordedEvents) WithAnnotations(_ ...string) event.Recorder {
+	return r
+}
+
+func TestStaleReason(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) metav1.Time {
+		return metav1.NewTime(now.Add(-d))
+	}
+	step := func(id, name string, phase v1alpha1.WorkflowStepPhase, executed metav1.Time) v1alpha1.WorkflowStepStatus {
+		return v1alpha1.WorkflowStepStatus{StepStatus: v1alpha1.StepStatus{ID: id, Name: name, Phase: phase, LastExecuteTime: executed}}
+	}
+	run := func(status v1alpha1.WorkflowRunStatus) *v1alpha1.WorkflowRun {
+		if status.Phase == "" {
+			status.Phase = v1alpha1.WorkflowStateExecuting
+		}
+		status.ContextBackend = &corev1.ObjectReference{Namespace: "default", Name: "workflow-wr-context"}
+		return &v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "wr", Namespace: "default"},
+			Status:     status,
+		}
+	}
+
+	testCases := map[string]struct {
+		run     *v1alpha1.WorkflowRun
+		markers map[string]string
+		reason  string
+	}{
+		"orphan op markers": {
+			run: run(v1alpha1.WorkflowRunStatus{
+				StartTime: ago(time.Hour),
+				Steps:     []v1alpha1.WorkflowStepStatus{step("s1", "step1", v1alpha1.WorkflowStepPhaseSucceeded, ago(20*time.Minute))},
+			}),
+			markers: map[string]string{"op_markers.s1.0": "done", "op_markers.s2.0": "done", "op_markers.s2.1": "done"},
+			reason:  "the ops of the steps s2 are committed without the status",
+		},
+		"unfinished steps": {
+			run: run(v1alpha1.WorkflowRunStatus{
+				StartTime: ago(time.Hour),
+				Steps: []v1alpha1.WorkflowStepStatus{
+					step("s1", "step1", v1alpha1.WorkflowStepPhaseSucceeded, ago(30*time.Minute)),
+					step("s2", "step2", v1alpha1.WorkflowStepPhaseRunning, ago(20*time.Minute)),
+				},
+			}),
+			markers: map[string]string{"op_markers.s2.0": "done"},
+			reason:  "the steps step2 are not transited since 2022-10-01T11:40:00Z",
+		},
+		"no step started": {
+			run:    run(v1alpha1.WorkflowRunStatus{StartTime: ago(time.Hour)}),
+			reason: "no step is started since 2022-10-01T11:00:00Z",
+		},
+		"recently transited": {
+			run: run(v1alpha1.WorkflowRunStatus{
+				StartTime: ago(time.Hour),
+				Steps:     []v1alpha1.WorkflowStepStatus{step("s1", "step1", v1alpha1.WorkflowStepPhaseRunning, ago(time.Minute))},
+			}),
+			markers: map[string]string{"op_markers.s2.0": "done"},
+		},
+		"suspended": {
+			run: run(v1alpha1.WorkflowRunStatus{
+				StartTime: ago(time.Hour),
+				Suspend:   true,
+				Steps:     []v1alpha1.WorkflowStepStatus{step("s1", "step1", v1alpha1.WorkflowStepPhaseRunning, ago(time.Hour))},
+			}),
+		},
+		"finished": {
+			run: run(v1alpha1.WorkflowRunStatus{
+				Phase:     v1alpha1.WorkflowStateSucceeded,
+				StartTime: ago(time.Hour),
+				Finished:  true,
+			}),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+			if tc.markers != nil {
+				r.NoError(cli.Create(context.Background(), &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "workflow-wr-context"},
+					Data:       tc.markers,
+				}))
+			}
+			s := &staleRunRecovery{Client: cli, Threshold: 10 * time.Minute, Clock: clocktesting.NewFakeClock(now)}
+			reason, err := s.staleReason(context.Background(), tc.run)
+			r.NoError(err)
+			r.Equal(tc.reason, reason)
+		})
+	}
+}
+
+func TestStaleRunRecoveryStart(t *testing.T) {
+	r := require.New(t)
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	newRun := func(name string, started time.Time) *v1alpha1.WorkflowRun {
+		return &v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Status: v1alpha1.WorkflowRunStatus{
+				Phase:     v1alpha1.WorkflowStateExecuting,
+				StartTime: metav1.NewTime(started),
+			},
+		}
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newRun("stale", now.Add(-time.Hour)),
+		newRun("fresh", now.Add(-time.Minute)),
+	).Build()
+
+	events := make(chan ctrlEvent.GenericEvent, 2)
+	recorder := &recordedEvents{}
+	s := &staleRunRecovery{
+		Client:    cli,
+		Recorder:  recorder,
+		Threshold: 10 * time.Minute,
+		Clock:     clocktesting.NewFakeClock(now),
+		Events:    events,
+	}
+	r.NoError(s.Start(context.Background()))
+	close(events)
+
+	var requeued []client.ObjectKey
+	for e := range events {
+		requeued = append(requeued, client.ObjectKeyFromObject(e.Object))
+	}
+	r.Equal([]client.ObjectKey{{Namespace: "default", Name: "stale"}}, requeued)
+	r.Len(*recorder, 1)
+	r.Equal(event.Reason(v1alpha1.ReasonRecover), (*recorder)[0].Reason)
+	r.Equal("recovered after controller restart: no step is started since 2022-10-01T11:00:00Z", (*recorder)[0].Message)
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/operation"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// terminateByAnnotation starts the graceful termination requested by the annotation of the run. The annotation is
+// removed after the termination is persisted, so that the run retried from the failure is not terminated again.
+func (r *WorkflowRunReconciler) terminateByAnnotation(ctx context.Context, run *v1alpha1.WorkflowRun) error {
+	v, ok := run.Annotations[types.AnnotationWorkflowRunTerminateGracefully]
+	if !ok {
+		return nil
+	}
+	if run.Status.GracefulTermination == nil && !run.Status.Terminated {
+		gracePeriod := terminateGracePeriod(v)
+		if err := operation.TerminateGracefully(ctx, r.Client, run, gracePeriod); err != nil {
+			return err
+		}
+		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonTerminate,
+			fmt.Sprintf("terminate gracefully by the annotation in %s", run.Status.GracefulTermination.GracePeriod.Duration)))
+	}
+	delete(run.Annotations, types.AnnotationWorkflowRunTerminateGracefully)
+	return r.Update(ctx, run)
+}
+
+// terminateGracePeriod returns the grace period of the annotation, the invalid one is ignored and the default grace
+// period is used
+func terminateGracePeriod(v string) time.Duration {
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return d
+	}
+	return types.DefaultTerminateGracePeriod
+}
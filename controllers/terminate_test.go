@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestTerminateByAnnotation(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	newRun := func(name, annotation string, status v1alpha1.WorkflowRunStatus) *v1alpha1.WorkflowRun {
+		run := &v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Status:     status,
+		}
+		if annotation != "" {
+			run.Annotations = map[string]string{types.AnnotationWorkflowRunTerminateGracefully: annotation}
+		}
+		return run
+	}
+	executing := v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateExecuting}
+	started := metav1.NewTime(time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC))
+	testCases := map[string]struct {
+		run         *v1alpha1.WorkflowRun
+		gracePeriod time.Duration
+		started     bool
+		events      int
+	}{
+		"not annotated": {
+			run: newRun("not-annotated", "", executing),
+		},
+		"default grace period": {
+			run:         newRun("default", "true", executing),
+			gracePeriod: types.DefaultTerminateGracePeriod,
+			events:      1,
+		},
+		"grace period": {
+			run:         newRun("grace-period", "5m", executing),
+			gracePeriod: 5 * time.Minute,
+			events:      1,
+		},
+		"invalid grace period": {
+			run:         newRun("invalid", "-5m", executing),
+			gracePeriod: types.DefaultTerminateGracePeriod,
+			events:      1,
+		},
+		"already terminating": {
+			run: newRun("terminating", "5m", v1alpha1.WorkflowRunStatus{
+				Phase:               v1alpha1.WorkflowStateExecuting,
+				GracefulTermination: &v1alpha1.GracefulTermination{StartTime: started, GracePeriod: metav1.Duration{Duration: time.Minute}},
+			}),
+			gracePeriod: time.Minute,
+			started:     true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.run).Build()
+			events := &recordedEvents{}
+			reconciler := &WorkflowRunReconciler{Client: cli, Recorder: events}
+			run := &v1alpha1.WorkflowRun{}
+			r.NoError(cli.Get(ctx, client.ObjectKeyFromObject(tc.run), run))
+			r.NoError(reconciler.terminateByAnnotation(ctx, run))
+			r.Len(*events, tc.events)
+
+			updated := &v1alpha1.WorkflowRun{}
+			r.NoError(cli.Get(ctx, client.ObjectKeyFromObject(tc.run), updated))
+			r.NotContains(updated.Annotations, types.AnnotationWorkflowRunTerminateGracefully)
+			if tc.gracePeriod == 0 {
+				r.Nil(updated.Status.GracefulTermination)
+				return
+			}
+			r.NotNil(updated.Status.GracefulTermination)
+			r.Equal(tc.gracePeriod, updated.Status.GracefulTermination.GracePeriod.Duration)
+			r.False(updated.Status.Terminated)
+			if tc.started {
+				r.True(started.Equal(&updated.Status.GracefulTermination.StartTime))
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+// watchKey is the identity of an object watched by the waiting steps
+type watchKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+// watchTracker maps the events of the objects watched by the waiting steps back to the runs watching them. The
+// informers of the kinds are started on demand by start and kept for the lifetime of the controller, while the
+// subscriptions of a run are replaced by the watches in its status after each reconcile.
+type watchTracker struct {
+	start func(gvk schema.GroupVersionKind) error
+
+	mu    sync.Mutex
+	kinds map[schema.GroupVersionKind]bool
+	// objects is the runs watching each object, runs is the objects watched by each run
+	objects map[watchKey]map[ktypes.NamespacedName]bool
+	runs    map[ktypes.NamespacedName][]watchKey
+}
+
+func newWatchTracker(start func(gvk schema.GroupVersionKind) error) *watchTracker {
+	return &watchTracker{
+		start:   start,
+		kinds:   map[schema.GroupVersionKind]bool{},
+		objects: map[watchKey]map[ktypes.NamespacedName]bool{},
+		runs:    map[ktypes.NamespacedName][]watchKey{},
+	}
+}
+
+// track replaces the subscriptions of the run with the watches of its waiting steps, the subscriptions are removed
+// if the run is finished, deleted or not found.
+func (t *watchTracker) track(ctx monitorContext.Context, name ktypes.NamespacedName, run *v1alpha1.WorkflowRun) {
+	if t == nil {
+		return
+	}
+	var keys []watchKey
+	if run != nil && !run.Status.Finished && run.DeletionTimestamp.IsZero() {
+		keys = watchKeys(run.Status)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range t.runs[name] {
+		delete(t.objects[key], name)
+		if len(t.objects[key]) == 0 {
+			delete(t.objects, key)
+		}
+	}
+	delete(t.runs, name)
+	for _, key := range keys {
+		if !t.kinds[key.gvk] {
+			if err := t.start(key.gvk); err != nil {
+				ctx.Error(err, "start watching", "kind", key.gvk.String())
+				continue
+			}
+			t.kinds[key.gvk] = true
+		}
+		if t.objects[key] == nil {
+			t.objects[key] = map[ktypes.NamespacedName]bool{}
+		}
+		t.objects[key][name] = true
+		t.runs[name] = append(t.runs[name], key)
+	}
+}
+
+// requests returns the requests of the runs watching the object
+func (t *watchTracker) requests(obj client.Object) []reconcile.Request {
+	key := watchKey{
+		gvk:       obj.GetObjectKind().GroupVersionKind(),
+		namespace: obj.GetNamespace(),
+		name:      obj.GetName(),
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var requests []reconcile.Request
+	for name := range t.objects[key] {
+		requests = append(requests, reconcile.Request{NamespacedName: name})
+	}
+	return requests
+}
+
+// watchKeys returns the objects watched by the steps and the sub steps of the run
+func watchKeys(status v1alpha1.WorkflowRunStatus) []watchKey {
+	var keys []watchKey
+	seen := map[watchKey]bool{}
+	add := func(watches []v1alpha1.WatchedObject) {
+		for _, w := range watches {
+			key := watchKey{
+				gvk:       schema.FromAPIVersionAndKind(w.APIVersion, w.Kind),
+				namespace: w.Namespace,
+				name:      w.Name,
+			}
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	for _, step := range status.Steps {
+		add(step.Watches)
+		for _, sub := range step.SubStepsStatus {
+			add(sub.Watches)
+		}
+	}
+	return keys
+}
+
+// clearWatches removes the watches of the steps of the terminated run, the steps interrupted by the termination
+// are never resumed.
+func clearWatches(status *v1alpha1.WorkflowRunStatus) {
+	for i := range status.Steps {
+		status.Steps[i].Watches = nil
+		for j := range status.Steps[i].SubStepsStatus {
+			status.Steps[i].SubStepsStatus[j].Watches = nil
+		}
+	}
+}
+
+// watchSource returns the object of the kind to start the informer of it, only the metadata is watched since the
+// events are mapped to the runs by the names and the objects are read by the steps when they're resumed
+func watchSource(gvk schema.GroupVersionKind) client.Object {
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(gvk)
+	return obj
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+func TestWatchTracker(t *testing.T) {
+	r := require.New(t)
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	deployGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	jobGVK := schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}
+	var started []schema.GroupVersionKind
+	failing := map[schema.GroupVersionKind]bool{}
+	tracker := newWatchTracker(func(gvk schema.GroupVersionKind) error {
+		if failing[gvk] {
+			return errors.New("no matches for kind")
+		}
+		started = append(started, gvk)
+		return nil
+	})
+	deploy := v1alpha1.WatchedObject{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web"}
+	job := v1alpha1.WatchedObject{APIVersion: "batch/v1", Kind: "Job", Namespace: "default", Name: "build"}
+	newRun := func(name string, stepWatches, subWatches []v1alpha1.WatchedObject) *v1alpha1.WorkflowRun {
+		return &v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Status: v1alpha1.WorkflowRunStatus{Steps: []v1alpha1.WorkflowStepStatus{
+				{StepStatus: v1alpha1.StepStatus{Name: "wait", Watches: stepWatches}},
+				{
+					StepStatus:     v1alpha1.StepStatus{Name: "group"},
+					SubStepsStatus: []v1alpha1.StepStatus{{Name: "sub", Watches: subWatches}},
+				},
+			}},
+		}
+	}
+	requests := func(gvk schema.GroupVersionKind, name string) []reconcile.Request {
+		obj := watchSource(gvk)
+		obj.SetNamespace("default")
+		obj.SetName(name)
+		return tracker.requests(obj)
+	}
+	run1 := ktypes.NamespacedName{Namespace: "default", Name: "run1"}
+	run2 := ktypes.NamespacedName{Namespace: "default", Name: "run2"}
+
+	tracker.track(ctx, run1, newRun("run1", []v1alpha1.WatchedObject{deploy}, []v1alpha1.WatchedObject{job, deploy}))
+	tracker.track(ctx, run2, newRun("run2", []v1alpha1.WatchedObject{deploy}, nil))
+	r.Equal([]schema.GroupVersionKind{deployGVK, jobGVK}, started)
+	r.ElementsMatch([]reconcile.Request{{NamespacedName: run1}, {NamespacedName: run2}}, requests(deployGVK, "web"))
+	r.Equal([]reconcile.Request{{NamespacedName: run1}}, requests(jobGVK, "build"))
+	r.Empty(requests(jobGVK, "other"))
+
+	// the subscriptions are replaced after the step completes
+	tracker.track(ctx, run1, newRun("run1", nil, []v1alpha1.WatchedObject{job}))
+	r.Equal([]reconcile.Request{{NamespacedName: run2}}, requests(deployGVK, "web"))
+	r.Equal([]reconcile.Request{{NamespacedName: run1}}, requests(jobGVK, "build"))
+	r.Len(started, 2)
+
+	// the subscriptions are removed when the run is finished or not found
+	finished := newRun("run1", nil, []v1alpha1.WatchedObject{job})
+	finished.Status.Finished = true
+	tracker.track(ctx, run1, finished)
+	r.Empty(requests(jobGVK, "build"))
+	tracker.track(ctx, run2, nil)
+	r.Empty(requests(deployGVK, "web"))
+	r.Empty(tracker.objects)
+	r.Empty(tracker.runs)
+
+	// the kind failed to start is not subscribed and retried in the next reconcile
+	cronGVK := schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"}
+	failing[cronGVK] = true
+	cron := v1alpha1.WatchedObject{APIVersion: "batch/v1", Kind: "CronJob", Namespace: "default", Name: "nightly"}
+	tracker.track(ctx, run1, newRun("run1", []v1alpha1.WatchedObject{cron}, nil))
+	r.Empty(requests(cronGVK, "nightly"))
+	failing[cronGVK] = false
+	tracker.track(ctx, run1, newRun("run1", []v1alpha1.WatchedObject{cron}, nil))
+	r.Equal([]reconcile.Request{{NamespacedName: run1}}, requests(cronGVK, "nightly"))
+
+	// the cluster-scoped objects are matched without the namespace
+	nsGVK := schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+	tracker.track(ctx, run2, newRun("run2", []v1alpha1.WatchedObject{{APIVersion: "v1", Kind: "Namespace", Name: "prod"}}, nil))
+	obj := watchSource(nsGVK)
+	obj.SetName("prod")
+	r.Equal([]reconcile.Request{{NamespacedName: run2}}, tracker.requests(obj))
+
+	// the tracker is nil if the watches are disabled
+	var disabled *watchTracker
+	disabled.track(ctx, run1, newRun("run1", []v1alpha1.WatchedObject{deploy}, nil))
+}
+
+func TestClearWatches(t *testing.T) {
+	watches := []v1alpha1.WatchedObject{{APIVersion: "batch/v1", Kind: "Job", Namespace: "default", Name: "build"}}
+	status := v1alpha1.WorkflowRunStatus{Steps: []v1alpha1.WorkflowStepStatus{
+		{StepStatus: v1alpha1.StepStatus{Name: "wait", Watches: watches}},
+		{
+			StepStatus:     v1alpha1.StepStatus{Name: "group"},
+			SubStepsStatus: []v1alpha1.StepStatus{{Name: "sub", Watches: watches}},
+		},
+	}}
+	clearWatches(&status)
+	require.Empty(t, watchKeys(status))
+}
@@ -20,18 +20,27 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	ctrlEvent "sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
@@ -39,9 +48,16 @@ import (
 	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/cue/packages"
+	"github.com/kubevela/workflow/pkg/dryrun"
 	"github.com/kubevela/workflow/pkg/executor"
+	"github.com/kubevela/workflow/pkg/export"
 	"github.com/kubevela/workflow/pkg/generator"
+	"github.com/kubevela/workflow/pkg/history"
 	"github.com/kubevela/workflow/pkg/monitor/metrics"
+	"github.com/kubevela/workflow/pkg/notification"
+	"github.com/kubevela/workflow/pkg/progress"
+	"github.com/kubevela/workflow/pkg/providers/lock"
+	"github.com/kubevela/workflow/pkg/resourcetracker"
 	"github.com/kubevela/workflow/pkg/types"
 )
 
@@ -49,6 +65,15 @@ import (
 type Args struct {
 	// ConcurrentReconciles is the concurrent reconcile number of the controller
 	ConcurrentReconciles int
+	// StaleRunThreshold is the duration after which the executing runs not transited are re-queued when the
+	// controller is started as the leader, 0 disables it
+	StaleRunThreshold time.Duration
+	// MaxConcurrentRuns is the max number of the concurrent executing runs in a namespace, the runs beyond it are
+	// queued until the slots are released, 0 means no limit
+	MaxConcurrentRuns int
+	// ConcurrencyConfigMap is the ConfigMap(namespace/name) overriding MaxConcurrentRuns of the namespaces, the data is
+	// keyed by the namespace. The namespace is vela-system if not specified.
+	ConcurrencyConfigMap string
 }
 
 // WorkflowRunReconciler reconciles a WorkflowRun object
@@ -57,7 +82,17 @@ type WorkflowRunReconciler struct {
 	Scheme          *runtime.Scheme
 	PackageDiscover *packages.PackageDiscover
 	Recorder        event.Recorder
+	// Gate stops starting the steps while the controller is draining, the reconciles are tracked by it if it's set
+	Gate *executor.Gate
+	// KubeClient collects the logs of the pods for the steps and reads the logs of the jobs for the wait-job op,
+	// the logs are not collected if it's not set
+	KubeClient kubernetes.Interface
+	// AuditSink receives the audit records of the ops executed by the steps, the ops are not audited if it's not set
+	AuditSink types.AuditSink
 	Args
+
+	// watches triggers the reconciles of the runs on the changes of the objects watched by their waiting steps
+	watches *watchTracker
 }
 
 var (
@@ -70,6 +105,14 @@ var (
 // +kubebuilder:rbac:groups=core.oam.dev,resources=workflowruns/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core.oam.dev,resources=workflowruns/finalizers,verbs=update
 func (r *WorkflowRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if r.Gate != nil {
+		r.Gate.Enter()
+		defer r.Gate.Leave()
+		// the in-flight reconcile is not cancelled with the manager, it's bounded by the drain timeout instead
+		var cancel context.CancelFunc
+		ctx, cancel = r.Gate.Context(ctx)
+		defer cancel()
+	}
 	ctx, cancel := context.WithTimeout(ctx, ReconcileTimeout)
 	defer cancel()
 
@@ -87,73 +130,184 @@ func (r *WorkflowRunReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			logCtx.Error(err, "get workflowrun")
 			return ctrl.Result{}, err
 		}
+		r.watches.track(logCtx, req.NamespacedName, nil)
+		progress.Forget(req.Namespace, req.Name)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	// the subscriptions follow the watches in the status of the run after the reconcile
+	defer func() { r.watches.track(logCtx, req.NamespacedName, run) }()
 
 	timeReporter := timeReconcile(run)
 	defer timeReporter()
 
+	if !run.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.handleDeletion(logCtx, run)
+	}
+
 	if run.Status.Finished {
+		if notification.Pending(run) {
+			return ctrl.Result{}, r.notify(logCtx, run)
+		}
 		logCtx.Info("WorkflowRun is finished, skip reconcile")
 		return ctrl.Result{}, nil
 	}
 
+	if err := r.terminateByAnnotation(ctx, run); err != nil {
+		logCtx.Error(err, "terminate workflowrun gracefully")
+		return ctrl.Result{}, err
+	}
+
+	admitted, position, err := r.admitRun(ctx, run)
+	if err != nil {
+		logCtx.Error(err, "admit workflowrun")
+		return ctrl.Result{}, err
+	}
+	if !admitted {
+		logCtx.Info("WorkflowRun is queued", "position", position)
+		run.Status.Phase = v1alpha1.WorkflowStateQueued
+		updateSummary(run, nil)
+		run.SetConditions(queuedCondition(run, position))
+		return ctrl.Result{RequeueAfter: QueueRecheckInterval}, r.patchStatus(logCtx, run, false)
+	}
+	// the status of the admitted run including the Queued condition is initialized when it starts executing, except
+	// the resumed run which keeps its status
+	clearQueuedCondition(run)
+
+	if run.Spec.ResourceGC && !controllerutil.ContainsFinalizer(run, types.FinalizerResourceGC) {
+		controllerutil.AddFinalizer(run, types.FinalizerResourceGC)
+		if err := r.Update(ctx, run); err != nil {
+			logCtx.Error(err, "add resource gc finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
 	instance, err := generator.GenerateWorkflowInstance(ctx, r.Client, run)
 	if err != nil {
 		logCtx.Error(err, "[generate workflow instance]")
 		r.Recorder.Event(run, event.Warning(v1alpha1.ReasonGenerate, errors.WithMessage(err, v1alpha1.MessageFailedGenerate)))
+		// the cycle in the dependencies, the duplicated step names and the missing required sources of the context
+		// can never be resolved by retrying, fail the run directly
+		if generator.IsDependencyCycleErr(err) || generator.IsDuplicateStepNameErr(err) || generator.IsContextSourceNotFoundErr(err) {
+			run.Status.Phase = v1alpha1.WorkflowStateFailed
+			updateSummary(run, nil)
+			r.doWorkflowFinish(logCtx, run)
+			run.SetConditions(condition.ErrorCondition(v1alpha1.WorkflowRunConditionType, err))
+			return ctrl.Result{}, r.patchStatus(logCtx, run, false)
+		}
 		run.Status.Phase = v1alpha1.WorkflowStateInitializing
+		updateSummary(run, nil)
 		return r.endWithNegativeCondition(logCtx, run, condition.ErrorCondition(v1alpha1.WorkflowRunConditionType, err))
 	}
 	isUpdate := instance.Status.Message != ""
+	for _, message := range instance.SkippedContextSources {
+		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonGenerate, message))
+	}
 
+	// the writes of the run in the dry-run mode are dry-run by the server and its context is kept in memory
+	cli := r.Client
+	if instance.DryRun {
+		cli = dryrun.Client(r.Client)
+	}
 	runners, err := generator.GenerateRunners(logCtx, instance, types.StepGeneratorOptions{
 		PackageDiscover: r.PackageDiscover,
-		Client:          r.Client,
+		Client:          cli,
+		KubeClient:      r.KubeClient,
 	})
 	if err != nil {
 		logCtx.Error(err, "[generate runners]")
 		r.Recorder.Event(run, event.Warning(v1alpha1.ReasonGenerate, errors.WithMessage(err, v1alpha1.MessageFailedGenerate)))
+		// the run waits for the changed properties to be reverted, which triggers another reconcile
+		if generator.IsSpecDriftErr(err) {
+			run.SetConditions(condition.ErrorCondition(v1alpha1.WorkflowRunConditionType, err))
+			return ctrl.Result{}, r.patchStatus(logCtx, run, false)
+		}
+		// the cycle in the dependencies, the duplicated step names and the denied step types can never be resolved by
+		// retrying, fail the run directly
+		if generator.IsDependencyCycleErr(err) || generator.IsDuplicateStepNameErr(err) || generator.IsStepTypeDeniedErr(err) {
+			run.Status.Phase = v1alpha1.WorkflowStateFailed
+			updateSummary(run, instance.Steps)
+			r.doWorkflowFinish(logCtx, run)
+			run.SetConditions(condition.ErrorCondition(v1alpha1.WorkflowRunConditionType, err))
+			return ctrl.Result{}, r.patchStatus(logCtx, run, false)
+		}
 		run.Status.Phase = v1alpha1.WorkflowStateInitializing
+		updateSummary(run, instance.Steps)
 		return r.endWithNegativeCondition(logCtx, run, condition.ErrorCondition(v1alpha1.WorkflowRunConditionType, err))
 	}
 
-	executor := executor.New(instance, r.Client)
+	terminating := run.Status.GracefulTermination != nil && !run.Status.Terminated
+	approvalTimeouts := approvalTimeoutSteps(run.Status)
+	ignoredFailures := ignoredFailureSteps(run.Status)
+	executor := executor.New(instance, cli, executor.WithGate(r.Gate), executor.WithKubeClient(r.KubeClient), executor.WithAuditSink(r.AuditSink))
 	state, err := executor.ExecuteRunners(logCtx, runners)
 	if err != nil {
 		logCtx.Error(err, "[execute runners]")
-		r.Recorder.Event(run, event.Warning(v1alpha1.ReasonExecute, errors.WithMessage(err, v1alpha1.MessageFailedExecute)))
 		run.Status.Phase = v1alpha1.WorkflowStateExecuting
+		updateSummary(run, instance.Steps)
+		r.Recorder.Event(run, event.Warning(v1alpha1.ReasonExecute, errors.WithMessagef(err, "%s (%s)", v1alpha1.MessageFailedExecute, run.Status.Summary)))
 		return r.endWithNegativeCondition(logCtx, run, condition.ErrorCondition(v1alpha1.WorkflowRunConditionType, err))
 	}
 	isUpdate = isUpdate && instance.Status.Message == ""
+	if termination := instance.Status.GracefulTermination; terminating && instance.Status.Terminated {
+		if termination.Forced {
+			r.Recorder.Event(run, event.Warning(v1alpha1.ReasonTerminate, errors.New(v1alpha1.MessageForcedTermination)))
+		} else {
+			r.Recorder.Event(run, event.Normal(v1alpha1.ReasonTerminate, v1alpha1.MessageTerminatedGracefully))
+		}
+	}
+	for name, phase := range approvalTimeoutSteps(instance.Status) {
+		if _, ok := approvalTimeouts[name]; !ok {
+			r.Recorder.Event(run, event.Warning(v1alpha1.ReasonApprovalTimeout, fmt.Errorf(v1alpha1.MessageApprovalTimeout, name, phase)))
+		}
+	}
+	for name, message := range ignoredFailureSteps(instance.Status) {
+		if _, ok := ignoredFailures[name]; !ok {
+			r.Recorder.Event(run, event.Warning(v1alpha1.ReasonStepFailureIgnored, fmt.Errorf(v1alpha1.MessageStepFailureIgnored, name, message)))
+		}
+	}
 	run.Status = instance.Status
 	run.Status.Phase = state
+	updateSummary(run, instance.Steps)
+	// the run is requeued before its locks expire, so that they are kept while it's waiting or suspended
+	var renewInterval time.Duration
+	if state == v1alpha1.WorkflowStateExecuting || state == v1alpha1.WorkflowStateSuspending {
+		if renewInterval, err = lock.Renew(logCtx, r.Client, run.Namespace, string(run.UID)); err != nil {
+			logCtx.Error(err, "renew locks")
+		}
+	}
 	switch state {
 	case v1alpha1.WorkflowStateSuspending:
 		logCtx.Info("Workflow return state=Suspend")
-		if duration := executor.GetSuspendBackoffWaitTime(); duration > 0 {
+		if duration := requeueBefore(executor.GetSuspendBackoffWaitTime(), renewInterval); duration > 0 {
 			return ctrl.Result{RequeueAfter: duration}, r.patchStatus(logCtx, run, isUpdate)
 		}
 		return ctrl.Result{}, r.patchStatus(logCtx, run, isUpdate)
 	case v1alpha1.WorkflowStateFailed:
 		logCtx.Info("Workflow return state=Failed")
-		r.doWorkflowFinish(run)
-		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonExecute, v1alpha1.MessageFailed))
+		r.setTerminatedCondition(run)
+		r.doWorkflowFinish(logCtx, run)
+		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonExecute, withSummary(v1alpha1.MessageFailed, run)))
 		return ctrl.Result{}, r.patchStatus(logCtx, run, isUpdate)
 	case v1alpha1.WorkflowStateTerminated:
 		logCtx.Info("Workflow return state=Terminated")
-		r.doWorkflowFinish(run)
-		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonExecute, v1alpha1.MessageTerminated))
+		r.setTerminatedCondition(run)
+		r.doWorkflowFinish(logCtx, run)
+		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonExecute, withSummary(v1alpha1.MessageTerminated, run)))
 		return ctrl.Result{}, r.patchStatus(logCtx, run, isUpdate)
 	case v1alpha1.WorkflowStateExecuting:
 		logCtx.Info("Workflow return state=Executing")
-		return ctrl.Result{RequeueAfter: executor.GetBackoffWaitTime()}, r.patchStatus(logCtx, run, isUpdate)
+		return ctrl.Result{RequeueAfter: requeueBefore(executor.GetBackoffWaitTime(), renewInterval)}, r.patchStatus(logCtx, run, isUpdate)
 	case v1alpha1.WorkflowStateSucceeded:
 		logCtx.Info("Workflow return state=Succeeded")
-		r.doWorkflowFinish(run)
+		if instance.DryRun {
+			logCtx.Info("Skip exporting the outputs in the dry-run mode")
+		} else if err := r.exportOutputs(logCtx, run); err != nil {
+			logCtx.Error(err, "[export outputs]")
+			r.Recorder.Event(run, event.Warning(v1alpha1.ReasonExport, errors.WithMessage(err, v1alpha1.MessageFailedExport)))
+		}
+		r.doWorkflowFinish(logCtx, run)
 		run.Status.SetConditions(condition.ReadyCondition(v1alpha1.WorkflowRunConditionType))
-		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonExecute, v1alpha1.MessageSuccessfully))
+		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonExecute, withSummary(v1alpha1.MessageSuccessfully, run)))
 		return ctrl.Result{}, r.patchStatus(logCtx, run, isUpdate)
 	case v1alpha1.WorkflowStateSkipped:
 		logCtx.Info("Skip this reconcile")
@@ -163,9 +317,31 @@ func (r *WorkflowRunReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	return ctrl.Result{}, nil
 }
 
+// requeueBefore returns the shorter one of the requeue duration and the deadline, the zero values are ignored
+func requeueBefore(duration, deadline time.Duration) time.Duration {
+	if deadline > 0 && (duration <= 0 || deadline < duration) {
+		return deadline
+	}
+	return duration
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *WorkflowRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr)
+	if r.StaleRunThreshold > 0 {
+		events := make(chan ctrlEvent.GenericEvent)
+		if err := mgr.Add(&staleRunRecovery{
+			Client:    mgr.GetClient(),
+			Recorder:  r.Recorder,
+			Threshold: r.StaleRunThreshold,
+			Clock:     clock.RealClock{},
+			Events:    events,
+		}); err != nil {
+			return err
+		}
+		builder = builder.Watches(&source.Channel{Source: events}, &handler.EnqueueRequestForObject{})
+	}
+	c, err := builder.
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: r.ConcurrentReconciles,
 		}).
@@ -176,9 +352,14 @@ func (r *WorkflowRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				new := e.ObjectNew.DeepCopyObject().(*v1alpha1.WorkflowRun)
 				old := e.ObjectOld.DeepCopyObject().(*v1alpha1.WorkflowRun)
 
-				// if the workflow is finished, skip the reconcile
+				// the deleting workflow run needs to clean up the applied resources
+				if !new.DeletionTimestamp.IsZero() {
+					return true
+				}
+
+				// if the workflow is finished, skip the reconcile unless its notifications are pending
 				if new.Status.Finished {
-					return false
+					return notification.Pending(new)
 				}
 
 				// filter managedFields changes
@@ -193,8 +374,10 @@ func (r *WorkflowRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
 					return true
 				}
 
-				// ignore the changes in step status
+				// ignore the changes in step status and the summary derived from it
 				old.Status.Steps = new.Status.Steps
+				old.Status.Progress = new.Status.Progress
+				old.Status.Summary = new.Status.Summary
 
 				return !reflect.DeepEqual(old, new)
 			},
@@ -203,7 +386,16 @@ func (r *WorkflowRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			},
 		}).
 		For(&v1alpha1.WorkflowRun{}).
-		Complete(r)
+		Build(r)
+	if err != nil {
+		return err
+	}
+	if types.MaxWatchesPerRun > 0 {
+		r.watches = newWatchTracker(func(gvk schema.GroupVersionKind) error {
+			return c.Watch(&source.Kind{Type: watchSource(gvk)}, handler.EnqueueRequestsFromMapFunc(r.watches.requests))
+		})
+	}
+	return nil
 }
 
 func (r *WorkflowRunReconciler) endWithNegativeCondition(ctx context.Context, wr *v1alpha1.WorkflowRun, condition condition.Condition) (ctrl.Result, error) {
@@ -229,12 +421,245 @@ func (r *WorkflowRunReconciler) patchStatus(ctx context.Context, wr *v1alpha1.Wo
 	return nil
 }
 
-func (r *WorkflowRunReconciler) doWorkflowFinish(wr *v1alpha1.WorkflowRun) {
+func (r *WorkflowRunReconciler) doWorkflowFinish(ctx monitorContext.Context, wr *v1alpha1.WorkflowRun) {
+	dryRun := types.IsRunDryRun(wr)
+	if dryRun {
+		// the resources are not applied and the context is not persisted in the dry-run mode
+		if wr.Status.ContextBackend != nil {
+			wfContext.MemStore.DeleteInMemoryStore(wr.Status.ContextBackend.Name, wr.Namespace)
+		}
+	} else {
+		if err := r.saveAppliedResources(ctx, wr); err != nil {
+			ctx.Error(err, "save applied resources")
+		}
+		if err := r.pruneOverflow(wr); err != nil {
+			ctx.Error(err, "prune overflow values")
+		}
+	}
 	wr.Status.Finished = true
 	wr.Status.EndTime = metav1.Now()
+	clearWatches(&wr.Status)
 	metrics.WorkflowRunFinishedTimeHistogram.WithLabelValues(string(wr.Status.Phase)).Observe(wr.Status.EndTime.Sub(wr.Status.StartTime.Time).Seconds())
 	executor.StepStatusCache.Delete(fmt.Sprintf("%s-%s", wr.Name, wr.Namespace))
 	wfContext.CleanupMemoryStore(wr.Name, wr.Namespace)
+	if err := lock.Release(ctx, r.Client, wr.Namespace, string(wr.UID)); err != nil {
+		ctx.Error(err, "release locks")
+	}
+	// the runs in the dry-run mode are not counted in the history
+	if !dryRun {
+		history.DefaultStore.Record(history.NewEntry(wr))
+	}
+	if wr.Annotations[types.AnnotationWorkflowRunLiveProgress] == "true" {
+		if err := progress.Delete(ctx, r.Client, wr.Namespace, wr.Name); err != nil {
+			ctx.Error(err, "delete live progress")
+		}
+	} else {
+		progress.Forget(wr.Namespace, wr.Name)
+	}
+	if dryRun {
+		wr.SetConditions(dryRunCompletedCondition(wr))
+		if cond, ok := skippedNotificationCondition(wr); ok {
+			wr.SetConditions(cond)
+		}
+		return
+	}
+	// the notifications are delivered by the reconcile of the persisted finished run
+	if cond, ok := notification.PendingCondition(wr); ok {
+		wr.SetConditions(cond)
+	}
+}
+
+// pruneOverflow removes the values in the overflow ConfigMap of the finished run which are referenced by neither the
+// context nor the truncated messages of the steps
+func (r *WorkflowRunReconciler) pruneOverflow(wr *v1alpha1.WorkflowRun) error {
+	if wr.Status.ContextBackend == nil {
+		return nil
+	}
+	wfCtx, err := wfContext.LoadContextFromRef(r.Client, wr.Namespace, wr.Name, wr.Status.ContextBackend)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	var messages []string
+	collect := func(ss v1alpha1.StepStatus) {
+		messages = append(messages, ss.Message)
+		for _, h := range ss.MessageHistory {
+			messages = append(messages, h.Message)
+		}
+	}
+	for _, ss := range wr.Status.Steps {
+		collect(ss.StepStatus)
+		for _, sub := range ss.SubStepsStatus {
+			collect(sub)
+		}
+	}
+	return wfCtx.PruneOverflow(func(ref wfContext.OverflowRef) bool {
+		suffix := fmt.Sprintf(types.MessageTruncated, ref.ConfigMapRef, ref.Key)
+		for _, message := range messages {
+			if strings.HasSuffix(message, suffix) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// notify delivers the pending notifications of the finished run and records the deliveries in the Notification
+// condition, the failures of the deliveries don't change the phase of the run.
+func (r *WorkflowRunReconciler) notify(ctx monitorContext.Context, wr *v1alpha1.WorkflowRun) error {
+	includeOutputs := false
+	for _, target := range wr.Spec.Notifications {
+		includeOutputs = includeOutputs || target.IncludeOutputs
+	}
+	var outputs map[string]string
+	if includeOutputs && wr.Status.ContextBackend != nil {
+		steps, err := generator.LoadSteps(ctx, r.Client, wr)
+		if err != nil {
+			ctx.Error(err, "load steps for the notifications")
+		} else if wfCtx, err := wfContext.LoadContextFromRef(r.Client, wr.Namespace, wr.Name, wr.Status.ContextBackend); err != nil {
+			ctx.Error(err, "load workflow context for the notifications")
+		} else {
+			outputs = notification.Outputs(wfCtx, steps)
+		}
+	}
+	cond, ok := notification.Send(ctx, r.Client, wr, outputs)
+	if !ok {
+		cond = condition.ReadyCondition(v1alpha1.WorkflowRunNotificationConditionType).WithMessage("delivered 0 notifications")
+	}
+	wr.SetConditions(cond)
+	if cond.Status == corev1.ConditionFalse {
+		r.Recorder.Event(wr, event.Warning(v1alpha1.ReasonNotify, errors.WithMessage(errors.New(cond.Message), v1alpha1.MessageFailedNotify)))
+	}
+	return r.patchStatus(ctx, wr, false)
+}
+
+// updateSummary refreshes the progress and the summary of the run, the steps are the ones of the workflow instance,
+// the started steps are counted if the instance is not generated
+func updateSummary(wr *v1alpha1.WorkflowRun, steps []v1alpha1.WorkflowStep) {
+	executor.UpdateSummary(&wr.Status, len(steps))
+}
+
+// withSummary appends the summary of the run to the message of the event
+func withSummary(message string, wr *v1alpha1.WorkflowRun) string {
+	if wr.Status.Summary == "" {
+		return message
+	}
+	return fmt.Sprintf("%s (%s)", message, wr.Status.Summary)
+}
+
+// setTerminatedCondition sets the condition describing why the run ends without success and counts it in the metrics
+func (r *WorkflowRunReconciler) setTerminatedCondition(wr *v1alpha1.WorkflowRun) {
+	cond, ok := executor.TerminatedCondition(&wr.Status)
+	if !ok {
+		return
+	}
+	wr.SetConditions(cond)
+	metrics.WorkflowRunTerminatedCounter.WithLabelValues(string(wr.Status.Phase), string(cond.Reason)).Inc()
+}
+
+// saveAppliedResources saves the resources applied by the run in the tracker if the resources are tracked,
+// which is used by the gc of the rerun and the deletion of the run
+func (r *WorkflowRunReconciler) saveAppliedResources(ctx monitorContext.Context, wr *v1alpha1.WorkflowRun) error {
+	if !types.IsResourceTracked(wr) {
+		return nil
+	}
+	resources, err := r.appliedResources(wr)
+	if err != nil || len(resources) == 0 {
+		return err
+	}
+	owner := metav1.OwnerReference{
+		APIVersion: v1alpha1.SchemeGroupVersion.String(),
+		Kind:       v1alpha1.WorkflowRunKind,
+		Name:       wr.Name,
+		UID:        wr.UID,
+		Controller: pointer.BoolPtr(true),
+	}
+	return resourcetracker.Save(ctx, r.Client, wr.Namespace, wr.Name, []metav1.OwnerReference{owner}, resources)
+}
+
+// appliedResources returns the resources recorded in the workflow context of the run
+func (r *WorkflowRunReconciler) appliedResources(wr *v1alpha1.WorkflowRun) ([]resourcetracker.Resource, error) {
+	if wr.Status.ContextBackend == nil {
+		return nil, nil
+	}
+	wfCtx, err := wfContext.LoadContextFromRef(r.Client, wr.Namespace, wr.Name, wr.Status.ContextBackend)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.WithMessage(err, "load workflow context")
+	}
+	return resourcetracker.Tracked(wfCtx)
+}
+
+// handleDeletion releases the locks held by the run and deletes the resources applied by the run if the resource
+// gc is enabled, then removes the finalizer
+func (r *WorkflowRunReconciler) handleDeletion(ctx monitorContext.Context, wr *v1alpha1.WorkflowRun) error {
+	progress.Forget(wr.Namespace, wr.Name)
+	if err := lock.Release(ctx, r.Client, wr.Namespace, string(wr.UID)); err != nil {
+		ctx.Error(err, "release locks")
+	}
+	if !controllerutil.ContainsFinalizer(wr, types.FinalizerResourceGC) {
+		return nil
+	}
+	if wr.Spec.ResourceGC {
+		resources, err := r.appliedResources(wr)
+		if err != nil {
+			return err
+		}
+		saved, err := resourcetracker.Load(ctx, r.Client, wr.Namespace, wr.Name)
+		if err != nil {
+			return errors.WithMessage(err, "load resource tracker")
+		}
+		resources = append(resources, resourcetracker.Subtract(saved, resources)...)
+		deleted, err := resourcetracker.Delete(ctx, r.Client, wr.Namespace, wr.Name, resources)
+		if err != nil {
+			r.Recorder.Event(wr, event.Warning(v1alpha1.ReasonGC, errors.WithMessage(err, v1alpha1.MessageFailedGC)))
+			return errors.WithMessage(err, "delete applied resources")
+		}
+		ctx.Info("Delete the resources applied by the workflow run", "resources", len(deleted))
+		if err := resourcetracker.DeleteTracker(ctx, r.Client, wr.Namespace, wr.Name); err != nil {
+			return errors.WithMessage(err, "delete resource tracker")
+		}
+	}
+	controllerutil.RemoveFinalizer(wr, types.FinalizerResourceGC)
+	return r.Update(ctx, wr)
+}
+
+func (r *WorkflowRunReconciler) exportOutputs(ctx monitorContext.Context, wr *v1alpha1.WorkflowRun) error {
+	if wr.Spec.ExportOutputs == nil || wr.Status.ContextBackend == nil {
+		return nil
+	}
+	wfCtx, err := wfContext.LoadContextFromRef(r.Client, wr.Namespace, wr.Name, wr.Status.ContextBackend)
+	if err != nil {
+		return errors.WithMessage(err, "load workflow context")
+	}
+	return export.Outputs(ctx, r.Client, wr, wfCtx)
+}
+
+// approvalTimeoutSteps returns the phases of the suspend steps which are not resumed in the timeout.
+func approvalTimeoutSteps(status v1alpha1.WorkflowRunStatus) map[string]v1alpha1.WorkflowStepPhase {
+	steps := make(map[string]v1alpha1.WorkflowStepPhase)
+	for _, step := range status.Steps {
+		for _, ss := range append([]v1alpha1.StepStatus{step.StepStatus}, step.SubStepsStatus...) {
+			if ss.Type == types.WorkflowStepTypeSuspend && ss.Reason == types.StatusReasonApprovalTimeout {
+				steps[ss.Name] = ss.Phase
+			}
+		}
+	}
+	return steps
+}
+
+// ignoredFailureSteps returns the messages of the failed steps whose failures are ignored by the onFailure policy.
+func ignoredFailureSteps(status v1alpha1.WorkflowRunStatus) map[string]string {
+	steps := make(map[string]string)
+	for _, step := range status.Steps {
+		for _, ss := range append([]v1alpha1.StepStatus{step.StepStatus}, step.SubStepsStatus...) {
+			if types.IsStepFailureIgnored(ss) {
+				steps[ss.Name] = ss.Message
+			}
+		}
+	}
+	return steps
 }
 
 func timeReconcile(wr *v1alpha1.WorkflowRun) func() {
@@ -0,0 +1,78 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// This example runs the workflow run in workflow.yaml without a cluster, the step
+// templates are loaded from the steps directory. Run it in this directory with:
+//
+//	go run . -f workflow.yaml -steps steps
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/runner"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func main() {
+	var file, steps string
+	flag.StringVar(&file, "f", "workflow.yaml", "The file of the workflow run")
+	flag.StringVar(&steps, "steps", "steps", "The directory of the step templates")
+	flag.Parse()
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	run := &v1alpha1.WorkflowRun{}
+	if err := yaml.Unmarshal(content, run); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// the workflow context and the resources are kept in the in-memory client
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	cli := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	// interrupting the process terminates the workflow
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	status, err := runner.New(run,
+		runner.WithClient(cli),
+		runner.WithGeneratorOptions(types.StepGeneratorOptions{TemplateFS: os.DirFS(steps)}),
+		runner.WithStepEventHandler(func(e runner.StepEvent) {
+			fmt.Printf("step %s is %s %s %v\n", e.StepName, e.Phase, e.Message, e.Output)
+		}),
+	).Run(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("workflow %s is %s\n", run.Name, status.Phase)
+}
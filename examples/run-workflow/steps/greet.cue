@@ -0,0 +1,2 @@
+parameter: name: string
+message: "hello " + parameter.name
@@ -0,0 +1,275 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/generator"
+	"github.com/kubevela/workflow/pkg/operation"
+	"github.com/kubevela/workflow/pkg/progress"
+)
+
+const (
+	// OperationResume resumes the suspended workflow run
+	OperationResume = "resume"
+	// OperationTerminate terminates the workflow run
+	OperationTerminate = "terminate"
+	// OperationRetryFromFailure retries the failed or terminated workflow run from the failed steps
+	OperationRetryFromFailure = "retry-from-failure"
+	// SubresourceGraph is the dependency graph of the steps of the workflow run
+	SubresourceGraph = "graph"
+)
+
+var operations = map[string]func(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) error{
+	OperationResume:           operation.Resume,
+	OperationTerminate:        operation.Terminate,
+	OperationRetryFromFailure: operation.RetryFromFailure,
+}
+
+// Server serves the http api to list, inspect and operate the workflow runs. The requests are authenticated
+// by the bearer tokens with TokenReview, and authorized with SubjectAccessReview against the workflow runs,
+// the operations require the permission to update the status of the workflow runs. The api is served over TLS
+// only, so that the bearer tokens are never sent in plain text.
+type Server struct {
+	addr       string
+	certFile   string
+	keyFile    string
+	cli        client.Client
+	kubeClient kubernetes.Interface
+}
+
+// New creates the api server listening on the address with the tls certificate and key files
+func New(addr, certFile, keyFile string, cli client.Client, kubeClient kubernetes.Interface) *Server {
+	return &Server{addr: addr, certFile: certFile, keyFile: keyFile, cli: cli, kubeClient: kubeClient}
+}
+
+// NeedLeaderElection implements the LeaderElectionRunnable interface, the api is served by the leader
+// as the operations reset the states of the workflow runs cached by the leader.
+func (s *Server) NeedLeaderElection() bool {
+	return true
+}
+
+// Start serves the api until the context is done
+func (s *Server) Start(ctx context.Context) error {
+	if s.certFile == "" || s.keyFile == "" {
+		return errors.New("the tls certificate and key are required to serve the api authenticated by the bearer tokens")
+	}
+	server := &http.Server{Addr: s.addr, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			klog.ErrorS(err, "Failed to shutdown the api server")
+		}
+	}()
+	klog.InfoS("Start the api server", "addr", s.addr)
+	if err := server.ListenAndServeTLS(s.certFile, s.keyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.WithMessage(err, "serve the api")
+	}
+	return nil
+}
+
+// ServeHTTP implements the http.Handler interface, the routes are
+// GET /v1/namespaces/{ns}/workflowruns,
+// GET /v1/namespaces/{ns}/workflowruns/{name},
+// GET /v1/namespaces/{ns}/workflowruns/{name}/graph and
+// POST /v1/namespaces/{ns}/workflowruns/{name}/{resume|terminate|retry-from-failure}.
+// The workflow run is terminated gracefully with the query `graceful=true` and the optional `gracePeriod`.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 4 || len(parts) > 6 || parts[0] != "v1" || parts[1] != "namespaces" || parts[3] != "workflowruns" {
+		writeError(w, http.StatusNotFound, errors.Errorf("no route for %s", r.URL.Path))
+		return
+	}
+	namespace, name, op := parts[2], "", ""
+	if len(parts) > 4 {
+		name = parts[4]
+	}
+	if len(parts) > 5 {
+		op = parts[5]
+	}
+
+	attributes := &authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Group:     v1alpha1.Group,
+		Resource:  "workflowruns",
+		Name:      name,
+	}
+	switch {
+	case op == "" && r.Method == http.MethodGet && name == "":
+		attributes.Verb = "list"
+	case (op == "" || op == SubresourceGraph) && r.Method == http.MethodGet:
+		attributes.Verb = "get"
+	case op != "" && r.Method == http.MethodPost:
+		if _, ok := operations[op]; !ok {
+			writeError(w, http.StatusNotFound, errors.Errorf("unknown operation %s", op))
+			return
+		}
+		attributes.Verb = "update"
+		attributes.Subresource = "status"
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s is not allowed for %s", r.Method, r.URL.Path))
+		return
+	}
+	if code, err := s.authorize(r, attributes); err != nil {
+		writeError(w, code, err)
+		return
+	}
+
+	ctx := r.Context()
+	if name == "" {
+		runs := &v1alpha1.WorkflowRunList{}
+		if err := s.cli.List(ctx, runs, client.InNamespace(namespace)); err != nil {
+			writeError(w, statusCode(err), err)
+			return
+		}
+		summaries := make([]Summary, 0, len(runs.Items))
+		for i := range runs.Items {
+			summaries = append(summaries, s.summarize(ctx, &runs.Items[i]))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"items": summaries})
+		return
+	}
+	run := &v1alpha1.WorkflowRun{}
+	if err := s.cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, run); err != nil {
+		writeError(w, statusCode(err), err)
+		return
+	}
+	if op == SubresourceGraph {
+		graph, err := generator.BuildGraph(ctx, s.cli, run)
+		if err != nil {
+			code := statusCode(err)
+			if generator.IsDependencyCycleErr(err) {
+				code = http.StatusUnprocessableEntity
+			}
+			writeError(w, code, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, graph)
+		return
+	}
+	if op != "" {
+		operate := operations[op]
+		if op == OperationTerminate && r.URL.Query().Get("graceful") == "true" {
+			var gracePeriod time.Duration
+			if v := r.URL.Query().Get("gracePeriod"); v != "" {
+				d, err := time.ParseDuration(v)
+				if err != nil {
+					writeError(w, http.StatusBadRequest, errors.Errorf("invalid grace period %s", v))
+					return
+				}
+				gracePeriod = d
+			}
+			operate = func(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) error {
+				return operation.TerminateGracefully(ctx, cli, run, gracePeriod)
+			}
+		}
+		if err := operate(ctx, s.cli, run); err != nil {
+			writeError(w, statusCode(err), err)
+			return
+		}
+		klog.InfoS("Operate the workflow run by api", "workflowrun", client.ObjectKeyFromObject(run), "operation", op)
+	}
+	writeJSON(w, http.StatusOK, s.summarize(ctx, run))
+}
+
+// summarize summarizes the workflow run with its live progress, the live progress is skipped if it can't be read
+func (s *Server) summarize(ctx context.Context, run *v1alpha1.WorkflowRun) Summary {
+	live, err := progress.Read(ctx, s.cli, run.Namespace, run.Name)
+	if err != nil {
+		klog.ErrorS(err, "Failed to read the live progress", "workflowrun", client.ObjectKeyFromObject(run))
+	}
+	return Summarize(run, live)
+}
+
+// authorize authenticates the bearer token of the request and checks whether the user is allowed to access
+// the workflow runs, it returns the status code to respond with if it's not allowed.
+func (s *Server) authorize(r *http.Request, attributes *authorizationv1.ResourceAttributes) (int, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		return http.StatusUnauthorized, errors.New("bearer token is required")
+	}
+	review, err := s.kubeClient.AuthenticationV1().TokenReviews().Create(r.Context(), &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return http.StatusInternalServerError, errors.WithMessage(err, "review the token")
+	}
+	if !review.Status.Authenticated {
+		return http.StatusUnauthorized, errors.Errorf("invalid bearer token: %s", review.Status.Error)
+	}
+	user := review.Status.User
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	access, err := s.kubeClient.AuthorizationV1().SubjectAccessReviews().Create(r.Context(), &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attributes,
+			User:               user.Username,
+			UID:                user.UID,
+			Groups:             user.Groups,
+			Extra:              extra,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return http.StatusInternalServerError, errors.WithMessage(err, "review the access")
+	}
+	if !access.Status.Allowed {
+		return http.StatusForbidden, errors.Errorf("user %s cannot %s the workflow runs in namespace %s", user.Username, attributes.Verb, attributes.Namespace)
+	}
+	return http.StatusOK, nil
+}
+
+func statusCode(err error) int {
+	if operation.IsInvalidOperationErr(err) {
+		return http.StatusConflict
+	}
+	var status kerrors.APIStatus
+	if errors.As(err, &status) {
+		return int(status.Status().Code)
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		klog.ErrorS(err, "Failed to write the response of the api")
+	}
+}
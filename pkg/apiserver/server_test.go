@@ -0,0 +1,213 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ktesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/progress"
+)
+
+func TestServer(t *testing.T) {
+	r := require.New(t)
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "suspended", Namespace: "default"},
+			Spec: v1alpha1.WorkflowRunSpec{WorkflowSpec: &v1alpha1.WorkflowSpec{Steps: []v1alpha1.WorkflowStep{
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "approve", Type: "suspend"}},
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "deploy", Type: "apply"}},
+			}}},
+			Status: v1alpha1.WorkflowRunStatus{
+				Phase:   v1alpha1.WorkflowStateSuspending,
+				Suspend: true,
+				Steps: []v1alpha1.WorkflowStepStatus{{
+					StepStatus: v1alpha1.StepStatus{Name: "approve", Type: "suspend", Phase: v1alpha1.WorkflowStepPhaseRunning},
+				}},
+			},
+		},
+		&v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "succeeded", Namespace: "default"},
+			Status:     v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateSucceeded, Finished: true},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: progress.GenerateProgressName("suspended"), Namespace: "default"},
+			Data: map[string]string{
+				progress.StepKey:      "deploy",
+				progress.PhaseKey:     string(v1alpha1.WorkflowStepPhaseRunning),
+				progress.HeartbeatKey: "2022-01-01T00:00:00Z",
+			},
+		},
+	).Build()
+
+	// the token "admin" is allowed to do anything, "viewer" is only allowed to get and list
+	kubeClient := kubefake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "tokenreviews", func(action ktesting.Action) (bool, runtime.Object, error) {
+		review := action.(ktesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		switch review.Spec.Token {
+		case "admin", "viewer":
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: review.Spec.Token}}
+		}
+		return true, review, nil
+	})
+	kubeClient.PrependReactor("create", "subjectaccessreviews", func(action ktesting.Action) (bool, runtime.Object, error) {
+		review := action.(ktesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = attributes.Group == v1alpha1.Group && attributes.Resource == "workflowruns" &&
+			(review.Spec.User == "admin" || attributes.Verb == "get" || attributes.Verb == "list")
+		return true, review, nil
+	})
+	server := httptest.NewTLSServer(New("", "", "", cli, kubeClient))
+	defer server.Close()
+
+	do := func(method, path, token string) (int, map[string]interface{}) {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		r.NoError(err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := server.Client().Do(req)
+		r.NoError(err)
+		defer resp.Body.Close()
+		body := map[string]interface{}{}
+		r.NoError(json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	code, _ := do(http.MethodGet, "/v1/namespaces/default/workflowruns", "")
+	r.Equal(http.StatusUnauthorized, code)
+	code, _ = do(http.MethodGet, "/v1/namespaces/default/workflowruns", "unknown")
+	r.Equal(http.StatusUnauthorized, code)
+
+	code, body := do(http.MethodGet, "/v1/namespaces/default/workflowruns", "viewer")
+	r.Equal(http.StatusOK, code)
+	r.Len(body["items"], 2)
+
+	code, body = do(http.MethodGet, "/v1/namespaces/default/workflowruns/suspended", "viewer")
+	r.Equal(http.StatusOK, code)
+	r.Equal("suspending", body["phase"])
+	r.Equal([]interface{}{map[string]interface{}{"name": "approve", "type": "suspend", "phase": "running"}}, body["steps"])
+	r.Equal("deploy", body["currentStep"])
+	r.Equal("2022-01-01T00:00:00Z", body["heartbeat"])
+
+	code, body = do(http.MethodGet, "/v1/namespaces/default/workflowruns/suspended/graph", "viewer")
+	r.Equal(http.StatusOK, code)
+	r.Equal([]interface{}{
+		map[string]interface{}{"name": "approve", "type": "suspend", "phase": "running"},
+		map[string]interface{}{"name": "deploy", "type": "apply"},
+	}, body["nodes"])
+	r.Equal([]interface{}{map[string]interface{}{"from": "approve", "to": "deploy", "type": "ordering"}}, body["edges"])
+
+	code, _ = do(http.MethodGet, "/v1/namespaces/default/workflowruns/not-found", "viewer")
+	r.Equal(http.StatusNotFound, code)
+	code, _ = do(http.MethodPost, "/v1/namespaces/default/workflowruns/suspended/resume", "viewer")
+	r.Equal(http.StatusForbidden, code)
+	code, _ = do(http.MethodPost, "/v1/namespaces/default/workflowruns/suspended/rollback", "admin")
+	r.Equal(http.StatusNotFound, code)
+	code, _ = do(http.MethodDelete, "/v1/namespaces/default/workflowruns/suspended", "admin")
+	r.Equal(http.StatusMethodNotAllowed, code)
+
+	code, body = do(http.MethodPost, "/v1/namespaces/default/workflowruns/suspended/resume", "admin")
+	r.Equal(http.StatusOK, code)
+	r.Equal(false, body["suspend"])
+	run := &v1alpha1.WorkflowRun{}
+	r.NoError(cli.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "suspended"}, run))
+	r.False(run.Status.Suspend)
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, run.Status.Steps[0].Phase)
+
+	code, body = do(http.MethodPost, "/v1/namespaces/default/workflowruns/succeeded/terminate", "admin")
+	r.Equal(http.StatusConflict, code)
+	r.Equal("cannot terminate the workflow run in phase succeeded", body["error"])
+
+	code, _ = do(http.MethodPost, "/v1/namespaces/default/workflowruns/suspended/terminate?graceful=true&gracePeriod=invalid", "admin")
+	r.Equal(http.StatusBadRequest, code)
+	code, _ = do(http.MethodPost, "/v1/namespaces/default/workflowruns/suspended/terminate?graceful=true&gracePeriod=1m", "admin")
+	r.Equal(http.StatusOK, code)
+	r.NoError(cli.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "suspended"}, run))
+	r.False(run.Status.Terminated)
+	r.NotNil(run.Status.GracefulTermination)
+	r.Equal(time.Minute, run.Status.GracefulTermination.GracePeriod.Duration)
+}
+
+func TestStartWithoutTLS(t *testing.T) {
+	r := require.New(t)
+	err := New(":0", "", "", nil, nil).Start(context.Background())
+	r.Error(err)
+	r.Contains(err.Error(), "tls certificate and key are required")
+}
+
+func TestSummarize(t *testing.T) {
+	r := require.New(t)
+	heartbeat := time.Date(2022, 1, 1, 0, 0, 10, 0, time.UTC)
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"},
+		Status: v1alpha1.WorkflowRunStatus{
+			Phase: v1alpha1.WorkflowStateExecuting,
+			Steps: []v1alpha1.WorkflowStepStatus{{
+				StepStatus: v1alpha1.StepStatus{Name: "build", Phase: v1alpha1.WorkflowStepPhaseSucceeded, LastExecuteTime: metav1.NewTime(heartbeat.Add(-time.Minute))},
+			}, {
+				StepStatus: v1alpha1.StepStatus{Name: "group", Phase: v1alpha1.WorkflowStepPhaseRunning},
+				SubStepsStatus: []v1alpha1.StepStatus{
+					{Name: "deploy", Phase: v1alpha1.WorkflowStepPhaseRunning, LastExecuteTime: metav1.NewTime(heartbeat.Add(-time.Minute))},
+					{Name: "notify", Phase: v1alpha1.WorkflowStepPhaseSucceeded, LastExecuteTime: metav1.NewTime(heartbeat.Add(time.Minute))},
+				},
+			}},
+		},
+	}
+
+	summary := Summarize(run, nil)
+	r.Empty(summary.CurrentStep)
+	r.Nil(summary.Heartbeat)
+
+	// the live progress is newer than the status of the step
+	summary = Summarize(run, &progress.Progress{Step: "deploy", Phase: v1alpha1.WorkflowStepPhaseSucceeded, Heartbeat: heartbeat})
+	r.Equal("deploy", summary.CurrentStep)
+	r.Equal(heartbeat, summary.Heartbeat.Time)
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, summary.Steps[1].SubSteps[0].Phase)
+
+	// the status of the step is newer than the live progress
+	summary = Summarize(run, &progress.Progress{Step: "notify", Phase: v1alpha1.WorkflowStepPhaseRunning, Heartbeat: heartbeat})
+	r.Empty(summary.CurrentStep)
+	r.Nil(summary.Heartbeat)
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, summary.Steps[1].SubSteps[1].Phase)
+
+	// the status of the finished run is always preferred
+	run.Status.Finished = true
+	summary = Summarize(run, &progress.Progress{Step: "deploy", Phase: v1alpha1.WorkflowStepPhaseSucceeded, Heartbeat: heartbeat})
+	r.Empty(summary.CurrentStep)
+	r.Equal(v1alpha1.WorkflowStepPhaseRunning, summary.Steps[1].SubSteps[0].Phase)
+}
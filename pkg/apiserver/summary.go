@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/progress"
+)
+
+// Summary is the status summary of the workflow run
+type Summary struct {
+	Name       string                    `json:"name"`
+	Namespace  string                    `json:"namespace"`
+	Phase      v1alpha1.WorkflowRunPhase `json:"phase"`
+	Message    string                    `json:"message,omitempty"`
+	Suspend    bool                      `json:"suspend"`
+	Terminated bool                      `json:"terminated"`
+	Finished   bool                      `json:"finished"`
+	StartTime  *metav1.Time              `json:"startTime,omitempty"`
+	EndTime    *metav1.Time              `json:"endTime,omitempty"`
+	Steps      []StepSummary             `json:"steps,omitempty"`
+	// CurrentStep and Heartbeat are filled by the live progress if it's newer than the status
+	CurrentStep string       `json:"currentStep,omitempty"`
+	Heartbeat   *metav1.Time `json:"heartbeat,omitempty"`
+}
+
+// StepSummary is the status summary of the step
+type StepSummary struct {
+	Name     string                     `json:"name"`
+	Type     string                     `json:"type,omitempty"`
+	Phase    v1alpha1.WorkflowStepPhase `json:"phase"`
+	Reason   string                     `json:"reason,omitempty"`
+	Message  string                     `json:"message,omitempty"`
+	SubSteps []StepSummary              `json:"subSteps,omitempty"`
+}
+
+// Summarize returns the status summary of the workflow run including the phases of the steps, the live progress
+// of the run is overlaid on the summary if the status has no newer entry of its current step
+func Summarize(run *v1alpha1.WorkflowRun, live *progress.Progress) Summary {
+	summary := Summary{
+		Name:       run.Name,
+		Namespace:  run.Namespace,
+		Phase:      run.Status.Phase,
+		Message:    run.Status.Message,
+		Suspend:    run.Status.Suspend,
+		Terminated: run.Status.Terminated,
+		Finished:   run.Status.Finished,
+	}
+	if !run.Status.StartTime.IsZero() {
+		summary.StartTime = run.Status.StartTime.DeepCopy()
+	}
+	if !run.Status.EndTime.IsZero() {
+		summary.EndTime = run.Status.EndTime.DeepCopy()
+	}
+	for _, ss := range run.Status.Steps {
+		step := summarizeStep(ss.StepStatus)
+		for _, sub := range ss.SubStepsStatus {
+			step.SubSteps = append(step.SubSteps, summarizeStep(sub))
+		}
+		summary.Steps = append(summary.Steps, step)
+	}
+	if live != nil && live.Overlays(run.Status) {
+		summary.CurrentStep = live.Step
+		summary.Heartbeat = &metav1.Time{Time: live.Heartbeat}
+		for i := range summary.Steps {
+			if summary.Steps[i].Name == live.Step {
+				summary.Steps[i].Phase = live.Phase
+			}
+			for j := range summary.Steps[i].SubSteps {
+				if summary.Steps[i].SubSteps[j].Name == live.Step {
+					summary.Steps[i].SubSteps[j].Phase = live.Phase
+				}
+			}
+		}
+	}
+	return summary
+}
+
+func summarizeStep(ss v1alpha1.StepStatus) StepSummary {
+	return StepSummary{
+		Name:    ss.Name,
+		Type:    ss.Type,
+		Phase:   ss.Phase,
+		Reason:  ss.Reason,
+		Message: ss.Message,
+	}
+}
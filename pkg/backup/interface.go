@@ -2,8 +2,13 @@ package backup
 
 import (
 	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
 )
 
 // PersistType is the type of persister.
@@ -12,15 +17,31 @@ type PersistType string
 const (
 	// PersistTypeSLS is the SLS persister.
 	PersistTypeSLS PersistType = "sls"
+	// PersistTypeS3 is the S3-compatible object storage persister.
+	PersistTypeS3 PersistType = "s3"
 )
 
+// Retention is the retention policy of the persisted records.
+type Retention struct {
+	// Count is the max number of the records kept in each group, zero means no limit
+	Count int
+	// GroupByLabel is the label of the workflow run to group the records, the records are grouped by namespace if it's empty
+	GroupByLabel string
+}
+
 // NewPersister is a factory method for creating a persister.
-func NewPersister(persistType PersistType) persistWorkflowRecord {
+func NewPersister(cli client.Client, persistType PersistType, config map[string][]byte, retention Retention) (persistWorkflowRecord, error) {
 	switch persistType {
 	case PersistTypeSLS:
-		return &slsHandler{}
+		return &slsHandler{}, nil
+	case PersistTypeS3:
+		handler, err := newS3Handler(cli, config, retention)
+		if err != nil {
+			return nil, err
+		}
+		return handler, nil
 	default:
-		return nil
+		return nil, nil
 	}
 }
 
@@ -28,6 +49,55 @@ type persistWorkflowRecord interface {
 	Store(ctx context.Context, run *v1alpha1.WorkflowRun) error
 }
 
+// Record is the workflow record to persist, including the workflow run, its context and the overflow values of the
+// context.
+type Record struct {
+	WorkflowRun *v1alpha1.WorkflowRun `json:"workflowRun"`
+	Context     map[string]string     `json:"context,omitempty"`
+	Overflow    map[string]string     `json:"overflow,omitempty"`
+}
+
+func newRecord(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) (*Record, error) {
+	record := &Record{WorkflowRun: run.DeepCopy()}
+	record.WorkflowRun.ManagedFields = nil
+	if ref := run.Status.ContextBackend; ref != nil && cli != nil {
+		cm := &corev1.ConfigMap{}
+		if err := cli.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, cm); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return nil, err
+			}
+		} else if ref.UID == "" || cm.UID == ref.UID {
+			// the stale context left by a previous run with the same name is not persisted
+			data, err := wfContext.InlineComponents(ctx, cli, cm)
+			if err != nil {
+				return nil, err
+			}
+			record.Context = data
+			overflow, err := wfContext.OverflowData(ctx, cli, cm)
+			if err != nil {
+				return nil, err
+			}
+			record.Overflow = overflow
+		}
+	}
+	return record, nil
+}
+
+// recordDir returns the directory of the record, the group is included if the retention groups the records by label
+func recordDir(prefix string, retention Retention, run *v1alpha1.WorkflowRun) string {
+	dir := fmt.Sprintf("%s%s/", prefix, run.Namespace)
+	if retention.GroupByLabel != "" {
+		if group := run.Labels[retention.GroupByLabel]; group != "" {
+			dir += group + "/"
+		}
+	}
+	return dir
+}
+
+func recordKey(prefix string, retention Retention, run *v1alpha1.WorkflowRun) string {
+	return fmt.Sprintf("%s%s.json", recordDir(prefix, retention, run), run.Name)
+}
+
 type slsHandler struct {
 }
 
@@ -0,0 +1,247 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+const (
+	// S3EndpointKey is the key of the endpoint in the backup secret, e.g. https://s3.us-east-1.amazonaws.com
+	S3EndpointKey = "endpoint"
+	// S3BucketKey is the key of the bucket in the backup secret
+	S3BucketKey = "bucket"
+	// S3RegionKey is the key of the region in the backup secret
+	S3RegionKey = "region"
+	// S3AccessKeyIDKey is the key of the access key id in the backup secret
+	S3AccessKeyIDKey = "accessKeyID"
+	// S3SecretAccessKeyKey is the key of the secret access key in the backup secret
+	S3SecretAccessKeyKey = "secretAccessKey"
+	// S3PrefixKey is the key of the object key prefix in the backup secret
+	S3PrefixKey = "prefix"
+
+	s3Algorithm = "AWS4-HMAC-SHA256"
+	s3TimeFmt   = "20060102T150405Z"
+	s3DateFmt   = "20060102"
+)
+
+type s3Handler struct {
+	cli             client.Client
+	httpClient      *http.Client
+	endpoint        *url.URL
+	bucket          string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	prefix          string
+	retention       Retention
+}
+
+func newS3Handler(cli client.Client, config map[string][]byte, retention Retention) (*s3Handler, error) {
+	for _, key := range []string{S3EndpointKey, S3BucketKey, S3AccessKeyIDKey, S3SecretAccessKeyKey} {
+		if len(config[key]) == 0 {
+			return nil, errors.Errorf("%s is required in the backup secret for s3", key)
+		}
+	}
+	endpoint, err := url.Parse(string(config[S3EndpointKey]))
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid s3 endpoint")
+	}
+	region := string(config[S3RegionKey])
+	if region == "" {
+		region = "us-east-1"
+	}
+	prefix := string(config[S3PrefixKey])
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &s3Handler{
+		cli:             cli,
+		httpClient:      &http.Client{Timeout: 30 * time.Second},
+		endpoint:        endpoint,
+		bucket:          string(config[S3BucketKey]),
+		region:          region,
+		accessKeyID:     string(config[S3AccessKeyIDKey]),
+		secretAccessKey: string(config[S3SecretAccessKeyKey]),
+		prefix:          prefix,
+		retention:       retention,
+	}, nil
+}
+
+// Store uploads the workflow record to the bucket, the failed upload is retried by requeueing the run.
+// If the retention count is set, the oldest records beyond the count in the group are deleted after uploading.
+func (s *s3Handler) Store(ctx context.Context, run *v1alpha1.WorkflowRun) error {
+	record, err := newRecord(ctx, s.cli, run)
+	if err != nil {
+		return errors.WithMessage(err, "get workflow record")
+	}
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	key := recordKey(s.prefix, s.retention, run)
+	if _, err := s.do(ctx, http.MethodPut, key, nil, body); err != nil {
+		return err
+	}
+	if s.retention.Count > 0 {
+		// the failures of the retention should not fail the backup
+		dir := recordDir(s.prefix, s.retention, run)
+		if err := s.prune(ctx, dir); err != nil {
+			klog.ErrorS(err, "Failed to delete the outdated workflow records", "bucket", s.bucket, "dir", dir)
+		}
+	}
+	return nil
+}
+
+type s3Object struct {
+	Key          string    `xml:"Key"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+type s3ListResult struct {
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+}
+
+// prune deletes the oldest records in the dir beyond the retention count
+func (s *s3Handler) prune(ctx context.Context, dir string) error {
+	var objects []s3Object
+	query := url.Values{"list-type": []string{"2"}, "prefix": []string{dir}, "delimiter": []string{"/"}}
+	for {
+		body, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return errors.WithMessage(err, "list objects")
+		}
+		result := &s3ListResult{}
+		if err := xml.Unmarshal(body, result); err != nil {
+			return errors.WithMessage(err, "parse objects")
+		}
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	if len(objects) <= s.retention.Count {
+		return nil
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].LastModified.After(objects[j].LastModified)
+	})
+	var errs []error
+	for _, obj := range objects[s.retention.Count:] {
+		if _, err := s.do(ctx, http.MethodDelete, obj.Key, nil, nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (s *s3Handler) do(ctx context.Context, method, key string, query url.Values, body []byte) ([]byte, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	s.sign(req, body, time.Now().UTC())
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	//nolint:errcheck
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, errors.Errorf("failed to %s object %s, status code: %d, message: %s", strings.ToLower(method), key, resp.StatusCode, string(data))
+	}
+	return data, nil
+}
+
+// sign signs the request with AWS Signature Version 4
+func (s *s3Handler) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format(s3TimeFmt)
+	date := now.Format(s3DateFmt)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", req.URL.Host, payloadHash, amzDate)
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		signedHeaders = "content-type;" + signedHeaders
+		canonicalHeaders = fmt.Sprintf("content-type:%s\n", contentType) + canonicalHeaders
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.region)
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.accessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+func TestS3Store(t *testing.T) {
+	r := require.New(t)
+	var (
+		failures = 0
+		path     string
+		auth     string
+		record   Record
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		r.Equal(http.MethodPut, req.Method)
+		path = req.URL.Path
+		auth = req.Header.Get("Authorization")
+		body, err := io.ReadAll(req.Body)
+		r.NoError(err)
+		r.NoError(json.Unmarshal(body, &record))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cli := fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "workflow-test-context", Namespace: "default"},
+		Data:       map[string]string{"vars": "{}", "overflow": "workflow-test-context-outputs"},
+	}, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "workflow-test-context-outputs", Namespace: "default"},
+		Data:       map[string]string{"render": "{replicas: 3}"},
+	}).Build()
+	persister, err := NewPersister(cli, PersistTypeS3, map[string][]byte{
+		S3EndpointKey:        []byte(server.URL),
+		S3BucketKey:          []byte("records"),
+		S3AccessKeyIDKey:     []byte("id"),
+		S3SecretAccessKeyKey: []byte("secret"),
+		S3PrefixKey:          []byte("workflow"),
+	}, Retention{})
+	r.NoError(err)
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Status: v1alpha1.WorkflowRunStatus{
+			Phase:          v1alpha1.WorkflowStateSucceeded,
+			ContextBackend: &corev1.ObjectReference{Name: "workflow-test-context", Namespace: "default"},
+		},
+	}
+	r.NoError(persister.Store(context.Background(), run))
+	r.Equal("/records/workflow/default/test.json", path)
+	r.True(strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=id/"))
+	r.Contains(auth, "/us-east-1/s3/aws4_request")
+	r.Equal("test", record.WorkflowRun.Name)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, record.WorkflowRun.Status.Phase)
+	r.Equal(map[string]string{"vars": "{}", "overflow": "workflow-test-context-outputs"}, record.Context)
+	r.Equal(map[string]string{"render": "{replicas: 3}"}, record.Overflow)
+
+	// the failed upload is not retried in place
+	failures = 1
+	r.Error(persister.Store(context.Background(), run))
+	r.Equal(0, failures)
+}
+
+func TestNewS3Handler(t *testing.T) {
+	r := require.New(t)
+	_, err := NewPersister(nil, PersistTypeS3, map[string][]byte{
+		S3EndpointKey: []byte("http://localhost"),
+	}, Retention{})
+	r.Error(err)
+	p, err := NewPersister(nil, "", nil, Retention{})
+	r.NoError(err)
+	r.Nil(p)
+}
+
+func TestS3Retention(t *testing.T) {
+	r := require.New(t)
+	var (
+		objects   = map[string]time.Time{}
+		listQuery url.Values
+		deleted   []string
+	)
+	now := time.Now()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := strings.TrimPrefix(req.URL.Path, "/records/")
+		switch req.Method {
+		case http.MethodPut:
+			objects[key] = now
+		case http.MethodGet:
+			listQuery = req.URL.Query()
+			var keys []string
+			for k := range objects {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			result := s3ListResult{}
+			for _, k := range keys {
+				result.Contents = append(result.Contents, s3Object{Key: k, LastModified: objects[k]})
+			}
+			body, err := xml.Marshal(result)
+			r.NoError(err)
+			_, _ = w.Write(body)
+			return
+		case http.MethodDelete:
+			deleted = append(deleted, key)
+			if key == "default/app/run-1.json" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			delete(objects, key)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	objects["default/app/run-1.json"] = now.Add(-3 * time.Hour)
+	objects["default/app/run-2.json"] = now.Add(-2 * time.Hour)
+	objects["default/app/run-3.json"] = now.Add(-time.Hour)
+	persister, err := NewPersister(nil, PersistTypeS3, map[string][]byte{
+		S3EndpointKey:        []byte(server.URL),
+		S3BucketKey:          []byte("records"),
+		S3AccessKeyIDKey:     []byte("id"),
+		S3SecretAccessKeyKey: []byte("secret"),
+	}, Retention{Count: 2, GroupByLabel: "app"})
+	r.NoError(err)
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "run-4", Namespace: "default", Labels: map[string]string{"app": "app"}},
+	}
+	// the deletion failures should not fail the backup
+	r.NoError(persister.Store(context.Background(), run))
+	r.Equal("default/app/", listQuery.Get("prefix"))
+	r.Equal("/", listQuery.Get("delimiter"))
+	sort.Strings(deleted)
+	r.Equal([]string{"default/app/run-1.json", "default/app/run-2.json"}, deleted)
+	r.Contains(objects, "default/app/run-3.json")
+	r.Contains(objects, "default/app/run-4.json")
+	r.NotContains(objects, "default/app/run-2.json")
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ComponentsPerShard is the max number of the components stored in one shard ConfigMap
+var ComponentsPerShard = 20
+
+// componentStore keeps the components of the workflow context. The components are stored in the shard
+// ConfigMaps named `<context>-components-<n>`, and the primary ConfigMap keeps the index from the
+// component names to the shards. The components are decoded on the first access, and only the shards
+// with changed components are written back.
+// The contexts written before sharding keep all the components in the primary ConfigMap, they are
+// read as is and moved to the shards when any component is changed.
+type componentStore struct {
+	// components are the decoded components
+	components map[string]*ComponentManifest
+	// legacy are the encoded components in the primary ConfigMap
+	legacy map[string]string
+	// index is the shard name of each component
+	index map[string]string
+	// shards are the loaded shard ConfigMaps
+	shards map[string]*corev1.ConfigMap
+	// changed are the names of the changed components
+	changed map[string]bool
+	// dirtyShards are the names of the shards to write back
+	dirtyShards map[string]bool
+}
+
+func newComponentStore() componentStore {
+	return componentStore{
+		components:  map[string]*ComponentManifest{},
+		legacy:      map[string]string{},
+		index:       map[string]string{},
+		shards:      map[string]*corev1.ConfigMap{},
+		changed:     map[string]bool{},
+		dirtyShards: map[string]bool{},
+	}
+}
+
+// loadComponentStore loads the index or the legacy components from the primary ConfigMap, the shards
+// already loaded are kept since they are consistent with what the context wrote.
+func (wf *WorkflowContext) loadComponentStore(data map[string]string) error {
+	shards := wf.shards
+	wf.componentStore = newComponentStore()
+	if shards != nil {
+		wf.shards = shards
+	}
+	if data[ConfigMapKeyComponents] != "" {
+		if err := json.Unmarshal([]byte(data[ConfigMapKeyComponents]), &wf.legacy); err != nil {
+			return errors.WithMessage(err, "decode components")
+		}
+	}
+	if data[ConfigMapKeyComponentsIndex] != "" {
+		if err := json.Unmarshal([]byte(data[ConfigMapKeyComponentsIndex]), &wf.index); err != nil {
+			return errors.WithMessage(err, "decode components index")
+		}
+	}
+	return nil
+}
+
+func (wf *WorkflowContext) componentNames() []string {
+	var names []string
+	for name := range wf.legacy {
+		names = append(names, name)
+	}
+	for name := range wf.index {
+		if _, ok := wf.legacy[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadComponent decodes the component from the legacy layout or its shard
+func (wf *WorkflowContext) loadComponent(name string) (*ComponentManifest, bool, error) {
+	if component, ok := wf.components[name]; ok {
+		return component, true, nil
+	}
+	encoded, ok := wf.legacy[name]
+	if !ok {
+		shardName, indexed := wf.index[name]
+		if !indexed {
+			return nil, false, nil
+		}
+		shard, err := wf.loadShard(shardName)
+		if err != nil {
+			return nil, false, err
+		}
+		if encoded, ok = shard.Data[name]; !ok {
+			return nil, false, errors.Errorf("component %s not found in shard %s", name, shardName)
+		}
+	}
+	component := new(ComponentManifest)
+	if err := component.unmarshal(encoded); err != nil {
+		return nil, false, errors.WithMessagef(err, "unmarshal component(%s) manifest", name)
+	}
+	wf.components[name] = component
+	return component, true, nil
+}
+
+// loadShard gets the shard from the cache or the store, the shard not referred by the index is new
+// and created on commit.
+func (wf *WorkflowContext) loadShard(name string) (*corev1.ConfigMap, error) {
+	if shard, ok := wf.shards[name]; ok {
+		return shard, nil
+	}
+	shard := &corev1.ConfigMap{}
+	shard.Name = name
+	shard.Namespace = wf.store.Namespace
+	if wf.indexed(name) {
+		if InMemory(wf.cli) {
+			if cm := MemStore.GetInMemoryContext(name, wf.store.Namespace); cm != nil {
+				cm.DeepCopyInto(shard)
+			}
+		} else if err := wf.cli.Get(context.Background(), client.ObjectKey{Namespace: shard.Namespace, Name: name}, shard); err != nil {
+			if !kerrors.IsNotFound(err) {
+				return nil, errors.WithMessagef(err, "load components shard %s", name)
+			}
+		}
+	}
+	if shard.Data == nil {
+		shard.Data = map[string]string{}
+	}
+	shard.SetOwnerReferences(wf.store.OwnerReferences)
+	wf.shards[name] = shard
+	return shard, nil
+}
+
+func (wf *WorkflowContext) indexed(shardName string) bool {
+	for _, name := range wf.index {
+		if name == shardName {
+			return true
+		}
+	}
+	return false
+}
+
+func (wf *WorkflowContext) shardName(n int) string {
+	return fmt.Sprintf("%s-components-%d", wf.store.Name, n)
+}
+
+// writeComponents writes the changed components into their shards, the legacy components are all
+// moved to the shards if any of them is changed.
+func (wf *WorkflowContext) writeComponents() error {
+	if len(wf.changed) == 0 {
+		return nil
+	}
+	names := []string{}
+	for name := range wf.changed {
+		names = append(names, name)
+	}
+	if len(wf.legacy) > 0 {
+		names = wf.componentNames()
+	}
+	sort.Strings(names)
+	counts := map[string]int{}
+	for _, shard := range wf.index {
+		counts[shard]++
+	}
+	for _, name := range names {
+		encoded, ok := wf.legacy[name]
+		if component, loaded := wf.components[name]; loaded && (wf.changed[name] || !ok) {
+			s, err := component.string()
+			if err != nil {
+				return errors.WithMessagef(err, "encode component %s ", name)
+			}
+			encoded = s
+		}
+		shardName, indexed := wf.index[name]
+		if !indexed {
+			for n := 0; ; n++ {
+				if shardName = wf.shardName(n); counts[shardName] < ComponentsPerShard {
+					break
+				}
+			}
+		}
+		shard, err := wf.loadShard(shardName)
+		if err != nil {
+			return err
+		}
+		if !indexed {
+			wf.index[name] = shardName
+			counts[shardName]++
+		}
+		shard.Data[name] = encoded
+		wf.dirtyShards[shardName] = true
+	}
+	index, err := json.Marshal(wf.index)
+	if err != nil {
+		return err
+	}
+	wf.store.Data[ConfigMapKeyComponentsIndex] = string(index)
+	delete(wf.store.Data, ConfigMapKeyComponents)
+	wf.legacy = map[string]string{}
+	return nil
+}
+
+// InlineComponents returns the data of the context ConfigMap with the components of its shards inlined in the
+// legacy layout, so that the context is self-contained, e.g. in the backup records.
+func InlineComponents(ctx context.Context, cli client.Reader, cm *corev1.ConfigMap) (map[string]string, error) {
+	data := map[string]string{}
+	for k, v := range cm.Data {
+		data[k] = v
+	}
+	if data[ConfigMapKeyComponentsIndex] == "" {
+		return data, nil
+	}
+	index := map[string]string{}
+	if err := json.Unmarshal([]byte(data[ConfigMapKeyComponentsIndex]), &index); err != nil {
+		return nil, errors.WithMessage(err, "decode components index")
+	}
+	components := map[string]string{}
+	shards := map[string]*corev1.ConfigMap{}
+	for name, shardName := range index {
+		shard, ok := shards[shardName]
+		if !ok {
+			shard = &corev1.ConfigMap{}
+			if err := cli.Get(ctx, client.ObjectKey{Namespace: cm.Namespace, Name: shardName}, shard); err != nil {
+				return nil, errors.WithMessagef(err, "load components shard %s", shardName)
+			}
+			shards[shardName] = shard
+		}
+		if encoded, ok := shard.Data[name]; ok {
+			components[name] = encoded
+		}
+	}
+	b, err := json.Marshal(components)
+	if err != nil {
+		return nil, err
+	}
+	data[ConfigMapKeyComponents] = string(b)
+	delete(data, ConfigMapKeyComponentsIndex)
+	return data, nil
+}
+
+// deleteShards deletes the shards indexed by the context ConfigMap and drops its components, it's called when the
+// ConfigMap is reused by a new context so that the stale components are not mixed into the new ones.
+func deleteShards(ctx context.Context, cli client.Client, cm *corev1.ConfigMap) error {
+	if data := cm.Data[ConfigMapKeyComponentsIndex]; data != "" {
+		index := map[string]string{}
+		if err := json.Unmarshal([]byte(data), &index); err != nil {
+			return errors.WithMessage(err, "decode components index")
+		}
+		deleted := map[string]bool{}
+		for _, name := range index {
+			if deleted[name] {
+				continue
+			}
+			deleted[name] = true
+			if InMemory(cli) {
+				MemStore.deleteInMemoryContext(name, cm.Namespace)
+				continue
+			}
+			shard := &corev1.ConfigMap{}
+			shard.Name = name
+			shard.Namespace = cm.Namespace
+			if err := cli.Delete(ctx, shard); err != nil && !kerrors.IsNotFound(err) {
+				return errors.WithMessagef(err, "delete components shard %s", name)
+			}
+		}
+	}
+	delete(cm.Data, ConfigMapKeyComponentsIndex)
+	delete(cm.Data, ConfigMapKeyComponents)
+	return nil
+}
+
+// syncShards writes the dirty shards back to the store
+func (wf *WorkflowContext) syncShards() error {
+	names := []string{}
+	for name := range wf.dirtyShards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		shard := wf.shards[name]
+		if InMemory(wf.cli) {
+			MemStore.UpdateInMemoryContext(shard)
+		} else if shard.ResourceVersion == "" {
+			if err := wf.createShard(shard); err != nil {
+				return errors.WithMessagef(err, "create components shard %s", name)
+			}
+		} else if err := wf.cli.Update(context.Background(), shard); err != nil {
+			return errors.WithMessagef(err, "update components shard %s", name)
+		}
+		delete(wf.dirtyShards, name)
+	}
+	wf.changed = map[string]bool{}
+	return nil
+}
+
+// createShard creates the shard, the stale one left by a former context of the same name is replaced
+func (wf *WorkflowContext) createShard(shard *corev1.ConfigMap) error {
+	ctx := context.Background()
+	err := wf.cli.Create(ctx, shard)
+	if !kerrors.IsAlreadyExists(err) {
+		return err
+	}
+	stale := &corev1.ConfigMap{}
+	if err := wf.cli.Get(ctx, client.ObjectKeyFromObject(shard), stale); err != nil {
+		return err
+	}
+	shard.ResourceVersion = stale.ResourceVersion
+	return wf.cli.Update(ctx, shard)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+func TestShardedComponents(t *testing.T) {
+	r := require.New(t)
+	defer func(n int) { ComponentsPerShard = n }(ComponentsPerShard)
+	ComponentsPerShard = 2
+
+	legacy := map[string]string{}
+	for _, name := range []string{"a", "b", "c"} {
+		b, err := json.Marshal(componentMould{StandardWorkload: fmt.Sprintf(`{"kind":"Pod","metadata":{"name":"%s"}}`, name)})
+		r.NoError(err)
+		legacy[name] = string(b)
+	}
+	b, err := json.Marshal(legacy)
+	r.NoError(err)
+	cli := fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "workflow-app-context", Namespace: "default"},
+		Data:       map[string]string{ConfigMapKeyComponents: string(b)},
+	}).Build()
+	ctx := context.Background()
+	patch := func(wfCtx Context, name string) {
+		pv, err := value.NewValue(`metadata: labels: patched: "true"`, nil, "")
+		r.NoError(err)
+		r.NoError(wfCtx.PatchComponent(name, pv))
+		r.NoError(wfCtx.Commit())
+	}
+	workload := func(wfCtx Context, name string) string {
+		cmf, err := wfCtx.GetComponent(name)
+		r.NoError(err)
+		s, err := cmf.Workload.String()
+		r.NoError(err)
+		return s
+	}
+
+	// the legacy layout is read as is
+	wfCtx, err := LoadContext(cli, "default", "app", "workflow-app-context")
+	r.NoError(err)
+	r.Equal(3, len(wfCtx.GetComponents()))
+
+	// the legacy components are moved to the shards once any of them is changed
+	patch(wfCtx, "a")
+	store := &corev1.ConfigMap{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-app-context"}, store))
+	r.Empty(store.Data[ConfigMapKeyComponents])
+	r.JSONEq(`{"a":"workflow-app-context-components-0","b":"workflow-app-context-components-0","c":"workflow-app-context-components-1"}`,
+		store.Data[ConfigMapKeyComponentsIndex])
+	shard0, shard1 := &corev1.ConfigMap{}, &corev1.ConfigMap{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-app-context-components-0"}, shard0))
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-app-context-components-1"}, shard1))
+	r.Equal(2, len(shard0.Data))
+	r.Equal(1, len(shard1.Data))
+
+	// the components are loaded from their shards on the first access, the names are listed without loading them
+	wfCtx, err = LoadContext(cli, "default", "app", "workflow-app-context")
+	r.NoError(err)
+	r.Equal([]string{"a", "b", "c"}, wfCtx.GetComponentNames())
+	r.Empty(wfCtx.(*WorkflowContext).shards)
+	r.Contains(workload(wfCtx, "c"), `name: "c"`)
+	r.Equal(1, len(wfCtx.(*WorkflowContext).shards))
+	r.Contains(workload(wfCtx, "a"), `patched: "true"`)
+
+	// only the shards with changed components are written back
+	patch(wfCtx, "c")
+	updated0, updated1 := &corev1.ConfigMap{}, &corev1.ConfigMap{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-app-context-components-0"}, updated0))
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-app-context-components-1"}, updated1))
+	r.Equal(shard0.ResourceVersion, updated0.ResourceVersion)
+	r.NotEqual(shard1.ResourceVersion, updated1.ResourceVersion)
+
+	wfCtx, err = LoadContext(cli, "default", "app", "workflow-app-context")
+	r.NoError(err)
+	r.Contains(workload(wfCtx, "c"), `patched: "true"`)
+	r.NotContains(workload(wfCtx, "b"), `patched: "true"`)
+
+	// the components of the shards are inlined to make the context self-contained
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-app-context"}, store))
+	data, err := InlineComponents(ctx, cli, store)
+	r.NoError(err)
+	r.NotContains(data, ConfigMapKeyComponentsIndex)
+	inlined := map[string]string{}
+	r.NoError(json.Unmarshal([]byte(data[ConfigMapKeyComponents]), &inlined))
+	r.Equal(3, len(inlined))
+	r.Contains(inlined["c"], "patched")
+
+	// the shards of the reused store are replaced by the new context
+	wfCtx, err = NewContext(cli, "default", "app", nil)
+	r.NoError(err)
+	r.Equal(0, len(wfCtx.GetComponents()))
+	r.Error(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-app-context-components-1"}, &corev1.ConfigMap{}))
+
+	// the stale shard left over is updated instead of failing the creation
+	r.NoError(cli.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "workflow-app-context-components-1", Namespace: "default"},
+		Data:       map[string]string{"stale": "{}"},
+	}))
+	r.NoError(wfCtx.(*WorkflowContext).createShard(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "workflow-app-context-components-1", Namespace: "default"},
+		Data:       map[string]string{"c": "{}"},
+	}))
+	shard1 = &corev1.ConfigMap{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-app-context-components-1"}, shard1))
+	r.Equal(map[string]string{"c": "{}"}, shard1.Data)
+}
@@ -30,18 +30,26 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/kubevela/pkg/util/rand"
 	"github.com/kubevela/workflow/pkg/cue/model"
+	"github.com/kubevela/workflow/pkg/cue/model/sets"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
 )
 
 const (
 	// ConfigMapKeyComponents is the key in ConfigMap Data field for containing data of components
 	ConfigMapKeyComponents = "components"
+	// ConfigMapKeyComponentsIndex is the key in ConfigMap Data field for containing the shards of components
+	ConfigMapKeyComponentsIndex = "components-index"
 	// ConfigMapKeyVars is the key in ConfigMap Data field for containing data of variable
 	ConfigMapKeyVars = "vars"
+	// ConfigMapKeyOverflow is the key in ConfigMap Data field for recording the name of the overflow ConfigMap
+	ConfigMapKeyOverflow = "overflow"
+	// ConfigMapKeyContext is the key in ConfigMap Data field for recording spec.context of the workflow run
+	ConfigMapKeyContext = "context"
 	// AnnotationStartTimestamp is the annotation key of the workflow start  timestamp
 	AnnotationStartTimestamp = "vela.io/startTime"
 )
@@ -55,34 +63,53 @@ type WorkflowContext struct {
 	cli         client.Client
 	store       *corev1.ConfigMap
 	memoryStore *sync.Map
-	components  map[string]*ComponentManifest
 	vars        *value.Value
 	modified    bool
+	// synced is the data of the store at the last time it's read or written
+	synced map[string]string
+	// overflow is the loaded overflow ConfigMap with the values too large to keep in the store
+	overflow      *corev1.ConfigMap
+	overflowDirty bool
+	componentStore
 }
 
 // GetComponent Get ComponentManifest from workflow context.
 func (wf *WorkflowContext) GetComponent(name string) (*ComponentManifest, error) {
-	component, ok := wf.components[name]
+	component, ok, err := wf.loadComponent(name)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
 		return nil, errors.Errorf("component %s not found in application", name)
 	}
 	return component, nil
 }
 
-// GetComponents Get All ComponentManifest from workflow context.
+// GetComponents Get All ComponentManifest from workflow context, the components failed to load are skipped.
 func (wf *WorkflowContext) GetComponents() map[string]*ComponentManifest {
+	for _, name := range wf.componentNames() {
+		if _, _, err := wf.loadComponent(name); err != nil {
+			klog.ErrorS(err, "Failed to load component", "component", name)
+		}
+	}
 	return wf.components
 }
 
+// GetComponentNames returns the sorted names of the components in workflow context without loading them.
+func (wf *WorkflowContext) GetComponentNames() []string {
+	return wf.componentNames()
+}
+
 // PatchComponent patch component with value.
-func (wf *WorkflowContext) PatchComponent(name string, patchValue *value.Value) error {
+func (wf *WorkflowContext) PatchComponent(name string, patchValue *value.Value, options ...sets.UnifyOption) error {
 	component, err := wf.GetComponent(name)
 	if err != nil {
 		return err
 	}
-	if err := component.Patch(patchValue); err != nil {
+	if err := component.Patch(patchValue, options...); err != nil {
 		return err
 	}
+	wf.changed[name] = true
 	wf.modified = true
 	return nil
 }
@@ -183,41 +210,44 @@ func (wf *WorkflowContext) Commit() error {
 	if err := wf.writeToStore(); err != nil {
 		return err
 	}
+	if len(wf.dirtyShards) == 0 && !wf.overflowDirty && reflect.DeepEqual(wf.store.Data, wf.synced) {
+		wf.modified = false
+		return nil
+	}
+	if err := wf.syncShards(); err != nil {
+		return errors.WithMessagef(err, "save context to configMap(%s/%s)", wf.store.Namespace, wf.store.Name)
+	}
+	if err := wf.syncOverflow(); err != nil {
+		return errors.WithMessagef(err, "save context to configMap(%s/%s)", wf.store.Namespace, wf.store.Name)
+	}
 	if err := wf.sync(); err != nil {
 		return errors.WithMessagef(err, "save context to configMap(%s/%s)", wf.store.Namespace, wf.store.Name)
 	}
+	wf.synced = copyData(wf.store.Data)
+	wf.modified = false
 	return nil
 }
 
 func (wf *WorkflowContext) writeToStore() error {
-	varStr, err := wf.vars.String()
+	// the fields are sorted to make the vars serialized stably, otherwise the store is updated
+	// in every reconciliation even if nothing is changed
+	varStr, err := wf.vars.String(sets.OptSortFields)
 	if err != nil {
 		return err
 	}
-	jsonObject := map[string]string{}
-	for name, comp := range wf.components {
-		s, err := comp.string()
-		if err != nil {
-			return errors.WithMessagef(err, "encode component %s ", name)
-		}
-		jsonObject[name] = s
-	}
-
 	if wf.store.Data == nil {
 		wf.store.Data = make(map[string]string)
 	}
-	b, err := json.Marshal(jsonObject)
-	if err != nil {
+	if err := wf.writeComponents(); err != nil {
 		return err
 	}
-	wf.store.Data[ConfigMapKeyComponents] = string(b)
 	wf.store.Data[ConfigMapKeyVars] = varStr
 	return nil
 }
 
 func (wf *WorkflowContext) sync() error {
 	ctx := context.Background()
-	if EnableInMemoryContext {
+	if InMemory(wf.cli) {
 		MemStore.UpdateInMemoryContext(wf.store)
 	} else if err := wf.cli.Update(ctx, wf.store); err != nil {
 		if kerrors.IsNotFound(err) {
@@ -233,21 +263,13 @@ func (wf *WorkflowContext) LoadFromConfigMap(cm corev1.ConfigMap) error {
 	if wf.store == nil {
 		wf.store = &cm
 	}
+	if wf.memoryStore == nil {
+		wf.memoryStore = &sync.Map{}
+	}
 	data := cm.Data
-	componentsJs := map[string]string{}
-
-	if data[ConfigMapKeyComponents] != "" {
-		if err := json.Unmarshal([]byte(data[ConfigMapKeyComponents]), &componentsJs); err != nil {
-			return errors.WithMessage(err, "decode components")
-		}
-		wf.components = map[string]*ComponentManifest{}
-		for name, compJs := range componentsJs {
-			cm := new(ComponentManifest)
-			if err := cm.unmarshal(compJs); err != nil {
-				return errors.WithMessagef(err, "unmarshal component(%s) manifest", name)
-			}
-			wf.components[name] = cm
-		}
+	wf.synced = copyData(data)
+	if err := wf.loadComponentStore(data); err != nil {
+		return err
 	}
 	var err error
 	wf.vars, err = value.NewValue(data[ConfigMapKeyVars], nil, "")
@@ -257,11 +279,23 @@ func (wf *WorkflowContext) LoadFromConfigMap(cm corev1.ConfigMap) error {
 	return nil
 }
 
+func copyData(data map[string]string) map[string]string {
+	if data == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(data))
+	for k, v := range data {
+		copied[k] = v
+	}
+	return copied
+}
+
 // StoreRef return the store reference of workflow context.
 func (wf *WorkflowContext) StoreRef() *corev1.ObjectReference {
+	// the type meta of the typed object is not filled by the client
 	return &corev1.ObjectReference{
-		APIVersion: wf.store.APIVersion,
-		Kind:       wf.store.Kind,
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
 		Name:       wf.store.Name,
 		Namespace:  wf.store.Namespace,
 		UID:        wf.store.UID,
@@ -275,8 +309,8 @@ type ComponentManifest struct {
 }
 
 // Patch the ComponentManifest with value
-func (comp *ComponentManifest) Patch(patchValue *value.Value) error {
-	return comp.Workload.Unify(patchValue.CueValue())
+func (comp *ComponentManifest) Patch(patchValue *value.Value, options ...sets.UnifyOption) error {
+	return comp.Workload.Unify(patchValue.CueValue(), options...)
 }
 
 type componentMould struct {
@@ -351,8 +385,14 @@ func newContext(cli client.Client, ns, name string, owner []metav1.OwnerReferenc
 	store.Name = generateStoreName(name)
 	store.Namespace = ns
 	store.SetOwnerReferences(owner)
-	if EnableInMemoryContext {
+	if InMemory(cli) {
 		MemStore.GetOrCreateInMemoryContext(&store)
+		if err := deleteShards(ctx, cli, &store); err != nil {
+			return nil, err
+		}
+		if err := deleteStaleOverflow(ctx, cli, &store); err != nil {
+			return nil, err
+		}
 	} else if err := cli.Get(ctx, client.ObjectKey{Name: store.Name, Namespace: store.Namespace}, &store); err != nil {
 		if kerrors.IsNotFound(err) {
 			if err := cli.Create(ctx, &store); err != nil {
@@ -361,7 +401,15 @@ func newContext(cli client.Client, ns, name string, owner []metav1.OwnerReferenc
 		} else {
 			return nil, err
 		}
-	} else if !reflect.DeepEqual(store.OwnerReferences, owner) {
+	} else if reflect.DeepEqual(store.OwnerReferences, owner) {
+		// the store is reused by the restarted run, the shards of its former components and the overflow values are stale
+		if err := deleteShards(ctx, cli, &store); err != nil {
+			return nil, err
+		}
+		if err := deleteStaleOverflow(ctx, cli, &store); err != nil {
+			return nil, err
+		}
+	} else {
 		store = corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:            fmt.Sprintf("%s-%s", generateStoreName(name), rand.RandomString(5)),
@@ -378,11 +426,11 @@ func newContext(cli client.Client, ns, name string, owner []metav1.OwnerReferenc
 	}
 	memCache := getMemoryStore(fmt.Sprintf("%s-%s", name, ns))
 	wfCtx := &WorkflowContext{
-		cli:         cli,
-		store:       &store,
-		memoryStore: memCache,
-		components:  map[string]*ComponentManifest{},
-		modified:    true,
+		cli:            cli,
+		store:          &store,
+		memoryStore:    memCache,
+		modified:       true,
+		componentStore: newComponentStore(),
 	}
 	var err error
 	wfCtx.vars, err = value.NewValue("", nil, "")
@@ -409,7 +457,7 @@ func LoadContext(cli client.Client, ns, name, ctxName string) (Context, error) {
 	var store corev1.ConfigMap
 	store.Name = ctxName
 	store.Namespace = ns
-	if EnableInMemoryContext {
+	if InMemory(cli) {
 		MemStore.GetOrCreateInMemoryContext(&store)
 	} else if err := cli.Get(context.Background(), client.ObjectKey{
 		Namespace: ns,
@@ -429,7 +477,63 @@ func LoadContext(cli client.Client, ns, name, ctxName string) (Context, error) {
 	return ctx, nil
 }
 
+// LoadContextFromRef loads workflow context from the store referred by the status of the workflow run, the namespace
+// of the workflow run is used if the reference has none. The store is regarded as not found if its uid is not the
+// recorded one, which is a stale store left by a previous workflow run with the same name.
+func LoadContextFromRef(cli client.Client, ns, name string, ref *corev1.ObjectReference) (Context, error) {
+	if ref.Namespace != "" {
+		ns = ref.Namespace
+	}
+	ctx, err := LoadContext(cli, ns, name, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	if uid := ctx.StoreRef().UID; ref.UID != "" && uid != "" && uid != ref.UID {
+		err := kerrors.NewNotFound(corev1.Resource("configmaps"), ref.Name)
+		err.ErrStatus.Message = fmt.Sprintf("the context backend %s/%s with uid %s is not found, the existing one with uid %s is stale", ns, ref.Name, ref.UID, uid)
+		return nil, err
+	}
+	return ctx, nil
+}
+
 // generateStoreName generates the config map name of workflow context.
 func generateStoreName(name string) string {
 	return fmt.Sprintf("workflow-%s-context", name)
 }
+
+type readOnlyContext struct {
+	ctx Context
+}
+
+// ReadOnly returns the read-only view of the workflow context, the view can not be converted back to the context.
+func ReadOnly(ctx Context) ReadOnlyContext {
+	return &readOnlyContext{ctx: ctx}
+}
+
+func (r *readOnlyContext) GetComponent(name string) (*ComponentManifest, error) {
+	return r.ctx.GetComponent(name)
+}
+
+func (r *readOnlyContext) GetComponents() map[string]*ComponentManifest {
+	return r.ctx.GetComponents()
+}
+
+func (r *readOnlyContext) GetComponentNames() []string {
+	return r.ctx.GetComponentNames()
+}
+
+func (r *readOnlyContext) GetVar(paths ...string) (*value.Value, error) {
+	return r.ctx.GetVar(paths...)
+}
+
+func (r *readOnlyContext) GetMutableValue(paths ...string) string {
+	return r.ctx.GetMutableValue(paths...)
+}
+
+func (r *readOnlyContext) GetValueInMemory(paths ...string) (interface{}, bool) {
+	return r.ctx.GetValueInMemory(paths...)
+}
+
+func (r *readOnlyContext) StoreRef() *corev1.ObjectReference {
+	return r.ctx.StoreRef()
+}
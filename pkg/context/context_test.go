@@ -28,9 +28,11 @@ import (
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	yamlUtil "sigs.k8s.io/yaml"
 
 	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/dryrun"
 )
 
 func TestComponent(t *testing.T) {
@@ -138,9 +140,15 @@ spec: {
 
 	err = wfCtx.LoadFromConfigMap(*wfCtx.store)
 	r.NoError(err)
-	componentsYaml, err := yaml.Marshal(wfCtx.components)
+	// the components are loaded lazily
+	componentsYaml, err := yaml.Marshal(wfCtx.GetComponents())
 	r.NoError(err)
 	r.Equal(string(expected), string(componentsYaml))
+	cmf, err = wfCtx.GetComponent("server")
+	r.NoError(err)
+	patched, err := cmf.Workload.String()
+	r.NoError(err)
+	r.Equal(s, patched)
 }
 
 func TestVars(t *testing.T) {
@@ -254,6 +262,109 @@ func TestContext(t *testing.T) {
 	r.Equal(err.Error(), "component server not found in application")
 }
 
+func TestLoadContextFromRef(t *testing.T) {
+	r := require.New(t)
+	cli := newCliForTest(t, nil)
+	wfCtx, err := NewContext(cli, "default", "app-v1", nil)
+	r.NoError(err)
+	wfCtx.GetStore().UID = "uid-1"
+	r.NoError(wfCtx.Commit())
+
+	ref := wfCtx.StoreRef()
+	r.Equal("ConfigMap", ref.Kind)
+	r.Equal("v1", ref.APIVersion)
+	_, err = LoadContextFromRef(cli, "default", "app-v1", ref)
+	r.NoError(err)
+	_, err = LoadContextFromRef(cli, "default", "app-v1", &corev1.ObjectReference{Name: ref.Name})
+	r.NoError(err)
+
+	_, err = LoadContextFromRef(cli, "default", "app-v1", &corev1.ObjectReference{Name: ref.Name, UID: "uid-0"})
+	r.True(kerrors.IsNotFound(err))
+	r.Contains(err.Error(), "stale")
+}
+
+func TestDryRunContext(t *testing.T) {
+	r := require.New(t)
+	base := fake.NewClientBuilder().Build()
+	cli := dryrun.Client(base)
+	r.True(InMemory(cli))
+	r.False(InMemory(base))
+
+	wfCtx, err := NewContext(cli, "default", "dry-run", nil)
+	r.NoError(err)
+	v, err := value.NewValue(`"bar"`, nil, "")
+	r.NoError(err)
+	r.NoError(wfCtx.SetVar(v, "foo"))
+	r.NoError(wfCtx.Commit())
+
+	cm := &corev1.ConfigMap{}
+	err = base.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "workflow-dry-run-context"}, cm)
+	r.True(kerrors.IsNotFound(err))
+
+	wfCtx, err = LoadContextFromRef(cli, "default", "dry-run", wfCtx.StoreRef())
+	r.NoError(err)
+	foo, err := wfCtx.GetVar("foo")
+	r.NoError(err)
+	s, err := foo.String()
+	r.NoError(err)
+	r.Equal(`"bar"
+`, s)
+
+	MemStore.DeleteInMemoryStore("workflow-dry-run-context", "default")
+	r.Nil(MemStore.GetInMemoryContext("workflow-dry-run-context", "default"))
+}
+
+func TestCommitUnchanged(t *testing.T) {
+	r := require.New(t)
+	cli := newCliForTest(t, nil)
+	var writes int
+	create, update := cli.MockCreate, cli.MockUpdate
+	cli.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+		writes++
+		return create(ctx, obj, opts...)
+	}
+	cli.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+		writes++
+		return update(ctx, obj, opts...)
+	}
+	setVars := func(wfCtx Context, names ...string) {
+		for _, name := range names {
+			v, err := value.NewValue(`{name: "`+name+`", port: 80}`, nil, "")
+			r.NoError(err)
+			r.NoError(wfCtx.SetVar(v, name))
+		}
+	}
+
+	wfCtx, err := NewContext(cli, "default", "app-v1", nil)
+	r.NoError(err)
+	setVars(wfCtx, "web", "db")
+	r.NoError(wfCtx.Commit())
+	vars := wfCtx.GetStore().Data[ConfigMapKeyVars]
+	r.Equal(`db: {
+	name: "db"
+	port: 80
+}
+web: {
+	name: "web"
+	port: 80
+}
+`, vars)
+
+	writes = 0
+	for i := 0; i < 10; i++ {
+		wfCtx, err = LoadContext(cli, "default", "app-v1", "workflow-app-v1-context")
+		r.NoError(err)
+		setVars(wfCtx, "db", "web")
+		r.NoError(wfCtx.Commit())
+	}
+	r.Equal(0, writes)
+	r.Equal(vars, wfCtx.GetStore().Data[ConfigMapKeyVars])
+
+	setVars(wfCtx, "cache")
+	r.NoError(wfCtx.Commit())
+	r.Equal(1, writes)
+}
+
 func TestGetStore(t *testing.T) {
 	cli := newCliForTest(t, nil)
 	r := require.New(t)
@@ -19,6 +19,7 @@ package context
 import (
 	corev1 "k8s.io/api/core/v1"
 
+	"github.com/kubevela/workflow/pkg/cue/model/sets"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
 )
 
@@ -26,13 +27,17 @@ import (
 type Context interface {
 	GetComponent(name string) (*ComponentManifest, error)
 	GetComponents() map[string]*ComponentManifest
-	PatchComponent(name string, patchValue *value.Value) error
+	GetComponentNames() []string
+	PatchComponent(name string, patchValue *value.Value, options ...sets.UnifyOption) error
 	GetVar(paths ...string) (*value.Value, error)
 	SetVar(v *value.Value, paths ...string) error
 	GetStore() *corev1.ConfigMap
 	GetMutableValue(path ...string) string
 	SetMutableValue(data string, path ...string)
 	DeleteMutableValue(paths ...string)
+	SetOverflowValue(data string, key string) (*OverflowRef, error)
+	GetOverflowValue(ref OverflowRef) (string, error)
+	PruneOverflow(keep func(ref OverflowRef) bool) error
 	IncreaseCountValueInMemory(paths ...string) int
 	SetValueInMemory(data interface{}, paths ...string)
 	GetValueInMemory(paths ...string) (interface{}, bool)
@@ -41,3 +46,14 @@ type Context interface {
 	MakeParameter(parameter string) (*value.Value, error)
 	StoreRef() *corev1.ObjectReference
 }
+
+// ReadOnlyContext is the read-only view of the workflow context
+type ReadOnlyContext interface {
+	GetComponent(name string) (*ComponentManifest, error)
+	GetComponents() map[string]*ComponentManifest
+	GetComponentNames() []string
+	GetVar(paths ...string) (*value.Value, error)
+	GetMutableValue(path ...string) string
+	GetValueInMemory(paths ...string) (interface{}, bool)
+	StoreRef() *corev1.ObjectReference
+}
@@ -0,0 +1,273 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+// OverflowKey is the field of the var referring to the value stored in the overflow ConfigMap
+const OverflowKey = "overflow__"
+
+// MaxOverflowSize is the max size in bytes of the data in the overflow ConfigMap, the values beyond it are refused
+// since the size of a ConfigMap is limited to 1MiB, 0 means no limit
+var MaxOverflowSize = 900 * 1024
+
+// OverflowRef refers to the value stored in the overflow ConfigMap of the workflow context
+type OverflowRef struct {
+	ConfigMapRef string `json:"configMapRef"`
+	Key          string `json:"key"`
+	Size         int    `json:"size"`
+}
+
+func (wf *WorkflowContext) overflowName() string {
+	return overflowName(wf.store.Name)
+}
+
+func overflowName(store string) string {
+	return fmt.Sprintf("%s-outputs", store)
+}
+
+// loadOverflow gets the overflow ConfigMap from the cache or the store, it's created on commit if not found.
+func (wf *WorkflowContext) loadOverflow(name string) (*corev1.ConfigMap, error) {
+	if wf.overflow != nil && wf.overflow.Name == name {
+		return wf.overflow, nil
+	}
+	cm := &corev1.ConfigMap{}
+	cm.Name = name
+	cm.Namespace = wf.store.Namespace
+	if InMemory(wf.cli) {
+		if stored := MemStore.GetInMemoryContext(name, wf.store.Namespace); stored != nil {
+			stored.DeepCopyInto(cm)
+		}
+	} else if err := wf.cli.Get(context.Background(), client.ObjectKey{Namespace: cm.Namespace, Name: name}, cm); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return nil, errors.WithMessagef(err, "load overflow configMap %s", name)
+		}
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.SetOwnerReferences(wf.store.OwnerReferences)
+	if name == wf.overflowName() {
+		wf.overflow = cm
+	}
+	return cm, nil
+}
+
+// SetOverflowValue stores the data in the overflow ConfigMap of the workflow context, and returns the reference to it.
+func (wf *WorkflowContext) SetOverflowValue(data string, key string) (*OverflowRef, error) {
+	cm, err := wf.loadOverflow(wf.overflowName())
+	if err != nil {
+		return nil, err
+	}
+	if stored, ok := cm.Data[key]; !ok || stored != data {
+		size := overflowSize(cm.Data) + len(data) - len(stored)
+		if !ok {
+			size += len(key)
+		}
+		if MaxOverflowSize > 0 && size > MaxOverflowSize {
+			return nil, errors.Errorf("the overflow configMap %s exceeds the max size %d bytes", cm.Name, MaxOverflowSize)
+		}
+		cm.Data[key] = data
+		wf.store.Data[ConfigMapKeyOverflow] = cm.Name
+		wf.overflowDirty = true
+		wf.modified = true
+	}
+	return &OverflowRef{ConfigMapRef: cm.Name, Key: key, Size: len(data)}, nil
+}
+
+// GetOverflowValue gets the data referred by the reference from the overflow ConfigMap.
+func (wf *WorkflowContext) GetOverflowValue(ref OverflowRef) (string, error) {
+	cm, err := wf.loadOverflow(ref.ConfigMapRef)
+	if err != nil {
+		return "", err
+	}
+	data, ok := cm.Data[ref.Key]
+	if !ok {
+		return "", errors.Errorf("key %s not found in overflow configMap %s", ref.Key, ref.ConfigMapRef)
+	}
+	return data, nil
+}
+
+// PruneOverflow deletes the values in the overflow ConfigMap which are neither referenced by the vars of the context
+// nor kept by the caller, e.g. the outputs replaced by the steps executed again. The ConfigMap is deleted if it's empty.
+func (wf *WorkflowContext) PruneOverflow(keep func(ref OverflowRef) bool) error {
+	cm, err := wf.loadOverflow(wf.overflowName())
+	if err != nil {
+		return err
+	}
+	referenced := overflowKeys(wf.vars.CueValue())
+	for key, data := range cm.Data {
+		if referenced[key] || (keep != nil && keep(OverflowRef{ConfigMapRef: cm.Name, Key: key, Size: len(data)})) {
+			continue
+		}
+		delete(cm.Data, key)
+		wf.overflowDirty = true
+	}
+	if len(cm.Data) > 0 {
+		return wf.syncOverflow()
+	}
+	wf.overflow, wf.overflowDirty = nil, false
+	if err := deleteOverflow(context.Background(), wf.cli, cm); err != nil {
+		return err
+	}
+	if _, ok := wf.store.Data[ConfigMapKeyOverflow]; ok {
+		delete(wf.store.Data, ConfigMapKeyOverflow)
+		wf.modified = true
+	}
+	return wf.Commit()
+}
+
+// deleteOverflow deletes the overflow ConfigMap
+func deleteOverflow(ctx context.Context, cli client.Client, cm *corev1.ConfigMap) error {
+	if InMemory(cli) {
+		MemStore.deleteInMemoryContext(cm.Name, cm.Namespace)
+		return nil
+	}
+	if err := cli.Delete(ctx, cm); err != nil && !kerrors.IsNotFound(err) {
+		return errors.WithMessagef(err, "delete overflow configMap %s", cm.Name)
+	}
+	return nil
+}
+
+// deleteStaleOverflow deletes the overflow ConfigMap recorded in the store, it's called when the store is reused by a
+// new context so that the stale values are not mixed into the new ones.
+func deleteStaleOverflow(ctx context.Context, cli client.Client, store *corev1.ConfigMap) error {
+	name := store.Data[ConfigMapKeyOverflow]
+	if name == "" {
+		return nil
+	}
+	cm := &corev1.ConfigMap{}
+	cm.Name = name
+	cm.Namespace = store.Namespace
+	if err := deleteOverflow(ctx, cli, cm); err != nil {
+		return err
+	}
+	delete(store.Data, ConfigMapKeyOverflow)
+	return nil
+}
+
+// OverflowData returns the data of the overflow ConfigMap recorded in the store, it's nil if there's none.
+func OverflowData(ctx context.Context, cli client.Reader, store *corev1.ConfigMap) (map[string]string, error) {
+	name := store.Data[ConfigMapKeyOverflow]
+	if name == "" {
+		return nil, nil
+	}
+	cm := &corev1.ConfigMap{}
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: store.Namespace, Name: name}, cm); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.WithMessagef(err, "load overflow configMap %s", name)
+	}
+	return cm.Data, nil
+}
+
+func overflowSize(data map[string]string) int {
+	size := 0
+	for k, v := range data {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+// overflowKeys returns the keys of the overflow values referenced in the value
+func overflowKeys(v cue.Value) map[string]bool {
+	keys := map[string]bool{}
+	v.Walk(func(v cue.Value) bool {
+		r := v.LookupPath(value.FieldPath(OverflowKey))
+		if !r.Exists() {
+			return true
+		}
+		ref := &OverflowRef{}
+		if err := r.Decode(ref); err == nil && ref.ConfigMapRef != "" {
+			keys[ref.Key] = true
+		}
+		return false
+	}, nil)
+	return keys
+}
+
+// syncOverflow writes the overflow ConfigMap back to the store
+func (wf *WorkflowContext) syncOverflow() error {
+	if !wf.overflowDirty {
+		return nil
+	}
+	cm := wf.overflow
+	if InMemory(wf.cli) {
+		MemStore.UpdateInMemoryContext(cm)
+	} else if cm.ResourceVersion == "" {
+		if err := wf.cli.Create(context.Background(), cm); err != nil {
+			return errors.WithMessagef(err, "create overflow configMap %s", cm.Name)
+		}
+	} else if err := wf.cli.Update(context.Background(), cm); err != nil {
+		return errors.WithMessagef(err, "update overflow configMap %s", cm.Name)
+	}
+	wf.overflowDirty = false
+	return nil
+}
+
+// LookupVar gets the variable from the workflow context like GetVar, the values moved to the overflow ConfigMap
+// are read back from it, so that the references are transparent to the consumers.
+func LookupVar(ctx Context, paths ...string) (*value.Value, error) {
+	for i := 1; i <= len(paths); i++ {
+		v, err := ctx.GetVar(paths[:i]...)
+		if err != nil {
+			break
+		}
+		ref, ok := overflowRef(v)
+		if !ok {
+			continue
+		}
+		data, err := ctx.GetOverflowValue(*ref)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "get the overflow value of %s", strings.Join(paths[:i], "."))
+		}
+		full, err := v.MakeValue(data)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "decode the overflow value of %s", strings.Join(paths[:i], "."))
+		}
+		if i == len(paths) {
+			return full, nil
+		}
+		return full.LookupValue(paths[i:]...)
+	}
+	return ctx.GetVar(paths...)
+}
+
+func overflowRef(v *value.Value) (*OverflowRef, bool) {
+	r, err := v.LookupValue(OverflowKey)
+	if err != nil {
+		return nil, false
+	}
+	ref := &OverflowRef{}
+	if err := r.UnmarshalTo(ref); err != nil || ref.ConfigMapRef == "" {
+		return nil, false
+	}
+	return ref, true
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+func TestOverflowValue(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().Build()
+	owner := []metav1.OwnerReference{{APIVersion: "core.oam.dev/v1alpha1", Kind: "WorkflowRun", Name: "app", UID: "uid"}}
+	wfCtx, err := NewContext(cli, "default", "app", owner)
+	r.NoError(err)
+
+	ref, err := wfCtx.SetOverflowValue(`{manifest: {kind: "Pod"}, replicas: 3}`, "render")
+	r.NoError(err)
+	r.Equal(&OverflowRef{ConfigMapRef: "workflow-app-context-outputs", Key: "render", Size: 38}, ref)
+	b, err := json.Marshal(map[string]interface{}{OverflowKey: ref})
+	r.NoError(err)
+	v, err := value.NewValue(string(b), nil, "")
+	r.NoError(err)
+	r.NoError(wfCtx.SetVar(v, "render"))
+	r.NoError(wfCtx.Commit())
+
+	overflow := &corev1.ConfigMap{}
+	r.NoError(cli.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "workflow-app-context-outputs"}, overflow))
+	r.Equal(owner, overflow.OwnerReferences)
+	r.Equal(`{manifest: {kind: "Pod"}, replicas: 3}`, overflow.Data["render"])
+
+	// the reference is dereferenced after the context is reloaded
+	wfCtx, err = LoadContext(cli, "default", "app", "workflow-app-context")
+	r.NoError(err)
+	v, err = LookupVar(wfCtx, "render", "manifest", "kind")
+	r.NoError(err)
+	kind, err := v.CueValue().String()
+	r.NoError(err)
+	r.Equal("Pod", kind)
+	v, err = LookupVar(wfCtx, "render")
+	r.NoError(err)
+	replicas, err := v.GetInt64("replicas")
+	r.NoError(err)
+	r.Equal(int64(3), replicas)
+	_, err = LookupVar(wfCtx, "render", "notExist")
+	r.Error(err)
+
+	// the missing overflow value is reported
+	overflow.Data = nil
+	r.NoError(cli.Update(context.Background(), overflow))
+	wfCtx, err = LoadContext(cli, "default", "app", "workflow-app-context")
+	r.NoError(err)
+	_, err = LookupVar(wfCtx, "render")
+	r.Error(err)
+	r.Contains(err.Error(), "key render not found in overflow configMap workflow-app-context-outputs")
+}
+
+func TestOverflowSizeLimit(t *testing.T) {
+	defer func(size int) { MaxOverflowSize = size }(MaxOverflowSize)
+	MaxOverflowSize = 16
+	r := require.New(t)
+	wfCtx, err := NewContext(fake.NewClientBuilder().Build(), "default", "app", nil)
+	r.NoError(err)
+	_, err = wfCtx.SetOverflowValue("0123456789", "a")
+	r.NoError(err)
+	_, err = wfCtx.SetOverflowValue("0123456789", "b")
+	r.Error(err)
+	r.Contains(err.Error(), "exceeds the max size 16 bytes")
+	// the value replaced is not counted
+	_, err = wfCtx.SetOverflowValue("01234567890123", "a")
+	r.NoError(err)
+}
+
+func TestPruneOverflow(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().Build()
+	wfCtx, err := NewContext(cli, "default", "app", nil)
+	r.NoError(err)
+	for _, key := range []string{"render", "replaced", "message-apply"} {
+		ref, err := wfCtx.SetOverflowValue(`{replicas: 3}`, key)
+		r.NoError(err)
+		if key == "render" {
+			b, err := json.Marshal(map[string]interface{}{OverflowKey: ref})
+			r.NoError(err)
+			v, err := value.NewValue(string(b), nil, "")
+			r.NoError(err)
+			r.NoError(wfCtx.SetVar(v, "render"))
+		}
+	}
+	r.NoError(wfCtx.Commit())
+	r.Equal("workflow-app-context-outputs", wfCtx.GetStore().Data[ConfigMapKeyOverflow])
+
+	// the values referenced by the vars or kept by the caller are left
+	wfCtx, err = LoadContext(cli, "default", "app", "workflow-app-context")
+	r.NoError(err)
+	r.NoError(wfCtx.PruneOverflow(func(ref OverflowRef) bool { return ref.Key == "message-apply" }))
+	overflow := &corev1.ConfigMap{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-app-context-outputs"}, overflow))
+	r.Contains(overflow.Data, "render")
+	r.Contains(overflow.Data, "message-apply")
+	r.NotContains(overflow.Data, "replaced")
+
+	// the ConfigMap is deleted if nothing is left
+	wfCtx, err = NewContext(cli, "default", "unreferenced", nil)
+	r.NoError(err)
+	_, err = wfCtx.SetOverflowValue(`{replicas: 3}`, "render")
+	r.NoError(err)
+	r.NoError(wfCtx.Commit())
+	r.NoError(wfCtx.PruneOverflow(nil))
+	r.True(kerrors.IsNotFound(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-unreferenced-context-outputs"}, overflow)))
+	store := &corev1.ConfigMap{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-unreferenced-context"}, store))
+	r.NotContains(store.Data, ConfigMapKeyOverflow)
+}
+
+func TestStaleOverflowDeleted(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().Build()
+	wfCtx, err := NewContext(cli, "default", "app", nil)
+	r.NoError(err)
+	_, err = wfCtx.SetOverflowValue(`{replicas: 3}`, "render")
+	r.NoError(err)
+	r.NoError(wfCtx.Commit())
+
+	// the store reused by the restarted run drops the overflow values of the former run
+	wfCtx, err = NewContext(cli, "default", "app", nil)
+	r.NoError(err)
+	r.NotContains(wfCtx.GetStore().Data, ConfigMapKeyOverflow)
+	overflow := &corev1.ConfigMap{}
+	r.True(kerrors.IsNotFound(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-app-context-outputs"}, overflow)))
+}
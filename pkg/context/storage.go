@@ -18,9 +18,13 @@ package context
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/pkg/dryrun"
 )
 
 var (
@@ -28,6 +32,12 @@ var (
 	EnableInMemoryContext = false
 )
 
+// InMemory returns whether the workflow context accessed by the client is stored in memory, which is the case if
+// the in-memory context is enabled or the writes of the client are dry-run
+func InMemory(cli client.Client) bool {
+	return EnableInMemoryContext || dryrun.IsDryRun(cli)
+}
+
 type inMemoryContextStorage struct {
 	mu       sync.Mutex
 	contexts map[string]*v1.ConfigMap
@@ -78,3 +88,21 @@ func (o *inMemoryContextStorage) DeleteInMemoryContext(appName string) {
 	key := fmt.Sprintf("workflow-%s-context", appName)
 	delete(o.contexts, key)
 }
+
+func (o *inMemoryContextStorage) deleteInMemoryContext(name, ns string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.contexts, ns+"/"+name)
+}
+
+// DeleteInMemoryStore deletes the in-memory store of the workflow context with its components shards and overflow
+func (o *inMemoryContextStorage) DeleteInMemoryStore(name, ns string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	key := ns + "/" + name
+	for k := range o.contexts {
+		if k == key || k == key+"-outputs" || strings.HasPrefix(k, key+"-components-") {
+			delete(o.contexts, k)
+		}
+	}
+}
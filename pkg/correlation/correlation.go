@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package correlation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+// BaggageHeader is the W3C baggage header to propagate the correlation ids in the http requests
+const BaggageHeader = "Baggage"
+
+// keys is the annotation keys of the workflow run to propagate
+var keys []string
+
+// SetKeys sets the annotation keys of the workflow run to propagate, the keys must be valid label keys
+func SetKeys(annotationKeys []string) error {
+	var result []string
+	for _, key := range annotationKeys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return errors.Errorf("invalid correlation key %s: %s", key, strings.Join(errs, "; "))
+		}
+		result = append(result, key)
+	}
+	keys = result
+	return nil
+}
+
+// FromAnnotations captures the correlation ids from the annotations of the workflow run.
+// The values that are not safe to be used as label values or header values are dropped.
+func FromAnnotations(annotations map[string]string) map[string]string {
+	if len(keys) == 0 || len(annotations) == 0 {
+		return nil
+	}
+	result := make(map[string]string)
+	for _, key := range keys {
+		v, ok := annotations[key]
+		if !ok {
+			continue
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			klog.Warningf("drop the invalid correlation value of %s: %s", key, strings.Join(errs, "; "))
+			continue
+		}
+		result[key] = v
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// Baggage encodes the correlation ids into the value of the W3C baggage header.
+// The `/` in the keys is replaced with `.` since it's not allowed in the baggage keys.
+func Baggage(correlation map[string]string) string {
+	members := make([]string, 0, len(correlation))
+	for k, v := range correlation {
+		members = append(members, fmt.Sprintf("%s=%s", strings.ReplaceAll(k, "/", "."), v))
+	}
+	sort.Strings(members)
+	return strings.Join(members, ",")
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package correlation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromAnnotations(t *testing.T) {
+	r := require.New(t)
+	defer func() { keys = nil }()
+
+	r.Error(SetKeys([]string{"invalid key"}))
+	r.Nil(FromAnnotations(map[string]string{"invalid key": "value"}))
+
+	r.NoError(SetKeys([]string{"example.com/ticket", " team ", "", "tenant"}))
+	r.Equal([]string{"example.com/ticket", "team", "tenant"}, keys)
+	r.Equal(map[string]string{
+		"example.com/ticket": "T-1",
+		"team":               "infra",
+	}, FromAnnotations(map[string]string{
+		"example.com/ticket": "T-1",
+		"team":               "infra",
+		"tenant":             "invalid value with spaces",
+		"other":              "ignored",
+	}))
+	r.Nil(FromAnnotations(map[string]string{"other": "ignored"}))
+	r.Nil(FromAnnotations(nil))
+}
+
+func TestBaggage(t *testing.T) {
+	r := require.New(t)
+	r.Equal("", Baggage(nil))
+	r.Equal("example.com.ticket=T-1,team=infra", Baggage(map[string]string{
+		"team":               "infra",
+		"example.com/ticket": "T-1",
+	}))
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cue
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	cueerrors "cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+const truncatedMarker = "...(truncated)"
+
+// MaxEvaluationErrorMessageLength is the max length of the message of the evaluation error shown in the step status
+var MaxEvaluationErrorMessageLength = 1024
+
+// EvaluationIssue is a single failure found in the evaluation of the CUE template
+type EvaluationIssue struct {
+	// Path is the path of the failing field, e.g. parameter.replicas
+	Path string
+	// Message is the message of the CUE error
+	Message string
+	// Positions are the positions of the source causing the failure, in the format of line:column
+	Positions []string
+	// ConflictingValues are the values conflicting with each other if the failure is a conflict
+	ConflictingValues []string
+}
+
+// EvaluationError is the error of evaluating the CUE template of a definition
+type EvaluationError struct {
+	Definition string
+	Issues     []EvaluationIssue
+	err        error
+}
+
+// NewEvaluationError converts the CUE errors in the error into the evaluation error of the definition.
+// It returns nil if there is no CUE error in the error.
+func NewEvaluationError(definition string, err error) *EvaluationError {
+	var cueErr cueerrors.Error
+	if !errors.As(err, &cueErr) {
+		return nil
+	}
+	errs := cueerrors.Errors(cueErr)
+	evalErr := &EvaluationError{Definition: definition, err: err}
+	seen := map[string]bool{}
+	for _, e := range errs {
+		issue := EvaluationIssue{Path: strings.Join(e.Path(), ".")}
+		format, args := e.Msg()
+		issue.Message = fmt.Sprintf(format, args...)
+		if strings.HasPrefix(format, "conflicting values") && len(args) >= 2 {
+			issue.ConflictingValues = []string{fmt.Sprint(args[0]), fmt.Sprint(args[1])}
+		}
+		for _, pos := range positions(e) {
+			issue.Positions = append(issue.Positions, fmt.Sprintf("%d:%d", pos.Line(), pos.Column()))
+		}
+		key := issue.Path + ": " + issue.Message + " " + strings.Join(issue.Positions, ",")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		evalErr.Issues = append(evalErr.Issues, issue)
+	}
+	return evalErr
+}
+
+func positions(e cueerrors.Error) []token.Pos {
+	var result []token.Pos
+	for _, pos := range append([]token.Pos{e.Position()}, e.InputPositions()...) {
+		if !pos.IsValid() {
+			continue
+		}
+		duplicated := false
+		for _, p := range result {
+			if p.Line() == pos.Line() && p.Column() == pos.Column() {
+				duplicated = true
+				break
+			}
+		}
+		if !duplicated {
+			result = append(result, pos)
+		}
+	}
+	return result
+}
+
+// Error returns the full message of the error, each issue is in its own line
+func (e *EvaluationError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("failed to evaluate definition %s:", e.Definition))
+	for _, issue := range e.Issues {
+		sb.WriteString("\n- ")
+		if issue.Path != "" {
+			sb.WriteString(issue.Path + ": ")
+		}
+		sb.WriteString(issue.Message)
+		if len(issue.Positions) > 0 {
+			sb.WriteString(" (at " + strings.Join(issue.Positions, ", ") + ")")
+		}
+	}
+	return sb.String()
+}
+
+// Unwrap returns the original error
+func (e *EvaluationError) Unwrap() error {
+	return e.err
+}
+
+// Message returns the message of the error truncated to MaxEvaluationErrorMessageLength at the rune boundary
+func (e *EvaluationError) Message() string {
+	msg := e.Error()
+	if len(msg) <= MaxEvaluationErrorMessageLength {
+		return msg
+	}
+	size := MaxEvaluationErrorMessageLength
+	for size > 0 && !utf8.RuneStart(msg[size]) {
+		size--
+	}
+	return msg[:size] + truncatedMarker
+}
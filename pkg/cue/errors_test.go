@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluationErrorMessage(t *testing.T) {
+	defer func(n int) { MaxEvaluationErrorMessageLength = n }(MaxEvaluationErrorMessageLength)
+	err := &EvaluationError{
+		Definition: "部署",
+		Issues:     []EvaluationIssue{{Path: "parameter.名称", Message: "incomplete value string"}},
+	}
+	testCases := map[string]struct {
+		size     int
+		expected string
+	}{
+		"not truncated": {
+			size:     1024,
+			expected: "failed to evaluate definition 部署:\n- parameter.名称: incomplete value string",
+		},
+		"rune boundary": {
+			// the size ends in the middle of the second rune of the definition
+			size:     34,
+			expected: "failed to evaluate definition 部" + truncatedMarker,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			MaxEvaluationErrorMessageLength = tc.size
+			require.Equal(t, tc.expected, err.Message())
+		})
+	}
+}
@@ -39,6 +39,20 @@ const (
 	ContextStepName = "stepName"
 	// ContextSpanID is name for span id.
 	ContextSpanID = "spanID"
+	// ContextCorrelation is the correlation ids captured from the annotations of the workflow run
+	ContextCorrelation = "correlation"
+	// ContextCustom is the custom data in spec.context of the workflow run
+	ContextCustom = "custom"
+	// ContextLabels is the labels of the workflow run
+	ContextLabels = "labels"
+	// ContextAnnotations is the annotations of the workflow run
+	ContextAnnotations = "annotations"
+	// ContextStartTime is the start time of the workflow run in RFC3339 format
+	ContextStartTime = "startTime"
+	// ContextRunUID is the uid of the workflow run
+	ContextRunUID = "runUID"
+	// ContextVar is the values of spec.var of the workflow run
+	ContextVar = "var"
 	// OutputSecretName is used to store all secret names which are generated by cloud resource components
 	OutputSecretName = "outputSecretName"
 )
@@ -51,6 +51,9 @@ var (
 // UnifyParams params for unify
 type UnifyParams struct {
 	PatchStrategy string
+	// Warnings collects the warnings of the strategic merge patch if it's set,
+	// e.g. the list items that can not be merged by the patch key
+	Warnings *[]string
 }
 
 // UnifyOption defines the option for unify
@@ -74,6 +77,16 @@ func (op UnifyByJSONMergePatch) ApplyToOption(params *UnifyParams) {
 	params.PatchStrategy = StrategyJSONMergePatch
 }
 
+// UnifyWithWarnings collects the warnings of the strategic merge patch into Warnings
+type UnifyWithWarnings struct {
+	Warnings *[]string
+}
+
+// ApplyToOption apply to option
+func (op UnifyWithWarnings) ApplyToOption(params *UnifyParams) {
+	params.Warnings = op.Warnings
+}
+
 func newUnifyParams(options ...UnifyOption) *UnifyParams {
 	params := &UnifyParams{}
 	for _, op := range options {
@@ -94,64 +107,77 @@ func CreateUnifyOptionsForPatcher(patcher cue.Value) (options []UnifyOption) {
 
 type interceptor func(baseNode ast.Node, patchNode ast.Node) error
 
-func listMergeProcess(field *ast.Field, key string, baseList, patchList *ast.ListLit) {
-	kmaps := map[string]ast.Expr{}
-	nElts := []ast.Expr{}
+// listMergeProcess merges the items of the lists by the patch key, the comma-separated keys are treated as
+// a tuple identifying the item. The patch items missing some of the keys are appended without being merged.
+func listMergeProcess(field *ast.Field, key string, baseList, patchList *ast.ListLit, params *UnifyParams) {
 	keys := strings.Split(key, ",")
-	for _, key := range keys {
-		foundPatch := false
-		for i, elt := range patchList.Elts {
-			if _, ok := elt.(*ast.Ellipsis); ok {
-				continue
-			}
-			nodev, err := lookUp(elt, strings.Split(key, ".")...)
+	// identity returns the values of the keys of the item, and the number of the keys found in it
+	identity := func(elt ast.Expr) (string, int) {
+		var values []string
+		for _, k := range keys {
+			nodev, err := lookUp(elt, strings.Split(k, ".")...)
 			if err != nil {
 				continue
 			}
-			foundPatch = true
-			blit, ok := nodev.(*ast.BasicLit)
-			if !ok {
-				return
-			}
-			kmaps[fmt.Sprintf(key, blit.Value)] = patchList.Elts[i]
-		}
-		if !foundPatch {
-			if len(patchList.Elts) == 0 {
-				continue
+			if blit, ok := nodev.(*ast.BasicLit); ok {
+				values = append(values, blit.Value)
 			}
-			return
 		}
+		return strings.Join(values, ","), len(values)
+	}
 
-		hasStrategyRetainKeys := isStrategyRetainKeys(field)
-
-		for i, elt := range baseList.Elts {
-			if _, ok := elt.(*ast.Ellipsis); ok {
-				continue
-			}
-
-			nodev, err := lookUp(elt, strings.Split(key, ".")...)
-			if err != nil {
-				continue
-			}
-			blit, ok := nodev.(*ast.BasicLit)
-			if !ok {
-				return
-			}
+	kmaps := map[string]ast.Expr{}
+	appended := map[ast.Expr]bool{}
+	var warnings []string
+	foundPatch := false
+	for i, elt := range patchList.Elts {
+		if _, ok := elt.(*ast.Ellipsis); ok {
+			continue
+		}
+		id, n := identity(elt)
+		if n > 0 {
+			foundPatch = true
+		}
+		if n == len(keys) {
+			kmaps[id] = elt
+			continue
+		}
+		appended[elt] = true
+		warnings = append(warnings, fmt.Sprintf("item %d of list %s misses the patch key %s, it's appended without being merged", i, labelStr(field.Label), key))
+	}
+	// the lists are unified as is if none of the patch items has the keys
+	if !foundPatch {
+		return
+	}
+	if params.Warnings != nil {
+		*params.Warnings = append(*params.Warnings, warnings...)
+	}
 
-			k := fmt.Sprintf(key, blit.Value)
-			if v, ok := kmaps[k]; ok {
-				if hasStrategyRetainKeys {
-					baseList.Elts[i] = ast.NewStruct()
-				}
-				nElts = append(nElts, v)
-				delete(kmaps, k)
-			} else {
-				nElts = append(nElts, ast.NewStruct())
+	hasStrategyRetainKeys := isStrategyRetainKeys(field)
+	nElts := []ast.Expr{}
+	for i, elt := range baseList.Elts {
+		if _, ok := elt.(*ast.Ellipsis); ok {
+			continue
+		}
+		id, n := identity(elt)
+		if n == 0 {
+			continue
+		}
+		if v, ok := kmaps[id]; ok && n == len(keys) {
+			if hasStrategyRetainKeys {
+				baseList.Elts[i] = ast.NewStruct()
 			}
-
+			nElts = append(nElts, v)
+			delete(kmaps, id)
+		} else {
+			nElts = append(nElts, ast.NewStruct())
 		}
 	}
 	for _, elt := range patchList.Elts {
+		if appended[elt] {
+			nElts = append(nElts, elt)
+			continue
+		}
 		for _, v := range kmaps {
 			if elt == v {
 				nElts = append(nElts, v)
@@ -164,7 +190,7 @@ func listMergeProcess(field *ast.Field, key string, baseList, patchList *ast.Lis
 	patchList.Elts = nElts
 }
 
-func strategyPatchHandle() interceptor {
+func strategyPatchHandle(params *UnifyParams) interceptor {
 	return func(baseNode ast.Node, patchNode ast.Node) error {
 		walker := newWalker(func(node ast.Node, ctx walkCtx) {
 			field, ok := node.(*ast.Field)
@@ -200,14 +226,17 @@ func strategyPatchHandle() interceptor {
 				if !ok {
 					return
 				}
-				if patchStrategy == StrategyReplace {
+				// the list without the patch key is replaced entirely by retainKeys,
+				// as there is no way to tell which items to retain
+				if patchStrategy == StrategyReplace || (patchStrategy == StrategyRetainKeys && key == "") {
 					baselist.Elts = val.Elts
 				} else if key != "" {
-					listMergeProcess(field, key, baselist, val)
+					listMergeProcess(field, key, baselist, val, params)
 				}
 
 			default:
-				if !isStrategyRetainKeys(field) {
+				// both the retainKeys and the replace strategy drop the keys absent from the patch of the struct
+				if !isStrategyRetainKeys(field) && !isStrategyReplace(field) {
 					return
 				}
 
@@ -247,6 +276,10 @@ func isStrategyRetainKeys(node *ast.Field) bool {
 	return false
 }
 
+func isStrategyReplace(node *ast.Field) bool {
+	return findCommentTag(node.Comments())[TagPatchStrategy] == StrategyReplace
+}
+
 // IsJSONMergePatch check if patcher is json merge patch
 func IsJSONMergePatch(patcher cue.Value) bool {
 	tags := findCommentTag(patcher.Doc())
@@ -269,7 +302,7 @@ func StrategyUnify(base, patch cue.Value, options ...UnifyOption) (ret cue.Value
 			return base, err
 		}
 	} else {
-		patchOpts = []interceptor{strategyPatchHandle()}
+		patchOpts = []interceptor{strategyPatchHandle(params)}
 	}
 	return strategyUnify(base, patch, params, patchOpts...)
 }
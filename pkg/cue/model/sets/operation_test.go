@@ -141,6 +141,8 @@ containers: [{
 	name: "x1"
 }, {
 	name: "x2"
+}, {
+	noname: "x3"
 }, ...]
 `,
 		},
@@ -425,6 +427,56 @@ containers: [{
 	}
 }
 
+func TestPatchWithMultipleKeys(t *testing.T) {
+	r := require.New(t)
+	ctx := cuecontext.New()
+	base := ctx.CompileString(`
+ports: [{
+	containerPort: 53
+	protocol: "TCP"
+	name: "dns-tcp"
+}, {
+	containerPort: 53
+	protocol: "UDP"
+}]
+`)
+	patch := ctx.CompileString(`
+// +patchKey=containerPort,protocol
+ports: [{
+	containerPort: 53
+	protocol: "UDP"
+	name: "dns-udp"
+}, {
+	containerPort: 8080
+	protocol: "TCP"
+}, {
+	containerPort: 9090
+}]
+`)
+	var warnings []string
+	v, err := StrategyUnify(base, patch, UnifyWithWarnings{Warnings: &warnings})
+	r.NoError(err)
+	s, err := toString(v)
+	r.NoError(err)
+	r.Equal(`// +patchKey=containerPort,protocol
+ports: [{
+	containerPort: 53
+	protocol:      "TCP"
+	name:          "dns-tcp"
+}, {
+	containerPort: 53
+	protocol:      "UDP"
+	name:          "dns-udp"
+}, {
+	containerPort: 8080
+	protocol:      "TCP"
+}, {
+	containerPort: 9090
+}, ...]
+`, s)
+	r.Equal([]string{"item 2 of list ports misses the patch key containerPort,protocol, it's appended without being merged"}, warnings)
+}
+
 func TestStrategyPatch(t *testing.T) {
 	testCase := []struct {
 		base    string
@@ -637,6 +689,41 @@ metadata: {
 		keep: "true"
 	}
 }
+`}, {
+			base: `
+metadata: labels: {
+	app: "web"
+	tier: "frontend"
+}
+`,
+			patch: `
+metadata: {
+	// +patchStrategy=replace
+	labels: {
+		app: "web"
+	}
+}
+`,
+			result: `metadata: {
+	labels: {
+		// +patchStrategy=replace
+		app: "web"
+	}
+}
+`}, {
+			base: `
+spec: args: ["--a", "--b"]
+`,
+			patch: `
+spec: {
+	// +patchStrategy=retainKeys
+	args: ["--b"]
+}
+`,
+			result: `spec: {
+	// +patchStrategy=retainKeys
+	args: ["--b"]
+}
 `}, {
 			base: `
 spec: containers: [{
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -300,6 +301,39 @@ func OptBytesToString(node ast.Node) ast.Node {
 	return node
 }
 
+// OptSortFields sorts the fields of the structs by their labels, so that the value is formatted to
+// the same string regardless of the order its fields are filled in. The structs containing
+// declarations other than the fields, e.g. the embeddings, are left as is.
+func OptSortFields(node ast.Node) ast.Node {
+	ast.Walk(node, nil, func(node ast.Node) {
+		switch x := node.(type) {
+		case *ast.File:
+			sortFields(x.Decls)
+		case *ast.StructLit:
+			sortFields(x.Elts)
+		}
+	})
+	return node
+}
+
+func sortFields(decls []ast.Decl) {
+	labels := make(map[ast.Decl]string, len(decls))
+	for _, decl := range decls {
+		field, ok := decl.(*ast.Field)
+		if !ok {
+			return
+		}
+		label, _, err := ast.LabelName(field.Label)
+		if err != nil {
+			return
+		}
+		labels[decl] = label
+	}
+	sort.SliceStable(decls, func(i, j int) bool {
+		return labels[decls[i]] < labels[decls[j]]
+	})
+}
+
 // OpenBaiscLit make that the basicLit can be modified.
 // nolint:staticcheck
 func OpenBaiscLit(val cue.Value) (*ast.File, error) {
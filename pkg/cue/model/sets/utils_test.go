@@ -138,6 +138,52 @@ lacy: """
 	}
 }
 
+func TestOptSortFields(t *testing.T) {
+	testCases := []struct {
+		s        string
+		expected string
+	}{
+		{
+			s: `
+web: {
+	port: 80
+	name: "web"
+}
+"app-name": "foo"
+db: name: "db"
+`,
+			expected: `"app-name": "foo"
+db: {
+	name: "db"
+}
+web: {
+	name: "web"
+	port: 80
+}
+`},
+		{
+			s: `
+list: [{b: 1, a: 2}]
+zoo: true
+`,
+			expected: `list: [{
+	a: 2
+	b: 1
+}]
+zoo: true
+`},
+	}
+
+	ctx := cuecontext.New()
+	for _, tcase := range testCases {
+		r := require.New(t)
+		inst := ctx.CompileString(tcase.s)
+		str, err := ToString(inst, OptSortFields)
+		r.NoError(err)
+		r.Equal(tcase.expected, str)
+	}
+}
+
 func TestPreprocessBuiltinFunc(t *testing.T) {
 
 	doScript := func(values []ast.Node) (ast.Expr, error) {
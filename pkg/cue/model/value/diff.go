@@ -0,0 +1,233 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// ChangeType is the type of the change between two values
+type ChangeType string
+
+const (
+	// ChangeAdded means the path only exists in the right value
+	ChangeAdded ChangeType = "added"
+	// ChangeRemoved means the path only exists in the left value
+	ChangeRemoved ChangeType = "removed"
+	// ChangeModified means the values at the path are different
+	ChangeModified ChangeType = "modified"
+)
+
+// Change is the change of the value at the path
+type Change struct {
+	Path   string      `json:"path"`
+	Type   ChangeType  `json:"type"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// Changes is the list of the changes between two values
+type Changes []Change
+
+// Changed checks if there is any change
+func (c Changes) Changed() bool {
+	return len(c) > 0
+}
+
+type diffOptions struct {
+	ignore [][]pathSegment
+}
+
+// DiffOption is the option of Diff
+type DiffOption func(o *diffOptions) error
+
+// DiffIgnore ignores the changes under the paths, e.g. `metadata.managedFields` or `status`
+func DiffIgnore(paths ...string) DiffOption {
+	return func(o *diffOptions) error {
+		for _, path := range paths {
+			segments, err := parseFillPath(path)
+			if err != nil {
+				return errors.WithMessage(err, "parse ignored path")
+			}
+			o.ignore = append(o.ignore, segments)
+		}
+		return nil
+	}
+}
+
+// Diff walks the concrete values a and b and returns the changes from a to b, the lists are compared by indices.
+func Diff(a, b *Value, opts ...DiffOption) (Changes, error) {
+	o := &diffOptions{}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	left, err := decodeConcrete(a)
+	if err != nil {
+		return nil, errors.WithMessage(err, "decode the left value")
+	}
+	right, err := decodeConcrete(b)
+	if err != nil {
+		return nil, errors.WithMessage(err, "decode the right value")
+	}
+	d := &differ{opts: o}
+	d.diff(nil, left, right)
+	return d.changes, nil
+}
+
+func decodeConcrete(val *Value) (interface{}, error) {
+	data, err := val.v.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var x interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type differ struct {
+	opts    *diffOptions
+	changes Changes
+}
+
+func (d *differ) diff(path []pathSegment, left, right interface{}) {
+	if d.ignored(path) {
+		return
+	}
+	switch l := left.(type) {
+	case map[string]interface{}:
+		if r, ok := right.(map[string]interface{}); ok {
+			d.diffMap(path, l, r)
+			return
+		}
+	case []interface{}:
+		if r, ok := right.([]interface{}); ok {
+			d.diffList(path, l, r)
+			return
+		}
+	}
+	if !reflect.DeepEqual(left, right) {
+		d.add(path, ChangeModified, left, right)
+	}
+}
+
+func (d *differ) diffMap(path []pathSegment, left, right map[string]interface{}) {
+	keys := make([]string, 0, len(left)+len(right))
+	for k := range left {
+		keys = append(keys, k)
+	}
+	for k := range right {
+		if _, ok := left[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := appendSegments(path, pathSegment{label: k})
+		l, inLeft := left[k]
+		r, inRight := right[k]
+		switch {
+		case !inRight:
+			d.add(p, ChangeRemoved, l, nil)
+		case !inLeft:
+			d.add(p, ChangeAdded, nil, r)
+		default:
+			d.diff(p, l, r)
+		}
+	}
+}
+
+func (d *differ) diffList(path []pathSegment, left, right []interface{}) {
+	for i := 0; i < len(left) || i < len(right); i++ {
+		p := appendSegments(path, pathSegment{index: i, isIndex: true})
+		switch {
+		case i >= len(right):
+			d.add(p, ChangeRemoved, left[i], nil)
+		case i >= len(left):
+			d.add(p, ChangeAdded, nil, right[i])
+		default:
+			d.diff(p, left[i], right[i])
+		}
+	}
+}
+
+func (d *differ) add(path []pathSegment, t ChangeType, before, after interface{}) {
+	if d.ignored(path) {
+		return
+	}
+	d.changes = append(d.changes, Change{Path: formatPath(path), Type: t, Before: before, After: after})
+}
+
+// ignored checks if the path is under one of the ignored paths
+func (d *differ) ignored(path []pathSegment) bool {
+	for _, ignore := range d.opts.ignore {
+		if len(ignore) > len(path) {
+			continue
+		}
+		matched := true
+		for i, seg := range ignore {
+			if seg.isIndex != path[i].isIndex || seg.label != path[i].label || seg.isIndex && seg.index != path[i].index {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func appendSegments(path []pathSegment, seg pathSegment) []pathSegment {
+	p := make([]pathSegment, 0, len(path)+1)
+	return append(append(p, path...), seg)
+}
+
+var identifierRegexp = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*$`)
+
+// formatPath formats the segments into the path accepted by the script path, e.g. `a."b.c"[0]`
+func formatPath(path []pathSegment) string {
+	var buf bytes.Buffer
+	for i, seg := range path {
+		switch {
+		case seg.isIndex:
+			fmt.Fprintf(&buf, "[%d]", seg.index)
+			continue
+		case i > 0:
+			buf.WriteString(".")
+		}
+		if identifierRegexp.MatchString(seg.label) {
+			buf.WriteString(seg.label)
+		} else {
+			buf.WriteString(strconv.Quote(seg.label))
+		}
+	}
+	return buf.String()
+}
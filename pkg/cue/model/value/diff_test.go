@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	testCases := map[string]struct {
+		left    string
+		right   string
+		ignore  []string
+		changes string
+		err     string
+	}{
+		"equal": {
+			left:    `a: 1, b: [1, 2]`,
+			right:   `b: [1, 2], a: 1`,
+			changes: `null`,
+		},
+		"scalar changes": {
+			left:    `a: 1, b: "x", c: true, d: 1`,
+			right:   `a: 2, b: "x", c: true, e: {f: 1}`,
+			changes: `[{"path":"a","type":"modified","before":1,"after":2},{"path":"d","type":"removed","before":1},{"path":"e","type":"added","after":{"f":1}}]`,
+		},
+		"nested and list changes": {
+			left: `
+metadata: labels: "app.kubernetes.io/name": "a"
+spec: containers: [{image: "nginx"}, {image: "busybox"}]
+`,
+			right: `
+metadata: labels: "app.kubernetes.io/name": "b"
+spec: containers: [{image: "nginx:1.20"}]
+`,
+			changes: `[{"path":"metadata.labels.\"app.kubernetes.io/name\"","type":"modified","before":"a","after":"b"},{"path":"spec.containers[0].image","type":"modified","before":"nginx","after":"nginx:1.20"},{"path":"spec.containers[1]","type":"removed","before":{"image":"busybox"}}]`,
+		},
+		"type changes": {
+			left:    `a: [1]`,
+			right:   `a: {b: 1}`,
+			changes: `[{"path":"a","type":"modified","before":[1],"after":{"b":1}}]`,
+		},
+		"ignore": {
+			left:    `metadata: {name: "a", managedFields: [{manager: "x"}]}, status: phase: "Running", spec: replicas: 1`,
+			right:   `metadata: {name: "a", managedFields: [{manager: "y"}]}, spec: replicas: 2`,
+			ignore:  []string{"metadata.managedFields", "status"},
+			changes: `[{"path":"spec.replicas","type":"modified","before":1,"after":2}]`,
+		},
+		"invalid ignore": {
+			left:   `a: 1`,
+			right:  `a: 1`,
+			ignore: []string{"a..b"},
+			err:    "parse ignored path",
+		},
+		"not concrete": {
+			left:  `a: string`,
+			right: `a: "x"`,
+			err:   "decode the left value",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			left, err := NewValue(tc.left, nil, "")
+			r.NoError(err)
+			right, err := NewValue(tc.right, nil, "")
+			r.NoError(err)
+			changes, err := Diff(left, right, DiffIgnore(tc.ignore...))
+			if tc.err != "" {
+				r.Error(err)
+				r.Contains(err.Error(), tc.err)
+				return
+			}
+			r.NoError(err)
+			b, err := json.Marshal(changes)
+			r.NoError(err)
+			r.Equal(tc.changes, string(b))
+			r.Equal(tc.changes != "null", changes.Changed())
+		})
+	}
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"github.com/pkg/errors"
+)
+
+// appendIndex is the index segment `[-]` to append to the list
+const appendIndex = -1
+
+// pathSegment is a segment of the fill path, either a field label or a list index
+type pathSegment struct {
+	label   string
+	index   int
+	isIndex bool
+	// length is the length of the list in the value, it's resolved before filling
+	length int
+}
+
+// parseFillPath parses the path like `a."b.c"[2].d[-]["e/f"]` into segments.
+// The labels that look like numbers are treated as the keys of the map, e.g. `a.2`,
+// and the list indices must be in the brackets, `[-]` means appending to the list.
+func parseFillPath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+	s := strings.TrimSpace(path)
+	for len(s) > 0 {
+		switch s[0] {
+		case '[':
+			seg, rest, err := parseBracket(s[1:])
+			if err != nil {
+				return nil, errors.WithMessagef(err, "invalid path %s", path)
+			}
+			segments = append(segments, seg)
+			s = rest
+			continue
+		case '.':
+			if len(segments) == 0 {
+				return nil, errors.Errorf("invalid path %s", path)
+			}
+			s = s[1:]
+		default:
+			if len(segments) > 0 {
+				return nil, errors.Errorf("invalid path %s", path)
+			}
+		}
+		label, rest, err := parseLabel(s)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid path %s", path)
+		}
+		segments = append(segments, pathSegment{label: label})
+		s = rest
+	}
+	if len(segments) == 0 {
+		return nil, errors.Errorf("invalid path %s", path)
+	}
+	return segments, nil
+}
+
+// parseLabel parses the quoted or bare label at the beginning of s
+func parseLabel(s string) (string, string, error) {
+	if strings.HasPrefix(s, "\"") {
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", "", errors.New("invalid quoted label")
+		}
+		label, err := strconv.Unquote(quoted)
+		return label, s[len(quoted):], err
+	}
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	if end == 0 {
+		return "", "", errors.New("empty label")
+	}
+	return s[:end], s[end:], nil
+}
+
+// parseBracket parses the content in the bracket and the rest after `]`
+func parseBracket(s string) (pathSegment, string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "\"") {
+		label, rest, err := parseLabel(s)
+		if err != nil {
+			return pathSegment{}, "", err
+		}
+		rest = strings.TrimSpace(rest)
+		if !strings.HasPrefix(rest, "]") {
+			return pathSegment{}, "", errors.New("unclosed bracket")
+		}
+		return pathSegment{label: label}, rest[1:], nil
+	}
+	end := strings.Index(s, "]")
+	if end < 0 {
+		return pathSegment{}, "", errors.New("unclosed bracket")
+	}
+	inner := strings.TrimSpace(s[:end])
+	if inner == "-" {
+		return pathSegment{index: appendIndex, isIndex: true}, s[end+1:], nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil || index < 0 {
+		return pathSegment{}, "", errors.Errorf("invalid index %s", inner)
+	}
+	return pathSegment{index: index, isIndex: true}, s[end+1:], nil
+}
+
+func (seg pathSegment) selector() cue.Selector {
+	if seg.isIndex {
+		return cue.Index(seg.index)
+	}
+	if strings.HasPrefix(seg.label, "#") {
+		return cue.Def(seg.label)
+	}
+	return cue.Str(seg.label)
+}
+
+// wrap wraps the cue string v into the segment, the list is padded to its length
+// with `_` so that it can be unified with the list in the value.
+func (seg pathSegment) wrap(v string) string {
+	if !seg.isIndex {
+		return fmt.Sprintf("{%s: %s}", strconv.Quote(seg.label), v)
+	}
+	length := seg.length
+	if seg.index >= length {
+		length = seg.index + 1
+	}
+	elems := make([]string, length)
+	for i := range elems {
+		elems[i] = "_"
+	}
+	elems[seg.index] = v
+	return fmt.Sprintf("[%s]", strings.Join(elems, ", "))
+}
+
+func makeSegmentsPath(segments []pathSegment) cue.Path {
+	selectors := make([]cue.Selector, 0, len(segments))
+	for _, seg := range segments {
+		selectors = append(selectors, seg.selector())
+	}
+	return cue.MakePath(selectors...)
+}
+
+// resolveIndices replaces the append index with the length of the list and checks the indices are in range.
+// The index equals to the length of the list appends to the list.
+func (val *Value) resolveIndices(segments []pathSegment) error {
+	for i, seg := range segments {
+		if !seg.isIndex {
+			continue
+		}
+		prefix := makeSegmentsPath(segments[:i])
+		list := val.v.LookupPath(prefix)
+		length := 0
+		if list.Exists() {
+			if list.IncompleteKind() != cue.ListKind {
+				return errors.Errorf("%s is not a list", prefix)
+			}
+			iter, err := list.List()
+			if err != nil {
+				return err
+			}
+			for iter.Next() {
+				length++
+			}
+		}
+		segments[i].length = length
+		if seg.index == appendIndex {
+			segments[i].index = length
+			continue
+		}
+		if seg.index > length {
+			return errors.Errorf("index %d out of range of %s with length %d", seg.index, prefix, length)
+		}
+	}
+	return nil
+}
+
+// appendSegment is the path segment to append to the list, e.g. LookupValue("a", "[-]")
+const appendSegment = "[-]"
+
+// NotFoundError is the error of looking up the path that does not exist in the value
+type NotFoundError struct {
+	Path   string
+	Reason string
+}
+
+// Error returns the message of the missing path
+func (e *NotFoundError) Error() string {
+	msg := fmt.Sprintf("failed to lookup value: var(path=%s) not exist", e.Path)
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+// IsNotFound checks if the error is caused by looking up the path that does not exist
+func IsNotFound(err error) bool {
+	var e *NotFoundError
+	return errors.As(err, &e)
+}
+
+// SplitPath splits the path like `a."b.c"[0]` into the segments of LookupValue and FillObject,
+// the labels with dots or looking like numbers are quoted and the list indices become numeric segments.
+// The path is split by dots if it can not be parsed.
+func SplitPath(path string) []string {
+	segments, err := parseFillPath(path)
+	if err != nil {
+		return strings.Split(path, ".")
+	}
+	paths := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		switch {
+		case seg.isIndex && seg.index == appendIndex:
+			paths = append(paths, appendSegment)
+		case seg.isIndex:
+			paths = append(paths, strconv.Itoa(seg.index))
+		case isNumber(seg.label) || strings.ContainsAny(seg.label, ".[]\"") || isQuoted(seg.label):
+			paths = append(paths, strconv.Quote(seg.label))
+		default:
+			paths = append(paths, seg.label)
+		}
+	}
+	return paths
+}
+
+// indexedSegments converts the paths into segments if some of them are list indices, the numeric paths
+// are indices only if the parents of them are lists, otherwise they are the keys of the map.
+func (val *Value) indexedSegments(paths []string) ([]pathSegment, bool) {
+	if len(paths) < 2 {
+		return nil, false
+	}
+	segments := make([]pathSegment, 0, len(paths))
+	indexed := false
+	for i, p := range paths {
+		switch {
+		case i > 0 && p == appendSegment:
+			segments = append(segments, pathSegment{index: appendIndex, isIndex: true})
+			indexed = true
+		case i > 0 && isNumber(p) && val.v.LookupPath(makeSegmentsPath(segments)).IncompleteKind() == cue.ListKind:
+			index, _ := strconv.Atoi(p)
+			segments = append(segments, pathSegment{index: index, isIndex: true})
+			indexed = true
+		default:
+			segments = append(segments, pathSegment{label: unquoteString(p)})
+		}
+	}
+	return segments, indexed
+}
+
+// lookupPath makes the cue path of the paths, the numeric paths index the lists in the value
+func (val *Value) lookupPath(paths ...string) (cue.Path, error) {
+	path := FieldPath(paths...)
+	selectors := path.Selectors()
+	if len(paths) < 2 || len(selectors) != len(paths) {
+		return path, nil
+	}
+	for i := 1; i < len(paths); i++ {
+		if !isNumber(paths[i]) {
+			continue
+		}
+		parent := val.v.LookupPath(cue.MakePath(selectors[:i]...))
+		if parent.IncompleteKind() != cue.ListKind {
+			continue
+		}
+		index, _ := strconv.Atoi(paths[i])
+		length, err := parent.Len().Int64()
+		if err == nil && (index < 0 || int64(index) >= length) {
+			return path, &NotFoundError{
+				Path:   strings.Join(paths, "."),
+				Reason: fmt.Sprintf("index %d out of range of the list with length %d", index, length),
+			}
+		}
+		selectors[i] = cue.Index(index)
+	}
+	return cue.MakePath(selectors...), nil
+}
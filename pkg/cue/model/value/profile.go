@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/parser"
+	"github.com/cue-exp/kubevelafix"
+	"github.com/pkg/errors"
+
+	"github.com/kubevela/workflow/pkg/cue/packages"
+)
+
+const (
+	// ProfileV06Compat is the profile compatible with the templates written for the previous CUE versions
+	ProfileV06Compat = "v0.6-compat"
+	// ProfileLatest is the profile follows the semantics of the vendored CUE version
+	ProfileLatest = "latest"
+)
+
+// DefaultProfile is the profile used by the steps that do not declare a profile
+var DefaultProfile = ProfileV06Compat
+
+// Profile is a set of behavioral switches of the CUE evaluation
+type Profile struct {
+	Name string
+	// Deprecated marks the profile is scheduled for removal
+	Deprecated bool
+	// FixGuardedComprehension rewrites the comprehensions guarded by `if x != _|_` into
+	// `*x | {}`, which keeps the default resolution of the disjunction as the previous CUE versions
+	FixGuardedComprehension bool
+}
+
+var profiles = map[string]*Profile{
+	ProfileV06Compat: {
+		Name:                    ProfileV06Compat,
+		Deprecated:              true,
+		FixGuardedComprehension: true,
+	},
+	ProfileLatest: {
+		Name: ProfileLatest,
+	},
+}
+
+// GetProfile returns the profile by name, the default profile is returned if the name is empty
+func GetProfile(name string) (*Profile, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return nil, errors.Errorf("unknown cue profile %s", name)
+	}
+	return p, nil
+}
+
+// NewValueWithProfile new a value with the behavioral switches of the profile
+func NewValueWithProfile(s string, pd *packages.PackageDiscover, tagTempl string, profile *Profile, opts ...func(*ast.File) error) (*Value, error) {
+	builder := &build.Instance{}
+
+	file, err := parser.ParseFile("-", s, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if profile.FixGuardedComprehension {
+		file = kubevelafix.Fix(file).(*ast.File)
+	}
+	for _, opt := range opts {
+		if err := opt(file); err != nil {
+			return nil, err
+		}
+	}
+	if err := builder.AddSyntax(file); err != nil {
+		return nil, err
+	}
+	return newValue(builder, pd, tagTempl)
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/format"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetProfile(t *testing.T) {
+	r := require.New(t)
+	p, err := GetProfile("")
+	r.NoError(err)
+	r.Equal(DefaultProfile, p.Name)
+	p, err = GetProfile(ProfileLatest)
+	r.NoError(err)
+	r.False(p.Deprecated)
+	p, err = GetProfile(ProfileV06Compat)
+	r.NoError(err)
+	r.True(p.Deprecated)
+	_, err = GetProfile("v0.1")
+	r.Error(err)
+}
+
+func TestNewValueWithDefaultProfile(t *testing.T) {
+	r := require.New(t)
+	defer func(profile string) {
+		DefaultProfile = profile
+	}(DefaultProfile)
+	src := `
+parameter: test: _
+y: {
+	for k, v in parameter.test.p {
+		"\(k)": v
+	}
+}`
+	DefaultProfile = ProfileV06Compat
+	v, err := NewValue(src, nil, "")
+	r.NoError(err)
+	r.NoError(v.Error())
+	DefaultProfile = ProfileLatest
+	v, err = NewValue(src, nil, "")
+	r.NoError(err)
+	r.Error(v.Error())
+	DefaultProfile = "v0.1"
+	_, err = NewValue(src, nil, "")
+	r.Error(err)
+}
+
+func TestNewValueWithProfile(t *testing.T) {
+	testCases := map[string]struct {
+		src      string
+		expected map[string]string
+		hasErr   map[string]bool
+	}{
+		"guarded comprehension over incomplete value": {
+			src: `
+parameter: test: _
+y: {
+	for k, v in parameter.test.p {
+		"\(k)": v
+	}
+}`,
+			expected: map[string]string{
+				ProfileV06Compat: `{
+	parameter: {
+		test: _
+	} @step(1)
+	y: {
+		for k, v in *parameter.test.p | {} {
+			"\(k)": v
+		}
+	} @step(2)
+}`,
+				ProfileLatest: `{
+	parameter: {
+		test: _
+	} @step(1)
+	y: {
+		for k, v in parameter.test.p {
+			"\(k)": v
+		}
+	} @step(2)
+}`,
+			},
+			hasErr: map[string]bool{
+				ProfileLatest: true,
+			},
+		},
+		"comprehension over optional field": {
+			src: `
+parameter: {
+	labels?: [string]: string
+}
+labels: {
+	for k, v in parameter.labels {
+		"\(k)": v
+	}
+}`,
+			expected: map[string]string{
+				ProfileV06Compat: `{
+	parameter: {
+		labels?: {
+			[string]: string
+		}
+	} @step(1)
+	labels: {
+		for k, v in *parameter.labels | {} {
+			"\(k)": v
+		}
+	} @step(2)
+}`,
+				ProfileLatest: `{
+	parameter: {
+		labels?: {
+			[string]: string
+		}
+	} @step(1)
+	labels: {
+		for k, v in parameter.labels {
+			"\(k)": v
+		}
+	} @step(2)
+}`,
+			},
+			hasErr: map[string]bool{
+				ProfileLatest: true,
+			},
+		},
+		"ordered steps": {
+			src: `
+step1: {
+	value: 1
+}
+step2: {
+	value: step1.value + 1
+}`,
+			expected: map[string]string{
+				ProfileV06Compat: `{
+	step1: {
+		value: 1
+	} @step(1)
+	step2: {
+		value: step1.value + 1
+	} @step(2)
+}`,
+				ProfileLatest: `{
+	step1: {
+		value: 1
+	} @step(1)
+	step2: {
+		value: step1.value + 1
+	} @step(2)
+}`,
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			for profileName, expected := range tc.expected {
+				profile, err := GetProfile(profileName)
+				r.NoError(err)
+				v, err := NewValueWithProfile(tc.src, nil, "", profile, TagFieldOrder)
+				r.NoError(err)
+				b, err := format.Node(v.CueValue().Syntax(cue.Docs(true), cue.Attributes(true)))
+				r.NoError(err)
+				r.Equal(expected, string(b), profileName)
+				if tc.hasErr[profileName] {
+					r.Error(v.Error(), profileName)
+				} else {
+					r.NoError(v.Error(), profileName)
+				}
+			}
+		})
+	}
+}
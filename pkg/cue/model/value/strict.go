@@ -0,0 +1,288 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldStrict is the field of the op to reject the unknown fields in its parameters
+const FieldStrict = "strict"
+
+// FieldOnce is the field of the op to execute it only once in a step
+const FieldOnce = "once"
+
+// FieldIgnoreError is the field of the op to record its error in FieldError instead of failing the step
+const FieldIgnoreError = "ignoreError"
+
+// FieldError is the field of the op filled with the error ignored by FieldIgnoreError
+const FieldError = "error"
+
+// DefaultStrictUnmarshal is the default of the strict flag of the ops that do not declare one
+var DefaultStrictUnmarshal = false
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+type unmarshalOptions struct {
+	strict  bool
+	ignored []string
+}
+
+// UnmarshalOption is the option of UnmarshalTo
+type UnmarshalOption func(o *unmarshalOptions)
+
+// Strict rejects the fields unknown to the target of UnmarshalTo, the top level fields in ignored are
+// allowed, e.g. the outputs of the op.
+func Strict(ignored ...string) UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.strict = true
+		o.ignored = append(o.ignored, ignored...)
+	}
+}
+
+// StrictFor rejects the unknown fields if the strict field of the op value is true,
+// DefaultStrictUnmarshal is used if the op does not declare it.
+func StrictFor(op *Value, ignored ...string) UnmarshalOption {
+	strict, err := op.GetBool(FieldStrict)
+	if err != nil {
+		strict = DefaultStrictUnmarshal
+	}
+	if !strict {
+		return func(o *unmarshalOptions) {}
+	}
+	return Strict(append(ignored, FieldStrict, FieldOnce, FieldIgnoreError, FieldError)...)
+}
+
+type unknownField struct {
+	path    string
+	closest string
+}
+
+// UnknownFieldsError is the error of the fields unknown to the target of UnmarshalTo
+type UnknownFieldsError struct {
+	fields []unknownField
+}
+
+// Fields returns the paths of the unknown fields
+func (e *UnknownFieldsError) Fields() []string {
+	var fields []string
+	for _, f := range e.fields {
+		fields = append(fields, f.path)
+	}
+	return fields
+}
+
+// Error lists the unknown fields and the closest known fields of them
+func (e *UnknownFieldsError) Error() string {
+	var msgs []string
+	for _, f := range e.fields {
+		msg := fmt.Sprintf("%q", f.path)
+		if f.closest != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", f.closest)
+		}
+		msgs = append(msgs, msg)
+	}
+	return "unknown fields: " + strings.Join(msgs, ", ")
+}
+
+func checkUnknownFields(data []byte, x interface{}, ignored []string) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	obj, ok := raw.(map[string]interface{})
+	if ok {
+		obj = copyMap(obj)
+		for _, field := range ignored {
+			delete(obj, field)
+		}
+		raw = obj
+	}
+	e := &UnknownFieldsError{}
+	collectUnknownFields(raw, reflect.TypeOf(x), "", e)
+	if len(e.fields) == 0 {
+		return nil
+	}
+	sort.Slice(e.fields, func(i, j int) bool {
+		return e.fields[i].path < e.fields[j].path
+	})
+	return e
+}
+
+func collectUnknownFields(raw interface{}, t reflect.Type, path string, e *UnknownFieldsError) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		for key, v := range obj {
+			fieldPath := joinPath(path, key)
+			ft, ok := fields[key]
+			if !ok {
+				ft, ok = lookupFold(fields, key)
+			}
+			if !ok {
+				closest := closestField(key, fields)
+				if closest != "" {
+					closest = joinPath(path, closest)
+				}
+				e.fields = append(e.fields, unknownField{path: fieldPath, closest: closest})
+				continue
+			}
+			collectUnknownFields(v, ft, fieldPath, e)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := raw.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			collectUnknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), e)
+		}
+	case reflect.Map:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, v := range obj {
+			collectUnknownFields(v, t.Elem(), joinPath(path, key), e)
+		}
+	default:
+	}
+}
+
+// jsonFields returns the types of the fields of the struct keyed by their json names,
+// the fields of the embedded structs are promoted like encoding/json does.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if (f.Anonymous && name == "" || strings.Contains(opts, "inline")) && ft.Kind() == reflect.Struct {
+			for k, v := range jsonFields(ft) {
+				if _, ok := fields[k]; !ok {
+					fields[k] = v
+				}
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// lookupFold matches the key with the fields case-insensitively like encoding/json does
+func lookupFold(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	for name, t := range fields {
+		if strings.EqualFold(name, key) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// closestField returns the known field with the smallest edit distance to the key,
+// it returns empty if none of the fields is close enough.
+func closestField(key string, fields map[string]reflect.Type) string {
+	closest, best := "", len(key)/2+1
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(name)); d < best {
+			closest, best = name, d
+		}
+	}
+	return closest
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
@@ -27,6 +27,7 @@ import (
 	"cuelang.org/go/cue/ast"
 	"cuelang.org/go/cue/build"
 	"cuelang.org/go/cue/cuecontext"
+	cueerrors "cuelang.org/go/cue/errors"
 	"cuelang.org/go/cue/literal"
 	"cuelang.org/go/cue/parser"
 	"cuelang.org/go/cue/token"
@@ -75,32 +76,30 @@ func (val *Value) Error() error {
 }
 
 // UnmarshalTo unmarshal value into golang object
-func (val *Value) UnmarshalTo(x interface{}) error {
+func (val *Value) UnmarshalTo(x interface{}, opts ...UnmarshalOption) error {
 	data, err := val.v.MarshalJSON()
 	if err != nil {
 		return err
 	}
+	o := &unmarshalOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.strict {
+		if err := checkUnknownFields(data, x, o.ignored); err != nil {
+			return err
+		}
+	}
 	return json.Unmarshal(data, x)
 }
 
-// NewValue new a value
+// NewValue new a value with the default profile
 func NewValue(s string, pd *packages.PackageDiscover, tagTempl string, opts ...func(*ast.File) error) (*Value, error) {
-	builder := &build.Instance{}
-
-	file, err := parser.ParseFile("-", s, parser.ParseComments)
+	profile, err := GetProfile("")
 	if err != nil {
 		return nil, err
 	}
-	file = kubevelafix.Fix(file).(*ast.File)
-	for _, opt := range opts {
-		if err := opt(file); err != nil {
-			return nil, err
-		}
-	}
-	if err := builder.AddSyntax(file); err != nil {
-		return nil, err
-	}
-	return newValue(builder, pd, tagTempl)
+	return NewValueWithProfile(s, pd, tagTempl, profile, opts...)
 }
 
 // NewValueWithInstance new value with instance
@@ -122,6 +121,9 @@ func newValue(builder *build.Instance, pd *packages.PackageDiscover, tagTempl st
 	if err := addImports(builder); err != nil {
 		return nil, err
 	}
+	if err := packages.CheckImports(builder); err != nil {
+		return nil, err
+	}
 
 	r := cuecontext.New()
 	inst := r.BuildInstance(builder)
@@ -197,21 +199,34 @@ func (vs *visitor) shouldDo(name string) bool {
 func (vs *visitor) addAttrForExpr(node ast.Node, index *int) {
 	switch v := node.(type) {
 	case *ast.Comprehension:
-		st := v.Value.(*ast.StructLit)
+		st, ok := v.Value.(*ast.StructLit)
+		if !ok {
+			return
+		}
+		for _, elt := range st.Elts {
+			vs.addAttrForExpr(elt, index)
+		}
+	case *ast.EmbedDecl:
+		st, ok := v.Expr.(*ast.StructLit)
+		if !ok {
+			return
+		}
 		for _, elt := range st.Elts {
 			vs.addAttrForExpr(elt, index)
 		}
 	case *ast.Field:
-		basic, ok := v.Label.(*ast.Ident)
+		name, ok := fieldLabel(v.Label)
 		if !ok {
 			return
 		}
-		if !vs.shouldDo(basic.Name) {
+		if name != "" && !vs.shouldDo(name) {
 			return
 		}
 		if v.Attrs == nil {
 			*index++
-			vs.done(basic.Name)
+			if name != "" {
+				vs.done(name)
+			}
 			v.Attrs = []*ast.Attribute{
 				{Text: fmt.Sprintf("@step(%d)", *index)},
 			}
@@ -219,6 +234,28 @@ func (vs *visitor) addAttrForExpr(node ast.Node, index *int) {
 	}
 }
 
+// fieldLabel returns the name of the label, the name of the interpolated label is empty since it's
+// only known after evaluation.
+func fieldLabel(label ast.Label) (string, bool) {
+	switch l := label.(type) {
+	case *ast.Ident:
+		return l.Name, true
+	case *ast.BasicLit:
+		if l.Kind != token.STRING {
+			return "", false
+		}
+		name, err := literal.Unquote(l.Value)
+		if err != nil {
+			return "", false
+		}
+		return name, true
+	case *ast.Interpolation:
+		return "", true
+	default:
+		return "", false
+	}
+}
+
 // MakeValue generate an value with same runtime
 func (val *Value) MakeValue(s string) (*Value, error) {
 	builder := &build.Instance{}
@@ -249,8 +286,9 @@ func (val *Value) makeValueWithFile(files ...*ast.File) (*Value, error) {
 	imports := map[string]*ast.ImportSpec{}
 	for _, f := range files {
 		for _, importSpec := range f.Imports {
-			if _, ok := imports[importSpec.Name.String()]; !ok {
-				imports[importSpec.Name.String()] = importSpec
+			key := importSpec.Name.String() + importSpec.Path.Value
+			if _, ok := imports[key]; !ok {
+				imports[key] = importSpec
 			}
 		}
 		newFile.Decls = append(newFile.Decls, f.Decls...)
@@ -281,6 +319,9 @@ func (val *Value) FillRaw(x string, paths ...string) error {
 		return err
 	}
 	xInst := val.r.BuildFile(file)
+	if segments, ok := val.indexedSegments(paths); ok {
+		return val.fillSegments(&Value{v: xInst, r: val.r, addImports: val.addImports}, segments)
+	}
 	v := val.v.FillPath(FieldPath(paths...), xInst)
 	if v.Err() != nil {
 		return v.Err()
@@ -290,20 +331,42 @@ func (val *Value) FillRaw(x string, paths ...string) error {
 }
 
 // FillValueByScript unify the value x at the given script path.
+// The path supports quoted labels like `a."b.c"`, list indices like `a[0]` and appending like `a[-]`.
 func (val *Value) FillValueByScript(x *Value, path string) error {
+	segments, err := parseFillPath(path)
+	if err != nil {
+		return err
+	}
 	if !strings.Contains(path, "[") {
-		newV := val.v.FillPath(FieldPath(path), x.v)
+		newV := val.v.FillPath(makeSegmentsPath(segments), x.v)
 		if err := newV.Err(); err != nil {
 			return err
 		}
 		val.v = newV
 		return nil
 	}
+	if segments[0].isIndex {
+		return errors.Errorf("invalid path %s", path)
+	}
+	return val.fillSegments(x, segments)
+}
+
+// fillSegments unifies the value x at the segments containing list indices
+func (val *Value) fillSegments(x *Value, segments []pathSegment) error {
+	if err := val.resolveIndices(segments); err != nil {
+		return err
+	}
 	s, err := x.String()
 	if err != nil {
 		return err
 	}
-	return val.fillRawByScript(s, path)
+	if x.v.IncompleteKind() == cue.StructKind {
+		s = fmt.Sprintf("{\n%s\n}", s)
+	}
+	for i := len(segments) - 1; i > 0; i-- {
+		s = segments[i].wrap(s)
+	}
+	return val.fillRaw(fmt.Sprintf("%s: %s", strconv.Quote(segments[0].label), s))
 }
 
 func (val *Value) fillRawByScript(x string, path string) error {
@@ -315,11 +378,17 @@ func (val *Value) fillRawByScript(x string, path string) error {
 	if err := a.installTo(pathExpr); err != nil {
 		return err
 	}
+	return val.fillRaw(a.v)
+}
+
+// fillRaw unifies the value with the cue string x, the lists in the value are opened
+// so that x can fill the elements of them
+func (val *Value) fillRaw(x string) error {
 	raw, err := val.String(sets.ListOpen)
 	if err != nil {
 		return err
 	}
-	v, err := val.MakeValue(raw + "\n" + a.v)
+	v, err := val.MakeValue(raw + "\n" + x)
 	if err != nil {
 		return errors.WithMessage(err, "remake value")
 	}
@@ -344,6 +413,16 @@ func (val *Value) FillObject(x interface{}, paths ...string) error {
 		}
 		insert = v.v
 	}
+	if segments, ok := val.indexedSegments(paths); ok {
+		xv, ok := x.(*Value)
+		if !ok {
+			xv = &Value{v: val.r.Encode(x), r: val.r, addImports: val.addImports}
+			if err := xv.v.Err(); err != nil {
+				return err
+			}
+		}
+		return val.fillSegments(xv, segments)
+	}
 	newV := val.v.FillPath(FieldPath(paths...), insert)
 	// do not check newV.Err() error here, because the value may be filled later
 	val.v = newV
@@ -352,9 +431,13 @@ func (val *Value) FillObject(x interface{}, paths ...string) error {
 
 // LookupValue reports the value at a path starting from val
 func (val *Value) LookupValue(paths ...string) (*Value, error) {
-	v := val.v.LookupPath(FieldPath(paths...))
+	path, err := val.lookupPath(paths...)
+	if err != nil {
+		return nil, err
+	}
+	v := val.v.LookupPath(path)
 	if !v.Exists() {
-		return nil, errors.Errorf("failed to lookup value: var(path=%s) not exist", strings.Join(paths, "."))
+		return nil, &NotFoundError{Path: strings.Join(paths, ".")}
 	}
 	return &Value{
 		v:          v,
@@ -417,6 +500,7 @@ func (val *Value) LookupByScript(script string) (*Value, error) {
 		return nil, errors.WithMessage(err, "parse script")
 	}
 
+	val.addBuiltinImports(scriptFile)
 	behindKey(scriptFile, outputKey)
 
 	newV, err := val.makeValueWithFile(rawFile, scriptFile)
@@ -430,6 +514,84 @@ func (val *Value) LookupByScript(script string) (*Value, error) {
 	return newV.LookupValue(outputKey)
 }
 
+// EvaluateExpression evaluates the cue expression in the scope of the value, the result must be concrete.
+// The simple path is looked up as is, the builtin packages referred in the expression are imported automatically.
+func (val *Value) EvaluateExpression(expr string) (*Value, error) {
+	isScriptPath, err := isScript(expr)
+	if err != nil {
+		return nil, err
+	}
+	v, err := val.LookupByScript(expr)
+	if !isScriptPath {
+		return v, err
+	}
+	if err == nil {
+		err = v.CueValue().Validate(cue.Concrete(true))
+	}
+	if err != nil {
+		return nil, errors.Errorf("failed to evaluate expression %s: %s", strings.TrimSpace(expr), strings.TrimSpace(cueerrors.Details(err, nil)))
+	}
+	return v, nil
+}
+
+// builtinPackages is the builtin packages that can be referred in the scripts without imports, keyed by the package name
+var builtinPackages = map[string]string{
+	"base64":  "encoding/base64",
+	"hex":     "encoding/hex",
+	"json":    "encoding/json",
+	"yaml":    "encoding/yaml",
+	"list":    "list",
+	"math":    "math",
+	"net":     "net",
+	"path":    "path",
+	"regexp":  "regexp",
+	"strconv": "strconv",
+	"strings": "strings",
+	"struct":  "struct",
+	"time":    "time",
+	"uuid":    "uuid",
+}
+
+// addBuiltinImports imports the builtin packages referred in the script but not imported,
+// the identifiers that are fields of the value are not treated as packages.
+func (val *Value) addBuiltinImports(file *ast.File) {
+	imported := map[string]bool{}
+	for _, spec := range file.Imports {
+		name := spec.Name.String()
+		if spec.Name == nil {
+			importPath, err := literal.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			name = importPath[strings.LastIndex(importPath, "/")+1:]
+		}
+		imported[name] = true
+	}
+	var specs []*ast.ImportSpec
+	ast.Walk(file, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		ident, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		importPath, ok := builtinPackages[ident.Name]
+		if !ok || imported[ident.Name] || val.v.LookupPath(cue.MakePath(cue.Str(ident.Name))).Exists() {
+			return true
+		}
+		imported[ident.Name] = true
+		specs = append(specs, ast.NewImport(nil, importPath))
+		return true
+	}, nil)
+	if len(specs) == 0 {
+		return
+	}
+	file.Imports = append(file.Imports, specs...)
+	file.Decls = append([]ast.Decl{&ast.ImportDecl{Specs: specs}}, file.Decls...)
+}
+
 func behindKey(file *ast.File, key string) {
 	var (
 		implDecls []ast.Decl
@@ -468,6 +630,10 @@ type field struct {
 	Name  string
 	Value *Value
 	no    int64
+	// pos is the position of the field in the source
+	pos token.Pos
+	// seq is the order of the field discovered by the iterator
+	seq int
 }
 
 // StepByList process item in list.
@@ -492,9 +658,24 @@ func (val *Value) StepByList(handle func(name string, in *Value) (bool, error))
 	return nil
 }
 
-// StepByFields process the fields in order
-func (val *Value) StepByFields(handle func(name string, in *Value) (bool, error)) error {
-	iter := steps(val)
+// StepOption is the option of StepByFields
+type StepOption func(iter *stepsIterator)
+
+// StepSortedByName iterates the fields sorted by their names instead of their declaration order
+func StepSortedByName() StepOption {
+	return func(iter *stepsIterator) {
+		iter.byName = true
+	}
+}
+
+// StepByFields process the fields in order. The fields tagged with @step are processed in the order of the tags,
+// `#do` and `#provider` are processed first if not tagged, and the others are processed in their declaration order
+// in the source, regardless of the order of the fields returned by CUE. The fields without the source, e.g. the
+// ones filled by the handlers, are processed after the declared ones in the order they appear.
+// The fields generated by the comprehensions after the earlier fields are filled are put into the order among
+// the fields not processed yet.
+func (val *Value) StepByFields(handle func(name string, in *Value) (bool, error), opts ...StepOption) error {
+	iter := steps(val, opts...)
 	for iter.next() {
 		iter.do(handle)
 	}
@@ -507,12 +688,18 @@ type stepsIterator struct {
 	target  *Value
 	err     error
 	stopped bool
+	byName  bool
+	seq     int
 }
 
-func steps(v *Value) *stepsIterator {
-	return &stepsIterator{
+func steps(v *Value, opts ...StepOption) *stepsIterator {
+	iter := &stepsIterator{
 		target: v,
 	}
+	for _, opt := range opts {
+		opt(iter)
+	}
+	return iter
 }
 
 func (iter *stepsIterator) next() bool {
@@ -558,16 +745,42 @@ func (iter *stepsIterator) assemble() {
 			addFields = append(addFields, &field{
 				Name: name,
 				no:   no,
+				pos:  val.Pos(),
+				seq:  iter.seq,
 			})
+			iter.seq++
 		}
 	}
 
-	suffixItems := addFields
-	suffixItems = append(suffixItems, iter.queue[iter.index:]...)
-	sort.Sort(sortFields(suffixItems))
+	suffixItems := append(iter.queue[iter.index:len(iter.queue):len(iter.queue)], addFields...)
+	sort.SliceStable(suffixItems, func(i, j int) bool {
+		return iter.less(suffixItems[i], suffixItems[j])
+	})
 	iter.queue = append(iter.queue[:iter.index], suffixItems...)
 }
 
+// less orders the fields by the step tags, then by the names or the declaration order
+func (iter *stepsIterator) less(x, y *field) bool {
+	if x.no != y.no {
+		return x.no < y.no
+	}
+	if iter.byName {
+		return x.Name < y.Name
+	}
+	if x.pos.IsValid() != y.pos.IsValid() {
+		return x.pos.IsValid()
+	}
+	if x.pos.IsValid() {
+		if x.pos.Filename() != y.pos.Filename() {
+			return x.pos.Filename() < y.pos.Filename()
+		}
+		if x.pos.Offset() != y.pos.Offset() {
+			return x.pos.Offset() < y.pos.Offset()
+		}
+	}
+	return x.seq < y.seq
+}
+
 func (iter *stepsIterator) value() *Value {
 	v := iter.target.v.LookupPath(FieldPath(iter.name()))
 	return &Value{
@@ -600,19 +813,6 @@ func (iter *stepsIterator) do(handle func(name string, in *Value) (bool, error))
 	}
 }
 
-type sortFields []*field
-
-func (sf sortFields) Len() int {
-	return len(sf)
-}
-func (sf sortFields) Less(i, j int) bool {
-	return sf[i].no < sf[j].no
-}
-
-func (sf sortFields) Swap(i, j int) {
-	sf[i], sf[j] = sf[j], sf[i]
-}
-
 // Field return the cue value corresponding to the specified field
 func (val *Value) Field(label string) (cue.Value, error) {
 	v := val.v.LookupPath(cue.ParsePath(label))
@@ -749,6 +949,9 @@ func makePath(paths ...string) string {
 		return mergedPath
 	}
 	mergedPath = paths[0]
+	if len(paths) == 1 && isQuoted(mergedPath) {
+		return mergedPath
+	}
 	if mergedPath == "" || (len(paths) == 1 && (strings.Contains(mergedPath, ".") || strings.Contains(mergedPath, "[") || isNumber(mergedPath))) {
 		return unquoteString(paths[0])
 	}
@@ -773,6 +976,11 @@ func unquoteString(s string) string {
 	return s
 }
 
+func isQuoted(s string) bool {
+	_, err := strconv.Unquote(s)
+	return err == nil && strings.HasPrefix(s, "\"")
+}
+
 func isNumber(s string) bool {
 	_, err := strconv.ParseInt(s, 10, 64)
 	return err == nil
@@ -19,9 +19,11 @@ package value
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 
 	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
 	"cuelang.org/go/cue/format"
 	"github.com/kubevela/workflow/pkg/cue/model/sets"
 
@@ -209,6 +211,56 @@ step3: "3"
 	r.Equal(inc, 2)
 }
 
+func TestStepByFieldsOrder(t *testing.T) {
+	base := `
+zeta: {}
+opt?: {}
+{
+	embedded: {}
+}
+alpha: {}
+for i, n in ["x", "y"] {
+	"gen\(i)": {name: n}
+}
+if alpha.value > 0 {
+	cond: {}
+}
+mid: {}
+`
+	testCases := map[string]struct {
+		opts     []func(*ast.File) error
+		stepOpts []StepOption
+		expected []string
+	}{
+		"declaration order": {
+			expected: []string{"zeta", "opt", "embedded", "alpha", "gen0", "gen1", "cond", "mid"},
+		},
+		"declaration order with tags": {
+			opts:     []func(*ast.File) error{TagFieldOrder},
+			expected: []string{"zeta", "opt", "embedded", "alpha", "gen0", "gen1", "cond", "mid"},
+		},
+		"sorted by name": {
+			stepOpts: []StepOption{StepSortedByName()},
+			expected: []string{"alpha", "cond", "embedded", "gen0", "gen1", "mid", "opt", "zeta"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			for i := 0; i < 3; i++ {
+				val, err := NewValue(base, nil, "", tc.opts...)
+				r.NoError(err)
+				var visited []string
+				r.NoError(val.StepByFields(func(name string, in *Value) (bool, error) {
+					visited = append(visited, name)
+					return false, in.FillObject(len(visited), "value")
+				}, tc.stepOpts...))
+				r.Equal(tc.expected, visited)
+			}
+		})
+	}
+}
+
 func TestStepWithTag(t *testing.T) {
 	testCases := []struct {
 		base     string
@@ -374,6 +426,70 @@ do: string
 	r.Error(err)
 }
 
+func TestStrictUnmarshal(t *testing.T) {
+	type notification struct {
+		Message string `json:"message"`
+		Labels  map[string]string
+		Targets []struct {
+			URL string `json:"url"`
+		} `json:"targets"`
+	}
+	testCases := map[string]struct {
+		src    string
+		opts   []UnmarshalOption
+		errMsg string
+	}{
+		"unknown fields are dropped by default": {
+			src: `mesage: "hello"`,
+		},
+		"known fields": {
+			src:  `{message: "hello", labels: app: "web", targets: [{url: "http://a"}]}`,
+			opts: []UnmarshalOption{Strict()},
+		},
+		"unknown fields are rejected": {
+			src:    `{mesage: "hello", targets: [{uri: "http://a"}], extra: 1}`,
+			opts:   []UnmarshalOption{Strict()},
+			errMsg: `unknown fields: "extra", "mesage" (did you mean "message"?), "targets[0].uri" (did you mean "targets[0].url"?)`,
+		},
+		"ignored fields": {
+			src:  `{message: "hello", result: ok: true}`,
+			opts: []UnmarshalOption{Strict("result")},
+		},
+		"strict flag of the op": {
+			src:    `{mesage: "hello", strict: true}`,
+			errMsg: `unknown fields: "mesage" (did you mean "message"?)`,
+		},
+		"strict flag disabled": {
+			src: `{mesage: "hello", strict: false}`,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			v, err := NewValue(tc.src, nil, "")
+			r.NoError(err)
+			opts := append(tc.opts, StrictFor(v))
+			out := &notification{}
+			err = v.UnmarshalTo(out, opts...)
+			if tc.errMsg != "" {
+				r.EqualError(err, tc.errMsg)
+				return
+			}
+			r.NoError(err)
+		})
+	}
+
+	r := require.New(t)
+	defer func(strict bool) { DefaultStrictUnmarshal = strict }(DefaultStrictUnmarshal)
+	DefaultStrictUnmarshal = true
+	v, err := NewValue(`mesage: "hello"`, nil, "")
+	r.NoError(err)
+	err = v.UnmarshalTo(&notification{}, StrictFor(v))
+	unknown := &UnknownFieldsError{}
+	r.True(errors.As(err, &unknown))
+	r.Equal([]string{"mesage"}, unknown.Fields())
+}
+
 func TestStepByList(t *testing.T) {
 	r := require.New(t)
 	base := `[{step: 1},{step: 2}]`
@@ -868,6 +984,45 @@ strings.Join(apply.arr,".")+"$"`,
 	}
 }
 
+func TestEvaluateExpression(t *testing.T) {
+	r := require.New(t)
+	srcV, err := NewValue(`
+parameter: port: 8080
+output: status: loadBalancer: ingress: [{ip: "10.0.0.1"}]
+output: spec: replicas: int
+`, nil, "")
+	r.NoError(err)
+
+	v, err := srcV.EvaluateExpression(`output.status.loadBalancer.ingress[0].ip + ":" + strconv.FormatInt(parameter.port, 10)`)
+	r.NoError(err)
+	s, err := v.CueValue().String()
+	r.NoError(err)
+	r.Equal("10.0.0.1:8080", s)
+
+	v, err = srcV.EvaluateExpression(`
+import "strings"
+strings.ToUpper(output.status.loadBalancer.ingress[0].ip) + strconv.Quote("a")`)
+	r.NoError(err)
+	s, err = v.CueValue().String()
+	r.NoError(err)
+	r.Equal(`10.0.0.1"a"`, s)
+
+	// the simple path keeps working even if it's not concrete
+	_, err = srcV.EvaluateExpression(`output.spec.replicas`)
+	r.NoError(err)
+
+	_, err = srcV.EvaluateExpression(`output.spec.replicas + 1`)
+	r.Error(err)
+	r.Contains(err.Error(), "failed to evaluate expression output.spec.replicas + 1")
+	r.Contains(err.Error(), "non-concrete value int in operand to +")
+
+	// the field shadows the builtin package
+	srcV, err = NewValue(`strings: "shadowed"`, nil, "")
+	r.NoError(err)
+	_, err = srcV.EvaluateExpression(`strings.ToUpper("a")`)
+	r.Error(err)
+}
+
 func TestGet(t *testing.T) {
 	caseOk := `
 strKey: "xxx"
@@ -934,6 +1089,152 @@ y: *"100" | _
 `)
 }
 
+func TestFillValueByScriptPath(t *testing.T) {
+	src := `
+properties: env: [{name: "a"}, {name: "b"}, {name: "c"}]
+labels: {"1": "one"}
+object: spec: {}
+`
+	testCases := []struct {
+		name   string
+		path   string
+		v      string
+		lookup string
+		expect string
+	}{{
+		name:   "list index",
+		path:   "properties.env[2].value",
+		v:      `"foo"`,
+		lookup: "properties.env[2].value",
+		expect: `"foo"`,
+	}, {
+		name:   "append to list",
+		path:   "properties.env[-]",
+		v:      `{name: "d"}`,
+		lookup: "properties.env[3].name",
+		expect: `"d"`,
+	}, {
+		name:   "quoted label with dots",
+		path:   `labels."app.oam.dev/name"`,
+		v:      `"foo"`,
+		lookup: `labels."app.oam.dev/name"`,
+		expect: `"foo"`,
+	}, {
+		name:   "quoted label in bracket",
+		path:   `properties.env[0]["example.com/key"]`,
+		v:      `"foo"`,
+		lookup: `properties.env[0]."example.com/key"`,
+		expect: `"foo"`,
+	}, {
+		name:   "numeric map key",
+		path:   "labels.2",
+		v:      `"two"`,
+		lookup: `labels."2"`,
+		expect: `"two"`,
+	}, {
+		name:   "quoted numeric map key",
+		path:   `labels["3"]`,
+		v:      `"three"`,
+		lookup: `labels."3"`,
+		expect: `"three"`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+			v, err := NewValue(src, nil, "")
+			r.NoError(err)
+			x, err := v.MakeValue(tc.v)
+			r.NoError(err)
+			r.NoError(v.FillValueByScript(x, tc.path))
+			result, err := v.LookupValue(tc.lookup)
+			r.NoError(err)
+			s, err := result.String()
+			r.NoError(err)
+			r.Equal(tc.expect, strings.TrimSpace(s))
+		})
+	}
+
+	errCases := map[string]string{
+		"properties.env[4]":  "index 4 out of range",
+		"properties.env[x]":  "invalid index x",
+		"object.spec[0]":     "object.spec is not a list",
+		"properties..env":    "invalid path",
+		"properties.env[0":   "unclosed bracket",
+		`labels."unclosed`:   "invalid quoted label",
+		"[0].properties.env": "invalid path",
+	}
+	for path, msg := range errCases {
+		r := require.New(t)
+		v, err := NewValue(src, nil, "")
+		r.NoError(err)
+		x, err := v.MakeValue(`"foo"`)
+		r.NoError(err)
+		err = v.FillValueByScript(x, path)
+		r.Error(err, path)
+		r.Contains(err.Error(), msg, path)
+	}
+}
+
+func TestIndexedPaths(t *testing.T) {
+	r := require.New(t)
+	v, err := NewValue(`
+spec: containers: [{image: "nginx"}, {image: "busybox"}]
+metadata: labels: {
+	"app.kubernetes.io/name": "app"
+	"2":                      "two"
+}
+`, nil, "")
+	r.NoError(err)
+
+	image, err := v.GetString("spec", "containers", "1", "image")
+	r.NoError(err)
+	r.Equal("busybox", image)
+	name, err := v.GetString("metadata", "labels", "app.kubernetes.io/name")
+	r.NoError(err)
+	r.Equal("app", name)
+	_, err = v.GetString(`"app.kubernetes.io/name"`)
+	r.True(IsNotFound(err))
+	two, err := v.GetString("metadata", "labels", "2")
+	r.NoError(err)
+	r.Equal("two", two)
+
+	_, err = v.LookupValue("spec", "containers", "2", "image")
+	r.Error(err)
+	r.True(IsNotFound(err))
+	r.Contains(err.Error(), "index 2 out of range of the list with length 2")
+	_, err = v.LookupValue("spec", "volumes")
+	r.True(IsNotFound(err))
+
+	r.NoError(v.FillObject("nginx", "spec", "containers", "0", "name"))
+	r.NoError(v.FillObject(map[string]string{"image": "redis"}, "spec", "containers", "[-]"))
+	r.NoError(v.FillRaw(`"web"`, "spec", "containers", "1", "name"))
+	r.NoError(v.FillObject("v1", "metadata", "labels", "app.kubernetes.io/version"))
+	s, err := v.String()
+	r.NoError(err)
+	r.Equal(`spec: {
+	containers: [{
+		image: "nginx"
+		name:  "nginx"
+	}, {
+		image: "busybox"
+		name:  "web"
+	}, {
+		image: "redis"
+	}]
+}
+metadata: {
+	labels: {
+		"app.kubernetes.io/name":    "app"
+		"app.kubernetes.io/version": "v1"
+		"2":                         "two"
+	}
+}
+`, s)
+
+	r.Equal([]string{"a", `"b.c"`, "2", "d", "[-]", `"3"`}, SplitPath(`a."b.c"[2].d[-].3`))
+	r.Equal([]string{"a", "b"}, SplitPath("a.b"))
+}
+
 func TestFillByScript(t *testing.T) {
 	testCases := []struct {
 		name     string
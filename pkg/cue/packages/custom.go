@@ -0,0 +1,263 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package packages
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/parser"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/kubevela/workflow/pkg/stdlib"
+)
+
+const (
+	// LabelCUEPackage is the label of the ConfigMaps containing the custom CUE packages, its value should be true
+	LabelCUEPackage = "cue.oam.dev/package"
+	// AnnotationCUEPackagePath is the annotation of the import path of the package in the ConfigMap,
+	// the name of the ConfigMap is used if it's not set
+	AnnotationCUEPackagePath = "cue.oam.dev/package-path"
+)
+
+// ImportNotFoundError is the error of importing the package that is neither builtin nor registered
+type ImportNotFoundError struct {
+	Package string
+	// Definition is the definition importing the package, it's set by the caller rendering the definition
+	Definition string
+}
+
+// Error implements the Error interface.
+func (e *ImportNotFoundError) Error() string {
+	if e.Definition != "" {
+		return fmt.Sprintf("package %q imported by definition %s is not found", e.Package, e.Definition)
+	}
+	return fmt.Sprintf("package %q is imported but not found", e.Package)
+}
+
+type customPackage struct {
+	instance *build.Instance
+	// source is where the package is loaded from, e.g. the ConfigMap or the directory
+	source string
+}
+
+// AddCustomPackage registers the CUE package built from the files keyed by their names, the files without
+// the .cue suffix are ignored. The package registered before with the same import path is replaced.
+func (pd *PackageDiscover) AddCustomPackage(importPath string, files map[string]string) error {
+	return pd.addCustomPackage(importPath, files, "")
+}
+
+func (pd *PackageDiscover) addCustomPackage(importPath string, files map[string]string, source string) error {
+	pkg := newPackage(importPath)
+	var names []string
+	for name := range files {
+		if strings.HasSuffix(name, ".cue") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return errors.Errorf("package %s has no cue files", importPath)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file, err := parser.ParseFile(name, files[name], parser.ParseComments)
+		if err != nil {
+			return errors.WithMessagef(err, "parse file %s of package %s", name, importPath)
+		}
+		if file.PackageName() != "" {
+			pkg.PkgName = file.PackageName()
+		}
+		if err := pkg.AddSyntax(file); err != nil {
+			return errors.WithMessagef(err, "add file %s of package %s", name, importPath)
+		}
+	}
+	if err := stdlib.AddImportsFor(pkg.Instance, ""); err != nil {
+		return err
+	}
+	if err := cuecontext.New().BuildInstance(pkg.Instance).Err(); err != nil {
+		return errors.WithMessagef(err, "build package %s", importPath)
+	}
+
+	pd.mutex.Lock()
+	defer pd.mutex.Unlock()
+	if pd.customPackages == nil {
+		pd.customPackages = map[string]customPackage{}
+	}
+	pd.customPackages[importPath] = customPackage{instance: pkg.Instance, source: source}
+	return nil
+}
+
+// RemoveCustomPackage unregisters the custom CUE package
+func (pd *PackageDiscover) RemoveCustomPackage(importPath string) {
+	pd.removeCustomPackage(importPath, "")
+}
+
+// removeCustomPackage removes the package only if it's loaded from the source, so that
+// deleting a ConfigMap does not remove the package replaced by another one.
+func (pd *PackageDiscover) removeCustomPackage(importPath string, source string) {
+	pd.mutex.Lock()
+	defer pd.mutex.Unlock()
+	if pkg, ok := pd.customPackages[importPath]; ok && (source == "" || pkg.source == source) {
+		delete(pd.customPackages, importPath)
+	}
+}
+
+// LoadCustomPackagesFromDir registers the cue files in each sub directory of dir as a package, the import path
+// of the package is the relative path of the sub directory, e.g. the files in `<dir>/mycorp.com/helpers` can be
+// imported by `import "mycorp.com/helpers"`. The symbolic links are followed so that the mounted volumes work.
+func (pd *PackageDiscover) LoadCustomPackagesFromDir(dir string) error {
+	return pd.loadPackagesFromDir(dir, "")
+}
+
+func (pd *PackageDiscover) loadPackagesFromDir(root, rel string) error {
+	entries, err := os.ReadDir(filepath.Join(root, rel))
+	if err != nil {
+		return err
+	}
+	files := map[string]string{}
+	for _, entry := range entries {
+		// skip the hidden files and the `..data` of the mounted ConfigMaps
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(root, rel, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if err := pd.loadPackagesFromDir(root, filepath.Join(rel, entry.Name())); err != nil {
+				return err
+			}
+			continue
+		}
+		if rel == "" || !strings.HasSuffix(entry.Name(), ".cue") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		files[entry.Name()] = string(content)
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	return pd.addCustomPackage(filepath.ToSlash(rel), files, "dir:"+root)
+}
+
+// WatchCustomPackages registers the packages in the ConfigMaps labeled with cue.oam.dev/package=true
+// in the namespace and reloads them when the ConfigMaps are changed, it blocks until the context is done.
+func (pd *PackageDiscover) WatchCustomPackages(ctx context.Context, cli kubernetes.Interface, namespace string) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(cli, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = LabelCUEPackage + "=true"
+		}))
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if cm, ok := obj.(*corev1.ConfigMap); ok {
+				pd.addConfigMapPackage(cm)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldCM, ok := oldObj.(*corev1.ConfigMap)
+			if !ok {
+				return
+			}
+			cm, ok := newObj.(*corev1.ConfigMap)
+			if !ok {
+				return
+			}
+			if oldPath := configMapPackagePath(oldCM); oldPath != configMapPackagePath(cm) {
+				pd.removeCustomPackage(oldPath, configMapSource(oldCM))
+			}
+			pd.addConfigMapPackage(cm)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if cm, ok := obj.(*corev1.ConfigMap); ok {
+				pd.removeCustomPackage(configMapPackagePath(cm), configMapSource(cm))
+			}
+		},
+	})
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return errors.New("failed to sync the ConfigMaps of the cue packages")
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (pd *PackageDiscover) addConfigMapPackage(cm *corev1.ConfigMap) {
+	importPath := configMapPackagePath(cm)
+	if err := pd.addCustomPackage(importPath, cm.Data, configMapSource(cm)); err != nil {
+		// keep the package loaded before so that the running workflows are not broken by a bad update
+		klog.ErrorS(err, "Failed to load the cue package from ConfigMap", "namespace", cm.Namespace, "name", cm.Name, "package", importPath)
+		return
+	}
+	klog.InfoS("Loaded the cue package from ConfigMap", "namespace", cm.Namespace, "name", cm.Name, "package", importPath)
+}
+
+func configMapPackagePath(cm *corev1.ConfigMap) string {
+	if path := cm.Annotations[AnnotationCUEPackagePath]; path != "" {
+		return path
+	}
+	return cm.Name
+}
+
+func configMapSource(cm *corev1.ConfigMap) string {
+	return fmt.Sprintf("configmap:%s/%s", cm.Namespace, cm.Name)
+}
+
+// CheckImports returns ImportNotFoundError if the instance imports a non-standard package that is not added
+// to its imports, the standard packages are the ones whose first path element does not contain a dot.
+func CheckImports(bi *build.Instance) error {
+	imported := map[string]bool{}
+	for _, inst := range bi.Imports {
+		imported[inst.ImportPath] = true
+	}
+	for _, file := range bi.Files {
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				return err
+			}
+			if imported[path] || !strings.Contains(strings.Split(path, "/")[0], ".") {
+				continue
+			}
+			return &ImportNotFoundError{Package: path}
+		}
+	}
+	return nil
+}
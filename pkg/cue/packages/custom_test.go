@@ -0,0 +1,163 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package packages
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/parser"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const helpersTemplate = `
+import "mycorp.com/helpers"
+
+name: helpers.#Name & {prefix: "app"}
+`
+
+func buildHelpers(t *testing.T, pd *PackageDiscover) (string, error) {
+	r := require.New(t)
+	bi := &build.Instance{}
+	file, err := parser.ParseFile("-", helpersTemplate)
+	r.NoError(err)
+	r.NoError(bi.AddSyntax(file))
+	pd.ImportBuiltinPackagesFor(bi)
+	if err := CheckImports(bi); err != nil {
+		return "", err
+	}
+	v, err := pd.ImportPackagesAndBuildValue(bi)
+	r.NoError(err)
+	return v.LookupPath(cue.ParsePath("name.out")).String()
+}
+
+func TestCustomPackage(t *testing.T) {
+	r := require.New(t)
+	pd := &PackageDiscover{pkgKinds: map[string][]VersionKind{}}
+
+	_, err := buildHelpers(t, pd)
+	r.Error(err)
+	r.Equal(`package "mycorp.com/helpers" is imported but not found`, err.Error())
+
+	r.NoError(pd.AddCustomPackage("mycorp.com/helpers", map[string]string{
+		"name.cue": `
+package helpers
+
+#Name: {
+	prefix: string
+	out:    prefix + "-name"
+}`,
+		"README.md": "ignored",
+	}))
+	out, err := buildHelpers(t, pd)
+	r.NoError(err)
+	r.Equal("app-name", out)
+
+	err = pd.AddCustomPackage("mycorp.com/broken", map[string]string{"a.cue": `a: 1, a: 2`})
+	r.Error(err)
+	r.Contains(err.Error(), "build package mycorp.com/broken")
+	err = pd.AddCustomPackage("mycorp.com/empty", map[string]string{"README.md": ""})
+	r.Error(err)
+	r.Contains(err.Error(), "has no cue files")
+
+	pd.RemoveCustomPackage("mycorp.com/helpers")
+	_, err = buildHelpers(t, pd)
+	r.Error(err)
+	importErr := &ImportNotFoundError{}
+	r.ErrorAs(err, &importErr)
+	importErr.Definition = "apply-helpers"
+	r.Equal(`package "mycorp.com/helpers" imported by definition apply-helpers is not found`, importErr.Error())
+}
+
+func TestLoadCustomPackagesFromDir(t *testing.T) {
+	r := require.New(t)
+	dir := t.TempDir()
+	r.NoError(os.MkdirAll(filepath.Join(dir, "..data", "mycorp.com", "helpers"), 0750))
+	r.NoError(os.WriteFile(filepath.Join(dir, "..data", "mycorp.com", "helpers", "name.cue"), []byte(`
+package helpers
+
+#Name: {
+	prefix: string
+	out:    prefix + "-dir"
+}`), 0600))
+	// the layout of the mounted volumes
+	r.NoError(os.Symlink(filepath.Join(dir, "..data", "mycorp.com"), filepath.Join(dir, "mycorp.com")))
+	r.NoError(os.WriteFile(filepath.Join(dir, "root.cue"), []byte(`a: 1`), 0600))
+
+	pd := &PackageDiscover{pkgKinds: map[string][]VersionKind{}}
+	r.NoError(pd.LoadCustomPackagesFromDir(dir))
+	r.Len(pd.customPackages, 1)
+	out, err := buildHelpers(t, pd)
+	r.NoError(err)
+	r.Equal("app-dir", out)
+
+	r.Error(pd.LoadCustomPackagesFromDir(filepath.Join(dir, "not-exist")))
+}
+
+func TestWatchCustomPackages(t *testing.T) {
+	r := require.New(t)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "helpers",
+			Namespace:   "vela-system",
+			Labels:      map[string]string{LabelCUEPackage: "true"},
+			Annotations: map[string]string{AnnotationCUEPackagePath: "mycorp.com/helpers"},
+		},
+		Data: map[string]string{"name.cue": `#Name: {prefix: string, out: prefix + "-v1"}`},
+	}
+	cli := fake.NewSimpleClientset(cm)
+	pd := &PackageDiscover{pkgKinds: map[string][]VersionKind{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = pd.WatchCustomPackages(ctx, cli, "vela-system")
+	}()
+
+	waitFor := func(expected string) {
+		r.Eventually(func() bool {
+			out, err := buildHelpers(t, pd)
+			if expected == "" {
+				return err != nil
+			}
+			return err == nil && out == expected
+		}, 5*time.Second, 10*time.Millisecond, expected)
+	}
+	waitFor("app-v1")
+
+	cm.Data["name.cue"] = `#Name: {prefix: string, out: prefix + "-v2"}`
+	_, err := cli.CoreV1().ConfigMaps("vela-system").Update(ctx, cm, metav1.UpdateOptions{})
+	r.NoError(err)
+	waitFor("app-v2")
+
+	// the broken update keeps the package loaded before
+	cm.Data["name.cue"] = `#Name: {`
+	_, err = cli.CoreV1().ConfigMaps("vela-system").Update(ctx, cm, metav1.UpdateOptions{})
+	r.NoError(err)
+	time.Sleep(100 * time.Millisecond)
+	waitFor("app-v2")
+
+	r.NoError(cli.CoreV1().ConfigMaps("vela-system").Delete(ctx, "helpers", metav1.DeleteOptions{}))
+	waitFor("")
+}
@@ -19,6 +19,7 @@ package packages
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -56,6 +57,7 @@ type PackageDiscover struct {
 	pkgKinds            map[string][]VersionKind
 	mutex               sync.RWMutex
 	client              *rest.RESTClient
+	customPackages      map[string]customPackage
 }
 
 // VersionKind contains the resource metadata and reference name
@@ -105,6 +107,14 @@ func (pd *PackageDiscover) ImportBuiltinPackagesFor(bi *build.Instance) {
 	pd.mutex.RLock()
 	defer pd.mutex.RUnlock()
 	bi.Imports = append(bi.Imports, pd.velaBuiltinPackages...)
+	paths := make([]string, 0, len(pd.customPackages))
+	for path := range pd.customPackages {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		bi.Imports = append(bi.Imports, pd.customPackages[path].instance)
+	}
 }
 
 // ImportPackagesAndBuildInstance Combine import built-in packages and build cue template together to avoid data race
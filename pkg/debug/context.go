@@ -18,7 +18,10 @@ package debug
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"unicode/utf8"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -29,9 +32,35 @@ import (
 	wfTypes "github.com/kubevela/workflow/pkg/types"
 )
 
+const (
+	// DebugKey is the key of the rendered step value in the debug config map
+	DebugKey = "debug"
+	// ErrorKey is the key of the failed op error in the debug config map
+	ErrorKey = "error"
+	// TraceKey is the key of the op trace in the debug config map
+	TraceKey = "trace"
+	// ValuesKey is the key of the values recorded by the debug op in the debug config map
+	ValuesKey = "values"
+	// truncatedMarker is appended to the truncated values in the trace
+	truncatedMarker = "...(truncated)"
+	// redactedMarker replaces the sensitive values in the debug config map
+	redactedMarker = "<redacted>"
+)
+
+var (
+	// MaxTraceEntries is the max number of op entries kept in the trace, the earliest entries are dropped first
+	MaxTraceEntries = 50
+	// MaxTraceValueSize is the max size of the input or output value of an op entry in the trace
+	MaxTraceValueSize = 4096
+	// MaxValueSize is the max size of a value recorded by the debug op
+	MaxValueSize = 16 * 1024
+)
+
 // ContextImpl is workflow debug context interface
 type ContextImpl interface {
 	Set(v *value.Value) error
+	SetWithError(v *value.Value, stepErr error) error
+	SetWithTrace(v *value.Value, stepErr error, trace []wfTypes.OpTrace) error
 }
 
 // Context is debug context.
@@ -39,15 +68,44 @@ type Context struct {
 	cli      client.Client
 	instance *wfTypes.WorkflowInstance
 	step     string
+	redactor *strings.Replacer
 }
 
 // Set sets debug content into context
 func (d *Context) Set(v *value.Value) error {
+	return d.SetWithError(v, nil)
+}
+
+// SetWithError sets debug content into context, along with the error of the failed op if any
+func (d *Context) SetWithError(v *value.Value, stepErr error) error {
+	return d.SetWithTrace(v, stepErr, nil)
+}
+
+// SetWithTrace sets debug content into context, along with the error of the failed op and the trace of the executed ops
+func (d *Context) SetWithTrace(v *value.Value, stepErr error, trace []wfTypes.OpTrace) error {
 	data, err := v.String()
 	if err != nil {
 		return err
 	}
-	err = setStore(context.Background(), d.cli, d.instance, d.step, data)
+	content := map[string]string{
+		DebugKey: d.redact(data),
+	}
+	if stepErr != nil {
+		content[ErrorKey] = d.redact(stepErr.Error())
+	}
+	if len(trace) > 0 {
+		trace = truncateTrace(trace)
+		for i := range trace {
+			trace[i].Input = d.redact(trace[i].Input)
+			trace[i].Output = d.redact(trace[i].Output)
+		}
+		b, err := json.Marshal(trace)
+		if err != nil {
+			return err
+		}
+		content[TraceKey] = string(b)
+	}
+	err = setStore(context.Background(), d.cli, d.instance, d.step, content)
 	if err != nil {
 		return err
 	}
@@ -55,7 +113,7 @@ func (d *Context) Set(v *value.Value) error {
 	return nil
 }
 
-func setStore(ctx context.Context, cli client.Client, instance *wfTypes.WorkflowInstance, step, data string) error {
+func setStore(ctx context.Context, cli client.Client, instance *wfTypes.WorkflowInstance, step string, data map[string]string) error {
 	cm := &corev1.ConfigMap{}
 	if err := cli.Get(ctx, types.NamespacedName{
 		Namespace: instance.Namespace,
@@ -64,9 +122,7 @@ func setStore(ctx context.Context, cli client.Client, instance *wfTypes.Workflow
 		if errors.IsNotFound(err) {
 			cm.Name = GenerateContextName(instance.Name, step)
 			cm.Namespace = instance.Namespace
-			cm.Data = map[string]string{
-				"debug": data,
-			}
+			cm.Data = data
 			cm.SetOwnerReferences(instance.ChildOwnerReferences)
 			if err := cli.Create(ctx, cm); err != nil {
 				return err
@@ -75,9 +131,13 @@ func setStore(ctx context.Context, cli client.Client, instance *wfTypes.Workflow
 		}
 		return err
 	}
-	cm.Data = map[string]string{
-		"debug": data,
+	// the values recorded by the debug op are kept while the step value is updated
+	if values, ok := cm.Data[ValuesKey]; ok {
+		if _, set := data[ValuesKey]; !set {
+			data[ValuesKey] = values
+		}
 	}
+	cm.Data = data
 	if err := cli.Update(ctx, cm); err != nil {
 		return err
 	}
@@ -85,16 +145,135 @@ func setStore(ctx context.Context, cli client.Client, instance *wfTypes.Workflow
 	return nil
 }
 
-// NewContext new workflow context without initialize data.
-func NewContext(cli client.Client, instance *wfTypes.WorkflowInstance, step string) ContextImpl {
-	return &Context{
+// SetValue records the value by the name into the debug config map of the step, the values recorded by the
+// other names are kept. The value is truncated to MaxValueSize.
+func SetValue(ctx context.Context, cli client.Client, instance *wfTypes.WorkflowInstance, step, name, data string) error {
+	values := map[string]string{}
+	cm := &corev1.ConfigMap{}
+	err := cli.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: GenerateContextName(instance.Name, step)}, cm)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	content := map[string]string{}
+	for k, v := range cm.Data {
+		content[k] = v
+	}
+	if s := content[ValuesKey]; s != "" {
+		if err := json.Unmarshal([]byte(s), &values); err != nil {
+			return err
+		}
+	}
+	values[name] = truncate(data, MaxValueSize)
+	b, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	content[ValuesKey] = string(b)
+	return setStore(ctx, cli, instance, step, content)
+}
+
+func truncateTrace(trace []wfTypes.OpTrace) []wfTypes.OpTrace {
+	if len(trace) > MaxTraceEntries {
+		trace = trace[len(trace)-MaxTraceEntries:]
+	}
+	result := make([]wfTypes.OpTrace, len(trace))
+	for i, entry := range trace {
+		entry.Input = truncate(entry.Input, MaxTraceValueSize)
+		entry.Output = truncate(entry.Output, MaxTraceValueSize)
+		result[i] = entry
+	}
+	return result
+}
+
+// truncate cuts the value to the size at the rune boundary, so that the multi-byte characters are not split
+func truncate(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	for size > 0 && !utf8.RuneStart(s[size]) {
+		size--
+	}
+	return s[:size] + truncatedMarker
+}
+
+// redact replaces the sensitive values in the content
+func (d *Context) redact(content string) string {
+	if d.redactor == nil {
+		return content
+	}
+	return d.redactor.Replace(content)
+}
+
+// NewContext new workflow context without initialize data, the sensitive values are redacted in the debug config map.
+func NewContext(cli client.Client, instance *wfTypes.WorkflowInstance, step string, sensitive ...string) ContextImpl {
+	d := &Context{
 		cli:      cli,
 		instance: instance,
 		step:     step,
 	}
+	var pairs []string
+	for _, s := range sensitive {
+		if s == "" {
+			continue
+		}
+		pairs = append(pairs, s, redactedMarker)
+		// the values are escaped in the rendered CUE and the JSON of the trace
+		if b, err := json.Marshal(s); err == nil && string(b[1:len(b)-1]) != s {
+			pairs = append(pairs, string(b[1:len(b)-1]), redactedMarker)
+		}
+	}
+	if len(pairs) > 0 {
+		d.redactor = strings.NewReplacer(pairs...)
+	}
+	return d
 }
 
 // GenerateContextName generate context name
 func GenerateContextName(name, step string) string {
 	return fmt.Sprintf("%s-%s-debug", name, step)
 }
+
+// ParseDebugAnnotation parses the debug annotation of the workflow run.
+// The annotation can be "true" to debug the whole run, or a comma-separated list of step names
+// to debug only the specified steps.
+func ParseDebugAnnotation(annotation string) (bool, []string) {
+	annotation = strings.TrimSpace(annotation)
+	switch annotation {
+	case "", "false":
+		return false, nil
+	case "true":
+		return true, nil
+	}
+	var steps []string
+	for _, step := range strings.Split(annotation, ",") {
+		if step = strings.TrimSpace(step); step != "" {
+			steps = append(steps, step)
+		}
+	}
+	return len(steps) > 0, steps
+}
+
+// IsOpEnabled checks if the debug op records the values of the step, it's enabled if the step is debugged or
+// the debug op is enabled by the annotation of the run
+func IsOpEnabled(instance *wfTypes.WorkflowInstance, step string) bool {
+	if instance == nil {
+		return false
+	}
+	return instance.Annotations[wfTypes.AnnotationWorkflowRunDebugOp] == "true" || IsStepEnabled(instance, step)
+}
+
+// IsStepEnabled checks if the debug is enabled for the step
+func IsStepEnabled(instance *wfTypes.WorkflowInstance, step string) bool {
+	if instance == nil || !instance.Debug {
+		return false
+	}
+	if len(instance.DebugSteps) == 0 {
+		return true
+	}
+	for _, s := range instance.DebugSteps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
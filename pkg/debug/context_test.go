@@ -18,7 +18,10 @@ package debug
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/stretchr/testify/require"
@@ -67,6 +70,197 @@ test: test
 	r.NoError(err)
 }
 
+func TestSetContextWithError(t *testing.T) {
+	r := require.New(t)
+	var created *corev1.ConfigMap
+	cli := newCliForTest(nil)
+	cli.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+		created = obj.(*corev1.ConfigMap)
+		return nil
+	}
+	debugCtx := NewContext(cli, &types.WorkflowInstance{
+		WorkflowMeta: types.WorkflowMeta{
+			Name: "test",
+		},
+	}, "step2")
+	v, err := value.NewValue(`
+test: "test"
+`, nil, "")
+	r.NoError(err)
+	err = debugCtx.SetWithError(v, errors.New("op failed"))
+	r.NoError(err)
+	r.NotNil(created)
+	r.Equal("test: \"test\"\n", created.Data[DebugKey])
+	r.Equal("op failed", created.Data[ErrorKey])
+}
+
+func TestSetContextWithTrace(t *testing.T) {
+	r := require.New(t)
+	var created *corev1.ConfigMap
+	cli := newCliForTest(nil)
+	cli.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+		created = obj.(*corev1.ConfigMap)
+		return nil
+	}
+	defer func(entries, size int) {
+		MaxTraceEntries, MaxTraceValueSize = entries, size
+	}(MaxTraceEntries, MaxTraceValueSize)
+	MaxTraceEntries, MaxTraceValueSize = 3, 8
+
+	debugCtx := NewContext(cli, &types.WorkflowInstance{
+		WorkflowMeta: types.WorkflowMeta{
+			Name: "test",
+		},
+	}, "step2")
+	v, err := value.NewValue(`
+test: "test"
+`, nil, "")
+	r.NoError(err)
+	now := time.Now()
+	err = debugCtx.SetWithTrace(v, errors.New("op failed"), []types.OpTrace{
+		{Op: "kube/apply", Input: "short", Output: "short", Timestamp: now},
+		{Op: "kube/read", Input: "short", Output: "a very long output", Timestamp: now},
+		{Op: "http/do", Input: "a very long input", Error: "op failed", Timestamp: now},
+		{Op: "email/send", Input: "发送邮件", Timestamp: now},
+	})
+	r.NoError(err)
+	r.NotNil(created)
+	r.Equal("op failed", created.Data[ErrorKey])
+	var trace []types.OpTrace
+	r.NoError(json.Unmarshal([]byte(created.Data[TraceKey]), &trace))
+	r.Equal(3, len(trace))
+	r.Equal("kube/read", trace[0].Op)
+	r.Equal("short", trace[0].Input)
+	r.Equal("a very l"+truncatedMarker, trace[0].Output)
+	r.Equal("http/do", trace[1].Op)
+	r.Equal("a very l"+truncatedMarker, trace[1].Input)
+	r.Equal("op failed", trace[1].Error)
+	// the value is truncated at the rune boundary
+	r.Equal("email/send", trace[2].Op)
+	r.Equal("发送"+truncatedMarker, trace[2].Input)
+}
+
+func TestSetContextRedactsSensitiveValues(t *testing.T) {
+	r := require.New(t)
+	var created *corev1.ConfigMap
+	cli := newCliForTest(nil)
+	cli.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+		created = obj.(*corev1.ConfigMap)
+		return nil
+	}
+	debugCtx := NewContext(cli, &types.WorkflowInstance{
+		WorkflowMeta: types.WorkflowMeta{
+			Name: "test",
+		},
+	}, "step1", "s3cr3t", "multi\nline", "")
+	v, err := value.NewValue(`
+context: var: {
+	token: "s3cr3t"
+	cert:  "multi\nline"
+}
+`, nil, "")
+	r.NoError(err)
+	err = debugCtx.SetWithTrace(v, errors.New("auth with s3cr3t failed"), []types.OpTrace{
+		{Op: "http/do", Input: `{"header":{"token":"s3cr3t"}}`, Output: `{"cert":"multi\nline"}`},
+	})
+	r.NoError(err)
+	r.NotNil(created)
+	for _, data := range created.Data {
+		r.NotContains(data, "s3cr3t")
+		r.NotContains(data, `multi\nline`)
+	}
+	r.Contains(created.Data[DebugKey], redactedMarker)
+	r.Equal("auth with "+redactedMarker+" failed", created.Data[ErrorKey])
+	var trace []types.OpTrace
+	r.NoError(json.Unmarshal([]byte(created.Data[TraceKey]), &trace))
+	r.Equal(`{"header":{"token":"`+redactedMarker+`"}}`, trace[0].Input)
+	r.Equal(`{"cert":"`+redactedMarker+`"}`, trace[0].Output)
+}
+
+func TestSetContextKeepsValues(t *testing.T) {
+	r := require.New(t)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: GenerateContextName("test", "step1")},
+		Data:       map[string]string{DebugKey: "test", ValuesKey: `{"value":"1\n"}`},
+	}
+	cli := newCliForTest(cm)
+	v, err := value.NewValue(`test: "test"`, nil, "")
+	r.NoError(err)
+	r.NoError(NewContext(cli, &types.WorkflowInstance{WorkflowMeta: types.WorkflowMeta{Name: "test"}}, "step1").Set(v))
+	r.Equal("test: \"test\"\n", cm.Data[DebugKey])
+	r.Equal(`{"value":"1\n"}`, cm.Data[ValuesKey])
+}
+
+func TestSetValue(t *testing.T) {
+	r := require.New(t)
+	defer func(size int) {
+		MaxValueSize = size
+	}(MaxValueSize)
+	MaxValueSize = 8
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: GenerateContextName("test", "step1")},
+		Data:       map[string]string{DebugKey: "test", ValuesKey: `{"value":"1\n"}`},
+	}
+	cli := newCliForTest(cm)
+	instance := &types.WorkflowInstance{WorkflowMeta: types.WorkflowMeta{Name: "test"}}
+	r.NoError(SetValue(context.Background(), cli, instance, "step1", "short", "short"))
+	// the value is truncated at the rune boundary
+	r.NoError(SetValue(context.Background(), cli, instance, "step1", "long", "发送邮件"))
+	values := map[string]string{}
+	r.NoError(json.Unmarshal([]byte(cm.Data[ValuesKey]), &values))
+	r.Equal(map[string]string{"value": "1\n", "short": "short", "long": "发送" + truncatedMarker}, values)
+	r.Equal("test", cm.Data[DebugKey])
+}
+
+func TestParseDebugAnnotation(t *testing.T) {
+	r := require.New(t)
+	testCases := map[string]struct {
+		annotation string
+		enabled    bool
+		steps      []string
+	}{
+		"empty": {
+			annotation: "",
+		},
+		"false": {
+			annotation: "false",
+		},
+		"whole run": {
+			annotation: "true",
+			enabled:    true,
+		},
+		"single step": {
+			annotation: "step-a",
+			enabled:    true,
+			steps:      []string{"step-a"},
+		},
+		"multiple steps": {
+			annotation: "step-a, step-c,",
+			enabled:    true,
+			steps:      []string{"step-a", "step-c"},
+		},
+		"only separators": {
+			annotation: ",,",
+		},
+	}
+	for name, tc := range testCases {
+		enabled, steps := ParseDebugAnnotation(tc.annotation)
+		r.Equal(tc.enabled, enabled, name)
+		r.Equal(tc.steps, steps, name)
+	}
+}
+
+func TestIsStepEnabled(t *testing.T) {
+	r := require.New(t)
+	r.False(IsStepEnabled(nil, "step-a"))
+	r.False(IsStepEnabled(&types.WorkflowInstance{}, "step-a"))
+	r.True(IsStepEnabled(&types.WorkflowInstance{Debug: true}, "step-a"))
+	instance := &types.WorkflowInstance{Debug: true, DebugSteps: []string{"step-a", "step-c"}}
+	r.True(IsStepEnabled(instance, "step-a"))
+	r.False(IsStepEnabled(instance, "step-b"))
+	r.True(IsStepEnabled(instance, "step-c"))
+}
+
 func newCliForTest(wfCm *corev1.ConfigMap) *test.MockClient {
 	return &test.MockClient{
 		MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
@@ -0,0 +1,276 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package definition
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	cueerrors "cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/token"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/kubevela/workflow/pkg/cue/model"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/cue/packages"
+	"github.com/kubevela/workflow/pkg/generator"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// AnnotationAllowedProviders is the annotation of the step definition with the comma separated names of the
+	// providers registered at runtime, the ops of these providers are not checked.
+	AnnotationAllowedProviders = "definition.oam.dev/allowed-providers"
+
+	// stepsOp is the op executing the nested steps, which is handled by the task instead of the providers
+	stepsOp = "steps"
+	// defaultProvider is the provider of the ops without #provider
+	defaultProvider = "builtin"
+	// maxDepth is the max depth of the fields to look for the ops
+	maxDepth = 32
+)
+
+// Issue is a problem found in the template
+type Issue struct {
+	// Pos is the position of the problem in the template as `line:column`, it's empty if unknown
+	Pos     string
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Pos == "" {
+		return i.Message
+	}
+	return fmt.Sprintf("%s: %s", i.Pos, i.Message)
+}
+
+// ValidationError is the error with the issues found in the template
+type ValidationError struct {
+	Issues []Issue
+}
+
+// Error implements the Error interface.
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		lines = append(lines, issue.String())
+	}
+	return "invalid template: " + strings.Join(lines, "; ")
+}
+
+// Validator validates the templates of the step definitions against the schemas and the ops of the providers
+type Validator struct {
+	pd       *packages.PackageDiscover
+	registry types.ProviderRegistry
+}
+
+// NewValidator creates the validator with the custom packages and the registry of the extra providers,
+// the default registry is used if the registry is nil.
+func NewValidator(pd *packages.PackageDiscover, registry types.ProviderRegistry) *Validator {
+	return &Validator{pd: pd, registry: registry}
+}
+
+// ValidateDefinition validates the template of the step definition, the providers in the annotation
+// definition.oam.dev/allowed-providers are allowed.
+func (v *Validator) ValidateDefinition(def *unstructured.Unstructured) error {
+	templ, _, err := unstructured.NestedString(def.Object, "spec", "schematic", "cue", "template")
+	if err != nil {
+		return errors.WithMessage(err, "invalid workflow step definition")
+	}
+	var allowed []string
+	for _, name := range strings.Split(def.GetAnnotations()[AnnotationAllowedProviders], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed = append(allowed, name)
+		}
+	}
+	return v.Validate(templ, allowed...)
+}
+
+// Validate compiles the template and checks that the references to the imported packages and every
+// `#do`/`#provider` pair resolve, it returns the *ValidationError with the positions of the issues.
+// The ops of the allowed providers are not checked.
+func (v *Validator) Validate(templ string, allowedProviders ...string) error {
+	file, err := parser.ParseFile("-", templ, parser.ParseComments)
+	if err != nil {
+		return &ValidationError{Issues: issuesOf(err)}
+	}
+	// the context and the parameter are filled when the step is executed
+	src := strings.Join([]string{templ, "context: {...}", model.ParameterFieldName + ": {...}"}, "\n")
+	val, err := value.NewValue(src, v.pd, "", value.ProcessScript)
+	if err != nil {
+		return &ValidationError{Issues: issuesOf(err)}
+	}
+	issues := v.checkImports(file)
+	issues = append(issues, issuesOf(val.CueValue().Validate())...)
+
+	ops := map[string]map[string]bool{}
+	for provider, names := range generator.RegisteredOps(v.registry) {
+		ops[provider] = map[string]bool{}
+		for _, name := range names {
+			ops[provider][name] = true
+		}
+	}
+	for _, provider := range allowedProviders {
+		ops[provider] = nil
+	}
+	issues = append(issues, checkOps(val.CueValue(), ops, 0)...)
+	if len(issues) > 0 {
+		return &ValidationError{Issues: issues}
+	}
+	return nil
+}
+
+// checkImports checks the fields of the imported packages referred by the template exist
+func (v *Validator) checkImports(file *ast.File) []Issue {
+	imports := map[string]string{}
+	for _, spec := range file.Imports {
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path.Base(p)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = p
+	}
+	if len(imports) == 0 {
+		return nil
+	}
+	pkgs := map[string]cue.Value{}
+	var issues []Issue
+	ast.Walk(file, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		x, ok := sel.X.(*ast.Ident)
+		if !ok || imports[x.Name] == "" {
+			return true
+		}
+		label, _, err := ast.LabelName(sel.Sel)
+		if err != nil {
+			return true
+		}
+		pkg, ok := pkgs[x.Name]
+		if !ok {
+			pv, err := value.NewValue(fmt.Sprintf("import pkg %q\nv: pkg", imports[x.Name]), v.pd, "")
+			if err != nil {
+				return true
+			}
+			pkg = pv.CueValue().LookupPath(cue.ParsePath("v"))
+			pkgs[x.Name] = pkg
+		}
+		selector := cue.Str(label)
+		if strings.HasPrefix(label, "#") {
+			selector = cue.Def(label)
+		}
+		if !pkg.LookupPath(cue.MakePath(selector)).Exists() {
+			issues = append(issues, Issue{
+				Pos:     position(sel.Sel.Pos()),
+				Message: fmt.Sprintf("reference %s.%s not found in package %q", x.Name, label, imports[x.Name]),
+			})
+		}
+		return true
+	}, nil)
+	return issues
+}
+
+// checkOps checks every `#do`/`#provider` pair in the value resolves to a registered op, the ops of the
+// providers with nil ops are allowed.
+func checkOps(v cue.Value, ops map[string]map[string]bool, depth int) []Issue {
+	if depth > maxDepth {
+		return nil
+	}
+	var issues []Issue
+	switch v.IncompleteKind() {
+	case cue.StructKind:
+		if do, err := v.LookupPath(value.FieldPath("#do")).String(); err == nil {
+			provider, err := v.LookupPath(value.FieldPath("#provider")).String()
+			if err != nil {
+				provider = defaultProvider
+			}
+			if msg := checkOp(ops, provider, do); msg != "" {
+				issues = append(issues, Issue{Pos: position(v.Pos()), Message: msg})
+			}
+		}
+		iter, err := v.Fields(cue.Definitions(true), cue.Optional(true))
+		if err != nil {
+			return issues
+		}
+		for iter.Next() {
+			issues = append(issues, checkOps(iter.Value(), ops, depth+1)...)
+		}
+	case cue.ListKind:
+		iter, err := v.List()
+		if err != nil {
+			return issues
+		}
+		for iter.Next() {
+			issues = append(issues, checkOps(iter.Value(), ops, depth+1)...)
+		}
+	default:
+	}
+	return issues
+}
+
+func checkOp(ops map[string]map[string]bool, provider, do string) string {
+	if do == stepsOp {
+		return ""
+	}
+	names, ok := ops[provider]
+	switch {
+	case !ok:
+		return fmt.Sprintf("provider %s of op %s is not found, add it to the annotation %s if it's registered at runtime", provider, do, AnnotationAllowedProviders)
+	case names == nil || names[do]:
+		return ""
+	default:
+		return fmt.Sprintf("op %s is not found in provider %s", do, provider)
+	}
+}
+
+func issuesOf(err error) []Issue {
+	if err == nil {
+		return nil
+	}
+	var issues []Issue
+	for _, e := range cueerrors.Errors(err) {
+		format, args := e.Msg()
+		msg := fmt.Sprintf(format, args...)
+		if p := e.Path(); len(p) > 0 {
+			msg = strings.Join(p, ".") + ": " + msg
+		}
+		issues = append(issues, Issue{Pos: position(e.Position()), Message: msg})
+	}
+	if len(issues) == 0 {
+		issues = append(issues, Issue{Message: err.Error()})
+	}
+	return issues
+}
+
+func position(pos token.Pos) string {
+	if !pos.IsValid() {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", pos.Line(), pos.Column())
+}
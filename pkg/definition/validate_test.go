@@ -0,0 +1,136 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package definition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := map[string]struct {
+		templ   string
+		allowed []string
+		issues  []string
+	}{
+		"valid": {
+			templ: `import "vela/op"
+
+apply: op.#Apply & {
+	value: parameter.value
+}
+wait: op.#ConditionalWait & {
+	continue: apply.value.status.ready == true
+}
+parameter: value: {...}
+`,
+		},
+		"unresolved reference": {
+			templ: `import "vela/op"
+
+apply: op.#Appy & {
+	value: {}
+}
+`,
+			issues: []string{`3:11: reference op.#Appy not found in package "vela/op"`},
+		},
+		"conflict": {
+			templ: `msg: "a"
+msg: {}
+`,
+			issues: []string{"msg: conflicting values"},
+		},
+		"unknown provider": {
+			templ: `call: {
+	#provider: "custom"
+	#do:       "run"
+}
+`,
+			issues: []string{"1:1: provider custom of op run is not found"},
+		},
+		"allowed provider": {
+			templ: `call: {
+	#provider: "custom"
+	#do:       "run"
+}
+`,
+			allowed: []string{"custom"},
+		},
+		"unknown op": {
+			templ: `call: {
+	#provider: "kube"
+	#do:       "explode"
+}
+`,
+			issues: []string{"1:1: op explode is not found in provider kube"},
+		},
+		"nested op": {
+			templ: `deploy: {
+	#do: "steps"
+	up: {
+		#provider: "kube"
+		#do:       "up"
+	}
+}
+`,
+			issues: []string{"3:2: op up is not found in provider kube"},
+		},
+	}
+	v := NewValidator(nil, nil)
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			err := v.Validate(tc.templ, tc.allowed...)
+			if len(tc.issues) == 0 {
+				r.NoError(err)
+				return
+			}
+			r.Error(err)
+			verr, ok := err.(*ValidationError)
+			r.True(ok)
+			r.Equal(len(tc.issues), len(verr.Issues), verr.Error())
+			for i, issue := range tc.issues {
+				r.Contains(verr.Issues[i].String(), issue)
+			}
+		})
+	}
+}
+
+func TestValidateDefinition(t *testing.T) {
+	r := require.New(t)
+	def := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "core.oam.dev/v1beta1",
+		"kind":       "WorkflowStepDefinition",
+		"metadata": map[string]interface{}{
+			"name":        "custom",
+			"annotations": map[string]interface{}{AnnotationAllowedProviders: "custom, other"},
+		},
+		"spec": map[string]interface{}{
+			"schematic": map[string]interface{}{
+				"cue": map[string]interface{}{
+					"template": "call: {\n\t#provider: \"custom\"\n\t#do: \"run\"\n}\n",
+				},
+			},
+		},
+	}}
+	v := NewValidator(nil, nil)
+	r.NoError(v.ValidateDefinition(def))
+	def.SetAnnotations(nil)
+	r.Error(v.ValidateDefinition(def))
+}
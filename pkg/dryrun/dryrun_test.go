@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestClient(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	}
+	base := fake.NewClientBuilder().WithObjects(existing).Build()
+	cli := Client(base)
+	r.True(IsDryRun(cli))
+	r.False(IsDryRun(base))
+	r.Equal(cli, Client(cli))
+
+	created := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "created", Namespace: "default"}}
+	r.NoError(cli.Create(ctx, created))
+	err := base.Get(ctx, client.ObjectKeyFromObject(created), &corev1.ConfigMap{})
+	r.True(kerrors.IsNotFound(err))
+
+	cm := &corev1.ConfigMap{}
+	r.NoError(cli.Get(ctx, client.ObjectKeyFromObject(existing), cm))
+	cm.Data["key"] = "updated"
+	r.NoError(cli.Update(ctx, cm))
+	patched := cm.DeepCopy()
+	patched.Data["key"] = "patched"
+	r.NoError(cli.Patch(ctx, patched, client.MergeFrom(cm)))
+
+	r.NoError(base.Get(ctx, client.ObjectKeyFromObject(existing), cm))
+	r.Equal("value", cm.Data["key"])
+}
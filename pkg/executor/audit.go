@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// AuditLogStdout is the destination of the audit log writing the records to stdout
+const AuditLogStdout = "stdout"
+
+// WithAuditSink writes the audit records of the ops executed by the steps to the sink.
+func WithAuditSink(sink types.AuditSink) Option {
+	return func(w *workflowExecutor) {
+		w.auditSink = sink
+	}
+}
+
+// jsonLinesAuditSink writes the audit records as JSON lines
+type jsonLinesAuditSink struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+// NewJSONLinesAuditSink creates the audit sink writing each record as a JSON line to the writer.
+func NewJSONLinesAuditSink(w io.Writer) types.AuditSink {
+	return &jsonLinesAuditSink{w: w}
+}
+
+// Write implements the AuditSink interface.
+func (s *jsonLinesAuditSink) Write(record types.AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// OpenAuditSink opens the JSON lines audit sink writing to stdout or appending to the file of the path,
+// the file is created if it doesn't exist.
+func OpenAuditSink(path string) (types.AuditSink, error) {
+	if path == AuditLogStdout {
+		return NewJSONLinesAuditSink(os.Stdout), nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.WithMessage(err, "open the audit log")
+	}
+	return NewJSONLinesAuditSink(file), nil
+}
+
+// auditFunc returns the function filling the audit records with the identity of the workflow run and writing them
+// to the sink, it's nil if the audit is disabled.
+func (e *engine) auditFunc() func(record types.AuditRecord) {
+	if e.auditSink == nil {
+		return nil
+	}
+	return func(record types.AuditRecord) {
+		record.RunUID = string(e.instance.UID)
+		record.Namespace = e.instance.Namespace
+		record.Name = e.instance.Name
+		record.Creator = e.instance.Annotations[types.AnnotationWorkflowRunCreator]
+		record.Correlation = e.instance.Correlation
+		if err := e.auditSink.Write(record); err != nil {
+			e.monitorCtx.Error(err, "write the audit record", "step", record.Step, "op", record.Op)
+		}
+	}
+}
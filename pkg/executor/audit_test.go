@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestAuditSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := OpenAuditSink(path)
+	require.NoError(t, err)
+	e := &engine{
+		monitorCtx: monitorContext.NewTraceContext(context.Background(), "test-app"),
+		instance: &types.WorkflowInstance{
+			WorkflowMeta: types.WorkflowMeta{
+				Name:        "test-run",
+				Namespace:   "default",
+				UID:         "test-uid",
+				Annotations: map[string]string{types.AnnotationWorkflowRunCreator: "alice"},
+			},
+			Correlation: map[string]string{"trace-id": "abc"},
+		},
+		auditSink: sink,
+	}
+	audit := e.auditFunc()
+	require.NotNil(t, audit)
+	audit(types.AuditRecord{
+		Timestamp: time.Now(),
+		Step:      "apply",
+		Op:        "kube/apply",
+		Targets: []types.AuditTarget{
+			{"apiVersion": "v1", "kind": "ConfigMap", "namespace": "default", "name": "test", "cluster": "local"},
+		},
+		Outcome:        types.AuditOutcomeSucceeded,
+		DurationMillis: 10,
+	})
+	audit(types.AuditRecord{
+		Timestamp:      time.Now(),
+		Step:           "notify",
+		Op:             "http/do",
+		Targets:        []types.AuditTarget{{"method": "POST", "host": "example.com"}},
+		Outcome:        types.AuditOutcomeFailed,
+		DurationMillis: 20,
+	})
+
+	// the records are appended to the existing file
+	sink, err = OpenAuditSink(path)
+	require.NoError(t, err)
+	e.auditSink = sink
+	e.auditFunc()(types.AuditRecord{Timestamp: time.Now(), Step: "apply", Op: "kube/read", Outcome: types.AuditOutcomeSucceeded})
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var records []types.AuditRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := types.AuditRecord{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.Equal(t, 3, len(records))
+	for _, record := range records {
+		require.Equal(t, "test-uid", record.RunUID)
+		require.Equal(t, "default", record.Namespace)
+		require.Equal(t, "test-run", record.Name)
+		require.Equal(t, "alice", record.Creator)
+		require.Equal(t, map[string]string{"trace-id": "abc"}, record.Correlation)
+	}
+	require.Equal(t, "ConfigMap", records[0].Targets[0]["kind"])
+	require.Equal(t, types.AuditOutcomeFailed, records[1].Outcome)
+	require.Equal(t, "example.com", records[1].Targets[0]["host"])
+	require.Equal(t, "kube/read", records[2].Op)
+
+	e.auditSink = nil
+	require.Nil(t, e.auditFunc())
+}
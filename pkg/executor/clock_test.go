@@ -0,0 +1,111 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	testingclock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// tick executes the workflow run for a single reconcile like the controller, then advances the fake clock by the
+// time the controller waits before the next reconcile, which is returned. It steps the run deterministically in the
+// tests of the timeouts and the backoff without waiting for the wall clock. The runners are generated for each tick
+// like the reconciles.
+func tick(ctx monitorContext.Context, fakeClock *testingclock.FakeClock, instance *types.WorkflowInstance, cli client.Client, runners []types.TaskRunner, opts ...Option) (v1alpha1.WorkflowRunPhase, time.Duration, error) {
+	w := New(instance, cli, append(opts, WithClock(fakeClock))...)
+	phase, err := w.ExecuteRunners(ctx, runners)
+	if err != nil {
+		return phase, 0, err
+	}
+	var wait time.Duration
+	switch phase {
+	case v1alpha1.WorkflowStateExecuting:
+		wait = w.GetBackoffWaitTime()
+	case v1alpha1.WorkflowStateSuspending:
+		wait = w.GetSuspendBackoffWaitTime()
+	}
+	fakeClock.Step(wait)
+	return phase, wait, nil
+}
+
+func TestTick(t *testing.T) {
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+	testCases := map[string]struct {
+		steps    []v1alpha1.WorkflowStep
+		timeout  string
+		expected time.Duration
+	}{
+		"step timeout": {
+			steps: []v1alpha1.WorkflowStep{
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "running", Timeout: "10m"}},
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+			},
+			expected: 10 * time.Minute,
+		},
+		"workflow timeout": {
+			steps: []v1alpha1.WorkflowStep{
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "running"}},
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+			},
+			timeout:  "1h",
+			expected: time.Hour,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			instance, runners := makeTestCase(tc.steps)
+			instance.Name = "tick"
+			instance.Timeout = tc.timeout
+			defer wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+			start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+			fakeClock := testingclock.NewFakeClock(start)
+
+			phase, wait, err := tick(ctx, fakeClock, instance, cli, runners)
+			r.NoError(err)
+			r.Equal(v1alpha1.WorkflowStateExecuting, phase)
+			r.Equal(v1alpha1.WorkflowStepPhaseRunning, instance.Status.Steps[0].Phase)
+			r.True(wait > 0)
+			for i := 0; i < 100 && phase == v1alpha1.WorkflowStateExecuting; i++ {
+				_, runners = makeTestCase(tc.steps)
+				phase, _, err = tick(ctx, fakeClock, instance, cli, runners)
+				r.NoError(err)
+			}
+			r.Equal(v1alpha1.WorkflowStateFailed, phase)
+			r.Equal(v1alpha1.WorkflowStepPhaseFailed, instance.Status.Steps[0].Phase)
+			r.Equal(types.StatusReasonTimeout, instance.Status.Steps[0].Reason)
+			r.Equal(start, instance.Status.StartTime.Time)
+			// the run is requeued at the timeout instead of polling for it
+			r.True(fakeClock.Since(start) >= tc.expected)
+			r.True(fakeClock.Since(start) < tc.expected+time.Duration(types.MaxWorkflowWaitBackoffTime)*time.Second)
+		})
+	}
+}
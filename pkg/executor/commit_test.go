@@ -0,0 +1,199 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// updateCountingClient counts the updates of the ConfigMaps, the updates fail with err if it's set
+type updateCountingClient struct {
+	client.Client
+	updates int
+	err     error
+}
+
+func (c *updateCountingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if _, ok := obj.(*corev1.ConfigMap); ok {
+		if c.err != nil {
+			return c.err
+		}
+		c.updates++
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// opsRunner writes a var into the context for each op like the steps doing many DoVar ops, the step yields with
+// wait after the ops if wait is set
+type opsRunner struct {
+	name string
+	ops  int
+	wait bool
+}
+
+func (o *opsRunner) Name() string {
+	return o.name
+}
+
+func (o *opsRunner) Pending(ctx monitorContext.Context, wfCtx wfContext.Context, stepStatus map[string]v1alpha1.StepStatus) (bool, v1alpha1.StepStatus) {
+	return false, v1alpha1.StepStatus{}
+}
+
+func (o *opsRunner) Run(ctx wfContext.Context, options *types.TaskRunOptions) (v1alpha1.StepStatus, *types.Operation, error) {
+	for i := 0; i < o.ops; i++ {
+		v, err := ctx.MakeParameter(fmt.Sprintf("%d", i))
+		if err != nil {
+			return v1alpha1.StepStatus{}, nil, err
+		}
+		if err := ctx.SetVar(v, o.name, fmt.Sprintf("op%d", i)); err != nil {
+			return v1alpha1.StepStatus{}, nil, err
+		}
+	}
+	if o.wait {
+		return v1alpha1.StepStatus{
+			ID:     o.name,
+			Name:   o.name,
+			Type:   "ops",
+			Phase:  v1alpha1.WorkflowStepPhaseRunning,
+			Reason: types.StatusReasonWait,
+		}, &types.Operation{Waiting: true}, nil
+	}
+	return v1alpha1.StepStatus{
+		ID:    o.name,
+		Name:  o.name,
+		Type:  "ops",
+		Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+	}, &types.Operation{}, nil
+}
+
+func TestCommitOncePerReconcile(t *testing.T) {
+	r := require.New(t)
+	cli := &updateCountingClient{Client: fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()}
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+	steps := []v1alpha1.WorkflowStep{{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "ops"},
+	}}
+	instance, _ := makeTestCase(steps)
+	wfCtx, err := wfContext.NewContext(cli, instance.Namespace, instance.Name, instance.ChildOwnerReferences)
+	r.NoError(err)
+	// the run has started with the context created
+	instance.Status.StartTime = metav1.Now()
+	instance.Status.ContextBackend = wfCtx.StoreRef()
+	defer wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+
+	cli.updates = 0
+	state, err := New(instance, cli).ExecuteRunners(ctx, []types.TaskRunner{&opsRunner{name: "s1", ops: 10}})
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, state)
+	r.Equal(1, cli.updates)
+
+	wfCtx, err = wfContext.LoadContext(cli, instance.Namespace, instance.Name, instance.Status.ContextBackend.Name)
+	r.NoError(err)
+	for i := 0; i < 10; i++ {
+		v, err := wfCtx.GetVar("s1", fmt.Sprintf("op%d", i))
+		r.NoError(err)
+		n, err := v.CueValue().Int64()
+		r.NoError(err)
+		r.Equal(int64(i), n)
+	}
+
+	// the steps executed in the same reconcile are committed together as well
+	next, _ := makeTestCase(append(steps, []v1alpha1.WorkflowStep{
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "ops"}},
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s3", Type: "ops"}},
+	}...))
+	next.Status = *instance.Status.DeepCopy()
+	cli.updates = 0
+	state, err = New(next, cli).ExecuteRunners(ctx, []types.TaskRunner{
+		&opsRunner{name: "s1", ops: 10}, &opsRunner{name: "s2", ops: 10}, &opsRunner{name: "s3", ops: 10},
+	})
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, state)
+	r.Len(next.Status.Steps, 3)
+	r.Equal(1, cli.updates)
+}
+
+func TestCommitOnWait(t *testing.T) {
+	r := require.New(t)
+	cli := &updateCountingClient{Client: fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()}
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+	instance, _ := makeTestCase([]v1alpha1.WorkflowStep{
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "ops"}},
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "ops"}},
+	})
+	instance.Name = "commit-on-wait"
+	wfCtx, err := wfContext.NewContext(cli, instance.Namespace, instance.Name, instance.ChildOwnerReferences)
+	r.NoError(err)
+	instance.Status.StartTime = metav1.Now()
+	instance.Status.Mode.Steps = v1alpha1.WorkflowModeDAG
+	instance.Status.ContextBackend = wfCtx.StoreRef()
+	defer wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+
+	// the waiting step commits its writes, the writes of the other step are committed at the end
+	cli.updates = 0
+	state, err := New(instance, cli).ExecuteRunners(ctx, []types.TaskRunner{
+		&opsRunner{name: "s1", ops: 10, wait: true}, &opsRunner{name: "s2", ops: 10},
+	})
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateExecuting, state)
+	r.Equal(2, cli.updates)
+}
+
+func TestCommitFailureKeepsStatus(t *testing.T) {
+	r := require.New(t)
+	cli := &updateCountingClient{Client: fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()}
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+	instance, _ := makeTestCase([]v1alpha1.WorkflowStep{
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "ops"}},
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "ops"}},
+	})
+	instance.Name = "commit-failure"
+	wfCtx, err := wfContext.NewContext(cli, instance.Namespace, instance.Name, instance.ChildOwnerReferences)
+	r.NoError(err)
+	instance.Status.StartTime = metav1.Now()
+	instance.Status.ContextBackend = wfCtx.StoreRef()
+	defer wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+
+	cli.err = errors.New("conflict")
+	_, err = New(instance, cli).ExecuteRunners(ctx, []types.TaskRunner{&opsRunner{name: "s1", ops: 10}, &opsRunner{name: "s2", ops: 10}})
+	r.Error(err)
+	r.Contains(err.Error(), "commit workflow context")
+	r.Empty(instance.Status.Steps)
+	r.NotNil(instance.Status.ContextBackend)
+
+	cli.err = nil
+	state, err := New(instance, cli).ExecuteRunners(ctx, []types.TaskRunner{&opsRunner{name: "s1", ops: 10}, &opsRunner{name: "s2", ops: 10}})
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, state)
+	r.Len(instance.Status.Steps, 2)
+}
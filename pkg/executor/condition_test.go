@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestTerminatedCondition(t *testing.T) {
+	testCases := map[string]struct {
+		steps   []v1alpha1.WorkflowStepStatus
+		reason  string
+		message string
+	}{
+		"succeeded": {
+			steps: []v1alpha1.WorkflowStepStatus{{StepStatus: v1alpha1.StepStatus{Name: "s1", Phase: v1alpha1.WorkflowStepPhaseSucceeded}}},
+		},
+		"failed": {
+			steps: []v1alpha1.WorkflowStepStatus{
+				{StepStatus: v1alpha1.StepStatus{Name: "s1", Phase: v1alpha1.WorkflowStepPhaseSucceeded}},
+				{StepStatus: v1alpha1.StepStatus{Name: "s2", Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonFailedAfterRetries, Message: "boom"}},
+			},
+			reason:  v1alpha1.TerminatedReasonStepFailed,
+			message: "initiated by step s2: boom",
+		},
+		"timeout": {
+			steps: []v1alpha1.WorkflowStepStatus{
+				{StepStatus: v1alpha1.StepStatus{Name: "s1", Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonExecute}},
+				{StepStatus: v1alpha1.StepStatus{Name: "s2", Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonTimeout}},
+			},
+			reason:  v1alpha1.TerminatedReasonTimeout,
+			message: "initiated by step s2",
+		},
+		"break in sub step": {
+			steps: []v1alpha1.WorkflowStepStatus{{
+				StepStatus: v1alpha1.StepStatus{Name: "group", Phase: v1alpha1.WorkflowStepPhaseSucceeded, Reason: types.StatusReasonTerminate},
+				SubStepsStatus: []v1alpha1.StepStatus{
+					{Name: "sub1", Phase: v1alpha1.WorkflowStepPhaseSucceeded},
+					{Name: "sub2", Phase: v1alpha1.WorkflowStepPhaseSucceeded, Reason: types.StatusReasonTerminate, Message: "stop"},
+				},
+			}},
+			reason:  v1alpha1.TerminatedReasonStepBreak,
+			message: "initiated by step sub2: stop",
+		},
+		"terminated by user": {
+			steps: []v1alpha1.WorkflowStepStatus{
+				{StepStatus: v1alpha1.StepStatus{Name: "s1", Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonExecute}},
+				{StepStatus: v1alpha1.StepStatus{Name: "s2", Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonTerminate}},
+			},
+			reason:  v1alpha1.TerminatedReasonUserTerminated,
+			message: "initiated by step s2",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			cond, ok := TerminatedCondition(&v1alpha1.WorkflowRunStatus{Steps: tc.steps})
+			r.Equal(tc.reason != "", ok)
+			if !ok {
+				return
+			}
+			r.Equal(v1alpha1.WorkflowRunTerminatedConditionType, string(cond.Type))
+			r.Equal(tc.reason, string(cond.Reason))
+			r.Equal(tc.message, cond.Message)
+		})
+	}
+}
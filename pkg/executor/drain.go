@@ -0,0 +1,139 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// Gate admits the steps to start. After the drain begins, the steps that are not started are not admitted, so
+// that the controller is stopped without starting the steps whose status can't be persisted before it exits.
+type Gate struct {
+	clock clock.Clock
+
+	mu       sync.Mutex
+	draining bool
+	inflight int
+	drained  chan struct{}
+	expired  chan struct{}
+}
+
+// NewGate creates the gate of the steps
+func NewGate(clk clock.Clock) *Gate {
+	return &Gate{clock: clk, expired: make(chan struct{})}
+}
+
+// Context returns the context of an in-flight execution. The parent is cancelled as soon as the manager begins to
+// stop, which is when the drain begins, so the returned context keeps the values of the parent but is not cancelled
+// with it. It's cancelled when the drain times out instead, so that the in-flight executions can still commit.
+func (g *Gate) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(detachedContext{parent})
+	go func() {
+		select {
+		case <-g.expired:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Enter records an in-flight execution of the workflow run, it must be paired with Leave
+func (g *Gate) Enter() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.inflight++
+}
+
+// Leave records the end of an in-flight execution of the workflow run
+func (g *Gate) Leave() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.inflight--
+	if g.inflight == 0 && g.drained != nil {
+		close(g.drained)
+		g.drained = nil
+	}
+}
+
+// Admit returns whether the steps that are not started can start
+func (g *Gate) Admit() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return !g.draining
+}
+
+// Drain stops admitting the steps that are not started, and waits for the in-flight executions to finish
+// until the timeout. It returns false if the executions are not finished before the timeout.
+func (g *Gate) Drain(timeout time.Duration) bool {
+	g.mu.Lock()
+	g.draining = true
+	if g.inflight == 0 {
+		g.mu.Unlock()
+		return true
+	}
+	if g.drained == nil {
+		g.drained = make(chan struct{})
+	}
+	drained := g.drained
+	g.mu.Unlock()
+	select {
+	case <-drained:
+		return true
+	case <-g.clock.After(timeout):
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		select {
+		case <-g.expired:
+		default:
+			close(g.expired)
+		}
+		return false
+	}
+}
+
+// detachedContext keeps the values of the parent without its deadline and cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// WithGate admits the steps to start by the gate, the steps that are not started are not executed after it's drained.
+func WithGate(gate *Gate) Option {
+	return func(w *workflowExecutor) {
+		w.gate = gate
+	}
+}
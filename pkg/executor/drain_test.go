@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestGateDrain(t *testing.T) {
+	r := require.New(t)
+	clk := clocktesting.NewFakeClock(time.Now())
+
+	gate := NewGate(clk)
+	r.True(gate.Admit())
+	r.True(gate.Drain(time.Minute))
+	r.False(gate.Admit())
+
+	// the drain waits for the in-flight executions
+	gate = NewGate(clk)
+	gate.Enter()
+	drained := make(chan bool)
+	go func() { drained <- gate.Drain(time.Minute) }()
+	r.Eventually(func() bool { return !gate.Admit() }, time.Second, time.Millisecond)
+	gate.Leave()
+	r.True(<-drained)
+
+	// the drain gives up after the timeout
+	gate = NewGate(clk)
+	gate.Enter()
+	go func() { drained <- gate.Drain(time.Minute) }()
+	r.Eventually(clk.HasWaiters, time.Second, time.Millisecond)
+	clk.Step(30 * time.Second)
+	select {
+	case <-drained:
+		r.Fail("the drain returns before the timeout")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clk.Step(30 * time.Second)
+	r.False(<-drained)
+}
+
+func TestGateContext(t *testing.T) {
+	r := require.New(t)
+	clk := clocktesting.NewFakeClock(time.Now())
+	type key struct{}
+
+	// the context is not cancelled with the parent, but after the drain timeout
+	gate := NewGate(clk)
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	ctx, done := gate.Context(parent)
+	defer done()
+	r.Equal("value", ctx.Value(key{}))
+	cancel()
+	gate.Enter()
+	drained := make(chan bool)
+	go func() { drained <- gate.Drain(time.Minute) }()
+	r.Eventually(clk.HasWaiters, time.Second, time.Millisecond)
+	r.NoError(ctx.Err())
+	clk.Step(time.Minute)
+	r.False(<-drained)
+	r.Eventually(func() bool { return ctx.Err() != nil }, time.Second, time.Millisecond)
+
+	// the context of the execution started after the timeout is cancelled directly
+	ctx, done = gate.Context(context.Background())
+	defer done()
+	r.Eventually(func() bool { return ctx.Err() != nil }, time.Second, time.Millisecond)
+}
+
+// drainingRunner starts the drain of the gate when the step is running, like the controller receives SIGTERM
+type drainingRunner struct {
+	types.TaskRunner
+	gate    *Gate
+	drained chan bool
+}
+
+func (d *drainingRunner) Run(ctx wfContext.Context, options *types.TaskRunOptions) (v1alpha1.StepStatus, *types.Operation, error) {
+	go func() { d.drained <- d.gate.Drain(time.Minute) }()
+	for d.gate.Admit() {
+		time.Sleep(time.Millisecond)
+	}
+	return d.TaskRunner.Run(ctx, options)
+}
+
+type countingRunner struct {
+	types.TaskRunner
+	runs int
+}
+
+func (c *countingRunner) Run(ctx wfContext.Context, options *types.TaskRunOptions) (v1alpha1.StepStatus, *types.Operation, error) {
+	c.runs++
+	return c.TaskRunner.Run(ctx, options)
+}
+
+func TestDrainStopsStartingSteps(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+	steps := []v1alpha1.WorkflowStep{
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "success"}},
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+	}
+
+	gate := NewGate(clocktesting.NewFakeClock(time.Now()))
+	instance, runners := makeTestCase(steps)
+	drained := make(chan bool)
+	runners[0] = &drainingRunner{TaskRunner: runners[0], gate: gate, drained: drained}
+	gate.Enter()
+	state, err := New(instance, cli, WithGate(gate)).ExecuteRunners(ctx, runners)
+	r.NoError(err)
+	gate.Leave()
+	r.True(<-drained)
+	// the running step is finished, the next step is not started during the drain
+	r.Equal(v1alpha1.WorkflowStateExecuting, state)
+	r.Len(instance.Status.Steps, 1)
+	r.Equal("s1", instance.Status.Steps[0].Name)
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, instance.Status.Steps[0].Phase)
+
+	// the run resumes under the next leader without executing the finished step again
+	wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+	resumed, runners := makeTestCase(steps)
+	resumed.Status = *instance.Status.DeepCopy()
+	counted := &countingRunner{TaskRunner: runners[0]}
+	runners[0] = counted
+	state, err = New(resumed, cli, WithGate(NewGate(clocktesting.NewFakeClock(time.Now())))).ExecuteRunners(ctx, runners)
+	r.NoError(err)
+	r.Equal(0, counted.runs)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, state)
+	r.Len(resumed.Status.Steps, 2)
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, resumed.Status.Steps[1].Phase)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// failurePolicy returns the onFailure policy of the step, the sub steps without the policy inherit the one of
+// their step group.
+func (e *engine) failurePolicy(name string) v1alpha1.StepFailurePolicy {
+	for _, step := range e.instance.Steps {
+		if step.Name == name {
+			return step.OnFailure
+		}
+		for _, sub := range step.SubSteps {
+			if sub.Name != name {
+				continue
+			}
+			if sub.OnFailure != "" {
+				return sub.OnFailure
+			}
+			return step.OnFailure
+		}
+	}
+	return v1alpha1.StepFailurePolicyFail
+}
+
+// applyFailurePolicy applies the onFailure policy to the failed step. The ignored failure finishes the step without
+// terminating or suspending the run, it's applied after the retries of the step are exhausted. The terminating
+// failure terminates the run on the first failure of the step without retrying it.
+func (e *engine) applyFailurePolicy(status v1alpha1.StepStatus, operation *types.Operation) (v1alpha1.StepStatus, *types.Operation) {
+	// the termination by the user is never ignored
+	if status.Phase != v1alpha1.WorkflowStepPhaseFailed || status.Reason == types.StatusReasonTerminate {
+		return status, operation
+	}
+	if operation == nil {
+		operation = &types.Operation{}
+	}
+	switch e.failurePolicy(status.Name) {
+	case v1alpha1.StepFailurePolicyIgnore:
+		if !operation.FailedAfterRetries && !types.IsStepFinish(status.Phase, status.Reason) {
+			return status, operation
+		}
+		status.Reason = types.StatusReasonIgnored
+		return status, &types.Operation{}
+	case v1alpha1.StepFailurePolicyTerminate:
+		if status.Reason == types.StatusReasonExecute {
+			// the step is not retried, its error is final
+			status.Reason = types.StatusReasonFailedAfterRetries
+		}
+		return status, &types.Operation{Terminated: true}
+	default:
+		return status, operation
+	}
+}
+
+// dependPhase returns the phase of the step seen by the steps after or depending on it, the ignored failure is
+// regarded as succeeded.
+func (e *engine) dependPhase(name string) v1alpha1.WorkflowStepPhase {
+	status := e.stepStatus[name]
+	if types.IsStepFailureIgnored(status) {
+		return v1alpha1.WorkflowStepPhaseSucceeded
+	}
+	return status.Phase
+}
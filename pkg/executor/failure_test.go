@@ -0,0 +1,193 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestFailurePolicy(t *testing.T) {
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+	// executeFailure fails the step with an error which is retried by default
+	executeFailure := func(name string) func(wfContext.Context, *types.TaskRunOptions) (v1alpha1.StepStatus, *types.Operation, error) {
+		return func(wfContext.Context, *types.TaskRunOptions) (v1alpha1.StepStatus, *types.Operation, error) {
+			return v1alpha1.StepStatus{
+				Name:   name,
+				Type:   "execute-failure",
+				Phase:  v1alpha1.WorkflowStepPhaseFailed,
+				Reason: types.StatusReasonExecute,
+			}, &types.Operation{Waiting: true}, nil
+		}
+	}
+	testCases := map[string]struct {
+		steps         []v1alpha1.WorkflowStep
+		executeFailed bool
+		expectedState v1alpha1.WorkflowRunPhase
+		expectedSteps []v1alpha1.StepStatus
+		expectedSubs  []v1alpha1.StepStatus
+	}{
+		"ignore the failure after retries": {
+			steps: []v1alpha1.WorkflowStep{
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "failed-after-retries", OnFailure: v1alpha1.StepFailurePolicyIgnore}},
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+			},
+			expectedState: v1alpha1.WorkflowStateSucceeded,
+			expectedSteps: []v1alpha1.StepStatus{
+				{Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonIgnored},
+				{Phase: v1alpha1.WorkflowStepPhaseSucceeded},
+			},
+		},
+		"fail the run by default": {
+			steps: []v1alpha1.WorkflowStep{
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "failed-after-retries"}},
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+			},
+			expectedState: v1alpha1.WorkflowStateFailed,
+			expectedSteps: []v1alpha1.StepStatus{
+				{Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonFailedAfterRetries},
+				{Phase: v1alpha1.WorkflowStepPhaseSkipped, Reason: types.StatusReasonSkip},
+			},
+		},
+		"retry the failure before ignoring it": {
+			steps: []v1alpha1.WorkflowStep{
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "execute-failure", OnFailure: v1alpha1.StepFailurePolicyIgnore}},
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+			},
+			executeFailed: true,
+			expectedState: v1alpha1.WorkflowStateExecuting,
+			expectedSteps: []v1alpha1.StepStatus{
+				{Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonExecute},
+			},
+		},
+		"terminate on the first failure": {
+			steps: []v1alpha1.WorkflowStep{
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "execute-failure", OnFailure: v1alpha1.StepFailurePolicyTerminate}},
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+			},
+			executeFailed: true,
+			expectedState: v1alpha1.WorkflowStateFailed,
+			expectedSteps: []v1alpha1.StepStatus{
+				{Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonFailedAfterRetries},
+				{Phase: v1alpha1.WorkflowStepPhaseSkipped, Reason: types.StatusReasonSkip},
+			},
+		},
+		"ignore the failure of the sub step": {
+			steps: []v1alpha1.WorkflowStep{
+				{
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "group", Type: "step-group"},
+					SubSteps: []v1alpha1.WorkflowStepBase{
+						{Name: "sub1", Type: "failed-after-retries", OnFailure: v1alpha1.StepFailurePolicyIgnore},
+						{Name: "sub2", Type: "success", DependsOn: []string{"sub1"}},
+					},
+				},
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+			},
+			expectedState: v1alpha1.WorkflowStateSucceeded,
+			expectedSteps: []v1alpha1.StepStatus{
+				{Phase: v1alpha1.WorkflowStepPhaseSucceeded},
+				{Phase: v1alpha1.WorkflowStepPhaseSucceeded},
+			},
+			expectedSubs: []v1alpha1.StepStatus{
+				{Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonIgnored},
+				{Phase: v1alpha1.WorkflowStepPhaseSucceeded},
+			},
+		},
+		"inherit the policy of the step group": {
+			steps: []v1alpha1.WorkflowStep{
+				{
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "group", Type: "step-group", OnFailure: v1alpha1.StepFailurePolicyIgnore},
+					SubSteps: []v1alpha1.WorkflowStepBase{
+						{Name: "sub1", Type: "failed-after-retries"},
+					},
+				},
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+			},
+			expectedState: v1alpha1.WorkflowStateSucceeded,
+			expectedSteps: []v1alpha1.StepStatus{
+				{Phase: v1alpha1.WorkflowStepPhaseSucceeded},
+				{Phase: v1alpha1.WorkflowStepPhaseSucceeded},
+			},
+			expectedSubs: []v1alpha1.StepStatus{
+				{Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonIgnored},
+			},
+		},
+		"failed job in the step group": {
+			steps: []v1alpha1.WorkflowStep{
+				{
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "group", Type: "step-group"},
+					SubSteps: []v1alpha1.WorkflowStepBase{
+						{Name: "sub1", Type: "failed-job"},
+						{Name: "sub2", Type: "success"},
+					},
+				},
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+			},
+			expectedState: v1alpha1.WorkflowStateFailed,
+			expectedSteps: []v1alpha1.StepStatus{
+				{Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: "BackoffLimitExceeded"},
+				{Phase: v1alpha1.WorkflowStepPhaseSkipped, Reason: types.StatusReasonSkip},
+			},
+			expectedSubs: []v1alpha1.StepStatus{
+				{Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: "BackoffLimitExceeded"},
+				{Phase: v1alpha1.WorkflowStepPhaseSucceeded},
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			instance, runners := makeTestCase(tc.steps)
+			instance.Name = "failure-policy"
+			defer wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+			if tc.executeFailed {
+				runners[0].(*testTaskRunner).run = executeFailure(tc.steps[0].Name)
+			}
+			state, err := New(instance, cli).ExecuteRunners(ctx, runners)
+			r.NoError(err)
+			r.Equal(tc.expectedState, state)
+			r.Len(instance.Status.Steps, len(tc.expectedSteps))
+			for i, expected := range tc.expectedSteps {
+				r.Equal(expected.Phase, instance.Status.Steps[i].Phase, instance.Status.Steps[i].Name)
+				r.Equal(expected.Reason, instance.Status.Steps[i].Reason, instance.Status.Steps[i].Name)
+			}
+			if tc.expectedSubs != nil {
+				subs := instance.Status.Steps[0].SubStepsStatus
+				r.Len(subs, len(tc.expectedSubs))
+				for i, expected := range tc.expectedSubs {
+					r.Equal(expected.Phase, subs[i].Phase, subs[i].Name)
+					r.Equal(expected.Reason, subs[i].Reason, subs[i].Name)
+				}
+			}
+			if tc.expectedState != v1alpha1.WorkflowStateExecuting {
+				_, terminated := TerminatedCondition(&instance.Status)
+				r.Equal(tc.expectedState == v1alpha1.WorkflowStateFailed, terminated)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubevela/pkg/util/rand"
+	"github.com/pkg/errors"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// EnableFaultInjection enables the annotations of the workflow runs to inject the failures and the latencies into
+// the steps, it's only for testing the failure paths of the workflows and must not be enabled in production.
+var EnableFaultInjection = false
+
+// faults are the failures and the latencies injected into the steps
+type faults struct {
+	failures  map[string]int
+	latencies map[string]time.Duration
+}
+
+// parseFaults parses the faults from the annotations, it returns nil if the fault injection is disabled
+// or no fault is injected, the invalid entries are ignored.
+func parseFaults(ctx monitorContext.Context, annotations map[string]string) *faults {
+	if !EnableFaultInjection {
+		return nil
+	}
+	f := &faults{failures: map[string]int{}, latencies: map[string]time.Duration{}}
+	parseEntries(ctx, annotations[types.AnnotationWorkflowRunInjectFailure], func(step, s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return errors.Errorf("negative failures %d", n)
+		}
+		f.failures[step] = n
+		return nil
+	})
+	parseEntries(ctx, annotations[types.AnnotationWorkflowRunInjectLatency], func(step, s string) error {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.latencies[step] = d
+		return nil
+	})
+	if len(f.failures) == 0 && len(f.latencies) == 0 {
+		return nil
+	}
+	return f
+}
+
+func parseEntries(ctx monitorContext.Context, annotation string, parse func(step, s string) error) {
+	for _, entry := range strings.Split(annotation, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, ":")
+		if i <= 0 {
+			ctx.Info("Ignore the invalid fault injection", "entry", entry)
+			continue
+		}
+		if err := parse(entry[:i], entry[i+1:]); err != nil {
+			ctx.Info("Ignore the invalid fault injection", "entry", entry, "err", err.Error())
+		}
+	}
+}
+
+// injectFault delays the execution of the step by the injected latency, then fails it with a synthetic error if
+// the injected failures are not exhausted. The step fails like an execution error, so it's retried with backoff.
+func (e *engine) injectFault(ctx monitorContext.Context, name string) (v1alpha1.StepStatus, *types.Operation, bool) {
+	if e.faults == nil {
+		return v1alpha1.StepStatus{}, nil, false
+	}
+	if latency := e.faults.latencies[name]; latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+		}
+	}
+	limit, ok := e.faults.failures[name]
+	if !ok {
+		return v1alpha1.StepStatus{}, nil, false
+	}
+	injected, _ := strconv.Atoi(e.wfCtx.GetMutableValue(types.ContextPrefixInjectedFailures, name))
+	if injected >= limit {
+		return v1alpha1.StepStatus{}, nil, false
+	}
+	injected++
+	e.wfCtx.SetMutableValue(strconv.Itoa(injected), types.ContextPrefixInjectedFailures, name)
+
+	status := e.stepStatus[name]
+	if status.ID == "" {
+		status.ID = rand.RandomString(10)
+	}
+	if step, ok := e.findStep(name); ok {
+		status.Name = step.Name
+		status.Type = step.Type
+	}
+	status.Phase = v1alpha1.WorkflowStepPhaseFailed
+	status.Reason = types.StatusReasonExecute
+	status.Message = fmt.Sprintf("injected failure %d/%d", injected, limit)
+	operation := &types.Operation{Waiting: true}
+	if injected >= types.MaxWorkflowStepErrorRetryTimes {
+		status.Reason = types.StatusReasonFailedAfterRetries
+		operation = &types.Operation{FailedAfterRetries: true}
+	}
+	return status, operation, true
+}
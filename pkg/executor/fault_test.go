@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestParseFaults(t *testing.T) {
+	r := require.New(t)
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	annotations := map[string]string{
+		types.AnnotationWorkflowRunInjectFailure: "step-a:2, step-b:invalid,invalid,step-c:-1",
+		types.AnnotationWorkflowRunInjectLatency: "step-c:10s,step-d:1x",
+	}
+	r.Nil(parseFaults(ctx, annotations))
+
+	defer func() { EnableFaultInjection = false }()
+	EnableFaultInjection = true
+	f := parseFaults(ctx, annotations)
+	r.NotNil(f)
+	r.Equal(map[string]int{"step-a": 2}, f.failures)
+	r.Equal(map[string]time.Duration{"step-c": 10 * time.Second}, f.latencies)
+	r.Nil(parseFaults(ctx, map[string]string{}))
+}
+
+func TestInjectFailure(t *testing.T) {
+	r := require.New(t)
+	defer func() { EnableFaultInjection = false }()
+	EnableFaultInjection = true
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+	steps := []v1alpha1.WorkflowStep{
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "success"}},
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+	}
+	instance, _ := makeTestCase(steps)
+	defer wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+	instance.Annotations = map[string]string{
+		types.AnnotationWorkflowRunInjectFailure: "s2:2",
+		types.AnnotationWorkflowRunInjectLatency: "s1:10ms",
+	}
+
+	for i := 1; i <= 2; i++ {
+		_, runners := makeTestCase(steps)
+		state, err := New(instance, cli).ExecuteRunners(ctx, runners)
+		r.NoError(err)
+		r.Equal(v1alpha1.WorkflowStateExecuting, state)
+		r.Len(instance.Status.Steps, 2)
+		r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, instance.Status.Steps[0].Phase)
+		r.Equal(v1alpha1.WorkflowStepPhaseFailed, instance.Status.Steps[1].Phase)
+		r.Equal(types.StatusReasonExecute, instance.Status.Steps[1].Reason)
+		r.Equal(fmt.Sprintf("injected failure %d/2", i), instance.Status.Steps[1].Message)
+	}
+	// the step succeeds after the injected failures
+	_, runners := makeTestCase(steps)
+	state, err := New(instance, cli).ExecuteRunners(ctx, runners)
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, state)
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, instance.Status.Steps[1].Phase)
+}
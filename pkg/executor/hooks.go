@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+	"github.com/kubevela/pkg/util/rand"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// Option is the option of the workflow executor
+type Option func(w *workflowExecutor)
+
+// WithStepHooks adds the hooks notified with the lifecycle of the steps, the hooks are called in order.
+func WithStepHooks(hooks ...types.StepHook) Option {
+	return func(w *workflowExecutor) {
+		w.stepHooks = append(w.stepHooks, hooks...)
+	}
+}
+
+// WithFailStepOnHookError fails the step if any of the step hooks returns an error, the step is not
+// executed if the start hook fails. The failed step is handled by its onFailure policy like the other
+// failures. Otherwise the error is only logged.
+func WithFailStepOnHookError(fail bool) Option {
+	return func(w *workflowExecutor) {
+		w.failOnHookError = fail
+	}
+}
+
+// findStep finds the spec of the step or the sub step by name
+func (e *engine) findStep(name string) (v1alpha1.WorkflowStep, bool) {
+	for _, step := range e.instance.Steps {
+		if step.Name == name {
+			return step, true
+		}
+		for _, sub := range step.SubSteps {
+			if sub.Name == name {
+				return v1alpha1.WorkflowStep{WorkflowStepBase: sub}, true
+			}
+		}
+	}
+	return v1alpha1.WorkflowStep{}, false
+}
+
+// onStepStart calls the hooks if the step is not started yet, the failed status is returned
+// if the hook fails and the failure is configured to fail the step.
+func (e *engine) onStepStart(ctx monitorContext.Context, name string) (v1alpha1.StepStatus, bool) {
+	if len(e.stepHooks) == 0 {
+		return v1alpha1.StepStatus{}, false
+	}
+	status, ok := e.stepStatus[name]
+	if ok && status.Phase != "" && status.Phase != v1alpha1.WorkflowStepPhasePending {
+		return v1alpha1.StepStatus{}, false
+	}
+	step, ok := e.findStep(name)
+	if !ok {
+		return v1alpha1.StepStatus{}, false
+	}
+	var hookErr error
+	for _, hook := range e.stepHooks {
+		if err := hook.OnStepStart(ctx, step, wfContext.ReadOnly(e.wfCtx)); err != nil {
+			ctx.Error(err, "run step start hook", "step", name)
+			if hookErr == nil {
+				hookErr = err
+			}
+		}
+	}
+	if hookErr == nil || !e.failOnHookError {
+		return v1alpha1.StepStatus{}, false
+	}
+	if status.ID == "" {
+		status.ID = rand.RandomString(10)
+	}
+	status.Name = step.Name
+	status.Type = step.Type
+	status.Phase = v1alpha1.WorkflowStepPhaseFailed
+	status.Reason = types.StatusReasonHook
+	status.Message = hookErr.Error()
+	return status, true
+}
+
+// onStepComplete calls the hooks with the finished step, the status is marked as failed
+// if the hook fails and the failure is configured to fail the step.
+func (e *engine) onStepComplete(ctx monitorContext.Context, status v1alpha1.StepStatus) v1alpha1.StepStatus {
+	if len(e.stepHooks) == 0 {
+		return status
+	}
+	step, ok := e.findStep(status.Name)
+	if !ok {
+		return status
+	}
+	var hookErr error
+	for _, hook := range e.stepHooks {
+		if err := hook.OnStepComplete(ctx, step, status, wfContext.ReadOnly(e.wfCtx)); err != nil {
+			ctx.Error(err, "run step complete hook", "step", status.Name)
+			if hookErr == nil {
+				hookErr = err
+			}
+		}
+	}
+	if hookErr != nil && e.failOnHookError && status.Phase == v1alpha1.WorkflowStepPhaseSucceeded {
+		status.Phase = v1alpha1.WorkflowStepPhaseFailed
+		status.Reason = types.StatusReasonHook
+		status.Message = hookErr.Error()
+	}
+	return status
+}
+
+// onWorkflowComplete calls the hooks when the workflow is finished in this execution
+func (w *workflowExecutor) onWorkflowComplete(ctx monitorContext.Context, phase v1alpha1.WorkflowRunPhase) {
+	if w.wfCtx == nil {
+		return
+	}
+	switch phase {
+	case v1alpha1.WorkflowStateSucceeded, v1alpha1.WorkflowStateFailed, v1alpha1.WorkflowStateTerminated:
+	default:
+		return
+	}
+	for _, hook := range w.stepHooks {
+		if err := hook.OnWorkflowComplete(ctx, w.instance, phase, wfContext.ReadOnly(w.wfCtx)); err != nil {
+			ctx.Error(err, "run workflow complete hook")
+		}
+	}
+}
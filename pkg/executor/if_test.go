@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+)
+
+func TestSubStepIf(t *testing.T) {
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+	testCases := map[string]struct {
+		subSteps      []v1alpha1.WorkflowStepBase
+		expectedState v1alpha1.WorkflowRunPhase
+		expectedGroup v1alpha1.WorkflowStepPhase
+		expectedSubs  map[string]v1alpha1.WorkflowStepPhase
+	}{
+		"skipped sub step doesn't block the group": {
+			subSteps: []v1alpha1.WorkflowStepBase{
+				{Name: "sub1", Type: "success", If: `inputs.test == "other"`},
+				{Name: "sub2", Type: "success", DependsOn: []string{"sub1"}},
+				{Name: "sub3", Type: "success", If: `inputs.test == "app" && status.sub1.skipped && steps.group.sub1.output.endpoint == _|_`},
+			},
+			expectedState: v1alpha1.WorkflowStateSucceeded,
+			expectedGroup: v1alpha1.WorkflowStepPhaseSucceeded,
+			expectedSubs: map[string]v1alpha1.WorkflowStepPhase{
+				"sub1": v1alpha1.WorkflowStepPhaseSkipped,
+				"sub2": v1alpha1.WorkflowStepPhaseSucceeded,
+				"sub3": v1alpha1.WorkflowStepPhaseSucceeded,
+			},
+		},
+		"skipped sub step passes the failure to its dependents": {
+			subSteps: []v1alpha1.WorkflowStepBase{
+				{Name: "sub1", Type: "failed-after-retries"},
+				{Name: "sub2", Type: "success", DependsOn: []string{"sub1"}, If: `inputs.test == "other"`},
+				{Name: "sub3", Type: "success", DependsOn: []string{"sub2"}},
+			},
+			expectedState: v1alpha1.WorkflowStateFailed,
+			expectedGroup: v1alpha1.WorkflowStepPhaseFailed,
+			expectedSubs: map[string]v1alpha1.WorkflowStepPhase{
+				"sub1": v1alpha1.WorkflowStepPhaseFailed,
+				"sub2": v1alpha1.WorkflowStepPhaseSkipped,
+				"sub3": v1alpha1.WorkflowStepPhaseSkipped,
+			},
+		},
+		"all sub steps skipped": {
+			subSteps: []v1alpha1.WorkflowStepBase{
+				{Name: "sub1", Type: "success", If: `inputs.test != "app"`},
+				{Name: "sub2", Type: "success", If: `context.name != "app"`},
+			},
+			expectedState: v1alpha1.WorkflowStateSucceeded,
+			expectedGroup: v1alpha1.WorkflowStepPhaseSkipped,
+			expectedSubs: map[string]v1alpha1.WorkflowStepPhase{
+				"sub1": v1alpha1.WorkflowStepPhaseSkipped,
+				"sub2": v1alpha1.WorkflowStepPhaseSkipped,
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "success"}},
+				{
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{
+						Name:   "group",
+						Type:   "step-group",
+						Inputs: v1alpha1.StepInputs{{From: "test"}},
+					},
+					SubSteps: tc.subSteps,
+				},
+			})
+			instance.Name = "sub-step-if"
+			defer wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+			state, err := New(instance, cli).ExecuteRunners(ctx, runners)
+			r.NoError(err)
+			r.Equal(tc.expectedState, state)
+			r.Len(instance.Status.Steps, 2)
+			group := instance.Status.Steps[1]
+			r.Equal(tc.expectedGroup, group.Phase)
+			subs := map[string]v1alpha1.WorkflowStepPhase{}
+			for _, sub := range group.SubStepsStatus {
+				subs[sub.Name] = sub.Phase
+			}
+			r.Equal(tc.expectedSubs, subs)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"encoding/json"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/logs"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// WithKubeClient sets the client to collect the logs of the pods for the steps which configure collect-logs,
+// the logs are not collected without it.
+func WithKubeClient(kubeClient kubernetes.Interface) Option {
+	return func(w *workflowExecutor) {
+		w.kubeClient = kubeClient
+	}
+}
+
+// collectLogs collects the logs of the pods into the logs config map of the finished step if the step configures
+// collect-logs, the failure of the collection is logged without failing the step.
+func (e *engine) collectLogs(ctx monitorContext.Context, status v1alpha1.StepStatus) v1alpha1.StepStatus {
+	if e.kubeClient == nil {
+		return status
+	}
+	c := e.wfCtx.GetMutableValue(types.ContextKeyLogConfig)
+	if c == "" {
+		return status
+	}
+	config := make(map[string]types.LogConfig)
+	if err := json.Unmarshal([]byte(c), &config); err != nil {
+		ctx.Error(err, "unmarshal log config", "step", status.Name)
+		return status
+	}
+	stepConfig, ok := config[status.Name]
+	if !ok || stepConfig.Collect == nil {
+		return status
+	}
+	name, err := logs.Collect(ctx, e.cli, e.kubeClient, e.instance, status.Name, *stepConfig.Collect)
+	if err != nil {
+		ctx.Error(err, "collect logs", "step", status.Name)
+		return status
+	}
+	status.Logs = name
+	return status
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// positionRegexp matches the line of the position in the CUE errors, e.g. `./main.cue:3:2` or `-:12:8: field not allowed`
+var positionRegexp = regexp.MustCompile(`^\s*\S*:\d+:\d+`)
+
+// truncateMessages caps the message and the message history of the step to types.MaxStepMessageSize, the full
+// message is stored in the overflow ConfigMap of the workflow context and referred by the suffix of the truncated one.
+func (e *engine) truncateMessages(status *v1alpha1.StepStatus) {
+	if types.MaxStepMessageSize <= 0 {
+		return
+	}
+	truncated := map[string]string{}
+	truncate := func(message string, key string) string {
+		if len(message) <= types.MaxStepMessageSize {
+			return message
+		}
+		if t, ok := truncated[message]; ok {
+			return t
+		}
+		suffix := "...[truncated]"
+		ref, err := e.wfCtx.SetOverflowValue(message, key)
+		if err != nil {
+			e.monitorCtx.Error(err, "store the full message of the step", "step", status.Name)
+		} else {
+			suffix = fmt.Sprintf(types.MessageTruncated, ref.ConfigMapRef, ref.Key)
+		}
+		truncated[message] = truncateMessage(message, types.MaxStepMessageSize, suffix)
+		return truncated[message]
+	}
+	// the records of the history are truncated first, so that the latest message shares the key of its record
+	for i := range status.MessageHistory {
+		record := &status.MessageHistory[i]
+		record.Message = truncate(record.Message, historyMessageKey(status.Name, i, record.Time))
+	}
+	status.Message = truncate(status.Message, messageKey(status.Name))
+}
+
+func messageKey(step string) string {
+	return fmt.Sprintf("message-%s", step)
+}
+
+// historyMessageKey is the key of the record in the message history, it's keyed by the time of the record
+// as the index of it changes when the history is rotated, the index is only used if the time is missing.
+func historyMessageKey(step string, index int, t metav1.Time) string {
+	if t.IsZero() {
+		return fmt.Sprintf("message-%s-%d", step, index)
+	}
+	return fmt.Sprintf("message-%s-%d", step, t.Unix())
+}
+
+// truncateMessage truncates the message to the size with the suffix. The first line and the line of the CUE position
+// are kept before the other lines, so that the error and where it happens are still readable.
+func truncateMessage(message string, size int, suffix string) string {
+	if len(message) <= size {
+		return message
+	}
+	budget := size - len(suffix)
+	if budget <= 0 {
+		return cutRunes(message, size)
+	}
+	lines := strings.Split(message, "\n")
+	kept, rest := []string{lines[0]}, lines[1:]
+	for i, line := range rest {
+		if positionRegexp.MatchString(line) {
+			kept = append(kept, line)
+			rest = append(rest[:i:i], rest[i+1:]...)
+			break
+		}
+	}
+	head := strings.Join(kept, "\n")
+	if len(head) >= budget || len(rest) == 0 {
+		return cutRunes(head, budget) + suffix
+	}
+	return head + cutRunes("\n"+strings.Join(rest, "\n"), budget-len(head)) + suffix
+}
+
+// cutRunes cuts the string to at most size bytes at the rune boundary
+func cutRunes(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	for size > 0 && !utf8.RuneStart(s[size]) {
+		size--
+	}
+	return s[:size]
+}
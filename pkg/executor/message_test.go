@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestTruncateMessage(t *testing.T) {
+	testCases := map[string]struct {
+		message  string
+		size     int
+		expected string
+	}{
+		"short": {
+			message:  "failed",
+			size:     20,
+			expected: "failed",
+		},
+		"keep the first line and the position": {
+			message:  "field not allowed\nline 1\nline 2\n    ./main.cue:3:2\nline 3",
+			size:     45,
+			expected: "field not allowed\n    ./main.cue:3:2\nline ...",
+		},
+		"cut the first line": {
+			message:  "field not allowed: " + strings.Repeat("x", 20),
+			size:     20,
+			expected: "field not allowed...",
+		},
+		"rune boundary": {
+			message:  "错误错误错误",
+			size:     10,
+			expected: "错误...",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, truncateMessage(tc.message, tc.size, "..."))
+		})
+	}
+}
+
+func TestTruncateMessages(t *testing.T) {
+	r := require.New(t)
+	maxSize := types.MaxStepMessageSize
+	defer func() {
+		types.MaxStepMessageSize = maxSize
+	}()
+	types.MaxStepMessageSize = 128
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	wfCtx, err := wfContext.NewContext(cli, "default", "truncate-messages", nil)
+	r.NoError(err)
+	e := &engine{wfCtx: wfCtx, monitorCtx: monitorContext.NewTraceContext(context.Background(), "test-app")}
+
+	message := "failed to render the step\n" + strings.Repeat("detail ", 20)
+	status := &v1alpha1.StepStatus{
+		Name:           "render",
+		Message:        message,
+		MessageHistory: []v1alpha1.StepMessage{{Message: message}},
+	}
+	e.truncateMessages(status)
+	r.Len(status.Message, 128)
+	r.True(strings.HasPrefix(status.Message, "failed to render the step\n"))
+	ref, err := wfCtx.SetOverflowValue(message, "message-render-0")
+	r.NoError(err)
+	r.True(strings.HasSuffix(status.Message, fmt.Sprintf(types.MessageTruncated, ref.ConfigMapRef, ref.Key)))
+	r.Equal(status.Message, status.MessageHistory[0].Message)
+	full, err := wfCtx.GetOverflowValue(*ref)
+	r.NoError(err)
+	r.Equal(message, full)
+
+	// the records of the same step in the history are kept in the different keys
+	older := "failed to apply the step\n" + strings.Repeat("older ", 30)
+	newer := "failed to apply the step\n" + strings.Repeat("newer ", 30)
+	status = &v1alpha1.StepStatus{
+		Name:    "apply",
+		Message: newer,
+		MessageHistory: []v1alpha1.StepMessage{
+			{Message: older, Time: metav1.Unix(100, 0)},
+			{Message: newer, Time: metav1.Unix(200, 0)},
+		},
+	}
+	e.truncateMessages(status)
+	for i, expected := range []string{older, newer} {
+		ref := wfContext.OverflowRef{ConfigMapRef: ref.ConfigMapRef, Key: fmt.Sprintf("message-apply-%d", (i+1)*100)}
+		r.True(strings.HasSuffix(status.MessageHistory[i].Message, fmt.Sprintf(types.MessageTruncated, ref.ConfigMapRef, ref.Key)))
+		full, err := wfCtx.GetOverflowValue(ref)
+		r.NoError(err)
+		r.Equal(expected, full)
+	}
+	r.Equal(status.MessageHistory[1].Message, status.Message)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"encoding/json"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+// MaxInlineOutputSize is the max size in bytes of an output inlined in the status of the succeeded step
+const MaxInlineOutputSize = 1024
+
+// inlineOutputs copies the small outputs of the succeeded step from the workflow context into its status, the
+// values are serialized as the compact JSON with the sorted keys so that the status is stable across reconciles.
+func (e *engine) inlineOutputs(ctx monitorContext.Context, status v1alpha1.StepStatus) v1alpha1.StepStatus {
+	if status.Phase != v1alpha1.WorkflowStepPhaseSucceeded {
+		return status
+	}
+	step, ok := e.findStep(status.Name)
+	if !ok {
+		return status
+	}
+	var outputs []v1alpha1.StepOutput
+	for _, output := range step.Outputs {
+		v, err := e.wfCtx.GetVar(value.SplitPath(output.Name)...)
+		if err != nil {
+			ctx.Error(err, "get output", "step", status.Name, "output", output.Name)
+			continue
+		}
+		s, ok := inlineValue(v)
+		if !ok {
+			continue
+		}
+		outputs = append(outputs, v1alpha1.StepOutput{Name: output.Name, Value: s})
+	}
+	status.Outputs = outputs
+	return status
+}
+
+// inlineValue returns the value in the stable JSON, it's false if the value is not concrete, exceeds
+// MaxInlineOutputSize or refers to the overflow ConfigMap.
+func inlineValue(v *value.Value) (string, bool) {
+	b, err := v.CueValue().MarshalJSON()
+	if err != nil {
+		return "", false
+	}
+	var data interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return "", false
+	}
+	if m, ok := data.(map[string]interface{}); ok {
+		if _, ok := m[wfContext.OverflowKey]; ok {
+			return "", false
+		}
+	}
+	if b, err = json.Marshal(data); err != nil || len(b) >= MaxInlineOutputSize {
+		return "", false
+	}
+	return string(b), true
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestInlineOutputs(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	wfCtx, err := wfContext.NewContext(cli, "default", "inline-outputs", nil)
+	r.NoError(err)
+	setVar := func(name, v string) {
+		val, err := value.NewValue(v, nil, "")
+		r.NoError(err)
+		r.NoError(wfCtx.SetVar(val, name))
+	}
+	setVar("endpoint", `{port: 80, host: "example.com"}`)
+	setVar("large", `"`+strings.Repeat("a", MaxInlineOutputSize)+`"`)
+	setVar("overflow", `{`+wfContext.OverflowKey+`: "ref"}`)
+	setVar("sub", `"done"`)
+	e := &engine{
+		wfCtx: wfCtx,
+		instance: &types.WorkflowInstance{Steps: []v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "deploy",
+					Outputs: v1alpha1.StepOutputs{
+						{Name: "endpoint"}, {Name: "large"}, {Name: "overflow"},
+					},
+				},
+				SubSteps: []v1alpha1.WorkflowStepBase{
+					{Name: "sub", Outputs: v1alpha1.StepOutputs{{Name: "sub"}}},
+				},
+			},
+		}},
+	}
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+
+	status := e.inlineOutputs(ctx, v1alpha1.StepStatus{Name: "deploy", Phase: v1alpha1.WorkflowStepPhaseSucceeded})
+	r.Equal([]v1alpha1.StepOutput{{Name: "endpoint", Value: `{"host":"example.com","port":80}`}}, status.Outputs)
+	status = e.inlineOutputs(ctx, v1alpha1.StepStatus{Name: "sub", Phase: v1alpha1.WorkflowStepPhaseSucceeded})
+	r.Equal([]v1alpha1.StepOutput{{Name: "sub", Value: `"done"`}}, status.Outputs)
+	status = e.inlineOutputs(ctx, v1alpha1.StepStatus{Name: "deploy", Phase: v1alpha1.WorkflowStepPhaseFailed})
+	r.Nil(status.Outputs)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// MaxRunSummaryLength is the max length in bytes of the summary of the workflow run
+const MaxRunSummaryLength = 128
+
+// UpdateSummary refreshes the progress and the summary of the workflow run from the phases of the steps,
+// total is the number of the steps in the workflow run, e.g. "3/7 steps, running: deploy-canary".
+func UpdateSummary(status *v1alpha1.WorkflowRunStatus, total int) {
+	finished := 0
+	var running, suspended, failed []string
+	for _, step := range status.Steps {
+		if types.IsStepFinish(step.Phase, step.Reason) {
+			finished++
+		}
+		switch {
+		case step.Phase == v1alpha1.WorkflowStepPhaseRunning:
+			running = append(running, step.Name)
+			if step.Type == types.WorkflowStepTypeSuspend {
+				suspended = append(suspended, step.Name)
+			}
+		case step.Phase == v1alpha1.WorkflowStepPhaseFailed && !types.IsStepFailureIgnored(step.StepStatus):
+			failed = append(failed, step.Name)
+		}
+	}
+	if total < len(status.Steps) {
+		total = len(status.Steps)
+	}
+	status.Progress = fmt.Sprintf("%d/%d", finished, total)
+
+	summary := fmt.Sprintf("%s steps", status.Progress)
+	switch status.Phase {
+	case v1alpha1.WorkflowStateSucceeded:
+		summary += ", succeeded"
+	case v1alpha1.WorkflowStateFailed, v1alpha1.WorkflowStateTerminated:
+		summary += fmt.Sprintf(", %s", status.Phase)
+		if len(failed) > 0 {
+			summary += fmt.Sprintf(": %s", strings.Join(failed, ", "))
+		}
+	case v1alpha1.WorkflowStateSuspending:
+		summary += ", suspended"
+		if len(suspended) > 0 {
+			summary += fmt.Sprintf(": %s", strings.Join(suspended, ", "))
+		}
+	default:
+		if len(running) > 0 {
+			summary += fmt.Sprintf(", running: %s", strings.Join(running, ", "))
+		}
+	}
+	if len(summary) > MaxRunSummaryLength {
+		summary = cutRunes(summary, MaxRunSummaryLength-len("...")) + "..."
+	}
+	status.Summary = summary
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestUpdateSummary(t *testing.T) {
+	step := func(name, typ string, phase v1alpha1.WorkflowStepPhase) v1alpha1.WorkflowStepStatus {
+		return v1alpha1.WorkflowStepStatus{StepStatus: v1alpha1.StepStatus{Name: name, Type: typ, Phase: phase}}
+	}
+	testCases := map[string]struct {
+		phase    v1alpha1.WorkflowRunPhase
+		steps    []v1alpha1.WorkflowStepStatus
+		total    int
+		progress string
+		summary  string
+	}{
+		"initializing": {
+			phase:    v1alpha1.WorkflowStateInitializing,
+			total:    7,
+			progress: "0/7",
+			summary:  "0/7 steps",
+		},
+		"running": {
+			phase: v1alpha1.WorkflowStateExecuting,
+			steps: []v1alpha1.WorkflowStepStatus{
+				step("build", "apply", v1alpha1.WorkflowStepPhaseSucceeded),
+				step("test", "apply", v1alpha1.WorkflowStepPhaseSkipped),
+				step("scan", "apply", v1alpha1.WorkflowStepPhaseSucceeded),
+				step("deploy-canary", "apply", v1alpha1.WorkflowStepPhaseRunning),
+			},
+			total:    7,
+			progress: "3/7",
+			summary:  "3/7 steps, running: deploy-canary",
+		},
+		"suspended": {
+			phase: v1alpha1.WorkflowStateSuspending,
+			steps: []v1alpha1.WorkflowStepStatus{
+				step("build", "apply", v1alpha1.WorkflowStepPhaseSucceeded),
+				step("approve", types.WorkflowStepTypeSuspend, v1alpha1.WorkflowStepPhaseRunning),
+			},
+			total:    3,
+			progress: "1/3",
+			summary:  "1/3 steps, suspended: approve",
+		},
+		"failed": {
+			phase: v1alpha1.WorkflowStateFailed,
+			steps: []v1alpha1.WorkflowStepStatus{
+				step("build", "apply", v1alpha1.WorkflowStepPhaseSucceeded),
+				{StepStatus: v1alpha1.StepStatus{Name: "deploy", Type: "apply", Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonFailedAfterRetries}},
+			},
+			total:    3,
+			progress: "2/3",
+			summary:  "2/3 steps, failed: deploy",
+		},
+		"succeeded": {
+			phase: v1alpha1.WorkflowStateSucceeded,
+			steps: []v1alpha1.WorkflowStepStatus{
+				step("build", "apply", v1alpha1.WorkflowStepPhaseSucceeded),
+			},
+			total:    1,
+			progress: "1/1",
+			summary:  "1/1 steps, succeeded",
+		},
+		"started steps without the instance": {
+			phase: v1alpha1.WorkflowStateExecuting,
+			steps: []v1alpha1.WorkflowStepStatus{
+				step("build", "apply", v1alpha1.WorkflowStepPhaseRunning),
+			},
+			progress: "0/1",
+			summary:  "0/1 steps, running: build",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			status := &v1alpha1.WorkflowRunStatus{Phase: tc.phase, Steps: tc.steps}
+			UpdateSummary(status, tc.total)
+			require.Equal(t, tc.progress, status.Progress)
+			require.Equal(t, tc.summary, status.Summary)
+		})
+	}
+}
+
+func TestUpdateSummaryCapped(t *testing.T) {
+	r := require.New(t)
+	status := &v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateExecuting}
+	for i := 0; i < 20; i++ {
+		status.Steps = append(status.Steps, v1alpha1.WorkflowStepStatus{StepStatus: v1alpha1.StepStatus{
+			Name:  strings.Repeat("x", 10),
+			Phase: v1alpha1.WorkflowStepPhaseRunning,
+		}})
+	}
+	UpdateSummary(status, 20)
+	r.Len(status.Summary, MaxRunSummaryLength)
+	r.True(strings.HasPrefix(status.Summary, "0/20 steps, running: xxxxxxxxxx, "))
+	r.True(strings.HasSuffix(status.Summary, "..."))
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"fmt"
+
+	"github.com/kubevela/pkg/util/rand"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// isRunningStep returns whether the step is started and not finished, the suspend steps are not regarded as running
+// as they are waiting for the manual operations. The step group is running if any of its sub steps is running.
+func isRunningStep(ss v1alpha1.WorkflowStepStatus) bool {
+	running := func(ss v1alpha1.StepStatus) bool {
+		return ss.Phase != "" && ss.Phase != v1alpha1.WorkflowStepPhasePending &&
+			!types.IsStepFinish(ss.Phase, ss.Reason) && ss.Type != types.WorkflowStepTypeSuspend
+	}
+	if ss.Type != types.WorkflowStepTypeStepGroup {
+		return running(ss.StepStatus)
+	}
+	for _, sub := range ss.SubStepsStatus {
+		if running(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGracefulTermination terminates the gracefully terminating workflow run if none of the steps is running,
+// or the grace period is exceeded. The unfinished steps are interrupted and the steps that are not started are skipped.
+func (w *workflowExecutor) checkGracefulTermination(ctx monitorContext.Context) {
+	status := &w.instance.Status
+	if status.GracefulTermination == nil || status.Terminated || status.Finished {
+		return
+	}
+	termination := status.GracefulTermination.DeepCopy()
+	running := false
+	for _, ss := range status.Steps {
+		running = running || isRunningStep(ss)
+	}
+	if running {
+		if w.clock.Now().Before(termination.StartTime.Add(termination.GracePeriod.Duration)) {
+			return
+		}
+		termination.Forced = true
+		ctx.Info("Force to terminate the workflow run as the grace period is exceeded", "gracePeriod", termination.GracePeriod.Duration)
+	}
+	w.terminateSteps(types.StatusReasonTerminate, fmt.Sprintf(types.MessageStepInterrupted, termination.GracePeriod.Duration))
+	status.GracefulTermination = termination
+}
+
+// terminateSteps terminates the workflow run, the unfinished steps are failed with the reason and the message,
+// and the steps that are not started are skipped.
+func (w *workflowExecutor) terminateSteps(reason, message string) {
+	status := &w.instance.Status
+	interrupt := func(ss *v1alpha1.StepStatus) {
+		if ss.Phase != "" && !types.IsStepFinish(ss.Phase, ss.Reason) {
+			ss.Phase = v1alpha1.WorkflowStepPhaseFailed
+			ss.Reason = reason
+			ss.Message = message
+		}
+	}
+	started := map[string]bool{}
+	for i := range status.Steps {
+		interrupt(&status.Steps[i].StepStatus)
+		for j := range status.Steps[i].SubStepsStatus {
+			interrupt(&status.Steps[i].SubStepsStatus[j])
+		}
+		started[status.Steps[i].Name] = true
+	}
+	now := metav1.NewTime(w.clock.Now())
+	for _, step := range w.instance.Steps {
+		if started[step.Name] {
+			continue
+		}
+		status.Steps = append(status.Steps, v1alpha1.WorkflowStepStatus{
+			StepStatus: v1alpha1.StepStatus{
+				ID:               rand.RandomString(10),
+				Name:             step.Name,
+				Type:             step.Type,
+				Phase:            v1alpha1.WorkflowStepPhaseSkipped,
+				Message:          types.MessageStepNotStarted,
+				FirstExecuteTime: now,
+				LastExecuteTime:  now,
+			},
+		})
+	}
+	status.Suspend = false
+	status.Terminated = true
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestGracefulTermination(t *testing.T) {
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+
+	t.Run("terminate after the running steps finish", func(t *testing.T) {
+		r := require.New(t)
+		steps := []v1alpha1.WorkflowStep{
+			{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "success"}},
+			{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+		}
+		instance, runners := makeTestCase(steps)
+		instance.Name = "graceful"
+		defer wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+		instance.Status.Steps = []v1alpha1.WorkflowStepStatus{{StepStatus: v1alpha1.StepStatus{Name: "s1", Type: "success", Phase: v1alpha1.WorkflowStepPhaseRunning}}}
+		instance.Status.GracefulTermination = &v1alpha1.GracefulTermination{
+			StartTime:   metav1.Now(),
+			GracePeriod: metav1.Duration{Duration: time.Hour},
+		}
+		// the running step is finished but the next step is not started
+		state, err := New(instance, cli).ExecuteRunners(ctx, runners)
+		r.NoError(err)
+		r.Equal(v1alpha1.WorkflowStateExecuting, state)
+		r.False(instance.Status.Terminated)
+		r.Len(instance.Status.Steps, 1)
+		r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, instance.Status.Steps[0].Phase)
+
+		_, runners = makeTestCase(steps)
+		state, err = New(instance, cli).ExecuteRunners(ctx, runners)
+		r.NoError(err)
+		r.Equal(v1alpha1.WorkflowStateTerminated, state)
+		r.True(instance.Status.Terminated)
+		r.False(instance.Status.GracefulTermination.Forced)
+		r.Len(instance.Status.Steps, 2)
+		r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, instance.Status.Steps[0].Phase)
+		r.Equal(v1alpha1.WorkflowStepPhaseSkipped, instance.Status.Steps[1].Phase)
+		r.Equal(types.MessageStepNotStarted, instance.Status.Steps[1].Message)
+		cond, ok := TerminatedCondition(&instance.Status)
+		r.True(ok)
+		r.Equal(v1alpha1.TerminatedReasonUserTerminated, string(cond.Reason))
+	})
+
+	t.Run("force to terminate after the grace period", func(t *testing.T) {
+		r := require.New(t)
+		steps := []v1alpha1.WorkflowStep{
+			{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "running"}},
+			{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+		}
+		instance, runners := makeTestCase(steps)
+		instance.Name = "forced"
+		defer wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+		state, err := New(instance, cli).ExecuteRunners(ctx, runners)
+		r.NoError(err)
+		r.Equal(v1alpha1.WorkflowStateExecuting, state)
+
+		instance.Status.GracefulTermination = &v1alpha1.GracefulTermination{
+			StartTime:   metav1.Now(),
+			GracePeriod: metav1.Duration{Duration: time.Hour},
+		}
+		_, runners = makeTestCase(steps)
+		state, err = New(instance, cli).ExecuteRunners(ctx, runners)
+		r.NoError(err)
+		r.Equal(v1alpha1.WorkflowStateExecuting, state)
+		r.Len(instance.Status.Steps, 1)
+
+		instance.Status.GracefulTermination.StartTime = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		_, runners = makeTestCase(steps)
+		state, err = New(instance, cli).ExecuteRunners(ctx, runners)
+		r.NoError(err)
+		r.Equal(v1alpha1.WorkflowStateTerminated, state)
+		r.True(instance.Status.GracefulTermination.Forced)
+		r.Len(instance.Status.Steps, 2)
+		r.Equal(v1alpha1.WorkflowStepPhaseFailed, instance.Status.Steps[0].Phase)
+		r.Equal(types.StatusReasonTerminate, instance.Status.Steps[0].Reason)
+		r.Equal(fmt.Sprintf(types.MessageStepInterrupted, time.Hour), instance.Status.Steps[0].Message)
+		r.Equal(v1alpha1.WorkflowStepPhaseSkipped, instance.Status.Steps[1].Phase)
+	})
+}
+
+func TestWorkflowTimeout(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+	steps := []v1alpha1.WorkflowStep{
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "running"}},
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success"}},
+	}
+	instance, runners := makeTestCase(steps)
+	instance.Name = "timeout"
+	instance.Timeout = "1h"
+	defer wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+	state, err := New(instance, cli).ExecuteRunners(ctx, runners)
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateExecuting, state)
+	r.Equal("1h", instance.Status.Timeout)
+
+	instance.Status.StartTime = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	_, runners = makeTestCase(steps)
+	state, err = New(instance, cli).ExecuteRunners(ctx, runners)
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateFailed, state)
+	r.True(instance.Status.Terminated)
+	r.Len(instance.Status.Steps, 2)
+	r.Equal(v1alpha1.WorkflowStepPhaseFailed, instance.Status.Steps[0].Phase)
+	r.Equal(types.StatusReasonTimeout, instance.Status.Steps[0].Reason)
+	r.Equal(fmt.Sprintf(types.MessageWorkflowTimeout, "1h"), instance.Status.Steps[0].Message)
+	r.Equal(v1alpha1.WorkflowStepPhaseSkipped, instance.Status.Steps[1].Phase)
+}
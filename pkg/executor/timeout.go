@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"fmt"
+	"time"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// workflowDeadline returns the time when the workflow run times out, it's zero if the run is not started or has no
+// valid timeout.
+func workflowDeadline(instance *types.WorkflowInstance) time.Time {
+	if instance.Timeout == "" || instance.Status.StartTime.IsZero() {
+		return time.Time{}
+	}
+	timeout, err := time.ParseDuration(instance.Timeout)
+	if err != nil || timeout <= 0 {
+		return time.Time{}
+	}
+	return instance.Status.StartTime.Add(timeout)
+}
+
+// checkWorkflowTimeout terminates the workflow run which is not finished in its timeout, the unfinished steps are
+// failed with the reason Timeout and the steps that are not started are skipped.
+func (w *workflowExecutor) checkWorkflowTimeout(ctx monitorContext.Context) {
+	status := &w.instance.Status
+	if status.Terminated || status.Finished {
+		return
+	}
+	deadline := workflowDeadline(w.instance)
+	if deadline.IsZero() || w.clock.Now().Before(deadline) {
+		return
+	}
+	ctx.Info("Terminate the workflow run as the timeout is exceeded", "timeout", w.instance.Timeout)
+	w.terminateSteps(types.StatusReasonTimeout, fmt.Sprintf(types.MessageWorkflowTimeout, w.instance.Timeout))
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"cuelang.org/go/cue"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/tasks/custom"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// sensitiveVarsSuffix is the suffix of the name of the Secret storing the sensitive vars after the context ConfigMap
+const sensitiveVarsSuffix = "-sensitive-vars"
+
+// setVarsToContext evaluates the expressions of spec.var when the workflow run starts and persists the values in the context,
+// the later reconciles load the values from the context instead of evaluating the expressions again.
+func (w *workflowExecutor) setVarsToContext(wfCtx wfContext.Context) error {
+	vars, err := evaluateVars(w.instance.Vars, map[string]interface{}{
+		model.ContextName:        w.instance.Name,
+		model.ContextNamespace:   w.instance.Namespace,
+		model.ContextLabels:      w.instance.Labels,
+		model.ContextAnnotations: w.instance.Annotations,
+		model.ContextRunUID:      string(w.instance.UID),
+		model.ContextStartTime:   w.instance.Status.StartTime.Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	if vars, err = mergeImportedVars(vars, w.instance.ContextFromVars); err != nil || vars == "" {
+		return err
+	}
+	v, err := value.NewValue(vars, nil, "")
+	if err != nil {
+		return err
+	}
+	return wfCtx.SetVar(v, types.ContextKeyVars)
+}
+
+// mergeImportedVars merges the vars imported by spec.contextFrom into the evaluated vars in JSON, the evaluated
+// ones take precedence.
+func mergeImportedVars(vars string, imported map[string]string) (string, error) {
+	if len(imported) == 0 {
+		return vars, nil
+	}
+	merged := map[string]interface{}{}
+	for name, v := range imported {
+		merged[name] = v
+	}
+	if vars != "" {
+		evaluated := map[string]json.RawMessage{}
+		if err := json.Unmarshal([]byte(vars), &evaluated); err != nil {
+			return "", err
+		}
+		for name, v := range evaluated {
+			merged[name] = v
+		}
+	}
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// setSensitiveVarsToContext keeps the sensitive vars imported from the Secrets in the memory store of the context for
+// the steps, they are persisted in a Secret owned by the workflow run instead of the context ConfigMap.
+func (w *workflowExecutor) setSensitiveVarsToContext(ctx context.Context, wfCtx wfContext.Context) error {
+	vars := map[string]string{}
+	for name, v := range w.instance.SensitiveVars {
+		if _, ok := w.instance.Vars[name]; !ok {
+			vars[name] = v
+		}
+	}
+	if len(vars) == 0 {
+		return nil
+	}
+	if !wfContext.InMemory(w.cli) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            wfCtx.StoreRef().Name + sensitiveVarsSuffix,
+				Namespace:       w.instance.Namespace,
+				OwnerReferences: w.instance.ChildOwnerReferences,
+			},
+			Data: map[string][]byte{},
+		}
+		for name, v := range vars {
+			secret.Data[name] = []byte(v)
+		}
+		if err := w.cli.Create(ctx, secret); err != nil {
+			if !kerrors.IsAlreadyExists(err) {
+				return errors.WithMessagef(err, "create the secret %s of the sensitive vars", secret.Name)
+			}
+			// the secret is left by a former attempt failed to commit the context
+			if err := w.cli.Update(ctx, secret); err != nil {
+				return errors.WithMessagef(err, "update the secret %s of the sensitive vars", secret.Name)
+			}
+		}
+		wfCtx.SetMutableValue(secret.Name, types.ContextKeySensitiveVars)
+	}
+	wfCtx.SetValueInMemory(vars, types.ContextKeySensitiveVars)
+	return nil
+}
+
+// loadSensitiveVars loads the sensitive vars from the Secret into the memory store of the context if they're not
+// loaded, e.g. after the controller restarts.
+func (w *workflowExecutor) loadSensitiveVars(ctx context.Context, wfCtx wfContext.Context) error {
+	name := wfCtx.GetMutableValue(types.ContextKeySensitiveVars)
+	if name == "" {
+		return nil
+	}
+	if _, ok := wfCtx.GetValueInMemory(types.ContextKeySensitiveVars); ok {
+		return nil
+	}
+	secret := &corev1.Secret{}
+	if err := w.cli.Get(ctx, client.ObjectKey{Namespace: w.instance.Namespace, Name: name}, secret); err != nil {
+		return errors.WithMessagef(err, "load the sensitive vars from the secret %s", name)
+	}
+	vars := map[string]string{}
+	for k, v := range secret.Data {
+		vars[k] = string(v)
+	}
+	wfCtx.SetValueInMemory(vars, types.ContextKeySensitiveVars)
+	return nil
+}
+
+// evaluateVars evaluates the expressions with the context and returns the values in JSON
+func evaluateVars(exprs map[string]string, scope map[string]interface{}) (string, error) {
+	if len(exprs) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(scope)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(exprs))
+	for name := range exprs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	vars := map[string]json.RawMessage{}
+	for _, name := range names {
+		imports, expr := custom.SplitImports(exprs[name])
+		v, err := value.NewValue(fmt.Sprintf("%s\ncontext: %s\nvar: %s", imports, b, expr), nil, "")
+		if err != nil {
+			return "", errors.WithMessagef(err, "evaluate var %s", name)
+		}
+		field := v.CueValue().LookupPath(cue.ParsePath("var"))
+		if err := field.Validate(cue.Concrete(true)); err != nil {
+			return "", errors.WithMessagef(err, "evaluate var %s", name)
+		}
+		vars[name], err = field.MarshalJSON()
+		if err != nil {
+			return "", errors.WithMessagef(err, "evaluate var %s", name)
+		}
+	}
+	result, err := json.Marshal(vars)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestVarsEvaluatedOnce(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	steps := []v1alpha1.WorkflowStep{{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "running"},
+	}}
+	vars := map[string]string{
+		"suffix": `import "strings"
+strings.SliceRunes(context.runUID, 0, 5)`,
+		"target": `"\(context.namespace)-\(context.labels.env)"`,
+	}
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+
+	getVar := func(instance *types.WorkflowInstance, name string) string {
+		wfCtx, err := wfContext.LoadContext(cli, instance.Namespace, instance.Name, instance.Status.ContextBackend.Name)
+		r.NoError(err)
+		v, err := wfCtx.GetVar(types.ContextKeyVars, name)
+		r.NoError(err)
+		s, err := v.GetString()
+		r.NoError(err)
+		return s
+	}
+
+	instance, runners := makeTestCase(steps)
+	instance.UID = "abcdefgh-uid"
+	instance.Labels = map[string]string{"env": "prod"}
+	instance.Vars = vars
+	_, err := New(instance, cli).ExecuteRunners(ctx, runners)
+	r.NoError(err)
+	r.Equal("abcde", getVar(instance, "suffix"))
+	r.Equal("default-prod", getVar(instance, "target"))
+
+	// simulate the controller restarts with the changed metadata, the persisted values are kept
+	wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+	restarted, runners := makeTestCase(steps)
+	restarted.UID = "other-uid"
+	restarted.Labels = map[string]string{"env": "dev"}
+	restarted.Vars = vars
+	restarted.Status = *instance.Status.DeepCopy()
+	_, err = New(restarted, cli).ExecuteRunners(ctx, runners)
+	r.NoError(err)
+	r.Equal("abcde", getVar(restarted, "suffix"))
+	r.Equal("default-prod", getVar(restarted, "target"))
+}
+
+func TestEvaluateVars(t *testing.T) {
+	r := require.New(t)
+	vars, err := evaluateVars(map[string]string{
+		"replicas": `len(context.name) + 1`,
+		"tags":     `[context.name, "latest"]`,
+	}, map[string]interface{}{"name": "app"})
+	r.NoError(err)
+	r.JSONEq(`{"replicas":4,"tags":["app","latest"]}`, vars)
+
+	_, err = evaluateVars(map[string]string{"missing": `context.labels.env`}, map[string]interface{}{"name": "app"})
+	r.Error(err)
+	r.Contains(err.Error(), "evaluate var missing")
+
+	vars, err = evaluateVars(nil, nil)
+	r.NoError(err)
+	r.Equal("", vars)
+}
+
+func TestImportedVars(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	steps := []v1alpha1.WorkflowStep{{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "running"},
+	}}
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+
+	instance, runners := makeTestCase(steps)
+	instance.Vars = map[string]string{"env": `"prod"`}
+	instance.ContextFromVars = map[string]string{"env": "dev", "region": "us"}
+	instance.SensitiveVars = map[string]string{"token": "secret-token", "env": "leaked"}
+	_, err := New(instance, cli).ExecuteRunners(ctx, runners)
+	r.NoError(err)
+
+	wfCtx, err := wfContext.LoadContext(cli, instance.Namespace, instance.Name, instance.Status.ContextBackend.Name)
+	r.NoError(err)
+	vars, err := wfCtx.GetVar(types.ContextKeyVars)
+	r.NoError(err)
+	s, err := vars.String()
+	r.NoError(err)
+	r.Contains(s, `env:    "prod"`)
+	r.Contains(s, `region: "us"`)
+	r.NotContains(s, "secret-token")
+	for _, data := range wfCtx.GetStore().Data {
+		r.NotContains(data, "secret-token")
+	}
+	sensitive, ok := wfCtx.GetValueInMemory(types.ContextKeySensitiveVars)
+	r.True(ok)
+	r.Equal(map[string]string{"token": "secret-token"}, sensitive)
+
+	// simulate the controller restarts, the sensitive vars are loaded from the secret
+	wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+	restarted, runners := makeTestCase(steps)
+	restarted.Status = *instance.Status.DeepCopy()
+	_, err = New(restarted, cli).ExecuteRunners(ctx, runners)
+	r.NoError(err)
+	wfCtx, err = wfContext.LoadContext(cli, instance.Namespace, instance.Name, instance.Status.ContextBackend.Name)
+	r.NoError(err)
+	sensitive, ok = wfCtx.GetValueInMemory(types.ContextKeySensitiveVars)
+	r.True(ok)
+	r.Equal(map[string]string{"token": "secret-token"}, sensitive)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// capWatches drops the watches of the step if they exceed the rest of MaxWatchesPerRun left by the other steps of
+// the run. The watches of a step are kept or dropped as a whole, so that the step with the dropped watches is still
+// reconciled with the periodic backoff instead of missing the changes of the unwatched objects.
+func (e *engine) capWatches(status v1alpha1.StepStatus) v1alpha1.StepStatus {
+	if len(status.Watches) == 0 {
+		return status
+	}
+	watched := 0
+	for _, ss := range e.status.Steps {
+		if ss.Name != status.Name {
+			watched += len(ss.Watches)
+		}
+		for _, sub := range ss.SubStepsStatus {
+			if sub.Name != status.Name {
+				watched += len(sub.Watches)
+			}
+		}
+	}
+	if watched+len(status.Watches) > types.MaxWatchesPerRun {
+		e.monitorCtx.Info("Drop the watches of the step beyond the max watches per run", "step", status.Name,
+			"watches", len(status.Watches), "max", types.MaxWatchesPerRun)
+		status.Watches = nil
+	}
+	return status
+}
+
+// watchResyncPeriod returns WatchResyncPeriod in seconds if all the running steps are watching the objects they are
+// waiting on, the changes of the objects trigger the reconcile and the run is only resynced for the missed events.
+// It's 0 if any unfinished step is not watching, e.g. the step retrying the failure.
+func (e *engine) watchResyncPeriod() int {
+	if types.WatchResyncPeriod <= 0 {
+		return 0
+	}
+	watched := false
+	watching := func(status v1alpha1.StepStatus) bool {
+		if status.Phase == v1alpha1.WorkflowStepPhaseRunning {
+			watched = true
+			return len(status.Watches) > 0
+		}
+		return status.Phase != v1alpha1.WorkflowStepPhaseFailed || types.IsStepFinish(status.Phase, status.Reason)
+	}
+	for _, step := range e.status.Steps {
+		if len(step.SubStepsStatus) == 0 {
+			if !watching(step.StepStatus) {
+				return 0
+			}
+			continue
+		}
+		for _, sub := range step.SubStepsStatus {
+			if !watching(sub) {
+				return 0
+			}
+		}
+	}
+	if !watched {
+		return 0
+	}
+	return int(types.WatchResyncPeriod.Seconds())
+}
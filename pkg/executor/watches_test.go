@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestCapWatches(t *testing.T) {
+	r := require.New(t)
+	defer func(max int) { types.MaxWatchesPerRun = max }(types.MaxWatchesPerRun)
+	types.MaxWatchesPerRun = 3
+	watches := func(names ...string) []v1alpha1.WatchedObject {
+		var objs []v1alpha1.WatchedObject
+		for _, name := range names {
+			objs = append(objs, v1alpha1.WatchedObject{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: name})
+		}
+		return objs
+	}
+	e := &engine{
+		monitorCtx: monitorContext.NewTraceContext(context.Background(), "test-app"),
+		status: &v1alpha1.WorkflowRunStatus{Steps: []v1alpha1.WorkflowStepStatus{
+			{StepStatus: v1alpha1.StepStatus{Name: "wait", Watches: watches("a")}},
+			{
+				StepStatus:     v1alpha1.StepStatus{Name: "group"},
+				SubStepsStatus: []v1alpha1.StepStatus{{Name: "sub", Watches: watches("b")}},
+			},
+		}},
+	}
+
+	// the watches of the step itself are replaced by the new ones
+	status := e.capWatches(v1alpha1.StepStatus{Name: "wait", Watches: watches("a", "c")})
+	r.Equal(watches("a", "c"), status.Watches)
+	status = e.capWatches(v1alpha1.StepStatus{Name: "sub", Watches: watches("b", "d")})
+	r.Equal(watches("b", "d"), status.Watches)
+	// the watches beyond the max are dropped as a whole
+	status = e.capWatches(v1alpha1.StepStatus{Name: "other", Watches: watches("e", "f")})
+	r.Nil(status.Watches)
+	status = e.capWatches(v1alpha1.StepStatus{Name: "other", Watches: watches("e")})
+	r.Equal(watches("e"), status.Watches)
+
+	types.MaxWatchesPerRun = 0
+	status = e.capWatches(v1alpha1.StepStatus{Name: "wait", Watches: watches("a")})
+	r.Nil(status.Watches)
+}
+
+func TestWatchResyncPeriod(t *testing.T) {
+	r := require.New(t)
+	defer func(period time.Duration) { types.WatchResyncPeriod = period }(types.WatchResyncPeriod)
+	types.WatchResyncPeriod = 5 * time.Minute
+	watches := []v1alpha1.WatchedObject{{APIVersion: "batch/v1", Kind: "Job", Namespace: "default", Name: "build"}}
+	testCases := map[string]struct {
+		steps    []v1alpha1.WorkflowStepStatus
+		expected int
+	}{
+		"all running steps watching": {
+			steps: []v1alpha1.WorkflowStepStatus{
+				{StepStatus: v1alpha1.StepStatus{Name: "done", Phase: v1alpha1.WorkflowStepPhaseSucceeded}},
+				{StepStatus: v1alpha1.StepStatus{Name: "wait", Phase: v1alpha1.WorkflowStepPhaseRunning, Watches: watches}},
+				{
+					StepStatus: v1alpha1.StepStatus{Name: "group", Phase: v1alpha1.WorkflowStepPhaseRunning},
+					SubStepsStatus: []v1alpha1.StepStatus{
+						{Name: "sub", Phase: v1alpha1.WorkflowStepPhaseRunning, Watches: watches},
+					},
+				},
+			},
+			expected: 300,
+		},
+		"running step not watching": {
+			steps: []v1alpha1.WorkflowStepStatus{
+				{StepStatus: v1alpha1.StepStatus{Name: "wait", Phase: v1alpha1.WorkflowStepPhaseRunning, Watches: watches}},
+				{StepStatus: v1alpha1.StepStatus{Name: "suspend", Phase: v1alpha1.WorkflowStepPhaseRunning}},
+			},
+		},
+		"running sub step not watching": {
+			steps: []v1alpha1.WorkflowStepStatus{
+				{
+					StepStatus: v1alpha1.StepStatus{Name: "group", Phase: v1alpha1.WorkflowStepPhaseRunning},
+					SubStepsStatus: []v1alpha1.StepStatus{
+						{Name: "sub1", Phase: v1alpha1.WorkflowStepPhaseRunning, Watches: watches},
+						{Name: "sub2", Phase: v1alpha1.WorkflowStepPhaseRunning},
+					},
+				},
+			},
+		},
+		"step retrying the failure": {
+			steps: []v1alpha1.WorkflowStepStatus{
+				{StepStatus: v1alpha1.StepStatus{Name: "wait", Phase: v1alpha1.WorkflowStepPhaseRunning, Watches: watches}},
+				{StepStatus: v1alpha1.StepStatus{Name: "apply", Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonExecute}},
+			},
+		},
+		"no running step": {
+			steps: []v1alpha1.WorkflowStepStatus{
+				{StepStatus: v1alpha1.StepStatus{Name: "done", Phase: v1alpha1.WorkflowStepPhaseSucceeded}},
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			e := &engine{status: &v1alpha1.WorkflowRunStatus{Steps: tc.steps}}
+			require.Equal(t, tc.expected, e.watchResyncPeriod())
+		})
+	}
+
+	types.WatchResyncPeriod = 0
+	e := &engine{status: &v1alpha1.WorkflowRunStatus{Steps: testCases["all running steps watching"].steps}}
+	r.Equal(0, e.watchResyncPeriod())
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// inExecuteWindow returns whether the time is in the execute window, and the start of the next window if it's not.
+// The window starts on the days in its time zone and ends on the next day if the end is not after the start, the
+// wall clock times skipped or repeated by the DST transitions are normalized by the time zone.
+func inExecuteWindow(window v1alpha1.ExecuteWindow, now time.Time) (bool, time.Time, error) {
+	start, err := time.Parse("15:04", window.Start)
+	if err != nil {
+		return false, time.Time{}, errors.WithMessage(err, "parse the start")
+	}
+	end, err := time.Parse("15:04", window.End)
+	if err != nil {
+		return false, time.Time{}, errors.WithMessage(err, "parse the end")
+	}
+	loc, err := time.LoadLocation(window.TimeZone)
+	if err != nil {
+		return false, time.Time{}, errors.WithMessage(err, "load the time zone")
+	}
+	days := map[time.Weekday]bool{}
+	for _, day := range window.Days {
+		weekday, err := parseWeekday(day)
+		if err != nil {
+			return false, time.Time{}, err
+		}
+		days[weekday] = true
+	}
+	spanDays := 0
+	if !end.After(start) {
+		spanDays = 1
+	}
+
+	local := now.In(loc)
+	// the window started yesterday may span midnight, the next window is at most a week later
+	for offset := -1; offset <= 7; offset++ {
+		windowStart := time.Date(local.Year(), local.Month(), local.Day()+offset, start.Hour(), start.Minute(), 0, 0, loc)
+		if len(days) > 0 && !days[windowStart.Weekday()] {
+			continue
+		}
+		windowEnd := time.Date(local.Year(), local.Month(), local.Day()+offset+spanDays, end.Hour(), end.Minute(), 0, 0, loc)
+		if !now.Before(windowStart) && now.Before(windowEnd) {
+			return true, time.Time{}, nil
+		}
+		if windowStart.After(now) {
+			return false, windowStart, nil
+		}
+	}
+	return false, time.Time{}, errors.New("no window is found in the days")
+}
+
+// parseWeekday parses the day of the week by its name or its first three letters, e.g. Sat or Saturday
+func parseWeekday(day string) (time.Weekday, error) {
+	name := strings.ToLower(day)
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		full := strings.ToLower(weekday.String())
+		if name == full || name == full[:3] {
+			return weekday, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid day %s", day)
+}
+
+// checkExecuteWindow keeps the step out of its execute window waiting, the start of the next window is recorded
+// to wake up the run then instead of the backoff.
+func (e *engine) checkExecuteWindow(step v1alpha1.WorkflowStep) *types.PreCheckResult {
+	if step.ExecuteWindow == nil {
+		return &types.PreCheckResult{}
+	}
+	in, next, err := inExecuteWindow(*step.ExecuteWindow, e.clock.Now())
+	if err != nil {
+		return &types.PreCheckResult{Failed: true, Message: fmt.Sprintf("invalid executeWindow: %s", err.Error())}
+	}
+	if in {
+		delete(e.stepWindow, step.Name)
+		return &types.PreCheckResult{}
+	}
+	e.stepWindow[step.Name] = next
+	return &types.PreCheckResult{Wait: true, Message: fmt.Sprintf("waiting for the execute window, the next window starts at %s", next.Format(time.RFC3339))}
+}
+
+// firstExecuteTime returns the first execute time kept in the last status of the step, it's reset while the step is
+// waiting for its execute window so that the timeout of the step counts from the time it's executed in the window.
+func firstExecuteTime(last v1alpha1.StepStatus, now metav1.Time) metav1.Time {
+	if last.Reason == types.StatusReasonExecuteWindow {
+		return now
+	}
+	return last.FirstExecuteTime
+}
+
+// windowWait returns the seconds until the earliest next window of the steps waiting for their execute windows if
+// all the running steps are waiting for them, the run is not reconciled with the backoff until the window starts.
+// It's 0 if any unfinished step is not waiting for its window.
+func (e *engine) windowWait() int64 {
+	var next time.Time
+	waiting := func(status v1alpha1.StepStatus) bool {
+		if status.Phase == v1alpha1.WorkflowStepPhaseRunning {
+			start, ok := e.stepWindow[status.Name]
+			if !ok || status.Reason != types.StatusReasonExecuteWindow {
+				return false
+			}
+			if next.IsZero() || start.Before(next) {
+				next = start
+			}
+			return true
+		}
+		return status.Phase != v1alpha1.WorkflowStepPhaseFailed || types.IsStepFinish(status.Phase, status.Reason)
+	}
+	for _, step := range e.status.Steps {
+		if len(step.SubStepsStatus) == 0 {
+			if !waiting(step.StepStatus) {
+				return 0
+			}
+			continue
+		}
+		for _, sub := range step.SubStepsStatus {
+			if !waiting(sub) {
+				return 0
+			}
+		}
+	}
+	if next.IsZero() {
+		return 0
+	}
+	d := next.Sub(e.clock.Now())
+	if d.Seconds() < 1 {
+		return minWorkflowBackoffWaitTime
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
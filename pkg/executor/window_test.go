@@ -0,0 +1,233 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	testingclock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestInExecuteWindow(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	night := v1alpha1.ExecuteWindow{Start: "22:00", End: "06:00", TimeZone: "Europe/Berlin"}
+	testCases := map[string]struct {
+		window v1alpha1.ExecuteWindow
+		now    time.Time
+		in     bool
+		next   time.Time
+		err    string
+	}{
+		"before the window": {
+			window: night,
+			now:    time.Date(2026, 1, 14, 21, 59, 0, 0, berlin),
+			next:   time.Date(2026, 1, 14, 22, 0, 0, 0, berlin),
+		},
+		"at the start": {
+			window: night,
+			now:    time.Date(2026, 1, 14, 22, 0, 0, 0, berlin),
+			in:     true,
+		},
+		"after midnight": {
+			window: night,
+			now:    time.Date(2026, 1, 15, 3, 0, 0, 0, berlin),
+			in:     true,
+		},
+		"at the end": {
+			window: night,
+			now:    time.Date(2026, 1, 15, 6, 0, 0, 0, berlin),
+			next:   time.Date(2026, 1, 15, 22, 0, 0, 0, berlin),
+		},
+		"in the other time zone": {
+			window: night,
+			now:    time.Date(2026, 1, 14, 21, 30, 0, 0, time.UTC),
+			in:     true,
+		},
+		"utc by default": {
+			window: v1alpha1.ExecuteWindow{Start: "09:00", End: "17:00"},
+			now:    time.Date(2026, 1, 14, 17, 30, 0, 0, time.UTC),
+			next:   time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC),
+		},
+		// the window of Sunday spans to Monday, the one of Friday is not started
+		"days spanning midnight": {
+			window: v1alpha1.ExecuteWindow{Start: "22:00", End: "06:00", TimeZone: "Europe/Berlin", Days: []string{"Sat", "sunday"}},
+			now:    time.Date(2026, 1, 19, 5, 0, 0, 0, berlin),
+			in:     true,
+		},
+		"next day": {
+			window: v1alpha1.ExecuteWindow{Start: "22:00", End: "06:00", TimeZone: "Europe/Berlin", Days: []string{"Sat", "sunday"}},
+			now:    time.Date(2026, 1, 19, 6, 0, 0, 0, berlin),
+			next:   time.Date(2026, 1, 24, 22, 0, 0, 0, berlin),
+		},
+		// the clocks are turned forward from 02:00 to 03:00 on 2026-03-29, the night is an hour shorter
+		"dst starts in the window": {
+			window: night,
+			now:    time.Date(2026, 3, 29, 3, 59, 0, 0, time.UTC),
+			in:     true,
+		},
+		"dst starts after the window": {
+			window: night,
+			now:    time.Date(2026, 3, 29, 4, 0, 0, 0, time.UTC),
+			next:   time.Date(2026, 3, 29, 20, 0, 0, 0, time.UTC),
+		},
+		// the start skipped by the clocks turned forward is normalized to the time after the transition
+		"start skipped by dst": {
+			window: v1alpha1.ExecuteWindow{Start: "02:30", End: "04:00", TimeZone: "Europe/Berlin"},
+			now:    time.Date(2026, 3, 29, 0, 0, 0, 0, time.UTC),
+			next:   time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC),
+		},
+		// the clocks are turned back from 03:00 to 02:00 on 2026-10-25, the night is an hour longer
+		"dst ends in the window": {
+			window: night,
+			now:    time.Date(2026, 10, 25, 4, 59, 0, 0, time.UTC),
+			in:     true,
+		},
+		"dst ends after the window": {
+			window: night,
+			now:    time.Date(2026, 10, 25, 5, 0, 0, 0, time.UTC),
+			next:   time.Date(2026, 10, 25, 21, 0, 0, 0, time.UTC),
+		},
+		"invalid start": {
+			window: v1alpha1.ExecuteWindow{Start: "24:00", End: "06:00"},
+			err:    `parse the start: parsing time "24:00": hour out of range`,
+		},
+		"invalid time zone": {
+			window: v1alpha1.ExecuteWindow{Start: "22:00", End: "06:00", TimeZone: "Mars/Olympus"},
+			err:    "load the time zone: unknown time zone Mars/Olympus",
+		},
+		"invalid day": {
+			window: v1alpha1.ExecuteWindow{Start: "22:00", End: "06:00", Days: []string{"Sa"}},
+			err:    "invalid day Sa",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			in, next, err := inExecuteWindow(tc.window, tc.now)
+			if tc.err != "" {
+				r.EqualError(err, tc.err)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tc.in, in)
+			r.True(tc.next.Equal(next), "expected %s, got %s", tc.next, next)
+		})
+	}
+}
+
+func TestExecuteWindow(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+	steps := []v1alpha1.WorkflowStep{
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s1", Type: "success"}},
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "s2", Type: "success", Timeout: "10m", ExecuteWindow: &v1alpha1.ExecuteWindow{Start: "22:00", End: "06:00"}}},
+	}
+	instance, runners := makeTestCase(steps)
+	instance.Name = "window"
+	defer wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+	start := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	fakeClock := testingclock.NewFakeClock(start)
+
+	phase, wait, err := tick(ctx, fakeClock, instance, cli, runners)
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateExecuting, phase)
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, instance.Status.Steps[0].Phase)
+	r.Equal(v1alpha1.WorkflowStepPhaseRunning, instance.Status.Steps[1].Phase)
+	r.Equal(types.StatusReasonExecuteWindow, instance.Status.Steps[1].Reason)
+	r.Equal("waiting for the execute window, the next window starts at 2022-01-01T22:00:00Z", instance.Status.Steps[1].Message)
+	// the run is requeued at the start of the window instead of the backoff
+	r.Equal(10*time.Hour, wait)
+
+	_, runners = makeTestCase(steps)
+	phase, _, err = tick(ctx, fakeClock, instance, cli, runners)
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, phase)
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, instance.Status.Steps[1].Phase)
+	// the timeout of the step counts from the start of the window
+	r.True(instance.Status.Steps[1].FirstExecuteTime.Time.Equal(start.Add(10 * time.Hour)))
+
+	steps[1].ExecuteWindow = &v1alpha1.ExecuteWindow{Start: "22:00", End: "06:00", TimeZone: "Mars/Olympus"}
+	instance, runners = makeTestCase(steps)
+	instance.Name = "invalid-window"
+	defer wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+	phase, _, err = tick(ctx, testingclock.NewFakeClock(start), instance, cli, runners)
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateFailed, phase)
+	r.Equal(types.StatusReasonCondition, instance.Status.Steps[1].Reason)
+	r.Equal("invalid executeWindow: load the time zone: unknown time zone Mars/Olympus", instance.Status.Steps[1].Message)
+}
+
+func TestWindowWait(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	waiting := func(name string) v1alpha1.StepStatus {
+		return v1alpha1.StepStatus{Name: name, Phase: v1alpha1.WorkflowStepPhaseRunning, Reason: types.StatusReasonExecuteWindow}
+	}
+	testCases := map[string]struct {
+		steps    []v1alpha1.WorkflowStepStatus
+		expected int64
+	}{
+		"earliest window": {
+			steps: []v1alpha1.WorkflowStepStatus{
+				{StepStatus: v1alpha1.StepStatus{Name: "done", Phase: v1alpha1.WorkflowStepPhaseSucceeded}},
+				{StepStatus: waiting("night")},
+				{
+					StepStatus:     v1alpha1.StepStatus{Name: "group", Phase: v1alpha1.WorkflowStepPhaseRunning},
+					SubStepsStatus: []v1alpha1.StepStatus{waiting("evening")},
+				},
+			},
+			expected: 6 * 3600,
+		},
+		"running step not waiting for the window": {
+			steps: []v1alpha1.WorkflowStepStatus{
+				{StepStatus: waiting("night")},
+				{StepStatus: v1alpha1.StepStatus{Name: "apply", Phase: v1alpha1.WorkflowStepPhaseRunning, Reason: types.StatusReasonWait}},
+			},
+		},
+		"step retrying the failure": {
+			steps: []v1alpha1.WorkflowStepStatus{
+				{StepStatus: waiting("night")},
+				{StepStatus: v1alpha1.StepStatus{Name: "apply", Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonExecute}},
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			e := &engine{
+				status: &v1alpha1.WorkflowRunStatus{Steps: tc.steps},
+				stepWindow: map[string]time.Time{
+					"night":   now.Add(10 * time.Hour),
+					"evening": now.Add(6 * time.Hour),
+				},
+				clock: testingclock.NewFakeClock(now),
+			}
+			require.Equal(t, tc.expected, e.windowWait())
+		})
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package executor
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -24,12 +25,16 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/clock"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
+	"github.com/kubevela/workflow/api/condition"
 	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
@@ -37,6 +42,7 @@ import (
 	"github.com/kubevela/workflow/pkg/features"
 	"github.com/kubevela/workflow/pkg/hooks"
 	"github.com/kubevela/workflow/pkg/monitor/metrics"
+	"github.com/kubevela/workflow/pkg/progress"
 	"github.com/kubevela/workflow/pkg/tasks/builtin"
 	"github.com/kubevela/workflow/pkg/tasks/custom"
 	"github.com/kubevela/workflow/pkg/types"
@@ -57,21 +63,36 @@ const (
 )
 
 type workflowExecutor struct {
-	instance *types.WorkflowInstance
-	cli      client.Client
-	wfCtx    wfContext.Context
+	instance        *types.WorkflowInstance
+	cli             client.Client
+	wfCtx           wfContext.Context
+	stepHooks       []types.StepHook
+	failOnHookError bool
+	gate            *Gate
+	kubeClient      kubernetes.Interface
+	auditSink       types.AuditSink
+	clock           clock.Clock
 }
 
 // New returns a Workflow Executor implementation.
-func New(instance *types.WorkflowInstance, cli client.Client) WorkflowExecutor {
-	return &workflowExecutor{
+func New(instance *types.WorkflowInstance, cli client.Client, opts ...Option) WorkflowExecutor {
+	w := &workflowExecutor{
 		instance: instance,
 		cli:      cli,
+		clock:    clock.RealClock{},
 	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
 }
 
 // InitializeWorkflowInstance init workflow instance
 func InitializeWorkflowInstance(instance *types.WorkflowInstance) {
+	initializeWorkflowInstance(instance, time.Now())
+}
+
+func initializeWorkflowInstance(instance *types.WorkflowInstance, now time.Time) {
 	if instance.Status.StartTime.IsZero() && len(instance.Status.Steps) == 0 {
 		metrics.WorkflowRunInitializedCounter.WithLabelValues().Inc()
 		mode := v1alpha1.WorkflowExecuteMode{
@@ -88,7 +109,8 @@ func InitializeWorkflowInstance(instance *types.WorkflowInstance) {
 		}
 		instance.Status = v1alpha1.WorkflowRunStatus{
 			Mode:      mode,
-			StartTime: metav1.Now(),
+			StartTime: metav1.NewTime(now),
+			DryRun:    instance.DryRun,
 		}
 		StepStatusCache.Delete(fmt.Sprintf("%s-%s", instance.Name, instance.Namespace))
 		wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
@@ -97,7 +119,22 @@ func InitializeWorkflowInstance(instance *types.WorkflowInstance) {
 
 // ExecuteRunners execute workflow task runners in order.
 func (w *workflowExecutor) ExecuteRunners(ctx monitorContext.Context, taskRunners []types.TaskRunner) (v1alpha1.WorkflowRunPhase, error) {
-	InitializeWorkflowInstance(w.instance)
+	if w.instance.LiveProgress {
+		stop := progress.Heartbeat(ctx, w.cli, w.instance)
+		defer stop()
+	}
+	phase, err := w.executeRunners(ctx, taskRunners)
+	if err == nil {
+		w.onWorkflowComplete(ctx, phase)
+	}
+	return phase, err
+}
+
+func (w *workflowExecutor) executeRunners(ctx monitorContext.Context, taskRunners []types.TaskRunner) (v1alpha1.WorkflowRunPhase, error) {
+	initializeWorkflowInstance(w.instance, w.clock.Now())
+	w.instance.Status.Timeout = w.instance.Timeout
+	w.checkGracefulTermination(ctx)
+	w.checkWorkflowTimeout(ctx)
 	status := &w.instance.Status
 	dagMode := status.Mode.Steps == v1alpha1.WorkflowModeDAG
 	cacheKey := fmt.Sprintf("%s-%s", w.instance.Name, w.instance.Namespace)
@@ -118,6 +155,7 @@ func (w *workflowExecutor) ExecuteRunners(ctx monitorContext.Context, taskRunner
 	if allTasksSucceeded {
 		return v1alpha1.WorkflowStateSucceeded, nil
 	}
+	resetConsecutiveFailures(status, w.instance.MaxConsecutiveFailures)
 
 	if cacheValue, ok := StepStatusCache.Load(cacheKey); ok {
 		// handle cache resource
@@ -126,16 +164,29 @@ func (w *workflowExecutor) ExecuteRunners(ctx monitorContext.Context, taskRunner
 		}
 	}
 
-	wfCtx, err := w.makeContext(w.instance.Name)
+	// the statuses are restored if the writes of the steps are not committed, otherwise the steps advanced in the
+	// patched status are not executed again with their writes lost
+	snapshot := status.DeepCopy()
+	wfCtx, err := w.makeContext(ctx, w.instance.Name)
 	if err != nil {
 		ctx.Error(err, "make context")
 		return v1alpha1.WorkflowStateExecuting, err
 	}
 	w.wfCtx = wfCtx
+	custom.PruneOpMarkers(wfCtx, w.instance.Status)
+	wfCtx.SetValueInMemory(hooks.OutputProducers(w.instance.Steps), types.ContextKeyOutputProducers)
+	wfCtx.SetValueInMemory(hooks.QualifiedOutputs(w.instance.Steps), types.ContextKeyQualifiedOutputs)
+	hooks.SetSkippedSteps(wfCtx, w.instance.Status)
 
 	e := newEngine(ctx, wfCtx, w, status)
 
 	err = e.Run(ctx, taskRunners, dagMode)
+	// the ops of the steps only write the context in memory, the writes are committed once per reconcile
+	// even if the run is interrupted by an error, so that the finished steps are not executed with lost writes
+	if commitErr := wfCtx.Commit(); commitErr != nil {
+		*status = *snapshot
+		err = errors.WithMessage(commitErr, "commit workflow context")
+	}
 	if err != nil {
 		ctx.Error(err, "run steps")
 		StepStatusCache.Store(cacheKey, len(status.Steps))
@@ -166,9 +217,9 @@ func (w *workflowExecutor) ExecuteRunners(ctx monitorContext.Context, taskRunner
 }
 
 func isTerminatedManually(status *v1alpha1.WorkflowRunStatus) bool {
-	manually := false
+	manually := status.GracefulTermination != nil
 	for _, step := range status.Steps {
-		if step.Phase == v1alpha1.WorkflowStepPhaseFailed {
+		if step.Phase == v1alpha1.WorkflowStepPhaseFailed && !types.IsStepFailureIgnored(step.StepStatus) {
 			if step.Reason == types.StatusReasonTerminate {
 				manually = true
 			} else {
@@ -179,6 +230,66 @@ func isTerminatedManually(status *v1alpha1.WorkflowRunStatus) bool {
 	return manually
 }
 
+// TerminatedCondition returns the condition describing why the workflow run is terminated and which step initiates it,
+// the termination by the user takes precedence over the break, the timeout and the failure of the steps.
+func TerminatedCondition(status *v1alpha1.WorkflowRunStatus) (condition.Condition, bool) {
+	var steps []v1alpha1.StepStatus
+	for _, step := range status.Steps {
+		// the sub steps are checked before the step group as they are the initiators
+		steps = append(steps, step.SubStepsStatus...)
+		steps = append(steps, step.StepStatus)
+	}
+	reasonOf := func(ss v1alpha1.StepStatus) string {
+		switch {
+		case types.IsStepFailureIgnored(ss):
+			return ""
+		case ss.Reason == types.StatusReasonTerminate && ss.Phase == v1alpha1.WorkflowStepPhaseFailed:
+			return v1alpha1.TerminatedReasonUserTerminated
+		case ss.Reason == types.StatusReasonTerminate:
+			return v1alpha1.TerminatedReasonStepBreak
+		case ss.Phase == v1alpha1.WorkflowStepPhaseFailed && (ss.Reason == types.StatusReasonTimeout || ss.Reason == types.StatusReasonApprovalTimeout):
+			return v1alpha1.TerminatedReasonTimeout
+		case ss.Phase == v1alpha1.WorkflowStepPhaseFailed:
+			return v1alpha1.TerminatedReasonStepFailed
+		default:
+			return ""
+		}
+	}
+	for _, reason := range []string{
+		v1alpha1.TerminatedReasonUserTerminated,
+		v1alpha1.TerminatedReasonStepBreak,
+		v1alpha1.TerminatedReasonTimeout,
+		v1alpha1.TerminatedReasonStepFailed,
+	} {
+		for _, ss := range steps {
+			if reasonOf(ss) != reason {
+				continue
+			}
+			message := fmt.Sprintf("initiated by step %s", ss.Name)
+			if ss.Message != "" {
+				message = fmt.Sprintf("%s: %s", message, ss.Message)
+			}
+			return condition.Condition{
+				Type:               condition.ConditionType(v1alpha1.WorkflowRunTerminatedConditionType),
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.Now(),
+				Reason:             condition.ConditionReason(reason),
+				Message:            message,
+			}, true
+		}
+	}
+	if status.GracefulTermination != nil {
+		return condition.Condition{
+			Type:               condition.ConditionType(v1alpha1.WorkflowRunTerminatedConditionType),
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.Now(),
+			Reason:             condition.ConditionReason(v1alpha1.TerminatedReasonUserTerminated),
+			Message:            "terminated gracefully after the running steps finished",
+		}, true
+	}
+	return condition.Condition{}, false
+}
+
 func checkWorkflowTerminated(status *v1alpha1.WorkflowRunStatus, allTasksDone bool) bool {
 	// if all tasks are done, and the terminated is true, then the workflow is terminated
 	return status.Terminated && allTasksDone
@@ -201,28 +312,52 @@ func checkWorkflowSuspended(status *v1alpha1.WorkflowRunStatus) bool {
 	return status.Suspend
 }
 
+// resetConsecutiveFailures resets the consecutive failures of the steps which have suspended the run,
+// the run is resumed manually if it's not suspended any more.
+func resetConsecutiveFailures(status *v1alpha1.WorkflowRunStatus, maxFailures int) {
+	if maxFailures <= 0 {
+		return
+	}
+	for i := range status.Steps {
+		if status.Steps[i].ConsecutiveFailures >= maxFailures {
+			status.Steps[i].ConsecutiveFailures = 0
+		}
+		for j := range status.Steps[i].SubStepsStatus {
+			if status.Steps[i].SubStepsStatus[j].ConsecutiveFailures >= maxFailures {
+				status.Steps[i].SubStepsStatus[j].ConsecutiveFailures = 0
+			}
+		}
+	}
+}
+
 func newEngine(ctx monitorContext.Context, wfCtx wfContext.Context, w *workflowExecutor, wfStatus *v1alpha1.WorkflowRunStatus) *engine {
 	stepStatus := make(map[string]v1alpha1.StepStatus)
 	setStepStatus(stepStatus, wfStatus.Steps)
-	stepDependsOn := make(map[string][]string)
 	for _, step := range w.instance.Steps {
 		hooks.SetAdditionalNameInStatus(stepStatus, step.Name, step.Properties, stepStatus[step.Name])
-		stepDependsOn[step.Name] = append(stepDependsOn[step.Name], step.DependsOn...)
-		for _, sub := range step.SubSteps {
+		for range step.SubSteps {
 			hooks.SetAdditionalNameInStatus(stepStatus, step.Name, step.Properties, stepStatus[step.Name])
-			stepDependsOn[sub.Name] = append(stepDependsOn[sub.Name], sub.DependsOn...)
 		}
 	}
+	stepDependsOn := hooks.StepDependencies(w.instance.Steps)
 	return &engine{
-		status:        wfStatus,
-		monitorCtx:    ctx,
-		instance:      w.instance,
-		wfCtx:         wfCtx,
-		cli:           w.cli,
-		debug:         w.instance.Debug,
-		stepStatus:    stepStatus,
-		stepDependsOn: stepDependsOn,
-		stepTimeout:   make(map[string]time.Time),
+		status:          wfStatus,
+		monitorCtx:      ctx,
+		instance:        w.instance,
+		wfCtx:           wfCtx,
+		cli:             w.cli,
+		debug:           w.instance.Debug,
+		stepStatus:      stepStatus,
+		stepDependsOn:   stepDependsOn,
+		stepTimeout:     make(map[string]time.Time),
+		stepWindow:      make(map[string]time.Time),
+		stepHooks:       w.stepHooks,
+		failOnHookError: w.failOnHookError,
+		gate:            w.gate,
+		kubeClient:      w.kubeClient,
+		faults:          parseFaults(ctx, w.instance.Annotations),
+		auditSink:       w.auditSink,
+		clock:           w.clock,
 	}
 }
 
@@ -243,9 +378,10 @@ func (w *workflowExecutor) GetSuspendBackoffWaitTime() time.Duration {
 	setStepStatus(stepStatus, w.instance.Status.Steps)
 	max := time.Duration(1<<63 - 1)
 	min := max
+	now := w.clock.Now()
 	for _, step := range w.instance.Steps {
 		if step.Type == types.WorkflowStepTypeSuspend || step.Type == types.WorkflowStepTypeStepGroup {
-			min = handleSuspendBackoffTime(step, stepStatus[step.Name], min)
+			min = handleSuspendBackoffTime(step, stepStatus[step.Name], min, now)
 		}
 		for _, sub := range step.SubSteps {
 			if sub.Type == types.WorkflowStepTypeSuspend {
@@ -256,17 +392,20 @@ func (w *workflowExecutor) GetSuspendBackoffWaitTime() time.Duration {
 						Timeout:    sub.Timeout,
 						Properties: sub.Properties,
 					},
-				}, stepStatus[sub.Name], min)
+				}, stepStatus[sub.Name], min, now)
 			}
 		}
 	}
+	if deadline := workflowDeadline(w.instance); !deadline.IsZero() && deadline.After(now) && deadline.Sub(now) < min {
+		min = deadline.Sub(now)
+	}
 	if min == max {
 		return 0
 	}
 	return min
 }
 
-func handleSuspendBackoffTime(step v1alpha1.WorkflowStep, status v1alpha1.StepStatus, min time.Duration) time.Duration {
+func handleSuspendBackoffTime(step v1alpha1.WorkflowStep, status v1alpha1.StepStatus, min time.Duration, now time.Time) time.Duration {
 	if status.Phase == v1alpha1.WorkflowStepPhaseRunning {
 		if step.Timeout != "" {
 			duration, err := time.ParseDuration(step.Timeout)
@@ -274,8 +413,8 @@ func handleSuspendBackoffTime(step v1alpha1.WorkflowStep, status v1alpha1.StepSt
 				return min
 			}
 			timeout := status.FirstExecuteTime.Add(duration)
-			if time.Now().Before(timeout) {
-				d := time.Until(timeout)
+			if now.Before(timeout) {
+				d := timeout.Sub(now)
 				if duration < min {
 					min = d
 				}
@@ -307,8 +446,8 @@ func (w *workflowExecutor) GetBackoffWaitTime() time.Duration {
 		return time.Second
 	}
 	next := time.Unix(unix, 0)
-	if next.After(time.Now()) {
-		return time.Until(next)
+	if now := w.clock.Now(); next.After(now) {
+		return next.Sub(now)
 	}
 
 	return time.Second
@@ -322,7 +461,7 @@ func (w *workflowExecutor) allDone(taskRunners []types.TaskRunner) (bool, bool)
 		for _, ss := range status.Steps {
 			if ss.Name == t.Name() {
 				done = types.IsStepFinish(ss.Phase, ss.Reason)
-				success = success && done && (ss.Phase == v1alpha1.WorkflowStepPhaseSucceeded || ss.Phase == v1alpha1.WorkflowStepPhaseSkipped)
+				success = success && done && (ss.Phase == v1alpha1.WorkflowStepPhaseSucceeded || ss.Phase == v1alpha1.WorkflowStepPhaseSkipped || types.IsStepFailureIgnored(ss.StepStatus))
 				break
 			}
 		}
@@ -333,13 +472,18 @@ func (w *workflowExecutor) allDone(taskRunners []types.TaskRunner) (bool, bool)
 	return true, success
 }
 
-func (w *workflowExecutor) makeContext(name string) (wfContext.Context, error) {
+func (w *workflowExecutor) makeContext(ctx context.Context, name string) (wfContext.Context, error) {
 	status := &w.instance.Status
 	if status.ContextBackend != nil {
-		wfCtx, err := wfContext.LoadContext(w.cli, w.instance.Namespace, w.instance.Name, w.instance.Status.ContextBackend.Name)
+		wfCtx, err := wfContext.LoadContextFromRef(w.cli, w.instance.Namespace, w.instance.Name, status.ContextBackend)
 		if err != nil {
 			return nil, errors.WithMessage(err, "load context")
 		}
+		// the reference is refreshed in case the backend is changed, e.g. the uid is missing in the legacy status
+		status.ContextBackend = wfCtx.StoreRef()
+		if err := w.loadSensitiveVars(ctx, wfCtx); err != nil {
+			return nil, err
+		}
 		return wfCtx, nil
 	}
 
@@ -351,13 +495,37 @@ func (w *workflowExecutor) makeContext(name string) (wfContext.Context, error) {
 	if err = w.setMetadataToContext(wfCtx); err != nil {
 		return nil, err
 	}
-	if err = wfCtx.Commit(); err != nil {
+	if err = w.setVarsToContext(wfCtx); err != nil {
 		return nil, err
 	}
+	if err = w.setSensitiveVarsToContext(ctx, wfCtx); err != nil {
+		return nil, err
+	}
+	if err = w.recordCustomContext(wfCtx); err != nil {
+		return nil, err
+	}
+	// the initial data is committed along with the writes of the steps at the end of the reconcile
 	status.ContextBackend = wfCtx.StoreRef()
 	return wfCtx, nil
 }
 
+// recordCustomContext records spec.context in the context backend, so that it's auditable which data the steps ran with
+func (w *workflowExecutor) recordCustomContext(wfCtx wfContext.Context) error {
+	if len(w.instance.Context) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(w.instance.Context)
+	if err != nil {
+		return err
+	}
+	store := wfCtx.GetStore()
+	if store.Data == nil {
+		store.Data = map[string]string{}
+	}
+	store.Data[wfContext.ConfigMapKeyContext] = string(b)
+	return nil
+}
+
 func (w *workflowExecutor) setMetadataToContext(wfCtx wfContext.Context) error {
 	copierMeta := types.WorkflowMeta{
 		Name:        w.instance.Name,
@@ -426,7 +594,7 @@ func (e *engine) getBackoffWaitTime() int {
 
 func (e *engine) getMaxBackoffWaitTime() int {
 	for _, step := range e.status.Steps {
-		if step.Phase == v1alpha1.WorkflowStepPhaseFailed {
+		if step.Phase == v1alpha1.WorkflowStepPhaseFailed && !types.IsStepFailureIgnored(step.StepStatus) {
 			return types.MaxWorkflowFailedBackoffTime
 		}
 	}
@@ -436,7 +604,7 @@ func (e *engine) getMaxBackoffWaitTime() int {
 func (e *engine) getNextTimeout() int64 {
 	max := time.Duration(1<<63 - 1)
 	min := time.Duration(1<<63 - 1)
-	now := time.Now()
+	now := e.clock.Now()
 	for _, step := range e.status.Steps {
 		if step.Phase == v1alpha1.WorkflowStepPhaseRunning {
 			if timeout, ok := e.stepTimeout[step.Name]; ok {
@@ -447,6 +615,9 @@ func (e *engine) getNextTimeout() int64 {
 			}
 		}
 	}
+	if deadline := workflowDeadline(e.instance); !deadline.IsZero() && deadline.Sub(now) < min {
+		min = deadline.Sub(now)
+	}
 	if min == max {
 		return -1
 	}
@@ -458,6 +629,9 @@ func (e *engine) getNextTimeout() int64 {
 
 func (e *engine) setNextExecuteTime() {
 	backoff := e.getBackoffWaitTime()
+	if resync := e.watchResyncPeriod(); resync > backoff {
+		backoff = resync
+	}
 	lastExecuteTime, ok := e.wfCtx.GetValueInMemory(types.ContextKeyLastExecuteTime)
 	if !ok {
 		e.monitorCtx.Error(fmt.Errorf("failed to get last execute time"), "workflow run", e.instance.Name)
@@ -468,6 +642,9 @@ func (e *engine) setNextExecuteTime() {
 		e.monitorCtx.Error(fmt.Errorf("failed to parse last execute time to int64"), "lastExecuteTime", lastExecuteTime)
 	}
 	interval := int64(backoff)
+	if wait := e.windowWait(); wait > 0 {
+		interval = wait
+	}
 	if timeout := e.getNextTimeout(); timeout > 0 && timeout < interval {
 		interval = timeout
 	}
@@ -538,12 +715,15 @@ func (e *engine) Run(ctx monitorContext.Context, taskRunners []types.TaskRunner,
 	}
 
 	e.checkFailedAfterRetries()
+	e.checkFailingRepeatedly()
 	e.setNextExecuteTime()
 	return err
 }
 
 func (e *engine) checkWorkflowStatusMessage(wfStatus *v1alpha1.WorkflowRunStatus) {
 	switch {
+	case e.failingStep != "" && !wfStatus.Terminated:
+		e.status.Message = fmt.Sprintf(types.MessageSuspendFailingRepeatedly, e.failingStep)
 	case !e.waiting && e.failedAfterRetries && feature.DefaultMutableFeatureGate.Enabled(features.EnableSuspendOnFailure):
 		e.status.Message = types.MessageSuspendFailedAfterRetries
 	case wfStatus.Terminated && !feature.DefaultMutableFeatureGate.Enabled(features.EnableSuspendOnFailure):
@@ -569,31 +749,63 @@ func (e *engine) steps(ctx monitorContext.Context, taskRunners []types.TaskRunne
 			}
 			return nil
 		}
+		if !e.admit(runner.Name()) {
+			// the controller is draining, the step is started after the run is reconciled again
+			e.waiting = true
+			if dag {
+				continue
+			}
+			return nil
+		}
 		options := e.generateRunOptions(e.findDependPhase(taskRunners, index, dag))
 
-		status, operation, err := runner.Run(wfCtx, options)
-		if err != nil {
-			return err
+		var (
+			status    v1alpha1.StepStatus
+			operation *types.Operation
+			err       error
+		)
+		if failed, ok := e.onStepStart(ctx, runner.Name()); ok {
+			status, operation = failed, &types.Operation{FailedAfterRetries: true}
+		} else if injected, op, ok := e.injectFault(ctx, runner.Name()); ok {
+			status, operation = injected, op
+		} else {
+			status, operation, err = runner.Run(wfCtx, options)
+			if err != nil {
+				return err
+			}
 		}
+		if types.IsStepFinish(status.Phase, status.Reason) {
+			status = e.onStepComplete(ctx, status)
+			status = e.collectLogs(ctx, status)
+			status = e.inlineOutputs(ctx, status)
+			// the step failed by the hooks is not retried, the failure is left to its onFailure policy
+			if status.Reason == types.StatusReasonHook {
+				operation.FailedAfterRetries = true
+			}
+		}
+		status, operation = e.applyFailurePolicy(status, operation)
 
 		e.updateStepStatus(status)
+		e.recordFailingStep(status.Name)
 
 		e.failedAfterRetries = e.failedAfterRetries || operation.FailedAfterRetries
 		e.waiting = e.waiting || operation.Waiting
+		// the writes of the step yielding with wait are committed before it's executed again
+		if operation.Waiting {
+			if err := wfCtx.Commit(); err != nil {
+				return errors.WithMessage(err, "commit workflow context")
+			}
+		}
 		// for the suspend step with duration, there's no need to increase the backoff time in reconcile when it's still running
 		if !types.IsStepFinish(status.Phase, status.Reason) && !isWaitSuspendStep(status) {
-			if err := handleBackoffTimes(wfCtx, status, false); err != nil {
-				return err
-			}
+			handleBackoffTimes(wfCtx, status, false)
 			if dag {
 				continue
 			}
 			return nil
 		}
 		// clear the backoff time when the step is finished
-		if err := handleBackoffTimes(wfCtx, status, true); err != nil {
-			return err
-		}
+		handleBackoffTimes(wfCtx, status, true)
 
 		e.finishStep(operation)
 		if dag {
@@ -606,6 +818,17 @@ func (e *engine) steps(ctx monitorContext.Context, taskRunners []types.TaskRunne
 	return nil
 }
 
+// admit returns whether the step can be executed, the steps that are not started are not admitted by the draining gate
+// or when the workflow run is terminating gracefully
+func (e *engine) admit(name string) bool {
+	terminating := e.status.GracefulTermination != nil
+	if !terminating && (e.gate == nil || e.gate.Admit()) {
+		return true
+	}
+	status, ok := e.stepStatus[name]
+	return ok && status.Phase != "" && status.Phase != v1alpha1.WorkflowStepPhasePending
+}
+
 func (e *engine) generateRunOptions(dependsOnPhase v1alpha1.WorkflowStepPhase) *types.TaskRunOptions {
 	options := &types.TaskRunOptions{
 		GetTracer: func(id string, stepStatus v1alpha1.WorkflowStep) monitorContext.Context {
@@ -615,6 +838,8 @@ func (e *engine) generateRunOptions(dependsOnPhase v1alpha1.WorkflowStepPhase) *
 		},
 		StepStatus: e.stepStatus,
 		Engine:     e,
+		Clock:      e.clock,
+		Audit:      e.auditFunc(),
 		PreCheckHooks: []types.TaskPreCheckHook{
 			func(step v1alpha1.WorkflowStep, options *types.PreCheckOptions) (*types.PreCheckResult, error) {
 				if feature.DefaultMutableFeatureGate.Enabled(features.EnableSuspendOnFailure) {
@@ -622,30 +847,51 @@ func (e *engine) generateRunOptions(dependsOnPhase v1alpha1.WorkflowStepPhase) *
 				}
 				if e.parentRunner != "" {
 					if status, ok := e.stepStatus[e.parentRunner]; ok && status.Phase == v1alpha1.WorkflowStepPhaseSkipped {
-						return &types.PreCheckResult{Skip: true}, nil
+						return &types.PreCheckResult{Skip: true, Message: fmt.Sprintf("skipped: the parent step %s is skipped", e.parentRunner)}, nil
 					}
 				}
 				switch step.If {
 				case "always":
 					return &types.PreCheckResult{Skip: false}, nil
 				case "":
-					return &types.PreCheckResult{Skip: isUnsuccessfulStep(dependsOnPhase)}, nil
+					if isUnsuccessfulStep(dependsOnPhase) {
+						return &types.PreCheckResult{Skip: true, Message: fmt.Sprintf("skipped: the previous step is %s", dependsOnPhase)}, nil
+					}
+					return &types.PreCheckResult{Skip: false}, nil
 				default:
-					ifValue, err := custom.ValidateIfValue(e.wfCtx, step, e.stepStatus, options)
+					// the if of the sub step is evaluated with the inputs of its step group and the outputs of its siblings
+					scoped := *options
+					scoped.Steps = e.instance.Steps
+					if e.parentRunner != "" {
+						if parent, ok := e.findStep(e.parentRunner); ok {
+							scoped.ParentStep = &parent
+						}
+					}
+					ifValue, message, err := custom.EvaluateIfValue(e.wfCtx, step, e.stepStatus, &scoped)
 					if err != nil {
-						return &types.PreCheckResult{Skip: true}, err
+						if message != "" {
+							return &types.PreCheckResult{Skip: true, Message: "skipped: " + message}, nil
+						}
+						return &types.PreCheckResult{Failed: true, Message: fmt.Sprintf("if %q could not be evaluated: %s", step.If, err.Error())}, nil
+					}
+					if !ifValue {
+						return &types.PreCheckResult{Skip: true, Message: "skipped: " + message}, nil
 					}
-					return &types.PreCheckResult{Skip: !ifValue}, nil
+					return &types.PreCheckResult{Skip: false}, nil
 				}
 			},
+			func(step v1alpha1.WorkflowStep, options *types.PreCheckOptions) (*types.PreCheckResult, error) {
+				return e.checkExecuteWindow(step), nil
+			},
 			func(step v1alpha1.WorkflowStep, options *types.PreCheckOptions) (*types.PreCheckResult, error) {
 				status := e.stepStatus[step.Name]
 				if e.parentRunner != "" {
 					if status, ok := e.stepStatus[e.parentRunner]; ok && status.Phase == v1alpha1.WorkflowStepPhaseFailed && status.Reason == types.StatusReasonTimeout {
-						return &types.PreCheckResult{Timeout: true}, nil
+						return &types.PreCheckResult{Timeout: true, ParentTimeout: true}, nil
 					}
 				}
-				if !status.FirstExecuteTime.Time.IsZero() && step.Timeout != "" {
+				// the step waiting for its execute window is not timed out, its timeout starts in the window
+				if !status.FirstExecuteTime.Time.IsZero() && step.Timeout != "" && status.Reason != types.StatusReasonExecuteWindow {
 					duration, err := time.ParseDuration(step.Timeout)
 					if err != nil {
 						// if the timeout is a invalid duration, return {timeout: false}
@@ -653,7 +899,7 @@ func (e *engine) generateRunOptions(dependsOnPhase v1alpha1.WorkflowStepPhase) *
 					}
 					timeout := status.FirstExecuteTime.Add(duration)
 					e.stepTimeout[step.Name] = timeout
-					if time.Now().After(timeout) {
+					if e.clock.Now().After(timeout) {
 						return &types.PreCheckResult{Timeout: true}, nil
 					}
 				}
@@ -664,9 +910,15 @@ func (e *engine) generateRunOptions(dependsOnPhase v1alpha1.WorkflowStepPhase) *
 		PostStopHooks: []types.TaskPostStopHook{hooks.Output},
 	}
 	if e.debug {
-		options.Debug = func(step string, v *value.Value) error {
-			debugContext := debug.NewContext(e.cli, e.instance, step)
-			if err := debugContext.Set(v); err != nil {
+		options.DebugStep = func(step string) bool {
+			return debug.IsStepEnabled(e.instance, step)
+		}
+		options.Debug = func(step string, v *value.Value, stepErr error, trace []types.OpTrace) error {
+			if !options.DebugStep(step) {
+				return nil
+			}
+			debugContext := debug.NewContext(e.cli, e.instance, step, e.sensitiveValues()...)
+			if err := debugContext.SetWithTrace(v, stepErr, trace); err != nil {
 				return err
 			}
 			return nil
@@ -675,6 +927,22 @@ func (e *engine) generateRunOptions(dependsOnPhase v1alpha1.WorkflowStepPhase) *
 	return options
 }
 
+// sensitiveValues returns the values of the sensitive vars, which are redacted in the debug snapshots
+func (e *engine) sensitiveValues() []string {
+	var values []string
+	if e.wfCtx == nil {
+		return values
+	}
+	if vars, ok := e.wfCtx.GetValueInMemory(types.ContextKeySensitiveVars); ok {
+		if m, ok := vars.(map[string]string); ok {
+			for _, v := range m {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
 type engine struct {
 	failedAfterRetries bool
 	waiting            bool
@@ -688,6 +956,17 @@ type engine struct {
 	stepStatus         map[string]v1alpha1.StepStatus
 	stepTimeout        map[string]time.Time
 	stepDependsOn      map[string][]string
+	stepHooks          []types.StepHook
+	failOnHookError    bool
+	gate               *Gate
+	kubeClient         kubernetes.Interface
+	faults             *faults
+	auditSink          types.AuditSink
+	// failingStep is the step failed consecutively for the max times, the run is suspended by it
+	failingStep string
+	clock       clock.Clock
+	// stepWindow is the start of the next execute window of the steps waiting for it
+	stepWindow map[string]time.Time
 }
 
 func (e *engine) finishStep(operation *types.Operation) {
@@ -700,7 +979,7 @@ func (e *engine) finishStep(operation *types.Operation) {
 func (e *engine) updateStepStatus(status v1alpha1.StepStatus) {
 	var (
 		conditionUpdated bool
-		now              = metav1.NewTime(time.Now())
+		now              = metav1.NewTime(e.clock.Now())
 	)
 
 	parentRunner := e.parentRunner
@@ -710,6 +989,11 @@ func (e *engine) updateStepStatus(status v1alpha1.StepStatus) {
 	}
 	e.wfCtx.SetValueInMemory(now.Unix(), types.ContextKeyLastExecuteTime)
 	status.LastExecuteTime = now
+	if step, ok := e.findStep(status.Name); ok {
+		status.Timeout = step.Timeout
+	}
+	e.truncateMessages(&status)
+	status = e.capWatches(status)
 	index := -1
 	for i, ss := range e.status.Steps {
 		if ss.Name == stepName {
@@ -718,7 +1002,13 @@ func (e *engine) updateStepStatus(status v1alpha1.StepStatus) {
 				// update the sub steps status
 				for j, sub := range ss.SubStepsStatus {
 					if sub.Name == status.Name {
-						status.FirstExecuteTime = sub.FirstExecuteTime
+						status.FirstExecuteTime = firstExecuteTime(sub, now)
+						status.PropertiesHash = sub.PropertiesHash
+						if status.DefinitionRevision == "" {
+							status.DefinitionRevision = sub.DefinitionRevision
+						}
+						status.ConsecutiveFailures = e.consecutiveFailures(sub, status)
+						status.MessageHistory = custom.AppendMessageHistory(sub.MessageHistory, status.MessageHistory...)
 						e.status.Steps[i].SubStepsStatus[j] = status
 						conditionUpdated = true
 						break
@@ -726,7 +1016,13 @@ func (e *engine) updateStepStatus(status v1alpha1.StepStatus) {
 				}
 			} else {
 				// update the parent steps status
-				status.FirstExecuteTime = ss.FirstExecuteTime
+				status.FirstExecuteTime = firstExecuteTime(ss.StepStatus, now)
+				status.PropertiesHash = ss.PropertiesHash
+				if status.DefinitionRevision == "" {
+					status.DefinitionRevision = ss.DefinitionRevision
+				}
+				status.ConsecutiveFailures = e.consecutiveFailures(ss.StepStatus, status)
+				status.MessageHistory = custom.AppendMessageHistory(ss.MessageHistory, status.MessageHistory...)
 				e.status.Steps[i].StepStatus = status
 				conditionUpdated = true
 				break
@@ -735,12 +1031,15 @@ func (e *engine) updateStepStatus(status v1alpha1.StepStatus) {
 	}
 	if !conditionUpdated {
 		status.FirstExecuteTime = now
+		status.PropertiesHash = e.propertiesHash(status.Name)
+		status.ConsecutiveFailures = e.consecutiveFailures(v1alpha1.StepStatus{}, status)
 		if parentRunner != "" {
 			if index < 0 {
 				e.status.Steps = append(e.status.Steps, v1alpha1.WorkflowStepStatus{
 					StepStatus: v1alpha1.StepStatus{
 						Name:             parentRunner,
 						FirstExecuteTime: now,
+						PropertiesHash:   e.propertiesHash(parentRunner),
 					}})
 				index = len(e.status.Steps) - 1
 			}
@@ -750,6 +1049,72 @@ func (e *engine) updateStepStatus(status v1alpha1.StepStatus) {
 		}
 	}
 	e.stepStatus[status.Name] = status
+	if e.instance.LiveProgress {
+		if err := progress.Report(e.monitorCtx, e.cli, e.instance, status.Name, status.Phase); err != nil {
+			e.monitorCtx.Error(err, "report live progress", "step", status.Name)
+		}
+	}
+}
+
+// propertiesHash returns the hash of the properties of the step in the spec, it's empty if the step is not found
+func (e *engine) propertiesHash(name string) string {
+	for _, step := range e.instance.Steps {
+		for _, base := range append([]v1alpha1.WorkflowStepBase{step.WorkflowStepBase}, step.SubSteps...) {
+			if base.Name != name {
+				continue
+			}
+			hash, err := custom.GetPropertiesHash(base)
+			if err != nil {
+				return ""
+			}
+			return hash
+		}
+	}
+	return ""
+}
+
+// consecutiveFailures counts the consecutive failed executions of the step if the max consecutive failures is set,
+// it's reset after the step succeeds
+func (e *engine) consecutiveFailures(last, current v1alpha1.StepStatus) int {
+	if e.instance.MaxConsecutiveFailures <= 0 {
+		return 0
+	}
+	switch current.Phase {
+	case v1alpha1.WorkflowStepPhaseFailed:
+		return last.ConsecutiveFailures + 1
+	case v1alpha1.WorkflowStepPhaseSucceeded, v1alpha1.WorkflowStepPhaseSkipped:
+		return 0
+	default:
+		return last.ConsecutiveFailures
+	}
+}
+
+// recordFailingStep records the step if it's still retried after failing consecutively for the max times
+func (e *engine) recordFailingStep(name string) {
+	maxFailures := e.instance.MaxConsecutiveFailures
+	if maxFailures <= 0 || e.failingStep != "" {
+		return
+	}
+	status := e.stepStatus[name]
+	if !types.IsStepFinish(status.Phase, status.Reason) && status.ConsecutiveFailures >= maxFailures {
+		e.failingStep = name
+	}
+}
+
+// checkFailingRepeatedly suspends the run if a step has failed consecutively for the max times instead of
+// retrying it in every reconcile, the run needs to be resumed manually.
+func (e *engine) checkFailingRepeatedly() {
+	if e.failingStep == "" || e.status.Terminated {
+		return
+	}
+	e.status.Suspend = true
+	e.status.SetConditions(condition.Condition{
+		Type:               condition.ConditionType(v1alpha1.WorkflowRunConditionType),
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             types.StatusReasonFailingRepeatedly,
+		Message:            fmt.Sprintf(types.MessageSuspendFailingRepeatedly, e.failingStep),
+	})
 }
 
 func (e *engine) checkFailedAfterRetries() {
@@ -777,17 +1142,26 @@ func (e *engine) findDependPhase(taskRunners []types.TaskRunner, index int, dag
 		return v1alpha1.WorkflowStepPhaseSucceeded
 	}
 	for i := index - 1; i >= 0; i-- {
-		if isUnsuccessfulStep(e.stepStatus[taskRunners[i].Name()].Phase) {
-			return e.stepStatus[taskRunners[i].Name()].Phase
+		if phase := e.dependPhase(taskRunners[i].Name()); isUnsuccessfulStep(phase) {
+			return phase
 		}
 	}
-	return e.stepStatus[taskRunners[index-1].Name()].Phase
+	return e.dependPhase(taskRunners[index-1].Name())
 }
 
 func (e *engine) findDependsOnPhase(name string) v1alpha1.WorkflowStepPhase {
 	for _, dependsOn := range e.stepDependsOn[name] {
-		if e.stepStatus[dependsOn].Phase != v1alpha1.WorkflowStepPhaseSucceeded {
-			return e.stepStatus[dependsOn].Phase
+		phase := e.dependPhase(dependsOn)
+		// depending on the step skipped by its if is satisfied with its outputs absent, while the step skipped
+		// because of the unsuccessful steps it depends on passes the phase of them to its dependents
+		if phase == v1alpha1.WorkflowStepPhaseSkipped {
+			if result := e.findDependsOnPhase(dependsOn); isUnsuccessfulStep(result) {
+				return result
+			}
+			continue
+		}
+		if phase != v1alpha1.WorkflowStepPhaseSucceeded {
+			return phase
 		}
 		if result := e.findDependsOnPhase(dependsOn); isUnsuccessfulStep(result) {
 			return result
@@ -804,7 +1178,7 @@ func isWaitSuspendStep(step v1alpha1.StepStatus) bool {
 	return step.Type == types.WorkflowStepTypeSuspend && step.Phase == v1alpha1.WorkflowStepPhaseRunning
 }
 
-func handleBackoffTimes(wfCtx wfContext.Context, status v1alpha1.StepStatus, clear bool) error {
+func handleBackoffTimes(wfCtx wfContext.Context, status v1alpha1.StepStatus, clear bool) {
 	if clear {
 		wfCtx.DeleteValueInMemory(types.ContextPrefixBackoffTimes, status.ID)
 		wfCtx.DeleteValueInMemory(types.ContextPrefixBackoffReason, status.ID)
@@ -815,10 +1189,6 @@ func handleBackoffTimes(wfCtx wfContext.Context, status v1alpha1.StepStatus, cle
 		}
 		wfCtx.IncreaseCountValueInMemory(types.ContextPrefixBackoffTimes, status.ID)
 	}
-	if err := wfCtx.Commit(); err != nil {
-		return errors.WithMessage(err, "commit workflow context")
-	}
-	return nil
 }
 
 func (e *engine) cleanBackoffTimesForTerminated() {
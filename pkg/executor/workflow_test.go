@@ -35,6 +35,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	utilfeature "k8s.io/apiserver/pkg/util/feature"
 	featuregatetesting "k8s.io/component-base/featuregate/testing"
+	"k8s.io/utils/clock"
+	testingclock "k8s.io/utils/clock/testing"
 	"k8s.io/utils/pointer"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
@@ -172,6 +174,98 @@ var _ = Describe("Test Workflow", func() {
 		})).Should(BeEquivalentTo(""))
 	})
 
+	It("Workflow test records the properties hash", func() {
+		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name:       "s1",
+					Type:       "success",
+					Properties: &runtime.RawExtension{Raw: []byte(`{"b":1,"a":"x"}`)},
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s2",
+					Type: "step-group",
+				},
+				SubSteps: []v1alpha1.WorkflowStepBase{
+					{
+						Name:       "s2-sub1",
+						Type:       "success",
+						Properties: &runtime.RawExtension{Raw: []byte(`{"c":true}`)},
+					},
+				},
+			},
+		})
+		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+		wf := New(instance, k8sClient)
+		_, err := wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		hash, err := custom.GetPropertiesHash(v1alpha1.WorkflowStepBase{Properties: &runtime.RawExtension{Raw: []byte(`{"a":"x","b":1}`)}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(instance.Status.Steps[0].PropertiesHash).Should(Equal(hash))
+		subHash, err := custom.GetPropertiesHash(instance.Steps[1].SubSteps[0])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(instance.Status.Steps[1].SubStepsStatus[0].PropertiesHash).Should(Equal(subHash))
+
+		// the hash recorded when the step is first executed is kept after the properties are changed
+		instance.Steps[0].Properties = &runtime.RawExtension{Raw: []byte(`{"a":"y"}`)}
+		instance.Status.Steps[0].Phase = v1alpha1.WorkflowStepPhaseRunning
+		wf = New(instance, k8sClient)
+		_, err = wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(instance.Status.Steps[0].PropertiesHash).Should(Equal(hash))
+	})
+
+	It("Workflow test suspends the run failing repeatedly", func() {
+		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s1",
+					Type: "success",
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s2",
+					Type: "failed",
+				},
+			},
+		})
+		instance.MaxConsecutiveFailures = 2
+		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+		wf := New(instance, k8sClient)
+		state, err := wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateExecuting))
+		Expect(instance.Status.Steps[1].ConsecutiveFailures).Should(Equal(1))
+
+		wf = New(instance, k8sClient)
+		state, err = wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateSuspending))
+		Expect(instance.Status.Steps[1].ConsecutiveFailures).Should(Equal(2))
+		Expect(instance.Status.Suspend).Should(BeTrue())
+		Expect(instance.Status.Message).Should(Equal("step s2 failing repeatedly, manual intervention required"))
+		Expect(instance.Status.Conditions).Should(HaveLen(1))
+		Expect(string(instance.Status.Conditions[0].Reason)).Should(Equal(types.StatusReasonFailingRepeatedly))
+
+		// the suspended run is not executed until it's resumed
+		wf = New(instance, k8sClient)
+		state, err = wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateSuspending))
+		Expect(instance.Status.Steps[1].ConsecutiveFailures).Should(Equal(2))
+
+		// resuming the run resets the consecutive failures
+		instance.Status.Suspend = false
+		wf = New(instance, k8sClient)
+		state, err = wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateExecuting))
+		Expect(instance.Status.Steps[1].ConsecutiveFailures).Should(Equal(1))
+	})
+
 	It("Workflow test failed with sub steps", func() {
 		By("Test failed with step group")
 		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
@@ -271,11 +365,12 @@ var _ = Describe("Test Workflow", func() {
 			},
 		})
 		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
-		wf := New(instance, k8sClient)
+		fakeClock := testingclock.NewFakeClock(time.Now())
+		wf := New(instance, k8sClient, WithClock(fakeClock))
 		state, err := wf.ExecuteRunners(ctx, runners)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateExecuting))
-		time.Sleep(1 * time.Second)
+		fakeClock.Step(time.Second)
 		state, err = wf.ExecuteRunners(ctx, runners)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateFailed))
@@ -319,6 +414,33 @@ var _ = Describe("Test Workflow", func() {
 		})).Should(BeEquivalentTo(""))
 	})
 
+	It("Workflow test fails the step whose if could not be evaluated", func() {
+		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s1",
+					Type: "success",
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s2",
+					If:   `status.s1.phase + 1`,
+					Type: "success",
+				},
+			},
+		})
+		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+		wf := New(instance, k8sClient)
+		state, err := wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateFailed))
+		s2 := instance.Status.Steps[1]
+		Expect(s2.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStepPhaseFailed))
+		Expect(s2.Reason).Should(BeEquivalentTo(types.StatusReasonCondition))
+		Expect(s2.Message).Should(ContainSubstring(`if "status.s1.phase + 1" could not be evaluated`))
+	})
+
 	It("Workflow test for timeout with suspend", func() {
 		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
 			{
@@ -357,11 +479,12 @@ var _ = Describe("Test Workflow", func() {
 			},
 		})
 		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
-		wf := New(instance, k8sClient)
+		fakeClock := testingclock.NewFakeClock(time.Now())
+		wf := New(instance, k8sClient, WithClock(fakeClock))
 		state, err := wf.ExecuteRunners(ctx, runners)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateSuspending))
-		time.Sleep(1 * time.Second)
+		fakeClock.Step(time.Second)
 		state, err = wf.ExecuteRunners(ctx, runners)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateFailed))
@@ -450,11 +573,12 @@ var _ = Describe("Test Workflow", func() {
 			},
 		})
 		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
-		wf := New(instance, k8sClient)
+		fakeClock := testingclock.NewFakeClock(time.Now())
+		wf := New(instance, k8sClient, WithClock(fakeClock))
 		state, err := wf.ExecuteRunners(ctx, runners)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateSuspending))
-		time.Sleep(1 * time.Second)
+		fakeClock.Step(time.Second)
 		state, err = wf.ExecuteRunners(ctx, runners)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateFailed))
@@ -542,11 +666,12 @@ var _ = Describe("Test Workflow", func() {
 			},
 		})
 		ctx = monitorContext.NewTraceContext(context.Background(), "test-app")
-		wf = New(instance, k8sClient)
+		fakeClock = testingclock.NewFakeClock(time.Now())
+		wf = New(instance, k8sClient, WithClock(fakeClock))
 		state, err = wf.ExecuteRunners(ctx, runners)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateSuspending))
-		time.Sleep(1 * time.Second)
+		fakeClock.Step(time.Second)
 		state, err = wf.ExecuteRunners(ctx, runners)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateFailed))
@@ -651,10 +776,11 @@ var _ = Describe("Test Workflow", func() {
 				},
 			}, {
 				StepStatus: v1alpha1.StepStatus{
-					Name:   "s2",
-					Type:   "step-group",
-					Phase:  v1alpha1.WorkflowStepPhaseSkipped,
-					Reason: types.StatusReasonSkip,
+					Name:    "s2",
+					Type:    "step-group",
+					Phase:   v1alpha1.WorkflowStepPhaseSkipped,
+					Reason:  types.StatusReasonSkip,
+					Message: "skipped: the previous step is failed",
 				},
 				SubStepsStatus: []v1alpha1.StepStatus{
 					{
@@ -1434,10 +1560,11 @@ var _ = Describe("Test Workflow", func() {
 				},
 			}, {
 				StepStatus: v1alpha1.StepStatus{
-					Name:   "s2",
-					Type:   "step-group",
-					Phase:  v1alpha1.WorkflowStepPhaseSkipped,
-					Reason: types.StatusReasonSkip,
+					Name:    "s2",
+					Type:    "step-group",
+					Phase:   v1alpha1.WorkflowStepPhaseSkipped,
+					Reason:  types.StatusReasonSkip,
+					Message: `skipped: if "status.s1.timeout" evaluated to false (status.s1.timeout=false)`,
 				},
 				SubStepsStatus: []v1alpha1.StepStatus{
 					{
@@ -1566,6 +1693,7 @@ var _ = Describe("Test Workflow", func() {
 		e := &engine{
 			status: &instance.Status,
 			wfCtx:  wfCtx,
+			clock:  clock.RealClock{},
 		}
 		interval := e.getBackoffWaitTime()
 		Expect(interval).Should(BeEquivalentTo(minWorkflowBackoffWaitTime))
@@ -1601,6 +1729,7 @@ var _ = Describe("Test Workflow", func() {
 		e = &engine{
 			status: &instance.Status,
 			wfCtx:  wfCtx,
+			clock:  clock.RealClock{},
 		}
 		interval = e.getBackoffWaitTime()
 		Expect(interval).Should(BeEquivalentTo(minWorkflowBackoffWaitTime))
@@ -2284,6 +2413,18 @@ func makeRunner(step v1alpha1.WorkflowStep, subTaskRunners []types.TaskRunner) t
 					FailedAfterRetries: true,
 				}, nil
 		}
+	case "failed-job":
+		// the run-job step fails with the reason of the job
+		run = func(ctx wfContext.Context, options *types.TaskRunOptions) (v1alpha1.StepStatus, *types.Operation, error) {
+			return v1alpha1.StepStatus{
+					Name:   step.Name,
+					Type:   "failed-job",
+					Phase:  v1alpha1.WorkflowStepPhaseFailed,
+					Reason: "BackoffLimitExceeded",
+				}, &types.Operation{
+					Terminated: true,
+				}, nil
+		}
 	case "error":
 		run = func(ctx wfContext.Context, options *types.TaskRunOptions) (v1alpha1.StepStatus, *types.Operation, error) {
 			return v1alpha1.StepStatus{
@@ -2383,6 +2524,24 @@ func (tr *testTaskRunner) Run(ctx wfContext.Context, options *types.TaskRunOptio
 					Reason: types.StatusReasonSkip,
 				}, &types.Operation{Skip: true}, nil
 			}
+			if result.Failed {
+				return v1alpha1.StepStatus{
+					Name:    tr.step.Name,
+					Type:    tr.step.Type,
+					Phase:   v1alpha1.WorkflowStepPhaseFailed,
+					Reason:  types.StatusReasonCondition,
+					Message: result.Message,
+				}, &types.Operation{Terminated: true}, nil
+			}
+			if result.Wait {
+				return v1alpha1.StepStatus{
+					Name:    tr.step.Name,
+					Type:    tr.step.Type,
+					Phase:   v1alpha1.WorkflowStepPhaseRunning,
+					Reason:  types.StatusReasonExecuteWindow,
+					Message: result.Message,
+				}, &types.Operation{Waiting: true}, nil
+			}
 			if result.Timeout {
 				return v1alpha1.StepStatus{
 					Name:   tr.step.Name,
@@ -2406,10 +2565,12 @@ func cleanStepTimeStamp(wfStatus *v1alpha1.WorkflowRunStatus) {
 	for index, step := range wfStatus.Steps {
 		wfStatus.Steps[index].FirstExecuteTime = metav1.Time{}
 		wfStatus.Steps[index].LastExecuteTime = metav1.Time{}
+		wfStatus.Steps[index].PropertiesHash = ""
 		if step.SubStepsStatus != nil {
 			for indexSubStep := range step.SubStepsStatus {
 				wfStatus.Steps[index].SubStepsStatus[indexSubStep].FirstExecuteTime = metav1.Time{}
 				wfStatus.Steps[index].SubStepsStatus[indexSubStep].LastExecuteTime = metav1.Time{}
+				wfStatus.Steps[index].SubStepsStatus[indexSubStep].PropertiesHash = ""
 			}
 		}
 	}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+)
+
+// Outputs writes the exported outputs of the workflow run into the target config map or secret.
+// The target is owned by the workflow run, so it's garbage collected with the run.
+func Outputs(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun, wfCtx wfContext.Context) error {
+	spec := run.Spec.ExportOutputs
+	if spec == nil {
+		return nil
+	}
+	if (spec.ConfigMapName == "") == (spec.SecretName == "") {
+		return errors.New("exactly one of configMapName and secretName is required to export outputs")
+	}
+	data, err := Collect(wfCtx, spec.Keys)
+	if err != nil {
+		return err
+	}
+	owner := metav1.OwnerReference{
+		APIVersion: v1alpha1.SchemeGroupVersion.String(),
+		Kind:       v1alpha1.WorkflowRunKind,
+		Name:       run.Name,
+		UID:        run.UID,
+		Controller: pointer.BoolPtr(true),
+	}
+	if spec.SecretName != "" {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: spec.SecretName, Namespace: run.Namespace}}
+		_, err = controllerutil.CreateOrUpdate(ctx, cli, secret, func() error {
+			secret.SetOwnerReferences([]metav1.OwnerReference{owner})
+			secret.StringData = nil
+			secret.Data = map[string][]byte{}
+			for k, v := range data {
+				secret.Data[k] = []byte(v)
+			}
+			return nil
+		})
+		return errors.WithMessagef(err, "export outputs to secret %s", spec.SecretName)
+	}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: spec.ConfigMapName, Namespace: run.Namespace}}
+	_, err = controllerutil.CreateOrUpdate(ctx, cli, cm, func() error {
+		cm.SetOwnerReferences([]metav1.OwnerReference{owner})
+		cm.Data = data
+		return nil
+	})
+	return errors.WithMessagef(err, "export outputs to config map %s", spec.ConfigMapName)
+}
+
+// Collect gets the values of the keys from the workflow context, the strings are kept as is
+// and the others are encoded in JSON with the sorted fields.
+func Collect(wfCtx wfContext.Context, keys []string) (map[string]string, error) {
+	data := make(map[string]string, len(keys))
+	for _, key := range keys {
+		v, err := wfContext.LookupVar(wfCtx, key)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "get output %s", key)
+		}
+		var i interface{}
+		if err := v.CueValue().Decode(&i); err != nil {
+			return nil, errors.WithMessagef(err, "decode output %s", key)
+		}
+		if s, ok := i.(string); ok {
+			data[key] = s
+			continue
+		}
+		b, err := json.Marshal(i)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "encode output %s", key)
+		}
+		data[key] = string(b)
+	}
+	return data, nil
+}
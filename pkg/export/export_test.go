@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+func TestOutputs(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().Build()
+	wfCtx, err := wfContext.NewContext(cli, "default", "test", nil)
+	r.NoError(err)
+	v, err := value.NewValue(`
+digest: "sha256:abc"
+endpoint: {
+	port: 80
+	host: "example.com"
+}
+replicas: 3
+`, nil, "")
+	r.NoError(err)
+	r.NoError(wfCtx.SetVar(v, "build"))
+
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"},
+		Spec: v1alpha1.WorkflowRunSpec{
+			ExportOutputs: &v1alpha1.ExportOutputs{
+				ConfigMapName: "outputs",
+				Keys:          []string{"build.digest", "build.endpoint", "build.replicas"},
+			},
+		},
+	}
+	r.NoError(Outputs(ctx, cli, run, wfCtx))
+	cm := &corev1.ConfigMap{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "outputs"}, cm))
+	r.Equal(map[string]string{
+		"build.digest":   "sha256:abc",
+		"build.endpoint": `{"host":"example.com","port":80}`,
+		"build.replicas": "3",
+	}, cm.Data)
+	r.Equal("test", cm.OwnerReferences[0].Name)
+
+	run.Spec.ExportOutputs = &v1alpha1.ExportOutputs{
+		SecretName: "outputs",
+		Keys:       []string{"build.digest"},
+	}
+	r.NoError(Outputs(ctx, cli, run, wfCtx))
+	secret := &corev1.Secret{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "outputs"}, secret))
+	r.Equal(map[string][]byte{"build.digest": []byte("sha256:abc")}, secret.Data)
+
+	run.Spec.ExportOutputs.Keys = []string{"build.notfound"}
+	r.Error(Outputs(ctx, cli, run, wfCtx))
+	run.Spec.ExportOutputs.ConfigMapName = "outputs"
+	r.Error(Outputs(ctx, cli, run, wfCtx))
+	run.Spec.ExportOutputs = nil
+	r.NoError(Outputs(ctx, cli, run, wfCtx))
+}
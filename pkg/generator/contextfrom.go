@@ -0,0 +1,126 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+// ContextSourceNotFoundError means the required source of spec.contextFrom or its key is not found
+type ContextSourceNotFoundError struct {
+	Source string
+	Key    string
+}
+
+// Error implements the Error interface.
+func (e ContextSourceNotFoundError) Error() string {
+	if e.Key != "" {
+		return fmt.Sprintf("the key %s of the source %s of spec.contextFrom is not found", e.Key, e.Source)
+	}
+	return fmt.Sprintf("the source %s of spec.contextFrom is not found", e.Source)
+}
+
+// IsContextSourceNotFoundErr returns true if the specified error is ContextSourceNotFoundError type.
+func IsContextSourceNotFoundErr(err error) bool {
+	return errors.As(err, &ContextSourceNotFoundError{})
+}
+
+// contextFromResult is the vars imported by spec.contextFrom
+type contextFromResult struct {
+	vars      map[string]string
+	sensitive map[string]string
+	// skipped is the messages of the optional sources and keys which are not found
+	skipped []string
+}
+
+// resolveContextFrom reads the keys of the sources in spec.contextFrom as the vars, the values of the Secrets are
+// sensitive. The later sources override the former ones for the same var.
+func resolveContextFrom(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) (*contextFromResult, error) {
+	result := &contextFromResult{vars: map[string]string{}, sensitive: map[string]string{}}
+	for _, from := range run.Spec.ContextFrom {
+		var (
+			source    string
+			data      map[string]string
+			sensitive bool
+			err       error
+		)
+		switch {
+		case from.ConfigMapRef != nil:
+			source = "ConfigMap " + from.ConfigMapRef.Name
+			cm := &corev1.ConfigMap{}
+			if err = cli.Get(ctx, client.ObjectKey{Namespace: run.Namespace, Name: from.ConfigMapRef.Name}, cm); err == nil {
+				data = cm.Data
+			}
+		case from.SecretRef != nil:
+			source, sensitive = "Secret "+from.SecretRef.Name, true
+			secret := &corev1.Secret{}
+			if err = cli.Get(ctx, client.ObjectKey{Namespace: run.Namespace, Name: from.SecretRef.Name}, secret); err == nil {
+				data = map[string]string{}
+				for k, v := range secret.Data {
+					data[k] = string(v)
+				}
+			}
+		default:
+			return nil, errors.New("either configMapRef or secretRef is required in spec.contextFrom")
+		}
+		if err != nil {
+			if !kerrors.IsNotFound(err) {
+				return nil, errors.WithMessagef(err, "get the source %s of spec.contextFrom", source)
+			}
+			if !from.Optional {
+				return nil, ContextSourceNotFoundError{Source: source}
+			}
+			result.skipped = append(result.skipped, fmt.Sprintf(v1alpha1.MessageSkippedContextSource, source, "not found"))
+			continue
+		}
+		keys := from.Keys
+		if len(keys) == 0 {
+			for k := range data {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+		}
+		for _, key := range keys {
+			val, ok := data[key]
+			if !ok {
+				if !from.Optional {
+					return nil, ContextSourceNotFoundError{Source: source, Key: key}
+				}
+				result.skipped = append(result.skipped, fmt.Sprintf(v1alpha1.MessageSkippedContextSource, source, "key "+key+" not found"))
+				continue
+			}
+			name := from.Prefix + key
+			delete(result.vars, name)
+			delete(result.sensitive, name)
+			if sensitive {
+				result.sensitive[name] = val
+			} else {
+				result.vars[name] = val
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+func TestResolveContextFrom(t *testing.T) {
+	cli := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "env", Namespace: "default"},
+			Data:       map[string]string{"region": "us", "zone": "us-1a", "team": "infra"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "default"},
+			Data:       map[string][]byte{"token": []byte("secret-token"), "region": []byte("secret-region")},
+		},
+	).Build()
+	configMap := func(name string) *corev1.LocalObjectReference { return &corev1.LocalObjectReference{Name: name} }
+	testCases := map[string]struct {
+		from      []v1alpha1.ContextFromSource
+		vars      map[string]string
+		sensitive map[string]string
+		skipped   []string
+		err       string
+		notFound  bool
+	}{
+		"all keys with prefix": {
+			from:      []v1alpha1.ContextFromSource{{ConfigMapRef: configMap("env"), Prefix: "env_"}, {SecretRef: configMap("creds")}},
+			vars:      map[string]string{"env_region": "us", "env_zone": "us-1a", "env_team": "infra"},
+			sensitive: map[string]string{"token": "secret-token", "region": "secret-region"},
+		},
+		"selected keys": {
+			from:      []v1alpha1.ContextFromSource{{ConfigMapRef: configMap("env"), Keys: []string{"region"}}},
+			vars:      map[string]string{"region": "us"},
+			sensitive: map[string]string{},
+		},
+		"the later secret overrides": {
+			from: []v1alpha1.ContextFromSource{
+				{ConfigMapRef: configMap("env"), Keys: []string{"region"}},
+				{SecretRef: configMap("creds"), Keys: []string{"region"}},
+			},
+			vars:      map[string]string{},
+			sensitive: map[string]string{"region": "secret-region"},
+		},
+		"the later config map overrides": {
+			from: []v1alpha1.ContextFromSource{
+				{SecretRef: configMap("creds"), Keys: []string{"region"}},
+				{ConfigMapRef: configMap("env"), Keys: []string{"region"}},
+			},
+			vars:      map[string]string{"region": "us"},
+			sensitive: map[string]string{},
+		},
+		"optional sources": {
+			from: []v1alpha1.ContextFromSource{
+				{ConfigMapRef: configMap("missing"), Optional: true},
+				{ConfigMapRef: configMap("env"), Keys: []string{"region", "missing"}, Optional: true},
+			},
+			vars:      map[string]string{"region": "us"},
+			sensitive: map[string]string{},
+			skipped: []string{
+				"skip the optional source ConfigMap missing of spec.contextFrom: not found",
+				"skip the optional source ConfigMap env of spec.contextFrom: key missing not found",
+			},
+		},
+		"required source": {
+			from:     []v1alpha1.ContextFromSource{{SecretRef: configMap("missing")}},
+			err:      "the source Secret missing of spec.contextFrom is not found",
+			notFound: true,
+		},
+		"required key": {
+			from:     []v1alpha1.ContextFromSource{{ConfigMapRef: configMap("env"), Keys: []string{"missing"}}},
+			err:      "the key missing of the source ConfigMap env of spec.contextFrom is not found",
+			notFound: true,
+		},
+		"no reference": {
+			from: []v1alpha1.ContextFromSource{{Keys: []string{"region"}}},
+			err:  "either configMapRef or secretRef is required in spec.contextFrom",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			run := &v1alpha1.WorkflowRun{ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"}}
+			run.Spec.ContextFrom = tc.from
+			result, err := resolveContextFrom(context.Background(), cli, run)
+			if tc.err != "" {
+				r.EqualError(err, tc.err)
+				r.Equal(tc.notFound, IsContextSourceNotFoundErr(err))
+				return
+			}
+			r.NoError(err)
+			r.Equal(tc.vars, result.vars)
+			r.Equal(tc.sensitive, result.sensitive)
+			r.Equal(tc.skipped, result.skipped)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/hooks"
+)
+
+// DependencyCycleError describes the cycle in the dependencies of the steps
+type DependencyCycleError struct {
+	// Cycle is the names of the steps in the cycle, the first step is repeated at the end
+	Cycle []string
+}
+
+// Error implements the Error interface.
+func (e DependencyCycleError) Error() string {
+	return fmt.Sprintf("dependency cycle detected: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// IsDependencyCycleErr returns true if the specified error is DependencyCycleError type.
+func IsDependencyCycleErr(err error) bool {
+	return errors.As(err, &DependencyCycleError{})
+}
+
+// checkDependencyCycle checks the cycle in the dependencies of the steps, both the explicit
+// dependsOn and the dependencies inferred from the inputs are considered.
+func checkDependencyCycle(steps []v1alpha1.WorkflowStep) error {
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.Name)
+		for _, sub := range step.SubSteps {
+			names = append(names, sub.Name)
+		}
+	}
+	if cycle := findCycle(names, hooks.StepDependencies(steps)); cycle != nil {
+		return DependencyCycleError{Cycle: cycle}
+	}
+	return nil
+}
+
+// findCycle returns the first cycle found in the dependencies by visiting the steps in order, the first step
+// of the cycle is repeated at the end. It returns nil if there's no cycle.
+func findCycle(names []string, dependencies map[string][]string) []string {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			for i, step := range path {
+				if step == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dependency := range dependencies[name] {
+			if cycle := visit(dependency); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range names {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// checkStepInputs checks the inputs referring to the qualified outputs of the sub steps,
+// which are named as `<step group>.<sub step>.<output>`.
+func checkStepInputs(steps []v1alpha1.WorkflowStep) error {
+	producers, qualified := hooks.OutputProducers(steps), hooks.QualifiedOutputs(steps)
+	groups, qualifiedGroups := map[string]bool{}, map[string]bool{}
+	for _, step := range steps {
+		if len(step.SubSteps) == 0 {
+			continue
+		}
+		groups[step.Name] = true
+		for _, sub := range step.SubSteps {
+			if len(qualified[sub.Name]) > 0 {
+				qualifiedGroups[step.Name] = true
+			}
+		}
+	}
+	check := func(step v1alpha1.WorkflowStepBase) error {
+		for _, output := range step.Outputs {
+			if qualifiedGroups[output.Name] {
+				return errors.Errorf("output %s of step %s conflicts with the qualified outputs of step group %s", output.Name, step.Name, output.Name)
+			}
+		}
+		for _, input := range step.Inputs {
+			segments := strings.Split(input.From, ".")
+			if !groups[segments[0]] || producers[segments[0]] != "" {
+				continue
+			}
+			if len(segments) < 3 || producers[strings.Join(segments[:3], ".")] == "" {
+				return errors.Errorf("input [%s] of step %s refers to an output which is not qualified by the sub steps of step group %s", input.From, step.Name, segments[0])
+			}
+		}
+		return nil
+	}
+	for _, step := range steps {
+		if err := check(step.WorkflowStepBase); err != nil {
+			return err
+		}
+		for _, sub := range step.SubSteps {
+			if err := check(sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+func TestCheckDependencyCycle(t *testing.T) {
+	step := func(name string, dependsOn []string, inputs ...string) v1alpha1.WorkflowStep {
+		s := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name:      name,
+			DependsOn: dependsOn,
+			Outputs:   v1alpha1.StepOutputs{{Name: name + "-output", ValueFrom: "output"}},
+		}}
+		for _, input := range inputs {
+			s.Inputs = append(s.Inputs, v1alpha1.InputItem{From: input})
+		}
+		return s
+	}
+	group := step("group", nil)
+	group.SubSteps = []v1alpha1.WorkflowStepBase{
+		step("sub1", []string{"sub2"}).WorkflowStepBase,
+		step("sub2", nil, "c-output").WorkflowStepBase,
+	}
+	testCases := map[string]struct {
+		steps []v1alpha1.WorkflowStep
+		cycle []string
+	}{
+		"no cycle": {
+			steps: []v1alpha1.WorkflowStep{step("a", nil), step("b", []string{"a"}, "a-output"), step("c", []string{"a", "b"})},
+		},
+		"cycle of dependsOn": {
+			steps: []v1alpha1.WorkflowStep{step("a", []string{"c"}), step("b", []string{"a"}), step("c", []string{"b"})},
+			cycle: []string{"a", "c", "b", "a"},
+		},
+		"cycle of dependsOn and inputs": {
+			steps: []v1alpha1.WorkflowStep{step("a", nil, "b-output.message"), step("b", []string{"a"})},
+			cycle: []string{"a", "b", "a"},
+		},
+		"self dependency": {
+			steps: []v1alpha1.WorkflowStep{step("a", []string{"a"})},
+			cycle: []string{"a", "a"},
+		},
+		"optional input is not a dependency": {
+			steps: []v1alpha1.WorkflowStep{func() v1alpha1.WorkflowStep {
+				s := step("a", nil, "b-output")
+				s.Inputs[0].Optional = true
+				return s
+			}(), step("b", []string{"a"})},
+		},
+		"cycle in sub steps": {
+			steps: []v1alpha1.WorkflowStep{group, step("c", []string{"sub1"})},
+			cycle: []string{"sub1", "sub2", "c", "sub1"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			err := checkDependencyCycle(tc.steps)
+			if tc.cycle == nil {
+				r.NoError(err)
+				return
+			}
+			r.True(IsDependencyCycleErr(err))
+			r.Equal(tc.cycle, err.(DependencyCycleError).Cycle)
+		})
+	}
+}
+
+func TestCheckStepInputs(t *testing.T) {
+	r := require.New(t)
+	group := func(name string, subs ...string) v1alpha1.WorkflowStep {
+		step := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: name, Type: "step-group"}}
+		for _, sub := range subs {
+			step.SubSteps = append(step.SubSteps, v1alpha1.WorkflowStepBase{
+				Name:    sub,
+				Outputs: v1alpha1.StepOutputs{{Name: "message", ValueFrom: "message"}},
+			})
+		}
+		return step
+	}
+	consumer := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+		Name:   "consumer",
+		Inputs: v1alpha1.StepInputs{{From: "group1.sub1.message.value"}, {From: "message"}},
+	}}
+	r.NoError(checkStepInputs([]v1alpha1.WorkflowStep{group("group1", "sub1"), group("group2", "sub2"), consumer}))
+
+	r.Error(checkStepInputs([]v1alpha1.WorkflowStep{group("group1", "sub1"), consumer}),
+		"the output of sub1 is not qualified without collision")
+
+	producer := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+		Name:    "producer",
+		Outputs: v1alpha1.StepOutputs{{Name: "group1", ValueFrom: "message"}},
+	}}
+	err := checkStepInputs([]v1alpha1.WorkflowStep{group("group1", "sub1"), group("group2", "sub2"), producer})
+	r.Error(err)
+	r.Equal("output group1 of step producer conflicts with the qualified outputs of step group group1", err.Error())
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/executor"
+	"github.com/kubevela/workflow/pkg/tasks/custom"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// SpecDriftError describes the started steps whose properties are changed
+type SpecDriftError struct {
+	// Steps is the names of the changed steps
+	Steps []string
+}
+
+// Error implements the Error interface.
+func (e SpecDriftError) Error() string {
+	return fmt.Sprintf("spec changed after execution started: the properties of steps %s are changed, revert the changes or annotate the workflow run with %s=true to restart the steps",
+		strings.Join(e.Steps, ", "), types.AnnotationWorkflowRunRestartOnSpecChange)
+}
+
+// IsSpecDriftErr returns true if the specified error is SpecDriftError type.
+func IsSpecDriftErr(err error) bool {
+	return errors.As(err, &SpecDriftError{})
+}
+
+// checkSpecDrift compares the properties of the started steps with the hashes recorded in their status.
+// The changed steps are restarted by removing their status if the run is annotated with restart-on-spec-change,
+// the whole step group is restarted if any of its sub steps is changed. Otherwise, SpecDriftError is returned.
+func checkSpecDrift(ctx monitorContext.Context, instance *types.WorkflowInstance) error {
+	specs := map[string]v1alpha1.WorkflowStepBase{}
+	for _, step := range instance.Steps {
+		specs[step.Name] = step.WorkflowStepBase
+		for _, sub := range step.SubSteps {
+			specs[sub.Name] = sub
+		}
+	}
+	drifted := func(status v1alpha1.StepStatus) bool {
+		spec, ok := specs[status.Name]
+		if !ok || status.PropertiesHash == "" {
+			return false
+		}
+		hash, err := custom.GetPropertiesHash(spec)
+		return err == nil && hash != status.PropertiesHash
+	}
+
+	var changed []string
+	var kept []v1alpha1.WorkflowStepStatus
+	for _, ss := range instance.Status.Steps {
+		restart := false
+		if drifted(ss.StepStatus) {
+			changed = append(changed, ss.Name)
+			restart = true
+		}
+		for _, sub := range ss.SubStepsStatus {
+			if drifted(sub) {
+				changed = append(changed, sub.Name)
+				restart = true
+			}
+		}
+		if !restart {
+			kept = append(kept, ss)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if instance.Annotations[types.AnnotationWorkflowRunRestartOnSpecChange] != "true" {
+		return SpecDriftError{Steps: changed}
+	}
+	ctx.Info("Restart the steps whose properties are changed after execution started", "steps", changed)
+	instance.Status.Steps = kept
+	// the cache of the number of the step status skips the reconcile with less steps
+	executor.StepStatusCache.Delete(fmt.Sprintf("%s-%s", instance.Name, instance.Namespace))
+	return nil
+}
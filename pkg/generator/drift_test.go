@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/executor"
+	"github.com/kubevela/workflow/pkg/tasks/custom"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestCheckSpecDrift(t *testing.T) {
+	step := func(name, properties string) v1alpha1.WorkflowStepBase {
+		return v1alpha1.WorkflowStepBase{Name: name, Type: "apply", Properties: &runtime.RawExtension{Raw: []byte(properties)}}
+	}
+	status := func(base v1alpha1.WorkflowStepBase) v1alpha1.StepStatus {
+		hash, err := custom.GetPropertiesHash(base)
+		require.NoError(t, err)
+		return v1alpha1.StepStatus{Name: base.Name, Phase: v1alpha1.WorkflowStepPhaseRunning, PropertiesHash: hash}
+	}
+	newInstance := func() *types.WorkflowInstance {
+		group := v1alpha1.WorkflowStep{
+			WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "group", Type: "step-group"},
+			SubSteps:         []v1alpha1.WorkflowStepBase{step("sub1", `{"a":1}`), step("sub2", `{"b":2}`)},
+		}
+		steps := []v1alpha1.WorkflowStep{
+			{WorkflowStepBase: step("s1", `{"x":1,"y":2}`)},
+			group,
+			{WorkflowStepBase: step("s3", `{}`)},
+		}
+		return &types.WorkflowInstance{
+			WorkflowMeta: types.WorkflowMeta{Name: "app", Namespace: "default"},
+			Steps:        steps,
+			Status: v1alpha1.WorkflowRunStatus{Steps: []v1alpha1.WorkflowStepStatus{
+				{StepStatus: status(steps[0].WorkflowStepBase)},
+				{StepStatus: status(group.WorkflowStepBase), SubStepsStatus: []v1alpha1.StepStatus{status(group.SubSteps[0]), status(group.SubSteps[1])}},
+				// the status recorded before the hash is introduced
+				{StepStatus: v1alpha1.StepStatus{Name: "s3", Phase: v1alpha1.WorkflowStepPhaseRunning}},
+			}},
+		}
+	}
+	ctx := monitorContext.NewTraceContext(context.Background(), "test")
+	r := require.New(t)
+
+	instance := newInstance()
+	// the order of the keys does not matter
+	instance.Steps[0].Properties.Raw = []byte(`{"y":2,"x":1}`)
+	instance.Steps[2].Properties.Raw = []byte(`{"z":1}`)
+	r.NoError(checkSpecDrift(ctx, instance))
+	r.Len(instance.Status.Steps, 3)
+
+	instance = newInstance()
+	instance.Steps[0].Properties.Raw = []byte(`{"x":2,"y":2}`)
+	instance.Steps[1].SubSteps[1].Properties.Raw = []byte(`{"b":3}`)
+	err := checkSpecDrift(ctx, instance)
+	r.Error(err)
+	r.True(IsSpecDriftErr(err))
+	r.Equal([]string{"s1", "sub2"}, err.(SpecDriftError).Steps)
+	r.Contains(err.Error(), "spec changed after execution started")
+	r.Len(instance.Status.Steps, 3)
+
+	executor.StepStatusCache.Store("app-default", 3)
+	instance.Annotations = map[string]string{types.AnnotationWorkflowRunRestartOnSpecChange: "true"}
+	r.NoError(checkSpecDrift(ctx, instance))
+	r.Len(instance.Status.Steps, 1)
+	r.Equal("s3", instance.Status.Steps[0].Name)
+	_, cached := executor.StepStatusCache.Load("app-default")
+	r.False(cached)
+}
@@ -19,9 +19,13 @@ package generator
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"sort"
+	"strconv"
+	"time"
 
+	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -29,15 +33,24 @@ import (
 	"github.com/kubevela/pkg/util/rand"
 
 	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/correlation"
+	"github.com/kubevela/workflow/pkg/cue/model"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
 	"github.com/kubevela/workflow/pkg/cue/process"
+	"github.com/kubevela/workflow/pkg/debug"
 	"github.com/kubevela/workflow/pkg/executor"
+	wfHistory "github.com/kubevela/workflow/pkg/history"
 	"github.com/kubevela/workflow/pkg/monitor/metrics"
 	"github.com/kubevela/workflow/pkg/providers"
 	"github.com/kubevela/workflow/pkg/providers/config"
+	debugProvider "github.com/kubevela/workflow/pkg/providers/debug"
 	"github.com/kubevela/workflow/pkg/providers/email"
+	"github.com/kubevela/workflow/pkg/providers/history"
 	"github.com/kubevela/workflow/pkg/providers/http"
 	"github.com/kubevela/workflow/pkg/providers/kube"
+	"github.com/kubevela/workflow/pkg/providers/lock"
 	"github.com/kubevela/workflow/pkg/providers/util"
+	"github.com/kubevela/workflow/pkg/providers/workflow"
 	"github.com/kubevela/workflow/pkg/providers/workspace"
 	"github.com/kubevela/workflow/pkg/tasks"
 	"github.com/kubevela/workflow/pkg/tasks/template"
@@ -51,20 +64,42 @@ func GenerateRunners(ctx monitorContext.Context, instance *types.WorkflowInstanc
 		metrics.GenerateTaskRunnersDurationHistogram.WithLabelValues("workflowrun").Observe(v)
 	}))
 	defer subCtx.Commit("finish generate task runners")
+	steps, err := normalizeStepNames(instance.Steps)
+	if err != nil {
+		return nil, err
+	}
+	instance.Steps = steps
+	if err := checkSpecDrift(ctx, instance); err != nil {
+		return nil, err
+	}
+	if err := checkStepInputs(instance.Steps); err != nil {
+		return nil, err
+	}
+	if err := checkDependencyCycle(instance.Steps); err != nil {
+		return nil, err
+	}
+	if err := checkStepTypes(ctx, options.Client, instance); err != nil {
+		return nil, err
+	}
 	options = initStepGeneratorOptions(ctx, instance, options)
 	taskDiscover := tasks.NewTaskDiscover(ctx, options)
 	var tasks []types.TaskRunner
 	for _, step := range instance.Steps {
 		opt := &types.TaskGeneratorOptions{
-			ID:              generateStepID(instance.Status, step.Name),
-			PackageDiscover: options.PackageDiscover,
-			ProcessContext:  options.ProcessCtx,
+			ID:                 generateStepID(instance.Status, step.Name),
+			PackageDiscover:    options.PackageDiscover,
+			ProcessContext:     options.ProcessCtx,
+			DefinitionRevision: getDefinitionRevision(instance.Status, step.Name, ""),
 		}
 		for typ, convertor := range options.StepConvertor {
-			if step.Type == typ {
+			if name, _ := template.SplitRevision(step.Type); name == typ {
 				opt.StepConvertor = convertor
 			}
 		}
+		warnDeprecatedProfile(ctx, step.WorkflowStepBase)
+		for _, sub := range step.SubSteps {
+			warnDeprecatedProfile(ctx, sub)
+		}
 		task, err := generateTaskRunner(ctx, instance, step, taskDiscover, opt, options)
 		if err != nil {
 			return nil, err
@@ -76,26 +111,15 @@ func GenerateRunners(ctx monitorContext.Context, instance *types.WorkflowInstanc
 
 // GenerateWorkflowInstance generates a workflow instance
 func GenerateWorkflowInstance(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) (*types.WorkflowInstance, error) {
-	var steps []v1alpha1.WorkflowStep
-	switch {
-	case run.Spec.WorkflowSpec != nil:
-		steps = run.Spec.WorkflowSpec.Steps
-	case run.Spec.WorkflowRef != "":
-		template := new(v1alpha1.Workflow)
-		if err := cli.Get(ctx, client.ObjectKey{
-			Name:      run.Spec.WorkflowRef,
-			Namespace: run.Namespace,
-		}, template); err != nil {
-			return nil, err
-		}
-		steps = template.WorkflowSpec.Steps
-	default:
-		return nil, errors.New("failed to generate workflow instance")
+	steps, err := LoadSteps(ctx, cli, run)
+	if err != nil {
+		return nil, err
 	}
+	applyDefaultStepTimeout(steps, run.Status)
 
-	debug := false
-	if run.Annotations != nil && run.Annotations[types.AnnotationWorkflowRunDebug] == "true" {
-		debug = true
+	debugEnabled, debugSteps := false, []string(nil)
+	if run.Annotations != nil {
+		debugEnabled, debugSteps = debug.ParseDebugAnnotation(run.Annotations[types.AnnotationWorkflowRunDebug])
 	}
 
 	contextData := make(map[string]interface{})
@@ -114,6 +138,7 @@ func GenerateWorkflowInstance(ctx context.Context, cli client.Client, run *v1alp
 			Namespace:   run.Namespace,
 			Annotations: run.Annotations,
 			Labels:      run.Labels,
+			UID:         run.UID,
 			ChildOwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion: v1alpha1.SchemeGroupVersion.String(),
@@ -124,16 +149,77 @@ func GenerateWorkflowInstance(ctx context.Context, cli client.Client, run *v1alp
 				},
 			},
 		},
-		Context: contextData,
-		Debug:   debug,
-		Mode:    run.Spec.Mode,
-		Steps:   steps,
-		Status:  run.Status,
+		Context:                  contextData,
+		Debug:                    debugEnabled,
+		DebugSteps:               debugSteps,
+		LiveProgress:             run.Annotations[types.AnnotationWorkflowRunLiveProgress] == "true",
+		DryRun:                   types.IsRunDryRun(run),
+		MaxConsecutiveFailures:   maxConsecutiveFailures(run.Annotations),
+		Correlation:              correlation.FromAnnotations(run.Annotations),
+		Vars:                     run.Spec.Vars,
+		ResourceLabels:           run.Spec.ResourceLabels,
+		ResourceAnnotations:      run.Spec.ResourceAnnotations,
+		ResourceMetadataOverride: run.Spec.ResourceMetadataOverride,
+		TrackResources:           types.IsResourceTracked(run),
+		Mode:                     run.Spec.Mode,
+		Timeout:                  workflowTimeout(run),
+		ProviderOverrides:        providerOverrides(run),
+		Steps:                    steps,
+		Status:                   run.Status,
+	}
+	// the vars are imported once before the first step runs, the later reconciles load them from the context
+	if run.Status.ContextBackend == nil && len(run.Spec.ContextFrom) > 0 {
+		result, err := resolveContextFrom(ctx, cli, run)
+		if err != nil {
+			return nil, err
+		}
+		instance.ContextFromVars = result.vars
+		instance.SensitiveVars = result.sensitive
+		instance.SkippedContextSources = result.skipped
 	}
 	executor.InitializeWorkflowInstance(instance)
 	return instance, nil
 }
 
+// providerOverrides returns the provider overrides of the workflow run, they are ignored if the controller disallows them
+func providerOverrides(run *v1alpha1.WorkflowRun) *v1alpha1.ProviderOverrides {
+	if !types.AllowProviderOverrides {
+		return nil
+	}
+	return run.Spec.ProviderOverrides
+}
+
+// LoadSteps loads the steps of the workflow run from the spec or the referred workflow, the step names are normalized
+func LoadSteps(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) ([]v1alpha1.WorkflowStep, error) {
+	var steps []v1alpha1.WorkflowStep
+	switch {
+	case run.Spec.WorkflowSpec != nil:
+		steps = run.Spec.WorkflowSpec.Steps
+	case run.Spec.WorkflowRef != "":
+		template := new(v1alpha1.Workflow)
+		if err := cli.Get(ctx, client.ObjectKey{
+			Name:      run.Spec.WorkflowRef,
+			Namespace: run.Namespace,
+		}, template); err != nil {
+			return nil, err
+		}
+		steps = template.WorkflowSpec.Steps
+	default:
+		return nil, errors.New("failed to generate workflow instance")
+	}
+	return normalizeStepNames(steps)
+}
+
+// maxConsecutiveFailures returns the consecutive failures of a step to suspend the run, the invalid annotation is ignored
+func maxConsecutiveFailures(annotations map[string]string) int {
+	if v, ok := annotations[types.AnnotationWorkflowRunMaxConsecutiveFailures]; ok {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return types.MaxConsecutiveFailures
+}
+
 func initStepGeneratorOptions(ctx monitorContext.Context, instance *types.WorkflowInstance, options types.StepGeneratorOptions) types.StepGeneratorOptions {
 	if options.Providers == nil {
 		options.Providers = providers.NewProviders()
@@ -141,23 +227,86 @@ func initStepGeneratorOptions(ctx monitorContext.Context, instance *types.Workfl
 	if options.ProcessCtx == nil {
 		options.ProcessCtx = process.NewContext(generateContextDataFromWorkflowRun(instance))
 	}
-	installBuiltinProviders(instance, options.Client, options.Providers, options.ProcessCtx)
+	installBuiltinProviders(instance, options.Client, options.KubeClient, options.Providers, options.ProcessCtx)
+	if options.ProviderRegistry == nil {
+		options.ProviderRegistry = providers.DefaultRegistry
+	}
+	options.ProviderRegistry.InstallTo(options.Providers)
 	if options.TemplateLoader == nil {
-		options.TemplateLoader = template.NewWorkflowStepTemplateLoader(options.Client)
+		var opts []template.LoaderOption
+		if options.TemplateFS != nil {
+			opts = append(opts, template.WithFS(options.TemplateFS))
+		}
+		if options.TemplateOverrides != nil {
+			opts = append(opts, template.WithOverrides(options.TemplateOverrides))
+		}
+		options.TemplateLoader = template.NewWorkflowStepTemplateLoader(options.Client, opts...)
 	}
 	return options
 }
 
-func installBuiltinProviders(instance *types.WorkflowInstance, client client.Client, providerHandlers types.Providers, pCtx process.Context) {
-	workspace.Install(providerHandlers)
-	email.Install(providerHandlers)
-	util.Install(providerHandlers, pCtx)
-	http.Install(providerHandlers, client, instance.Namespace)
+func installBuiltinProviders(instance *types.WorkflowInstance, client client.Client, kubeClient kubernetes.Interface, providerHandlers types.Providers, pCtx process.Context) {
+	workspace.Install(providerHandlers, client, instance.WorkflowMeta, pCtx)
+	var emailOverride *v1alpha1.EmailOverride
+	if instance.ProviderOverrides != nil {
+		emailOverride = instance.ProviderOverrides.Email
+	}
+	email.Install(providerHandlers, client, instance.Namespace, emailOverride)
+	util.Install(providerHandlers, client, instance.Namespace, pCtx)
+	debugProvider.Install(providerHandlers, client, instance, pCtx)
+	http.Install(providerHandlers, client, instance.Namespace, instance.Correlation, instance.ProviderOverrides)
 	config.Install(providerHandlers, client)
-	kube.Install(providerHandlers, client, map[string]string{
-		types.LabelWorkflowRunName:      instance.Name,
-		types.LabelWorkflowRunNamespace: instance.Namespace,
-	}, nil)
+	history.Install(providerHandlers, wfHistory.DefaultStore, instance.Namespace)
+	lock.Install(providerHandlers, client, instance.WorkflowMeta)
+	workflow.Install(providerHandlers, client, instance.WorkflowMeta)
+	labels := map[string]string{}
+	for k, v := range instance.Correlation {
+		labels[k] = v
+	}
+	labels[types.LabelWorkflowRunName] = instance.Name
+	labels[types.LabelWorkflowRunNamespace] = instance.Namespace
+	kube.Install(providerHandlers, client, kubeClient, labels, nil, &kube.ResourceMetadata{
+		Labels:      instance.ResourceLabels,
+		Annotations: instance.ResourceAnnotations,
+		Override:    instance.ResourceMetadataOverride,
+		Track:       instance.TrackResources,
+		ProcessCtx:  pCtx,
+	})
+}
+
+func init() {
+	// the providers installed by the generator can not be registered by the users
+	recorder := opRecorder{}
+	installBuiltinProviders(&types.WorkflowInstance{}, nil, nil, recorder, nil)
+	for name := range recorder {
+		providers.ReserveBuiltin(name)
+	}
+}
+
+// opRecorder records the ops of the providers installed to it
+type opRecorder map[string][]string
+
+func (r opRecorder) GetHandler(string, string) (types.Handler, bool) {
+	return nil, false
+}
+
+func (r opRecorder) Register(provider string, m map[string]types.Handler) {
+	for op := range m {
+		r[provider] = append(r[provider], op)
+	}
+	sort.Strings(r[provider])
+}
+
+// RegisteredOps returns the sorted ops of the builtin providers and the providers in the registry, keyed by the
+// provider names. The default registry is used if the registry is nil.
+func RegisteredOps(registry types.ProviderRegistry) map[string][]string {
+	recorder := opRecorder{}
+	installBuiltinProviders(&types.WorkflowInstance{}, nil, nil, recorder, nil)
+	if registry == nil {
+		registry = providers.DefaultRegistry
+	}
+	registry.InstallTo(recorder)
+	return recorder
 }
 
 func generateTaskRunner(ctx context.Context,
@@ -173,12 +322,13 @@ func generateTaskRunner(ctx context.Context,
 				WorkflowStepBase: subStep,
 			}
 			o := &types.TaskGeneratorOptions{
-				ID:              generateSubStepID(instance.Status, subStep.Name, step.Name),
-				PackageDiscover: options.PackageDiscover,
-				ProcessContext:  options.ProcessContext,
+				ID:                 generateSubStepID(instance.Status, subStep.Name, step.Name),
+				PackageDiscover:    options.PackageDiscover,
+				ProcessContext:     options.ProcessContext,
+				DefinitionRevision: getDefinitionRevision(instance.Status, subStep.Name, step.Name),
 			}
 			for typ, convertor := range stepOptions.StepConvertor {
-				if subStep.Type == typ {
+				if name, _ := template.SplitRevision(subStep.Type); name == typ {
 					o.StepConvertor = convertor
 				}
 			}
@@ -195,7 +345,19 @@ func generateTaskRunner(ctx context.Context,
 		}
 	}
 
-	genTask, err := taskDiscover.GetTaskGenerator(ctx, step.Type)
+	typ := step.Type
+	if typ != types.WorkflowStepTypeStepGroup && typ != types.WorkflowStepTypeSuspend {
+		revision, err := resolveDefinitionRevision(ctx, stepOptions.TemplateLoader, typ, options.DefinitionRevision)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "resolve the revision of step %s", step.Name)
+		}
+		if revision != "" {
+			options.DefinitionRevision = revision
+			typ = revision
+		}
+	}
+
+	genTask, err := taskDiscover.GetTaskGenerator(ctx, typ)
 	if err != nil {
 		return nil, err
 	}
@@ -207,6 +369,32 @@ func generateTaskRunner(ctx context.Context,
 	return task, nil
 }
 
+// resolveDefinitionRevision returns the step type pinned to the revision of the definition, the revision recorded
+// in the step status is reused so that the step keeps using the same template for the lifetime of the run.
+func resolveDefinitionRevision(ctx context.Context, loader template.Loader, typ string, recorded string) (string, error) {
+	if recorded != "" {
+		return recorded, nil
+	}
+	if resolver, ok := loader.(template.RevisionResolver); ok {
+		return resolver.ResolveRevision(ctx, typ)
+	}
+	if _, revision := template.SplitRevision(typ); revision != "" {
+		return typ, nil
+	}
+	return "", nil
+}
+
+// warnDeprecatedProfile warns the step using the deprecated cue profile, the steps without the profile use the default
+// profile, the builtin steps are not rendered by cue
+func warnDeprecatedProfile(ctx monitorContext.Context, step v1alpha1.WorkflowStepBase) {
+	if step.Type == types.WorkflowStepTypeSuspend || step.Type == types.WorkflowStepTypeStepGroup {
+		return
+	}
+	if profile, err := value.GetProfile(step.CUEProfile); err == nil && profile.Deprecated {
+		ctx.Info("[WARNING] the cue profile is deprecated and scheduled for removal", "step", step.Name, "profile", profile.Name)
+	}
+}
+
 func generateStepID(status v1alpha1.WorkflowRunStatus, name string) string {
 	for _, ss := range status.Steps {
 		if ss.Name == name {
@@ -231,11 +419,50 @@ func generateSubStepID(status v1alpha1.WorkflowRunStatus, name, parentStepName s
 	return rand.RandomString(10)
 }
 
+// getDefinitionRevision returns the revision of the definition recorded in the status of the step,
+// the sub step is looked up in the status of its parent step if the parent is not empty.
+func getDefinitionRevision(status v1alpha1.WorkflowRunStatus, name, parentStepName string) string {
+	for _, ss := range status.Steps {
+		if parentStepName == "" && ss.Name == name {
+			return ss.DefinitionRevision
+		}
+		if parentStepName != "" && ss.Name == parentStepName {
+			for _, sub := range ss.SubStepsStatus {
+				if sub.Name == name {
+					return sub.DefinitionRevision
+				}
+			}
+		}
+	}
+	return ""
+}
+
 func generateContextDataFromWorkflowRun(instance *types.WorkflowInstance) process.ContextData {
 	data := process.ContextData{
 		Name:       instance.Name,
 		Namespace:  instance.Namespace,
 		CustomData: instance.Context,
 	}
+	labels, annotations := map[string]string{}, map[string]string{}
+	for k, v := range instance.Labels {
+		labels[k] = v
+	}
+	for k, v := range instance.Annotations {
+		annotations[k] = v
+	}
+	data.Data = map[string]interface{}{
+		model.ContextLabels:      labels,
+		model.ContextAnnotations: annotations,
+		model.ContextRunUID:      string(instance.UID),
+	}
+	if !instance.Status.StartTime.IsZero() {
+		data.Data[model.ContextStartTime] = instance.Status.StartTime.Format(time.RFC3339)
+	}
+	if len(instance.Correlation) > 0 {
+		data.Data[model.ContextCorrelation] = instance.Correlation
+	}
+	if len(instance.Context) > 0 {
+		data.Data[model.ContextCustom] = instance.Context
+	}
 	return data
 }
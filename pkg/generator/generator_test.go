@@ -19,6 +19,7 @@ package generator
 import (
 	"context"
 	"strconv"
+	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -29,6 +30,8 @@ import (
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
 	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/cue/model"
+	"github.com/kubevela/workflow/pkg/providers"
 	"github.com/kubevela/workflow/pkg/types"
 )
 
@@ -134,3 +137,31 @@ var _ = Describe("Test workflow step runner generator", func() {
 		Expect(runners[0].Name()).Should(BeEquivalentTo("step-1"))
 	})
 })
+
+func TestGenerateContextDataFromWorkflowRun(t *testing.T) {
+	g := NewWithT(t)
+	instance := &types.WorkflowInstance{
+		WorkflowMeta: types.WorkflowMeta{
+			Name:        "test",
+			Namespace:   "default",
+			Labels:      map[string]string{"env": "prod"},
+			Annotations: nil,
+		},
+		Status: v1alpha1.WorkflowRunStatus{
+			StartTime: metav1.NewTime(time.Date(2022, 10, 1, 8, 0, 0, 0, time.UTC)),
+		},
+	}
+	data := generateContextDataFromWorkflowRun(instance)
+	g.Expect(data.Data[model.ContextLabels]).Should(Equal(map[string]string{"env": "prod"}))
+	g.Expect(data.Data[model.ContextAnnotations]).Should(Equal(map[string]string{}))
+	g.Expect(data.Data[model.ContextStartTime]).Should(Equal("2022-10-01T08:00:00Z"))
+}
+
+func TestBuiltinProvidersReserved(t *testing.T) {
+	g := NewWithT(t)
+	registry := providers.NewRegistry()
+	for name := range RegisteredOps(registry) {
+		g.Expect(registry.Register(name, map[string]types.Handler{})).Should(HaveOccurred(), name)
+	}
+	g.Expect(registry.Register("myorg", map[string]types.Handler{})).Should(Succeed())
+}
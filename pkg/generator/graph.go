@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/hooks"
+)
+
+// EdgeType is the type of the edge in the graph of the workflow run
+type EdgeType string
+
+const (
+	// EdgeTypeData is the edge inferred from the inputs of the step referring to the outputs of another step
+	EdgeTypeData EdgeType = "data"
+	// EdgeTypeExplicit is the edge declared by the dependsOn of the step
+	EdgeTypeExplicit EdgeType = "explicit"
+	// EdgeTypeOrdering is the edge between the adjacent steps executed step by step
+	EdgeTypeOrdering EdgeType = "ordering"
+)
+
+// Node is a step in the graph of the workflow run
+type Node struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Group is the name of the step group if the node is a sub step
+	Group string                     `json:"group,omitempty"`
+	Phase v1alpha1.WorkflowStepPhase `json:"phase,omitempty"`
+}
+
+// Edge is the dependency between the steps, the step To depends on the step From
+type Edge struct {
+	From string   `json:"from"`
+	To   string   `json:"to"`
+	Type EdgeType `json:"type"`
+}
+
+// Graph is the dependency graph of the steps of the workflow run
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// BuildGraph builds the dependency graph of the steps of the workflow run for visualization, the nodes are in the
+// order of the steps with the phases in the status. It returns the DependencyCycleError if the dependencies of the
+// steps form a cycle, which is never executed.
+func BuildGraph(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) (*Graph, error) {
+	steps, err := LoadSteps(ctx, cli, run)
+	if err != nil {
+		return nil, err
+	}
+	mode := v1alpha1.WorkflowExecuteMode{Steps: v1alpha1.WorkflowModeStep, SubSteps: v1alpha1.WorkflowModeDAG}
+	switch {
+	case run.Status.Mode.Steps != "":
+		mode = run.Status.Mode
+	case run.Spec.Mode != nil:
+		if run.Spec.Mode.Steps != "" {
+			mode.Steps = run.Spec.Mode.Steps
+		}
+		if run.Spec.Mode.SubSteps != "" {
+			mode.SubSteps = run.Spec.Mode.SubSteps
+		}
+	}
+	phases := map[string]v1alpha1.WorkflowStepPhase{}
+	for _, ss := range run.Status.Steps {
+		phases[ss.Name] = ss.Phase
+		for _, sub := range ss.SubStepsStatus {
+			phases[sub.Name] = sub.Phase
+		}
+	}
+
+	graph := &Graph{Nodes: []Node{}, Edges: []Edge{}}
+	var names []string
+	dependencies := hooks.TypedStepDependencies(steps)
+	adjacency := map[string][]string{}
+	connected := map[[2]string]bool{}
+	addNode := func(step v1alpha1.WorkflowStepBase, group string) {
+		names = append(names, step.Name)
+		graph.Nodes = append(graph.Nodes, Node{Name: step.Name, Type: step.Type, Group: group, Phase: phases[step.Name]})
+		for _, dependency := range dependencies[step.Name] {
+			typ := EdgeTypeExplicit
+			if dependency.Data {
+				typ = EdgeTypeData
+			}
+			graph.Edges = append(graph.Edges, Edge{From: dependency.Step, To: step.Name, Type: typ})
+			adjacency[step.Name] = append(adjacency[step.Name], dependency.Step)
+			connected[[2]string{dependency.Step, step.Name}] = true
+		}
+	}
+	var ordering []Edge
+	addOrdering := func(from, to string, stepByStep bool) {
+		if stepByStep && !connected[[2]string{from, to}] {
+			ordering = append(ordering, Edge{From: from, To: to, Type: EdgeTypeOrdering})
+		}
+	}
+	for i, step := range steps {
+		addNode(step.WorkflowStepBase, "")
+		if i > 0 {
+			addOrdering(steps[i-1].Name, step.Name, mode.Steps == v1alpha1.WorkflowModeStep)
+		}
+		for j, sub := range step.SubSteps {
+			addNode(sub, step.Name)
+			if j > 0 {
+				addOrdering(step.SubSteps[j-1].Name, sub.Name, mode.SubSteps == v1alpha1.WorkflowModeStep)
+			}
+		}
+	}
+	if cycle := findCycle(names, adjacency); cycle != nil {
+		return nil, DependencyCycleError{Cycle: cycle}
+	}
+	graph.Edges = append(graph.Edges, ordering...)
+	return graph, nil
+}
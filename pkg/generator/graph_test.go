@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+func TestBuildGraph(t *testing.T) {
+	step := func(name string, dependsOn []string, inputs ...string) v1alpha1.WorkflowStepBase {
+		s := v1alpha1.WorkflowStepBase{
+			Name:      name,
+			Type:      "apply",
+			DependsOn: dependsOn,
+			Outputs:   v1alpha1.StepOutputs{{Name: name + "-output", ValueFrom: "output"}},
+		}
+		for _, input := range inputs {
+			s.Inputs = append(s.Inputs, v1alpha1.InputItem{From: input})
+		}
+		return s
+	}
+	group := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "group", Type: "step-group"}, SubSteps: []v1alpha1.WorkflowStepBase{
+		step("sub1", nil), step("sub2", nil, "sub1-output"),
+	}}
+	testCases := map[string]struct {
+		mode          *v1alpha1.WorkflowExecuteMode
+		steps         []v1alpha1.WorkflowStep
+		expectedEdges []Edge
+		expectedErr   string
+	}{
+		"step by step": {
+			steps: []v1alpha1.WorkflowStep{{WorkflowStepBase: step("a", nil)}, {WorkflowStepBase: step("b", []string{"a"})}, group},
+			expectedEdges: []Edge{
+				{From: "a", To: "b", Type: EdgeTypeExplicit},
+				{From: "sub1", To: "sub2", Type: EdgeTypeData},
+				{From: "b", To: "group", Type: EdgeTypeOrdering},
+			},
+		},
+		"dag with step by step sub steps": {
+			mode:  &v1alpha1.WorkflowExecuteMode{Steps: v1alpha1.WorkflowModeDAG, SubSteps: v1alpha1.WorkflowModeStep},
+			steps: []v1alpha1.WorkflowStep{{WorkflowStepBase: step("a", nil)}, {WorkflowStepBase: step("b", nil, "a-output")}, group},
+			expectedEdges: []Edge{
+				{From: "a", To: "b", Type: EdgeTypeData},
+				{From: "sub1", To: "sub2", Type: EdgeTypeData},
+			},
+		},
+		"cycle": {
+			steps:       []v1alpha1.WorkflowStep{{WorkflowStepBase: step("a", []string{"b"})}, {WorkflowStepBase: step("b", nil, "a-output")}},
+			expectedErr: "dependency cycle detected: a -> b -> a",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			run := &v1alpha1.WorkflowRun{
+				Spec: v1alpha1.WorkflowRunSpec{Mode: tc.mode, WorkflowSpec: &v1alpha1.WorkflowSpec{Steps: tc.steps}},
+				Status: v1alpha1.WorkflowRunStatus{Steps: []v1alpha1.WorkflowStepStatus{
+					{StepStatus: v1alpha1.StepStatus{Name: "a", Phase: v1alpha1.WorkflowStepPhaseSucceeded}},
+				}},
+			}
+			graph, err := BuildGraph(context.Background(), fake.NewClientBuilder().Build(), run)
+			if tc.expectedErr != "" {
+				r.True(IsDependencyCycleErr(err))
+				r.EqualError(err, tc.expectedErr)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tc.expectedEdges, graph.Edges)
+			r.Len(graph.Nodes, 5)
+			r.Equal(Node{Name: "a", Type: "apply", Phase: v1alpha1.WorkflowStepPhaseSucceeded}, graph.Nodes[0])
+			r.Equal(Node{Name: "sub2", Type: "apply", Group: "group"}, graph.Nodes[4])
+		})
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+// DuplicateStepNameError describes the steps sharing the same name, whose status would overwrite each other
+type DuplicateStepNameError struct {
+	// Duplicates is the positions of the steps keyed by the duplicated names, e.g. steps[0] or steps[1].subSteps[0]
+	Duplicates map[string][]string
+}
+
+// Error implements the Error interface.
+func (e DuplicateStepNameError) Error() string {
+	names := make([]string, 0, len(e.Duplicates))
+	for name := range e.Duplicates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s (%s)", name, strings.Join(e.Duplicates[name], ", ")))
+	}
+	return fmt.Sprintf("duplicate step names: %s", strings.Join(msgs, "; "))
+}
+
+// IsDuplicateStepNameErr returns true if the specified error is DuplicateStepNameError type.
+func IsDuplicateStepNameErr(err error) bool {
+	return errors.As(err, &DuplicateStepNameError{})
+}
+
+// normalizeStepNames names the unnamed steps as `<type>-<index>` and the unnamed sub steps as
+// `<step group>-<type>-<index>`, the names only depend on the spec so that they are stable across
+// reconciles. The steps are copied before naming and the duplicated names are rejected.
+func normalizeStepNames(steps []v1alpha1.WorkflowStep) ([]v1alpha1.WorkflowStep, error) {
+	named := make([]v1alpha1.WorkflowStep, len(steps))
+	positions := map[string][]string{}
+	for i, step := range steps {
+		step = *step.DeepCopy()
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("%s-%d", step.Type, i)
+		}
+		positions[step.Name] = append(positions[step.Name], fmt.Sprintf("steps[%d]", i))
+		for j := range step.SubSteps {
+			if step.SubSteps[j].Name == "" {
+				step.SubSteps[j].Name = fmt.Sprintf("%s-%s-%d", step.Name, step.SubSteps[j].Type, j)
+			}
+			name := step.SubSteps[j].Name
+			positions[name] = append(positions[name], fmt.Sprintf("steps[%d].subSteps[%d]", i, j))
+		}
+		named[i] = step
+	}
+	duplicates := map[string][]string{}
+	for name, pos := range positions {
+		if len(pos) > 1 {
+			duplicates[name] = pos
+		}
+	}
+	if len(duplicates) > 0 {
+		return nil, DuplicateStepNameError{Duplicates: duplicates}
+	}
+	return named, nil
+}
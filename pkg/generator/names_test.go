@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+func TestNormalizeStepNames(t *testing.T) {
+	step := func(name, typ string, subs ...v1alpha1.WorkflowStepBase) v1alpha1.WorkflowStep {
+		return v1alpha1.WorkflowStep{
+			WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: name, Type: typ},
+			SubSteps:         subs,
+		}
+	}
+	sub := func(name, typ string) v1alpha1.WorkflowStepBase {
+		return v1alpha1.WorkflowStepBase{Name: name, Type: typ}
+	}
+
+	r := require.New(t)
+	steps := []v1alpha1.WorkflowStep{
+		step("", "apply"),
+		step("notify", "notification"),
+		step("", "step-group", sub("", "apply"), sub("deploy", "apply")),
+		step("", "apply"),
+	}
+	named, err := normalizeStepNames(steps)
+	r.NoError(err)
+	var names []string
+	for _, s := range named {
+		names = append(names, s.Name)
+		for _, sub := range s.SubSteps {
+			names = append(names, sub.Name)
+		}
+	}
+	r.Equal([]string{"apply-0", "notify", "step-group-2", "step-group-2-apply-0", "deploy", "apply-3"}, names)
+	// the spec is not modified and the names are stable
+	r.Equal("", steps[0].Name)
+	r.Equal("", steps[2].SubSteps[0].Name)
+	again, err := normalizeStepNames(steps)
+	r.NoError(err)
+	r.Equal(named, again)
+
+	_, err = normalizeStepNames([]v1alpha1.WorkflowStep{
+		step("a", "apply"),
+		step("group", "step-group", sub("a", "apply"), sub("b", "apply")),
+		step("b", "apply"),
+		step("apply-4", "apply"),
+		step("", "apply"),
+	})
+	r.Error(err)
+	r.True(IsDuplicateStepNameErr(err))
+	r.Equal(map[string][]string{
+		"a":       {"steps[0]", "steps[1].subSteps[0]"},
+		"b":       {"steps[1].subSteps[1]", "steps[2]"},
+		"apply-4": {"steps[3]", "steps[4]"},
+	}, err.(DuplicateStepNameError).Duplicates)
+	r.Equal("duplicate step names: a (steps[0], steps[1].subSteps[0]); apply-4 (steps[3], steps[4]); b (steps[1].subSteps[1], steps[2])", err.Error())
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/pkg/tasks/template"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+var (
+	// DefaultStepTypePolicy is the policy of the step types of the workflow runs in the namespaces without the overrides
+	DefaultStepTypePolicy StepTypePolicy
+	// StepTypePolicyConfigMap is the ConfigMap(namespace/name) overriding the step type policy of the namespaces, the
+	// data is keyed by the namespace and the value is the policy in JSON. The namespace is vela-system if not specified.
+	StepTypePolicyConfigMap string
+)
+
+// StepTypePolicy restricts the step types of the workflow runs by the glob patterns, a step type is allowed if it
+// matches one of the allowed patterns, or no allowed pattern is set, and matches none of the denied patterns.
+type StepTypePolicy struct {
+	Allowed []string `json:"allowed,omitempty"`
+	Denied  []string `json:"denied,omitempty"`
+}
+
+// Allow returns true if the step type is allowed by the policy, the revision of the type is ignored.
+func (p StepTypePolicy) Allow(typ string) bool {
+	name, _ := template.SplitRevision(typ)
+	return (len(p.Allowed) == 0 || matchAny(p.Allowed, name)) && !matchAny(p.Denied, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// StepTypeDeniedError describes the step whose type is not allowed in the namespace of the workflow run
+type StepTypeDeniedError struct {
+	Step      string
+	Type      string
+	Namespace string
+}
+
+// Error implements the Error interface.
+func (e StepTypeDeniedError) Error() string {
+	return fmt.Sprintf("the type %s of step %s is not allowed in the namespace %s", e.Type, e.Step, e.Namespace)
+}
+
+// IsStepTypeDeniedErr returns true if the specified error is StepTypeDeniedError type.
+func IsStepTypeDeniedErr(err error) bool {
+	return errors.As(err, &StepTypeDeniedError{})
+}
+
+// checkStepTypes rejects the steps and the sub steps whose types are not allowed in the namespace of the workflow run
+func checkStepTypes(ctx context.Context, cli client.Client, instance *types.WorkflowInstance) error {
+	policy, err := getStepTypePolicy(ctx, cli, instance.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, step := range instance.Steps {
+		if !policy.Allow(step.Type) {
+			return StepTypeDeniedError{Step: step.Name, Type: step.Type, Namespace: instance.Namespace}
+		}
+		for _, sub := range step.SubSteps {
+			if !policy.Allow(sub.Type) {
+				return StepTypeDeniedError{Step: sub.Name, Type: sub.Type, Namespace: instance.Namespace}
+			}
+		}
+	}
+	return nil
+}
+
+// getStepTypePolicy returns the step type policy of the namespace, the override in StepTypePolicyConfigMap replaces
+// the default policy.
+func getStepTypePolicy(ctx context.Context, cli client.Client, namespace string) (StepTypePolicy, error) {
+	if StepTypePolicyConfigMap == "" || cli == nil {
+		return DefaultStepTypePolicy, nil
+	}
+	key := client.ObjectKey{Namespace: types.DefaultKubeVelaNS, Name: StepTypePolicyConfigMap}
+	if i := strings.Index(StepTypePolicyConfigMap, "/"); i >= 0 {
+		key.Namespace, key.Name = StepTypePolicyConfigMap[:i], StepTypePolicyConfigMap[i+1:]
+	}
+	cm := &corev1.ConfigMap{}
+	if err := cli.Get(ctx, key, cm); err != nil {
+		if kerrors.IsNotFound(err) {
+			return DefaultStepTypePolicy, nil
+		}
+		return StepTypePolicy{}, errors.WithMessage(err, "get the step type policy")
+	}
+	override, ok := cm.Data[namespace]
+	if !ok {
+		return DefaultStepTypePolicy, nil
+	}
+	policy := StepTypePolicy{}
+	if err := json.Unmarshal([]byte(override), &policy); err != nil {
+		return StepTypePolicy{}, errors.WithMessagef(err, "parse the step type policy of the namespace %s", namespace)
+	}
+	return policy, nil
+}
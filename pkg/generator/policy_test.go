@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestCheckStepTypes(t *testing.T) {
+	defaultPolicy, configMap := DefaultStepTypePolicy, StepTypePolicyConfigMap
+	defer func() {
+		DefaultStepTypePolicy, StepTypePolicyConfigMap = defaultPolicy, configMap
+	}()
+	DefaultStepTypePolicy = StepTypePolicy{Allowed: []string{"notification", "suspend", "apply-*"}, Denied: []string{"apply-cluster-*"}}
+	StepTypePolicyConfigMap = "step-type-policy"
+	cli := fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "step-type-policy", Namespace: types.DefaultKubeVelaNS},
+		Data: map[string]string{
+			"admin":   `{"allowed":["*"]}`,
+			"invalid": `[`,
+		},
+	}).Build()
+
+	testCases := map[string]struct {
+		namespace string
+		steps     []v1alpha1.WorkflowStep
+		expected  string
+	}{
+		"allowed": {
+			namespace: "default",
+			steps: []v1alpha1.WorkflowStep{
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "approve", Type: "suspend"}},
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "deploy", Type: "apply-deployment@v1"}},
+			},
+		},
+		"not allowed": {
+			namespace: "default",
+			steps: []v1alpha1.WorkflowStep{
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "apply", Type: "kube-apply"}},
+			},
+			expected: "the type kube-apply of step apply is not allowed in the namespace default",
+		},
+		"denied sub step": {
+			namespace: "default",
+			steps: []v1alpha1.WorkflowStep{
+				{
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "group", Type: "step-group"},
+					SubSteps: []v1alpha1.WorkflowStepBase{
+						{Name: "admin", Type: "apply-cluster-role"},
+					},
+				},
+			},
+			expected: "the type step-group of step group is not allowed in the namespace default",
+		},
+		"override": {
+			namespace: "admin",
+			steps: []v1alpha1.WorkflowStep{
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "apply", Type: "kube-apply"}},
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "admin", Type: "apply-cluster-role"}},
+			},
+		},
+		"invalid override": {
+			namespace: "invalid",
+			steps: []v1alpha1.WorkflowStep{
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "approve", Type: "suspend"}},
+			},
+			expected: "parse the step type policy of the namespace invalid",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			err := checkStepTypes(context.Background(), cli, &types.WorkflowInstance{
+				WorkflowMeta: types.WorkflowMeta{Namespace: tc.namespace},
+				Steps:        tc.steps,
+			})
+			if tc.expected == "" {
+				r.NoError(err)
+				return
+			}
+			r.Error(err)
+			r.Contains(err.Error(), tc.expected)
+		})
+	}
+
+	DefaultStepTypePolicy.Allowed = append(DefaultStepTypePolicy.Allowed, "step-group")
+	err := checkStepTypes(context.Background(), cli, &types.WorkflowInstance{
+		WorkflowMeta: types.WorkflowMeta{Namespace: "default"},
+		Steps:        testCases["denied sub step"].steps,
+	})
+	require.True(t, IsStepTypeDeniedErr(err))
+	require.Equal(t, "the type apply-cluster-role of step admin is not allowed in the namespace default", err.Error())
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/executor"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestRunWithFilesystemTemplates(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().Build()
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "standalone", Namespace: "default"},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowSpec: &v1alpha1.WorkflowSpec{
+				Steps: []v1alpha1.WorkflowStep{{
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{
+						Name:       "greet",
+						Type:       "greet",
+						Properties: &runtime.RawExtension{Raw: []byte(`{"name":"world"}`)},
+						Outputs:    v1alpha1.StepOutputs{{Name: "message", ValueFrom: "message"}},
+					},
+				}},
+			},
+		},
+	}
+	fsys := fstest.MapFS{
+		"greet.cue": {Data: []byte(`
+parameter: name: string
+message: "hello " + parameter.name
+`)},
+	}
+	ctx := monitorContext.NewTraceContext(context.Background(), "standalone")
+	instance, err := GenerateWorkflowInstance(ctx, cli, run)
+	r.NoError(err)
+	runners, err := GenerateRunners(ctx, instance, types.StepGeneratorOptions{Client: cli, TemplateFS: fsys})
+	r.NoError(err)
+	state, err := executor.New(instance, cli).ExecuteRunners(ctx, runners)
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, state)
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, instance.Status.Steps[0].Phase)
+}
+
+func TestRunWithDefinitionRevisions(t *testing.T) {
+	r := require.New(t)
+	def := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "core.oam.dev/v1beta1",
+		"kind":       "WorkflowStepDefinition",
+		"metadata":   map[string]interface{}{"name": "greet", "namespace": "vela-system"},
+		"spec": map[string]interface{}{"schematic": map[string]interface{}{"cue": map[string]interface{}{
+			"template": "parameter: name: string\nmessage: \"hello \" + parameter.name\n",
+		}}},
+	}}
+	cli := fake.NewClientBuilder().WithObjects(def).Build()
+	newRun := func(name, typ string) *v1alpha1.WorkflowRun {
+		return &v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: v1alpha1.WorkflowRunSpec{
+				WorkflowSpec: &v1alpha1.WorkflowSpec{
+					Steps: []v1alpha1.WorkflowStep{{
+						WorkflowStepBase: v1alpha1.WorkflowStepBase{
+							Name:       "greet",
+							Type:       typ,
+							Properties: &runtime.RawExtension{Raw: []byte(`{"name":"world"}`)},
+						},
+					}},
+				},
+			},
+		}
+	}
+	ctx := monitorContext.NewTraceContext(context.Background(), "revision")
+	execute := func(run *v1alpha1.WorkflowRun) *types.WorkflowInstance {
+		instance, err := GenerateWorkflowInstance(ctx, cli, run)
+		r.NoError(err)
+		runners, err := GenerateRunners(ctx, instance, types.StepGeneratorOptions{Client: cli})
+		r.NoError(err)
+		_, err = executor.New(instance, cli).ExecuteRunners(ctx, runners)
+		r.NoError(err)
+		return instance
+	}
+
+	instance := execute(newRun("latest", "greet"))
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, instance.Status.Steps[0].Phase)
+	r.Equal("greet@v1", instance.Status.Steps[0].DefinitionRevision)
+	r.Equal("greet", instance.Status.Steps[0].Type)
+
+	// the template is not compatible with the properties after the change
+	r.NoError(unstructured.SetNestedField(def.Object, "parameter: name: int\n", "spec", "schematic", "cue", "template"))
+	r.NoError(cli.Update(ctx, def))
+	instance = execute(newRun("changed", "greet"))
+	r.Equal(v1alpha1.WorkflowStepPhaseFailed, instance.Status.Steps[0].Phase)
+	r.Equal("greet@v2", instance.Status.Steps[0].DefinitionRevision)
+
+	instance = execute(newRun("pinned", "greet@v1"))
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, instance.Status.Steps[0].Phase)
+	r.Equal("greet@v1", instance.Status.Steps[0].DefinitionRevision)
+
+	// the recorded revision is used for the lifetime of the run
+	run := newRun("recorded", "greet")
+	run.Status.Steps = []v1alpha1.WorkflowStepStatus{{StepStatus: v1alpha1.StepStatus{
+		ID: "recorded", Name: "greet", Type: "greet", Phase: v1alpha1.WorkflowStepPhasePending, DefinitionRevision: "greet@v1",
+	}}}
+	instance = execute(run)
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, instance.Status.Steps[0].Phase)
+	r.Equal("greet@v1", instance.Status.Steps[0].DefinitionRevision)
+
+	_, err := GenerateRunners(ctx, &types.WorkflowInstance{Steps: newRun("missing", "greet@v9").Spec.WorkflowSpec.Steps}, types.StepGeneratorOptions{Client: cli})
+	r.Error(err)
+	r.Contains(err.Error(), "revision v9 of workflow step definition greet not found")
+}
+
+func TestRunWithValuesStep(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().Build()
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "values", Namespace: "default"},
+		Spec: v1alpha1.WorkflowRunSpec{
+			Mode: &v1alpha1.WorkflowExecuteMode{Steps: v1alpha1.WorkflowModeDAG},
+			WorkflowSpec: &v1alpha1.WorkflowSpec{
+				Steps: []v1alpha1.WorkflowStep{{
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{
+						Name:       "image",
+						Type:       "values",
+						Properties: &runtime.RawExtension{Raw: []byte(`{"repo":"nginx","tag":"1.21"}`)},
+						Outputs:    v1alpha1.StepOutputs{{Name: "image", ValueFrom: `values.repo + ":" + values.tag`}},
+					},
+				}, {
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{
+						Name:       "join",
+						Type:       "values",
+						If:         `status.image.succeeded`,
+						Properties: &runtime.RawExtension{Raw: []byte(`{"replicas":2}`)},
+						Inputs:     v1alpha1.StepInputs{{From: "image", ParameterKey: "image"}},
+						Outputs:    v1alpha1.StepOutputs{{Name: "spec", ValueFrom: "values"}},
+					},
+				}, {
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{
+						Name:      "skipped",
+						Type:      "values",
+						If:        `status.image.failed`,
+						DependsOn: []string{"image"},
+					},
+				}},
+			},
+		},
+	}
+	ctx := monitorContext.NewTraceContext(context.Background(), "values")
+	instance, err := GenerateWorkflowInstance(ctx, cli, run)
+	r.NoError(err)
+	runners, err := GenerateRunners(ctx, instance, types.StepGeneratorOptions{Client: cli})
+	r.NoError(err)
+	state, err := executor.New(instance, cli).ExecuteRunners(ctx, runners)
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, state)
+	phases := map[string]v1alpha1.WorkflowStepPhase{}
+	for _, ss := range instance.Status.Steps {
+		phases[ss.Name] = ss.Phase
+	}
+	r.Equal(map[string]v1alpha1.WorkflowStepPhase{
+		"image":   v1alpha1.WorkflowStepPhaseSucceeded,
+		"join":    v1alpha1.WorkflowStepPhaseSucceeded,
+		"skipped": v1alpha1.WorkflowStepPhaseSkipped,
+	}, phases)
+	wfCtx, err := wfContext.LoadContext(cli, instance.Namespace, instance.Name, instance.Status.ContextBackend.Name)
+	r.NoError(err)
+	spec, err := wfCtx.GetVar("spec")
+	r.NoError(err)
+	b, err := spec.CueValue().MarshalJSON()
+	r.NoError(err)
+	r.JSONEq(`{"replicas":2,"image":"nginx:1.21"}`, string(b))
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"time"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+var (
+	// DefaultStepTimeout is the timeout of the steps which do not specify their own, 0 means no timeout
+	DefaultStepTimeout time.Duration
+	// DefaultWorkflowTimeout is the timeout of the workflow runs which do not specify their own, 0 means no timeout
+	DefaultWorkflowTimeout time.Duration
+)
+
+// applyDefaultStepTimeout sets the timeout of the steps without their own. The timeout recorded in the status is
+// kept so that the effective timeout of the started step is not changed with the default, otherwise it's
+// DefaultStepTimeout. The step groups and the suspend steps are not applied, the sub steps of the groups are.
+func applyDefaultStepTimeout(steps []v1alpha1.WorkflowStep, status v1alpha1.WorkflowRunStatus) {
+	recorded := map[string]string{}
+	for _, ss := range status.Steps {
+		recorded[ss.Name] = ss.Timeout
+		for _, sub := range ss.SubStepsStatus {
+			recorded[sub.Name] = sub.Timeout
+		}
+	}
+	apply := func(step *v1alpha1.WorkflowStepBase) {
+		if step.Timeout != "" || step.Type == types.WorkflowStepTypeSuspend || step.Type == types.WorkflowStepTypeStepGroup {
+			return
+		}
+		if timeout, ok := recorded[step.Name]; ok {
+			step.Timeout = timeout
+			return
+		}
+		if DefaultStepTimeout > 0 {
+			step.Timeout = DefaultStepTimeout.String()
+		}
+	}
+	for i := range steps {
+		apply(&steps[i].WorkflowStepBase)
+		for j := range steps[i].SubSteps {
+			apply(&steps[i].SubSteps[j])
+		}
+	}
+}
+
+// workflowTimeout returns the effective timeout of the workflow run, the one recorded in the status is kept
+// if the run does not specify its own
+func workflowTimeout(run *v1alpha1.WorkflowRun) string {
+	switch {
+	case run.Spec.Timeout != "":
+		return run.Spec.Timeout
+	case run.Status.Timeout != "" || !run.Status.StartTime.IsZero():
+		return run.Status.Timeout
+	case DefaultWorkflowTimeout > 0:
+		return DefaultWorkflowTimeout.String()
+	default:
+		return ""
+	}
+}
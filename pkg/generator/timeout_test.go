@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+func TestApplyDefaultStepTimeout(t *testing.T) {
+	defaultTimeout := DefaultStepTimeout
+	defer func() {
+		DefaultStepTimeout = defaultTimeout
+	}()
+	DefaultStepTimeout = 10 * time.Minute
+	steps := []v1alpha1.WorkflowStep{
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "apply", Type: "apply-object"}},
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "explicit", Type: "apply-object", Timeout: "1m"}},
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "approve", Type: "suspend"}},
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "started", Type: "apply-object"}},
+		{
+			WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "group", Type: "step-group"},
+			SubSteps:         []v1alpha1.WorkflowStepBase{{Name: "sub", Type: "apply-object"}},
+		},
+	}
+	status := v1alpha1.WorkflowRunStatus{
+		Steps: []v1alpha1.WorkflowStepStatus{{StepStatus: v1alpha1.StepStatus{Name: "started", Timeout: "5m"}}},
+	}
+	applyDefaultStepTimeout(steps, status)
+	r := require.New(t)
+	r.Equal("10m0s", steps[0].Timeout)
+	r.Equal("1m", steps[1].Timeout)
+	r.Equal("", steps[2].Timeout)
+	r.Equal("5m", steps[3].Timeout)
+	r.Equal("", steps[4].Timeout)
+	r.Equal("10m0s", steps[4].SubSteps[0].Timeout)
+}
+
+func TestWorkflowTimeout(t *testing.T) {
+	defaultTimeout := DefaultWorkflowTimeout
+	defer func() {
+		DefaultWorkflowTimeout = defaultTimeout
+	}()
+	DefaultWorkflowTimeout = time.Hour
+
+	testCases := map[string]struct {
+		run      *v1alpha1.WorkflowRun
+		expected string
+	}{
+		"explicit": {
+			run:      &v1alpha1.WorkflowRun{Spec: v1alpha1.WorkflowRunSpec{Timeout: "30m"}, Status: v1alpha1.WorkflowRunStatus{Timeout: "1h0m0s"}},
+			expected: "30m",
+		},
+		"default": {
+			run:      &v1alpha1.WorkflowRun{},
+			expected: "1h0m0s",
+		},
+		"recorded": {
+			run:      &v1alpha1.WorkflowRun{Status: v1alpha1.WorkflowRunStatus{Timeout: "2h0m0s"}},
+			expected: "2h0m0s",
+		},
+		"started without timeout": {
+			run:      &v1alpha1.WorkflowRun{Status: v1alpha1.WorkflowRunStatus{StartTime: metav1.Now()}},
+			expected: "",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, workflowTimeout(tc.run))
+		})
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/condition"
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+var (
+	// MaxEntriesPerNamespace is the max number of the finished runs kept in the history of each namespace
+	MaxEntriesPerNamespace = 1000
+	// DefaultStore is the history store maintained by the controller
+	DefaultStore Store = NewStore()
+)
+
+// Entry is the record of a finished workflow run
+type Entry struct {
+	Name        string
+	Namespace   string
+	WorkflowRef string
+	Labels      map[string]string
+	Phase       v1alpha1.WorkflowRunPhase
+	// Reason is the reason of the Terminated condition of the workflow run
+	Reason  string
+	EndTime time.Time
+}
+
+// Failed returns true if the workflow run is failed or terminated, the runs terminated by the users are not failures
+func (e Entry) Failed() bool {
+	if e.Phase == v1alpha1.WorkflowStateTerminated {
+		return e.Reason != v1alpha1.TerminatedReasonUserTerminated
+	}
+	return e.Phase == v1alpha1.WorkflowStateFailed
+}
+
+// Query is the query of the history entries
+type Query struct {
+	Namespace   string
+	WorkflowRef string
+	Selector    labels.Selector
+	// Count is the max number of the latest entries to return, zero means no limit
+	Count int
+	// Since filters the entries finished before it, zero means no limit
+	Since time.Time
+}
+
+// Store is the store of the history of the finished workflow runs
+type Store interface {
+	Record(entry Entry)
+	List(query Query) []Entry
+}
+
+type memoryStore struct {
+	mu      sync.RWMutex
+	entries map[string][]Entry
+}
+
+// NewStore creates an in-memory history store
+func NewStore() Store {
+	return &memoryStore{entries: map[string][]Entry{}}
+}
+
+// NewEntry creates the history entry of the workflow run
+func NewEntry(run *v1alpha1.WorkflowRun) Entry {
+	return Entry{
+		Name:        run.Name,
+		Namespace:   run.Namespace,
+		WorkflowRef: run.Spec.WorkflowRef,
+		Labels:      run.Labels,
+		Phase:       run.Status.Phase,
+		Reason:      string(run.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunTerminatedConditionType)).Reason),
+		EndTime:     run.Status.EndTime.Time,
+	}
+}
+
+// Rebuild records the finished workflow runs in the store, which restores the history kept in memory when the
+// controller starts. The runs in the dry-run mode are skipped.
+func Rebuild(ctx context.Context, cli client.Reader, store Store) error {
+	runs := &v1alpha1.WorkflowRunList{}
+	if err := cli.List(ctx, runs); err != nil {
+		return err
+	}
+	for i := range runs.Items {
+		if runs.Items[i].Status.Finished && !runs.Items[i].Status.DryRun {
+			store.Record(NewEntry(&runs.Items[i]))
+		}
+	}
+	return nil
+}
+
+// Record records the entry, the entry with the same name in the namespace is replaced
+func (s *memoryStore) Record(entry Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := s.entries[entry.Namespace]
+	for i, e := range entries {
+		if e.Name == entry.Name {
+			entries = append(entries[:i], entries[i+1:]...)
+			break
+		}
+	}
+	entries = append(entries, entry)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].EndTime.Before(entries[j].EndTime)
+	})
+	if len(entries) > MaxEntriesPerNamespace {
+		entries = entries[len(entries)-MaxEntriesPerNamespace:]
+	}
+	s.entries[entry.Namespace] = entries
+}
+
+// List lists the entries matches the query, the latest entry comes first
+func (s *memoryStore) List(query Query) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := s.entries[query.Namespace]
+	var result []Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if query.Count > 0 && len(result) >= query.Count {
+			break
+		}
+		if !query.Since.IsZero() && e.EndTime.Before(query.Since) {
+			break
+		}
+		if query.WorkflowRef != "" && e.WorkflowRef != query.WorkflowRef {
+			continue
+		}
+		if query.Selector != nil && !query.Selector.Matches(labels.Set(e.Labels)) {
+			continue
+		}
+		result = append(result, e)
+	}
+	return result
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/condition"
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+func TestStore(t *testing.T) {
+	r := require.New(t)
+	defer func(max int) { MaxEntriesPerNamespace = max }(MaxEntriesPerNamespace)
+	MaxEntriesPerNamespace = 3
+	store := NewStore()
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		store.Record(NewEntry(&v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("run-%d", i), Namespace: "default"},
+			Spec:       v1alpha1.WorkflowRunSpec{WorkflowRef: "deploy"},
+			Status: v1alpha1.WorkflowRunStatus{
+				Phase:   v1alpha1.WorkflowStateSucceeded,
+				EndTime: metav1.NewTime(now.Add(time.Duration(i) * time.Minute)),
+			},
+		}))
+	}
+	entries := store.List(Query{Namespace: "default", WorkflowRef: "deploy"})
+	r.Equal(3, len(entries))
+	r.Equal("run-3", entries[0].Name)
+	r.Equal("run-1", entries[2].Name)
+
+	// replace the entry with the same name
+	store.Record(Entry{Name: "run-2", Namespace: "default", WorkflowRef: "deploy", Phase: v1alpha1.WorkflowStateFailed, EndTime: now.Add(time.Hour)})
+	entries = store.List(Query{Namespace: "default", Count: 2})
+	r.Equal(2, len(entries))
+	r.Equal("run-2", entries[0].Name)
+	r.True(entries[0].Failed())
+	r.Equal("run-3", entries[1].Name)
+
+	entries = store.List(Query{Namespace: "default", Since: now.Add(150 * time.Second)})
+	r.Equal(2, len(entries))
+	r.Empty(store.List(Query{Namespace: "other"}))
+}
+
+func TestRebuild(t *testing.T) {
+	r := require.New(t)
+	scheme := runtime.NewScheme()
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	now := metav1.Now()
+	terminated := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "terminated", Namespace: "default"},
+		Spec:       v1alpha1.WorkflowRunSpec{WorkflowRef: "deploy"},
+		Status:     v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateTerminated, Finished: true, EndTime: now},
+	}
+	terminated.SetConditions(condition.Condition{
+		Type:   condition.ConditionType(v1alpha1.WorkflowRunTerminatedConditionType),
+		Status: corev1.ConditionTrue,
+		Reason: v1alpha1.TerminatedReasonUserTerminated,
+	})
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "failed", Namespace: "default"},
+			Spec:       v1alpha1.WorkflowRunSpec{WorkflowRef: "deploy"},
+			Status:     v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateFailed, Finished: true, EndTime: now},
+		},
+		&v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "default"},
+			Spec:       v1alpha1.WorkflowRunSpec{WorkflowRef: "deploy"},
+			Status:     v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateExecuting},
+		},
+		terminated,
+	).Build()
+	store := NewStore()
+	r.NoError(Rebuild(context.Background(), cli, store))
+	entries := store.List(Query{Namespace: "default", WorkflowRef: "deploy"})
+	r.Equal(2, len(entries))
+	for _, e := range entries {
+		r.Equal(e.Name == "failed", e.Failed())
+	}
+}
@@ -17,12 +17,16 @@ limitations under the License.
 package hooks
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/strings/slices"
 
 	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
@@ -30,14 +34,45 @@ import (
 	wfTypes "github.com/kubevela/workflow/pkg/types"
 )
 
+const (
+	// OutputOverflowConfigMap moves the outputs exceeding the size limit to the overflow ConfigMap of the workflow
+	// context, and keeps the references to them in the context
+	OutputOverflowConfigMap = "configmap"
+	// OutputOverflowTruncate keeps the outputs exceeding the size limit as the strings truncated to the size limit
+	OutputOverflowTruncate = "truncate"
+)
+
+var (
+	// MaxOutputSize is the max size in bytes of an output kept in the workflow context, 0 means no limit
+	MaxOutputSize = 0
+	// OutputOverflowStrategy is how the outputs exceeding the size limit are stored
+	OutputOverflowStrategy = OutputOverflowConfigMap
+)
+
 // Input set data to parameter.
 func Input(ctx wfContext.Context, paramValue *value.Value, step v1alpha1.WorkflowStep) error {
 	for _, input := range step.Inputs {
-		inputValue, err := ctx.GetVar(strings.Split(input.From, ".")...)
+		if input.Patch {
+			continue
+		}
+		inputValue, err := wfContext.LookupVar(ctx, value.SplitPath(input.From)...)
 		if err != nil {
 			inputValue, err = paramValue.LookupByScript(input.From)
-			if err != nil {
-				return errors.WithMessagef(err, "get input from [%s]", input.From)
+		}
+		if err == nil && isSkippedOutput(ctx, input, inputValue) {
+			continue
+		}
+		// the outputs of the skipped steps are null, treat them as missing for the optional inputs
+		if err != nil || (isOptionalInput(input) && inputValue.CueValue().Null() == nil) {
+			switch {
+			case input.Default != nil:
+				if inputValue, err = paramValue.MakeValue(string(input.Default.Raw)); err != nil {
+					return errors.WithMessagef(err, "parse the default value of input [%s]", input.From)
+				}
+			case input.Optional:
+				continue
+			default:
+				return missingInputError(ctx, input.From, err)
 			}
 		}
 		if input.ParameterKey != "" {
@@ -49,13 +84,262 @@ func Input(ctx wfContext.Context, paramValue *value.Value, step v1alpha1.Workflo
 	return nil
 }
 
+// HasPatchInputs returns true if any input of the step is applied to the properties as a patch
+func HasPatchInputs(step v1alpha1.WorkflowStep) bool {
+	for _, input := range step.Inputs {
+		if input.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// PatchProperties applies the patch inputs to the properties of the step in order and returns the patched properties,
+// the object is applied as a JSON merge patch like `kubectl patch --type=merge` and the list is applied as a JSON
+// patch of RFC6902, the op failed to apply is reported with its index.
+func PatchProperties(ctx wfContext.Context, step v1alpha1.WorkflowStep) (*runtime.RawExtension, error) {
+	properties := []byte("{}")
+	if step.Properties != nil && len(step.Properties.Raw) > 0 {
+		properties = step.Properties.Raw
+	}
+	for _, input := range step.Inputs {
+		if !input.Patch {
+			continue
+		}
+		var patch []byte
+		inputValue, err := wfContext.LookupVar(ctx, value.SplitPath(input.From)...)
+		if err == nil && isSkippedOutput(ctx, input, inputValue) {
+			continue
+		}
+		if err != nil || (isOptionalInput(input) && inputValue.CueValue().Null() == nil) {
+			switch {
+			case input.Default != nil:
+				patch = input.Default.Raw
+			case input.Optional:
+				continue
+			default:
+				return nil, missingInputError(ctx, input.From, err)
+			}
+		} else if patch, err = inputValue.CueValue().MarshalJSON(); err != nil {
+			return nil, errors.WithMessagef(err, "encode the patch of input [%s]", input.From)
+		}
+		if properties, err = applyPatch(properties, patch); err != nil {
+			return nil, errors.WithMessagef(err, "patch the properties with input [%s]", input.From)
+		}
+	}
+	return &runtime.RawExtension{Raw: properties}, nil
+}
+
+func applyPatch(doc, patch []byte) ([]byte, error) {
+	switch trimmed := bytes.TrimSpace(patch); {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		ops, err := jsonpatch.DecodePatch(trimmed)
+		if err != nil {
+			return nil, errors.WithMessage(err, "decode the json patch")
+		}
+		for i, op := range ops {
+			if doc, err = (jsonpatch.Patch{op}).Apply(doc); err != nil {
+				return nil, errors.WithMessagef(err, "apply the op %d of the json patch", i)
+			}
+		}
+		return doc, nil
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return jsonpatch.MergePatch(doc, trimmed)
+	default:
+		return nil, errors.New("the patch must be an object or a list")
+	}
+}
+
+// OutputProducers returns the names of the steps that produce the outputs, keyed by the output names.
+// The qualified names of the sub step outputs are included.
+func OutputProducers(steps []v1alpha1.WorkflowStep) map[string]string {
+	producers := map[string]string{}
+	for _, step := range steps {
+		for _, output := range step.Outputs {
+			producers[output.Name] = step.Name
+		}
+		for _, sub := range step.SubSteps {
+			for _, output := range sub.Outputs {
+				producers[output.Name] = sub.Name
+			}
+		}
+	}
+	for step, outputs := range QualifiedOutputs(steps) {
+		for _, qualified := range outputs {
+			producers[qualified] = step
+		}
+	}
+	return producers
+}
+
+// QualifiedOutputs returns the qualified names of the sub step outputs, keyed by the sub step names and the output names.
+// The output of the sub step is qualified as `<step group>.<sub step>.<output>` if the output name is declared by
+// more than one step, so that it can be referred without being overridden by the other steps.
+func QualifiedOutputs(steps []v1alpha1.WorkflowStep) map[string]map[string]string {
+	declared := map[string]int{}
+	for _, step := range steps {
+		for _, output := range step.Outputs {
+			declared[output.Name]++
+		}
+		for _, sub := range step.SubSteps {
+			for _, output := range sub.Outputs {
+				declared[output.Name]++
+			}
+		}
+	}
+	qualified := map[string]map[string]string{}
+	for _, step := range steps {
+		for _, sub := range step.SubSteps {
+			for _, output := range sub.Outputs {
+				if declared[output.Name] < 2 {
+					continue
+				}
+				if qualified[sub.Name] == nil {
+					qualified[sub.Name] = map[string]string{}
+				}
+				qualified[sub.Name][output.Name] = strings.Join([]string{step.Name, sub.Name, output.Name}, ".")
+			}
+		}
+	}
+	return qualified
+}
+
+// ProducerOf returns the name of the step that produces the output referred by the input
+func ProducerOf(ctx wfContext.Context, from string) string {
+	v, ok := ctx.GetValueInMemory(wfTypes.ContextKeyOutputProducers)
+	if !ok {
+		return ""
+	}
+	producers, ok := v.(map[string]string)
+	if !ok {
+		return ""
+	}
+	return lookupProducer(producers, from)
+}
+
+func lookupProducer(producers map[string]string, from string) string {
+	if producer, ok := producers[from]; ok {
+		return producer
+	}
+	segments := strings.Split(from, ".")
+	if len(segments) > 3 {
+		if producer, ok := producers[strings.Join(segments[:3], ".")]; ok {
+			return producer
+		}
+	}
+	return producers[segments[0]]
+}
+
+// StepDependencies returns the names of the steps that each step depends on, keyed by the step names.
+// The dependencies are the explicit dependsOn and the steps producing the required inputs.
+func StepDependencies(steps []v1alpha1.WorkflowStep) map[string][]string {
+	dependencies := map[string][]string{}
+	for name, typed := range TypedStepDependencies(steps) {
+		var names []string
+		for _, dependency := range typed {
+			names = append(names, dependency.Step)
+		}
+		dependencies[name] = names
+	}
+	return dependencies
+}
+
+// StepDependency is the dependency of a step on another step
+type StepDependency struct {
+	// Step is the name of the step depended on
+	Step string
+	// Data indicates the dependency is inferred from the required inputs instead of the explicit dependsOn
+	Data bool
+}
+
+// TypedStepDependencies returns the dependencies of each step keyed by the step names, the explicit dependsOn
+// precede the dependencies inferred from the required inputs.
+func TypedStepDependencies(steps []v1alpha1.WorkflowStep) map[string][]StepDependency {
+	producers := OutputProducers(steps)
+	dependencies := map[string][]StepDependency{}
+	add := func(step v1alpha1.WorkflowStepBase) {
+		var names []string
+		for _, dependsOn := range step.DependsOn {
+			names = append(names, dependsOn)
+			dependencies[step.Name] = append(dependencies[step.Name], StepDependency{Step: dependsOn})
+		}
+		for _, input := range step.Inputs {
+			if isOptionalInput(input) {
+				continue
+			}
+			producer := lookupProducer(producers, input.From)
+			if producer == "" || producer == step.Name || slices.Contains(names, producer) {
+				continue
+			}
+			names = append(names, producer)
+			dependencies[step.Name] = append(dependencies[step.Name], StepDependency{Step: producer, Data: true})
+		}
+		if _, ok := dependencies[step.Name]; !ok {
+			dependencies[step.Name] = nil
+		}
+	}
+	for _, step := range steps {
+		add(step.WorkflowStepBase)
+		for _, sub := range step.SubSteps {
+			add(sub)
+		}
+	}
+	return dependencies
+}
+
+func isOptionalInput(input v1alpha1.InputItem) bool {
+	return input.Optional || input.Default != nil
+}
+
+// isSkippedOutput returns true if the required input is the null output of a skipped step, the step depending on the
+// skipped step runs with the input absent instead of null. The null outputs of the other steps are kept.
+func isSkippedOutput(ctx wfContext.Context, input v1alpha1.InputItem, v *value.Value) bool {
+	if isOptionalInput(input) || v.CueValue().Null() != nil {
+		return false
+	}
+	producer := ProducerOf(ctx, input.From)
+	return producer != "" && isSkippedStep(ctx, producer)
+}
+
+// SetSkippedSteps marks the skipped steps and sub steps in the status of the run, the outputs of them are null
+func SetSkippedSteps(ctx wfContext.Context, status v1alpha1.WorkflowRunStatus) {
+	for _, step := range status.Steps {
+		if step.Phase == v1alpha1.WorkflowStepPhaseSkipped {
+			ctx.SetValueInMemory(true, wfTypes.ContextKeySkippedSteps, step.Name)
+		}
+		for _, sub := range step.SubStepsStatus {
+			if sub.Phase == v1alpha1.WorkflowStepPhaseSkipped {
+				ctx.SetValueInMemory(true, wfTypes.ContextKeySkippedSteps, sub.Name)
+			}
+		}
+	}
+}
+
+func isSkippedStep(ctx wfContext.Context, name string) bool {
+	_, ok := ctx.GetValueInMemory(wfTypes.ContextKeySkippedSteps, name)
+	return ok
+}
+
+func missingInputError(ctx wfContext.Context, from string, err error) error {
+	if producer := ProducerOf(ctx, from); producer != "" {
+		return errors.Errorf("input [%s] is missing, the output is expected to be produced by step %s", from, producer)
+	}
+	if err == nil {
+		return errors.Errorf("input [%s] is missing", from)
+	}
+	return errors.WithMessagef(err, "get input from [%s]", from)
+}
+
 // Output get data from task value.
 func Output(ctx wfContext.Context, taskValue *value.Value, step v1alpha1.WorkflowStep, status v1alpha1.StepStatus, stepStatus map[string]v1alpha1.StepStatus) error {
 	errMsg := ""
 	if wfTypes.IsStepFinish(status.Phase, status.Reason) {
 		SetAdditionalNameInStatus(stepStatus, step.Name, step.Properties, status)
+		if status.Phase == v1alpha1.WorkflowStepPhaseSkipped {
+			ctx.SetValueInMemory(true, wfTypes.ContextKeySkippedSteps, step.Name)
+		}
 		for _, output := range step.Outputs {
-			v, err := taskValue.LookupByScript(output.ValueFrom)
+			v, err := taskValue.EvaluateExpression(output.ValueFrom)
 			// if the error is not nil and the step is not skipped, return the error
 			if err != nil && status.Phase != v1alpha1.WorkflowStepPhaseSkipped {
 				errMsg += fmt.Sprintf("failed to get output from %s: %s\n", output.ValueFrom, err.Error())
@@ -64,9 +348,23 @@ func Output(ctx wfContext.Context, taskValue *value.Value, step v1alpha1.Workflo
 			if err != nil || v.Error() != nil {
 				v, _ = taskValue.MakeValue("null")
 			}
+			qualified := qualifiedOutput(ctx, step.Name, output.Name)
+			key := output.Name
+			if qualified != "" {
+				key = qualified
+			}
+			if v, err = limitOutputSize(ctx, v, key); err != nil {
+				errMsg += fmt.Sprintf("failed to set output %s: %s\n", output.Name, err.Error())
+				continue
+			}
 			if err := ctx.SetVar(v, output.Name); err != nil {
 				errMsg += fmt.Sprintf("failed to set output %s: %s\n", output.Name, err.Error())
 			}
+			if qualified != "" {
+				if err := ctx.SetVar(v, value.SplitPath(qualified)...); err != nil {
+					errMsg += fmt.Sprintf("failed to set output %s: %s\n", qualified, err.Error())
+				}
+			}
 		}
 	}
 
@@ -76,6 +374,70 @@ func Output(ctx wfContext.Context, taskValue *value.Value, step v1alpha1.Workflo
 	return nil
 }
 
+// limitOutputSize returns the value to keep in the workflow context for the output exceeding MaxOutputSize,
+// which is either the reference to the overflow ConfigMap or the truncated string.
+func limitOutputSize(ctx wfContext.Context, v *value.Value, key string) (*value.Value, error) {
+	if MaxOutputSize <= 0 {
+		return v, nil
+	}
+	s, err := v.String()
+	if err != nil || len(s) <= MaxOutputSize {
+		return v, nil
+	}
+	if OutputOverflowStrategy == OutputOverflowTruncate {
+		b, err := json.Marshal(truncateOutput(v, s, MaxOutputSize))
+		if err != nil {
+			return nil, err
+		}
+		return v.MakeValue(string(b))
+	}
+	ref, err := ctx.SetOverflowValue(s, key)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(map[string]interface{}{wfContext.OverflowKey: ref})
+	if err != nil {
+		return nil, err
+	}
+	return v.MakeValue(string(b))
+}
+
+// truncateOutput truncates the output to at most size bytes at a valid boundary. The content of the string is
+// truncated instead of its quoted form, and the other values keep only the lines of the complete fields.
+func truncateOutput(v *value.Value, s string, size int) string {
+	if str, err := v.CueValue().String(); err == nil {
+		return cutRunes(str, size)
+	}
+	cut := s[:size]
+	if i := strings.LastIndex(cut, "\n"); i >= 0 {
+		return cut[:i]
+	}
+	return ""
+}
+
+// cutRunes cuts the string to at most size bytes at the rune boundary
+func cutRunes(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	for size > 0 && !utf8.RuneStart(s[size]) {
+		size--
+	}
+	return s[:size]
+}
+
+func qualifiedOutput(ctx wfContext.Context, step, output string) string {
+	v, ok := ctx.GetValueInMemory(wfTypes.ContextKeyQualifiedOutputs)
+	if !ok {
+		return ""
+	}
+	qualified, ok := v.(map[string]map[string]string)
+	if !ok {
+		return ""
+	}
+	return qualified[step][output]
+}
+
 // SetAdditionalNameInStatus sets additional name from properties to status map
 func SetAdditionalNameInStatus(stepStatus map[string]v1alpha1.StepStatus, name string, properties *runtime.RawExtension, status v1alpha1.StepStatus) {
 	if stepStatus == nil || properties == nil {
@@ -28,6 +28,7 @@ import (
 	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
+	wfTypes "github.com/kubevela/workflow/pkg/types"
 )
 
 func TestInput(t *testing.T) {
@@ -74,6 +75,175 @@ func TestInput(t *testing.T) {
 `)
 }
 
+func TestOptionalInput(t *testing.T) {
+	wfCtx := mockContext(t)
+	r := require.New(t)
+	wfCtx.SetValueInMemory(OutputProducers([]v1alpha1.WorkflowStep{{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name:    "build",
+			Outputs: v1alpha1.StepOutputs{{Name: "image", ValueFrom: "output.image"}},
+		},
+	}}), wfTypes.ContextKeyOutputProducers)
+	null, err := value.NewValue("null", nil, "")
+	r.NoError(err)
+	r.NoError(wfCtx.SetVar(null, "skipped"))
+
+	paramValue, err := wfCtx.MakeParameter(`{}`)
+	r.NoError(err)
+	err = Input(wfCtx, paramValue, v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Inputs: v1alpha1.StepInputs{{
+				From:         "image",
+				ParameterKey: "image",
+				Optional:     true,
+			}, {
+				From:         "skipped",
+				ParameterKey: "skipped",
+				Default:      &runtime.RawExtension{Raw: []byte(`{"replicas":1}`)},
+			}},
+		},
+	})
+	r.NoError(err)
+	_, err = paramValue.LookupValue("parameter", "image")
+	r.Error(err)
+	result, err := paramValue.LookupValue("parameter", "skipped", "replicas")
+	r.NoError(err)
+	replicas, err := result.CueValue().Int64()
+	r.NoError(err)
+	r.Equal(int64(1), replicas)
+
+	err = Input(wfCtx, paramValue, v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Inputs: v1alpha1.StepInputs{{
+				From:         "image",
+				ParameterKey: "image",
+			}},
+		},
+	})
+	r.Error(err)
+	r.Equal("input [image] is missing, the output is expected to be produced by step build", err.Error())
+}
+
+func TestSkippedOutputInput(t *testing.T) {
+	wfCtx := mockContext(t)
+	r := require.New(t)
+	wfCtx.SetValueInMemory(OutputProducers([]v1alpha1.WorkflowStep{{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name:    "probe",
+			Outputs: v1alpha1.StepOutputs{{Name: "endpoint", ValueFrom: "output.endpoint"}},
+		},
+	}}), wfTypes.ContextKeyOutputProducers)
+	null, err := value.NewValue("null", nil, "")
+	r.NoError(err)
+	r.NoError(wfCtx.SetVar(null, "endpoint"))
+	r.NoError(wfCtx.SetVar(null, "unknown"))
+
+	// the null output of the succeeded step is kept
+	paramValue, err := wfCtx.MakeParameter(`{}`)
+	r.NoError(err)
+	step := v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			DependsOn: []string{"probe"},
+			Inputs:    v1alpha1.StepInputs{{From: "endpoint", ParameterKey: "endpoint"}},
+		},
+	}
+	r.NoError(Input(wfCtx, paramValue, step))
+	result, err := paramValue.LookupValue("parameter", "endpoint")
+	r.NoError(err)
+	r.NoError(result.CueValue().Null())
+
+	// the null output of the skipped step is absent for the required input
+	SetSkippedSteps(wfCtx, v1alpha1.WorkflowRunStatus{Steps: []v1alpha1.WorkflowStepStatus{
+		{StepStatus: v1alpha1.StepStatus{Name: "probe", Phase: v1alpha1.WorkflowStepPhaseSkipped}},
+	}})
+	paramValue, err = wfCtx.MakeParameter(`{}`)
+	r.NoError(err)
+	r.NoError(Input(wfCtx, paramValue, step))
+	_, err = paramValue.LookupValue("parameter", "endpoint")
+	r.Error(err)
+
+	step.Inputs = v1alpha1.StepInputs{{From: "endpoint", ParameterKey: "endpoint", Patch: true}}
+	properties, err := PatchProperties(wfCtx, step)
+	r.NoError(err)
+	r.Equal(`{}`, string(properties.Raw))
+
+	// the null value not produced by a step is kept
+	step.Inputs = v1alpha1.StepInputs{{From: "unknown", ParameterKey: "unknown"}}
+	r.NoError(Input(wfCtx, paramValue, step))
+	result, err = paramValue.LookupValue("parameter", "unknown")
+	r.NoError(err)
+	r.NoError(result.CueValue().Null())
+}
+
+func TestPatchProperties(t *testing.T) {
+	wfCtx := mockContext(t)
+	for name, patch := range map[string]string{
+		"merge":   `{replicas: 3, labels: {app: null, tier: "web"}}`,
+		"json":    `[{op: "replace", path: "/replicas", value: 3}, {op: "add", path: "/image", value: "nginx"}]`,
+		"invalid": `[{op: "replace", path: "/replicas", value: 3}, {op: "remove", path: "/not-found"}]`,
+		"scalar":  `"value"`,
+	} {
+		v, err := value.NewValue(patch, nil, "")
+		require.NoError(t, err)
+		require.NoError(t, wfCtx.SetVar(v, name))
+	}
+	step := func(inputs ...v1alpha1.InputItem) v1alpha1.WorkflowStep {
+		return v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Properties: &runtime.RawExtension{Raw: []byte(`{"replicas":1,"labels":{"app":"a"}}`)},
+			Inputs:     inputs,
+		}}
+	}
+	testCases := map[string]struct {
+		step        v1alpha1.WorkflowStep
+		expected    string
+		expectedErr string
+	}{
+		"merge patch": {
+			step:     step(v1alpha1.InputItem{From: "merge", Patch: true}),
+			expected: `{"labels":{"tier":"web"},"replicas":3}`,
+		},
+		"json patch": {
+			step:     step(v1alpha1.InputItem{From: "json", Patch: true}),
+			expected: `{"image":"nginx","labels":{"app":"a"},"replicas":3}`,
+		},
+		"patches in order": {
+			step:     step(v1alpha1.InputItem{From: "merge", Patch: true}, v1alpha1.InputItem{From: "json", Patch: true}),
+			expected: `{"image":"nginx","labels":{"tier":"web"},"replicas":3}`,
+		},
+		"the inputs not patched are ignored": {
+			step:     step(v1alpha1.InputItem{From: "merge", ParameterKey: "merge"}, v1alpha1.InputItem{From: "missing", Patch: true, Optional: true}),
+			expected: `{"labels":{"app":"a"},"replicas":1}`,
+		},
+		"path not found": {
+			step:        step(v1alpha1.InputItem{From: "invalid", Patch: true}),
+			expectedErr: "patch the properties with input [invalid]: apply the op 1 of the json patch",
+		},
+		"scalar patch": {
+			step:        step(v1alpha1.InputItem{From: "scalar", Patch: true}),
+			expectedErr: "patch the properties with input [scalar]: the patch must be an object or a list",
+		},
+		"missing input": {
+			step:        step(v1alpha1.InputItem{From: "missing", Patch: true}),
+			expectedErr: "get input from [missing]",
+		},
+	}
+	require.True(t, HasPatchInputs(step(v1alpha1.InputItem{From: "merge", Patch: true})))
+	require.False(t, HasPatchInputs(step(v1alpha1.InputItem{From: "merge", ParameterKey: "merge"})))
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			properties, err := PatchProperties(wfCtx, tc.step)
+			if tc.expectedErr != "" {
+				r.Error(err)
+				r.Contains(err.Error(), tc.expectedErr)
+				return
+			}
+			r.NoError(err)
+			r.JSONEq(tc.expected, string(properties.Raw))
+		})
+	}
+}
+
 func TestOutput(t *testing.T) {
 	wfCtx := mockContext(t)
 	r := require.New(t)
@@ -105,6 +275,84 @@ output: score: 99
 	r.Equal(stepStatus["mystep"].Phase, v1alpha1.WorkflowStepPhaseSucceeded)
 }
 
+func TestOutputSizeLimit(t *testing.T) {
+	defer func(size int, strategy string) {
+		MaxOutputSize, OutputOverflowStrategy = size, strategy
+	}(MaxOutputSize, OutputOverflowStrategy)
+	MaxOutputSize = 20
+	step := v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name: "render",
+			Outputs: v1alpha1.StepOutputs{
+				{ValueFrom: "output.manifest", Name: "manifest"},
+				{ValueFrom: "output.name", Name: "name"},
+				{ValueFrom: "output.title", Name: "title"},
+			},
+		},
+	}
+	output := func(r *require.Assertions, wfCtx wfContext.Context) {
+		taskValue, err := value.NewValue(`output: {manifest: {replicas: 3, kind: "Deployment"}, name: "app", title: "应用应用应用应用应用"}`, nil, "")
+		r.NoError(err)
+		r.NoError(Output(wfCtx, taskValue, step, v1alpha1.StepStatus{Phase: v1alpha1.WorkflowStepPhaseSucceeded}, nil))
+	}
+
+	t.Run("configmap", func(t *testing.T) {
+		r := require.New(t)
+		OutputOverflowStrategy = OutputOverflowConfigMap
+		wfCtx := mockContext(t)
+		output(r, wfCtx)
+		v, err := wfCtx.GetVar("manifest", wfContext.OverflowKey)
+		r.NoError(err)
+		ref := &wfContext.OverflowRef{}
+		r.NoError(v.UnmarshalTo(ref))
+		r.Equal("workflow-v1-context-outputs", ref.ConfigMapRef)
+		r.Equal("manifest", ref.Key)
+		r.Greater(ref.Size, MaxOutputSize)
+		name, err := wfCtx.GetVar("name")
+		r.NoError(err)
+		s, err := name.CueValue().String()
+		r.NoError(err)
+		r.Equal("app", s)
+
+		// the input is dereferenced from the overflow ConfigMap
+		paramValue, err := wfCtx.MakeParameter(`{}`)
+		r.NoError(err)
+		r.NoError(Input(wfCtx, paramValue, v1alpha1.WorkflowStep{
+			WorkflowStepBase: v1alpha1.WorkflowStepBase{
+				Inputs: v1alpha1.StepInputs{
+					{From: "manifest", ParameterKey: "manifest"},
+					{From: "manifest.replicas", ParameterKey: "replicas"},
+				},
+			},
+		}))
+		kind, err := paramValue.GetString("parameter", "manifest", "kind")
+		r.NoError(err)
+		r.Equal("Deployment", kind)
+		replicas, err := paramValue.GetInt64("parameter", "replicas")
+		r.NoError(err)
+		r.Equal(int64(3), replicas)
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		r := require.New(t)
+		OutputOverflowStrategy = OutputOverflowTruncate
+		wfCtx := mockContext(t)
+		output(r, wfCtx)
+		v, err := wfCtx.GetVar("manifest")
+		r.NoError(err)
+		s, err := v.CueValue().String()
+		r.NoError(err)
+		r.Equal("replicas: 3", s)
+
+		// the content of the string is truncated at the rune boundary
+		v, err = wfCtx.GetVar("title")
+		r.NoError(err)
+		s, err = v.CueValue().String()
+		r.NoError(err)
+		r.Equal("应用应用应用", s)
+	})
+}
+
 func mockContext(t *testing.T) wfContext.Context {
 	cli := &test.MockClient{
 		MockCreate: func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
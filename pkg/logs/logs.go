@@ -0,0 +1,210 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kubevela/pkg/multicluster"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wfTypes "github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// LogsKey is the key of the collected logs in the logs config map
+	LogsKey = "logs"
+	// truncatedMarker is appended to the truncated logs
+	truncatedMarker = "\n...(truncated)"
+	// noPodsMessage is stored as the logs if no pod is found, e.g. the pods are garbage collected
+	noPodsMessage = "no pods found, the pods may be garbage collected"
+)
+
+// MaxSize is the max size in bytes of the logs collected for a step, the logs beyond it are truncated
+var MaxSize = 256 * 1024
+
+// Collect collects the logs of the pods into the logs config map of the step and returns the name of the config map.
+// The logs of the pods are concatenated with the headers of the pods and the containers, the pods or the resource
+// not found are recorded in the logs instead of failing the collection.
+func Collect(ctx context.Context, cli client.Client, kubeClient kubernetes.Interface, instance *wfTypes.WorkflowInstance, step string, config wfTypes.CollectLogs) (string, error) {
+	logs, err := collect(ctx, cli, kubeClient, instance.Namespace, config)
+	if err != nil {
+		return "", err
+	}
+	if len(logs) > MaxSize {
+		size := MaxSize
+		for size > 0 && !utf8.RuneStart(logs[size]) {
+			size--
+		}
+		logs = logs[:size] + truncatedMarker
+	}
+	name := GenerateLogsName(instance.Name, step)
+	cm := &corev1.ConfigMap{}
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: instance.Namespace, Name: name}, cm); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return "", errors.WithMessagef(err, "get logs configMap %s", name)
+		}
+		cm.Name = name
+		cm.Namespace = instance.Namespace
+		cm.Data = map[string]string{LogsKey: logs}
+		cm.SetOwnerReferences(instance.ChildOwnerReferences)
+		return name, errors.WithMessagef(cli.Create(ctx, cm), "create logs configMap %s", name)
+	}
+	cm.Data = map[string]string{LogsKey: logs}
+	return name, errors.WithMessagef(cli.Update(ctx, cm), "update logs configMap %s", name)
+}
+
+func collect(ctx context.Context, cli client.Client, kubeClient kubernetes.Interface, namespace string, config wfTypes.CollectLogs) (string, error) {
+	cluster, namespace, opts, err := listOptions(ctx, cli, namespace, config)
+	if kerrors.IsNotFound(err) {
+		return fmt.Sprintf("%s: %s", noPodsMessage, err.Error()), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	ctx = multicluster.WithCluster(ctx, cluster)
+	pods, err := kubeClient.CoreV1().Pods(namespace).List(ctx, opts)
+	if err != nil {
+		return "", errors.WithMessage(err, "list pods")
+	}
+	if len(pods.Items) == 0 {
+		return noPodsMessage, nil
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+	sb := &strings.Builder{}
+	for _, pod := range pods.Items {
+		containers := []string{config.Container}
+		if config.Container == "" {
+			containers = nil
+			for _, c := range pod.Spec.Containers {
+				containers = append(containers, c.Name)
+			}
+		}
+		for _, container := range containers {
+			fmt.Fprintf(sb, "==> %s/%s <==\n", pod.Name, container)
+			if err := readLogs(ctx, kubeClient, sb, pod.Namespace, pod.Name, container, config.TailLines); err != nil {
+				fmt.Fprintf(sb, "logs unavailable: %s\n", err.Error())
+			}
+			if sb.Len() > MaxSize {
+				return sb.String(), nil
+			}
+		}
+	}
+	return sb.String(), nil
+}
+
+func readLogs(ctx context.Context, kubeClient kubernetes.Interface, sb *strings.Builder, namespace, pod, container string, tailLines *int64) error {
+	stream, err := kubeClient.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container: container,
+		TailLines: tailLines,
+	}).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	//nolint:errcheck
+	defer stream.Close()
+	if _, err := io.Copy(sb, io.LimitReader(stream, int64(MaxSize-sb.Len()+1))); err != nil {
+		return err
+	}
+	if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+		sb.WriteString("\n")
+	}
+	return nil
+}
+
+// listOptions returns the cluster, the namespace and the options to list the pods selected by the labels, by the
+// selector of the resource or by the name of the pod, the namespace of the run is used if it's not specified.
+func listOptions(ctx context.Context, cli client.Client, namespace string, config wfTypes.CollectLogs) (string, string, metav1.ListOptions, error) {
+	if selector := config.PodSelector; selector != nil {
+		if selector.Namespace != "" {
+			namespace = selector.Namespace
+		}
+		return selector.Cluster, namespace, metav1.ListOptions{
+			LabelSelector: metav1.FormatLabelSelector(&metav1.LabelSelector{MatchLabels: selector.MatchLabels}),
+		}, nil
+	}
+	if config.Resource == nil {
+		return "", "", metav1.ListOptions{}, errors.New("either podSelector or resource is required to collect logs")
+	}
+	ref := *config.Resource
+	if ref.Namespace != "" {
+		namespace = ref.Namespace
+	}
+	if ref.APIVersion == "v1" && ref.Kind == "Pod" {
+		return ref.Cluster, namespace, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", ref.Name).String(),
+		}, nil
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(ref.APIVersion)
+	obj.SetKind(ref.Kind)
+	if err := cli.Get(multicluster.WithCluster(ctx, ref.Cluster), client.ObjectKey{Namespace: namespace, Name: ref.Name}, obj); err != nil {
+		return "", "", metav1.ListOptions{}, err
+	}
+	s, found, err := unstructured.NestedMap(obj.Object, "spec", "selector")
+	if err != nil || !found {
+		return "", "", metav1.ListOptions{}, errors.Errorf("%s %s has no pod selector", ref.Kind, ref.Name)
+	}
+	selector := &metav1.LabelSelector{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(s, selector); err != nil {
+		return "", "", metav1.ListOptions{}, errors.WithMessagef(err, "convert the pod selector of %s %s", ref.Kind, ref.Name)
+	}
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return "", "", metav1.ListOptions{}, err
+	}
+	return ref.Cluster, namespace, metav1.ListOptions{LabelSelector: labelSelector.String()}, nil
+}
+
+// GenerateLogsName generates the name of the logs config map of the step. The step names may contain the characters
+// not allowed in the object names, they are lowercased and replaced with `-`, the name is cut if it's too long. The
+// hash of the original name is appended if it's changed, so that the different steps never share the config map.
+func GenerateLogsName(name, step string) string {
+	const suffix = "-logs"
+	base := fmt.Sprintf("%s-%s", name, step)
+	sanitized := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(base))
+	if sanitized == base && len(base)+len(suffix) <= validation.DNS1123SubdomainMaxLength {
+		return base + suffix
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(base)))[:8]
+	if limit := validation.DNS1123SubdomainMaxLength - len(suffix) - len(hash) - 1; len(sanitized) > limit {
+		sanitized = sanitized[:limit]
+	}
+	return strings.TrimRight(sanitized, "-.") + "-" + hash + suffix
+}
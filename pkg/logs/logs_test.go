@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestCollect(t *testing.T) {
+	pod := func(name string, labels map[string]string, containers ...string) *corev1.Pod {
+		p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels}}
+		for _, c := range containers {
+			p.Spec.Containers = append(p.Spec.Containers, corev1.Container{Name: c})
+		}
+		return p
+	}
+	// the fake client set returns "fake logs" as the logs of any container
+	kubeClient := kubefake.NewSimpleClientset(
+		pod("job-b", map[string]string{"job-name": "job"}, "main"),
+		pod("job-a", map[string]string{"job-name": "job"}, "main", "sidecar"),
+		pod("other", map[string]string{"app": "other"}, "main"),
+		pod("unicode", map[string]string{"app": "unicode"}, "容器"),
+	)
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(&batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "default"},
+		Spec: batchv1.JobSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": "job"}},
+		},
+	}).Build()
+	instance := &types.WorkflowInstance{
+		WorkflowMeta: types.WorkflowMeta{Name: "wr", Namespace: "default"},
+	}
+
+	testCases := map[string]struct {
+		config   types.CollectLogs
+		maxSize  int
+		expected string
+	}{
+		"pod selector": {
+			config:   types.CollectLogs{PodSelector: &types.PodSelector{MatchLabels: map[string]string{"app": "other"}}},
+			expected: "==> other/main <==\nfake logs\n",
+		},
+		"resource with the pod selector": {
+			config: types.CollectLogs{Resource: &types.LogResourceRef{APIVersion: "batch/v1", Kind: "Job", Name: "job"}},
+			expected: "==> job-a/main <==\nfake logs\n" +
+				"==> job-a/sidecar <==\nfake logs\n" +
+				"==> job-b/main <==\nfake logs\n",
+		},
+		"container": {
+			config: types.CollectLogs{Resource: &types.LogResourceRef{APIVersion: "batch/v1", Kind: "Job", Name: "job"}, Container: "main"},
+			expected: "==> job-a/main <==\nfake logs\n" +
+				"==> job-b/main <==\nfake logs\n",
+		},
+		"pods garbage collected": {
+			config:   types.CollectLogs{PodSelector: &types.PodSelector{MatchLabels: map[string]string{"app": "deleted"}}},
+			expected: "no pods found, the pods may be garbage collected",
+		},
+		"resource not found": {
+			config:   types.CollectLogs{Resource: &types.LogResourceRef{APIVersion: "batch/v1", Kind: "Job", Name: "deleted"}},
+			expected: `no pods found, the pods may be garbage collected: jobs.batch "deleted" not found`,
+		},
+		"truncated": {
+			config:   types.CollectLogs{Resource: &types.LogResourceRef{APIVersion: "batch/v1", Kind: "Job", Name: "job"}},
+			maxSize:  30,
+			expected: "==> job-a/main <==\nfake logs\n=\n...(truncated)",
+		},
+		"truncated at the rune boundary": {
+			config:   types.CollectLogs{PodSelector: &types.PodSelector{MatchLabels: map[string]string{"app": "unicode"}}},
+			maxSize:  16,
+			expected: "==> unicode/容\n...(truncated)",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			if tc.maxSize > 0 {
+				defer func(size int) { MaxSize = size }(MaxSize)
+				MaxSize = tc.maxSize
+			}
+			ctx := context.Background()
+			name, err := Collect(ctx, cli, kubeClient, instance, "step", tc.config)
+			r.NoError(err)
+			r.Equal("wr-step-logs", name)
+			cm := &corev1.ConfigMap{}
+			r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, cm))
+			r.Equal(tc.expected, cm.Data[LogsKey])
+		})
+	}
+}
+
+func TestGenerateLogsName(t *testing.T) {
+	testCases := map[string]struct {
+		step     string
+		expected string
+	}{
+		"valid": {
+			step:     "build",
+			expected: "wr-build-logs",
+		},
+		"invalid characters": {
+			step:     "Build_Image",
+			expected: "wr-build-image-ee295b68-logs",
+		},
+		"too long": {
+			step:     strings.Repeat("a", 300),
+			expected: "wr-" + strings.Repeat("a", 253-len("wr-")-len("-8d00bbdd-logs")) + "-8d00bbdd-logs",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			logsName := GenerateLogsName("wr", tc.step)
+			r.Empty(validation.IsDNS1123Subdomain(logsName))
+			r.Equal(tc.expected, logsName)
+		})
+	}
+}
@@ -55,6 +55,12 @@ var (
 		ConstLabels: prometheus.Labels{},
 	}, []string{"phase"})
 
+	// WorkflowRunTerminatedCounter report the number of the workflow runs ending without success by the reason
+	WorkflowRunTerminatedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "workflowrun_terminated_num",
+		Help: "workflow run terminated times by the reason",
+	}, []string{"phase", "reason"})
+
 	// WorkflowRunInitializedCounter report the workflow run initialize execute number.
 	WorkflowRunInitializedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "workflowrun_initialized_num",
@@ -81,6 +87,7 @@ var collectorGroup = []prometheus.Collector{
 	WorkflowRunStepDurationHistogram,
 	WorkflowRunReconcileTimeHistogram,
 	WorkflowRunFinishedTimeHistogram,
+	WorkflowRunTerminatedCounter,
 	WorkflowRunInitializedCounter,
 	WorkflowRunPhaseCounter,
 	WorkflowRunStepPhaseGauge,
@@ -28,8 +28,12 @@ import (
 
 	"github.com/kubevela/workflow/api/v1alpha1"
 	"github.com/kubevela/workflow/pkg/monitor/metrics"
+	"github.com/kubevela/workflow/pkg/types"
 )
 
+// stepPhaseIgnored is the phase label of the failed steps whose failures are ignored by the onFailure policy
+const stepPhaseIgnored = "ignored"
+
 type workflowRunMetricsWatcher struct {
 	mu               sync.Mutex
 	phaseCounter     map[string]int
@@ -62,6 +66,10 @@ func (watcher *workflowRunMetricsWatcher) inc(wr *v1alpha1.WorkflowRun, delta in
 	watcher.phaseDirty[phase] = struct{}{}
 	for _, step := range wr.Status.Steps {
 		stepPhase := watcher.getPhase(string(step.Phase))
+		// the ignored failures are reported apart from the failures failing the run
+		if types.IsStepFailureIgnored(step.StepStatus) {
+			stepPhase = stepPhaseIgnored
+		}
 		key := fmt.Sprintf("%s/%s#%s", step.Type, stepPhase, step.Reason)
 		watcher.stepPhaseCounter[key] += delta
 		watcher.stepPhaseDirty[key] = struct{}{}
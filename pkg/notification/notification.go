@@ -0,0 +1,320 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/condition"
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/correlation"
+	"github.com/kubevela/workflow/pkg/export"
+)
+
+// SignatureHeader is the header of the HMAC-SHA256 signature of the payload, in the form of sha256=<hex>
+const SignatureHeader = "X-Workflow-Signature"
+
+var (
+	// HTTPClient delivers the notifications, each attempt is bounded by its timeout
+	HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	// MaxAttempts is the max attempts to deliver a notification
+	MaxAttempts = 3
+	// RetryInterval is the interval before the first retry, it's doubled for each retry
+	RetryInterval = time.Second
+)
+
+// Payload is the summary of the finished workflow run POSTed to the targets
+type Payload struct {
+	Name      string                    `json:"name"`
+	Namespace string                    `json:"namespace"`
+	UID       string                    `json:"uid"`
+	Phase     v1alpha1.WorkflowRunPhase `json:"phase"`
+	Message   string                    `json:"message,omitempty"`
+	// Correlation is the correlation ids captured from the annotations of the workflow run
+	Correlation map[string]string `json:"correlation,omitempty"`
+	StartTime   metav1.Time       `json:"startTime"`
+	EndTime     metav1.Time       `json:"endTime"`
+	Steps       []StepSummary     `json:"steps"`
+	// Outputs are the outputs of the steps keyed by their names, the values which are not strings are encoded in JSON
+	Outputs map[string]string `json:"outputs,omitempty"`
+}
+
+// StepSummary is the summary of a step in the payload
+type StepSummary struct {
+	Name     string                     `json:"name"`
+	Type     string                     `json:"type"`
+	Phase    v1alpha1.WorkflowStepPhase `json:"phase"`
+	Reason   string                     `json:"reason,omitempty"`
+	Message  string                     `json:"message,omitempty"`
+	SubSteps []StepSummary              `json:"subSteps,omitempty"`
+}
+
+// Outputs collects the outputs of the steps from the workflow context, the outputs not produced, e.g. by the
+// skipped steps, are left out.
+func Outputs(wfCtx wfContext.Context, steps []v1alpha1.WorkflowStep) map[string]string {
+	outputs := map[string]string{}
+	collect := func(step v1alpha1.WorkflowStepBase) {
+		for _, output := range step.Outputs {
+			if data, err := export.Collect(wfCtx, []string{output.Name}); err == nil {
+				outputs[output.Name] = data[output.Name]
+			}
+		}
+	}
+	for _, step := range steps {
+		collect(step.WorkflowStepBase)
+		for _, sub := range step.SubSteps {
+			collect(sub)
+		}
+	}
+	return outputs
+}
+
+// Send POSTs the payload of the finished workflow run to the notification targets matching its phase and returns the
+// condition recording the deliveries, it returns false if no target matches. The outputs are only included for the
+// targets with includeOutputs.
+func Send(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun, outputs map[string]string) (condition.Condition, bool) {
+	payload := newPayload(run)
+	var delivered int
+	var failures []string
+	for i, target := range run.Spec.Notifications {
+		if !matches(target, run.Status.Phase) {
+			continue
+		}
+		payload.Outputs = nil
+		if target.IncludeOutputs {
+			payload.Outputs = outputs
+		}
+		if err := deliver(ctx, cli, run.Namespace, target, payload); err != nil {
+			failures = append(failures, fmt.Sprintf("notification %d: %s", i, err.Error()))
+			continue
+		}
+		delivered++
+	}
+	switch {
+	case len(failures) > 0:
+		return condition.ErrorCondition(v1alpha1.WorkflowRunNotificationConditionType, errors.Errorf("delivered %d/%d notifications, %s",
+			delivered, delivered+len(failures), strings.Join(failures, "; "))), true
+	case delivered > 0:
+		return condition.ReadyCondition(v1alpha1.WorkflowRunNotificationConditionType).WithMessage(fmt.Sprintf("delivered %d notifications", delivered)), true
+	default:
+		return condition.Condition{}, false
+	}
+}
+
+// ReasonPending is the reason of the Notification condition of the finished run whose notifications are not delivered
+const ReasonPending condition.ConditionReason = "Pending"
+
+// PendingCondition returns the Notification condition of the finished run before the deliveries, the notifications
+// are delivered after the finished status is persisted so that they're not sent again if the status fails to persist.
+// It returns false if no target matches.
+func PendingCondition(run *v1alpha1.WorkflowRun) (condition.Condition, bool) {
+	targets := Targets(run)
+	if len(targets) == 0 {
+		return condition.Condition{}, false
+	}
+	return condition.Condition{
+		Type:               condition.ConditionType(v1alpha1.WorkflowRunNotificationConditionType),
+		Status:             corev1.ConditionUnknown,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonPending,
+		Message:            fmt.Sprintf("delivering %d notifications", len(targets)),
+	}, true
+}
+
+// Pending returns whether the notifications of the run are waiting to be delivered
+func Pending(run *v1alpha1.WorkflowRun) bool {
+	cond := run.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunNotificationConditionType))
+	return cond.Status == corev1.ConditionUnknown && cond.Reason == ReasonPending
+}
+
+// Targets returns the notification targets of the finished workflow run matching its phase
+func Targets(run *v1alpha1.WorkflowRun) []v1alpha1.RunNotification {
+	var targets []v1alpha1.RunNotification
+	for _, target := range run.Spec.Notifications {
+		if matches(target, run.Status.Phase) {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+func matches(target v1alpha1.RunNotification, phase v1alpha1.WorkflowRunPhase) bool {
+	if len(target.Events) == 0 {
+		return true
+	}
+	for _, event := range target.Events {
+		if strings.EqualFold(string(event), string(phase)) {
+			return true
+		}
+	}
+	return false
+}
+
+func newPayload(run *v1alpha1.WorkflowRun) *Payload {
+	payload := &Payload{
+		Name:        run.Name,
+		Namespace:   run.Namespace,
+		UID:         string(run.UID),
+		Phase:       run.Status.Phase,
+		Message:     run.Status.Message,
+		Correlation: correlation.FromAnnotations(run.Annotations),
+		StartTime:   run.Status.StartTime,
+		EndTime:     run.Status.EndTime,
+		Steps:       []StepSummary{},
+	}
+	summary := func(status v1alpha1.StepStatus) StepSummary {
+		return StepSummary{Name: status.Name, Type: status.Type, Phase: status.Phase, Reason: status.Reason, Message: status.Message}
+	}
+	for _, step := range run.Status.Steps {
+		s := summary(step.StepStatus)
+		for _, sub := range step.SubStepsStatus {
+			s.SubSteps = append(s.SubSteps, summary(sub))
+		}
+		payload.Steps = append(payload.Steps, s)
+	}
+	return payload
+}
+
+// deliver POSTs the payload to the target, the errors of the connections, the throttled requests and the server
+// errors are retried with the backoff. The errors only name the host of the endpoint as the rest may carry the credentials.
+func deliver(ctx context.Context, cli client.Client, namespace string, target v1alpha1.RunNotification, payload *Payload) error {
+	endpoint := target.URL
+	if target.SecretRef != nil {
+		value, err := secretValue(ctx, cli, namespace, target.SecretRef)
+		if err != nil {
+			return err
+		}
+		endpoint = value
+	}
+	if endpoint == "" {
+		return errors.New("either url or secretRef is required")
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return errors.New("invalid url")
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var signature string
+	if target.SigningSecretRef != nil {
+		key, err := secretValue(ctx, cli, namespace, target.SigningSecretRef)
+		if err != nil {
+			return err
+		}
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write(body)
+		signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	headers := target.Headers
+	if len(payload.Correlation) > 0 {
+		headers = make(map[string]string, len(target.Headers)+1)
+		for k, v := range target.Headers {
+			headers[k] = v
+		}
+		baggage := correlation.Baggage(payload.Correlation)
+		if existing := headers[correlation.BaggageHeader]; existing != "" {
+			baggage = existing + "," + baggage
+		}
+		headers[correlation.BaggageHeader] = baggage
+	}
+	interval := RetryInterval
+	for attempt := 1; ; attempt++ {
+		retry, err := post(ctx, endpoint, headers, signature, body)
+		if err == nil {
+			return nil
+		}
+		if !retry {
+			return errors.WithMessagef(err, "post to %s", u.Host)
+		}
+		if attempt >= MaxAttempts {
+			return errors.WithMessagef(err, "post to %s after %d attempts", u.Host, attempt)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.WithMessagef(ctx.Err(), "post to %s after %d attempts", u.Host, attempt)
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+}
+
+// post sends the request once and returns whether the failure is worth retrying
+func post(ctx context.Context, endpoint string, headers map[string]string, signature string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.New("invalid request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if signature != "" {
+		req.Header.Set(SignatureHeader, signature)
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		// the error of the client names the whole url, only keep the cause of it
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return true, err
+	}
+	//nolint:errcheck
+	defer resp.Body.Close()
+	//nolint:errcheck
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, errors.Errorf("unexpected status %s", resp.Status)
+}
+
+func secretValue(ctx context.Context, cli client.Client, namespace string, ref *corev1.SecretKeySelector) (string, error) {
+	secret := &corev1.Secret{}
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, secret); err != nil {
+		if kerrors.IsNotFound(err) {
+			return "", errors.Errorf("secret %s not found", ref.Name)
+		}
+		return "", errors.WithMessagef(err, "get secret %s", ref.Name)
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", errors.Errorf("key %s not found in secret %s", ref.Key, ref.Name)
+	}
+	return string(value), nil
+}
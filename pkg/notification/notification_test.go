@@ -0,0 +1,166 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/correlation"
+)
+
+func TestSend(t *testing.T) {
+	RetryInterval = time.Millisecond
+	var requests []*http.Request
+	var bodies [][]byte
+	failures := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		requests = append(requests, req)
+		bodies = append(bodies, body)
+		switch req.URL.Path {
+		case "/flaky":
+			if failures < 2 {
+				failures++
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/down":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "/invalid":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	cli := fake.NewClientBuilder().WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "notify", Namespace: "default"},
+		Data:       map[string][]byte{"url": []byte(server.URL + "/secret"), "key": []byte("signing-key")},
+	}).Build()
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default", UID: "run-uid"},
+		Status: v1alpha1.WorkflowRunStatus{
+			Phase: v1alpha1.WorkflowStateFailed,
+			Steps: []v1alpha1.WorkflowStepStatus{{
+				StepStatus: v1alpha1.StepStatus{Name: "deploy", Type: "apply", Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: "Action", Message: "invalid"},
+			}},
+		},
+	}
+	outputs := map[string]string{"image": "nginx"}
+
+	testCases := map[string]struct {
+		notifications []v1alpha1.RunNotification
+		requests      int
+		notified      bool
+		status        corev1.ConditionStatus
+		message       string
+	}{
+		"signed with the outputs": {
+			notifications: []v1alpha1.RunNotification{{
+				SecretRef:        &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "notify"}, Key: "url"},
+				Events:           []v1alpha1.WorkflowRunPhase{"Succeeded", "Failed"},
+				IncludeOutputs:   true,
+				Headers:          map[string]string{"X-Token": "token"},
+				SigningSecretRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "notify"}, Key: "key"},
+			}},
+			requests: 1,
+			notified: true,
+			status:   corev1.ConditionTrue,
+			message:  "delivered 1 notifications",
+		},
+		"events not matched": {
+			notifications: []v1alpha1.RunNotification{{URL: server.URL, Events: []v1alpha1.WorkflowRunPhase{"Succeeded"}}},
+		},
+		"retried": {
+			notifications: []v1alpha1.RunNotification{{URL: server.URL + "/flaky"}},
+			requests:      3,
+			notified:      true,
+			status:        corev1.ConditionTrue,
+			message:       "delivered 1 notifications",
+		},
+		"failures": {
+			notifications: []v1alpha1.RunNotification{
+				{URL: server.URL + "/down"},
+				{URL: server.URL + "/invalid"},
+				{SecretRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "notify"}, Key: "missing"}},
+				{URL: server.URL},
+			},
+			requests: 5,
+			notified: true,
+			status:   corev1.ConditionFalse,
+			message: "delivered 1/4 notifications, notification 0: post to " + server.Listener.Addr().String() + " after 3 attempts: unexpected status 500 Internal Server Error; " +
+				"notification 1: post to " + server.Listener.Addr().String() + ": unexpected status 400 Bad Request; " +
+				"notification 2: key missing not found in secret notify",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			requests, bodies, failures = nil, nil, 0
+			run.Spec.Notifications = tc.notifications
+			cond, ok := Send(context.Background(), cli, run, outputs)
+			r.Equal(tc.notified, ok)
+			r.Equal(tc.requests, len(requests))
+			if !ok {
+				return
+			}
+			r.Equal(v1alpha1.WorkflowRunNotificationConditionType, string(cond.Type))
+			r.Equal(tc.status, cond.Status)
+			r.Equal(tc.message, cond.Message)
+		})
+	}
+
+	// the payload of the signed notification
+	require.NoError(t, correlation.SetKeys([]string{"trace-id"}))
+	defer func() {
+		require.NoError(t, correlation.SetKeys(nil))
+	}()
+	run.Annotations = map[string]string{"trace-id": "abc"}
+	run.Spec.Notifications = testCases["signed with the outputs"].notifications
+	requests, bodies = nil, nil
+	_, ok := Send(context.Background(), cli, run, outputs)
+	require.True(t, ok)
+	require.Equal(t, "/secret", requests[0].URL.Path)
+	require.Equal(t, "token", requests[0].Header.Get("X-Token"))
+	require.Equal(t, "trace-id=abc", requests[0].Header.Get(correlation.BaggageHeader))
+	mac := hmac.New(sha256.New, []byte("signing-key"))
+	mac.Write(bodies[0])
+	require.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), requests[0].Header.Get(SignatureHeader))
+	payload := &Payload{}
+	require.NoError(t, json.Unmarshal(bodies[0], payload))
+	require.Equal(t, "run-uid", payload.UID)
+	require.Equal(t, map[string]string{"trace-id": "abc"}, payload.Correlation)
+	require.Equal(t, v1alpha1.WorkflowStateFailed, payload.Phase)
+	require.Equal(t, []StepSummary{{Name: "deploy", Type: "apply", Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: "Action", Message: "invalid"}}, payload.Steps)
+	require.Equal(t, outputs, payload.Outputs)
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/condition"
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/executor"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// InvalidOperationError is the error of the operation which is not allowed in the current phase of the workflow run
+type InvalidOperationError struct {
+	Operation string
+	Phase     v1alpha1.WorkflowRunPhase
+}
+
+// Error implements the Error interface.
+func (e InvalidOperationError) Error() string {
+	return fmt.Sprintf("cannot %s the workflow run in phase %s", e.Operation, e.Phase)
+}
+
+// IsInvalidOperationErr returns true if the specified error is InvalidOperationError type.
+func IsInvalidOperationErr(err error) bool {
+	return errors.As(err, &InvalidOperationError{})
+}
+
+// Resume resumes the suspended workflow run, the running suspend steps are marked as succeeded
+func Resume(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) error {
+	if !run.Status.Suspend || run.Status.Finished {
+		return InvalidOperationError{Operation: "resume", Phase: run.Status.Phase}
+	}
+	resume := func(ss *v1alpha1.StepStatus) {
+		if ss.Type == types.WorkflowStepTypeSuspend && ss.Phase == v1alpha1.WorkflowStepPhaseRunning {
+			ss.Phase = v1alpha1.WorkflowStepPhaseSucceeded
+		}
+	}
+	for i := range run.Status.Steps {
+		resume(&run.Status.Steps[i].StepStatus)
+		for j := range run.Status.Steps[i].SubStepsStatus {
+			resume(&run.Status.Steps[i].SubStepsStatus[j])
+		}
+	}
+	run.Status.Suspend = false
+	return errors.WithMessage(cli.Status().Update(ctx, run), "resume the workflow run")
+}
+
+// Terminate terminates the workflow run, the unfinished steps are marked as failed by the termination
+func Terminate(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) error {
+	if run.Status.Finished || run.Status.Terminated {
+		return InvalidOperationError{Operation: "terminate", Phase: run.Status.Phase}
+	}
+	terminate := func(ss *v1alpha1.StepStatus) {
+		if !types.IsStepFinish(ss.Phase, ss.Reason) {
+			ss.Phase = v1alpha1.WorkflowStepPhaseFailed
+			ss.Reason = types.StatusReasonTerminate
+		}
+	}
+	for i := range run.Status.Steps {
+		terminate(&run.Status.Steps[i].StepStatus)
+		for j := range run.Status.Steps[i].SubStepsStatus {
+			terminate(&run.Status.Steps[i].SubStepsStatus[j])
+		}
+	}
+	run.Status.Suspend = false
+	run.Status.Terminated = true
+	return errors.WithMessage(cli.Status().Update(ctx, run), "terminate the workflow run")
+}
+
+// TerminateGracefully terminates the workflow run after the running steps finish, the steps that are not started
+// are never started, and the running steps are interrupted if they are not finished in the grace period
+func TerminateGracefully(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun, gracePeriod time.Duration) error {
+	if run.Status.Finished || run.Status.Terminated || run.Status.GracefulTermination != nil {
+		return InvalidOperationError{Operation: "terminate", Phase: run.Status.Phase}
+	}
+	if gracePeriod <= 0 {
+		gracePeriod = types.DefaultTerminateGracePeriod
+	}
+	run.Status.GracefulTermination = &v1alpha1.GracefulTermination{
+		StartTime:   metav1.Now(),
+		GracePeriod: metav1.Duration{Duration: gracePeriod},
+	}
+	return errors.WithMessage(cli.Status().Update(ctx, run), "terminate the workflow run gracefully")
+}
+
+// RetryFromFailure restarts the failed or terminated workflow run from the failed steps, the status of the failed
+// and skipped steps are removed to execute them again, the whole step group is restarted if any of its sub steps
+// is failed or skipped. The succeeded steps are kept with their outputs.
+func RetryFromFailure(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) error {
+	if !run.Status.Finished || (run.Status.Phase != v1alpha1.WorkflowStateFailed && run.Status.Phase != v1alpha1.WorkflowStateTerminated) {
+		return InvalidOperationError{Operation: "retry", Phase: run.Status.Phase}
+	}
+	unsuccessful := func(ss v1alpha1.StepStatus) bool {
+		return ss.Phase == v1alpha1.WorkflowStepPhaseFailed || ss.Phase == v1alpha1.WorkflowStepPhaseSkipped
+	}
+	var kept []v1alpha1.WorkflowStepStatus
+	for _, ss := range run.Status.Steps {
+		retry := unsuccessful(ss.StepStatus)
+		for _, sub := range ss.SubStepsStatus {
+			retry = retry || unsuccessful(sub)
+		}
+		if !retry {
+			kept = append(kept, ss)
+		}
+	}
+	var conditions []condition.Condition
+	for _, c := range run.Status.Conditions {
+		if c.Type != condition.ConditionType(v1alpha1.WorkflowRunTerminatedConditionType) {
+			conditions = append(conditions, c)
+		}
+	}
+	run.Status.Conditions = conditions
+	run.Status.Steps = kept
+	run.Status.Phase = v1alpha1.WorkflowStateExecuting
+	run.Status.Message = ""
+	run.Status.Suspend = false
+	run.Status.Terminated = false
+	run.Status.GracefulTermination = nil
+	run.Status.Finished = false
+	run.Status.EndTime = metav1.Time{}
+	if err := cli.Status().Update(ctx, run); err != nil {
+		return errors.WithMessage(err, "retry the workflow run")
+	}
+	// the cache of the number of the step status skips the reconcile with less steps
+	executor.StepStatusCache.Delete(fmt.Sprintf("%s-%s", run.Name, run.Namespace))
+	return nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestOperations(t *testing.T) {
+	stepStatus := func(name, typ string, phase v1alpha1.WorkflowStepPhase, reason string, subs ...v1alpha1.StepStatus) v1alpha1.WorkflowStepStatus {
+		return v1alpha1.WorkflowStepStatus{
+			StepStatus:     v1alpha1.StepStatus{ID: name, Name: name, Type: typ, Phase: phase, Reason: reason},
+			SubStepsStatus: subs,
+		}
+	}
+
+	testCases := map[string]struct {
+		status      v1alpha1.WorkflowRunStatus
+		operate     func(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) error
+		expected    v1alpha1.WorkflowRunStatus
+		expectedErr string
+	}{
+		"resume": {
+			status: v1alpha1.WorkflowRunStatus{
+				Phase:   v1alpha1.WorkflowStateSuspending,
+				Suspend: true,
+				Steps: []v1alpha1.WorkflowStepStatus{
+					stepStatus("step1", "apply", v1alpha1.WorkflowStepPhaseSucceeded, ""),
+					stepStatus("group", "step-group", v1alpha1.WorkflowStepPhaseRunning, "",
+						v1alpha1.StepStatus{Name: "sub", Type: "suspend", Phase: v1alpha1.WorkflowStepPhaseRunning}),
+				},
+			},
+			operate: Resume,
+			expected: v1alpha1.WorkflowRunStatus{
+				Phase: v1alpha1.WorkflowStateSuspending,
+				Steps: []v1alpha1.WorkflowStepStatus{
+					stepStatus("step1", "apply", v1alpha1.WorkflowStepPhaseSucceeded, ""),
+					stepStatus("group", "step-group", v1alpha1.WorkflowStepPhaseRunning, "",
+						v1alpha1.StepStatus{Name: "sub", Type: "suspend", Phase: v1alpha1.WorkflowStepPhaseSucceeded}),
+				},
+			},
+		},
+		"resume the executing run": {
+			status:      v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateExecuting},
+			operate:     Resume,
+			expectedErr: "cannot resume the workflow run in phase executing",
+		},
+		"terminate": {
+			status: v1alpha1.WorkflowRunStatus{
+				Phase:   v1alpha1.WorkflowStateSuspending,
+				Suspend: true,
+				Steps: []v1alpha1.WorkflowStepStatus{
+					stepStatus("step1", "apply", v1alpha1.WorkflowStepPhaseSucceeded, ""),
+					stepStatus("step2", "suspend", v1alpha1.WorkflowStepPhaseRunning, ""),
+				},
+			},
+			operate: Terminate,
+			expected: v1alpha1.WorkflowRunStatus{
+				Phase:      v1alpha1.WorkflowStateSuspending,
+				Terminated: true,
+				Steps: []v1alpha1.WorkflowStepStatus{
+					stepStatus("step1", "apply", v1alpha1.WorkflowStepPhaseSucceeded, ""),
+					stepStatus("step2", "suspend", v1alpha1.WorkflowStepPhaseFailed, types.StatusReasonTerminate),
+				},
+			},
+		},
+		"terminate the finished run": {
+			status:      v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateSucceeded, Finished: true},
+			operate:     Terminate,
+			expectedErr: "cannot terminate the workflow run in phase succeeded",
+		},
+		"terminate the gracefully terminating run": {
+			status: v1alpha1.WorkflowRunStatus{
+				Phase:               v1alpha1.WorkflowStateExecuting,
+				GracefulTermination: &v1alpha1.GracefulTermination{GracePeriod: metav1.Duration{Duration: time.Minute}},
+			},
+			operate: func(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) error {
+				return TerminateGracefully(ctx, cli, run, time.Minute)
+			},
+			expectedErr: "cannot terminate the workflow run in phase executing",
+		},
+		"retry from failure": {
+			status: v1alpha1.WorkflowRunStatus{
+				Phase:               v1alpha1.WorkflowStateFailed,
+				Message:             "failed",
+				Terminated:          true,
+				GracefulTermination: &v1alpha1.GracefulTermination{GracePeriod: metav1.Duration{Duration: time.Minute}},
+				Finished:            true,
+				Steps: []v1alpha1.WorkflowStepStatus{
+					stepStatus("step1", "apply", v1alpha1.WorkflowStepPhaseSucceeded, ""),
+					stepStatus("group", "step-group", v1alpha1.WorkflowStepPhaseFailed, "",
+						v1alpha1.StepStatus{Name: "sub1", Phase: v1alpha1.WorkflowStepPhaseSucceeded},
+						v1alpha1.StepStatus{Name: "sub2", Phase: v1alpha1.WorkflowStepPhaseFailed, Reason: types.StatusReasonFailedAfterRetries}),
+					stepStatus("step3", "apply", v1alpha1.WorkflowStepPhaseSkipped, types.StatusReasonSkip),
+				},
+			},
+			operate: RetryFromFailure,
+			expected: v1alpha1.WorkflowRunStatus{
+				Phase: v1alpha1.WorkflowStateExecuting,
+				Steps: []v1alpha1.WorkflowStepStatus{
+					stepStatus("step1", "apply", v1alpha1.WorkflowStepPhaseSucceeded, ""),
+				},
+			},
+		},
+		"retry the succeeded run": {
+			status:      v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateSucceeded, Finished: true},
+			operate:     RetryFromFailure,
+			expectedErr: "cannot retry the workflow run in phase succeeded",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			ctx := context.Background()
+			scheme := runtime.NewScheme()
+			r.NoError(clientgoscheme.AddToScheme(scheme))
+			r.NoError(v1alpha1.AddToScheme(scheme))
+			run := &v1alpha1.WorkflowRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "wr", Namespace: "default"},
+				Status:     tc.status,
+			}
+			cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(run).Build()
+			r.NoError(cli.Get(ctx, client.ObjectKeyFromObject(run), run))
+			err := tc.operate(ctx, cli, run)
+			if tc.expectedErr != "" {
+				r.True(IsInvalidOperationErr(err))
+				r.EqualError(err, tc.expectedErr)
+				return
+			}
+			r.NoError(err)
+			updated := &v1alpha1.WorkflowRun{}
+			r.NoError(cli.Get(ctx, client.ObjectKeyFromObject(run), updated))
+			r.Equal(tc.expected, updated.Status)
+		})
+	}
+}
+
+func TestTerminateGracefully(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "wr", Namespace: "default"},
+		Status: v1alpha1.WorkflowRunStatus{
+			Phase: v1alpha1.WorkflowStateExecuting,
+			Steps: []v1alpha1.WorkflowStepStatus{{StepStatus: v1alpha1.StepStatus{Name: "step1", Phase: v1alpha1.WorkflowStepPhaseRunning}}},
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(run).Build()
+	r.NoError(cli.Get(ctx, client.ObjectKeyFromObject(run), run))
+	r.NoError(TerminateGracefully(ctx, cli, run, 0))
+
+	updated := &v1alpha1.WorkflowRun{}
+	r.NoError(cli.Get(ctx, client.ObjectKeyFromObject(run), updated))
+	r.False(updated.Status.Terminated)
+	r.NotNil(updated.Status.GracefulTermination)
+	r.False(updated.Status.GracefulTermination.StartTime.IsZero())
+	r.Equal(types.DefaultTerminateGracePeriod, updated.Status.GracefulTermination.GracePeriod.Duration)
+	// the running steps are left to finish
+	r.Equal(v1alpha1.WorkflowStepPhaseRunning, updated.Status.Steps[0].Phase)
+}
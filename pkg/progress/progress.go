@@ -0,0 +1,213 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package progress
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfTypes "github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// StepKey is the key of the current step name in the progress config map
+	StepKey = "step"
+	// PhaseKey is the key of the current step phase in the progress config map
+	PhaseKey = "phase"
+	// HeartbeatKey is the key of the heartbeat timestamp in the progress config map
+	HeartbeatKey = "heartbeat"
+)
+
+var (
+	// Interval is the min interval between two writes of the live progress of a workflow run
+	Interval = time.Second
+	// HeartbeatInterval is the interval to refresh the heartbeat of the live progress while the steps are executing
+	HeartbeatInterval = 10 * time.Second
+	// reports records the latest live progress of each workflow run and when it's written
+	reports sync.Map
+)
+
+// report is the latest live progress of a workflow run, the writes of the same run are serialized by it
+type report struct {
+	sync.Mutex
+	step    string
+	phase   v1alpha1.WorkflowStepPhase
+	written time.Time
+	// dirty means the latest progress is throttled and not written yet
+	dirty bool
+}
+
+func progressKey(namespace, name string) string {
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
+
+func loadReport(instance *wfTypes.WorkflowInstance) *report {
+	r, _ := reports.LoadOrStore(progressKey(instance.Namespace, instance.Name), &report{})
+	return r.(*report)
+}
+
+// Report writes the live progress of the workflow run into the progress config map.
+// The write is throttled by Interval, so it's safe to call it on every step status change. The progress of the
+// finished step is always written, so that the last change of the step is never dropped by the throttle.
+func Report(ctx context.Context, cli client.Client, instance *wfTypes.WorkflowInstance, step string, phase v1alpha1.WorkflowStepPhase) error {
+	r := loadReport(instance)
+	r.Lock()
+	defer r.Unlock()
+	r.step, r.phase = step, phase
+	finished := phase != v1alpha1.WorkflowStepPhaseRunning && phase != v1alpha1.WorkflowStepPhasePending
+	if !finished && time.Since(r.written) < Interval {
+		r.dirty = true
+		return nil
+	}
+	return r.write(ctx, cli, instance)
+}
+
+// Heartbeat refreshes the heartbeat of the live progress of the workflow run every HeartbeatInterval until it's
+// stopped, the throttled progress is written when it's stopped.
+func Heartbeat(ctx context.Context, cli client.Client, instance *wfTypes.WorkflowInstance) (stop func()) {
+	r := loadReport(instance)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.Lock()
+				if r.step != "" {
+					if err := r.write(ctx, cli, instance); err != nil {
+						klog.ErrorS(err, "Failed to refresh the heartbeat of the live progress", "workflowrun", progressKey(instance.Namespace, instance.Name))
+					}
+				}
+				r.Unlock()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		r.Lock()
+		defer r.Unlock()
+		if r.dirty {
+			if err := r.write(ctx, cli, instance); err != nil {
+				klog.ErrorS(err, "Failed to write the live progress", "workflowrun", progressKey(instance.Namespace, instance.Name))
+			}
+		}
+	}
+}
+
+// write writes the latest progress with the current heartbeat, it must be called with the lock held
+func (r *report) write(ctx context.Context, cli client.Client, instance *wfTypes.WorkflowInstance) error {
+	now := time.Now()
+	r.written, r.dirty = now, false
+	data := map[string]string{
+		StepKey:      r.step,
+		PhaseKey:     string(r.phase),
+		HeartbeatKey: now.Format(time.RFC3339Nano),
+	}
+	cm := &corev1.ConfigMap{}
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: instance.Namespace, Name: GenerateProgressName(instance.Name)}, cm); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		cm.Name = GenerateProgressName(instance.Name)
+		cm.Namespace = instance.Namespace
+		cm.Data = data
+		cm.SetOwnerReferences(instance.ChildOwnerReferences)
+		return cli.Create(ctx, cm)
+	}
+	cm.Data = data
+	return cli.Update(ctx, cm)
+}
+
+// Progress is the live progress of a workflow run read from the progress config map
+type Progress struct {
+	Step      string
+	Phase     v1alpha1.WorkflowStepPhase
+	Heartbeat time.Time
+}
+
+// Read reads the live progress of the workflow run, nil is returned if the progress is not reported
+func Read(ctx context.Context, cli client.Client, namespace, name string) (*Progress, error) {
+	cm := &corev1.ConfigMap{}
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: GenerateProgressName(name)}, cm); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if cm.Data[StepKey] == "" {
+		return nil, nil
+	}
+	heartbeat, err := time.Parse(time.RFC3339Nano, cm.Data[HeartbeatKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid heartbeat of the live progress: %w", err)
+	}
+	return &Progress{Step: cm.Data[StepKey], Phase: v1alpha1.WorkflowStepPhase(cm.Data[PhaseKey]), Heartbeat: heartbeat}, nil
+}
+
+// Overlays returns true if the live progress is newer than the status of the workflow run, i.e. the run is not
+// finished and the status has no entry of the current step executed at or after the heartbeat. The authoritative
+// status is preferred on conflict, the heartbeat is compared in seconds as the times in the status.
+func (p *Progress) Overlays(status v1alpha1.WorkflowRunStatus) bool {
+	if status.Finished {
+		return false
+	}
+	heartbeat := metav1.NewTime(p.Heartbeat.Truncate(time.Second))
+	for _, ss := range status.Steps {
+		if ss.Name == p.Step {
+			return ss.LastExecuteTime.Before(&heartbeat)
+		}
+		for _, sub := range ss.SubStepsStatus {
+			if sub.Name == p.Step {
+				return sub.LastExecuteTime.Before(&heartbeat)
+			}
+		}
+	}
+	return true
+}
+
+// Forget drops the live progress of the workflow run kept in memory
+func Forget(namespace, name string) {
+	reports.Delete(progressKey(namespace, name))
+}
+
+// Delete deletes the live progress of the workflow run
+func Delete(ctx context.Context, cli client.Client, namespace, name string) error {
+	Forget(namespace, name)
+	cm := &corev1.ConfigMap{}
+	cm.Name = GenerateProgressName(name)
+	cm.Namespace = namespace
+	return client.IgnoreNotFound(cli.Delete(ctx, cm))
+}
+
+// GenerateProgressName generates the name of the progress config map
+func GenerateProgressName(name string) string {
+	return fmt.Sprintf("%s-progress", name)
+}
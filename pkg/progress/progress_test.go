@@ -0,0 +1,176 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package progress
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestReport(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().Build()
+	instance := &types.WorkflowInstance{
+		WorkflowMeta: types.WorkflowMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+	}
+	defer func(interval time.Duration) { Interval = interval }(Interval)
+	Interval = time.Hour
+	get := func() map[string]string {
+		cm := &corev1.ConfigMap{}
+		r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: GenerateProgressName("test")}, cm))
+		return cm.Data
+	}
+
+	r.NoError(Report(ctx, cli, instance, "step1", v1alpha1.WorkflowStepPhaseRunning))
+	data := get()
+	r.Equal("step1", data[StepKey])
+	r.Equal(string(v1alpha1.WorkflowStepPhaseRunning), data[PhaseKey])
+	r.NotEmpty(data[HeartbeatKey])
+
+	// throttled
+	r.NoError(Report(ctx, cli, instance, "step2", v1alpha1.WorkflowStepPhaseRunning))
+	r.Equal("step1", get()[StepKey])
+
+	// the finished step is always written
+	r.NoError(Report(ctx, cli, instance, "step2", v1alpha1.WorkflowStepPhaseSucceeded))
+	data = get()
+	r.Equal("step2", data[StepKey])
+	r.Equal(string(v1alpha1.WorkflowStepPhaseSucceeded), data[PhaseKey])
+
+	r.NoError(Delete(ctx, cli, "default", "test"))
+	r.NoError(Delete(ctx, cli, "default", "test"))
+	r.Error(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: GenerateProgressName("test")}, &corev1.ConfigMap{}))
+	_, ok := reports.Load(progressKey("default", "test"))
+	r.False(ok)
+}
+
+func TestHeartbeat(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().Build()
+	instance := &types.WorkflowInstance{
+		WorkflowMeta: types.WorkflowMeta{
+			Name:      "heartbeat",
+			Namespace: "default",
+		},
+	}
+	defer Forget("default", "heartbeat")
+	defer func(interval, heartbeat time.Duration) { Interval, HeartbeatInterval = interval, heartbeat }(Interval, HeartbeatInterval)
+	Interval, HeartbeatInterval = time.Hour, 10*time.Millisecond
+	get := func() map[string]string {
+		cm := &corev1.ConfigMap{}
+		r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: GenerateProgressName("heartbeat")}, cm))
+		return cm.Data
+	}
+
+	stop := Heartbeat(ctx, cli, instance)
+	r.NoError(Report(ctx, cli, instance, "step1", v1alpha1.WorkflowStepPhaseRunning))
+	first := get()[HeartbeatKey]
+	// the heartbeat is refreshed periodically while the step is running
+	r.Eventually(func() bool { return get()[HeartbeatKey] != first }, time.Second, 10*time.Millisecond)
+
+	// the throttled progress is written when the heartbeat stops
+	HeartbeatInterval = time.Hour
+	stop()
+	stop = Heartbeat(ctx, cli, instance)
+	r.NoError(Report(ctx, cli, instance, "step2", v1alpha1.WorkflowStepPhaseRunning))
+	r.Equal("step1", get()[StepKey])
+	stop()
+	r.Equal("step2", get()[StepKey])
+}
+
+func TestRead(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().Build()
+	instance := &types.WorkflowInstance{
+		WorkflowMeta: types.WorkflowMeta{
+			Name:      "read",
+			Namespace: "default",
+		},
+	}
+	defer Forget("default", "read")
+
+	live, err := Read(ctx, cli, "default", "read")
+	r.NoError(err)
+	r.Nil(live)
+
+	r.NoError(Report(ctx, cli, instance, "step1", v1alpha1.WorkflowStepPhaseRunning))
+	live, err = Read(ctx, cli, "default", "read")
+	r.NoError(err)
+	r.Equal("step1", live.Step)
+	r.Equal(v1alpha1.WorkflowStepPhaseRunning, live.Phase)
+	r.WithinDuration(time.Now(), live.Heartbeat, time.Minute)
+
+	cm := &corev1.ConfigMap{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: GenerateProgressName("read")}, cm))
+	cm.Data[HeartbeatKey] = "invalid"
+	r.NoError(cli.Update(ctx, cm))
+	_, err = Read(ctx, cli, "default", "read")
+	r.Error(err)
+}
+
+func TestOverlays(t *testing.T) {
+	heartbeat := time.Date(2022, 1, 1, 0, 0, 10, 500, time.UTC)
+	executed := func(t time.Time) v1alpha1.StepStatus {
+		return v1alpha1.StepStatus{Name: "step1", Phase: v1alpha1.WorkflowStepPhaseSucceeded, LastExecuteTime: metav1.NewTime(t)}
+	}
+	testCases := map[string]struct {
+		status   v1alpha1.WorkflowRunStatus
+		expected bool
+	}{
+		"step not in status": {
+			expected: true,
+		},
+		"status older": {
+			status:   v1alpha1.WorkflowRunStatus{Steps: []v1alpha1.WorkflowStepStatus{{StepStatus: executed(heartbeat.Add(-time.Second))}}},
+			expected: true,
+		},
+		"status in the same second": {
+			status: v1alpha1.WorkflowRunStatus{Steps: []v1alpha1.WorkflowStepStatus{{StepStatus: executed(heartbeat.Truncate(time.Second))}}},
+		},
+		"sub step status newer": {
+			status: v1alpha1.WorkflowRunStatus{Steps: []v1alpha1.WorkflowStepStatus{{
+				StepStatus:     v1alpha1.StepStatus{Name: "group"},
+				SubStepsStatus: []v1alpha1.StepStatus{executed(heartbeat.Add(time.Second))},
+			}}},
+		},
+		"finished": {
+			status: v1alpha1.WorkflowRunStatus{Finished: true},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			live := &Progress{Step: "step1", Phase: v1alpha1.WorkflowStepPhaseRunning, Heartbeat: heartbeat}
+			require.Equal(t, tc.expected, live.Overlays(tc.status))
+		})
+	}
+}
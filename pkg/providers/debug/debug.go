@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package debug
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/cue/process"
+	wfDebug "github.com/kubevela/workflow/pkg/debug"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// ProviderName is provider name for install.
+	ProviderName = "debug"
+	// defaultName is the name of the value if it's not set
+	defaultName = "value"
+)
+
+type provider struct {
+	cli      client.Client
+	instance *types.WorkflowInstance
+	pCtx     process.Context
+}
+
+// Debug records the value into the debug config map of the step if the debug op is enabled for the run, it never
+// fails the step, the incomplete fields of the value are rendered as cue.
+func (h *provider) Debug(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	step := fmt.Sprint(h.pCtx.GetData(model.ContextStepName))
+	if !wfDebug.IsOpEnabled(h.instance, step) {
+		return nil
+	}
+	name := defaultName
+	if s, err := v.GetString("name"); err == nil && s != "" {
+		name = s
+	}
+	if err := wfDebug.SetValue(ctx, h.cli, h.instance, step, name, render(v)); err != nil {
+		ctx.Error(err, "record the debug value", "step", step, "name", name)
+	}
+	return nil
+}
+
+// render renders the value as cue, the errors of the value, e.g. the cycles, are rendered instead of the value
+func render(v *value.Value) (s string) {
+	defer func() {
+		if r := recover(); r != nil {
+			s = fmt.Sprintf("_|_ // failed to render the value: %v", r)
+		}
+	}()
+	val, err := v.LookupValue("value")
+	if err != nil {
+		return fmt.Sprintf("_|_ // %s", err.Error())
+	}
+	s, err = val.String()
+	if err != nil {
+		return fmt.Sprintf("_|_ // %s", err.Error())
+	}
+	return s
+}
+
+// Install register handlers to provider discover.
+func Install(p types.Providers, cli client.Client, instance *types.WorkflowInstance, pCtx process.Context) {
+	prd := &provider{cli: cli, instance: instance, pCtx: pCtx}
+	p.Register(ProviderName, map[string]types.Handler{
+		"debug": prd.Debug,
+	})
+}
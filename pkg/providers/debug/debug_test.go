@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/pkg/cue/model"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/cue/process"
+	wfDebug "github.com/kubevela/workflow/pkg/debug"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestDebug(t *testing.T) {
+	testCases := map[string]struct {
+		annotations map[string]string
+		debug       bool
+		params      []string
+		expected    map[string]string
+	}{
+		"production mode": {
+			params: []string{`value: {a: 1}`},
+		},
+		"debug op enabled": {
+			annotations: map[string]string{types.AnnotationWorkflowRunDebugOp: "true"},
+			params:      []string{`value: {a: 1}`},
+			expected:    map[string]string{"value": "a: 1\n"},
+		},
+		"run debugged": {
+			debug:    true,
+			params:   []string{`value: "test"`},
+			expected: map[string]string{"value": "\"test\"\n"},
+		},
+		"incomplete value": {
+			annotations: map[string]string{types.AnnotationWorkflowRunDebugOp: "true"},
+			params:      []string{`value: {a: 1, b: string}`},
+			expected:    map[string]string{"value": "a: 1\nb: string\n"},
+		},
+		"multiple values": {
+			annotations: map[string]string{types.AnnotationWorkflowRunDebugOp: "true"},
+			params:      []string{`name: "first", value: 1`, `name: "second", value: 2`, `name: "first", value: 3`},
+			expected:    map[string]string{"first": "3\n", "second": "2\n"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			ctx := monitorContext.NewTraceContext(context.Background(), "")
+			cli := fake.NewClientBuilder().Build()
+			instance := &types.WorkflowInstance{
+				WorkflowMeta: types.WorkflowMeta{Name: "wr", Namespace: "default", Annotations: tc.annotations},
+				Debug:        tc.debug,
+			}
+			pCtx := process.NewContext(process.ContextData{})
+			pCtx.PushData(model.ContextStepName, "step")
+			prd := &provider{cli: cli, instance: instance, pCtx: pCtx}
+			for _, params := range tc.params {
+				v, err := value.NewValue(params, nil, "")
+				r.NoError(err)
+				r.NoError(prd.Debug(ctx, nil, v, nil))
+			}
+			cm := &corev1.ConfigMap{}
+			err := cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: wfDebug.GenerateContextName("wr", "step")}, cm)
+			if tc.expected == nil {
+				r.True(kerrors.IsNotFound(err))
+				return
+			}
+			r.NoError(err)
+			values := map[string]string{}
+			r.NoError(json.Unmarshal([]byte(cm.Data[wfDebug.ValuesKey]), &values))
+			r.Equal(tc.expected, values)
+		})
+	}
+}
@@ -20,12 +20,17 @@ import (
 	"fmt"
 	"sync"
 
+	"cuelang.org/go/cue"
+	"github.com/pkg/errors"
 	"gopkg.in/gomail.v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
+	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/dryrun"
 	"github.com/kubevela/workflow/pkg/types"
 )
 
@@ -35,6 +40,9 @@ const (
 )
 
 type provider struct {
+	cli      client.Client
+	ns       string
+	override *v1alpha1.EmailOverride
 }
 
 type sender struct {
@@ -50,10 +58,15 @@ type content struct {
 	Body    string `json:"body"`
 }
 
+const fieldSMTP = "smtp"
+
 var emailRoutine sync.Map
 
 // Send sends email
 func (h *provider) Send(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	if dryrun.IsDryRun(h.cli) {
+		return skip(ctx, v, act)
+	}
 	stepID, err := v.LookupValue("stepID")
 	if err != nil {
 		return err
@@ -78,14 +91,21 @@ func (h *provider) Send(ctx monitorContext.Context, wfCtx wfContext.Context, v *
 	} else {
 		emailRoutine.Store(id, "initializing")
 	}
+	if h.override != nil {
+		if err := v.FillObject(h.override, "override"); err != nil {
+			emailRoutine.Delete(id)
+			return err
+		}
+	}
 
 	s, err := v.LookupValue("from")
 	if err != nil {
 		return err
 	}
 
+	strict := value.StrictFor(v)
 	senderValue := &sender{}
-	if err := s.UnmarshalTo(senderValue); err != nil {
+	if err := s.UnmarshalTo(senderValue, strict); err != nil {
 		return err
 	}
 
@@ -103,7 +123,7 @@ func (h *provider) Send(ctx monitorContext.Context, wfCtx wfContext.Context, v *
 		return err
 	}
 	contentValue := &content{}
-	if err := c.UnmarshalTo(contentValue); err != nil {
+	if err := c.UnmarshalTo(contentValue, strict); err != nil {
 		return err
 	}
 
@@ -113,11 +133,15 @@ func (h *provider) Send(ctx monitorContext.Context, wfCtx wfContext.Context, v *
 	m.SetHeader("Subject", contentValue.Subject)
 	m.SetBody("text/html", contentValue.Body)
 
-	dial := gomail.NewDialer(senderValue.Host, senderValue.Port, senderValue.Address, senderValue.Password)
+	send, err := h.sendFunc(ctx, v, senderValue, *receiverValue, m)
+	if err != nil {
+		emailRoutine.Delete(id)
+		return err
+	}
 	go func() {
 		if routine, ok := emailRoutine.Load(id); ok && routine == "initializing" {
 			emailRoutine.Store(id, "sending")
-			if err := dial.DialAndSend(m); err != nil {
+			if err := send(); err != nil {
 				emailRoutine.Store(id, err.Error())
 				return
 			}
@@ -128,9 +152,69 @@ func (h *provider) Send(ctx monitorContext.Context, wfCtx wfContext.Context, v *
 	return nil
 }
 
+// skip skips sending the email in the dry-run mode
+func skip(ctx monitorContext.Context, v *value.Value, act types.Action) error {
+	to := []string{}
+	if r, err := v.LookupValue("to"); err == nil {
+		if err := r.UnmarshalTo(&to); err != nil {
+			return err
+		}
+	}
+	ctx.Info("Skip sending the email in the dry-run mode", "receivers", len(to))
+	act.Message(fmt.Sprintf("%s: email to %d receivers", dryrun.SkippedMessage, len(to)))
+	return v.FillObject(dryrun.SkippedMessage, "result")
+}
+
+// sendFunc returns the function to send the message, the message is sent with the smtp options if they are set,
+// otherwise it's sent to the host of the sender with implicit TLS and basic auth.
+func (h *provider) sendFunc(ctx monitorContext.Context, v *value.Value, from *sender, to []string, m *gomail.Message) (func() error, error) {
+	if !v.CueValue().LookupPath(cue.ParsePath(fieldSMTP)).Exists() {
+		h.overrideServer(&from.Host, &from.Port)
+		if from.Host == "" || from.Port == 0 {
+			return nil, errors.New("the host and the port of the sender are required if smtp is not set")
+		}
+		dial := gomail.NewDialer(from.Host, from.Port, from.Address, from.Password)
+		return func() error {
+			return dial.DialAndSend(m)
+		}, nil
+	}
+	s, err := v.LookupValue(fieldSMTP)
+	if err != nil {
+		return nil, err
+	}
+	config := &smtpConfig{}
+	if err := s.UnmarshalTo(config, value.StrictFor(v)); err != nil {
+		return nil, err
+	}
+	config.setDefaults()
+	h.overrideServer(&config.Host, &config.Port)
+	creds, err := h.credentials(ctx, config, from)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		return sendSMTP(config, creds, from.Address, to, m)
+	}, nil
+}
+
+// overrideServer replaces the smtp server with the email override of the workflow run
+func (h *provider) overrideServer(host *string, port *int) {
+	if h.override == nil {
+		return
+	}
+	if h.override.SMTPHost != "" {
+		*host = h.override.SMTPHost
+	}
+	if h.override.SMTPPort != 0 {
+		*port = h.override.SMTPPort
+	}
+}
+
 // Install register handlers to provider discover.
-func Install(p types.Providers) {
-	prd := &provider{}
+// The secrets of the smtp credentials are read from the namespace of the workflow run, and the smtp server is
+// replaced by the email override of the workflow run.
+func Install(p types.Providers, cli client.Client, ns string, override *v1alpha1.EmailOverride) {
+	prd := &provider{cli: cli, ns: ns, override: override}
 	p.Register(ProviderName, map[string]types.Handler{
 		"send": prd.Send,
 	})
@@ -17,6 +17,7 @@ limitations under the License.
 package email
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -26,8 +27,13 @@ import (
 	. "github.com/agiledragon/gomonkey/v2"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/gomail.v2"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/dryrun"
 	"github.com/kubevela/workflow/pkg/mock"
 	"github.com/kubevela/workflow/pkg/providers"
 )
@@ -91,6 +97,21 @@ stepID: "no-content"
 `,
 			expectedErr: errors.New("failed to lookup value: var(path=content) not exist"),
 		},
+		"no-host": {
+			from: `
+from: {
+address: "kubevela@gmail.com"
+password: "pwd"
+}
+to: ["user1@gmail.com"]
+content: {
+subject: "Subject"
+body: "Test body."
+}
+stepID: "no-host"
+`,
+			expectedErr: errors.New("the host and the port of the sender are required if smtp is not set"),
+		},
 		"send-fail": {
 			from: `
 from: {
@@ -152,11 +173,88 @@ stepID: "send-fail"
 	}
 }
 
+func TestSendEmailWithOverride(t *testing.T) {
+	r := require.New(t)
+	var dial *gomail.Dialer
+	var host string
+	var port int
+	patch := ApplyMethod(reflect.TypeOf(dial), "DialAndSend", func(d *gomail.Dialer, _ ...*gomail.Message) error {
+		host, port = d.Host, d.Port
+		return nil
+	})
+	defer patch.Reset()
+
+	v, err := value.NewValue(`
+from: {
+address: "kubevela@gmail.com"
+password: "pwd"
+host: "smtp.test.com"
+port: 465
+}
+to: ["user1@gmail.com"]
+content: {
+subject: "Subject"
+body: "Test body."
+}
+stepID: "override"
+`, nil, "")
+	r.NoError(err)
+	act := &mock.Action{}
+	prd := &provider{override: &v1alpha1.EmailOverride{SMTPHost: "mailhog.sandbox", SMTPPort: 1025}}
+	r.NoError(prd.Send(nil, nil, v, act))
+	r.Equal(act.Phase, "Wait")
+	overridden, err := v.GetString("override", "smtpHost")
+	r.NoError(err)
+	r.Equal("mailhog.sandbox", overridden)
+
+	time.Sleep(time.Second)
+	r.NoError(prd.Send(nil, nil, v, act))
+	r.Equal("mailhog.sandbox", host)
+	r.Equal(1025, port)
+}
+
 func TestInstall(t *testing.T) {
 	p := providers.NewProviders()
-	Install(p)
+	Install(p, nil, "", nil)
 	h, ok := p.GetHandler("email", "send")
 	r := require.New(t)
 	r.Equal(ok, true)
 	r.Equal(h != nil, true)
 }
+
+func TestSendEmailInDryRun(t *testing.T) {
+	r := require.New(t)
+	var dial *gomail.Dialer
+	sent := false
+	patch := ApplyMethod(reflect.TypeOf(dial), "DialAndSend", func(_ *gomail.Dialer, _ ...*gomail.Message) error {
+		sent = true
+		return nil
+	})
+	defer patch.Reset()
+
+	v, err := value.NewValue(`
+from: {
+address: "kubevela@gmail.com"
+password: "pwd"
+host: "smtp.test.com"
+port: 465
+}
+to: ["user1@gmail.com", "user2@gmail.com"]
+content: {
+subject: "Subject"
+body: "Test body."
+}
+stepID: "dry-run"
+`, nil, "")
+	r.NoError(err)
+	act := &mock.Action{}
+	prd := &provider{cli: dryrun.Client(fake.NewClientBuilder().Build())}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	r.NoError(prd.Send(ctx, nil, v, act))
+	r.Equal("skipped: dry-run: email to 2 receivers", act.Msg)
+	result, err := v.GetString("result")
+	r.NoError(err)
+	r.Equal("skipped: dry-run", result)
+	time.Sleep(100 * time.Millisecond)
+	r.False(sent)
+}
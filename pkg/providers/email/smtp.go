@@ -0,0 +1,226 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/gomail.v2"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// TLSNone sends the email over the plain connection
+	TLSNone = "none"
+	// TLSStartTLS upgrades the plain connection to TLS with the STARTTLS command
+	TLSStartTLS = "starttls"
+	// TLSImplicit connects to the server with TLS
+	TLSImplicit = "tls"
+
+	// AuthNone sends the email without authentication
+	AuthNone = "none"
+	// AuthPlain authenticates with the PLAIN mechanism
+	AuthPlain = "plain"
+	// AuthLogin authenticates with the LOGIN mechanism
+	AuthLogin = "login"
+	// AuthCRAMMD5 authenticates with the CRAM-MD5 mechanism
+	AuthCRAMMD5 = "cram-md5"
+)
+
+var dialTimeout = 10 * time.Second
+
+type smtpConfig struct {
+	Host               string     `json:"host"`
+	Port               int        `json:"port"`
+	TLS                string     `json:"tls,omitempty"`
+	InsecureSkipVerify bool       `json:"insecureSkipVerify,omitempty"`
+	Auth               string     `json:"auth,omitempty"`
+	SecretRef          *secretRef `json:"secretRef,omitempty"`
+}
+
+func (c *smtpConfig) setDefaults() {
+	if c.Port == 0 {
+		c.Port = 587
+	}
+	if c.TLS == "" {
+		c.TLS = TLSStartTLS
+	}
+	if c.Auth == "" {
+		c.Auth = AuthPlain
+	}
+}
+
+// secretRef refers to the secret in the namespace of the workflow run with the credentials
+type secretRef struct {
+	Name        string `json:"name"`
+	UsernameKey string `json:"usernameKey,omitempty"`
+	PasswordKey string `json:"passwordKey,omitempty"`
+}
+
+type credentials struct {
+	username string
+	password string
+}
+
+// credentials returns the credentials to authenticate to the smtp server, the address and the password of
+// the sender are overridden by the secret if it's referred.
+func (h *provider) credentials(ctx context.Context, config *smtpConfig, from *sender) (credentials, error) {
+	creds := credentials{username: from.Address, password: from.Password}
+	if config.SecretRef == nil {
+		return creds, nil
+	}
+	if h.cli == nil {
+		return creds, errors.New("no client to read the secret of the smtp credentials")
+	}
+	secret := new(corev1.Secret)
+	if err := h.cli.Get(ctx, client.ObjectKey{Namespace: h.ns, Name: config.SecretRef.Name}, secret); err != nil {
+		return creds, errors.WithMessagef(err, "get the secret %s of the smtp credentials", config.SecretRef.Name)
+	}
+	usernameKey, passwordKey := config.SecretRef.UsernameKey, config.SecretRef.PasswordKey
+	if usernameKey == "" {
+		usernameKey = "username"
+	}
+	if passwordKey == "" {
+		passwordKey = "password"
+	}
+	if username, ok := secret.Data[usernameKey]; ok {
+		creds.username = string(username)
+	}
+	password, ok := secret.Data[passwordKey]
+	if !ok {
+		return creds, errors.Errorf("key %s not found in the secret %s of the smtp credentials", passwordKey, config.SecretRef.Name)
+	}
+	creds.password = string(password)
+	return creds, nil
+}
+
+// sendSMTP sends the message with the smtp config, the errors of dialing, the tls handshake and the authentication
+// are distinguished to diagnose the misconfiguration.
+func sendSMTP(config *smtpConfig, creds credentials, from string, to []string, m *gomail.Message) error {
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	tlsConfig := &tls.Config{ServerName: config.Host, InsecureSkipVerify: config.InsecureSkipVerify}
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return errors.Errorf("dial smtp server %s: %v", addr, err)
+	}
+	if config.TLS == TLSImplicit {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			_ = conn.Close()
+			return errors.Errorf("tls handshake with smtp server %s: %v", addr, err)
+		}
+		conn = tlsConn
+	}
+	c, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return errors.Errorf("dial smtp server %s: %v", addr, err)
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+	if config.TLS == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.Errorf("tls handshake with smtp server %s: STARTTLS is not supported by the server", addr)
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return errors.Errorf("tls handshake with smtp server %s: %v", addr, err)
+		}
+	}
+	if auth := smtpAuth(config, creds); auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return errors.Errorf("authenticate to smtp server %s with %s: %v", addr, config.Auth, err)
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return errors.Errorf("send the email to smtp server %s: %v", addr, err)
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return errors.Errorf("send the email to smtp server %s: %v", addr, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return errors.Errorf("send the email to smtp server %s: %v", addr, err)
+	}
+	if _, err := m.WriteTo(w); err != nil {
+		return errors.Errorf("send the email to smtp server %s: %v", addr, err)
+	}
+	if err := w.Close(); err != nil {
+		return errors.Errorf("send the email to smtp server %s: %v", addr, err)
+	}
+	return c.Quit()
+}
+
+func smtpAuth(config *smtpConfig, creds credentials) smtp.Auth {
+	switch config.Auth {
+	case AuthNone:
+		return nil
+	case AuthLogin:
+		return &loginAuth{username: creds.username, password: creds.password, host: config.Host}
+	case AuthCRAMMD5:
+		return smtp.CRAMMD5Auth(creds.username, creds.password)
+	default:
+		return smtp.PlainAuth("", creds.username, creds.password, config.Host)
+	}
+}
+
+// loginAuth implements the LOGIN mechanism which is not supported by net/smtp
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+// Start begins the authentication, the credentials are only sent over TLS or to localhost like smtp.PlainAuth
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+// Next answers the challenges of the server
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch string(fromServer) {
+	case "Username:":
+		return []byte(a.username), nil
+	case "Password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected server challenge: %s", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package email
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/gomail.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeSMTPServer serves the smtp sessions with the extensions, the authentication is rejected if rejectAuth is true
+type fakeSMTPServer struct {
+	ln         net.Listener
+	extensions []string
+	rejectAuth bool
+	data       chan string
+}
+
+func newFakeSMTPServer(t *testing.T, rejectAuth bool, extensions ...string) *fakeSMTPServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeSMTPServer{ln: ln, extensions: extensions, rejectAuth: rejectAuth, data: make(chan string, 1)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+	return s
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.Fields(line + " ")[0])
+		switch cmd {
+		case "EHLO":
+			reply("250-localhost")
+			for _, ext := range s.extensions {
+				reply("250-" + ext)
+			}
+			reply("250 8BITMIME")
+		case "AUTH":
+			if strings.Contains(strings.ToUpper(line), "CRAM-MD5") {
+				reply("334 PDEyMzQ1QGxvY2FsaG9zdD4=")
+				if _, err := r.ReadString('\n'); err != nil {
+					return
+				}
+			}
+			if s.rejectAuth {
+				reply("535 authentication failed")
+				continue
+			}
+			reply("235 authenticated")
+		case "MAIL", "RCPT":
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.data <- data.String()
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSendSMTP(t *testing.T) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	require.NoError(t, closed.Close())
+
+	testCases := map[string]struct {
+		server *fakeSMTPServer
+		config smtpConfig
+		errMsg string
+	}{
+		"dial": {
+			config: smtpConfig{Port: closedPort, TLS: TLSNone, Auth: AuthNone},
+			errMsg: "dial smtp server",
+		},
+		"implicit tls handshake": {
+			server: newFakeSMTPServer(t, false),
+			config: smtpConfig{TLS: TLSImplicit, Auth: AuthNone},
+			errMsg: "tls handshake with smtp server",
+		},
+		"starttls not supported": {
+			server: newFakeSMTPServer(t, false),
+			config: smtpConfig{TLS: TLSStartTLS, Auth: AuthNone},
+			errMsg: "tls handshake with smtp server 127.0.0.1:",
+		},
+		"auth rejected": {
+			server: newFakeSMTPServer(t, true, "AUTH PLAIN LOGIN CRAM-MD5"),
+			config: smtpConfig{TLS: TLSNone, Auth: AuthCRAMMD5},
+			errMsg: "authenticate to smtp server",
+		},
+		"no auth": {
+			server: newFakeSMTPServer(t, false),
+			config: smtpConfig{TLS: TLSNone, Auth: AuthNone},
+		},
+		"plain auth": {
+			server: newFakeSMTPServer(t, false, "AUTH PLAIN LOGIN CRAM-MD5"),
+			config: smtpConfig{TLS: TLSNone, Auth: AuthPlain},
+		},
+		"login auth": {
+			server: newFakeSMTPServer(t, false, "AUTH PLAIN LOGIN CRAM-MD5"),
+			config: smtpConfig{TLS: TLSNone, Auth: AuthLogin},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			tc.config.Host = "127.0.0.1"
+			if tc.server != nil {
+				tc.config.Port = tc.server.port()
+			}
+			m := gomail.NewMessage()
+			m.SetHeader("From", "bot@example.com")
+			m.SetHeader("To", "user@example.com")
+			m.SetHeader("Subject", "Workflow finished")
+			m.SetBody("text/html", "done")
+			err := sendSMTP(&tc.config, credentials{username: "bot", password: "pwd"}, "bot@example.com", []string{"user@example.com"}, m)
+			if tc.errMsg != "" {
+				r.Error(err)
+				r.Contains(err.Error(), tc.errMsg)
+				r.Contains(err.Error(), "127.0.0.1:"+strconv.Itoa(tc.config.Port))
+				return
+			}
+			r.NoError(err)
+			r.Contains(<-tc.server.data, "Subject: Workflow finished")
+		})
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "smtp", Namespace: "default"},
+		Data:       map[string][]byte{"user": []byte("relay"), "password": []byte("secret")},
+	}).Build()
+	prd := &provider{cli: cli, ns: "default"}
+	from := &sender{Address: "bot@example.com", Password: "pwd"}
+
+	creds, err := prd.credentials(context.Background(), &smtpConfig{}, from)
+	r.NoError(err)
+	r.Equal(credentials{username: "bot@example.com", password: "pwd"}, creds)
+
+	creds, err = prd.credentials(context.Background(), &smtpConfig{SecretRef: &secretRef{Name: "smtp", UsernameKey: "user"}}, from)
+	r.NoError(err)
+	r.Equal(credentials{username: "relay", password: "secret"}, creds)
+
+	_, err = prd.credentials(context.Background(), &smtpConfig{SecretRef: &secretRef{Name: "smtp", PasswordKey: "token"}}, from)
+	r.Error(err)
+	r.Contains(err.Error(), "key token not found")
+
+	_, err = prd.credentials(context.Background(), &smtpConfig{SecretRef: &secretRef{Name: "not-exist"}}, from)
+	r.Error(err)
+	r.Contains(err.Error(), "get the secret not-exist")
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/history"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// ProviderName is provider name for install.
+	ProviderName = "history"
+)
+
+type provider struct {
+	store history.Store
+	ns    string
+}
+
+// ErrorBudgetParams is the params of the error budget check
+type ErrorBudgetParams struct {
+	WorkflowRef string            `json:"workflowRef,omitempty"`
+	Selector    map[string]string `json:"selector,omitempty"`
+	Window      struct {
+		Count    int    `json:"count,omitempty"`
+		Duration string `json:"duration,omitempty"`
+	} `json:"window"`
+	Threshold  int `json:"threshold"`
+	MinSamples int `json:"minSamples"`
+}
+
+// ErrorBudgetResult is the result of the error budget check
+type ErrorBudgetResult struct {
+	Allowed bool `json:"allowed"`
+	Total   int  `json:"total"`
+	Failed  int  `json:"failed"`
+	// Sufficient indicates whether the samples are enough to check the error budget
+	Sufficient bool `json:"sufficient"`
+}
+
+// CheckErrorBudget checks whether the failed runs in the window exceed the threshold
+func (p *provider) CheckErrorBudget(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	params := &ErrorBudgetParams{}
+	if err := v.UnmarshalTo(params, value.StrictFor(v, "result")); err != nil {
+		return err
+	}
+	if params.WorkflowRef == "" && len(params.Selector) == 0 {
+		return errors.New("either workflowRef or selector is required")
+	}
+	// the runs are looked up in the namespace of the current run only
+	query := history.Query{
+		Namespace:   p.ns,
+		WorkflowRef: params.WorkflowRef,
+		Count:       params.Window.Count,
+	}
+	if len(params.Selector) > 0 {
+		query.Selector = labels.SelectorFromSet(params.Selector)
+	}
+	if params.Window.Duration != "" {
+		duration, err := time.ParseDuration(params.Window.Duration)
+		if err != nil {
+			return errors.WithMessage(err, "invalid window duration")
+		}
+		query.Since = time.Now().Add(-duration)
+	}
+	if query.Count == 0 && query.Since.IsZero() {
+		return errors.New("either window count or window duration is required")
+	}
+
+	entries := p.store.List(query)
+	result := ErrorBudgetResult{Total: len(entries)}
+	for _, e := range entries {
+		if e.Failed() {
+			result.Failed++
+		}
+	}
+	// if the samples are not enough, the budget can not be judged, allow it
+	result.Sufficient = result.Total >= params.MinSamples
+	result.Allowed = !result.Sufficient || result.Failed <= params.Threshold
+	return v.FillObject(result, "result")
+}
+
+// Install register handlers to provider discover.
+func Install(p types.Providers, store history.Store, ns string) {
+	prd := &provider{
+		store: store,
+		ns:    ns,
+	}
+	p.Register(ProviderName, map[string]types.Handler{
+		"check-error-budget": prd.CheckErrorBudget,
+	})
+}
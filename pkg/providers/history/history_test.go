@@ -0,0 +1,148 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/history"
+)
+
+func TestCheckErrorBudget(t *testing.T) {
+	store := history.NewStore()
+	now := time.Now()
+	// the latest 10 runs of deploy have 3 failures, the older ones are all failed
+	for i := 0; i < 15; i++ {
+		phase := v1alpha1.WorkflowStateSucceeded
+		if i < 5 || i == 8 || i == 11 || i == 14 {
+			phase = v1alpha1.WorkflowStateFailed
+		}
+		store.Record(history.Entry{
+			Name:        fmt.Sprintf("deploy-%d", i),
+			Namespace:   "default",
+			WorkflowRef: "deploy",
+			Labels:      map[string]string{"service": "api"},
+			Phase:       phase,
+			EndTime:     now.Add(time.Duration(i-15) * time.Hour),
+		})
+	}
+	store.Record(history.Entry{
+		Name:        "other",
+		Namespace:   "default",
+		WorkflowRef: "other",
+		Phase:       v1alpha1.WorkflowStateTerminated,
+		EndTime:     now,
+	})
+	store.Record(history.Entry{
+		Name:        "other-terminated",
+		Namespace:   "default",
+		WorkflowRef: "other",
+		Phase:       v1alpha1.WorkflowStateTerminated,
+		Reason:      v1alpha1.TerminatedReasonUserTerminated,
+		EndTime:     now.Add(-time.Minute),
+	})
+
+	testCases := map[string]struct {
+		ns       string
+		params   string
+		expected ErrorBudgetResult
+		err      bool
+	}{
+		"exceed threshold by count": {
+			params:   `workflowRef: "deploy", window: count: 10, threshold: 2`,
+			expected: ErrorBudgetResult{Allowed: false, Total: 10, Failed: 3, Sufficient: true},
+		},
+		"within threshold by count": {
+			params:   `workflowRef: "deploy", window: count: 10, threshold: 3`,
+			expected: ErrorBudgetResult{Allowed: true, Total: 10, Failed: 3, Sufficient: true},
+		},
+		"by duration and selector": {
+			params:   `selector: service: "api", window: duration: "3h30m", threshold: 0`,
+			expected: ErrorBudgetResult{Allowed: false, Total: 3, Failed: 1, Sufficient: true},
+		},
+		"sparse history": {
+			params:   `workflowRef: "other", window: count: 10, threshold: 0, minSamples: 5`,
+			expected: ErrorBudgetResult{Allowed: true, Total: 2, Failed: 1, Sufficient: false},
+		},
+		"enough samples": {
+			params:   `workflowRef: "other", window: count: 10, threshold: 0, minSamples: 1`,
+			expected: ErrorBudgetResult{Allowed: false, Total: 2, Failed: 1, Sufficient: true},
+		},
+		"user terminated": {
+			params:   `workflowRef: "other", window: count: 10, threshold: 1, minSamples: 1`,
+			expected: ErrorBudgetResult{Allowed: true, Total: 2, Failed: 1, Sufficient: true},
+		},
+		"no history in namespace": {
+			ns:       "prod",
+			params:   `workflowRef: "deploy", window: count: 10, threshold: 0`,
+			expected: ErrorBudgetResult{Allowed: true, Total: 0, Failed: 0, Sufficient: false},
+		},
+		"namespace of other runs": {
+			ns:     "prod",
+			params: `workflowRef: "deploy", namespace: "default", window: count: 10, threshold: 0, strict: true`,
+			err:    true,
+		},
+		"no target": {
+			params: `window: count: 10, threshold: 0`,
+			err:    true,
+		},
+		"no window": {
+			params: `workflowRef: "deploy", window: {}, threshold: 0`,
+			err:    true,
+		},
+		"invalid duration": {
+			params: `workflowRef: "deploy", window: duration: "1x", threshold: 0`,
+			err:    true,
+		},
+		"unknown field in strict mode": {
+			params: `workflowRef: "deploy", window: cuont: 10, threshold: 0, strict: true`,
+			err:    true,
+		},
+		"strict mode": {
+			params:   `workflowRef: "deploy", window: count: 3, threshold: 0, strict: true`,
+			expected: ErrorBudgetResult{Allowed: false, Total: 3, Failed: 1, Sufficient: true},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			p := &provider{store: store, ns: "default"}
+			if tc.ns != "" {
+				p.ns = tc.ns
+			}
+			v, err := value.NewValue(tc.params+"\nminSamples: *1 | int", nil, "")
+			r.NoError(err)
+			err = p.CheckErrorBudget(nil, nil, v, nil)
+			if tc.err {
+				r.Error(err)
+				return
+			}
+			r.NoError(err)
+			result := ErrorBudgetResult{}
+			rv, err := v.LookupValue("result")
+			r.NoError(err)
+			r.NoError(rv.UnmarshalTo(&result))
+			r.Equal(tc.expected, result)
+		})
+	}
+}
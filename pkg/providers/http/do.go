@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -34,8 +35,11 @@ import (
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
+	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/correlation"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/dryrun"
 	"github.com/kubevela/workflow/pkg/providers/http/ratelimiter"
 	"github.com/kubevela/workflow/pkg/types"
 )
@@ -59,20 +63,49 @@ func init() {
 }
 
 type provider struct {
-	cli client.Client
-	ns  string
+	cli         client.Client
+	ns          string
+	correlation map[string]string
+	overrides   *v1alpha1.ProviderOverrides
 }
 
 // Do process http request.
 func (h *provider) Do(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
-	resp, err := h.runHTTP(ctx, v)
+	if dryrun.IsDryRun(h.cli) {
+		return h.skip(ctx, v, act)
+	}
+	resp, err := h.runHTTP(ctx, v, act)
 	if err != nil {
 		return err
 	}
 	return v.FillObject(resp, "response")
 }
 
-func (h *provider) runHTTP(ctx monitorContext.Context, v *value.Value) (interface{}, error) {
+// skip skips the request in the dry-run mode, the empty response with the status 200 is filled so that the step
+// proceeds as the request succeeds
+func (h *provider) skip(ctx monitorContext.Context, v *value.Value, act types.Action) error {
+	method, err := v.GetString("method")
+	if err != nil {
+		return err
+	}
+	u, err := v.GetString("url")
+	if err != nil {
+		return err
+	}
+	u, _ = h.rewriteURL(u)
+	host := ""
+	if parsed, err := url.Parse(u); err == nil {
+		host = parsed.Host
+	}
+	ctx.Info("Skip the request in the dry-run mode", "method", method, "host", host)
+	act.Message(fmt.Sprintf("%s: %s %s", dryrun.SkippedMessage, method, host))
+	if err := v.FillObject(map[string]interface{}{"body": "", "statusCode": http.StatusOK}, "response"); err != nil {
+		return err
+	}
+	return v.FillObject(dryrun.SkippedMessage, "result")
+}
+
+func (h *provider) runHTTP(ctx monitorContext.Context, v *value.Value, act types.Action) (interface{}, error) {
 	var (
 		err             error
 		method, u       string
@@ -93,6 +126,13 @@ func (h *provider) runHTTP(ctx monitorContext.Context, v *value.Value) (interfac
 	if u, err = v.GetString("url"); err != nil {
 		return nil, err
 	}
+	u, override := h.rewriteURL(u)
+	if override != nil {
+		if err := v.FillObject(override, "override"); err != nil {
+			return nil, err
+		}
+		ctx.Info("The url of the request is overridden by the workflow run", "rule", override["rule"], "url", override["rewrittenURL"])
+	}
 	if rl, err := v.LookupValue("request", "ratelimiter"); err == nil {
 		limit, err := rl.GetInt64("limit")
 		if err != nil {
@@ -126,6 +166,13 @@ func (h *provider) runHTTP(ctx monitorContext.Context, v *value.Value) (interfac
 		header = map[string][]string{}
 		header.Set("Content-Type", "application/json")
 	}
+	if len(h.correlation) > 0 {
+		baggage := correlation.Baggage(h.correlation)
+		if existing := header.Get(correlation.BaggageHeader); existing != "" {
+			baggage = existing + "," + baggage
+		}
+		header.Set(correlation.BaggageHeader, baggage)
+	}
 
 	req, err := http.NewRequestWithContext(context.Background(), method, u, r)
 	if err != nil {
@@ -133,6 +180,8 @@ func (h *provider) runHTTP(ctx monitorContext.Context, v *value.Value) (interfac
 	}
 	req.Header = header
 	req.Trailer = trailer
+	// only the method and the host are audited, the path, the query and the headers may carry the credentials
+	types.RecordAuditTarget(act, types.AuditTarget{"method": req.Method, "host": req.URL.Host})
 
 	if tr, err := h.getTransport(ctx, v); err == nil && tr != nil {
 		defaultClient.Transport = tr
@@ -238,10 +287,14 @@ func parseHeaders(obj cue.Value, label string) (http.Header, error) {
 }
 
 // Install register handlers to provider discover.
-func Install(p types.Providers, cli client.Client, ns string) {
+// The correlation ids are propagated in the baggage header of the requests, and the urls are rewritten by the
+// http and the notification overrides of the workflow run.
+func Install(p types.Providers, cli client.Client, ns string, correlationIDs map[string]string, overrides *v1alpha1.ProviderOverrides) {
 	prd := &provider{
-		cli: cli,
-		ns:  ns,
+		cli:         cli,
+		ns:          ns,
+		correlation: correlationIDs,
+		overrides:   overrides,
 	}
 	p.Register(ProviderName, map[string]types.Handler{
 		"do": prd.Do,
@@ -34,10 +34,14 @@ import (
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
+	"github.com/kubevela/workflow/api/v1alpha1"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/dryrun"
+	"github.com/kubevela/workflow/pkg/mock"
 	"github.com/kubevela/workflow/pkg/providers"
 	"github.com/kubevela/workflow/pkg/providers/http/ratelimiter"
 	"github.com/kubevela/workflow/pkg/providers/http/testdata"
@@ -245,12 +249,41 @@ request: {
 func TestInstall(t *testing.T) {
 	r := require.New(t)
 	p := providers.NewProviders()
-	Install(p, nil, "")
+	Install(p, nil, "", nil, nil)
 	h, ok := p.GetHandler("http", "do")
 	r.Equal(ok, true)
 	r.Equal(h != nil, true)
 }
 
+func TestHTTPDoWithCorrelation(t *testing.T) {
+	r := require.New(t)
+	var baggage string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		baggage = req.Header.Get("baggage")
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	prd := &provider{correlation: map[string]string{"example.com/ticket": "T-1", "team": "infra"}}
+
+	v, err := value.NewValue(fmt.Sprintf(`
+method: "GET"
+url: "%s"
+`, server.URL), nil, "")
+	r.NoError(err)
+	r.NoError(prd.Do(ctx, nil, v, nil))
+	r.Equal("example.com.ticket=T-1,team=infra", baggage)
+
+	v, err = value.NewValue(fmt.Sprintf(`
+method: "GET"
+url: "%s"
+request: header: baggage: "user=test"
+`, server.URL), nil, "")
+	r.NoError(err)
+	r.NoError(prd.Do(ctx, nil, v, nil))
+	r.Equal("user=test,example.com.ticket=T-1,team=infra", baggage)
+}
+
 func runMockServer(shutdown chan struct{}) {
 	http.HandleFunc("/timeout", func(w http.ResponseWriter, req *http.Request) {
 		time.Sleep(time.Second * 2)
@@ -309,7 +342,7 @@ url: "https://127.0.0.1:8443/api/v1/token?val=test-token"
 `, nil, "")
 	r.NoError(err)
 	r.NoError(v.FillObject("certs", "tls_config", "secret"))
-	prd := &provider{cli, "default"}
+	prd := &provider{cli: cli, ns: "default"}
 	err = prd.Do(ctx, nil, v, nil)
 	r.NoError(err)
 }
@@ -350,3 +383,93 @@ func newMockHttpsServer() *httptest.Server {
 	ts.StartTLS()
 	return ts
 }
+
+func TestHTTPDoWithOverrides(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path = req.URL.Path
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+	prd := &provider{overrides: &v1alpha1.ProviderOverrides{
+		HTTP: &v1alpha1.HTTPOverride{BaseURLRewrite: []v1alpha1.URLRewrite{
+			{From: "https://api.example.com/v2", To: server.URL + "/mock"},
+			{From: "https://api.example.com", To: server.URL},
+		}},
+		Notification: &v1alpha1.NotificationOverride{SlackURL: server.URL + "/slack"},
+	}}
+	testCases := map[string]struct {
+		url      string
+		path     string
+		override string
+	}{
+		"rewrite the first matched prefix": {
+			url:      "https://api.example.com/v2/users",
+			path:     "/mock/users",
+			override: "http.baseURLRewrite[0]",
+		},
+		"rewrite the base url": {
+			url:      "https://api.example.com/v1/users",
+			path:     "/v1/users",
+			override: "http.baseURLRewrite[1]",
+		},
+		"replace the slack webhook": {
+			url:      "https://hooks.slack.com/services/T0/B0/XXX",
+			path:     "/slack",
+			override: "notification.slackURL",
+		},
+		"no override": {
+			url:  server.URL + "/hello",
+			path: "/hello",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			ctx := monitorContext.NewTraceContext(context.Background(), "")
+			v, err := value.NewValue(fmt.Sprintf(`
+method: "GET"
+url: "%s"
+`, tc.url), nil, "")
+			r.NoError(err)
+			r.NoError(prd.Do(ctx, nil, v, nil))
+			r.Equal(tc.path, path)
+			rule, err := v.GetString("override", "rule")
+			if tc.override == "" {
+				r.Error(err)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tc.override, rule)
+			original, err := v.GetString("override", "url")
+			r.NoError(err)
+			r.Equal(tc.url, original)
+		})
+	}
+}
+
+func TestHTTPDoInDryRun(t *testing.T) {
+	r := require.New(t)
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requested = true
+	}))
+	defer server.Close()
+	prd := &provider{cli: dryrun.Client(fake.NewClientBuilder().Build())}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	v, err := value.NewValue(fmt.Sprintf(`
+method: "POST"
+url: "%s/hooks"
+`, server.URL), nil, "")
+	r.NoError(err)
+	act := &mock.Action{}
+	r.NoError(prd.Do(ctx, nil, v, act))
+	r.False(requested)
+	result, err := v.GetString("result")
+	r.NoError(err)
+	r.Equal("skipped: dry-run", result)
+	statusCode, err := v.GetInt64("response", "statusCode")
+	r.NoError(err)
+	r.Equal(int64(http.StatusOK), statusCode)
+	r.Equal(fmt.Sprintf("skipped: dry-run: POST %s", server.Listener.Addr().String()), act.Msg)
+}
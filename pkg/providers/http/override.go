@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const slackWebhookHost = "hooks.slack.com"
+
+// rewriteURL applies the provider overrides of the workflow run to the url of the request. The urls of the slack
+// incoming webhooks are replaced by the notification override, otherwise the first matched prefix of the http
+// override is rewritten. The applied override is returned to be recorded in the result of the op.
+func (h *provider) rewriteURL(u string) (string, map[string]interface{}) {
+	if h.overrides == nil {
+		return u, nil
+	}
+	if n := h.overrides.Notification; n != nil && n.SlackURL != "" {
+		if parsed, err := url.Parse(u); err == nil && parsed.Host == slackWebhookHost {
+			return n.SlackURL, appliedOverride("notification.slackURL", u, n.SlackURL)
+		}
+	}
+	if o := h.overrides.HTTP; o != nil {
+		for i, rewrite := range o.BaseURLRewrite {
+			if rewrite.From != "" && strings.HasPrefix(u, rewrite.From) {
+				rewritten := rewrite.To + strings.TrimPrefix(u, rewrite.From)
+				return rewritten, appliedOverride(fmt.Sprintf("http.baseURLRewrite[%d]", i), u, rewritten)
+			}
+		}
+	}
+	return u, nil
+}
+
+func appliedOverride(rule, from, to string) map[string]interface{} {
+	return map[string]interface{}{
+		"rule":         rule,
+		"url":          from,
+		"rewrittenURL": to,
+	}
+}
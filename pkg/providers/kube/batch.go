@@ -0,0 +1,275 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// BatchPhaseSucceeded means the batch is applied and its gate is passed
+	BatchPhaseSucceeded = "succeeded"
+	// BatchPhaseRunning means the batch is applied and waiting for its gate
+	BatchPhaseRunning = "running"
+	// BatchPhaseFailed means the batch is failed to apply
+	BatchPhaseFailed = "failed"
+	// BatchPhasePending means the batch is not applied yet
+	BatchPhasePending = "pending"
+
+	// defaultHealthConditionType is the condition type checked by the health check if it's not set
+	defaultHealthConditionType = "Ready"
+)
+
+// batch is the clusters applied in one reconcile, the gate runs after the batch is applied
+type batch struct {
+	// Weight is the number of the clusters in the batch or the percentage of all the clusters, e.g. 25%
+	Weight intstr.IntOrString `json:"weight"`
+	After  *batchGate         `json:"after,omitempty"`
+}
+
+// batchGate is the gate that must be passed before applying the next batch
+type batchGate struct {
+	// Suspend suspends the workflow after the batch is applied, the next batch is applied after it's resumed
+	Suspend bool `json:"suspend,omitempty"`
+	// HealthCheck waits for the object in the clusters of the batch to be healthy
+	HealthCheck *healthCheck `json:"healthCheck,omitempty"`
+}
+
+// healthCheck checks the condition of the object
+type healthCheck struct {
+	// ConditionType is the type of the condition whose status should be True, default to Ready
+	ConditionType string `json:"conditionType,omitempty"`
+}
+
+// batchProgress is the progress of the batches persisted in the workflow context across reconciles
+type batchProgress struct {
+	// Batch is the index of the batch in progress, the former batches are succeeded
+	Batch int `json:"batch"`
+	// Suspended means the workflow is suspended by the gate of the batch, the gate is passed once it's resumed
+	Suspended bool `json:"suspended,omitempty"`
+}
+
+// batchStatus is the status of the batch filled into the progress of the op
+type batchStatus struct {
+	Clusters []string `json:"clusters"`
+	Phase    string   `json:"phase"`
+}
+
+// rollout applies the object to the clusters batch by batch, one batch per reconcile
+type rollout struct {
+	wfCtx    wfContext.Context
+	key      string
+	batches  []batch
+	clusters [][]string
+	progress batchProgress
+}
+
+func newRollout(v *value.Value, wfCtx wfContext.Context, workload *unstructured.Unstructured, clusters []string) (*rollout, error) {
+	bv, err := v.LookupValue("batches")
+	if err != nil {
+		return nil, nil
+	}
+	r := &rollout{
+		wfCtx: wfCtx,
+		key:   strings.ToLower(strings.Join([]string{"batches", workload.GetKind(), workload.GetNamespace(), workload.GetName()}, "-")),
+	}
+	if err := bv.UnmarshalTo(&r.batches); err != nil {
+		return nil, err
+	}
+	if len(r.batches) == 0 {
+		return nil, errors.New("batches should not be empty")
+	}
+	r.clusters = splitBatches(clusters, r.batches)
+	// the clusters left by the batches are applied in the last batch without the gate
+	if len(r.clusters) > len(r.batches) {
+		r.batches = append(r.batches, batch{})
+	}
+	if data := wfCtx.GetMutableValue(r.key); data != "" {
+		if err := json.Unmarshal([]byte(data), &r.progress); err != nil {
+			return nil, err
+		}
+	}
+	if r.progress.Batch >= len(r.clusters) {
+		r.progress = batchProgress{}
+	}
+	return r, nil
+}
+
+// splitBatches splits the clusters by the weights of the batches in order, the percentages are rounded up
+func splitBatches(clusters []string, batches []batch) [][]string {
+	var result [][]string
+	start := 0
+	for i := range batches {
+		size, err := intstr.GetScaledValueFromIntOrPercent(&batches[i].Weight, len(clusters), true)
+		if err != nil || size < 0 {
+			size = 0
+		}
+		end := start + size
+		if end > len(clusters) {
+			end = len(clusters)
+		}
+		result = append(result, clusters[start:end])
+		start = end
+	}
+	if start < len(clusters) {
+		result = append(result, clusters[start:])
+	}
+	return result
+}
+
+// current returns the clusters of the batch in progress
+func (r *rollout) current() []string {
+	return r.clusters[r.progress.Batch]
+}
+
+func (r *rollout) save() error {
+	b, err := json.Marshal(r.progress)
+	if err != nil {
+		return err
+	}
+	r.wfCtx.SetMutableValue(string(b), r.key)
+	return nil
+}
+
+// fillProgress fills the phases of the batches into the value, the batch in progress is in the phase
+func (r *rollout) fillProgress(v *value.Value, phase string) error {
+	statuses := make([]batchStatus, 0, len(r.clusters))
+	for i, clusters := range r.clusters {
+		status := batchStatus{Clusters: clusters, Phase: BatchPhasePending}
+		switch {
+		case i < r.progress.Batch:
+			status.Phase = BatchPhaseSucceeded
+		case i == r.progress.Batch:
+			status.Phase = phase
+		}
+		statuses = append(statuses, status)
+	}
+	return v.FillObject(map[string]interface{}{
+		"batch":   r.progress.Batch,
+		"batches": statuses,
+	}, "progress")
+}
+
+// fail fails the step with the states of the clusters when the batch is failed to apply to the cluster
+func (r *rollout) fail(v *value.Value, act types.Action, cluster string, err error) error {
+	var applied, notApplied []string
+	reached := false
+	for i, clusters := range r.clusters {
+		for _, c := range clusters {
+			// the clusters of the batch are applied in order until the failed one
+			reached = reached || (i == r.progress.Batch && c == cluster)
+			if i < r.progress.Batch || (i == r.progress.Batch && !reached) {
+				applied = append(applied, c)
+			} else {
+				notApplied = append(notApplied, c)
+			}
+		}
+	}
+	r.wfCtx.DeleteMutableValue(r.key)
+	act.Fail(fmt.Sprintf("batch %d/%d failed to apply to cluster %s: %s, applied clusters: %v, not applied clusters: %v",
+		r.progress.Batch+1, len(r.clusters), cluster, err.Error(), applied, notApplied))
+	return r.fillProgress(v, BatchPhaseFailed)
+}
+
+// gate runs the gate of the batch in progress after it's applied, the next batch is applied in the next reconcile
+// if the gate is passed, otherwise the step waits for the gate.
+func (r *rollout) gate(ctx context.Context, h *provider, v *value.Value, act types.Action, workload *unstructured.Unstructured) error {
+	current := fmt.Sprintf("batch %d/%d", r.progress.Batch+1, len(r.clusters))
+	gate := r.batches[r.progress.Batch].After
+	switch {
+	case gate == nil:
+	case gate.Suspend:
+		if !r.progress.Suspended {
+			r.progress.Suspended = true
+			if err := r.save(); err != nil {
+				return err
+			}
+			// keep the step running so that it's executed again once the workflow is resumed
+			message := fmt.Sprintf("%s is applied to clusters %v, resume the workflow to continue", current, r.current())
+			act.Suspend(message)
+			act.Wait(message)
+			return r.fillProgress(v, BatchPhaseRunning)
+		}
+	case gate.HealthCheck != nil:
+		unhealthy, err := h.unhealthyClusters(ctx, workload, r.current(), gate.HealthCheck)
+		if err != nil {
+			return err
+		}
+		if len(unhealthy) > 0 {
+			if err := r.save(); err != nil {
+				return err
+			}
+			act.Wait(fmt.Sprintf("%s is applied, waiting for the object to be healthy in clusters %v", current, unhealthy))
+			return r.fillProgress(v, BatchPhaseRunning)
+		}
+	}
+
+	r.progress.Batch++
+	r.progress.Suspended = false
+	if r.progress.Batch < len(r.clusters) {
+		if err := r.save(); err != nil {
+			return err
+		}
+		act.Wait(fmt.Sprintf("%s is succeeded in clusters %v, the next batch is applied in the next reconcile", current, r.clusters[r.progress.Batch-1]))
+		return r.fillProgress(v, BatchPhasePending)
+	}
+	r.wfCtx.DeleteMutableValue(r.key)
+	return r.fillProgress(v, BatchPhaseSucceeded)
+}
+
+// unhealthyClusters returns the clusters where the condition of the object is not True
+func (h *provider) unhealthyClusters(ctx context.Context, workload *unstructured.Unstructured, clusters []string, check *healthCheck) ([]string, error) {
+	conditionType := check.ConditionType
+	if conditionType == "" {
+		conditionType = defaultHealthConditionType
+	}
+	var unhealthy []string
+	for _, cluster := range clusters {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(workload.GroupVersionKind())
+		if err := h.cli.Get(handleContext(ctx, cluster), client.ObjectKeyFromObject(workload), obj); err != nil {
+			return nil, clientError(err, "get", workload)
+		}
+		if !isConditionTrue(obj, conditionType) {
+			unhealthy = append(unhealthy, cluster)
+		}
+	}
+	return unhealthy, nil
+}
+
+func isConditionTrue(obj *unstructured.Unstructured, conditionType string) bool {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == conditionType {
+			return condition["status"] == "True"
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+type mockAction struct {
+	suspend bool
+	wait    bool
+	fail    bool
+	message string
+	watches []v1alpha1.WatchedObject
+}
+
+func (act *mockAction) Suspend(message string) {
+	act.suspend = true
+	act.message = message
+}
+
+func (act *mockAction) Terminate(message string) {
+	act.message = message
+}
+
+func (act *mockAction) Wait(message string) {
+	act.wait = true
+	act.message = message
+}
+
+func (act *mockAction) Fail(message string) {
+	act.fail = true
+	act.message = message
+}
+
+func (act *mockAction) Message(message string) {
+	act.message = message
+}
+
+func (act *mockAction) Watch(obj v1alpha1.WatchedObject) {
+	act.watches = append(act.watches, obj)
+}
+
+func TestSplitBatches(t *testing.T) {
+	r := require.New(t)
+	clusters := []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8"}
+	batches := []batch{{Weight: intstr.FromInt(1)}, {Weight: intstr.FromString("25%")}}
+	r.Equal([][]string{{"c1"}, {"c2", "c3"}, {"c4", "c5", "c6", "c7", "c8"}}, splitBatches(clusters, batches))
+	batches = []batch{{Weight: intstr.FromString("50%")}, {Weight: intstr.FromString("100%")}}
+	r.Equal([][]string{{"c1", "c2", "c3", "c4"}, {"c5", "c6", "c7", "c8"}}, splitBatches(clusters, batches))
+}
+
+func TestApplyInBatches(t *testing.T) {
+	r := require.New(t)
+	var objs []client.Object
+	for _, name := range []string{"c1", "c2", "c3"} {
+		objs = append(objs, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ClusterSecretNamespace,
+			Labels:    map[string]string{LabelClusterCredentialType: "X509Certificate"},
+		}})
+	}
+	cli := fake.NewClientBuilder().WithObjects(objs...).Build()
+	var applied []string
+	var applyErr error
+	prd := &provider{cli: cli, handlers: Handlers{Apply: func(ctx context.Context, cluster, owner string, manifests ...*unstructured.Unstructured) error {
+		if applyErr != nil {
+			return applyErr
+		}
+		applied = append(applied, cluster)
+		return nil
+	}}}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	wfCtx, err := newWorkflowContextForTest()
+	r.NoError(err)
+
+	run := func() (*mockAction, *value.Value) {
+		v, err := value.NewValue(`
+value: {
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	metadata: name: "config"
+}
+cluster: ""
+clusterSelector: exclude: ["local"]
+batches: [{
+	weight: 1
+	after: suspend: true
+}, {
+	weight: "50%"
+}]
+`, nil, "")
+		r.NoError(err)
+		act := &mockAction{}
+		r.NoError(prd.Apply(ctx, wfCtx, v, act))
+		return act, v
+	}
+	phases := func(v *value.Value) []string {
+		var progress struct {
+			Batches []batchStatus `json:"batches"`
+		}
+		pv, err := v.LookupValue("progress")
+		r.NoError(err)
+		r.NoError(pv.UnmarshalTo(&progress))
+		var phases []string
+		for _, b := range progress.Batches {
+			phases = append(phases, fmt.Sprintf("%v:%s", b.Clusters, b.Phase))
+		}
+		return phases
+	}
+
+	// the first batch is applied and the workflow is suspended
+	act, v := run()
+	r.Equal([]string{"c1"}, applied)
+	r.True(act.suspend)
+	r.True(act.wait)
+	r.Contains(act.message, "batch 1/2 is applied to clusters [c1], resume the workflow to continue")
+	r.Equal([]string{"[c1]:running", "[c2 c3]:pending"}, phases(v))
+
+	// resumed
+	applied = nil
+	act, v = run()
+	r.Equal([]string{"c1"}, applied)
+	r.False(act.suspend)
+	r.True(act.wait)
+	r.Equal([]string{"[c1]:succeeded", "[c2 c3]:pending"}, phases(v))
+
+	// the second batch failed
+	applyErr = errors.New("boom")
+	act, v = run()
+	r.True(act.fail)
+	r.Equal("batch 2/2 failed to apply to cluster c2: boom, applied clusters: [c1], not applied clusters: [c2 c3]", act.message)
+	r.Equal([]string{"[c1]:succeeded", "[c2 c3]:failed"}, phases(v))
+}
+
+func TestApplyWithHealthCheck(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithObjects(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      "c1",
+		Namespace: ClusterSecretNamespace,
+		Labels:    map[string]string{LabelClusterCredentialType: "X509Certificate"},
+	}}).Build()
+	d := &dispatcher{cli: cli}
+	prd := &provider{cli: cli, handlers: Handlers{Apply: d.apply}}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	wfCtx, err := newWorkflowContextForTest()
+	r.NoError(err)
+
+	run := func(status string) *mockAction {
+		v, err := value.NewValue(`
+value: {
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	metadata: name: "config"
+	status: conditions: [{type: "Ready", status: "`+status+`"}]
+}
+cluster: ""
+clusterSelector: matchNames: ["c1"]
+batches: [{
+	weight: 1
+	after: healthCheck: conditionType: "Ready"
+}]
+`, nil, "")
+		r.NoError(err)
+		act := &mockAction{}
+		r.NoError(prd.Apply(ctx, wfCtx, v, act))
+		return act
+	}
+	act := run("False")
+	r.True(act.wait)
+	r.Contains(act.message, "waiting for the object to be healthy in clusters [c1]")
+	act = run("True")
+	r.False(act.wait)
+	r.Equal("", wfCtx.GetMutableValue("batches-configmap-default-config"))
+}
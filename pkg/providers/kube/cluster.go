@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/pkg/multicluster"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// LabelClusterCredentialType is the label of the secrets of the clusters registered in cluster-gateway
+	LabelClusterCredentialType = "cluster.core.oam.dev/cluster-credential-type"
+)
+
+// ClusterSecretNamespace is the namespace of the secrets of the registered clusters
+var ClusterSecretNamespace = "vela-system"
+
+// clusterSelector selects the clusters by their names and the labels of their secrets
+type clusterSelector struct {
+	MatchLabels map[string]string `json:"matchLabels"`
+	MatchNames  []string          `json:"matchNames"`
+	Exclude     []string          `json:"exclude"`
+	// AllowEmpty allows the selector to select no cluster, otherwise it's an error
+	AllowEmpty bool `json:"allowEmpty"`
+}
+
+// selectClusters returns the clusters to apply, it's the cluster of the op if the cluster selector is not set.
+// The clusters are resolved from the secrets of the registered clusters along with the local cluster, which has no labels.
+func (h *provider) selectClusters(ctx context.Context, v *value.Value, cluster string) ([]string, bool, error) {
+	sv, err := v.LookupValue("clusterSelector")
+	if err != nil {
+		return []string{cluster}, false, nil
+	}
+	selector := clusterSelector{}
+	if err := sv.UnmarshalTo(&selector); err != nil {
+		return nil, false, err
+	}
+	candidates, err := h.listClusters(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	clusters := []string{}
+	for name, clusterLabels := range candidates {
+		if selector.matches(name, clusterLabels) {
+			clusters = append(clusters, name)
+		}
+	}
+	if len(clusters) == 0 && !selector.AllowEmpty {
+		return nil, false, fmt.Errorf("no cluster is selected by the cluster selector, set allowEmpty to true if it's expected")
+	}
+	sort.Strings(clusters)
+	return clusters, true, nil
+}
+
+// listClusters returns the labels of the registered clusters keyed by their names
+func (h *provider) listClusters(ctx context.Context) (map[string]labels.Set, error) {
+	secrets := &corev1.SecretList{}
+	if err := h.cli.List(multicluster.WithCluster(ctx, multicluster.Local), secrets, client.InNamespace(ClusterSecretNamespace), client.HasLabels{LabelClusterCredentialType}); err != nil {
+		return nil, types.NewClientError(err, "list", "Secret", ClusterSecretNamespace)
+	}
+	clusters := map[string]labels.Set{multicluster.Local: {}}
+	for _, secret := range secrets.Items {
+		clusters[secret.Name] = secret.Labels
+	}
+	return clusters, nil
+}
+
+func (s clusterSelector) matches(name string, clusterLabels labels.Set) bool {
+	for _, excluded := range s.Exclude {
+		if excluded == name {
+			return false
+		}
+	}
+	if len(s.MatchNames) > 0 {
+		matched := false
+		for _, n := range s.MatchNames {
+			matched = matched || n == name
+		}
+		if !matched {
+			return false
+		}
+	}
+	return labels.SelectorFromSet(s.MatchLabels).Matches(clusterLabels)
+}
@@ -0,0 +1,292 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+	"github.com/kubevela/pkg/multicluster"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+func TestSelectClusters(t *testing.T) {
+	clusterSecret := func(name string, labels map[string]string) *corev1.Secret {
+		labels[LabelClusterCredentialType] = "X509Certificate"
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ClusterSecretNamespace, Labels: labels}}
+	}
+	cli := fake.NewClientBuilder().WithObjects(
+		clusterSecret("eu-1", map[string]string{"region": "eu"}),
+		clusterSecret("eu-2", map[string]string{"region": "eu"}),
+		clusterSecret("us-1", map[string]string{"region": "us"}),
+		// not a cluster secret
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: ClusterSecretNamespace, Labels: map[string]string{"region": "eu"}}},
+	).Build()
+	prd := &provider{cli: cli}
+	ctx := context.Background()
+
+	testCases := map[string]struct {
+		selector string
+		clusters []string
+		selected bool
+		err      string
+	}{
+		"no selector": {
+			clusters: []string{"prod"},
+		},
+		"all clusters": {
+			selector: `clusterSelector: {}`,
+			clusters: []string{"eu-1", "eu-2", multicluster.Local, "us-1"},
+			selected: true,
+		},
+		"match labels and exclude": {
+			selector: `clusterSelector: {matchLabels: region: "eu", exclude: ["eu-2"]}`,
+			clusters: []string{"eu-1"},
+			selected: true,
+		},
+		"match names": {
+			selector: `clusterSelector: matchNames: ["local", "us-1", "token"]`,
+			clusters: []string{multicluster.Local, "us-1"},
+			selected: true,
+		},
+		"empty": {
+			selector: `clusterSelector: matchLabels: region: "ue"`,
+			err:      "no cluster is selected by the cluster selector",
+		},
+		"allow empty": {
+			selector: `clusterSelector: {matchLabels: region: "ue", allowEmpty: true}`,
+			clusters: []string{},
+			selected: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			v, err := value.NewValue(tc.selector, nil, "")
+			r.NoError(err)
+			clusters, selected, err := prd.selectClusters(ctx, v, "prod")
+			if tc.err != "" {
+				r.Error(err)
+				r.Contains(err.Error(), tc.err)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tc.clusters, clusters)
+			r.Equal(tc.selected, selected)
+		})
+	}
+}
+
+func TestApplyToSelectedClusters(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithObjects(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      "eu-1",
+		Namespace: ClusterSecretNamespace,
+		Labels:    map[string]string{LabelClusterCredentialType: "X509Certificate", "region": "eu"},
+	}}).Build()
+	var applied []string
+	prd := &provider{cli: cli, handlers: Handlers{Apply: func(ctx context.Context, cluster, owner string, manifests ...*unstructured.Unstructured) error {
+		applied = append(applied, cluster)
+		return nil
+	}}}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+
+	v, err := value.NewValue(`
+value: {
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	metadata: name: "config"
+}
+cluster: ""
+clusterSelector: exclude: ["local"]
+`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.Apply(ctx, nil, v, nil))
+	r.Equal([]string{"eu-1"}, applied)
+	clusters, err := v.GetStringSlice("clusters")
+	r.NoError(err)
+	r.Equal([]string{"eu-1"}, clusters)
+	cluster, err := v.GetString("result", "0", "cluster")
+	r.NoError(err)
+	r.Equal("eu-1", cluster)
+
+	applied = nil
+	v, err = value.NewValue(`
+value: [{
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	metadata: name: "config"
+}]
+cluster: ""
+clusterSelector: {}
+`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.ApplyInParallel(ctx, nil, v, nil))
+	r.Equal([]string{"eu-1", "local"}, applied)
+}
+
+func TestApplyWithOverrides(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithObjects(
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      "eu-1",
+			Namespace: ClusterSecretNamespace,
+			Labels:    map[string]string{LabelClusterCredentialType: "X509Certificate", "region": "eu"},
+		}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      "us-1",
+			Namespace: ClusterSecretNamespace,
+			Labels:    map[string]string{LabelClusterCredentialType: "X509Certificate", "region": "us"},
+		}},
+	).Build()
+	applied := map[string]*unstructured.Unstructured{}
+	prd := &provider{cli: cli, handlers: Handlers{Apply: func(ctx context.Context, cluster, owner string, manifests ...*unstructured.Unstructured) error {
+		applied[cluster] = manifests[0]
+		return nil
+	}}}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+
+	v, err := value.NewValue(`
+value: {
+	apiVersion: "apps/v1"
+	kind:       "Deployment"
+	metadata: name: "app"
+	spec: {
+		replicas: 1
+		template: spec: containers: [{name: "app", image: "app:v1"}]
+	}
+}
+cluster: ""
+clusterSelector: matchNames: ["local", "eu-1", "us-1"]
+overrides: [{
+	clusterSelector: matchLabels: region: "eu"
+	patch: spec: {
+		// +patchStrategy=retainKeys
+		replicas: 3
+	}
+}, {
+	cluster: "eu-1"
+	patch: spec: template: spec: {
+		// +patchKey=name
+		containers: [{
+			name: "app"
+			// +patchStrategy=retainKeys
+			image: "app:eu"
+		}]
+	}
+}, {
+	cluster: "local"
+	patch: spec: {
+		// +patchStrategy=retainKeys
+		replicas: 2
+	}
+}]
+`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.Apply(ctx, nil, v, nil))
+	replicas := func(cluster string) int64 {
+		n, _, err := unstructured.NestedInt64(applied[cluster].Object, "spec", "replicas")
+		r.NoError(err)
+		return n
+	}
+	r.Equal(int64(2), replicas("local"))
+	r.Equal(int64(3), replicas("eu-1"))
+	r.Equal(int64(1), replicas("us-1"))
+	containers, _, err := unstructured.NestedSlice(applied["eu-1"].Object, "spec", "template", "spec", "containers")
+	r.NoError(err)
+	r.Equal("app:eu", containers[0].(map[string]interface{})["image"])
+	image, err := v.GetString("manifests", "eu-1", "spec", "template", "spec", "containers", "0", "image")
+	r.NoError(err)
+	r.Equal("app:eu", image)
+	n, err := v.GetInt64("manifests", "us-1", "spec", "replicas")
+	r.NoError(err)
+	r.Equal(int64(1), n)
+
+	v, err = value.NewValue(`
+value: {apiVersion: "v1", kind: "ConfigMap", metadata: name: "config"}
+cluster: ""
+overrides: [{patch: data: key: "value"}]
+`, nil, "")
+	r.NoError(err)
+	err = prd.Apply(ctx, nil, v, nil)
+	r.Error(err)
+	r.Contains(err.Error(), "overrides[0] should have either cluster or clusterSelector")
+}
+
+func TestApplyInParallelWithOverrides(t *testing.T) {
+	r := require.New(t)
+	applied := map[string][]*unstructured.Unstructured{}
+	cli := fake.NewClientBuilder().WithObjects(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      "eu-1",
+		Namespace: ClusterSecretNamespace,
+		Labels:    map[string]string{LabelClusterCredentialType: "X509Certificate"},
+	}}).Build()
+	prd := &provider{cli: cli, handlers: Handlers{Apply: func(ctx context.Context, cluster, owner string, manifests ...*unstructured.Unstructured) error {
+		applied[cluster] = manifests
+		return nil
+	}}}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+
+	v, err := value.NewValue(`
+value: [{
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	metadata: name: "first"
+	data: key: "value"
+}, {
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	metadata: name: "second"
+	data: key: "value"
+}]
+cluster: ""
+clusterSelector: matchNames: ["local", "eu-1"]
+overrides: [{
+	cluster: "eu-1"
+	patch: data: {
+		// +patchStrategy=retainKeys
+		key: "eu"
+	}
+}]
+`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.ApplyInParallel(ctx, nil, v, nil))
+	data := func(obj *unstructured.Unstructured) string {
+		s, _, err := unstructured.NestedString(obj.Object, "data", "key")
+		r.NoError(err)
+		return s
+	}
+	r.Equal(2, len(applied["eu-1"]))
+	r.Equal("eu", data(applied["eu-1"][0]))
+	r.Equal("eu", data(applied["eu-1"][1]))
+	r.Equal("value", data(applied[multicluster.Local][0]))
+	r.Equal("value", data(applied[multicluster.Local][1]))
+	s, err := v.GetString("manifests", "eu-1", "1", "data", "key")
+	r.NoError(err)
+	r.Equal("eu", s)
+	s, err = v.GetString("manifests", "local", "0", "data", "key")
+	r.NoError(err)
+	r.Equal("value", s)
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue"
+	"github.com/kubevela/workflow/pkg/cue/model"
+	"github.com/kubevela/workflow/pkg/cue/model/sets"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// ApplyComponent applies the component stored in the workflow context, the workload is applied before the
+// auxiliaries. The step keeps waiting until the workload is healthy.
+func (h *provider) ApplyComponent(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	name, err := v.GetString("component")
+	if err != nil {
+		return err
+	}
+	component, err := wfCtx.GetComponent(name)
+	if err != nil {
+		return errors.WithMessagef(err, "load component %s", name)
+	}
+	cluster, err := v.GetString("cluster")
+	if err != nil {
+		return err
+	}
+
+	// patch a copy of the workload to keep the component in the workflow context unchanged
+	workloadInst, err := model.NewBase(component.Workload.Value())
+	if err != nil {
+		return err
+	}
+	var warnings []string
+	if pv, err := v.Field("patch"); err == nil && pv.Exists() {
+		if err := workloadInst.Unify(pv, sets.UnifyWithWarnings{Warnings: &warnings}); err != nil {
+			return errors.WithMessagef(err, "patch the workload of component %s", name)
+		}
+	}
+	workload, err := workloadInst.Unstructured()
+	if err != nil {
+		return err
+	}
+	h.prepare(workload)
+	auditTarget(act, cluster, workload.GetAPIVersion(), workload.GetKind(), workload.GetNamespace(), workload.GetName())
+	results, err := h.apply(ctx, wfCtx, cluster, false, workload)
+	if err != nil {
+		return errors.WithMessagef(err, "apply the workload %s %s of component %s", workload.GetKind(), workload.GetName(), name)
+	}
+
+	for i, aux := range component.Auxiliaries {
+		auxiliary, err := aux.Unstructured()
+		if err != nil {
+			return err
+		}
+		h.prepare(auxiliary)
+		auditTarget(act, cluster, auxiliary.GetAPIVersion(), auxiliary.GetKind(), auxiliary.GetNamespace(), auxiliary.GetName())
+		auxResults, err := h.apply(ctx, wfCtx, cluster, false, auxiliary)
+		if err != nil {
+			return errors.WithMessagef(err, "apply the auxiliary %d %s %s of component %s", i, auxiliary.GetKind(), auxiliary.GetName(), name)
+		}
+		results = append(results, auxResults...)
+	}
+	if err := fillApplyResults(v, results); err != nil {
+		return err
+	}
+	if len(warnings) > 0 {
+		if err := v.FillObject(warnings, "warnings"); err != nil {
+			return err
+		}
+	}
+
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(workload.GroupVersionKind())
+	if err := h.cli.Get(handleContext(ctx, cluster), client.ObjectKeyFromObject(workload), current); err != nil {
+		return errors.WithMessagef(clientError(err, "get", workload), "get the workload %s %s of component %s", workload.GetKind(), workload.GetName(), name)
+	}
+	healthy := isHealthy(current)
+	if !healthy {
+		act.Wait(fmt.Sprintf("waiting for the workload %s %s of component %s to be healthy", workload.GetKind(), workload.GetName(), name))
+	}
+	if err := v.FillObject(healthy, "healthy"); err != nil {
+		return err
+	}
+	return cue.FillUnstructuredObject(v, current, "workload")
+}
+
+// prepare sets the default namespace, the labels of the workflow run and the resource metadata on the object
+func (h *provider) prepare(obj *unstructured.Unstructured) {
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace("default")
+	}
+	labels := obj.GetLabels()
+	if labels == nil && len(h.labels) > 0 {
+		labels = map[string]string{}
+	}
+	for k, l := range h.labels {
+		labels[k] = l
+	}
+	obj.SetLabels(labels)
+	if h.metadata == nil {
+		return
+	}
+	resourceLabels := map[string]string{}
+	for k, l := range h.metadata.Labels {
+		resourceLabels[k] = l
+	}
+	if len(resourceLabels) > 0 && h.metadata.ProcessCtx != nil {
+		if step := h.metadata.ProcessCtx.GetData(model.ContextStepName); step != nil {
+			resourceLabels[types.LabelWorkflowRunStep] = fmt.Sprint(step)
+		}
+	}
+	obj.SetLabels(mergeMetadata(obj.GetLabels(), resourceLabels, h.metadata.Override))
+	obj.SetAnnotations(mergeMetadata(obj.GetAnnotations(), h.metadata.Annotations, h.metadata.Override))
+}
+
+// mergeMetadata merges the values into the existing metadata, the existing values are kept unless override is set
+func mergeMetadata(existing, values map[string]string, override bool) map[string]string {
+	if len(values) == 0 {
+		return existing
+	}
+	if existing == nil {
+		existing = map[string]string{}
+	}
+	for k, v := range values {
+		if _, found := existing[k]; found && !override {
+			continue
+		}
+		existing[k] = v
+	}
+	return existing
+}
+
+// isHealthy checks the health of the object by the common status fields: the observed generation catches up
+// with the generation, the ready replicas reach the desired replicas and the Ready condition is True if they exist.
+func isHealthy(obj *unstructured.Unstructured) bool {
+	if observed, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration"); found && observed < obj.GetGeneration() {
+		return false
+	}
+	if replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); found {
+		ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+		if ready < replicas {
+			return false
+		}
+	}
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		if condition, ok := c.(map[string]interface{}); ok && condition["type"] == defaultHealthConditionType {
+			return condition["status"] == "True"
+		}
+	}
+	return true
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/pkg/cue/model"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/cue/process"
+)
+
+func TestApplyComponent(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().Build()
+	var applied []string
+	var applyErr error
+	prd := &provider{cli: cli, labels: map[string]string{"workflowrun.oam.dev/name": "run"}, handlers: Handlers{Apply: func(ctx context.Context, cluster, owner string, manifests ...*unstructured.Unstructured) error {
+		for _, manifest := range manifests {
+			if applyErr != nil && manifest.GetKind() == "Service" {
+				return applyErr
+			}
+			applied = append(applied, manifest.GetKind())
+			existing := &unstructured.Unstructured{}
+			existing.SetGroupVersionKind(manifest.GroupVersionKind())
+			if err := cli.Get(ctx, client.ObjectKeyFromObject(manifest), existing); err != nil {
+				if !kerrors.IsNotFound(err) {
+					return err
+				}
+				if manifest.GetKind() == "Pod" {
+					r.NoError(unstructured.SetNestedSlice(manifest.Object, []interface{}{map[string]interface{}{"type": "Ready", "status": "False"}}, "status", "conditions"))
+				}
+				if err := cli.Create(ctx, manifest); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}}}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	wfCtx, err := newWorkflowContextForTest()
+	r.NoError(err)
+
+	act := &mockAction{}
+	v, err := value.NewValue(`
+component: "server"
+cluster:   ""
+patch: metadata: name: "app"
+`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.ApplyComponent(ctx, wfCtx, v, act))
+	r.Equal([]string{"Pod", "Service"}, applied)
+	r.True(act.wait)
+	healthy, err := v.GetBool("healthy")
+	r.NoError(err)
+	r.False(healthy)
+	pod := &unstructured.Unstructured{}
+	pod.SetAPIVersion("v1")
+	pod.SetKind("Pod")
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "app"}, pod))
+	r.Equal("run", pod.GetLabels()["workflowrun.oam.dev/name"])
+	// the component in the workflow context is not patched
+	component, err := wfCtx.GetComponent("server")
+	r.NoError(err)
+	workload, err := component.Workload.Unstructured()
+	r.NoError(err)
+	r.Equal("", workload.GetName())
+
+	r.NoError(unstructured.SetNestedSlice(pod.Object, []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}}, "status", "conditions"))
+	r.NoError(cli.Update(ctx, pod))
+	act = &mockAction{}
+	r.NoError(prd.ApplyComponent(ctx, wfCtx, v, act))
+	r.False(act.wait)
+
+	applyErr = errors.New("mock error")
+	err = prd.ApplyComponent(ctx, wfCtx, v, &mockAction{})
+	r.Error(err)
+	r.Contains(err.Error(), "apply the auxiliary 0 Service my-service of component server: mock error")
+
+	v, err = value.NewValue(`component: "not-exist", cluster: ""`, nil, "")
+	r.NoError(err)
+	err = prd.ApplyComponent(ctx, wfCtx, v, &mockAction{})
+	r.Error(err)
+	r.Contains(err.Error(), "load component not-exist")
+}
+
+func TestIsHealthy(t *testing.T) {
+	r := require.New(t)
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	r.True(isHealthy(obj))
+
+	obj.SetGeneration(2)
+	r.NoError(unstructured.SetNestedField(obj.Object, int64(1), "status", "observedGeneration"))
+	r.False(isHealthy(obj))
+	r.NoError(unstructured.SetNestedField(obj.Object, int64(2), "status", "observedGeneration"))
+	r.True(isHealthy(obj))
+
+	r.NoError(unstructured.SetNestedField(obj.Object, int64(3), "spec", "replicas"))
+	r.NoError(unstructured.SetNestedField(obj.Object, int64(2), "status", "readyReplicas"))
+	r.False(isHealthy(obj))
+	r.NoError(unstructured.SetNestedField(obj.Object, int64(3), "status", "readyReplicas"))
+	r.True(isHealthy(obj))
+
+	r.NoError(unstructured.SetNestedSlice(obj.Object, []interface{}{map[string]interface{}{"type": "Ready", "status": "False"}}, "status", "conditions"))
+	r.False(isHealthy(obj))
+}
+
+func TestPrepareResourceMetadata(t *testing.T) {
+	pCtx := process.NewContext(process.ContextData{})
+	pCtx.PushData(model.ContextStepName, "step-a")
+	testCases := map[string]struct {
+		metadata            *ResourceMetadata
+		labels              map[string]string
+		annotations         map[string]string
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+	}{
+		"no metadata": {
+			labels:         map[string]string{"app": "a"},
+			expectedLabels: map[string]string{"app": "a", "workflowrun.oam.dev/name": "run"},
+		},
+		"keep the existing values": {
+			metadata: &ResourceMetadata{
+				Labels:      map[string]string{"app": "b", "team": "t", "workflowrun.oam.dev/name": "other"},
+				Annotations: map[string]string{"owner": "o", "note": "n"},
+				ProcessCtx:  pCtx,
+			},
+			labels:              map[string]string{"app": "a"},
+			annotations:         map[string]string{"note": "x"},
+			expectedLabels:      map[string]string{"app": "a", "team": "t", "workflowrun.oam.dev/name": "run", "workflowrun.oam.dev/step": "step-a"},
+			expectedAnnotations: map[string]string{"owner": "o", "note": "x"},
+		},
+		"override the existing values": {
+			metadata: &ResourceMetadata{
+				Labels:      map[string]string{"app": "b"},
+				Annotations: map[string]string{"note": "n"},
+				Override:    true,
+			},
+			labels:              map[string]string{"app": "a"},
+			annotations:         map[string]string{"note": "x"},
+			expectedLabels:      map[string]string{"app": "b", "workflowrun.oam.dev/name": "run"},
+			expectedAnnotations: map[string]string{"note": "n"},
+		},
+		"annotations only": {
+			metadata: &ResourceMetadata{
+				Annotations: map[string]string{"note": "n"},
+				ProcessCtx:  pCtx,
+			},
+			expectedLabels:      map[string]string{"workflowrun.oam.dev/name": "run"},
+			expectedAnnotations: map[string]string{"note": "n"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			prd := &provider{labels: map[string]string{"workflowrun.oam.dev/name": "run"}, metadata: tc.metadata}
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			obj.SetLabels(tc.labels)
+			obj.SetAnnotations(tc.annotations)
+			prd.prepare(obj)
+			r.Equal("default", obj.GetNamespace())
+			r.Equal(tc.expectedLabels, obj.GetLabels())
+			r.Equal(tc.expectedAnnotations, obj.GetAnnotations())
+		})
+	}
+}
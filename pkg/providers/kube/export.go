@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// exportParams is the parameters of exporting data to the ConfigMap or the Secret
+type exportParams struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Data      map[string]string `json:"data"`
+	// Replace replaces the whole data of the existing object instead of merging the keys
+	Replace bool   `json:"replace"`
+	Cluster string `json:"cluster"`
+}
+
+// ExportToConfigMap creates the ConfigMap with the data or merges the data into the existing one.
+func (h *provider) ExportToConfigMap(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	return h.export(ctx, wfCtx, v, act, &corev1.ConfigMap{}, func(obj client.Object, data map[string]string, replace bool) {
+		cm := obj.(*corev1.ConfigMap)
+		if replace || cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		for k, d := range data {
+			cm.Data[k] = d
+		}
+	})
+}
+
+// ExportToSecret creates the Secret with the data or merges the data into the existing one,
+// the data is the plain text and encoded by base64 when it's stored.
+func (h *provider) ExportToSecret(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	return h.export(ctx, wfCtx, v, act, &corev1.Secret{}, func(obj client.Object, data map[string]string, replace bool) {
+		secret := obj.(*corev1.Secret)
+		if replace || secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		for k, d := range data {
+			secret.Data[k] = []byte(d)
+		}
+		// the stringData written by others is merged into data by the apiserver, clear it to avoid overwriting the data
+		secret.StringData = nil
+	})
+}
+
+func (h *provider) export(ctx context.Context, wfCtx wfContext.Context, v *value.Value, act types.Action, obj client.Object, setData func(obj client.Object, data map[string]string, replace bool)) error {
+	params := exportParams{}
+	if err := v.UnmarshalTo(&params, value.StrictFor(v, "resourceVersion")); err != nil {
+		return err
+	}
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	exportCtx := handleContext(ctx, params.Cluster)
+	resource := fmt.Sprintf("%s %s", reflect.TypeOf(obj).Elem().Name(), params.Name)
+	key := client.ObjectKey{Namespace: params.Namespace, Name: params.Name}
+	auditTarget(act, params.Cluster, "v1", reflect.TypeOf(obj).Elem().Name(), params.Namespace, params.Name)
+	if err := h.cli.Get(exportCtx, key, obj); err != nil {
+		if !errors.IsNotFound(err) {
+			return types.NewClientError(err, "get", resource, params.Namespace)
+		}
+		obj.SetNamespace(params.Namespace)
+		obj.SetName(params.Name)
+		obj.SetLabels(h.labels)
+		setData(obj, params.Data, true)
+		if err := h.cli.Create(exportCtx, obj); err != nil {
+			return types.NewClientError(err, "create", resource, params.Namespace)
+		}
+		if err := h.trackObject(wfCtx, params.Cluster, obj); err != nil {
+			return err
+		}
+		return v.FillObject(obj.GetResourceVersion(), "resourceVersion")
+	}
+	labels := obj.GetLabels()
+	if labels == nil && len(h.labels) > 0 {
+		labels = map[string]string{}
+	}
+	for k, l := range h.labels {
+		labels[k] = l
+	}
+	obj.SetLabels(labels)
+	setData(obj, params.Data, params.Replace)
+	if err := h.cli.Update(exportCtx, obj); err != nil {
+		return types.NewClientError(err, "update", resource, params.Namespace)
+	}
+	if err := h.trackObject(wfCtx, params.Cluster, obj); err != nil {
+		return err
+	}
+	return v.FillObject(obj.GetResourceVersion(), "resourceVersion")
+}
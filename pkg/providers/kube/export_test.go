@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+func TestExport(t *testing.T) {
+	cli := fake.NewClientBuilder().WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeconfig", Namespace: "default"},
+		Data:       map[string][]byte{"old": []byte("old")},
+	}).Build()
+	prd := &provider{cli: cli, labels: map[string]string{"workflowrun.oam.dev/name": "run"}}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	r := require.New(t)
+
+	v, err := value.NewValue(`name: "endpoints", namespace: "default", cluster: "", replace: false, data: {a: "1", b: "2"}`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.ExportToConfigMap(ctx, nil, v, nil))
+	rv, err := v.GetString("resourceVersion")
+	r.NoError(err)
+	cm := &corev1.ConfigMap{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "endpoints"}, cm))
+	r.Equal(map[string]string{"a": "1", "b": "2"}, cm.Data)
+	r.Equal("run", cm.Labels["workflowrun.oam.dev/name"])
+	r.Equal(cm.ResourceVersion, rv)
+
+	v, err = value.NewValue(`name: "endpoints", namespace: "default", cluster: "", replace: false, data: {b: "3", c: "4"}`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.ExportToConfigMap(ctx, nil, v, nil))
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "endpoints"}, cm))
+	r.Equal(map[string]string{"a": "1", "b": "3", "c": "4"}, cm.Data)
+	updated, err := v.GetString("resourceVersion")
+	r.NoError(err)
+	r.NotEqual(rv, updated)
+
+	v, err = value.NewValue(`name: "endpoints", namespace: "default", cluster: "", replace: true, data: {d: "5"}`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.ExportToConfigMap(ctx, nil, v, nil))
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "endpoints"}, cm))
+	r.Equal(map[string]string{"d": "5"}, cm.Data)
+
+	v, err = value.NewValue(`name: "kubeconfig", namespace: "default", cluster: "", replace: false, data: {config: "apiVersion: v1"}`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.ExportToSecret(ctx, nil, v, nil))
+	secret := &corev1.Secret{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "kubeconfig"}, secret))
+	r.Equal(map[string][]byte{"old": []byte("old"), "config": []byte("apiVersion: v1")}, secret.Data)
+	r.Equal("run", secret.Labels["workflowrun.oam.dev/name"])
+	rv, err = v.GetString("resourceVersion")
+	r.NoError(err)
+	r.Equal(secret.ResourceVersion, rv)
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/resourcetracker"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// GC deletes the objects applied by the last run of the same name but not applied by the current run,
+// the objects taken over by other runs are skipped. Nothing is deleted if the resources are not tracked
+// or the tracker of the last run is missing.
+func (h *provider) GC(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	name, namespace := h.labels[types.LabelWorkflowRunName], h.labels[types.LabelWorkflowRunNamespace]
+	if name == "" || namespace == "" || !h.tracking() {
+		return v.FillObject([]resourcetracker.Resource{}, "deleted")
+	}
+	last, err := resourcetracker.Load(ctx, h.cli, namespace, name)
+	if err != nil {
+		return err
+	}
+	tracked, err := resourcetracker.Tracked(wfCtx)
+	if err != nil {
+		return err
+	}
+	deleted, err := resourcetracker.Delete(ctx, h.cli, namespace, name, resourcetracker.Subtract(last, tracked))
+	if err != nil {
+		return err
+	}
+	if deleted == nil {
+		deleted = []resourcetracker.Resource{}
+	}
+	return v.FillObject(deleted, "deleted")
+}
+
+// tracking returns whether the applied objects are tracked
+func (h *provider) tracking() bool {
+	return h.metadata != nil && h.metadata.Track
+}
+
+// track records the objects applied to the cluster in the workflow context
+func (h *provider) track(wfCtx wfContext.Context, cluster string, objs ...*unstructured.Unstructured) error {
+	if wfCtx == nil || !h.tracking() {
+		return nil
+	}
+	resources := make([]resourcetracker.Resource, len(objs))
+	for i, obj := range objs {
+		resources[i] = resourcetracker.ResourceOf(obj, cluster)
+	}
+	return resourcetracker.Record(wfCtx, resources...)
+}
+
+// trackObject records the typed object applied to the cluster in the workflow context
+func (h *provider) trackObject(wfCtx wfContext.Context, cluster string, obj client.Object) error {
+	if wfCtx == nil || !h.tracking() {
+		return nil
+	}
+	gvk, err := apiutil.GVKForObject(obj, h.cli.Scheme())
+	if err != nil {
+		return err
+	}
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	u.SetNamespace(obj.GetNamespace())
+	u.SetName(obj.GetName())
+	return h.track(wfCtx, cluster, u)
+}
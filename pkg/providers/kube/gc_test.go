@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/resourcetracker"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestGC(t *testing.T) {
+	r := require.New(t)
+	labels := map[string]string{
+		types.LabelWorkflowRunName:      "run",
+		types.LabelWorkflowRunNamespace: "default",
+	}
+	cli := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "kept", Namespace: "default", Labels: labels}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "pruned", Namespace: "default", Labels: labels}},
+	).Build()
+	prd := &provider{cli: cli, labels: labels, metadata: &ResourceMetadata{Track: true}, handlers: Handlers{Apply: func(ctx context.Context, cluster, owner string, manifests ...*unstructured.Unstructured) error {
+		return nil
+	}}}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	wfCtx, err := newWorkflowContextForTest()
+	r.NoError(err)
+
+	kept := resourcetracker.Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "kept"}
+	pruned := resourcetracker.Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "pruned"}
+	r.NoError(resourcetracker.Save(ctx, cli, "default", "run", nil, []resourcetracker.Resource{kept, pruned}))
+
+	v, err := value.NewValue(`
+value: {
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	metadata: name: "kept"
+}
+cluster: ""
+`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.Apply(ctx, wfCtx, v, nil))
+	tracked, err := resourcetracker.Tracked(wfCtx)
+	r.NoError(err)
+	r.Equal([]resourcetracker.Resource{kept}, tracked)
+
+	v, err = value.NewValue(`{}`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.GC(ctx, wfCtx, v, nil))
+	dv, err := v.LookupValue("deleted")
+	r.NoError(err)
+	var deleted []resourcetracker.Resource
+	r.NoError(dv.UnmarshalTo(&deleted))
+	r.Equal([]resourcetracker.Resource{pruned}, deleted)
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "kept"}, &corev1.ConfigMap{}))
+	err = cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "pruned"}, &corev1.ConfigMap{})
+	r.True(kerrors.IsNotFound(err))
+}
+
+func TestGCWithoutTracker(t *testing.T) {
+	r := require.New(t)
+	labels := map[string]string{
+		types.LabelWorkflowRunName:      "run",
+		types.LabelWorkflowRunNamespace: "default",
+	}
+	cli := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default", Labels: labels}},
+	).Build()
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	cm := resourcetracker.Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "cm"}
+	apply := func(ctx context.Context, cluster, owner string, manifests ...*unstructured.Unstructured) error {
+		return nil
+	}
+
+	testCases := map[string]struct {
+		metadata *ResourceMetadata
+		saved    bool
+	}{
+		"not tracked": {
+			saved: true,
+		},
+		"tracker missing": {
+			metadata: &ResourceMetadata{Track: true},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r.NoError(resourcetracker.DeleteTracker(ctx, cli, "default", "run"))
+			if tc.saved {
+				r.NoError(resourcetracker.Save(ctx, cli, "default", "run", nil, []resourcetracker.Resource{cm}))
+			}
+			prd := &provider{cli: cli, labels: labels, metadata: tc.metadata, handlers: Handlers{Apply: apply}}
+			wfCtx, err := newWorkflowContextForTest()
+			r.NoError(err)
+			v, err := value.NewValue(`{}`, nil, "")
+			r.NoError(err)
+			r.NoError(prd.GC(ctx, wfCtx, v, nil))
+			dv, err := v.LookupValue("deleted")
+			r.NoError(err)
+			var deleted []resourcetracker.Resource
+			r.NoError(dv.UnmarshalTo(&deleted))
+			r.Empty(deleted)
+			r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "cm"}, &corev1.ConfigMap{}))
+		})
+	}
+}
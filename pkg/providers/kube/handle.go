@@ -18,11 +18,16 @@ package kube
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
@@ -30,10 +35,13 @@ import (
 	"github.com/kubevela/pkg/util/k8s"
 	"github.com/kubevela/pkg/util/k8s/patch"
 
+	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/cue"
 	"github.com/kubevela/workflow/pkg/cue/model"
+	"github.com/kubevela/workflow/pkg/cue/model/sets"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/cue/process"
 	"github.com/kubevela/workflow/pkg/types"
 )
 
@@ -63,10 +71,25 @@ type filters struct {
 	MatchingLabels map[string]string `json:"matchingLabels"`
 }
 
+// ResourceMetadata is the labels and annotations merged into the metadata of the applied objects
+type ResourceMetadata struct {
+	Labels      map[string]string
+	Annotations map[string]string
+	// Override overrides the existing values of the objects
+	Override bool
+	// Track records the applied objects in the workflow context for the gc
+	Track bool
+	// ProcessCtx provides the name of the running step, which is labeled on the objects if the labels are set
+	ProcessCtx process.Context
+}
+
 type provider struct {
 	labels   map[string]string
+	metadata *ResourceMetadata
 	handlers Handlers
 	cli      client.Client
+	// kubeClient reads the logs of the pods, the logs are not read if it's nil
+	kubeClient kubernetes.Interface
 }
 
 const (
@@ -100,10 +123,10 @@ func (d *dispatcher) apply(ctx context.Context, cluster, owner string, workloads
 					return err
 				}
 				if err := d.cli.Create(ctx, workload); err != nil {
-					return err
+					return clientError(err, "create", workload)
 				}
 			} else {
-				return err
+				return clientError(err, "get", workload)
 			}
 		} else {
 			patcher, err := patch.ThreeWayMergePatch(existing, workload, &patch.PatchAction{
@@ -115,7 +138,7 @@ func (d *dispatcher) apply(ctx context.Context, cluster, owner string, workloads
 				return err
 			}
 			if err := d.cli.Patch(ctx, workload, patcher); err != nil {
-				return err
+				return clientError(err, "patch", workload)
 			}
 		}
 	}
@@ -123,7 +146,7 @@ func (d *dispatcher) apply(ctx context.Context, cluster, owner string, workloads
 }
 
 func (d *dispatcher) delete(ctx context.Context, cluster, owner string, manifest *unstructured.Unstructured) error {
-	return d.cli.Delete(ctx, manifest)
+	return clientError(d.cli.Delete(ctx, manifest), "delete", manifest)
 }
 
 // Apply create or update CR in cluster.
@@ -133,6 +156,7 @@ func (h *provider) Apply(ctx monitorContext.Context, wfCtx wfContext.Context, v
 		return err
 	}
 	var workload = new(unstructured.Unstructured)
+	var warnings []string
 	pv, err := v.Field("patch")
 	if pv.Exists() && err == nil {
 		base, err := model.NewBase(val.CueValue())
@@ -140,7 +164,7 @@ func (h *provider) Apply(ctx monitorContext.Context, wfCtx wfContext.Context, v
 			return err
 		}
 
-		if err := base.Unify(pv); err != nil {
+		if err := base.Unify(pv, sets.UnifyWithWarnings{Warnings: &warnings}); err != nil {
 			return err
 		}
 		workload, err = base.Unstructured()
@@ -150,19 +174,104 @@ func (h *provider) Apply(ctx monitorContext.Context, wfCtx wfContext.Context, v
 	} else if err := val.UnmarshalTo(workload); err != nil {
 		return err
 	}
-	if workload.GetNamespace() == "" {
-		workload.SetNamespace("default")
-	}
-	workload.SetLabels(h.labels)
+	h.prepare(workload)
 	cluster, err := v.GetString("cluster")
 	if err != nil {
 		return err
 	}
-	deployCtx := handleContext(ctx, cluster)
-	if err := h.handlers.Apply(deployCtx, cluster, WorkflowResourceCreator, workload); err != nil {
+	clusters, selected, err := h.selectClusters(ctx, v, cluster)
+	if err != nil {
+		return err
+	}
+	overrides, err := h.parseOverrides(ctx, v)
+	if err != nil {
+		return err
+	}
+	rollout, err := newRollout(v, wfCtx, workload, clusters)
+	if err != nil {
+		return err
+	}
+	targets := clusters
+	if rollout != nil {
+		targets = rollout.current()
+	}
+	var results []applyResult
+	var applied *unstructured.Unstructured
+	manifests := map[string]interface{}{}
+	for _, cluster := range targets {
+		obj, err := overrides.apply(v, workload, cluster, &warnings)
+		if err != nil {
+			return err
+		}
+		if multicluster.IsLocal(cluster) {
+			manifests[multicluster.Local] = obj.DeepCopy().Object
+		} else {
+			manifests[cluster] = obj.DeepCopy().Object
+		}
+		auditTarget(act, cluster, obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		clusterResults, err := h.apply(ctx, wfCtx, cluster, selected, obj)
+		if err != nil {
+			if rollout != nil {
+				return rollout.fail(v, act, cluster, err)
+			}
+			return err
+		}
+		results = append(results, clusterResults...)
+		if applied == nil {
+			applied = obj
+		}
+	}
+	if err := fillApplyResults(v, results); err != nil {
 		return err
 	}
-	return cue.FillUnstructuredObject(v, workload, "value")
+	if selected {
+		if err := v.FillObject(clusters, "clusters"); err != nil {
+			return err
+		}
+	}
+	// record the final manifests of the clusters for troubleshooting the overrides
+	if overrides != nil {
+		if err := v.FillObject(manifests, "manifests"); err != nil {
+			return err
+		}
+	}
+	if len(warnings) > 0 {
+		if err := v.FillObject(warnings, "warnings"); err != nil {
+			return err
+		}
+	}
+	if rollout != nil {
+		if err := rollout.gate(ctx, h, v, act, workload); err != nil {
+			return err
+		}
+	}
+	if applied == nil {
+		return nil
+	}
+	return cue.FillUnstructuredObject(v, applied, "value")
+}
+
+// apply applies the objects to the cluster and returns the results, the cluster is recorded
+// in the results if the clusters are selected by the cluster selector. The applied objects are
+// recorded in the workflow context for the garbage collection.
+func (h *provider) apply(ctx context.Context, wfCtx wfContext.Context, cluster string, selected bool, workloads ...*unstructured.Unstructured) ([]applyResult, error) {
+	deployCtx := handleContext(ctx, cluster)
+	results, err := h.diffApplyResults(deployCtx, workloads...)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.handlers.Apply(deployCtx, cluster, WorkflowResourceCreator, workloads...); err != nil {
+		return nil, err
+	}
+	if err := h.track(wfCtx, cluster, workloads...); err != nil {
+		return nil, err
+	}
+	if selected {
+		for i := range results {
+			results[i].Cluster = cluster
+		}
+	}
+	return results, nil
 }
 
 // ApplyInParallel create or update CRs in parallel.
@@ -184,23 +293,78 @@ func (h *provider) ApplyInParallel(ctx monitorContext.Context, wfCtx wfContext.C
 		return err
 	}
 	for i := range workloads {
-		if workloads[i].GetNamespace() == "" {
-			workloads[i].SetNamespace("default")
-		}
+		h.prepare(workloads[i])
 	}
 	cluster, err := v.GetString("cluster")
 	if err != nil {
 		return err
 	}
-	deployCtx := handleContext(ctx, cluster)
-	if err := h.handlers.Apply(deployCtx, cluster, WorkflowResourceCreator, workloads...); err != nil {
+	clusters, selected, err := h.selectClusters(ctx, v, cluster)
+	if err != nil {
 		return err
 	}
-	return nil
+	overrides, err := h.parseOverrides(ctx, v)
+	if err != nil {
+		return err
+	}
+	var results []applyResult
+	var warnings []string
+	manifests := map[string]interface{}{}
+	for _, cluster := range clusters {
+		objs := make([]*unstructured.Unstructured, len(workloads))
+		clusterManifests := make([]interface{}, len(workloads))
+		for i := range workloads {
+			if objs[i], err = overrides.apply(v, workloads[i], cluster, &warnings); err != nil {
+				return err
+			}
+			clusterManifests[i] = objs[i].DeepCopy().Object
+			auditTarget(act, cluster, objs[i].GetAPIVersion(), objs[i].GetKind(), objs[i].GetNamespace(), objs[i].GetName())
+		}
+		if multicluster.IsLocal(cluster) {
+			manifests[multicluster.Local] = clusterManifests
+		} else {
+			manifests[cluster] = clusterManifests
+		}
+		clusterResults, err := h.apply(ctx, wfCtx, cluster, selected, objs...)
+		if err != nil {
+			return err
+		}
+		results = append(results, clusterResults...)
+	}
+	if selected {
+		if err := v.FillObject(clusters, "clusters"); err != nil {
+			return err
+		}
+	}
+	// record the final manifests of the clusters for troubleshooting the overrides
+	if overrides != nil {
+		if err := v.FillObject(manifests, "manifests"); err != nil {
+			return err
+		}
+	}
+	if len(warnings) > 0 {
+		if err := v.FillObject(warnings, "warnings"); err != nil {
+			return err
+		}
+	}
+	return fillApplyResults(v, results)
+}
+
+// objectRef refers to an object to read
+type objectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	// Key is the key of the object in the values and the errors, default to the name
+	Key string `json:"key"`
 }
 
 // Read get CR from cluster.
 func (h *provider) Read(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	if objects, err := v.LookupValue("objects"); err == nil {
+		return h.readObjects(ctx, v, act, objects)
+	}
 	val, err := v.LookupValue("value")
 	if err != nil {
 		return err
@@ -217,13 +381,192 @@ func (h *provider) Read(ctx monitorContext.Context, wfCtx wfContext.Context, v *
 	if err != nil {
 		return err
 	}
+	auditTarget(act, cluster, obj.GetAPIVersion(), obj.GetKind(), key.Namespace, key.Name)
 	readCtx := handleContext(ctx, cluster)
 	if err := h.cli.Get(readCtx, key, obj); err != nil {
+		obj.SetNamespace(key.Namespace)
+		if clientErr, ok := types.AsTerminalClientError(clientError(err, "get", obj)); ok {
+			return clientErr
+		}
+		if err := v.FillObject(errorType(err), "errType"); err != nil {
+			return err
+		}
 		return v.FillObject(err.Error(), "err")
 	}
 	return cue.FillUnstructuredObject(v, obj, "value")
 }
 
+// readObjects reads the objects and fills them into `values` keyed by their keys, the errors of
+// reading the objects are filled into `errors` so that the template decides which ones are fatal.
+func (h *provider) readObjects(ctx monitorContext.Context, v *value.Value, act types.Action, objects *value.Value) error {
+	var refs []objectRef
+	if err := objects.UnmarshalTo(&refs); err != nil {
+		return err
+	}
+	cluster, err := v.GetString("cluster")
+	if err != nil {
+		return err
+	}
+	readCtx := handleContext(ctx, cluster)
+	values := map[string]interface{}{}
+	readErrors := map[string]string{}
+	keys := map[string]bool{}
+	for _, ref := range refs {
+		key := ref.Key
+		if key == "" {
+			key = ref.Name
+		}
+		if keys[key] {
+			return fmt.Errorf("duplicate key %s in objects, set the key of the objects to distinguish them", key)
+		}
+		keys[key] = true
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		obj := new(unstructured.Unstructured)
+		obj.SetAPIVersion(ref.APIVersion)
+		obj.SetKind(ref.Kind)
+		auditTarget(act, cluster, ref.APIVersion, ref.Kind, namespace, ref.Name)
+		if err := h.cli.Get(readCtx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, obj); err != nil {
+			obj.SetNamespace(namespace)
+			obj.SetName(ref.Name)
+			if clientErr, ok := types.AsTerminalClientError(clientError(err, "get", obj)); ok {
+				return clientErr
+			}
+			readErrors[key] = err.Error()
+			continue
+		}
+		values[key] = obj.Object
+	}
+	if err := v.FillObject(values, "values"); err != nil {
+		return err
+	}
+	return v.FillObject(readErrors, "errors")
+}
+
+// Patch patches the CR in cluster with the merge, json or strategic merge patch.
+func (h *provider) Patch(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	r, err := v.LookupValue("resource")
+	if err != nil {
+		return err
+	}
+	var ref objectRef
+	if err := r.UnmarshalTo(&ref); err != nil {
+		return err
+	}
+	if ref.Namespace == "" {
+		ref.Namespace = "default"
+	}
+	patchType, err := v.GetString("patchType")
+	if err != nil {
+		return err
+	}
+	pv, err := v.LookupValue("patch")
+	if err != nil {
+		return err
+	}
+	data, err := pv.CueValue().MarshalJSON()
+	if err != nil {
+		return err
+	}
+	var patcher client.Patch
+	switch patchType {
+	case "merge":
+		patcher = client.RawPatch(ktypes.MergePatchType, data)
+	case "json":
+		patcher = client.RawPatch(ktypes.JSONPatchType, data)
+	case "strategic":
+		patcher = client.RawPatch(ktypes.StrategicMergePatchType, data)
+	default:
+		return fmt.Errorf("unsupported patch type %s", patchType)
+	}
+	cluster, err := v.GetString("cluster")
+	if err != nil {
+		return err
+	}
+	obj := new(unstructured.Unstructured)
+	obj.SetAPIVersion(ref.APIVersion)
+	obj.SetKind(ref.Kind)
+	obj.SetNamespace(ref.Namespace)
+	obj.SetName(ref.Name)
+	auditTarget(act, cluster, ref.APIVersion, ref.Kind, ref.Namespace, ref.Name)
+	patchCtx := handleContext(ctx, cluster)
+	if err := h.cli.Patch(patchCtx, obj, patcher); err != nil {
+		if clientErr, ok := types.AsTerminalClientError(clientError(err, "patch", obj)); ok {
+			return clientErr
+		}
+		if err := v.FillObject(errorType(err), "errType"); err != nil {
+			return err
+		}
+		return v.FillObject(err.Error(), "err")
+	}
+	return cue.FillUnstructuredObject(v, obj, "value")
+}
+
+const (
+	// PatchErrorNotFound means the object to patch or read is not found
+	PatchErrorNotFound = "NotFound"
+	// PatchErrorTestFailed means the test operation in the json patch failed
+	PatchErrorTestFailed = "TestFailed"
+	// PatchErrorUnknown is the other errors of the patch or the read
+	PatchErrorUnknown = "Unknown"
+)
+
+// clientError classifies the error of the client call on the object, the forbidden and the invalid errors fail
+// the step immediately while the others are retried.
+func clientError(err error, verb string, obj *unstructured.Unstructured) error {
+	return types.NewClientError(err, verb, fmt.Sprintf("%s %s", obj.GetKind(), obj.GetName()), obj.GetNamespace())
+}
+
+// auditTarget records the object as the target of the op in the audit log, the content of the object
+// is never recorded as it may carry the credentials.
+func auditTarget(act types.Action, cluster, apiVersion, kind, namespace, name string) {
+	types.RecordAuditTarget(act, types.AuditTarget{
+		"cluster":    cluster,
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"namespace":  namespace,
+		"name":       name,
+	})
+}
+
+// watchObject records the object watched by the waiting op, the objects in the managed clusters are not watched
+// and the step waiting on them is reconciled with the periodic backoff.
+func watchObject(act types.Action, cluster, apiVersion, kind, namespace, name string) {
+	if !multicluster.IsLocal(cluster) {
+		return
+	}
+	types.RecordWatch(act, v1alpha1.WatchedObject{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  namespace,
+		Name:       name,
+	})
+}
+
+// scopedNamespace returns the namespace of the object of the kind, which is empty for the cluster-scoped kinds so
+// that the object matches the events of it. The kinds failed to map are regarded as namespaced.
+func (h *provider) scopedNamespace(apiVersion, kind, namespace string) string {
+	gvk := schema.FromAPIVersionAndKind(apiVersion, kind)
+	mapping, err := h.cli.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err == nil && mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return ""
+	}
+	return namespace
+}
+
+func errorType(err error) string {
+	switch {
+	case errors.IsNotFound(err):
+		return PatchErrorNotFound
+	case strings.Contains(err.Error(), "test failed"):
+		return PatchErrorTestFailed
+	default:
+		return PatchErrorUnknown
+	}
+}
+
 // List lists CRs from cluster.
 func (h *provider) List(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
 	r, err := v.LookupValue("resource")
@@ -244,7 +587,7 @@ func (h *provider) List(ctx monitorContext.Context, wfCtx wfContext.Context, v *
 		return err
 	}
 	filter := &filters{}
-	if err := filterValue.UnmarshalTo(filter); err != nil {
+	if err := filterValue.UnmarshalTo(filter, value.StrictFor(v)); err != nil {
 		return err
 	}
 	cluster, err := v.GetString("cluster")
@@ -255,8 +598,12 @@ func (h *provider) List(ctx monitorContext.Context, wfCtx wfContext.Context, v *
 		client.InNamespace(filter.Namespace),
 		client.MatchingLabels(filter.MatchingLabels),
 	}
+	auditTarget(act, cluster, resource.APIVersion, resource.Kind, filter.Namespace, "")
 	readCtx := handleContext(ctx, cluster)
 	if err := h.cli.List(readCtx, list, listOpts...); err != nil {
+		if clientErr, ok := types.AsTerminalClientError(types.NewClientError(err, "list", resource.Kind, filter.Namespace)); ok {
+			return clientErr
+		}
 		return v.FillObject(err.Error(), "err")
 	}
 	return cue.FillUnstructuredObject(v, list, "list")
@@ -280,28 +627,36 @@ func (h *provider) Delete(ctx monitorContext.Context, wfCtx wfContext.Context, v
 
 	if filterValue, err := v.LookupValue("filter"); err == nil {
 		filter := &filters{}
-		if err := filterValue.UnmarshalTo(filter); err != nil {
+		if err := filterValue.UnmarshalTo(filter, value.StrictFor(v)); err != nil {
 			return err
 		}
+		auditTarget(act, cluster, obj.GetAPIVersion(), obj.GetKind(), filter.Namespace, "")
 		labelSelector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{MatchLabels: filter.MatchingLabels})
 		if err != nil {
 			return err
 		}
 		if err := h.cli.DeleteAllOf(deleteCtx, obj, &client.DeleteAllOfOptions{ListOptions: client.ListOptions{Namespace: filter.Namespace, LabelSelector: labelSelector}}); err != nil {
+			if clientErr, ok := types.AsTerminalClientError(types.NewClientError(err, "deletecollection", obj.GetKind(), filter.Namespace)); ok {
+				return clientErr
+			}
 			return v.FillObject(err.Error(), "err")
 		}
 		return nil
 	}
 
+	auditTarget(act, cluster, obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName())
 	if err := h.handlers.Delete(deleteCtx, cluster, WorkflowResourceCreator, obj); err != nil {
+		if clientErr, ok := types.AsTerminalClientError(clientError(err, "delete", obj)); ok {
+			return clientErr
+		}
 		return v.FillObject(err.Error(), "err")
 	}
 
 	return nil
 }
 
-// Install register handlers to provider discover.
-func Install(p types.Providers, cli client.Client, labels map[string]string, handlers *Handlers) {
+// Install register handlers to provider discover, the kube client reads the logs of the pods and can be nil.
+func Install(p types.Providers, cli client.Client, kubeClient kubernetes.Interface, labels map[string]string, handlers *Handlers, metadata *ResourceMetadata) {
 	if handlers == nil {
 		d := &dispatcher{
 			cli: cli,
@@ -312,15 +667,25 @@ func Install(p types.Providers, cli client.Client, labels map[string]string, han
 		}
 	}
 	prd := &provider{
-		cli:      cli,
-		handlers: *handlers,
-		labels:   labels,
+		cli:        cli,
+		kubeClient: kubeClient,
+		handlers:   *handlers,
+		labels:     labels,
+		metadata:   metadata,
 	}
 	p.Register(ProviderName, map[string]types.Handler{
 		"apply":             prd.Apply,
 		"apply-in-parallel": prd.ApplyInParallel,
 		"read":              prd.Read,
+		"wait-all":          prd.WaitAll,
+		"wait-job":          prd.WaitJob,
+		"patch":             prd.Patch,
 		"list":              prd.List,
 		"delete":            prd.Delete,
+		"export2config":     prd.ExportToConfigMap,
+		"export2secret":     prd.ExportToSecret,
+		"gc":                prd.GC,
+		"prune":             prd.Prune,
+		"apply-component":   prd.ApplyComponent,
 	})
 }
@@ -0,0 +1,195 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// JobPhaseComplete means the job is completed successfully
+	JobPhaseComplete = "Complete"
+	// JobPhaseFailed means the job is failed, the reason tells whether the backoff limit or the deadline is exceeded
+	JobPhaseFailed = "Failed"
+	// maxJobLogsBytes is the max size of the logs of the job filled into the output and the message of the step
+	maxJobLogsBytes = 4096
+)
+
+// waitJobParams is the parameters of waiting for the job to complete
+type waitJobParams struct {
+	Cluster   string `json:"cluster"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// TailLines is the number of the last lines of the logs of the terminated container to read
+	TailLines int64 `json:"tailLines"`
+}
+
+// waitJobResult is the result of the finished job
+type waitJobResult struct {
+	Phase     string `json:"phase"`
+	Reason    string `json:"reason,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+	Container string `json:"container,omitempty"`
+	ExitCode  *int32 `json:"exitCode,omitempty"`
+	Logs      string `json:"logs,omitempty"`
+}
+
+// WaitJob waits until the job is completed or failed, the exit code and the last lines of the logs of the terminated
+// container are filled into the result. The step fails with the reason of the job, e.g. BackoffLimitExceeded or
+// DeadlineExceeded, if the job is failed.
+func (h *provider) WaitJob(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	params := &waitJobParams{}
+	if err := v.UnmarshalTo(params, value.StrictFor(v, "result")); err != nil {
+		return err
+	}
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	auditTarget(act, params.Cluster, "batch/v1", "Job", params.Namespace, params.Name)
+	watchObject(act, params.Cluster, "batch/v1", "Job", params.Namespace, params.Name)
+	readCtx := handleContext(ctx, params.Cluster)
+	job := &batchv1.Job{}
+	if err := h.cli.Get(readCtx, client.ObjectKey{Namespace: params.Namespace, Name: params.Name}, job); err != nil {
+		if kerrors.IsNotFound(err) {
+			act.Wait(fmt.Sprintf("waiting for job %s to be created", params.Name))
+			return nil
+		}
+		return types.NewClientError(err, "get", "Job "+params.Name, params.Namespace)
+	}
+	result := &waitJobResult{}
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			result.Phase = JobPhaseComplete
+		case batchv1.JobFailed:
+			result.Phase = JobPhaseFailed
+			result.Reason = cond.Reason
+			result.Message = cond.Message
+		}
+	}
+	if result.Phase == "" {
+		act.Wait(fmt.Sprintf("waiting for job %s to complete: %d active, %d succeeded, %d failed",
+			params.Name, job.Status.Active, job.Status.Succeeded, job.Status.Failed))
+		return nil
+	}
+	if err := h.readJobTermination(readCtx, job, params.TailLines, result); err != nil {
+		return err
+	}
+	if err := v.FillObject(result, "result"); err != nil {
+		return err
+	}
+	if result.Phase == JobPhaseFailed {
+		reason := result.Reason
+		if reason == "" {
+			reason = JobPhaseFailed
+		}
+		types.FailWithReason(act, reason, jobFailureMessage(params.Name, result))
+	}
+	return nil
+}
+
+// readJobTermination finds the terminated container of the latest pod of the job, the failed container is preferred
+// if the job is failed, and reads its exit code and the last lines of its logs.
+func (h *provider) readJobTermination(ctx context.Context, job *batchv1.Job, tailLines int64, result *waitJobResult) error {
+	selector := client.MatchingLabels{"job-name": job.Name}
+	if job.Spec.Selector != nil {
+		selector = job.Spec.Selector.MatchLabels
+	}
+	pods := &corev1.PodList{}
+	if err := h.cli.List(ctx, pods, client.InNamespace(job.Namespace), selector); err != nil {
+		return types.NewClientError(err, "list", "Pod", job.Namespace)
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[j].CreationTimestamp.Before(&pods.Items[i].CreationTimestamp)
+	})
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.State.Terminated
+			if terminated == nil || (result.Phase == JobPhaseFailed && terminated.ExitCode == 0) {
+				continue
+			}
+			exitCode := terminated.ExitCode
+			result.Pod = pod.Name
+			result.Container = status.Name
+			result.ExitCode = &exitCode
+			result.Logs = h.readJobLogs(ctx, pod.Namespace, pod.Name, status.Name, tailLines)
+			return nil
+		}
+	}
+	return nil
+}
+
+// readJobLogs reads the last lines of the logs of the container, the error of reading them is returned as the logs
+// since the logs are only informative.
+func (h *provider) readJobLogs(ctx context.Context, namespace, pod, container string, tailLines int64) string {
+	if h.kubeClient == nil || tailLines <= 0 {
+		return ""
+	}
+	limit := int64(maxJobLogsBytes)
+	stream, err := h.kubeClient.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container:  container,
+		TailLines:  &tailLines,
+		LimitBytes: &limit,
+	}).Stream(ctx)
+	if err != nil {
+		return "logs unavailable: " + err.Error()
+	}
+	//nolint:errcheck
+	defer stream.Close()
+	logs, err := io.ReadAll(io.LimitReader(stream, limit))
+	if err != nil {
+		return "logs unavailable: " + err.Error()
+	}
+	return strings.TrimSuffix(string(logs), "\n")
+}
+
+func jobFailureMessage(name string, result *waitJobResult) string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "job %s failed", name)
+	if result.Reason != "" {
+		fmt.Fprintf(sb, " with %s", result.Reason)
+	}
+	if result.Message != "" {
+		fmt.Fprintf(sb, ": %s", result.Message)
+	}
+	if result.ExitCode != nil {
+		fmt.Fprintf(sb, ", container %s of pod %s exited with %d", result.Container, result.Pod, *result.ExitCode)
+	}
+	if result.Logs != "" {
+		fmt.Fprintf(sb, ", last logs:\n%s", result.Logs)
+	}
+	return sb.String()
+}
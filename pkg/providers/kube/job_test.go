@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+// reasonedAction records the reason of the failure
+type reasonedAction struct {
+	mockAction
+	reason string
+}
+
+func (act *reasonedAction) FailWithReason(reason, message string) {
+	act.Fail(message)
+	act.reason = reason
+}
+
+func TestWaitJob(t *testing.T) {
+	job := func(name string, conds ...batchv1.JobCondition) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       batchv1.JobSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"job": name}}},
+			Status:     batchv1.JobStatus{Conditions: conds, Active: 1},
+		}
+	}
+	pod := func(name, jobName string, created int64, exitCode int32) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				Labels:            map[string]string{"job": jobName},
+				CreationTimestamp: metav1.Unix(created, 0),
+			},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "main",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}},
+			}}},
+		}
+	}
+	cli := fake.NewClientBuilder().WithObjects(
+		job("running"),
+		job("complete", batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}),
+		job("backoff", batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue,
+			Reason: "BackoffLimitExceeded", Message: "Job has reached the specified backoff limit"}),
+		job("deadline", batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue,
+			Reason: "DeadlineExceeded", Message: "Job was active longer than specified deadline"}),
+		pod("complete-0", "complete", 1, 0),
+		pod("backoff-0", "backoff", 1, 2),
+		pod("backoff-1", "backoff", 2, 1),
+		pod("backoff-2", "backoff", 3, 0),
+	).Build()
+	prd := &provider{cli: cli, kubeClient: kubefake.NewSimpleClientset()}
+	testCases := map[string]struct {
+		name    string
+		wait    bool
+		fail    bool
+		reason  string
+		message string
+		result  *waitJobResult
+	}{
+		"not found": {
+			name:    "missing",
+			wait:    true,
+			message: "waiting for job missing to be created",
+		},
+		"running": {
+			name:    "running",
+			wait:    true,
+			message: "waiting for job running to complete: 1 active, 0 succeeded, 0 failed",
+		},
+		"complete": {
+			name:   "complete",
+			result: &waitJobResult{Phase: JobPhaseComplete, Pod: "complete-0", Container: "main", ExitCode: pointer.Int32(0), Logs: "fake logs"},
+		},
+		"backoff limit exceeded": {
+			name:    "backoff",
+			fail:    true,
+			reason:  "BackoffLimitExceeded",
+			message: "job backoff failed with BackoffLimitExceeded: Job has reached the specified backoff limit, container main of pod backoff-1 exited with 1, last logs:\nfake logs",
+			result: &waitJobResult{Phase: JobPhaseFailed, Reason: "BackoffLimitExceeded", Message: "Job has reached the specified backoff limit",
+				Pod: "backoff-1", Container: "main", ExitCode: pointer.Int32(1), Logs: "fake logs"},
+		},
+		"deadline exceeded": {
+			name:    "deadline",
+			fail:    true,
+			reason:  "DeadlineExceeded",
+			message: "job deadline failed with DeadlineExceeded: Job was active longer than specified deadline",
+			result:  &waitJobResult{Phase: JobPhaseFailed, Reason: "DeadlineExceeded", Message: "Job was active longer than specified deadline"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			v, err := value.NewValue(`cluster: "", namespace: "default", tailLines: 20, name: "`+tc.name+`"`, nil, "")
+			r.NoError(err)
+			act := &reasonedAction{}
+			ctx := monitorContext.NewTraceContext(context.Background(), "")
+			r.NoError(prd.WaitJob(ctx, nil, v, act))
+			r.Equal(tc.wait, act.wait)
+			r.Equal(tc.fail, act.fail)
+			r.Equal(tc.reason, act.reason)
+			r.Equal(tc.message, act.message)
+			if tc.result == nil {
+				_, err := v.LookupValue("result")
+				r.Error(err)
+				return
+			}
+			result := &waitJobResult{}
+			rv, err := v.LookupValue("result")
+			r.NoError(err)
+			r.NoError(rv.UnmarshalTo(result))
+			r.Equal(tc.result, result)
+		})
+	}
+
+	// the reason is dropped by the action not supporting it, the logs are not read without the tail lines
+	v, err := value.NewValue(`cluster: "", namespace: "default", tailLines: 0, name: "backoff"`, nil, "")
+	require.NoError(t, err)
+	act := &mockAction{}
+	require.NoError(t, prd.WaitJob(monitorContext.NewTraceContext(context.Background(), ""), nil, v, act))
+	require.True(t, act.fail)
+	require.Equal(t, "job backoff failed with BackoffLimitExceeded: Job has reached the specified backoff limit, container main of pod backoff-1 exited with 1", act.message)
+}
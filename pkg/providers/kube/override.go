@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"strconv"
+
+	"cuelang.org/go/cue"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/kubevela/pkg/multicluster"
+
+	"github.com/kubevela/workflow/pkg/cue/model"
+	"github.com/kubevela/workflow/pkg/cue/model/sets"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+// override is the patch of the object applied to the cluster or the clusters selected by the selector
+type override struct {
+	Cluster         *string          `json:"cluster"`
+	ClusterSelector *clusterSelector `json:"clusterSelector"`
+	patch           cue.Value
+}
+
+// overrides is the overrides of the object in order
+type overrides struct {
+	items []override
+	// clusters is the labels of the registered clusters, it's listed only if any override has the cluster selector
+	clusters map[string]labels.Set
+}
+
+func (h *provider) parseOverrides(ctx context.Context, v *value.Value) (*overrides, error) {
+	ov, err := v.LookupValue("overrides")
+	if err != nil {
+		return nil, nil
+	}
+	o := &overrides{}
+	if err := ov.UnmarshalTo(&o.items); err != nil {
+		return nil, err
+	}
+	for i := range o.items {
+		if o.items[i].Cluster == nil && o.items[i].ClusterSelector == nil {
+			return nil, errors.Errorf("overrides[%d] should have either cluster or clusterSelector", i)
+		}
+		pv, err := v.LookupValue("overrides", strconv.Itoa(i), "patch")
+		if err != nil {
+			return nil, err
+		}
+		o.items[i].patch = pv.CueValue()
+		if o.items[i].ClusterSelector != nil && o.clusters == nil {
+			if o.clusters, err = h.listClusters(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return o, nil
+}
+
+// apply patches the copy of the object with the overrides matching the cluster in order, the later
+// overrides are patched on the result of the former ones.
+func (o *overrides) apply(v *value.Value, workload *unstructured.Unstructured, cluster string, warnings *[]string) (*unstructured.Unstructured, error) {
+	obj := workload.DeepCopy()
+	if o == nil {
+		return obj, nil
+	}
+	for i, item := range o.items {
+		if !o.matches(item, cluster) {
+			continue
+		}
+		b, err := obj.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		objV, err := v.MakeValue(string(b))
+		if err != nil {
+			return nil, err
+		}
+		base, err := model.NewBase(objV.CueValue())
+		if err != nil {
+			return nil, err
+		}
+		if err := base.Unify(item.patch, sets.UnifyWithWarnings{Warnings: warnings}); err != nil {
+			return nil, errors.WithMessagef(err, "apply overrides[%d] to cluster %s", i, cluster)
+		}
+		if obj, err = base.Unstructured(); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+func (o *overrides) matches(item override, cluster string) bool {
+	if item.Cluster != nil {
+		return *item.Cluster == cluster || (multicluster.IsLocal(*item.Cluster) && multicluster.IsLocal(cluster))
+	}
+	if multicluster.IsLocal(cluster) {
+		cluster = multicluster.Local
+	}
+	clusterLabels, ok := o.clusters[cluster]
+	return ok && item.ClusterSelector.matches(cluster, clusterLabels)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+func TestPatch(t *testing.T) {
+	cli := fake.NewClientBuilder().WithObjects(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: pointer.Int32(1)},
+	}).Build()
+	prd := &provider{cli: cli}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	// the cases are run in order on the same deployment
+	testCases := []struct {
+		name     string
+		patch    string
+		replicas int64
+		errType  string
+	}{
+		{
+			name:     "merge patch",
+			patch:    `patchType: "merge", patch: spec: replicas: 2`,
+			replicas: 2,
+		},
+		{
+			name:     "json patch",
+			patch:    `patchType: "json", patch: [{op: "test", path: "/spec/replicas", value: 2}, {op: "replace", path: "/spec/replicas", value: 3}]`,
+			replicas: 3,
+		},
+		{
+			name:    "json patch test failed",
+			patch:   `patchType: "json", patch: [{op: "test", path: "/spec/replicas", value: 2}]`,
+			errType: PatchErrorTestFailed,
+		},
+		{
+			name:    "not found",
+			patch:   `resource: name: "missing", patchType: "merge", patch: spec: replicas: 2`,
+			errType: PatchErrorNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+			v, err := value.NewValue(`
+resource: {
+	apiVersion: "apps/v1"
+	kind:       "Deployment"
+	name:       *"app" | string
+	namespace:  "default"
+}
+cluster: ""
+`+tc.patch, nil, "")
+			r.NoError(err)
+			r.NoError(prd.Patch(ctx, nil, v, nil))
+			if tc.errType != "" {
+				errType, err := v.GetString("errType")
+				r.NoError(err)
+				r.Equal(tc.errType, errType)
+				return
+			}
+			replicas, err := v.GetInt64("value", "spec", "replicas")
+			r.NoError(err)
+			r.Equal(tc.replicas, replicas)
+		})
+	}
+}
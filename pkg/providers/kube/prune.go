@@ -0,0 +1,188 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/dryrun"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// PruneAllowedKinds is the kinds of the objects allowed to be pruned by the selectors, the prune op fails if
+// any of its resources is not allowed, which prevents a loose selector from deleting the cluster wide objects.
+// The kinds are qualified by their groups as `Kind.group`, e.g. `Deployment.apps`, the kinds of the core group have none.
+var PruneAllowedKinds = []string{"ConfigMap", "Secret", "Service", "Deployment.apps", "StatefulSet.apps", "DaemonSet.apps", "Job.batch", "CronJob.batch", "Ingress.networking.k8s.io"}
+
+// pruneParams is the parameters of pruning the objects matching the label selector
+type pruneParams struct {
+	Cluster   string `json:"cluster"`
+	Resources []struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	} `json:"resources"`
+	Namespace     string `json:"namespace"`
+	LabelSelector string `json:"labelSelector"`
+	// DryRun fills the objects that would be deleted without deleting them
+	DryRun bool `json:"dryRun"`
+	// Grace is the grace period of the deletion, the default of the objects is used if it's empty
+	Grace  string `json:"grace,omitempty"`
+	Wait   bool   `json:"wait"`
+	StepID string `json:"stepID"`
+}
+
+// Prune lists the objects of the resources matching the label selector in the namespace and deletes them, the
+// names of the deleted objects are filled into `deleted` as kind/name. The step keeps waiting until the objects
+// are gone if wait is set.
+func (h *provider) Prune(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	params := &pruneParams{}
+	if err := v.UnmarshalTo(params, value.StrictFor(v, "deleted")); err != nil {
+		return err
+	}
+	if strings.TrimSpace(params.LabelSelector) == "" {
+		return errors.New("labelSelector is required to prune the objects")
+	}
+	selector, err := labels.Parse(params.LabelSelector)
+	if err != nil {
+		return errors.WithMessage(err, "parse the labelSelector")
+	}
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	var opts []client.DeleteOption
+	if params.Grace != "" {
+		d, err := time.ParseDuration(params.Grace)
+		if err != nil {
+			return errors.WithMessage(err, "parse the grace")
+		}
+		if d < 0 {
+			return errors.Errorf("the grace %s must not be negative", params.Grace)
+		}
+		opts = append(opts, client.GracePeriodSeconds(int64(d.Seconds())))
+	}
+	for _, r := range params.Resources {
+		if kind, ok := pruneAllowed(r.APIVersion, r.Kind); !ok {
+			return fmt.Errorf("kind %s is not allowed to be pruned, the allowed kinds are %s", kind, strings.Join(PruneAllowedKinds, ", "))
+		}
+	}
+
+	pruneCtx := handleContext(ctx, params.Cluster)
+	list := func() ([]*unstructured.Unstructured, error) {
+		var objs []*unstructured.Unstructured
+		for _, r := range params.Resources {
+			auditTarget(act, params.Cluster, r.APIVersion, r.Kind, params.Namespace, "")
+			l := &unstructured.UnstructuredList{}
+			l.SetAPIVersion(r.APIVersion)
+			l.SetKind(r.Kind + "List")
+			if err := h.cli.List(pruneCtx, l, client.InNamespace(params.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+				return nil, types.NewClientError(err, "list", r.Kind, params.Namespace)
+			}
+			for i := range l.Items {
+				obj := &l.Items[i]
+				obj.SetAPIVersion(r.APIVersion)
+				obj.SetKind(r.Kind)
+				objs = append(objs, obj)
+			}
+		}
+		return objs, nil
+	}
+	objs, err := list()
+	if err != nil {
+		return err
+	}
+	if params.DryRun || dryrun.IsDryRun(h.cli) {
+		names := make([]string, 0, len(objs))
+		for _, obj := range objs {
+			names = append(names, pruneName(obj))
+		}
+		sort.Strings(names)
+		return v.FillObject(names, "deleted")
+	}
+
+	// the objects deleted by the previous reconciles of the waiting step are kept in the workflow context
+	key := "prune-" + params.StepID
+	deleted := map[string]bool{}
+	if s := wfCtx.GetMutableValue(key); s != "" {
+		for _, name := range strings.Split(s, ",") {
+			deleted[name] = true
+		}
+	}
+	for _, obj := range objs {
+		if obj.GetDeletionTimestamp() == nil {
+			if err := h.cli.Delete(pruneCtx, obj, opts...); err != nil && !kerrors.IsNotFound(err) {
+				return clientError(err, "delete", obj)
+			}
+		}
+		deleted[pruneName(obj)] = true
+	}
+	names := make([]string, 0, len(deleted))
+	for name := range deleted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if params.Wait {
+		remaining, err := list()
+		if err != nil {
+			return err
+		}
+		if len(remaining) > 0 {
+			wfCtx.SetMutableValue(strings.Join(names, ","), key)
+			waiting := make([]string, 0, len(remaining))
+			for _, obj := range remaining {
+				watchObject(act, params.Cluster, obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName())
+				waiting = append(waiting, pruneName(obj))
+			}
+			act.Wait(fmt.Sprintf("waiting for %d objects to be deleted: %s", len(waiting), strings.Join(waiting, ", ")))
+			return nil
+		}
+	}
+	wfCtx.DeleteMutableValue(key)
+	return v.FillObject(names, "deleted")
+}
+
+// pruneAllowed returns the kind qualified by the group of the apiVersion and whether the objects of it are allowed
+// to be pruned, so that the kinds of the same name in the other groups are not allowed by accident.
+func pruneAllowed(apiVersion, kind string) (string, bool) {
+	if gv, err := schema.ParseGroupVersion(apiVersion); err == nil && gv.Group != "" {
+		kind = kind + "." + gv.Group
+	}
+	for _, k := range PruneAllowedKinds {
+		if k == "*" || k == kind {
+			return kind, true
+		}
+	}
+	return kind, false
+}
+
+func pruneName(obj *unstructured.Unstructured) string {
+	return obj.GetKind() + "/" + obj.GetName()
+}
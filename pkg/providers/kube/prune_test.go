@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+func TestPrune(t *testing.T) {
+	objects := func() []client.Object {
+		meta := func(name string, labels map[string]string) metav1.ObjectMeta {
+			return metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels}
+		}
+		return []client.Object{
+			&appsv1.Deployment{ObjectMeta: meta("web", map[string]string{"app": "web"})},
+			&appsv1.Deployment{ObjectMeta: meta("db", map[string]string{"app": "db"})},
+			&corev1.ConfigMap{ObjectMeta: meta("web-config", map[string]string{"app": "web"})},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web", Labels: map[string]string{"app": "web"}}},
+		}
+	}
+	resources := `resources: [{apiVersion: "apps/v1", kind: "Deployment"}, {apiVersion: "v1", kind: "ConfigMap"}]
+cluster: ""
+stepID: "step"
+`
+	testCases := map[string]struct {
+		params  string
+		deleted []string
+		remain  []string
+		err     string
+	}{
+		"prune": {
+			params:  resources + `labelSelector: "app=web", wait: true`,
+			deleted: []string{"ConfigMap/web-config", "Deployment/web"},
+			remain:  []string{"db"},
+		},
+		"dry run": {
+			params:  resources + `labelSelector: "app=web", dryRun: true, grace: "10s"`,
+			deleted: []string{"ConfigMap/web-config", "Deployment/web"},
+			remain:  []string{"db", "web"},
+		},
+		"selector": {
+			params:  resources + `labelSelector: "app in (web, db)", grace: "10s"`,
+			deleted: []string{"ConfigMap/web-config", "Deployment/db", "Deployment/web"},
+		},
+		"no match": {
+			params:  resources + `labelSelector: "app=api"`,
+			deleted: []string{},
+			remain:  []string{"db", "web"},
+		},
+		"empty selector": {
+			params: resources + `labelSelector: ""`,
+			err:    "labelSelector is required to prune the objects",
+			remain: []string{"db", "web"},
+		},
+		"not allowed": {
+			params: `resources: [{apiVersion: "v1", kind: "Namespace"}], cluster: "", stepID: "step", labelSelector: "app=web"`,
+			err:    "kind Namespace is not allowed to be pruned, the allowed kinds are ConfigMap, Secret, Service, Deployment.apps, StatefulSet.apps, DaemonSet.apps, Job.batch, CronJob.batch, Ingress.networking.k8s.io",
+			remain: []string{"db", "web"},
+		},
+		"kind of the other group": {
+			params: `resources: [{apiVersion: "example.com/v1", kind: "Deployment"}], cluster: "", stepID: "step", labelSelector: "app=web"`,
+			err:    "kind Deployment.example.com is not allowed to be pruned, the allowed kinds are ConfigMap, Secret, Service, Deployment.apps, StatefulSet.apps, DaemonSet.apps, Job.batch, CronJob.batch, Ingress.networking.k8s.io",
+			remain: []string{"db", "web"},
+		},
+		"negative grace": {
+			params: resources + `labelSelector: "app=web", grace: "-10s"`,
+			err:    "the grace -10s must not be negative",
+			remain: []string{"db", "web"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			cli := fake.NewClientBuilder().WithObjects(objects()...).Build()
+			prd := &provider{cli: cli}
+			wfCtx, err := newWorkflowContextForTest()
+			r.NoError(err)
+			v, err := value.NewValue(tc.params, nil, "")
+			r.NoError(err)
+			act := &mockAction{}
+			ctx := monitorContext.NewTraceContext(context.Background(), "")
+			err = prd.Prune(ctx, wfCtx, v, act)
+			if tc.err != "" {
+				r.EqualError(err, tc.err)
+			} else {
+				r.NoError(err)
+				r.False(act.wait)
+				deleted := []string{}
+				dv, err := v.LookupValue("deleted")
+				r.NoError(err)
+				r.NoError(dv.UnmarshalTo(&deleted))
+				r.Equal(tc.deleted, deleted)
+			}
+			deploys := &appsv1.DeploymentList{}
+			r.NoError(cli.List(context.Background(), deploys))
+			var remain []string
+			for _, d := range deploys.Items {
+				remain = append(remain, d.Name)
+			}
+			r.Equal(tc.remain, remain)
+		})
+	}
+}
+
+func TestPruneWait(t *testing.T) {
+	r := require.New(t)
+	// the finalizer keeps the deleted object until it's removed
+	deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"app": "web"}, Finalizers: []string{"test"}}}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "web-config", Namespace: "default", Labels: map[string]string{"app": "web"}}}
+	cli := fake.NewClientBuilder().WithObjects(deploy, cm).Build()
+	prd := &provider{cli: cli}
+	wfCtx, err := newWorkflowContextForTest()
+	r.NoError(err)
+	params := `resources: [{apiVersion: "apps/v1", kind: "Deployment"}, {apiVersion: "v1", kind: "ConfigMap"}]
+cluster: ""
+stepID: "step"
+labelSelector: "app=web"
+wait: true
+`
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	v, err := value.NewValue(params, nil, "")
+	r.NoError(err)
+	act := &mockAction{}
+	r.NoError(prd.Prune(ctx, wfCtx, v, act))
+	r.True(act.wait)
+	r.Equal("waiting for 1 objects to be deleted: Deployment/web", act.message)
+	r.Len(act.watches, 1)
+	r.Equal("web", act.watches[0].Name)
+	_, err = v.LookupValue("deleted")
+	r.Error(err)
+
+	// the objects deleted by the previous reconciles are filled after the waiting objects are gone
+	r.NoError(cli.Get(ctx, client.ObjectKeyFromObject(deploy), deploy))
+	deploy.Finalizers = nil
+	r.NoError(cli.Update(ctx, deploy))
+	v, err = value.NewValue(params, nil, "")
+	r.NoError(err)
+	act = &mockAction{}
+	r.NoError(prd.Prune(ctx, wfCtx, v, act))
+	r.False(act.wait)
+	deleted := []string{}
+	dv, err := v.LookupValue("deleted")
+	r.NoError(err)
+	r.NoError(dv.UnmarshalTo(&deleted))
+	r.Equal([]string{"ConfigMap/web-config", "Deployment/web"}, deleted)
+	r.Equal("", wfCtx.GetMutableValue("prune-step"))
+}
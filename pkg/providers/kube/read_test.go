@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestReadObjects(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}, Data: map[string]string{"key": "value"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}},
+	).Build()
+	prd := &provider{cli: cli}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+
+	v, err := value.NewValue(`
+objects: [{
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	name:       "app"
+	namespace:  "default"
+}, {
+	apiVersion: "v1"
+	kind:       "Service"
+	name:       "app"
+	namespace:  "default"
+	key:        "service"
+}, {
+	apiVersion: "v1"
+	kind:       "Secret"
+	name:       "missing"
+	namespace:  "default"
+}]
+cluster: ""
+`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.Read(ctx, nil, v, nil))
+	data, err := v.GetString("values", "app", "data", "key")
+	r.NoError(err)
+	r.Equal("value", data)
+	kind, err := v.GetString("values", "service", "kind")
+	r.NoError(err)
+	r.Equal("Service", kind)
+	msg, err := v.GetString("errors", "missing")
+	r.NoError(err)
+	r.Contains(msg, "not found")
+	_, err = v.LookupValue("values", "missing")
+	r.Error(err)
+
+	v, err = value.NewValue(`
+objects: [{apiVersion: "v1", kind: "ConfigMap", name: "app"}, {apiVersion: "v1", kind: "Service", name: "app"}]
+cluster: ""
+`, nil, "")
+	r.NoError(err)
+	r.Error(prd.Read(ctx, nil, v, nil))
+}
+
+func TestReadClientError(t *testing.T) {
+	testCases := map[string]struct {
+		err      error
+		class    types.ClientErrorClass
+		errField string
+	}{
+		"forbidden": {
+			err:   kerrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "app", errors.New("no permission")),
+			class: types.ClientErrorForbidden,
+		},
+		"invalid": {
+			err:   kerrors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, "app", nil),
+			class: types.ClientErrorInvalid,
+		},
+		"conflict": {
+			err:      kerrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "app", errors.New("modified")),
+			errField: "Operation cannot be fulfilled",
+		},
+		"not found": {
+			err:      kerrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "app"),
+			errField: "not found",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			cli := &test.MockClient{MockGet: test.NewMockGetFn(tc.err)}
+			prd := &provider{cli: cli}
+			ctx := monitorContext.NewTraceContext(context.Background(), "")
+			v, err := value.NewValue(`
+value: {
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	metadata: name: "app"
+}
+cluster: ""
+`, nil, "")
+			r.NoError(err)
+			err = prd.Read(ctx, nil, v, nil)
+			if tc.class != "" {
+				clientErr, ok := types.AsTerminalClientError(err)
+				r.True(ok)
+				r.Equal(tc.class, clientErr.Class)
+				r.Contains(err.Error(), "failed to get ConfigMap app in namespace default")
+				return
+			}
+			r.NoError(err)
+			msg, err := v.GetString("err")
+			r.NoError(err)
+			r.Contains(msg, tc.errField)
+		})
+	}
+}
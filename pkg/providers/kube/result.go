@@ -0,0 +1,142 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+const (
+	// ApplyActionCreated means the object is created by the apply
+	ApplyActionCreated = "created"
+	// ApplyActionUpdated means the fields of the object are changed by the apply
+	ApplyActionUpdated = "updated"
+	// ApplyActionUnchanged means the object already has the applied fields
+	ApplyActionUnchanged = "unchanged"
+)
+
+// applyResult is the result of applying an object
+type applyResult struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Action    string `json:"action"`
+	// Cluster is the cluster of the object if the clusters are selected by the cluster selector
+	Cluster string `json:"cluster,omitempty"`
+	// Diff is the paths of the fields changed by the apply
+	Diff []string `json:"diff,omitempty"`
+}
+
+// diffApplyResults compares the objects to apply with the ones in the cluster, it must be called before applying.
+func (h *provider) diffApplyResults(ctx context.Context, workloads ...*unstructured.Unstructured) ([]applyResult, error) {
+	results := make([]applyResult, 0, len(workloads))
+	for _, workload := range workloads {
+		result := applyResult{
+			Name:      workload.GetName(),
+			Namespace: workload.GetNamespace(),
+			Kind:      workload.GetKind(),
+			Action:    ApplyActionUnchanged,
+		}
+		existing := new(unstructured.Unstructured)
+		existing.SetGroupVersionKind(workload.GroupVersionKind())
+		if err := h.cli.Get(ctx, client.ObjectKeyFromObject(workload), existing); err != nil {
+			if !errors.IsNotFound(err) {
+				return nil, clientError(err, "get", workload)
+			}
+			result.Action = ApplyActionCreated
+		} else if result.Diff = diffFields(existing.Object, workload.Object); len(result.Diff) > 0 {
+			result.Action = ApplyActionUpdated
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// fillApplyResults fills the results and whether any object is changed into the value
+func fillApplyResults(v *value.Value, results []applyResult) error {
+	changed := false
+	for _, result := range results {
+		changed = changed || result.Action != ApplyActionUnchanged
+	}
+	if err := v.FillObject(results, "result"); err != nil {
+		return err
+	}
+	return v.FillObject(changed, "changed")
+}
+
+// diffFields returns the paths of the fields in the desired object that are different from the existing one.
+// The status and the metadata except the labels and the annotations are ignored. Only the fields present in the
+// desired object are compared, so the fields defaulted by the server are not taken as changes, the lists are compared
+// by their items if they have the same length.
+func diffFields(existing, desired map[string]interface{}) []string {
+	var diff []string
+	for key, want := range desired {
+		switch key {
+		case "status":
+			continue
+		case "metadata":
+			meta, _ := want.(map[string]interface{})
+			existingMeta, _ := existing[key].(map[string]interface{})
+			for _, field := range []string{"labels", "annotations"} {
+				if want, ok := meta[field]; ok {
+					diff = append(diff, diffValue("metadata."+field, existingMeta[field], want)...)
+				}
+			}
+			continue
+		}
+		diff = append(diff, diffValue(key, existing[key], want)...)
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+func diffValue(path string, existing, desired interface{}) []string {
+	switch want := desired.(type) {
+	case map[string]interface{}:
+		existingMap, _ := existing.(map[string]interface{})
+		var diff []string
+		for key, w := range want {
+			diff = append(diff, diffValue(strings.Join([]string{path, key}, "."), existingMap[key], w)...)
+		}
+		return diff
+	case []interface{}:
+		existingList, ok := existing.([]interface{})
+		if !ok || len(existingList) != len(want) {
+			return []string{path}
+		}
+		var diff []string
+		for i, w := range want {
+			diff = append(diff, diffValue(fmt.Sprintf("%s[%d]", path, i), existingList[i], w)...)
+		}
+		return diff
+	default:
+		if reflect.DeepEqual(existing, desired) {
+			return nil
+		}
+		return []string{path}
+	}
+}
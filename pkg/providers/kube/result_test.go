@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+func TestApplyResult(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().Build()
+	d := &dispatcher{cli: cli}
+	prd := &provider{cli: cli, handlers: Handlers{Apply: d.apply, Delete: d.delete}}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+
+	apply := func(data string) (string, bool) {
+		v, err := value.NewValue(`
+value: {
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	metadata: name: "config"
+	data: key: "`+data+`"
+}
+cluster: ""
+`, nil, "")
+		r.NoError(err)
+		r.NoError(prd.Apply(ctx, nil, v, nil))
+		result, err := v.LookupValue("result")
+		r.NoError(err)
+		s, err := result.String()
+		r.NoError(err)
+		changed, err := v.GetBool("changed")
+		r.NoError(err)
+		return s, changed
+	}
+
+	result, changed := apply("v1")
+	r.True(changed)
+	r.Contains(result, `action:    "created"`)
+
+	result, changed = apply("v1")
+	r.False(changed)
+	r.Contains(result, `action:    "unchanged"`)
+
+	result, changed = apply("v2")
+	r.True(changed)
+	r.Contains(result, `action:    "updated"`)
+	r.Contains(result, `"data.key"`)
+}
+
+func TestDiffFields(t *testing.T) {
+	r := require.New(t)
+	existing := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":            "app",
+			"resourceVersion": "1",
+			"labels":          map[string]interface{}{"app": "web"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"ports":    []interface{}{int64(80)},
+			"containers": []interface{}{
+				map[string]interface{}{"name": "web", "image": "nginx:1", "imagePullPolicy": "IfNotPresent"},
+				map[string]interface{}{"name": "sidecar", "image": "envoy", "imagePullPolicy": "IfNotPresent"},
+			},
+		},
+		"status": map[string]interface{}{"ready": true},
+	}
+	desired := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":   "app",
+			"labels": map[string]interface{}{"app": "web", "tier": "frontend"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"ports":    []interface{}{int64(80), int64(443)},
+			"image":    "nginx",
+			// the fields defaulted by the server in the items are not compared
+			"containers": []interface{}{
+				map[string]interface{}{"name": "web", "image": "nginx:2"},
+				map[string]interface{}{"name": "sidecar", "image": "envoy"},
+			},
+		},
+		"status": map[string]interface{}{"ready": false},
+	}
+	r.Equal([]string{"metadata.labels.tier", "spec.containers[0].image", "spec.image", "spec.ports"}, diffFields(existing, desired))
+	r.Empty(diffFields(existing, existing))
+}
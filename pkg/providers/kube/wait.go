@@ -0,0 +1,175 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// waitAllParams is the parameters of waiting for the objects to be ready
+type waitAllParams struct {
+	Cluster string      `json:"cluster"`
+	Objects []objectRef `json:"objects"`
+	// Condition is the CUE expression evaluated on each object referred as `object`, the object is ready if it's true
+	Condition string `json:"condition,omitempty"`
+	// Expression is the CUE expression evaluated on all the objects referred as `resources`, the objects are ready
+	// if it's true
+	Expression string `json:"expression,omitempty"`
+	// Timeout fails the step with the unready objects if they are not ready in the duration since the op starts waiting
+	Timeout string `json:"timeout,omitempty"`
+	StepID  string `json:"stepID"`
+}
+
+// WaitAll reads the objects in one pass and waits until the condition holds for each of them and the expression
+// holds for all of them, the readiness of the objects is filled into `ready` keyed by their keys.
+func (h *provider) WaitAll(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	params := &waitAllParams{}
+	if err := v.UnmarshalTo(params, value.StrictFor(v, "ready")); err != nil {
+		return err
+	}
+	if params.Condition == "" && params.Expression == "" {
+		return errors.New("either condition or expression is required")
+	}
+	var timeout time.Duration
+	if params.Timeout != "" {
+		d, err := time.ParseDuration(params.Timeout)
+		if err != nil {
+			return errors.WithMessage(err, "parse the timeout")
+		}
+		timeout = d
+	}
+
+	readCtx := handleContext(ctx, params.Cluster)
+	ready := map[string]bool{}
+	var keys, unready []string
+	resources := make([]interface{}, 0, len(params.Objects))
+	for _, ref := range params.Objects {
+		key := ref.Key
+		if key == "" {
+			key = ref.Name
+		}
+		if _, ok := ready[key]; ok {
+			return fmt.Errorf("duplicate key %s in objects, set the key of the objects to distinguish them", key)
+		}
+		keys = append(keys, key)
+		obj := new(unstructured.Unstructured)
+		obj.SetAPIVersion(ref.APIVersion)
+		obj.SetKind(ref.Kind)
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		auditTarget(act, params.Cluster, ref.APIVersion, ref.Kind, namespace, ref.Name)
+		watchObject(act, params.Cluster, ref.APIVersion, ref.Kind, h.scopedNamespace(ref.APIVersion, ref.Kind, namespace), ref.Name)
+		if err := h.cli.Get(readCtx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, obj); err != nil {
+			if !kerrors.IsNotFound(err) {
+				obj.SetNamespace(namespace)
+				obj.SetName(ref.Name)
+				return clientError(err, "get", obj)
+			}
+			// the objects not created yet are not ready
+			ready[key] = false
+			unready = append(unready, key)
+			continue
+		}
+		resources = append(resources, obj.Object)
+		ok := true
+		if params.Condition != "" {
+			var err error
+			if ok, err = evaluatePredicate("object", obj.Object, params.Condition); err != nil {
+				return errors.WithMessagef(err, "evaluate the condition on %s", key)
+			}
+		}
+		ready[key] = ok
+		if !ok {
+			unready = append(unready, key)
+		}
+	}
+	if err := v.FillObject(ready, "ready"); err != nil {
+		return err
+	}
+
+	progress := fmt.Sprintf("%d/%d ready", len(keys)-len(unready), len(keys))
+	waiting := strings.Join(unready, ", ")
+	if len(unready) == 0 && params.Expression != "" {
+		ok, err := evaluatePredicate("resources", resources, params.Expression)
+		if err != nil {
+			return errors.WithMessage(err, "evaluate the expression")
+		}
+		if !ok {
+			waiting = "the expression " + params.Expression
+		}
+	}
+	startKey := "wait-all-" + params.StepID
+	if waiting == "" {
+		wfCtx.DeleteMutableValue(startKey)
+		return nil
+	}
+	if timeout > 0 {
+		start := time.Now()
+		if s := wfCtx.GetMutableValue(startKey); s != "" {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				start = t
+			}
+		} else {
+			wfCtx.SetMutableValue(start.Format(time.RFC3339), startKey)
+		}
+		if time.Since(start) >= timeout {
+			wfCtx.DeleteMutableValue(startKey)
+			act.Fail(fmt.Sprintf("timed out after %s, %s, unready: %s", params.Timeout, progress, waiting))
+			return nil
+		}
+	}
+	act.Wait(fmt.Sprintf("%s: waiting on %s", progress, waiting))
+	return nil
+}
+
+// evaluatePredicate evaluates the CUE expression with the data referred by the name, the incomplete result caused by
+// the missing fields of the data is false.
+func evaluatePredicate(name string, data interface{}, expr string) (bool, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+	v, err := value.NewValue(fmt.Sprintf("%s: %s\nresult: %s", name, string(b), expr), nil, "")
+	if err != nil {
+		return false, err
+	}
+	result, err := v.LookupValue("result")
+	if err != nil {
+		return false, err
+	}
+	ok, err := result.CueValue().Bool()
+	if err != nil && !result.CueValue().IsConcrete() {
+		return false, nil
+	}
+	return ok, err
+}
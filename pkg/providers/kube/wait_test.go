@@ -0,0 +1,153 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+func TestWaitAll(t *testing.T) {
+	deploy := func(name string, available int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       appsv1.DeploymentSpec{Replicas: pointer.Int32(2)},
+			Status:     appsv1.DeploymentStatus{AvailableReplicas: available},
+		}
+	}
+	cli := fake.NewClientBuilder().WithObjects(deploy("web-0", 2), deploy("web-1", 1)).Build()
+	prd := &provider{cli: cli}
+	objects := func(names ...string) string {
+		s := "objects: ["
+		for _, name := range names {
+			s += fmt.Sprintf(`{apiVersion: "apps/v1", kind: "Deployment", name: "%s"},`, name)
+		}
+		return s + "]\n"
+	}
+	testCases := map[string]struct {
+		params  string
+		start   time.Time
+		wait    bool
+		fail    bool
+		message string
+		ready   map[string]bool
+	}{
+		"condition": {
+			params:  objects("web-0", "web-1", "web-2") + `condition: "object.status.availableReplicas == object.spec.replicas"`,
+			wait:    true,
+			message: "1/3 ready: waiting on web-1, web-2",
+			ready:   map[string]bool{"web-0": true, "web-1": false, "web-2": false},
+		},
+		"condition holds": {
+			params: objects("web-0") + `condition: "object.status.availableReplicas == object.spec.replicas"`,
+			ready:  map[string]bool{"web-0": true},
+		},
+		"expression": {
+			params:  objects("web-0", "web-1") + `expression: "len([for r in resources if r.status.availableReplicas == r.spec.replicas {r}]) == len(resources)"`,
+			wait:    true,
+			message: "2/2 ready: waiting on the expression len([for r in resources if r.status.availableReplicas == r.spec.replicas {r}]) == len(resources)",
+			ready:   map[string]bool{"web-0": true, "web-1": true},
+		},
+		"missing field": {
+			params:  objects("web-0") + `condition: "object.status.readyReplicas == object.spec.replicas"`,
+			wait:    true,
+			message: "0/1 ready: waiting on web-0",
+			ready:   map[string]bool{"web-0": false},
+		},
+		"timeout": {
+			params:  objects("web-0", "web-1", "web-2") + `condition: "object.status.availableReplicas == object.spec.replicas", timeout: "1m"`,
+			start:   time.Now().Add(-time.Hour),
+			fail:    true,
+			message: "timed out after 1m, 1/3 ready, unready: web-1, web-2",
+			ready:   map[string]bool{"web-0": true, "web-1": false, "web-2": false},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			wfCtx, err := newWorkflowContextForTest()
+			r.NoError(err)
+			if !tc.start.IsZero() {
+				wfCtx.SetMutableValue(tc.start.Format(time.RFC3339), "wait-all-step")
+			}
+			v, err := value.NewValue(tc.params+"\ncluster: \"\"\nstepID: \"step\"", nil, "")
+			r.NoError(err)
+			act := &mockAction{}
+			ctx := monitorContext.NewTraceContext(context.Background(), "")
+			r.NoError(prd.WaitAll(ctx, wfCtx, v, act))
+			r.Equal(tc.wait, act.wait)
+			r.Equal(tc.fail, act.fail)
+			r.Equal(tc.message, act.message)
+			ready := map[string]bool{}
+			rv, err := v.LookupValue("ready")
+			r.NoError(err)
+			r.NoError(rv.UnmarshalTo(&ready))
+			r.Equal(tc.ready, ready)
+		})
+	}
+
+	// the objects in the local cluster are watched by the waiting step
+	for cluster, watched := range map[string]bool{"": true, "local": true, "c1": false} {
+		wfCtx, err := newWorkflowContextForTest()
+		require.NoError(t, err)
+		v, err := value.NewValue(objects("web-1")+fmt.Sprintf("condition: \"object.status.availableReplicas == object.spec.replicas\"\ncluster: %q\nstepID: \"step\"", cluster), nil, "")
+		require.NoError(t, err)
+		act := &mockAction{}
+		require.NoError(t, prd.WaitAll(monitorContext.NewTraceContext(context.Background(), ""), wfCtx, v, act))
+		require.True(t, act.wait)
+		if watched {
+			require.Equal(t, []v1alpha1.WatchedObject{{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web-1"}}, act.watches, cluster)
+		} else {
+			require.Empty(t, act.watches, cluster)
+		}
+	}
+
+	// the namespace of the cluster-scoped objects is not recorded to match their events
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Namespace"), meta.RESTScopeRoot)
+	scoped := &provider{cli: fake.NewClientBuilder().WithRESTMapper(mapper).WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod"}}).Build()}
+	wfCtx, err := newWorkflowContextForTest()
+	require.NoError(t, err)
+	v, err := value.NewValue(`objects: [{apiVersion: "v1", kind: "Namespace", name: "prod"}]
+condition: "object.status.phase == \"Active\""
+cluster: ""
+stepID: "step"`, nil, "")
+	require.NoError(t, err)
+	act := &mockAction{}
+	require.NoError(t, scoped.WaitAll(monitorContext.NewTraceContext(context.Background(), ""), wfCtx, v, act))
+	require.True(t, act.wait)
+	require.Equal(t, []v1alpha1.WatchedObject{{APIVersion: "v1", Kind: "Namespace", Name: "prod"}}, act.watches)
+
+	v, err = value.NewValue(objects("web-0")+"cluster: \"\"\nstepID: \"step\"", nil, "")
+	require.NoError(t, err)
+	require.EqualError(t, prd.WaitAll(nil, nil, v, &mockAction{}), "either condition or expression is required")
+}
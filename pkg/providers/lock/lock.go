@@ -0,0 +1,256 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// ProviderName is provider name for install.
+	ProviderName = "lock"
+	// LabelLockHolder is the label of the lease with the uid of the workflow run holding the lock
+	LabelLockHolder = "workflowrun.oam.dev/lock-holder"
+	// defaultTTL is the ttl of the lock if it's not set
+	defaultTTL = 5 * time.Minute
+)
+
+// GenerateLeaseName generates the name of the lease backing the lock
+func GenerateLeaseName(name string) string {
+	return fmt.Sprintf("workflow-lock-%s", name)
+}
+
+type provider struct {
+	cli  client.Client
+	meta types.WorkflowMeta
+}
+
+type lockParams struct {
+	Name string `json:"name"`
+	TTL  string `json:"ttl,omitempty"`
+	Wait *bool  `json:"wait,omitempty"`
+}
+
+// Lock acquires the lock for the workflow run, the lock held by another run is taken over after its ttl expires.
+// The step waits for the lock if wait is true, otherwise it fails.
+func (h *provider) Lock(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	params := &lockParams{}
+	if err := v.UnmarshalTo(params, value.StrictFor(v, "holder")); err != nil {
+		return err
+	}
+	ttl := defaultTTL
+	if params.TTL != "" {
+		d, err := time.ParseDuration(params.TTL)
+		if err != nil {
+			return errors.WithMessagef(err, "invalid ttl %s of the lock %s", params.TTL, params.Name)
+		}
+		ttl = d
+	}
+	holder, err := h.acquire(ctx, params.Name, ttl)
+	if err != nil {
+		return err
+	}
+	if err := v.FillObject(holder, "holder"); err != nil {
+		return err
+	}
+	if holder == string(h.meta.UID) {
+		return nil
+	}
+	message := fmt.Sprintf("lock %s is held by another workflow run %s", params.Name, holder)
+	if params.Wait == nil || *params.Wait {
+		act.Wait(message)
+		return nil
+	}
+	act.Fail(message)
+	return nil
+}
+
+// acquire returns the holder of the lock after trying to acquire it, the lock is acquired if the holder is this run
+func (h *provider) acquire(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	now := metav1.NewMicroTime(time.Now())
+	lease := &coordinationv1.Lease{}
+	err := h.cli.Get(ctx, client.ObjectKey{Namespace: h.meta.Namespace, Name: GenerateLeaseName(name)}, lease)
+	if kerrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            GenerateLeaseName(name),
+				Namespace:       h.meta.Namespace,
+				OwnerReferences: h.meta.ChildOwnerReferences,
+				Labels:          h.labels(),
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       pointer.String(string(h.meta.UID)),
+				LeaseDurationSeconds: pointer.Int32(int32(ttl.Seconds())),
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		if err := h.cli.Create(ctx, lease); err != nil {
+			if kerrors.IsAlreadyExists(err) {
+				// acquired by another run at the same time
+				return h.acquire(ctx, name, ttl)
+			}
+			return "", errors.WithMessagef(err, "create the lease of the lock %s", name)
+		}
+		return string(h.meta.UID), nil
+	}
+	if err != nil {
+		return "", errors.WithMessagef(err, "get the lease of the lock %s", name)
+	}
+	holder := pointer.StringDeref(lease.Spec.HolderIdentity, "")
+	if holder != string(h.meta.UID) && !expired(lease, now.Time) {
+		return holder, nil
+	}
+	if holder != string(h.meta.UID) {
+		lease.Spec.AcquireTime = &now
+		lease.Spec.LeaseTransitions = pointer.Int32(pointer.Int32Deref(lease.Spec.LeaseTransitions, 0) + 1)
+	}
+	lease.OwnerReferences = h.meta.ChildOwnerReferences
+	lease.Labels = h.labels()
+	lease.Spec.HolderIdentity = pointer.String(string(h.meta.UID))
+	lease.Spec.LeaseDurationSeconds = pointer.Int32(int32(ttl.Seconds()))
+	lease.Spec.RenewTime = &now
+	if err := h.cli.Update(ctx, lease); err != nil {
+		if kerrors.IsConflict(err) {
+			// the lease is changed by another run, try again in the next reconcile
+			return holder, nil
+		}
+		return "", errors.WithMessagef(err, "update the lease of the lock %s", name)
+	}
+	return string(h.meta.UID), nil
+}
+
+func (h *provider) labels() map[string]string {
+	return map[string]string{
+		types.LabelWorkflowRunName:      h.meta.Name,
+		types.LabelWorkflowRunNamespace: h.meta.Namespace,
+		LabelLockHolder:                 string(h.meta.UID),
+	}
+}
+
+type unlockParams struct {
+	Name string `json:"name"`
+}
+
+// Unlock releases the lock if it's held by the workflow run
+func (h *provider) Unlock(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	params := &unlockParams{}
+	if err := v.UnmarshalTo(params, value.StrictFor(v)); err != nil {
+		return err
+	}
+	lease := &coordinationv1.Lease{}
+	if err := h.cli.Get(ctx, client.ObjectKey{Namespace: h.meta.Namespace, Name: GenerateLeaseName(params.Name)}, lease); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.WithMessagef(err, "get the lease of the lock %s", params.Name)
+	}
+	if pointer.StringDeref(lease.Spec.HolderIdentity, "") != string(h.meta.UID) {
+		return nil
+	}
+	if err := h.cli.Delete(ctx, lease); err != nil && !kerrors.IsNotFound(err) {
+		return errors.WithMessagef(err, "delete the lease of the lock %s", params.Name)
+	}
+	return nil
+}
+
+func expired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second).Before(now)
+}
+
+// Renew refreshes the locks held by the workflow run, so that they are not taken over while the run is executing or
+// suspended. It returns the interval in which the locks must be renewed again, which is half of the shortest ttl of
+// them, or zero if the run holds no lock.
+func Renew(ctx context.Context, cli client.Client, namespace string, uid string) (time.Duration, error) {
+	leases, err := held(ctx, cli, namespace, uid)
+	if err != nil {
+		return 0, err
+	}
+	var interval time.Duration
+	now := metav1.NewMicroTime(time.Now())
+	for i := range leases {
+		lease := leases[i]
+		lease.Spec.RenewTime = &now
+		if err := cli.Update(ctx, &lease); err != nil && !kerrors.IsNotFound(err) {
+			return 0, errors.WithMessagef(err, "renew the lease %s", lease.Name)
+		}
+		ttl := defaultTTL
+		if lease.Spec.LeaseDurationSeconds != nil {
+			ttl = time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+		}
+		if interval == 0 || ttl/2 < interval {
+			interval = ttl / 2
+		}
+	}
+	return interval, nil
+}
+
+// Release releases the locks held by the workflow run
+func Release(ctx context.Context, cli client.Client, namespace string, uid string) error {
+	leases, err := held(ctx, cli, namespace, uid)
+	if err != nil {
+		return err
+	}
+	for i := range leases {
+		if err := cli.Delete(ctx, &leases[i]); err != nil && !kerrors.IsNotFound(err) {
+			return errors.WithMessagef(err, "release the lease %s", leases[i].Name)
+		}
+	}
+	return nil
+}
+
+// held returns the leases held by the workflow run, the leases taken over by other runs are excluded
+func held(ctx context.Context, cli client.Client, namespace string, uid string) ([]coordinationv1.Lease, error) {
+	list := &coordinationv1.LeaseList{}
+	if err := cli.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{LabelLockHolder: uid}); err != nil {
+		return nil, errors.WithMessage(err, "list the leases of the locks")
+	}
+	var leases []coordinationv1.Lease
+	for _, lease := range list.Items {
+		if pointer.StringDeref(lease.Spec.HolderIdentity, "") == uid {
+			leases = append(leases, lease)
+		}
+	}
+	return leases, nil
+}
+
+// Install register handlers to provider discover.
+func Install(p types.Providers, cli client.Client, meta types.WorkflowMeta) {
+	prd := &provider{cli: cli, meta: meta}
+	p.Register(ProviderName, map[string]types.Handler{
+		"lock":   prd.Lock,
+		"unlock": prd.Unlock,
+	})
+}
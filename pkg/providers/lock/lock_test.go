@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/mock"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func newProvider(cli client.Client, uid string) *provider {
+	return &provider{cli: cli, meta: types.WorkflowMeta{Name: "run-" + uid, Namespace: "default", UID: k8stypes.UID(uid)}}
+}
+
+func TestLock(t *testing.T) {
+	r := require.New(t)
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	run1, run2 := newProvider(cli, "uid-1"), newProvider(cli, "uid-2")
+	lock := func(prd *provider, params string) (*value.Value, *mock.Action) {
+		v, err := value.NewValue(params, nil, "")
+		r.NoError(err)
+		act := &mock.Action{}
+		r.NoError(prd.Lock(ctx, nil, v, act))
+		return v, act
+	}
+
+	v, act := lock(run1, `name: "deploy", ttl: "1m"`)
+	r.Equal("", act.Phase)
+	holder, err := v.GetString("holder")
+	r.NoError(err)
+	r.Equal("uid-1", holder)
+	lease := &coordinationv1.Lease{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-lock-deploy"}, lease))
+	r.Equal("uid-1", *lease.Spec.HolderIdentity)
+	r.Equal(int32(60), *lease.Spec.LeaseDurationSeconds)
+	r.Equal("uid-1", lease.Labels[LabelLockHolder])
+
+	// the lock is reentrant for the holder
+	_, act = lock(run1, `name: "deploy", ttl: "1m"`)
+	r.Equal("", act.Phase)
+
+	_, act = lock(run2, `name: "deploy"`)
+	r.Equal("Wait", act.Phase)
+	r.Equal("lock deploy is held by another workflow run uid-1", act.Msg)
+	_, act = lock(run2, `name: "deploy", wait: false`)
+	r.Equal("Fail", act.Phase)
+
+	// the expired lock is taken over
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-lock-deploy"}, lease))
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now().Add(-2 * time.Minute)}
+	r.NoError(cli.Update(ctx, lease))
+	_, act = lock(run2, `name: "deploy"`)
+	r.Equal("", act.Phase)
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-lock-deploy"}, lease))
+	r.Equal("uid-2", *lease.Spec.HolderIdentity)
+	r.Equal(int32(1), *lease.Spec.LeaseTransitions)
+	r.Equal("uid-2", lease.Labels[LabelLockHolder])
+
+	v, err = value.NewValue(`name: "deploy", ttl: "1x"`, nil, "")
+	r.NoError(err)
+	err = run1.Lock(ctx, nil, v, &mock.Action{})
+	r.Error(err)
+	r.Contains(err.Error(), "invalid ttl 1x of the lock deploy")
+}
+
+func TestUnlock(t *testing.T) {
+	r := require.New(t)
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	run1, run2 := newProvider(cli, "uid-1"), newProvider(cli, "uid-2")
+	v, err := value.NewValue(`name: "deploy"`, nil, "")
+	r.NoError(err)
+	r.NoError(run1.Lock(ctx, nil, v, &mock.Action{}))
+
+	// the lock held by another run is not released
+	v, err = value.NewValue(`name: "deploy"`, nil, "")
+	r.NoError(err)
+	r.NoError(run2.Unlock(ctx, nil, v, &mock.Action{}))
+	lease := &coordinationv1.Lease{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-lock-deploy"}, lease))
+
+	r.NoError(run1.Unlock(ctx, nil, v, &mock.Action{}))
+	err = cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "workflow-lock-deploy"}, lease)
+	r.Error(err)
+	r.NoError(run1.Unlock(ctx, nil, v, &mock.Action{}))
+}
+
+func TestRenewAndRelease(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	past := metav1.MicroTime{Time: time.Now().Add(-time.Minute)}
+	newLease := func(name, holder, label string) *coordinationv1.Lease {
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{LabelLockHolder: label}},
+			Spec:       coordinationv1.LeaseSpec{HolderIdentity: pointer.String(holder), RenewTime: &past},
+		}
+	}
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		newLease("held", "uid-1", "uid-1"),
+		// the lease is held by the holder identity regardless of the label
+		newLease("taken-over", "uid-2", "uid-1"),
+		newLease("other", "uid-2", "uid-2"),
+	).Build()
+
+	interval, err := Renew(ctx, cli, "default", "uid-1")
+	r.NoError(err)
+	r.Equal(defaultTTL/2, interval)
+	lease := &coordinationv1.Lease{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "held"}, lease))
+	r.True(lease.Spec.RenewTime.After(past.Time))
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "taken-over"}, lease))
+	r.True(lease.Spec.RenewTime.Time.Before(time.Now().Add(-30 * time.Second)))
+
+	// the locks are renewed in half of the shortest ttl of them
+	r.NoError(cli.Create(ctx, &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: "short", Namespace: "default", Labels: map[string]string{LabelLockHolder: "uid-1"}},
+		Spec:       coordinationv1.LeaseSpec{HolderIdentity: pointer.String("uid-1"), RenewTime: &past, LeaseDurationSeconds: pointer.Int32(30)},
+	}))
+	interval, err = Renew(ctx, cli, "default", "uid-1")
+	r.NoError(err)
+	r.Equal(15*time.Second, interval)
+	interval, err = Renew(ctx, cli, "default", "uid-3")
+	r.NoError(err)
+	r.Zero(interval)
+
+	r.NoError(Release(ctx, cli, "default", "uid-1"))
+	r.Error(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "held"}, lease))
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "taken-over"}, lease))
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "other"}, lease))
+}
@@ -19,8 +19,11 @@ package providers
 import (
 	"testing"
 
+	monitorContext "github.com/kubevela/pkg/monitor/context"
 	"github.com/stretchr/testify/require"
 
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
 	"github.com/kubevela/workflow/pkg/types"
 )
 
@@ -41,3 +44,27 @@ func TestProvers(t *testing.T) {
 	_, found = p.GetHandler("test", "fly")
 	r.Equal(found, false)
 }
+
+func TestRegistry(t *testing.T) {
+	r := require.New(t)
+	ReserveBuiltin("kube")
+	registry := NewRegistry()
+	r.NoError(registry.Register("myorg", map[string]Handler{
+		"do-thing": func(_ monitorContext.Context, _ wfContext.Context, _ *value.Value, _ types.Action) error {
+			return nil
+		},
+	}))
+	err := registry.Register("myorg", map[string]Handler{})
+	r.EqualError(err, "provider myorg is already registered")
+	err = registry.Register("kube", map[string]Handler{})
+	r.EqualError(err, "provider kube conflicts with the builtin provider")
+	err = registry.Register("nil", map[string]Handler{"foo": nil})
+	r.EqualError(err, "handler of op foo in provider nil is nil")
+
+	p := NewProviders()
+	registry.InstallTo(p)
+	_, found := p.GetHandler("myorg", "do-thing")
+	r.Equal(found, true)
+	_, found = p.GetHandler("nil", "foo")
+	r.Equal(found, false)
+}
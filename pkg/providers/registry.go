@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// Handler is the handler of the op in the provider, it's called with the step value when the op is executed.
+type Handler = types.Handler
+
+var (
+	builtinLock sync.RWMutex
+	// builtinProviders is the names of the builtin providers installed by the generator
+	builtinProviders = map[string]bool{}
+)
+
+// ReserveBuiltin reserves the names of the builtin providers, which can not be registered to the registries.
+// The generator reserves the providers it installs.
+func ReserveBuiltin(names ...string) {
+	builtinLock.Lock()
+	defer builtinLock.Unlock()
+	for _, name := range names {
+		builtinProviders[name] = true
+	}
+}
+
+func isBuiltin(name string) bool {
+	builtinLock.RLock()
+	defer builtinLock.RUnlock()
+	return builtinProviders[name]
+}
+
+// Registry keeps the providers registered by the users who embed the workflow engine,
+// the registered providers are installed with the builtin ones when generating the task runners.
+type Registry struct {
+	l sync.RWMutex
+	m map[string]map[string]Handler
+}
+
+// DefaultRegistry is the registry used by Register
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty provider registry.
+func NewRegistry() *Registry {
+	return &Registry{m: map[string]map[string]Handler{}}
+}
+
+// Register registers the provider with its handlers, the ops of the provider can be called in cue with
+// `#provider: name` and `#do: op`. The provider that is builtin or already registered is rejected.
+func (r *Registry) Register(name string, handlers map[string]Handler) error {
+	if name == "" {
+		return errors.New("provider name is required")
+	}
+	if isBuiltin(name) {
+		return errors.Errorf("provider %s conflicts with the builtin provider", name)
+	}
+	r.l.Lock()
+	defer r.l.Unlock()
+	if _, ok := r.m[name]; ok {
+		return errors.Errorf("provider %s is already registered", name)
+	}
+	m := make(map[string]Handler, len(handlers))
+	for op, h := range handlers {
+		if h == nil {
+			return errors.Errorf("handler of op %s in provider %s is nil", op, name)
+		}
+		m[op] = h
+	}
+	r.m[name] = m
+	return nil
+}
+
+// InstallTo installs the registered providers to p.
+func (r *Registry) InstallTo(p types.Providers) {
+	r.l.RLock()
+	defer r.l.RUnlock()
+	for name, handlers := range r.m {
+		p.Register(name, handlers)
+	}
+}
+
+// Register registers the provider to the default registry.
+func Register(name string, handlers map[string]Handler) error {
+	return DefaultRegistry.Register(name, handlers)
+}
@@ -0,0 +1,105 @@
+/*
+ Copyright 2022. The KubeVela Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/pkg/errors"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// MissingKeyError fails the format if the template refers to a key missing in the data
+	MissingKeyError = "error"
+	// MissingKeyDefault renders the missing keys as "<no value>"
+	MissingKeyDefault = "default"
+	// EscapeNone renders the data as is
+	EscapeNone = "none"
+	// EscapeHTML escapes the data for HTML, e.g. the messages shown in the web pages
+	EscapeHTML = "html"
+)
+
+type formatParams struct {
+	Template   string `json:"template"`
+	MissingKey string `json:"missingKey"`
+	Escape     string `json:"escape"`
+}
+
+// executor is the template of text/template or html/template
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// Format renders the go template with the data into a string, e.g. the messages of the wait and the fail ops.
+// The missing keys fail the format by default so that the typos in the template are caught.
+func (p *provider) Format(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	params := &formatParams{}
+	if err := v.UnmarshalTo(params, value.StrictFor(v, "data", "result")); err != nil {
+		return err
+	}
+	if params.MissingKey == "" {
+		params.MissingKey = MissingKeyError
+	}
+	if params.MissingKey != MissingKeyError && params.MissingKey != MissingKeyDefault {
+		return errors.Errorf("invalid missingKey %s, it's either %s or %s", params.MissingKey, MissingKeyError, MissingKeyDefault)
+	}
+	var data interface{}
+	if dv, err := v.LookupValue("data"); err == nil {
+		b, err := dv.CueValue().MarshalJSON()
+		if err != nil {
+			return errors.WithMessage(err, "encode the data")
+		}
+		// keep the numbers as they are written instead of the float64
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.UseNumber()
+		if err := decoder.Decode(&data); err != nil {
+			return errors.WithMessage(err, "decode the data")
+		}
+	}
+	option := "missingkey=" + params.MissingKey
+	var (
+		tmpl executor
+		err  error
+	)
+	switch params.Escape {
+	case "", EscapeNone:
+		tmpl, err = texttemplate.New("format").Option(option).Parse(params.Template)
+	case EscapeHTML:
+		tmpl, err = htmltemplate.New("format").Option(option).Parse(params.Template)
+	default:
+		return errors.Errorf("invalid escape %s, it's either %s or %s", params.Escape, EscapeNone, EscapeHTML)
+	}
+	if err != nil {
+		return errors.WithMessage(err, "parse the template")
+	}
+	sb := &strings.Builder{}
+	if err := tmpl.Execute(sb, data); err != nil {
+		return errors.WithMessage(err, "render the template")
+	}
+	return v.FillObject(sb.String(), "result")
+}
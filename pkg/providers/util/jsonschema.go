@@ -0,0 +1,262 @@
+/*
+ Copyright 2022. The KubeVela Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// jsonSchema is the subset of the JSON Schema draft-07 used to validate the values, the keywords not listed are ignored
+type jsonSchema struct {
+	Type                 schemaTypes            `json:"type,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Const                *interface{}           `json:"const,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties *additionalProperties  `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// schemaTypes is the type keyword, which is either a type or a list of types
+type schemaTypes []string
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (t *schemaTypes) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return errors.New("type is either a string or a list of strings")
+	}
+	*t = multiple
+	return nil
+}
+
+// additionalProperties is either a boolean or a schema of the properties not declared in properties
+type additionalProperties struct {
+	Allowed bool
+	Schema  *jsonSchema
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (a *additionalProperties) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.Allowed); err == nil {
+		return nil
+	}
+	a.Allowed = true
+	return json.Unmarshal(b, &a.Schema)
+}
+
+// validationError is a violation of the schema at the path of the value
+type validationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// parseJSONSchema parses the JSON Schema and compiles the patterns in it
+func parseJSONSchema(src []byte) (*jsonSchema, error) {
+	schema := &jsonSchema{}
+	if err := json.Unmarshal(src, schema); err != nil {
+		return nil, errors.WithMessage(err, "parse the json schema")
+	}
+	if err := schema.compile(""); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+func (s *jsonSchema) compile(path string) error {
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return errors.WithMessagef(err, "compile the pattern of %s", displayPath(path))
+		}
+		s.pattern = pattern
+	}
+	for name, prop := range s.Properties {
+		if err := prop.compile(joinPath(path, name)); err != nil {
+			return err
+		}
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+		if err := s.AdditionalProperties.Schema.compile(joinPath(path, "*")); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile(path + "[*]")
+	}
+	return nil
+}
+
+// validate returns all the violations of the schema in the value decoded from JSON
+func (s *jsonSchema) validate(path string, v interface{}, errs *[]validationError) {
+	report := func(format string, args ...interface{}) {
+		*errs = append(*errs, validationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if len(s.Type) > 0 && !s.matchType(v) {
+		report("expected %s, but got %s", strings.Join(s.Type, " or "), typeOf(v))
+		return
+	}
+	if len(s.Enum) > 0 && !containsValue(s.Enum, v) {
+		report("value must be one of %s", encode(s.Enum))
+	}
+	if s.Const != nil && !reflect.DeepEqual(*s.Const, v) {
+		report("value must be %s", encode(*s.Const))
+	}
+	switch val := v.(type) {
+	case string:
+		length := len([]rune(val))
+		if s.MinLength != nil && length < *s.MinLength {
+			report("length must be at least %d", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			report("length must be at most %d", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(val) {
+			report("value %q does not match the pattern %q", val, s.Pattern)
+		}
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			report("value must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && val > *s.Maximum {
+			report("value must be at most %v", *s.Maximum)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			report("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(val) > *s.MaxItems {
+			report("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range val {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				*errs = append(*errs, validationError{Path: joinPath(path, name), Message: "field is required"})
+			}
+		}
+		names := make([]string, 0, len(val))
+		for name := range val {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := s.Properties[name]; ok {
+				prop.validate(joinPath(path, name), val[name], errs)
+				continue
+			}
+			if additional := s.AdditionalProperties; additional != nil {
+				if !additional.Allowed {
+					*errs = append(*errs, validationError{Path: joinPath(path, name), Message: "field is not allowed"})
+				} else if additional.Schema != nil {
+					additional.Schema.validate(joinPath(path, name), val[name], errs)
+				}
+			}
+		}
+	}
+}
+
+func (s *jsonSchema) matchType(v interface{}) bool {
+	actual := typeOf(v)
+	for _, t := range s.Type {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON Schema type of the value decoded from JSON
+func typeOf(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if val == math.Trunc(val) && !math.IsInf(val, 0) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, value := range values {
+		if reflect.DeepEqual(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func encode(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// joinPath appends the field to the path in the format of the lookup op, e.g. `spec.containers[0].image`
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "the value"
+	}
+	return path
+}
@@ -0,0 +1,159 @@
+/*
+ Copyright 2022. The KubeVela Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	cueerrors "cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/parser"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// templateCacheSize is the max number of the compiled templates of the ConfigMaps cached
+	templateCacheSize = 100
+	// templateCacheTTL is the ttl of the compiled template, the entries of the old resource versions are
+	// evicted by the lru or expired after it
+	templateCacheTTL = time.Hour
+)
+
+var templateCache = cache.NewLRUExpireCache(templateCacheSize)
+
+// compiledTemplate is the template compiled in its own runtime, the runtime is not safe for the concurrent use
+// so the renders of the template are serialized
+type compiledTemplate struct {
+	mu  sync.Mutex
+	val cue.Value
+}
+
+type templateRef struct {
+	ConfigMap string `json:"configMap"`
+	Key       string `json:"key"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type renderParams struct {
+	TemplateRef *templateRef    `json:"templateRef,omitempty"`
+	Inline      string          `json:"inline,omitempty"`
+	Values      json.RawMessage `json:"values,omitempty"`
+}
+
+// Render unifies the CUE template in the ConfigMap or inline with the values, the result is required to be concrete.
+// The templates of the ConfigMaps are compiled once for each resource version.
+func (p *provider) Render(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	params := &renderParams{}
+	if err := v.UnmarshalTo(params, value.StrictFor(v, "result")); err != nil {
+		return err
+	}
+	if (params.TemplateRef == nil) == (params.Inline == "") {
+		return errors.New("either templateRef or inline is required to render the template")
+	}
+	var (
+		tmpl *compiledTemplate
+		err  error
+	)
+	if params.TemplateRef != nil {
+		tmpl, err = p.loadTemplate(ctx, params.TemplateRef)
+	} else {
+		tmpl, err = compileTemplate("inline", params.Inline)
+	}
+	if err != nil {
+		return err
+	}
+	result, err := tmpl.render(params.Values)
+	if err != nil {
+		return err
+	}
+	return v.FillRaw(result, "result")
+}
+
+func (p *provider) loadTemplate(ctx monitorContext.Context, ref *templateRef) (*compiledTemplate, error) {
+	if ref.Namespace == "" {
+		ref.Namespace = p.ns
+	}
+	cm := &corev1.ConfigMap{}
+	if err := p.cli.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.ConfigMap}, cm); err != nil {
+		return nil, errors.WithMessagef(err, "get the template configmap %s/%s", ref.Namespace, ref.ConfigMap)
+	}
+	src, ok := cm.Data[ref.Key]
+	if !ok {
+		return nil, errors.Errorf("key %s not found in the template configmap %s/%s", ref.Key, ref.Namespace, ref.ConfigMap)
+	}
+	// the resource version changes when the configmap is edited, so the stale entry is never hit
+	key := fmt.Sprintf("%s/%s@%s/%s", ref.Namespace, ref.ConfigMap, cm.ResourceVersion, ref.Key)
+	if tmpl, ok := templateCache.Get(key); ok {
+		return tmpl.(*compiledTemplate), nil
+	}
+	tmpl, err := compileTemplate(fmt.Sprintf("%s/%s/%s", ref.Namespace, ref.ConfigMap, ref.Key), src)
+	if err != nil {
+		return nil, err
+	}
+	if cm.ResourceVersion != "" {
+		templateCache.Add(key, tmpl, templateCacheTTL)
+	}
+	return tmpl, nil
+}
+
+// compileTemplate compiles the template with the filename in the positions of the errors
+func compileTemplate(filename, src string) (*compiledTemplate, error) {
+	file, err := parser.ParseFile(filename, src, parser.ParseComments)
+	if err == nil {
+		val := cuecontext.New().BuildFile(file)
+		if err = val.Err(); err == nil {
+			return &compiledTemplate{val: val}, nil
+		}
+	}
+	return nil, errors.Errorf("compile the template %s: %s", filename, strings.TrimSpace(cueerrors.Details(err, nil)))
+}
+
+func (t *compiledTemplate) render(values json.RawMessage) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	val := t.val
+	if len(values) > 0 {
+		val = val.Unify(val.Context().CompileBytes(values))
+	}
+	if err := val.Err(); err != nil {
+		return "", errors.Errorf("render the template: %s", strings.TrimSpace(cueerrors.Details(err, nil)))
+	}
+	var incomplete []string
+	collectIncompleteFields(val, "", &incomplete)
+	if len(incomplete) > 0 {
+		return "", errors.Errorf("the rendered template is not concrete, the incomplete fields are %s", strings.Join(incomplete, ", "))
+	}
+	b, err := val.MarshalJSON()
+	if err != nil {
+		return "", errors.Errorf("render the template: %s", strings.TrimSpace(cueerrors.Details(err, nil)))
+	}
+	return string(b), nil
+}
@@ -19,8 +19,13 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+
+	"cuelang.org/go/cue"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/cue/model"
@@ -35,6 +40,8 @@ const (
 )
 
 type provider struct {
+	cli  client.Client
+	ns   string
 	pCtx process.Context
 }
 
@@ -75,16 +82,128 @@ func (p *provider) String(ctx monitorContext.Context, wfCtx wfContext.Context, v
 	return v.FillObject(string(s), "str")
 }
 
+// Diff compares the left and right values and reports the changes
+func (p *provider) Diff(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	left, err := v.LookupValue("left")
+	if err != nil {
+		return err
+	}
+	right, err := v.LookupValue("right")
+	if err != nil {
+		return err
+	}
+	var ignore []string
+	if ignoreV, err := v.LookupValue("ignore"); err == nil {
+		if err := ignoreV.UnmarshalTo(&ignore); err != nil {
+			return err
+		}
+	}
+	changes, err := value.Diff(left, right, value.DiffIgnore(ignore...))
+	if err != nil {
+		return err
+	}
+	if changes == nil {
+		changes = value.Changes{}
+	}
+	if err := v.FillObject(changes.Changed(), "changed"); err != nil {
+		return err
+	}
+	// fill the json of the changes to keep the numbers in the values
+	b, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	return v.FillRaw(string(b), "changes")
+}
+
+// Lookup selects the field of the value by the path, e.g. `spec.template.spec.containers[0].image`
+func (p *provider) Lookup(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	val, err := v.LookupValue("value")
+	if err != nil {
+		return err
+	}
+	path, err := v.GetString("path")
+	if err != nil {
+		return err
+	}
+	result, err := val.LookupValue(value.SplitPath(path)...)
+	if err != nil {
+		if value.IsNotFound(err) {
+			return v.FillObject(false, "found")
+		}
+		return err
+	}
+	b, err := result.CueValue().MarshalJSON()
+	if err != nil {
+		return err
+	}
+	if err := v.FillRaw(string(b), "result"); err != nil {
+		return err
+	}
+	return v.FillObject(true, "found")
+}
+
+// Concrete outputs the value as result if it's concrete, otherwise the paths of the incomplete fields are returned
+func (p *provider) Concrete(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	val, err := v.LookupValue("value")
+	if err != nil {
+		return err
+	}
+	var incomplete []string
+	collectIncompleteFields(val.CueValue(), "", &incomplete)
+	if len(incomplete) > 0 {
+		return v.FillObject(incomplete, "incomplete")
+	}
+	b, err := val.CueValue().MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return v.FillRaw(string(b), "result")
+}
+
+func collectIncompleteFields(v cue.Value, path string, incomplete *[]string) {
+	if d, ok := v.Default(); ok {
+		v = d
+	}
+	join := func(sel string) string {
+		if path == "" {
+			return sel
+		}
+		return path + "." + sel
+	}
+	switch v.IncompleteKind() {
+	case cue.StructKind:
+		iter, err := v.Fields()
+		if err != nil {
+			*incomplete = append(*incomplete, path)
+			return
+		}
+		for iter.Next() {
+			collectIncompleteFields(iter.Value(), join(iter.Selector().String()), incomplete)
+		}
+	case cue.ListKind:
+		iter, err := v.List()
+		if err != nil {
+			*incomplete = append(*incomplete, path)
+			return
+		}
+		for i := 0; iter.Next(); i++ {
+			collectIncompleteFields(iter.Value(), path+"["+strconv.Itoa(i)+"]", incomplete)
+		}
+	default:
+		if err := v.Validate(cue.Concrete(true)); err != nil {
+			*incomplete = append(*incomplete, path)
+		}
+	}
+}
+
 // Log print cue value in log
 func (p *provider) Log(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
 	stepName := fmt.Sprint(p.pCtx.GetData(model.ContextStepName))
 	stepID := fmt.Sprint(p.pCtx.GetData(model.ContextStepSessionID))
-	config := make(map[string]types.LogConfig)
-	c := wfCtx.GetMutableValue(types.ContextKeyLogConfig)
-	if c != "" {
-		if err := json.Unmarshal([]byte(c), &config); err != nil {
-			return err
-		}
+	config, err := loadLogConfig(wfCtx)
+	if err != nil {
+		return err
 	}
 	stepConfig := config[stepName]
 	data, err := v.LookupValue("data")
@@ -108,6 +227,40 @@ func (p *provider) Log(ctx monitorContext.Context, wfCtx wfContext.Context, v *v
 		}
 	}
 	config[stepName] = stepConfig
+	return saveLogConfig(wfCtx, config)
+}
+
+// CollectLogs records the config to collect the logs of the pods when the step is finished
+func (p *provider) CollectLogs(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	collect := &types.CollectLogs{}
+	if err := v.UnmarshalTo(collect, value.StrictFor(v)); err != nil {
+		return err
+	}
+	if (collect.PodSelector == nil) == (collect.Resource == nil) {
+		return errors.New("either podSelector or resource is required to collect logs")
+	}
+	stepName := fmt.Sprint(p.pCtx.GetData(model.ContextStepName))
+	config, err := loadLogConfig(wfCtx)
+	if err != nil {
+		return err
+	}
+	stepConfig := config[stepName]
+	stepConfig.Collect = collect
+	config[stepName] = stepConfig
+	return saveLogConfig(wfCtx, config)
+}
+
+func loadLogConfig(wfCtx wfContext.Context) (map[string]types.LogConfig, error) {
+	config := make(map[string]types.LogConfig)
+	if c := wfCtx.GetMutableValue(types.ContextKeyLogConfig); c != "" {
+		if err := json.Unmarshal([]byte(c), &config); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+func saveLogConfig(wfCtx wfContext.Context, config map[string]types.LogConfig) error {
 	b, err := json.Marshal(config)
 	if err != nil {
 		return err
@@ -163,13 +316,22 @@ func setSourceInLog(source *value.Value, stepConfig *types.LogConfig) error {
 }
 
 // Install register handlers to provider discover.
-func Install(p types.Providers, pCtx process.Context) {
+func Install(p types.Providers, cli client.Client, ns string, pCtx process.Context) {
 	prd := &provider{
+		cli:  cli,
+		ns:   ns,
 		pCtx: pCtx,
 	}
 	p.Register(ProviderName, map[string]types.Handler{
 		"patch-k8s-object": prd.PatchK8sObject,
 		"string":           prd.String,
 		"log":              prd.Log,
+		"collect-logs":     prd.CollectLogs,
+		"diff":             prd.Diff,
+		"lookup":           prd.Lookup,
+		"concrete":         prd.Concrete,
+		"render":           prd.Render,
+		"format":           prd.Format,
+		"validate":         prd.Validate,
 	})
 }
@@ -21,10 +21,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/yaml"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
@@ -201,6 +204,372 @@ func TestConvertString(t *testing.T) {
 	}
 }
 
+func TestDiff(t *testing.T) {
+	testCases := map[string]struct {
+		from    string
+		changed bool
+		changes string
+	}{
+		"changed": {
+			from: `
+left: {metadata: name: "a", spec: replicas: 1, status: ready: true}
+right: {metadata: name: "a", spec: replicas: 3}
+ignore: ["status"]
+`,
+			changed: true,
+			changes: `[{
+	path:   "spec.replicas"
+	type:   "modified"
+	before: 1
+	after:  3
+}]`,
+		},
+		"unchanged": {
+			from: `
+left: {metadata: name: "a"}
+right: {metadata: name: "a"}
+`,
+			changes: `[]`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			v, err := value.NewValue(tc.from, nil, "")
+			r.NoError(err)
+			prd := &provider{}
+			r.NoError(prd.Diff(nil, nil, v, nil))
+			changed, err := v.GetBool("changed")
+			r.NoError(err)
+			r.Equal(tc.changed, changed)
+			changes, err := v.LookupValue("changes")
+			r.NoError(err)
+			s, err := changes.String()
+			r.NoError(err)
+			r.Equal(tc.changes, strings.TrimSpace(s))
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	r := require.New(t)
+	prd := &provider{}
+	v, err := value.NewValue(`
+value: spec: containers: [{name: "app", image: "app:v1"}, {name: "sidecar", image: "sidecar:v1"}]
+path: "spec.containers[1].image"
+`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.Lookup(nil, nil, v, nil))
+	found, err := v.GetBool("found")
+	r.NoError(err)
+	r.True(found)
+	image, err := v.GetString("result")
+	r.NoError(err)
+	r.Equal("sidecar:v1", image)
+
+	v, err = value.NewValue(`
+value: spec: containers: [{name: "app", image: "app:v1"}]
+path: "spec.replicas"
+`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.Lookup(nil, nil, v, nil))
+	found, err = v.GetBool("found")
+	r.NoError(err)
+	r.False(found)
+	_, err = v.LookupValue("result")
+	r.Error(err)
+}
+
+func TestConcrete(t *testing.T) {
+	r := require.New(t)
+	prd := &provider{}
+	v, err := value.NewValue(`
+value: {
+	name:     "app"
+	replicas: *1 | int
+	ports: [80, 443]
+}
+`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.Concrete(nil, nil, v, nil))
+	result, err := v.LookupValue("result")
+	r.NoError(err)
+	b, err := result.CueValue().MarshalJSON()
+	r.NoError(err)
+	r.Equal(`{"name":"app","replicas":1,"ports":[80,443]}`, string(b))
+
+	v, err = value.NewValue(`
+value: {
+	name: string
+	spec: "image-name": string
+	ports: [80, int]
+	optional?: string
+}
+`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.Concrete(nil, nil, v, nil))
+	var incomplete []string
+	incompleteValue, err := v.LookupValue("incomplete")
+	r.NoError(err)
+	r.NoError(incompleteValue.UnmarshalTo(&incomplete))
+	r.Equal([]string{"name", `spec."image-name"`, "ports[1]"}, incomplete)
+	_, err = v.LookupValue("result")
+	r.Error(err)
+}
+
+func TestRender(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "templates", Namespace: "default", ResourceVersion: "1"},
+		Data: map[string]string{
+			"labels": `
+name: string
+labels: {
+	"app.oam.dev/name": name
+	"app.oam.dev/team": *"platform" | string
+}
+`,
+			"invalid": `
+name: string
+name: 1
+`,
+		},
+	}
+	prd := &provider{cli: fake.NewClientBuilder().WithObjects(cm).Build(), ns: "default"}
+	testCases := map[string]struct {
+		params   string
+		expected string
+		err      string
+	}{
+		"configmap": {
+			params:   `templateRef: {configMap: "templates", key: "labels"}, values: name: "app"`,
+			expected: `{"name":"app","labels":{"app.oam.dev/name":"app","app.oam.dev/team":"platform"}}`,
+		},
+		"inline": {
+			params:   `inline: "replicas: *1 | int", values: replicas: 3`,
+			expected: `{"replicas":3}`,
+		},
+		"not concrete": {
+			params: `templateRef: {configMap: "templates", key: "labels"}`,
+			err:    "the rendered template is not concrete, the incomplete fields are name, labels.\"app.oam.dev/name\"",
+		},
+		"compile error": {
+			params: `templateRef: {configMap: "templates", key: "invalid"}`,
+			err:    "compile the template default/templates/invalid: name: conflicting values string and 1 (mismatched types string and int):\n    default/templates/invalid:2:7\n    default/templates/invalid:3:7",
+		},
+		"key not found": {
+			params: `templateRef: {configMap: "templates", key: "other"}`,
+			err:    "key other not found in the template configmap default/templates",
+		},
+		"no template": {
+			params: `values: name: "app"`,
+			err:    "either templateRef or inline is required to render the template",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			v, err := value.NewValue(tc.params, nil, "")
+			r.NoError(err)
+			err = prd.Render(nil, nil, v, nil)
+			if tc.err != "" {
+				r.EqualError(err, tc.err)
+				return
+			}
+			r.NoError(err)
+			result, err := v.LookupValue("result")
+			r.NoError(err)
+			b, err := result.CueValue().MarshalJSON()
+			r.NoError(err)
+			r.Equal(tc.expected, string(b))
+		})
+	}
+	_, ok := templateCache.Get("default/templates@1/labels")
+	require.True(t, ok)
+}
+
+func TestFormat(t *testing.T) {
+	prd := &provider{}
+	testCases := map[string]struct {
+		params   string
+		expected string
+		err      string
+	}{
+		"format": {
+			params:   `template: "waiting for {{.ready}}/{{.total}} replicas", data: {ready: 2, total: 1000000}`,
+			expected: "waiting for 2/1000000 replicas",
+		},
+		"nested data": {
+			params:   `template: "{{.app.name}}: {{range .errors}}{{.}};{{end}}", data: {app: name: "web", errors: ["a", "b"]}`,
+			expected: "web: a;b;",
+		},
+		"no data": {
+			params:   `template: "done"`,
+			expected: "done",
+		},
+		"missing key": {
+			params: `template: "waiting for {{.ready}}/{{.totl}} replicas", data: {ready: 2, total: 3}`,
+			err:    "render the template: template: format:1:25: executing \"format\" at <.totl>: map has no entry for key \"totl\"",
+		},
+		"missing key as default": {
+			params:   `template: "waiting for {{.ready}}/{{.totl}} replicas", data: {ready: 2, total: 3}, missingKey: "default"`,
+			expected: "waiting for 2/<no value> replicas",
+		},
+		"escape html": {
+			params:   `template: "<b>{{.message}}</b>", data: message: "a < b", escape: "html"`,
+			expected: "<b>a &lt; b</b>",
+		},
+		"not escaped": {
+			params:   `template: "<b>{{.message}}</b>", data: message: "a < b"`,
+			expected: "<b>a < b</b>",
+		},
+		"strict": {
+			params: `template: "done", data: {ready: 2}, missingKeys: "default", strict: true`,
+			err:    `unknown fields: "missingKeys" (did you mean "missingKey"?)`,
+		},
+		"parse error": {
+			params: `template: "{{.ready"`,
+			err:    "parse the template: template: format:1: unclosed action",
+		},
+		"invalid escape": {
+			params: `template: "", escape: "json"`,
+			err:    "invalid escape json, it's either none or html",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			v, err := value.NewValue(tc.params, nil, "")
+			r.NoError(err)
+			err = prd.Format(nil, nil, v, nil)
+			if tc.err != "" {
+				r.EqualError(err, tc.err)
+				return
+			}
+			r.NoError(err)
+			result, err := v.GetString("result")
+			r.NoError(err)
+			r.Equal(tc.expected, result)
+		})
+	}
+}
+
+type mockAction struct {
+	fail    bool
+	message string
+}
+
+func (act *mockAction) Suspend(message string) {}
+
+func (act *mockAction) Terminate(message string) {}
+
+func (act *mockAction) Wait(message string) {}
+
+func (act *mockAction) Fail(message string) {
+	act.fail = true
+	act.message = message
+}
+
+func (act *mockAction) Message(message string) {}
+
+func TestValidate(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "schemas", Namespace: "default"},
+		Data: map[string]string{
+			"app": `{
+	"type": "object",
+	"required": ["name", "replicas"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "pattern": "^[a-z-]+$"},
+		"replicas": {"type": "integer", "minimum": 1},
+		"tier": {"enum": ["frontend", "backend"]},
+		"ports": {"type": "array", "items": {"type": "integer"}}
+	}
+}`,
+			"invalid": `{"type": 1}`,
+		},
+	}
+	prd := &provider{cli: fake.NewClientBuilder().WithObjects(cm).Build(), ns: "default"}
+	testCases := map[string]struct {
+		params  string
+		valid   bool
+		errors  []validationError
+		failMsg string
+		err     string
+	}{
+		"cue schema": {
+			params: `schema: "name: string, replicas: int & >=1", value: {name: "web", replicas: 2}`,
+			valid:  true,
+			errors: []validationError{},
+		},
+		"cue schema violated": {
+			params: `schema: "name: string, replicas: int & >=1, image: string", value: {name: "web", replicas: 0}`,
+			errors: []validationError{{Path: "replicas", Message: "invalid value 0 (out of bound >=1)"}},
+		},
+		"cue schema incomplete": {
+			params: `schema: "name: string, image: string", value: name: "web"`,
+			errors: []validationError{{Path: "image", Message: "incomplete value string"}},
+		},
+		"json schema": {
+			params: `jsonSchemaRef: {configMap: "schemas", key: "app"}, value: {name: "web", replicas: 2, tier: "backend", ports: [80]}`,
+			valid:  true,
+			errors: []validationError{},
+		},
+		"json schema violated": {
+			params: `jsonSchemaRef: {configMap: "schemas", key: "app"}, value: {name: "Web", tier: "db", ports: [80, "443"], image: "nginx"}`,
+			errors: []validationError{
+				{Path: "replicas", Message: "field is required"},
+				{Path: "image", Message: "field is not allowed"},
+				{Path: "name", Message: `value "Web" does not match the pattern "^[a-z-]+$"`},
+				{Path: "ports[1]", Message: "expected integer, but got string"},
+				{Path: "tier", Message: `value must be one of ["frontend","backend"]`},
+			},
+		},
+		"fail on invalid": {
+			params:  `schema: "replicas: int & >=1", value: replicas: 0, failOnInvalid: true`,
+			errors:  []validationError{{Path: "replicas", Message: "invalid value 0 (out of bound >=1)"}},
+			failMsg: "the value is invalid: replicas: invalid value 0 (out of bound >=1)",
+		},
+		"invalid json schema": {
+			params: `jsonSchemaRef: {configMap: "schemas", key: "invalid"}, value: {}`,
+			err:    "json schema default/schemas/invalid: parse the json schema: type is either a string or a list of strings",
+		},
+		"key not found": {
+			params: `jsonSchemaRef: {configMap: "schemas", key: "other"}, value: {}`,
+			err:    "key other not found in the json schema configmap default/schemas",
+		},
+		"no schema": {
+			params: `value: {}`,
+			err:    "either schema or jsonSchemaRef is required to validate the value",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			v, err := value.NewValue(tc.params, nil, "")
+			r.NoError(err)
+			act := &mockAction{}
+			err = prd.Validate(nil, nil, v, act)
+			if tc.err != "" {
+				r.EqualError(err, tc.err)
+				return
+			}
+			r.NoError(err)
+			valid, err := v.GetBool("valid")
+			r.NoError(err)
+			r.Equal(tc.valid, valid)
+			errs, err := v.LookupValue("errors")
+			r.NoError(err)
+			violations := []validationError{}
+			r.NoError(errs.UnmarshalTo(&violations))
+			r.Equal(tc.errors, violations)
+			r.Equal(tc.failMsg != "", act.fail)
+			r.Equal(tc.failMsg, act.message)
+		})
+	}
+}
+
 func TestLog(t *testing.T) {
 	wfCtx := newWorkflowContextForTest(t)
 	pCtx := process.NewContext(process.ContextData{})
@@ -287,11 +656,65 @@ source: {
 	}
 }
 
+func TestCollectLogs(t *testing.T) {
+	wfCtx := newWorkflowContextForTest(t)
+	pCtx := process.NewContext(process.ContextData{})
+	pCtx.PushData(model.ContextStepName, "test-step")
+	prd := &provider{pCtx: pCtx}
+	logCtx := monitorContext.NewTraceContext(context.Background(), "")
+
+	testCases := []struct {
+		value       string
+		expected    string
+		expectedErr string
+	}{
+		{
+			value:       `container: "main"`,
+			expectedErr: "either podSelector or resource is required to collect logs",
+		},
+		{
+			value: `
+podSelector: matchLabels: app: "test"
+resource: {apiVersion: "batch/v1", kind: "Job", name: "test"}
+`,
+			expectedErr: "either podSelector or resource is required to collect logs",
+		},
+		{
+			value: `
+podSelector: matchLabels: app: "test"
+tailLines: 10
+`,
+			expected: `{"test-step":{"collect":{"podSelector":{"matchLabels":{"app":"test"}},"tailLines":10}}}`,
+		},
+		{
+			value: `
+resource: {apiVersion: "batch/v1", kind: "Job", name: "test", cluster: "local"}
+container: "main"
+`,
+			expected: `{"test-step":{"collect":{"resource":{"apiVersion":"batch/v1","kind":"Job","name":"test","cluster":"local"},"container":"main"}}}`,
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			r := require.New(t)
+			v, err := value.NewValue(tc.value, nil, "")
+			r.NoError(err)
+			err = prd.CollectLogs(logCtx, wfCtx, v, nil)
+			if tc.expectedErr != "" {
+				r.EqualError(err, tc.expectedErr)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tc.expected, wfCtx.GetMutableValue("logConfig"))
+		})
+	}
+}
+
 func TestInstall(t *testing.T) {
 	p := providers.NewProviders()
 	pCtx := process.NewContext(process.ContextData{})
 	pCtx.PushData(model.ContextStepName, "test-step")
-	Install(p, pCtx)
+	Install(p, nil, "default", pCtx)
 	h, ok := p.GetHandler("util", "string")
 	r := require.New(t)
 	r.Equal(ok, true)
@@ -0,0 +1,162 @@
+/*
+ Copyright 2022. The KubeVela Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	cueerrors "cuelang.org/go/cue/errors"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+type validateParams struct {
+	Schema        string       `json:"schema,omitempty"`
+	JSONSchemaRef *templateRef `json:"jsonSchemaRef,omitempty"`
+	FailOnInvalid bool         `json:"failOnInvalid,omitempty"`
+}
+
+// Validate validates the value against the inline CUE schema or the JSON Schema in the ConfigMap, all the violations
+// are reported in errors. The step fails with the violations if failOnInvalid is set, otherwise the template can
+// branch on valid.
+func (p *provider) Validate(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	params := &validateParams{}
+	if err := v.UnmarshalTo(params, value.StrictFor(v, "value", "valid", "errors")); err != nil {
+		return err
+	}
+	if (params.JSONSchemaRef == nil) == (params.Schema == "") {
+		return errors.New("either schema or jsonSchemaRef is required to validate the value")
+	}
+	val, err := v.LookupValue("value")
+	if err != nil {
+		return errors.WithMessage(err, "lookup the value to validate")
+	}
+	data, err := val.CueValue().MarshalJSON()
+	if err != nil {
+		return errors.WithMessage(err, "encode the value to validate")
+	}
+	var violations []validationError
+	if params.JSONSchemaRef != nil {
+		violations, err = p.validateJSONSchema(ctx, params.JSONSchemaRef, data)
+	} else {
+		violations, err = validateCUESchema(params.Schema, data)
+	}
+	if err != nil {
+		return err
+	}
+	if violations == nil {
+		violations = []validationError{}
+	}
+	if err := v.FillObject(len(violations) == 0, "valid"); err != nil {
+		return err
+	}
+	b, err := json.Marshal(violations)
+	if err != nil {
+		return err
+	}
+	if err := v.FillRaw(string(b), "errors"); err != nil {
+		return err
+	}
+	if params.FailOnInvalid && len(violations) > 0 {
+		messages := make([]string, 0, len(violations))
+		for _, violation := range violations {
+			if violation.Path == "" {
+				messages = append(messages, violation.Message)
+				continue
+			}
+			messages = append(messages, fmt.Sprintf("%s: %s", violation.Path, violation.Message))
+		}
+		act.Fail(fmt.Sprintf("the value is invalid: %s", strings.Join(messages, "; ")))
+	}
+	return nil
+}
+
+func (p *provider) validateJSONSchema(ctx monitorContext.Context, ref *templateRef, data []byte) ([]validationError, error) {
+	if ref.Namespace == "" {
+		ref.Namespace = p.ns
+	}
+	cm := &corev1.ConfigMap{}
+	if err := p.cli.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.ConfigMap}, cm); err != nil {
+		return nil, errors.WithMessagef(err, "get the json schema configmap %s/%s", ref.Namespace, ref.ConfigMap)
+	}
+	src, ok := cm.Data[ref.Key]
+	if !ok {
+		return nil, errors.Errorf("key %s not found in the json schema configmap %s/%s", ref.Key, ref.Namespace, ref.ConfigMap)
+	}
+	schema, err := parseJSONSchema([]byte(src))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "json schema %s/%s/%s", ref.Namespace, ref.ConfigMap, ref.Key)
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, errors.WithMessage(err, "decode the value to validate")
+	}
+	var violations []validationError
+	schema.validate("", v, &violations)
+	return violations, nil
+}
+
+// validateCUESchema unifies the value with the CUE schema, the conflicts and the fields left incomplete by the value
+// are the violations
+func validateCUESchema(schema string, data []byte) ([]validationError, error) {
+	cuectx := cuecontext.New()
+	s := cuectx.CompileString(schema, cue.Filename("schema"))
+	if err := s.Err(); err != nil {
+		return nil, errors.Errorf("compile the schema: %s", strings.TrimSpace(cueerrors.Details(err, nil)))
+	}
+	unified := s.Unify(cuectx.CompileBytes(data))
+	err := unified.Validate(cue.Concrete(true), cue.All())
+	if err == nil {
+		return nil, nil
+	}
+	var violations []validationError
+	seen := map[validationError]bool{}
+	for _, e := range cueerrors.Errors(err) {
+		format, args := e.Msg()
+		violation := validationError{Path: cuePath(e.Path()), Message: fmt.Sprintf(format, args...)}
+		if !seen[violation] {
+			seen[violation] = true
+			violations = append(violations, violation)
+		}
+	}
+	return violations, nil
+}
+
+// cuePath formats the selectors of the CUE error in the format of the lookup op, e.g. `spec.containers[0].image`
+func cuePath(selectors []string) string {
+	path := ""
+	for _, sel := range selectors {
+		if _, err := strconv.Atoi(sel); err == nil {
+			path += fmt.Sprintf("[%s]", sel)
+			continue
+		}
+		path = joinPath(path, sel)
+	}
+	return path
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+type runMetadata struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type patchMetadataParams struct {
+	// the nil values remove the keys
+	Labels      map[string]*string `json:"labels,omitempty"`
+	Annotations map[string]*string `json:"annotations,omitempty"`
+}
+
+// GetRunMetadata reads the labels and the annotations of the current workflow run
+func (h *provider) GetRunMetadata(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	run, err := h.getRun(ctx)
+	if err != nil {
+		return err
+	}
+	return v.FillObject(newRunMetadata(run), "result")
+}
+
+// PatchRunMetadata patches the labels and the annotations of the current workflow run with a merge patch, only the
+// keys under types.CustomRunMetadataPrefix can be patched. The metadata after the patch is filled in the result.
+func (h *provider) PatchRunMetadata(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	params := &patchMetadataParams{}
+	if err := v.UnmarshalTo(params, value.StrictFor(v, "result")); err != nil {
+		return err
+	}
+	if err := checkMetadataKeys("label", params.Labels); err != nil {
+		return err
+	}
+	if err := checkMetadataKeys("annotation", params.Annotations); err != nil {
+		return err
+	}
+	if len(params.Labels) == 0 && len(params.Annotations) == 0 {
+		return h.GetRunMetadata(ctx, wfCtx, v, act)
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": params})
+	if err != nil {
+		return err
+	}
+	run := &v1alpha1.WorkflowRun{}
+	run.Name, run.Namespace = h.meta.Name, h.meta.Namespace
+	if err := h.cli.Patch(ctx, run, client.RawPatch(k8stypes.MergePatchType, patch)); err != nil {
+		return errors.WithMessagef(err, "patch the metadata of the workflow run %s", h.key())
+	}
+	return v.FillObject(newRunMetadata(run), "result")
+}
+
+func (h *provider) getRun(ctx context.Context) (*v1alpha1.WorkflowRun, error) {
+	run := &v1alpha1.WorkflowRun{}
+	if err := h.cli.Get(ctx, h.key(), run); err != nil {
+		return nil, errors.WithMessagef(err, "get the workflow run %s", h.key())
+	}
+	return run, nil
+}
+
+func (h *provider) key() client.ObjectKey {
+	return client.ObjectKey{Namespace: h.meta.Namespace, Name: h.meta.Name}
+}
+
+func newRunMetadata(run *v1alpha1.WorkflowRun) runMetadata {
+	metadata := runMetadata{Labels: run.Labels, Annotations: run.Annotations}
+	if metadata.Labels == nil {
+		metadata.Labels = map[string]string{}
+	}
+	if metadata.Annotations == nil {
+		metadata.Annotations = map[string]string{}
+	}
+	return metadata
+}
+
+func checkMetadataKeys(kind string, m map[string]*string) error {
+	var denied []string
+	for k := range m {
+		if !strings.HasPrefix(k, types.CustomRunMetadataPrefix) || k == types.CustomRunMetadataPrefix {
+			denied = append(denied, k)
+		}
+	}
+	if len(denied) == 0 {
+		return nil
+	}
+	sort.Strings(denied)
+	return errors.Errorf("the %s keys %s are not allowed to patch, only the keys under %s are allowed", kind, strings.Join(denied, ", "), types.CustomRunMetadataPrefix)
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// ProviderName is provider name for install.
+	ProviderName = "workflow"
+)
+
+type provider struct {
+	cli  client.Client
+	meta types.WorkflowMeta
+}
+
+type createParams struct {
+	Name        string                   `json:"name"`
+	Namespace   string                   `json:"namespace,omitempty"`
+	Labels      map[string]string        `json:"labels,omitempty"`
+	Annotations map[string]string        `json:"annotations,omitempty"`
+	Spec        v1alpha1.WorkflowRunSpec `json:"spec"`
+	// Owner sets the parent run as the owner of the child run, so the child run is deleted with the parent run
+	Owner *bool `json:"owner,omitempty"`
+}
+
+// Create creates the child workflow run from the inline spec or the workflowRef, the run is annotated with the
+// parent run to reject the circular runs. The existing child run created by this run is kept as is.
+func (h *provider) Create(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	params := &createParams{}
+	if err := v.UnmarshalTo(params, value.StrictFor(v)); err != nil {
+		return err
+	}
+	if (params.Spec.WorkflowSpec == nil) == (params.Spec.WorkflowRef == "") {
+		return errors.New("either workflowSpec or workflowRef is required to create the workflow run")
+	}
+	key := client.ObjectKey{Namespace: params.Namespace, Name: params.Name}
+	if key.Namespace == "" {
+		key.Namespace = h.meta.Namespace
+	}
+	owner := params.Owner == nil || *params.Owner
+	if owner && key.Namespace != h.meta.Namespace {
+		return errors.Errorf("cannot own the workflow run %s in another namespace, set owner to false", key)
+	}
+	if err := h.checkCircular(ctx, key); err != nil {
+		return err
+	}
+
+	existing := &v1alpha1.WorkflowRun{}
+	err := h.cli.Get(ctx, key, existing)
+	if err == nil {
+		if existing.Annotations[types.AnnotationWorkflowRunParent] != h.parent() {
+			return errors.Errorf("workflow run %s already exists and is not created by %s", key, h.parent())
+		}
+		return nil
+	}
+	if !kerrors.IsNotFound(err) {
+		return errors.WithMessagef(err, "get the workflow run %s", key)
+	}
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        key.Name,
+			Namespace:   key.Namespace,
+			Labels:      params.Labels,
+			Annotations: map[string]string{},
+		},
+		Spec: params.Spec,
+	}
+	for k, v := range params.Annotations {
+		run.Annotations[k] = v
+	}
+	run.Annotations[types.AnnotationWorkflowRunParent] = h.parent()
+	if owner {
+		run.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion:         v1alpha1.SchemeGroupVersion.String(),
+			Kind:               v1alpha1.WorkflowRunKind,
+			Name:               h.meta.Name,
+			UID:                h.meta.UID,
+			BlockOwnerDeletion: pointer.BoolPtr(true),
+		}}
+	}
+	if err := h.cli.Create(ctx, run); err != nil {
+		return errors.WithMessagef(err, "create the workflow run %s", key)
+	}
+	return nil
+}
+
+// checkCircular rejects the child run if it's this run or one of the ancestors of this run
+func (h *provider) checkCircular(ctx context.Context, child client.ObjectKey) error {
+	key := client.ObjectKey{Namespace: h.meta.Namespace, Name: h.meta.Name}
+	annotations := h.meta.Annotations
+	visited := map[client.ObjectKey]bool{}
+	for !visited[key] {
+		if key == child {
+			return errors.Errorf("circular workflow runs, %s is the ancestor of %s", child, h.parent())
+		}
+		visited[key] = true
+		parent, ok := parseParent(annotations[types.AnnotationWorkflowRunParent])
+		if !ok {
+			return nil
+		}
+		run := &v1alpha1.WorkflowRun{}
+		if err := h.cli.Get(ctx, parent, run); err != nil {
+			if kerrors.IsNotFound(err) {
+				return nil
+			}
+			return errors.WithMessagef(err, "get the parent workflow run %s", parent)
+		}
+		key, annotations = parent, run.Annotations
+	}
+	return nil
+}
+
+func (h *provider) parent() string {
+	return fmt.Sprintf("%s/%s", h.meta.Namespace, h.meta.Name)
+}
+
+func parseParent(s string) (client.ObjectKey, bool) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return client.ObjectKey{}, false
+	}
+	return client.ObjectKey{Namespace: parts[0], Name: parts[1]}, true
+}
+
+type readParams struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type runStatus struct {
+	Phase      v1alpha1.WorkflowRunPhase `json:"phase"`
+	Message    string                    `json:"message,omitempty"`
+	Suspend    bool                      `json:"suspend"`
+	Terminated bool                      `json:"terminated"`
+	Finished   bool                      `json:"finished"`
+	Steps      []stepStatus              `json:"steps,omitempty"`
+}
+
+type stepStatus struct {
+	Name     string                     `json:"name"`
+	Type     string                     `json:"type,omitempty"`
+	Phase    v1alpha1.WorkflowStepPhase `json:"phase"`
+	Reason   string                     `json:"reason,omitempty"`
+	Message  string                     `json:"message,omitempty"`
+	SubSteps []stepStatus               `json:"subSteps,omitempty"`
+}
+
+// Read reads the status of the workflow run and the outputs exported to its config map
+func (h *provider) Read(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	_, err := h.read(ctx, v)
+	return err
+}
+
+// Wait waits for the workflow run to reach a terminal phase, the status and the outputs are filled like Read
+func (h *provider) Wait(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	run, err := h.read(ctx, v)
+	if err != nil {
+		return err
+	}
+	switch run.Status.Phase {
+	case v1alpha1.WorkflowStateSucceeded, v1alpha1.WorkflowStateFailed, v1alpha1.WorkflowStateTerminated, v1alpha1.WorkflowStateSkipped:
+		return nil
+	default:
+		act.Wait(fmt.Sprintf("wait for the workflow run %s/%s to finish, the current phase is %s", run.Namespace, run.Name, run.Status.Phase))
+		return nil
+	}
+}
+
+func (h *provider) read(ctx context.Context, v *value.Value) (*v1alpha1.WorkflowRun, error) {
+	params := &readParams{}
+	if err := v.UnmarshalTo(params, value.StrictFor(v, "status", "outputs")); err != nil {
+		return nil, err
+	}
+	key := client.ObjectKey{Namespace: params.Namespace, Name: params.Name}
+	if key.Namespace == "" {
+		key.Namespace = h.meta.Namespace
+	}
+	run := &v1alpha1.WorkflowRun{}
+	if err := h.cli.Get(ctx, key, run); err != nil {
+		return nil, errors.WithMessagef(err, "get the workflow run %s", key)
+	}
+	status := runStatus{
+		Phase:      run.Status.Phase,
+		Message:    run.Status.Message,
+		Suspend:    run.Status.Suspend,
+		Terminated: run.Status.Terminated,
+		Finished:   run.Status.Finished,
+	}
+	for _, ss := range run.Status.Steps {
+		step := convertStepStatus(ss.StepStatus)
+		for _, sub := range ss.SubStepsStatus {
+			step.SubSteps = append(step.SubSteps, convertStepStatus(sub))
+		}
+		status.Steps = append(status.Steps, step)
+	}
+	if err := v.FillObject(status, "status"); err != nil {
+		return nil, err
+	}
+	outputs := map[string]string{}
+	if spec := run.Spec.ExportOutputs; spec != nil && spec.ConfigMapName != "" {
+		cm := &corev1.ConfigMap{}
+		err := h.cli.Get(ctx, client.ObjectKey{Namespace: run.Namespace, Name: spec.ConfigMapName}, cm)
+		if err != nil && !kerrors.IsNotFound(err) {
+			return nil, errors.WithMessagef(err, "get the outputs of the workflow run %s", key)
+		}
+		for k, v := range cm.Data {
+			outputs[k] = v
+		}
+	}
+	return run, v.FillObject(outputs, "outputs")
+}
+
+func convertStepStatus(ss v1alpha1.StepStatus) stepStatus {
+	return stepStatus{
+		Name:    ss.Name,
+		Type:    ss.Type,
+		Phase:   ss.Phase,
+		Reason:  ss.Reason,
+		Message: ss.Message,
+	}
+}
+
+// Install register handlers to provider discover.
+func Install(p types.Providers, cli client.Client, meta types.WorkflowMeta) {
+	prd := &provider{cli: cli, meta: meta}
+	p.Register(ProviderName, map[string]types.Handler{
+		"create":             prd.Create,
+		"read":               prd.Read,
+		"wait":               prd.Wait,
+		"get-run-metadata":   prd.GetRunMetadata,
+		"patch-run-metadata": prd.PatchRunMetadata,
+	})
+}
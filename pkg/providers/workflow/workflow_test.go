@@ -0,0 +1,186 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/mock"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestCreate(t *testing.T) {
+	r := require.New(t)
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "grandparent", Namespace: "default"},
+	}, &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+	}).Build()
+	prd := &provider{cli: cli, meta: types.WorkflowMeta{
+		Name:        "parent",
+		Namespace:   "default",
+		UID:         "parent-uid",
+		Annotations: map[string]string{types.AnnotationWorkflowRunParent: "default/grandparent"},
+	}}
+	create := func(params string) error {
+		v, err := value.NewValue(params, nil, "")
+		r.NoError(err)
+		return prd.Create(ctx, nil, v, &mock.Action{})
+	}
+
+	r.NoError(create(`name: "child", spec: workflowRef: "template"`))
+	child := &v1alpha1.WorkflowRun{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "child"}, child))
+	r.Equal("template", child.Spec.WorkflowRef)
+	r.Equal("default/parent", child.Annotations[types.AnnotationWorkflowRunParent])
+	r.Equal(1, len(child.OwnerReferences))
+	r.Equal(k8stypes.UID("parent-uid"), child.OwnerReferences[0].UID)
+	// the existing child is kept
+	r.NoError(create(`name: "child", spec: workflowRef: "template"`))
+
+	r.NoError(create(`name: "orphan", owner: false, spec: workflowSpec: steps: [{name: "step", type: "suspend"}]`))
+	orphan := &v1alpha1.WorkflowRun{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "orphan"}, orphan))
+	r.Equal(0, len(orphan.OwnerReferences))
+	r.Equal(1, len(orphan.Spec.WorkflowSpec.Steps))
+
+	r.EqualError(create(`name: "parent", spec: workflowRef: "template"`), "circular workflow runs, default/parent is the ancestor of default/parent")
+	r.EqualError(create(`name: "grandparent", spec: workflowRef: "template"`), "circular workflow runs, default/grandparent is the ancestor of default/parent")
+	r.EqualError(create(`name: "other", spec: workflowRef: "template"`), "workflow run default/other already exists and is not created by default/parent")
+	r.EqualError(create(`name: "child", namespace: "another", spec: workflowRef: "template"`), "cannot own the workflow run another/child in another namespace, set owner to false")
+	r.EqualError(create(`name: "child", spec: {}`), "either workflowSpec or workflowRef is required to create the workflow run")
+}
+
+func TestReadAndWait(t *testing.T) {
+	r := require.New(t)
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "child", Namespace: "default"},
+		Spec:       v1alpha1.WorkflowRunSpec{ExportOutputs: &v1alpha1.ExportOutputs{ConfigMapName: "child-outputs", Keys: []string{"image"}}},
+		Status: v1alpha1.WorkflowRunStatus{
+			Phase: v1alpha1.WorkflowStateExecuting,
+			Steps: []v1alpha1.WorkflowStepStatus{{
+				StepStatus:     v1alpha1.StepStatus{Name: "group", Type: "step-group", Phase: v1alpha1.WorkflowStepPhaseRunning},
+				SubStepsStatus: []v1alpha1.StepStatus{{Name: "sub", Type: "apply", Phase: v1alpha1.WorkflowStepPhaseRunning, Message: "applying"}},
+			}},
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(run, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "child-outputs", Namespace: "default"},
+		Data:       map[string]string{"image": "nginx"},
+	}).Build()
+	prd := &provider{cli: cli, meta: types.WorkflowMeta{Name: "parent", Namespace: "default"}}
+
+	v, err := value.NewValue(`name: "child"`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.Read(ctx, nil, v, &mock.Action{}))
+	phase, err := v.GetString("status", "phase")
+	r.NoError(err)
+	r.Equal("executing", phase)
+	message, err := v.GetString("status", "steps", "0", "subSteps", "0", "message")
+	r.NoError(err)
+	r.Equal("applying", message)
+	image, err := v.GetString("outputs", "image")
+	r.NoError(err)
+	r.Equal("nginx", image)
+
+	act := &mock.Action{}
+	v, err = value.NewValue(`name: "child"`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.Wait(ctx, nil, v, act))
+	r.Equal("Wait", act.Phase)
+	r.Equal("wait for the workflow run default/child to finish, the current phase is executing", act.Msg)
+
+	r.NoError(cli.Get(ctx, client.ObjectKeyFromObject(run), run))
+	run.Status.Phase = v1alpha1.WorkflowStateSucceeded
+	r.NoError(cli.Status().Update(ctx, run))
+	act = &mock.Action{}
+	v, err = value.NewValue(`name: "child"`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.Wait(ctx, nil, v, act))
+	r.Equal("", act.Phase)
+
+	v, err = value.NewValue(`name: "not-found"`, nil, "")
+	r.NoError(err)
+	r.Error(prd.Read(ctx, nil, v, &mock.Action{}))
+}
+
+func TestRunMetadata(t *testing.T) {
+	r := require.New(t)
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "run",
+			Namespace:   "default",
+			Labels:      map[string]string{"app": "test", "custom.workflow.oam.dev/stage": "dev"},
+			Annotations: map[string]string{types.AnnotationWorkflowRunParent: "default/parent"},
+		},
+	}).Build()
+	prd := &provider{cli: cli, meta: types.WorkflowMeta{Name: "run", Namespace: "default"}}
+
+	v, err := value.NewValue(``, nil, "")
+	r.NoError(err)
+	r.NoError(prd.GetRunMetadata(ctx, nil, v, &mock.Action{}))
+	app, err := v.GetString("result", "labels", "app")
+	r.NoError(err)
+	r.Equal("test", app)
+
+	v, err = value.NewValue(`
+labels: {
+	"custom.workflow.oam.dev/promotion-approved": "true"
+	"custom.workflow.oam.dev/stage": null
+}
+annotations: "custom.workflow.oam.dev/approver": "admin"
+`, nil, "")
+	r.NoError(err)
+	r.NoError(prd.PatchRunMetadata(ctx, nil, v, &mock.Action{}))
+	approved, err := v.GetString("result", "labels", "custom.workflow.oam.dev/promotion-approved")
+	r.NoError(err)
+	r.Equal("true", approved)
+	run := &v1alpha1.WorkflowRun{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "run"}, run))
+	r.Equal(map[string]string{"app": "test", "custom.workflow.oam.dev/promotion-approved": "true"}, run.Labels)
+	r.Equal(map[string]string{types.AnnotationWorkflowRunParent: "default/parent", "custom.workflow.oam.dev/approver": "admin"}, run.Annotations)
+
+	v, err = value.NewValue(`annotations: {"workflowrun.oam.dev/parent": "default/other", "custom.workflow.oam.dev/": "x"}`, nil, "")
+	r.NoError(err)
+	r.EqualError(prd.PatchRunMetadata(ctx, nil, v, &mock.Action{}), "the annotation keys custom.workflow.oam.dev/, workflowrun.oam.dev/parent are not allowed to patch, only the keys under custom.workflow.oam.dev/ are allowed")
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// RedactSelf indicates whether the secrets and the properties of the other steps are redacted in the workflow run
+// read by the read-self op
+var RedactSelf = true
+
+const redacted = "<redacted>"
+
+// self is the sanitized copy of the workflow run filled by the read-self op
+type self struct {
+	Metadata selfMetadata               `json:"metadata"`
+	Spec     v1alpha1.WorkflowRunSpec   `json:"spec"`
+	Status   v1alpha1.WorkflowRunStatus `json:"status"`
+}
+
+type selfMetadata struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	UID               string            `json:"uid"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+	CreationTimestamp metav1.Time       `json:"creationTimestamp"`
+}
+
+// ReadSelf fills the value with the spec and the status of the current workflow run, the run is read once in a
+// reconcile. The op is never recorded in the workflow context even if `once` is set.
+func (h *provider) ReadSelf(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	run, err := h.readSelf(ctx)
+	if err != nil {
+		return err
+	}
+	step := ""
+	if h.pCtx != nil {
+		if name := h.pCtx.GetData(model.ContextStepName); name != nil {
+			step = fmt.Sprint(name)
+		}
+	}
+	b, err := json.Marshal(sanitize(run, step, RedactSelf))
+	if err != nil {
+		return err
+	}
+	return v.FillRaw(string(b), "value")
+}
+
+func (h *provider) readSelf(ctx context.Context) (*v1alpha1.WorkflowRun, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.self != nil {
+		return h.self, nil
+	}
+	if h.cli == nil {
+		return nil, errors.New("the workflow run is not readable without the client")
+	}
+	run := &v1alpha1.WorkflowRun{}
+	if err := h.cli.Get(ctx, client.ObjectKey{Namespace: h.meta.Namespace, Name: h.meta.Name}, run); err != nil {
+		return nil, errors.WithMessagef(err, "get the workflow run %s/%s", h.meta.Namespace, h.meta.Name)
+	}
+	h.self = run
+	return run, nil
+}
+
+// sanitize copies the workflow run without the managed fields and the last applied configuration, the urls and the
+// headers of the notifications and the properties of the steps other than the current one are redacted if redact is set
+func sanitize(run *v1alpha1.WorkflowRun, step string, redact bool) *self {
+	run = run.DeepCopy()
+	s := &self{
+		Metadata: selfMetadata{
+			Name:              run.Name,
+			Namespace:         run.Namespace,
+			UID:               string(run.UID),
+			Labels:            run.Labels,
+			Annotations:       run.Annotations,
+			CreationTimestamp: run.CreationTimestamp,
+		},
+		Spec:   run.Spec,
+		Status: run.Status,
+	}
+	delete(s.Metadata.Annotations, corev1.LastAppliedConfigAnnotation)
+	if !redact {
+		return s
+	}
+	for i := range s.Spec.Notifications {
+		notification := &s.Spec.Notifications[i]
+		if notification.URL != "" {
+			notification.URL = redacted
+		}
+		for k := range notification.Headers {
+			notification.Headers[k] = redacted
+		}
+	}
+	if overrides := s.Spec.ProviderOverrides; overrides != nil && overrides.Notification != nil && overrides.Notification.SlackURL != "" {
+		overrides.Notification.SlackURL = redacted
+	}
+	if s.Spec.WorkflowSpec != nil {
+		for i := range s.Spec.WorkflowSpec.Steps {
+			redactProperties(&s.Spec.WorkflowSpec.Steps[i].WorkflowStepBase, step)
+			for j := range s.Spec.WorkflowSpec.Steps[i].SubSteps {
+				redactProperties(&s.Spec.WorkflowSpec.Steps[i].SubSteps[j], step)
+			}
+		}
+	}
+	return s
+}
+
+func redactProperties(step *v1alpha1.WorkflowStepBase, current string) {
+	if step.Name != current {
+		step.Properties = nil
+	}
+}
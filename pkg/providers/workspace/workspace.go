@@ -19,11 +19,17 @@ package workspace
 import (
 	"fmt"
 	"strings"
+	"sync"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
+	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/sets"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/cue/process"
 	"github.com/kubevela/workflow/pkg/types"
 )
 
@@ -33,10 +39,39 @@ const (
 )
 
 type provider struct {
+	cli  client.Client
+	meta types.WorkflowMeta
+	pCtx process.Context
+
+	mu sync.Mutex
+	// self is the workflow run read by the read-self op, it's cached as the provider is installed in every reconcile
+	self *v1alpha1.WorkflowRun
 }
 
-// Load get component from context.
+// Load get component from context, all the components are loaded if neither the component nor the components is set.
+// The components not found in the subset set by the components are reported in the errors instead of failing the op.
 func (h *provider) Load(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	if components, err := v.LookupValue("components"); err == nil {
+		var names []string
+		if err := components.UnmarshalTo(&names); err != nil {
+			return err
+		}
+		errs := map[string]string{}
+		for _, name := range names {
+			component, err := wfCtx.GetComponent(name)
+			if err != nil {
+				errs[name] = err.Error()
+				continue
+			}
+			if err := fillComponent(v, component, "value", name); err != nil {
+				return err
+			}
+		}
+		if len(errs) > 0 {
+			return v.FillObject(errs, "errors")
+		}
+		return nil
+	}
 	componentName, _ := v.Field("component")
 	if !componentName.Exists() {
 		componets := wfCtx.GetComponents()
@@ -58,6 +93,15 @@ func (h *provider) Load(ctx monitorContext.Context, wfCtx wfContext.Context, v *
 	return fillComponent(v, component, "value")
 }
 
+// LoadComponentNames lists the names of the components in context, the components can be loaded in chunks by the names.
+func (h *provider) LoadComponentNames(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	names := wfCtx.GetComponentNames()
+	if names == nil {
+		names = []string{}
+	}
+	return v.FillObject(names, "names")
+}
+
 func fillComponent(v *value.Value, component *wfContext.ComponentManifest, paths ...string) error {
 	workload, err := component.Workload.String()
 	if err != nil {
@@ -101,10 +145,11 @@ func (h *provider) DoVar(ctx monitorContext.Context, wfCtx wfContext.Context, v
 	if err != nil {
 		return err
 	}
+	paths := value.SplitPath(path)
 
 	switch method {
 	case "Get":
-		value, err := wfCtx.GetVar(strings.Split(path, ".")...)
+		value, err := wfContext.LookupVar(wfCtx, paths...)
 		if err != nil {
 			return err
 		}
@@ -118,7 +163,7 @@ func (h *provider) DoVar(ctx monitorContext.Context, wfCtx wfContext.Context, v
 		if err != nil {
 			return err
 		}
-		return wfCtx.SetVar(value, strings.Split(path, ".")...)
+		return wfCtx.SetVar(value, paths...)
 	}
 	return nil
 }
@@ -139,7 +184,14 @@ func (h *provider) Export(ctx monitorContext.Context, wfCtx wfContext.Context, v
 	if err != nil {
 		return err
 	}
-	return wfCtx.PatchComponent(name, val)
+	var warnings []string
+	if err := wfCtx.PatchComponent(name, val, sets.UnifyWithWarnings{Warnings: &warnings}); err != nil {
+		return err
+	}
+	if len(warnings) > 0 {
+		return v.FillObject(warnings, "warnings")
+	}
+	return nil
 }
 
 // Wait let workflow wait.
@@ -190,14 +242,17 @@ func (h *provider) Message(ctx monitorContext.Context, wfCtx wfContext.Context,
 }
 
 // Install register handler to provider discover.
-func Install(p types.Providers) {
-	prd := &provider{}
+// The current workflow run is read by the read-self op with the client.
+func Install(p types.Providers, cli client.Client, meta types.WorkflowMeta, pCtx process.Context) {
+	prd := &provider{cli: cli, meta: meta, pCtx: pCtx}
 	p.Register(ProviderName, map[string]types.Handler{
-		"load":   prd.Load,
-		"export": prd.Export,
-		"wait":   prd.Wait,
-		"break":  prd.Break,
-		"fail":   prd.Fail,
-		"var":    prd.DoVar,
+		"load":                 prd.Load,
+		"load-component-names": prd.LoadComponentNames,
+		"export":               prd.Export,
+		"wait":                 prd.Wait,
+		"break":                prd.Break,
+		"fail":                 prd.Fail,
+		"var":                  prd.DoVar,
+		"read-self":            prd.ReadSelf,
 	})
 }
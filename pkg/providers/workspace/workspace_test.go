@@ -17,14 +17,25 @@ limitations under the License.
 package workspace
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/yaml"
 
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/cue/process"
+	"github.com/kubevela/workflow/pkg/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -55,8 +66,26 @@ component: "server"
 	r.NoError(err)
 	r.Equal(str, expectedManifest)
 
+	// check Get the subset of the components
+	v, err = value.NewValue(`components: ["server", "not-found"]`, nil, "")
+	r.NoError(err)
+	err = p.Load(nil, wfCtx, v, &mockAction{})
+	r.NoError(err)
+	sv, err := v.LookupValue("value", "server")
+	r.NoError(err)
+	str, err = sv.String()
+	r.NoError(err)
+	r.Equal(str, expectedManifest)
+	errs := map[string]string{}
+	ev, err := v.LookupValue("errors")
+	r.NoError(err)
+	r.NoError(ev.UnmarshalTo(&errs))
+	r.Equal(1, len(errs))
+	r.Contains(errs, "not-found")
+
 	errTestCases := []string{
 		`component: "not-found"`,
+		`components: "server"`,
 		`component: 124`,
 		`component: _|_`,
 	}
@@ -69,6 +98,21 @@ component: "server"
 	}
 }
 
+func TestProvider_LoadComponentNames(t *testing.T) {
+	wfCtx := newWorkflowContextForTest(t)
+	r := require.New(t)
+	p := &provider{}
+	v, err := value.NewValue(`{}`, nil, "")
+	r.NoError(err)
+	err = p.LoadComponentNames(nil, wfCtx, v, &mockAction{})
+	r.NoError(err)
+	var names []string
+	nv, err := v.LookupValue("names")
+	r.NoError(err)
+	r.NoError(nv.UnmarshalTo(&names))
+	r.Equal([]string{"server"}, names)
+}
+
 func TestProvider_Export(t *testing.T) {
 	wfCtx := newWorkflowContextForTest(t)
 	r := require.New(t)
@@ -134,6 +178,66 @@ component: "server"
 	}
 }
 
+func TestProvider_ExportWithPatchStrategy(t *testing.T) {
+	wfCtx := newWorkflowContextForTest(t)
+	r := require.New(t)
+	p := &provider{}
+	v, err := value.NewValue(`
+value: {
+	metadata: {
+		// +patchStrategy=retainKeys
+		labels: {
+			tier: "web"
+		}
+	}
+	spec: containers: [{
+		// +patchKey=name
+		// +patchStrategy=replace
+		env: [{name: "ClusterIP", value: "1.1.1.1"}]
+	}]
+}
+component: "server"
+`, nil, "")
+	r.NoError(err)
+	r.NoError(p.Export(nil, wfCtx, v, &mockAction{}))
+	component, err := wfCtx.GetComponent("server")
+	r.NoError(err)
+	workload, err := component.Workload.Unstructured()
+	r.NoError(err)
+	r.Equal(map[string]string{"tier": "web"}, workload.GetLabels())
+	containers, _, err := unstructured.NestedSlice(workload.Object, "spec", "containers")
+	r.NoError(err)
+	r.Equal(1, len(containers))
+	r.Equal([]interface{}{map[string]interface{}{"name": "ClusterIP", "value": "1.1.1.1"}}, containers[0].(map[string]interface{})["env"])
+	r.Equal("nginx:1.14.2", containers[0].(map[string]interface{})["image"])
+
+	v, err = value.NewValue(`
+value: spec: containers: [{
+	// +patchKey=containerPort,protocol
+	ports: [{containerPort: 8080, protocol: "TCP", name: "http"}, {containerPort: 9090}]
+}]
+component: "server"
+`, nil, "")
+	r.NoError(err)
+	r.NoError(p.Export(nil, wfCtx, v, &mockAction{}))
+	warnings, err := v.LookupValue("warnings")
+	r.NoError(err)
+	s, err := warnings.String()
+	r.NoError(err)
+	r.Equal(`["item 1 of list ports misses the patch key containerPort,protocol, it's appended without being merged"]
+`, s)
+	component, err = wfCtx.GetComponent("server")
+	r.NoError(err)
+	workload, err = component.Workload.Unstructured()
+	r.NoError(err)
+	containers, _, err = unstructured.NestedSlice(workload.Object, "spec", "containers")
+	r.NoError(err)
+	r.Equal([]interface{}{
+		map[string]interface{}{"containerPort": int64(8080), "protocol": "TCP", "name": "http"},
+		map[string]interface{}{"containerPort": int64(9090)},
+	}, containers[0].(map[string]interface{})["ports"])
+}
+
 func TestProvider_DoVar(t *testing.T) {
 	wfCtx := newWorkflowContextForTest(t)
 	p := &provider{}
@@ -166,6 +270,28 @@ path: "clusterIP"
 	r.NoError(err)
 	r.Equal(s, "1.1.1.1")
 
+	v, err = value.NewValue(`
+method: "Put"
+path: "endpoints[-]"
+value: "2.2.2.2"
+`, nil, "")
+	r.NoError(err)
+	endpoints, err := v.MakeValue(`["1.1.1.1"]`)
+	r.NoError(err)
+	r.NoError(wfCtx.SetVar(endpoints, "endpoints"))
+	err = p.DoVar(nil, wfCtx, v, &mockAction{})
+	r.NoError(err)
+	v, err = value.NewValue(`
+method: "Get"
+path: "endpoints[1]"
+`, nil, "")
+	r.NoError(err)
+	err = p.DoVar(nil, wfCtx, v, &mockAction{})
+	r.NoError(err)
+	s, err = v.GetString("value")
+	r.NoError(err)
+	r.Equal("2.2.2.2", s)
+
 	errCases := []string{`
 value: "1.1.1.1"
 `, `
@@ -402,3 +528,97 @@ auxiliaries: [{
 }]
 `
 )
+
+func TestProvider_ReadSelf(t *testing.T) {
+	r := require.New(t)
+	scheme := runtime.NewScheme()
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "run",
+			Namespace:   "default",
+			Annotations: map[string]string{corev1.LastAppliedConfigAnnotation: "{}", "team": "platform"},
+		},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowSpec: &v1alpha1.WorkflowSpec{Steps: []v1alpha1.WorkflowStep{
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "apply", Type: "apply", Properties: &runtime.RawExtension{Raw: []byte(`{"replicas":3}`)}}},
+				{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "notify", Type: "notify", Properties: &runtime.RawExtension{Raw: []byte(`{"channel":"ops"}`)}}},
+			}},
+			Notifications: []v1alpha1.RunNotification{{URL: "https://hooks.example.com/token", Headers: map[string]string{"Authorization": "Bearer token"}}},
+		},
+		Status: v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateExecuting},
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(run).Build()
+	pCtx := process.NewContext(process.ContextData{})
+	pCtx.PushData(model.ContextStepName, "notify")
+	p := &provider{cli: cli, meta: types.WorkflowMeta{Name: "run", Namespace: "default"}, pCtx: pCtx}
+	ctx := monitorContext.NewTraceContext(context.Background(), "")
+
+	testCases := map[string]struct {
+		redact bool
+		apply  string
+		url    string
+		header string
+	}{
+		"redacted": {
+			redact: true,
+			apply:  `null`,
+			url:    "<redacted>",
+			header: "<redacted>",
+		},
+		"not redacted": {
+			apply:  `{"replicas":3}`,
+			url:    "https://hooks.example.com/token",
+			header: "Bearer token",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			RedactSelf = tc.redact
+			defer func() { RedactSelf = true }()
+			v, err := value.NewValue(`
+value: _
+apply: *value.spec.workflowSpec.steps[0].properties | null
+`, nil, "")
+			r.NoError(err)
+			r.NoError(p.ReadSelf(ctx, nil, v, &mockAction{}))
+			phase, err := v.GetString("value", "status", "status")
+			r.NoError(err)
+			r.Equal("executing", phase)
+			annotations, err := v.LookupValue("value", "metadata", "annotations")
+			r.NoError(err)
+			b, err := annotations.CueValue().MarshalJSON()
+			r.NoError(err)
+			r.Equal(`{"team":"platform"}`, string(b))
+			apply, err := v.LookupValue("apply")
+			r.NoError(err)
+			b, err = apply.CueValue().MarshalJSON()
+			r.NoError(err)
+			r.Equal(tc.apply, string(b))
+			notify, err := v.LookupValue("value", "spec", "workflowSpec", "steps", "1", "properties")
+			r.NoError(err)
+			b, err = notify.CueValue().MarshalJSON()
+			r.NoError(err)
+			r.Equal(`{"channel":"ops"}`, string(b))
+			url, err := v.GetString("value", "spec", "notifications", "0", "url")
+			r.NoError(err)
+			r.Equal(tc.url, url)
+			header, err := v.GetString("value", "spec", "notifications", "0", "headers", "Authorization")
+			r.NoError(err)
+			r.Equal(tc.header, header)
+		})
+	}
+
+	// the run is read once by the provider
+	r.NoError(cli.Delete(ctx, run))
+	v, err := value.NewValue("", nil, "")
+	r.NoError(err)
+	r.NoError(p.ReadSelf(ctx, nil, v, &mockAction{}))
+	name, err := v.GetString("value", "metadata", "name")
+	r.NoError(err)
+	r.Equal("run", name)
+
+	p = &provider{cli: cli, meta: types.WorkflowMeta{Name: "run", Namespace: "default"}}
+	r.Error(p.ReadSelf(ctx, nil, v, &mockAction{}))
+}
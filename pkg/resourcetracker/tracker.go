@@ -0,0 +1,203 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcetracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/pkg/multicluster"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	wfTypes "github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// ResourcesKey is the key of the applied resources in the tracker config map
+	ResourcesKey = "resources"
+)
+
+// Resource is the object applied by the workflow run
+type Resource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Cluster    string `json:"cluster,omitempty"`
+}
+
+// ResourceOf returns the resource of the object applied to the cluster
+func ResourceOf(obj *unstructured.Unstructured, cluster string) Resource {
+	if multicluster.IsLocal(cluster) {
+		cluster = ""
+	}
+	return Resource{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		Cluster:    cluster,
+	}
+}
+
+// Record records the applied resources in the workflow context
+func Record(wfCtx wfContext.Context, resources ...Resource) error {
+	tracked, err := Tracked(wfCtx)
+	if err != nil {
+		return err
+	}
+	modified := false
+	for _, r := range resources {
+		if !contains(tracked, r) {
+			tracked = append(tracked, r)
+			modified = true
+		}
+	}
+	if !modified {
+		return nil
+	}
+	b, err := json.Marshal(tracked)
+	if err != nil {
+		return err
+	}
+	wfCtx.SetMutableValue(string(b), wfTypes.ContextKeyAppliedResources)
+	return nil
+}
+
+// Tracked returns the resources recorded in the workflow context
+func Tracked(wfCtx wfContext.Context) ([]Resource, error) {
+	var tracked []Resource
+	if data := wfCtx.GetMutableValue(wfTypes.ContextKeyAppliedResources); data != "" {
+		if err := json.Unmarshal([]byte(data), &tracked); err != nil {
+			return nil, err
+		}
+	}
+	return tracked, nil
+}
+
+// Load loads the resources applied by the last run of the name, which are saved when the run is finished
+func Load(ctx context.Context, cli client.Client, namespace, name string) ([]Resource, error) {
+	cm := &corev1.ConfigMap{}
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: GenerateTrackerName(name)}, cm); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	var resources []Resource
+	if data := cm.Data[ResourcesKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), &resources); err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+// Save saves the resources applied by the run in the tracker config map, so that the later reruns of the
+// same run can prune the resources no longer applied. The tracker is owned by the run and deleted with it,
+// and it's deleted if there are no resources.
+func Save(ctx context.Context, cli client.Client, namespace, name string, owners []metav1.OwnerReference, resources []Resource) error {
+	if len(resources) == 0 {
+		return DeleteTracker(ctx, cli, namespace, name)
+	}
+	b, err := json.Marshal(resources)
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{}
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: GenerateTrackerName(name)}, cm); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		cm.Name = GenerateTrackerName(name)
+		cm.Namespace = namespace
+		cm.Labels = map[string]string{
+			wfTypes.LabelWorkflowRunName:      name,
+			wfTypes.LabelWorkflowRunNamespace: namespace,
+		}
+		cm.OwnerReferences = owners
+		cm.Data = map[string]string{ResourcesKey: string(b)}
+		return cli.Create(ctx, cm)
+	}
+	cm.OwnerReferences = owners
+	cm.Data = map[string]string{ResourcesKey: string(b)}
+	return cli.Update(ctx, cm)
+}
+
+// DeleteTracker deletes the tracker config map of the run
+func DeleteTracker(ctx context.Context, cli client.Client, namespace, name string) error {
+	cm := &corev1.ConfigMap{}
+	cm.Name = GenerateTrackerName(name)
+	cm.Namespace = namespace
+	return client.IgnoreNotFound(cli.Delete(ctx, cm))
+}
+
+// Delete deletes the resources applied by the run, the resources labeled with other runs are skipped
+// as they are taken over by others. The deleted resources are returned.
+func Delete(ctx context.Context, cli client.Client, namespace, name string, resources []Resource) ([]Resource, error) {
+	var deleted []Resource
+	for _, r := range resources {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(r.APIVersion)
+		obj.SetKind(r.Kind)
+		clusterCtx := multicluster.WithCluster(ctx, r.Cluster)
+		if err := cli.Get(clusterCtx, client.ObjectKey{Namespace: r.Namespace, Name: r.Name}, obj); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return deleted, err
+		}
+		labels := obj.GetLabels()
+		if labels[wfTypes.LabelWorkflowRunName] != name || labels[wfTypes.LabelWorkflowRunNamespace] != namespace {
+			continue
+		}
+		if err := cli.Delete(clusterCtx, obj); err != nil && !errors.IsNotFound(err) {
+			return deleted, fmt.Errorf("delete %s %s/%s in cluster %q: %w", r.Kind, r.Namespace, r.Name, r.Cluster, err)
+		}
+		deleted = append(deleted, r)
+	}
+	return deleted, nil
+}
+
+// Subtract returns the resources not in the others
+func Subtract(resources []Resource, others []Resource) []Resource {
+	var result []Resource
+	for _, r := range resources {
+		if !contains(others, r) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+func contains(resources []Resource, r Resource) bool {
+	for _, item := range resources {
+		if item == r {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateTrackerName generates the name of the tracker config map
+func GenerateTrackerName(name string) string {
+	return fmt.Sprintf("workflow-%s-resources", name)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcetracker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestRecord(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().Build()
+	wfCtx, err := wfContext.NewContext(cli, "default", "run", nil)
+	r.NoError(err)
+
+	tracked, err := Tracked(wfCtx)
+	r.NoError(err)
+	r.Empty(tracked)
+
+	cm := Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "cm"}
+	secret := Resource{APIVersion: "v1", Kind: "Secret", Namespace: "default", Name: "secret", Cluster: "cluster-1"}
+	r.NoError(Record(wfCtx, cm))
+	r.NoError(Record(wfCtx, cm, secret))
+	tracked, err = Tracked(wfCtx)
+	r.NoError(err)
+	r.Equal([]Resource{cm, secret}, tracked)
+}
+
+func TestSaveAndDelete(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	labels := map[string]string{
+		types.LabelWorkflowRunName:      "run",
+		types.LabelWorkflowRunNamespace: "default",
+	}
+	cli := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "owned", Namespace: "default", Labels: labels}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "taken-over", Namespace: "default", Labels: map[string]string{
+			types.LabelWorkflowRunName:      "another",
+			types.LabelWorkflowRunNamespace: "default",
+		}}},
+	).Build()
+	owned := Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "owned"}
+	takenOver := Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "taken-over"}
+	missing := Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "missing"}
+
+	resources, err := Load(ctx, cli, "default", "run")
+	r.NoError(err)
+	r.Empty(resources)
+	owners := []metav1.OwnerReference{{APIVersion: "core.oam.dev/v1alpha1", Kind: "WorkflowRun", Name: "run", UID: "uid"}}
+	r.NoError(Save(ctx, cli, "default", "run", nil, []Resource{owned}))
+	r.NoError(Save(ctx, cli, "default", "run", owners, []Resource{owned, takenOver, missing}))
+	resources, err = Load(ctx, cli, "default", "run")
+	r.NoError(err)
+	r.Equal([]Resource{owned, takenOver, missing}, resources)
+	tracker := &corev1.ConfigMap{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: GenerateTrackerName("run")}, tracker))
+	r.Equal(owners, tracker.OwnerReferences)
+	r.Equal([]Resource{takenOver, missing}, Subtract(resources, []Resource{owned}))
+
+	deleted, err := Delete(ctx, cli, "default", "run", resources)
+	r.NoError(err)
+	r.Equal([]Resource{owned}, deleted)
+	err = cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "owned"}, &corev1.ConfigMap{})
+	r.True(kerrors.IsNotFound(err))
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "taken-over"}, &corev1.ConfigMap{}))
+
+	r.NoError(Save(ctx, cli, "default", "run", owners, nil))
+	err = cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: GenerateTrackerName("run")}, &corev1.ConfigMap{})
+	r.True(kerrors.IsNotFound(err))
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/executor"
+	"github.com/kubevela/workflow/pkg/export"
+	"github.com/kubevela/workflow/pkg/generator"
+	"github.com/kubevela/workflow/pkg/hooks"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// StepEvent is the event of the step emitted when the phase or the message of the step is changed
+type StepEvent struct {
+	StepName string
+	// ParentStepName is the name of the step group if the step is a sub step
+	ParentStepName string
+	Phase          v1alpha1.WorkflowStepPhase
+	Message        string
+	// Output is the outputs of the step keyed by the output name, it's set when the step is succeeded
+	Output map[string]string
+}
+
+// Runner runs the workflow run without the controller, the status of the run is kept in memory.
+type Runner struct {
+	run          *v1alpha1.WorkflowRun
+	cli          client.Client
+	options      types.StepGeneratorOptions
+	execOptions  []executor.Option
+	eventHandler func(StepEvent)
+	reported     map[string]v1alpha1.StepStatus
+}
+
+// Option is the option of the runner
+type Option func(r *Runner)
+
+// WithClient sets the client to run the workflow, the workflow context and the resources are stored with it.
+// It's required, a fake client can be used to run the workflow without a cluster.
+func WithClient(cli client.Client) Option {
+	return func(r *Runner) {
+		r.cli = cli
+	}
+}
+
+// WithGeneratorOptions sets the options to generate the task runners, e.g. the providers and the template loader.
+func WithGeneratorOptions(options types.StepGeneratorOptions) Option {
+	return func(r *Runner) {
+		r.options = options
+	}
+}
+
+// WithExecutorOptions sets the options of the executor, e.g. the step hooks.
+func WithExecutorOptions(opts ...executor.Option) Option {
+	return func(r *Runner) {
+		r.execOptions = append(r.execOptions, opts...)
+	}
+}
+
+// WithStepEventHandler sets the handler called with the step events in order.
+func WithStepEventHandler(handler func(StepEvent)) Option {
+	return func(r *Runner) {
+		r.eventHandler = handler
+	}
+}
+
+// New creates a runner of the workflow run.
+func New(run *v1alpha1.WorkflowRun, opts ...Option) *Runner {
+	r := &Runner{run: run.DeepCopy(), reported: map[string]v1alpha1.StepStatus{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.run.Namespace == "" {
+		r.run.Namespace = metav1.NamespaceDefault
+	}
+	return r
+}
+
+// Run executes the workflow until it's finished or suspended without a duration, and returns the final status.
+// Canceling the context terminates the workflow, the running steps are marked as terminated.
+func (r *Runner) Run(ctx context.Context) (*v1alpha1.WorkflowRunStatus, error) {
+	if r.cli == nil {
+		return &r.run.Status, errors.New("the client is required to run the workflow")
+	}
+	logCtx := monitorContext.NewTraceContext(ctx, "").AddTag("workflowrun", fmt.Sprintf("%s/%s", r.run.Namespace, r.run.Name))
+	defer logCtx.Commit("End run workflowrun")
+	for {
+		instance, err := generator.GenerateWorkflowInstance(ctx, r.cli, r.run)
+		if err != nil {
+			return &r.run.Status, errors.WithMessage(err, "generate workflow instance")
+		}
+		options := r.options
+		options.Client = r.cli
+		runners, err := generator.GenerateRunners(logCtx, instance, options)
+		if err != nil {
+			return &r.run.Status, errors.WithMessage(err, "generate runners")
+		}
+		e := executor.New(instance, r.cli, r.execOptions...)
+		state, err := e.ExecuteRunners(logCtx, runners)
+		if err != nil {
+			return &r.run.Status, errors.WithMessage(err, "execute runners")
+		}
+		r.run.Status = instance.Status
+		r.run.Status.Phase = state
+		r.emitEvents(ctx)
+
+		var wait time.Duration
+		switch state {
+		case v1alpha1.WorkflowStateSucceeded, v1alpha1.WorkflowStateFailed, v1alpha1.WorkflowStateTerminated:
+			r.finish()
+			return &r.run.Status, nil
+		case v1alpha1.WorkflowStateSuspending:
+			if wait = e.GetSuspendBackoffWaitTime(); wait <= 0 {
+				return &r.run.Status, nil
+			}
+		default:
+			wait = e.GetBackoffWaitTime()
+		}
+
+		select {
+		case <-ctx.Done():
+			r.terminate()
+			r.emitEvents(ctx)
+			r.finish()
+			return &r.run.Status, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// terminate marks the unfinished steps as terminated like terminating the workflow manually
+func (r *Runner) terminate() {
+	status := &r.run.Status
+	terminate := func(ss *v1alpha1.StepStatus) {
+		if ss.Phase == v1alpha1.WorkflowStepPhaseRunning || ss.Phase == v1alpha1.WorkflowStepPhasePending {
+			ss.Phase = v1alpha1.WorkflowStepPhaseFailed
+			ss.Reason = types.StatusReasonTerminate
+		}
+	}
+	for i := range status.Steps {
+		terminate(&status.Steps[i].StepStatus)
+		for j := range status.Steps[i].SubStepsStatus {
+			terminate(&status.Steps[i].SubStepsStatus[j])
+		}
+	}
+	status.Terminated = true
+	status.Suspend = false
+	status.Phase = v1alpha1.WorkflowStateTerminated
+}
+
+func (r *Runner) finish() {
+	if r.run.Status.Phase != v1alpha1.WorkflowStateSucceeded {
+		if cond, ok := executor.TerminatedCondition(&r.run.Status); ok {
+			r.run.SetConditions(cond)
+		}
+	}
+	r.run.Status.Finished = true
+	r.run.Status.EndTime = metav1.Now()
+	executor.StepStatusCache.Delete(fmt.Sprintf("%s-%s", r.run.Name, r.run.Namespace))
+	wfContext.CleanupMemoryStore(r.run.Name, r.run.Namespace)
+}
+
+func (r *Runner) emitEvents(ctx context.Context) {
+	if r.eventHandler == nil {
+		return
+	}
+	steps := r.workflowSteps(ctx)
+	qualified := hooks.QualifiedOutputs(steps)
+	// the outputs of the steps, keyed by the output names and valued by the names of the vars
+	outputs := map[string]map[string]string{}
+	collect := func(step v1alpha1.WorkflowStepBase) {
+		for _, output := range step.Outputs {
+			if outputs[step.Name] == nil {
+				outputs[step.Name] = map[string]string{}
+			}
+			outputs[step.Name][output.Name] = output.Name
+			if name, ok := qualified[step.Name][output.Name]; ok {
+				outputs[step.Name][output.Name] = name
+			}
+		}
+	}
+	for _, step := range steps {
+		collect(step.WorkflowStepBase)
+		for _, sub := range step.SubSteps {
+			collect(sub)
+		}
+	}
+	for _, step := range r.run.Status.Steps {
+		r.emit(step.StepStatus, "", outputs)
+		for _, sub := range step.SubStepsStatus {
+			r.emit(sub, step.Name, outputs)
+		}
+	}
+}
+
+func (r *Runner) emit(ss v1alpha1.StepStatus, parent string, outputs map[string]map[string]string) {
+	last, ok := r.reported[ss.ID]
+	if ok && last.Phase == ss.Phase && last.Message == ss.Message {
+		return
+	}
+	r.reported[ss.ID] = ss
+	e := StepEvent{StepName: ss.Name, ParentStepName: parent, Phase: ss.Phase, Message: ss.Message}
+	if ss.Phase == v1alpha1.WorkflowStepPhaseSucceeded && len(outputs[ss.Name]) > 0 && r.run.Status.ContextBackend != nil {
+		if wfCtx, err := wfContext.LoadContextFromRef(r.cli, r.run.Namespace, r.run.Name, r.run.Status.ContextBackend); err == nil {
+			e.Output = map[string]string{}
+			for name, key := range outputs[ss.Name] {
+				data, err := export.Collect(wfCtx, []string{key})
+				if err != nil {
+					continue
+				}
+				e.Output[name] = data[key]
+			}
+		}
+	}
+	r.eventHandler(e)
+}
+
+// workflowSteps loads the steps of the run with the names normalized as the executed ones, so that the outputs
+// of the unnamed steps are matched with their statuses
+func (r *Runner) workflowSteps(ctx context.Context) []v1alpha1.WorkflowStep {
+	steps, err := generator.LoadSteps(ctx, r.cli, r.run)
+	if err != nil {
+		return nil
+	}
+	return steps
+}
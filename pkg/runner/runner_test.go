@@ -0,0 +1,396 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/condition"
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/correlation"
+	"github.com/kubevela/workflow/pkg/executor"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+var templates = map[string]string{
+	"greet": `
+parameter: name: string
+message: "hello " + parameter.name
+`,
+	"env": `
+message: "deploy to " + context.custom.env
+`,
+	"wait": `
+import "vela/op"
+
+wait: op.#ConditionalWait & {
+	continue: false
+}
+`,
+}
+
+// newClient returns the in-memory client to run the workflow without a cluster
+func newClient() client.Client {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).Build()
+}
+
+func TestRun(t *testing.T) {
+	r := require.New(t)
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowSpec: &v1alpha1.WorkflowSpec{
+				Steps: []v1alpha1.WorkflowStep{{
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{
+						Name:       "greet",
+						Type:       "greet",
+						Properties: &runtime.RawExtension{Raw: []byte(`{"name":"world"}`)},
+						Outputs:    v1alpha1.StepOutputs{{Name: "message", ValueFrom: "message"}},
+					},
+				}, {
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{
+						Name:   "reply",
+						Type:   "greet",
+						Inputs: v1alpha1.StepInputs{{From: "message", ParameterKey: "name"}},
+					},
+				}},
+			},
+		},
+	}
+	var events []StepEvent
+	status, err := New(run,
+		WithClient(newClient()),
+		WithGeneratorOptions(types.StepGeneratorOptions{TemplateOverrides: templates}),
+		WithStepEventHandler(func(e StepEvent) { events = append(events, e) }),
+	).Run(context.Background())
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, status.Phase)
+	r.True(status.Finished)
+	r.Equal([]StepEvent{
+		{StepName: "greet", Phase: v1alpha1.WorkflowStepPhaseSucceeded, Output: map[string]string{"message": "hello world"}},
+		{StepName: "reply", Phase: v1alpha1.WorkflowStepPhaseSucceeded},
+	}, events)
+}
+
+func TestRunWorkflowRef(t *testing.T) {
+	r := require.New(t)
+	cli := newClient()
+	r.NoError(cli.Create(context.Background(), &v1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "greeting", Namespace: "default"},
+		WorkflowSpec: v1alpha1.WorkflowSpec{
+			Steps: []v1alpha1.WorkflowStep{{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Type:       "greet",
+					Properties: &runtime.RawExtension{Raw: []byte(`{"name":"world"}`)},
+					Outputs:    v1alpha1.StepOutputs{{Name: "message", ValueFrom: "message"}},
+				},
+			}},
+		},
+	}))
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "ref", Namespace: "default"},
+		Spec:       v1alpha1.WorkflowRunSpec{WorkflowRef: "greeting"},
+	}
+	var events []StepEvent
+	status, err := New(run,
+		WithClient(cli),
+		WithGeneratorOptions(types.StepGeneratorOptions{TemplateOverrides: templates}),
+		WithStepEventHandler(func(e StepEvent) { events = append(events, e) }),
+	).Run(context.Background())
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, status.Phase)
+	// the outputs of the unnamed step are matched by the normalized name
+	r.Equal([]StepEvent{
+		{StepName: "greet-0", Phase: v1alpha1.WorkflowStepPhaseSucceeded, Output: map[string]string{"message": "hello world"}},
+	}, events)
+}
+
+func TestRunCanceled(t *testing.T) {
+	r := require.New(t)
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "canceled"},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowSpec: &v1alpha1.WorkflowSpec{
+				Steps: []v1alpha1.WorkflowStep{{
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "wait", Type: "wait"},
+				}},
+			},
+		},
+	}
+	var events []StepEvent
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	status, err := New(run,
+		WithClient(newClient()),
+		WithGeneratorOptions(types.StepGeneratorOptions{TemplateOverrides: templates}),
+		WithStepEventHandler(func(e StepEvent) { events = append(events, e) }),
+	).Run(ctx)
+	r.ErrorIs(err, context.DeadlineExceeded)
+	r.Equal(v1alpha1.WorkflowStateTerminated, status.Phase)
+	r.Equal(types.StatusReasonTerminate, status.Steps[0].Reason)
+	r.Equal(v1alpha1.TerminatedReasonUserTerminated, string(status.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunTerminatedConditionType)).Reason))
+	r.Equal(2, len(events))
+	r.Equal(v1alpha1.WorkflowStepPhaseRunning, events[0].Phase)
+	r.Equal(v1alpha1.WorkflowStepPhaseFailed, events[1].Phase)
+}
+
+func TestRunWithSubStepOutputs(t *testing.T) {
+	r := require.New(t)
+	greet := func(name string, from string) v1alpha1.WorkflowStepBase {
+		step := v1alpha1.WorkflowStepBase{
+			Name:    name,
+			Type:    "greet",
+			Outputs: v1alpha1.StepOutputs{{Name: "message", ValueFrom: "message"}},
+		}
+		if from == "" {
+			step.Properties = &runtime.RawExtension{Raw: []byte(`{"name":"world"}`)}
+		} else {
+			step.Inputs = v1alpha1.StepInputs{{From: from, ParameterKey: "name"}}
+		}
+		return step
+	}
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "sub-step-outputs"},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowSpec: &v1alpha1.WorkflowSpec{
+				Steps: []v1alpha1.WorkflowStep{{
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "group1", Type: "step-group"},
+					SubSteps:         []v1alpha1.WorkflowStepBase{greet("greet1", "")},
+				}, {
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "group2", Type: "step-group"},
+					SubSteps:         []v1alpha1.WorkflowStepBase{greet("greet2", "group1.greet1.message")},
+				}, {
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{
+						Name:    "reply",
+						Type:    "greet",
+						Inputs:  v1alpha1.StepInputs{{From: "group1.greet1.message", ParameterKey: "name"}},
+						Outputs: v1alpha1.StepOutputs{{Name: "reply", ValueFrom: "message"}},
+					},
+				}},
+			},
+		},
+	}
+	outputs := map[string]map[string]string{}
+	status, err := New(run,
+		WithClient(newClient()),
+		WithGeneratorOptions(types.StepGeneratorOptions{TemplateOverrides: templates}),
+		WithStepEventHandler(func(e StepEvent) {
+			if e.Output != nil {
+				outputs[e.StepName] = e.Output
+			}
+		}),
+	).Run(context.Background())
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, status.Phase)
+	r.Equal(map[string]map[string]string{
+		"greet1": {"message": "hello world"},
+		"greet2": {"message": "hello hello world"},
+		"reply":  {"reply": "hello hello world"},
+	}, outputs)
+
+	run.Name = "invalid-sub-step-outputs"
+	run.Spec.WorkflowSpec.Steps[2].Inputs[0].From = "group1.greet2.message"
+	_, err = New(run, WithClient(newClient()), WithGeneratorOptions(types.StepGeneratorOptions{TemplateOverrides: templates})).Run(context.Background())
+	r.Error(err)
+	r.Contains(err.Error(), "input [group1.greet2.message] of step reply refers to an output which is not qualified by the sub steps of step group group1")
+}
+
+func TestRunWithoutClient(t *testing.T) {
+	r := require.New(t)
+	run := &v1alpha1.WorkflowRun{ObjectMeta: metav1.ObjectMeta{Name: "no-client"}}
+	_, err := New(run, WithGeneratorOptions(types.StepGeneratorOptions{TemplateOverrides: templates})).Run(context.Background())
+	r.Error(err)
+	r.Contains(err.Error(), "the client is required")
+}
+
+func TestRunWithCustomContext(t *testing.T) {
+	r := require.New(t)
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "custom-context"},
+		Spec: v1alpha1.WorkflowRunSpec{
+			Context: &runtime.RawExtension{Raw: []byte(`{"env":"prod"}`)},
+			WorkflowSpec: &v1alpha1.WorkflowSpec{
+				Steps: []v1alpha1.WorkflowStep{{
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{
+						Name:    "deploy",
+						Type:    "env",
+						Outputs: v1alpha1.StepOutputs{{Name: "message", ValueFrom: "message"}},
+					},
+				}},
+			},
+		},
+	}
+	cli := fake.NewClientBuilder().Build()
+	var events []StepEvent
+	status, err := New(run,
+		WithClient(cli),
+		WithGeneratorOptions(types.StepGeneratorOptions{TemplateOverrides: templates}),
+		WithStepEventHandler(func(e StepEvent) { events = append(events, e) }),
+	).Run(context.Background())
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, status.Phase)
+	r.Equal(map[string]string{"message": "deploy to prod"}, events[0].Output)
+
+	cm := &corev1.ConfigMap{}
+	r.NoError(cli.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: status.ContextBackend.Name}, cm))
+	r.Equal(`{"env":"prod"}`, cm.Data[wfContext.ConfigMapKeyContext])
+}
+
+type recordHook struct {
+	records []string
+	err     error
+}
+
+func (h *recordHook) OnStepStart(_ monitorContext.Context, step v1alpha1.WorkflowStep, _ wfContext.ReadOnlyContext) error {
+	h.records = append(h.records, "start "+step.Name)
+	return h.err
+}
+
+func (h *recordHook) OnStepComplete(_ monitorContext.Context, step v1alpha1.WorkflowStep, status v1alpha1.StepStatus, wfCtx wfContext.ReadOnlyContext) error {
+	record := fmt.Sprintf("complete %s %s", step.Name, status.Phase)
+	if v, err := wfCtx.GetVar("message"); err == nil {
+		s, _ := v.CueValue().String()
+		record += " " + s
+	}
+	h.records = append(h.records, record)
+	return nil
+}
+
+func (h *recordHook) OnWorkflowComplete(_ monitorContext.Context, instance *types.WorkflowInstance, phase v1alpha1.WorkflowRunPhase, _ wfContext.ReadOnlyContext) error {
+	h.records = append(h.records, fmt.Sprintf("workflow %s %s", instance.Name, phase))
+	return nil
+}
+
+func TestRunWithStepHooks(t *testing.T) {
+	r := require.New(t)
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "hooks"},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowSpec: &v1alpha1.WorkflowSpec{
+				Steps: []v1alpha1.WorkflowStep{{
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{
+						Name:       "greet",
+						Type:       "greet",
+						Properties: &runtime.RawExtension{Raw: []byte(`{"name":"world"}`)},
+						Outputs:    v1alpha1.StepOutputs{{Name: "message", ValueFrom: "message"}},
+					},
+				}},
+			},
+		},
+	}
+	first, second := &recordHook{}, &recordHook{err: errors.New("audit failed")}
+	status, err := New(run,
+		WithClient(newClient()),
+		WithGeneratorOptions(types.StepGeneratorOptions{TemplateOverrides: templates}),
+		WithExecutorOptions(executor.WithStepHooks(first, second)),
+	).Run(context.Background())
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, status.Phase)
+	r.Equal([]string{"start greet", "complete greet succeeded hello world", "workflow hooks succeeded"}, first.records)
+	r.Equal(first.records, second.records)
+
+	first.records = nil
+	run.Name = "hooks-failed"
+	status, err = New(run,
+		WithClient(newClient()),
+		WithGeneratorOptions(types.StepGeneratorOptions{TemplateOverrides: templates}),
+		WithExecutorOptions(executor.WithStepHooks(second, first), executor.WithFailStepOnHookError(true)),
+	).Run(context.Background())
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateFailed, status.Phase)
+	r.Equal(types.StatusReasonHook, status.Steps[0].Reason)
+	r.Equal("audit failed", status.Steps[0].Message)
+	r.Equal([]string{"start greet", "complete greet failed", "workflow hooks-failed failed"}, first.records)
+
+	// the hook failure is handled by the onFailure policy of the step
+	first.records = nil
+	run.Name = "hooks-ignored"
+	run.Spec.WorkflowSpec.Steps[0].OnFailure = v1alpha1.StepFailurePolicyIgnore
+	status, err = New(run,
+		WithClient(newClient()),
+		WithGeneratorOptions(types.StepGeneratorOptions{TemplateOverrides: templates}),
+		WithExecutorOptions(executor.WithStepHooks(second, first), executor.WithFailStepOnHookError(true)),
+	).Run(context.Background())
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, status.Phase)
+	r.Equal(types.StatusReasonIgnored, status.Steps[0].Reason)
+	r.False(status.Terminated)
+}
+
+func TestRunWithCorrelation(t *testing.T) {
+	r := require.New(t)
+	r.NoError(correlation.SetKeys([]string{"trace-id"}))
+	defer func() {
+		r.NoError(correlation.SetKeys(nil))
+	}()
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "correlation", Namespace: "default", Annotations: map[string]string{"trace-id": "abc"}},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowSpec: &v1alpha1.WorkflowSpec{
+				Steps: []v1alpha1.WorkflowStep{{
+					WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "apply", Type: "apply"},
+				}},
+			},
+		},
+	}
+	cli := newClient()
+	status, err := New(run,
+		WithClient(cli),
+		WithGeneratorOptions(types.StepGeneratorOptions{TemplateOverrides: map[string]string{
+			"apply": `
+import "vela/op"
+
+apply: op.#Apply & {
+	value: {
+		apiVersion: "v1"
+		kind:       "ConfigMap"
+		metadata: {
+			name:      "applied"
+			namespace: "default"
+		}
+		data: key: "value"
+	}
+}
+`,
+		}}),
+	).Run(context.Background())
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStateSucceeded, status.Phase)
+
+	cm := &corev1.ConfigMap{}
+	r.NoError(cli.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "applied"}, cm))
+	r.Equal("abc", cm.Labels["trace-id"])
+	r.Equal("correlation", cm.Labels[types.LabelWorkflowRunName])
+}
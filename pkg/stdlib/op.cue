@@ -4,6 +4,12 @@ import (
 	"strings"
 )
 
+// #Strict is embedded in the ops decoding their parameters, the unknown fields in the parameters are rejected
+// if strict is true. The default of it is set by the controller flag --strict-unmarshal.
+#Strict: {
+	strict?: bool
+}
+
 #ConditionalWait: {
 	#do:      "wait"
 	continue: bool
@@ -29,12 +35,28 @@ import (
 
 #ApplyInParallel: kube.#ApplyInParallel
 
+#WaitAll: kube.#WaitAll
+
+#WaitJob: kube.#WaitJob
+
 #Read: kube.#Read
 
+#Patch: kube.#Patch
+
 #List: kube.#List
 
 #Delete: kube.#Delete
 
+#ExportToConfigMap: kube.#ExportToConfigMap
+
+#ExportToSecret: kube.#ExportToSecret
+
+#GC: kube.#GC
+
+#Prune: kube.#Prune
+
+#ApplyComponent: kube.#ApplyComponent
+
 #DingTalk: #Steps & {
 	message: {...}
 	dingUrl: string
@@ -86,6 +108,10 @@ import (
 
 #Log: util.#Log
 
+#CollectLogs: util.#CollectLogs
+
+#Debug: debug.#Debug
+
 #DateToTimestamp: time.#DateToTimestamp
 
 #TimestampToDate: time.#TimestampToDate
@@ -100,6 +126,34 @@ import (
 
 #PatchK8sObject: util.#PatchK8sObject
 
+#Diff: util.#Diff
+
+#Lookup: util.#Lookup
+
+#Concrete: util.#Concrete
+
+#Render: util.#Render
+
+#Format: util.#Format
+
+#Validate: util.#Validate
+
+#CheckErrorBudget: history.#CheckErrorBudget
+
+// The providers about the mutex across the workflow runs
+#Lock:   lock.#Lock
+#Unlock: lock.#Unlock
+
+// The providers about the child workflow runs
+#CreateWorkflowRun: workflow.#Create
+#ReadWorkflowRun:   workflow.#Read
+#WaitWorkflowRun:   workflow.#Wait
+
+// The providers about the metadata of the current workflow run
+#GetRunMetadata:   workflow.#GetRunMetadata
+#PatchRunMetadata: workflow.#PatchRunMetadata
+#ReadSelf:         ws.#ReadSelf
+
 #Steps: {
 	#do: "steps"
 	...
@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"cuelang.org/go/cue/build"
 	"cuelang.org/go/cue/parser"
@@ -44,6 +45,9 @@ var (
 	builtinImport *build.Instance
 	// GeneralImports is the general imports for cue
 	GeneralImports []*build.Instance
+	// definitions is the extra definitions added to the builtin package
+	definitions []string
+	importLock  sync.RWMutex
 )
 
 const (
@@ -76,10 +80,29 @@ func GetPackages() (string, error) {
 		pkgContent := fmt.Sprintf("%s: {\n%s\n}\n", strings.TrimSuffix(file.Name(), ".cue"), string(body))
 		opContent += pkgContent
 	}
+	for _, def := range definitions {
+		opContent += def + "\n"
+	}
 
 	return opContent, nil
 }
 
+// AddDefinitions adds the cue definitions to the builtin package `vela/op`, it's used to expose the ops
+// of the registered providers, e.g. `#MyOrg: #DoThing: {#provider: "myorg", #do: "do-thing"}`
+// makes the op callable as `op.#MyOrg.#DoThing`.
+func AddDefinitions(content string) error {
+	importLock.Lock()
+	defer importLock.Unlock()
+	definitions = append(definitions, content)
+	inst, err := initBuiltinImports()
+	if err != nil {
+		definitions = definitions[:len(definitions)-1]
+		return err
+	}
+	builtinImport = inst
+	return nil
+}
+
 // AddImportsFor install imports for build.Instance.
 func AddImportsFor(inst *build.Instance, tagTempl string) error {
 	inst.Imports = append(inst.Imports, GeneralImports...)
@@ -91,7 +114,9 @@ func AddImportsFor(inst *build.Instance, tagTempl string) error {
 		}
 	}
 	if addDefault {
+		importLock.RLock()
 		inst.Imports = append(inst.Imports, builtinImport)
+		importLock.RUnlock()
 	}
 	if tagTempl != "" {
 		p := &build.Instance{
@@ -50,3 +50,54 @@ out: custom.context`)
 	r.NoError(err)
 	r.Equal(str, "xxx")
 }
+
+func TestAddDefinitions(t *testing.T) {
+	r := require.New(t)
+	defer func() {
+		definitions = nil
+		var err error
+		builtinImport, err = initBuiltinImports()
+		r.NoError(err)
+	}()
+	r.Error(AddDefinitions(`#MyOrg: {`))
+	r.NoError(AddDefinitions(`#MyOrg: #DoThing: {
+	#provider: "myorg"
+	#do:       "do-thing"
+}`))
+
+	file, err := parser.ParseFile("-", `
+import "vela/op"
+out: op.#MyOrg.#DoThing`)
+	r.NoError(err)
+	builder := &build.Instance{}
+	r.NoError(builder.AddSyntax(file))
+	r.NoError(AddImportsFor(builder, ""))
+	inst := cuecontext.New().BuildInstance(builder)
+	r.NoError(inst.Err())
+	str, err := inst.LookupPath(cue.ParsePath("out.#do")).String()
+	r.NoError(err)
+	r.Equal("do-thing", str)
+}
+
+func TestStrictOps(t *testing.T) {
+	r := require.New(t)
+	file, err := parser.ParseFile("-", `
+import "vela/op"
+list: op.#List & {
+	resource: {apiVersion: "v1", kind: "ConfigMap"}
+	strict: true
+}
+send: op.email.#Send & {strict: true}`)
+	r.NoError(err)
+	builder := &build.Instance{}
+	r.NoError(builder.AddSyntax(file))
+	r.NoError(AddImportsFor(builder, ""))
+	inst := cuecontext.New().BuildInstance(builder)
+	r.NoError(inst.Err())
+	strict, err := inst.LookupPath(cue.ParsePath("list.strict")).Bool()
+	r.NoError(err)
+	r.True(strict)
+	strict, err = inst.LookupPath(cue.ParsePath("send.strict")).Bool()
+	r.NoError(err)
+	r.True(strict)
+}
@@ -0,0 +1,10 @@
+#Debug: {
+	#do:       "debug"
+	#provider: "debug"
+
+	// the name of the value in the debug config map of the step
+	name: *"value" | string
+	// the value to record, the incomplete fields are rendered as cue
+	value: _
+	...
+}
@@ -1,13 +1,29 @@
 #Send: {
 	#do:       "send"
 	#provider: "email"
+	#Strict
 
 	from: {
-		address:  string
-		alias?:   string
-		password: string
-		host:     string
-		port:     int
+		address:   string
+		alias?:    string
+		password?: string
+		// the host and the port are required if smtp is not set
+		host?: string
+		port?: int
+	}
+	// the smtp server to send the email, the sender is authenticated with the address and the password
+	// of the sender unless the credentials are read from the secret in the namespace of the workflow run
+	smtp?: {
+		host:                string
+		port:                *587 | int
+		tls:                 *"starttls" | "none" | "tls"
+		insecureSkipVerify?: bool
+		auth:                *"plain" | "login" | "cram-md5" | "none"
+		secretRef?: {
+			name:        string
+			usernameKey: *"username" | string
+			passwordKey: *"password" | string
+		}
 	}
 	to: [...string]
 	content: {
@@ -15,5 +31,14 @@
 		body:    string
 	}
 	stepID: context.stepSessionID
+	// the smtp server applied by the provider overrides of the workflow run
+	override?: {
+		smtpHost?: string
+		smtpPort?: int
+	}
+	// send the email only once in the step, the default is true
+	once?: bool
+	// the result of the email skipped in the dry-run mode
+	result?: string
 	...
 }
@@ -0,0 +1,27 @@
+#CheckErrorBudget: {
+	#do:       "check-error-budget"
+	#provider: "history"
+	#Strict
+
+	// select the runs in the namespace of the current run by the referenced workflow or the labels
+	workflowRef?: string
+	selector?: [string]: string
+	window: {
+		// the number of the latest runs
+		count?: int
+		// the duration to look back, e.g. 24h
+		duration?: string
+	}
+	// the max number of the failed runs allowed in the window
+	threshold: int
+	// the budget is allowed if the runs in the window are fewer than minSamples
+	minSamples: *1 | int
+
+	result?: {
+		allowed:    bool
+		total:      int
+		failed:     int
+		sufficient: bool
+	}
+	...
+}
@@ -4,6 +4,18 @@
 
 	method: *"GET" | "POST" | "PUT" | "DELETE"
 	url:    string
+	// send the request only once in the step, the default is true for the POST request
+	once?: bool
+	// record the error of the request in the error field instead of failing the step
+	ignoreError?: bool
+	error?:       string
+	// the idempotency key sent in the header of the request, "auto" derives a stable key from the workflow run,
+	// the step and the op. The first successful response of the key is returned instead of sending the request again.
+	idempotencyKey?: string
+	// the header of the idempotency key
+	idempotencyHeader: *"Idempotency-Key" | string
+	// send the request even if a successful response is recorded for the idempotency key
+	force?: bool
 	request?: {
 		timeout?: string
 		body?:    string
@@ -16,6 +28,14 @@
 		...
 	}
 	tls_config?: secret: string
+	// the override of the url applied by the provider overrides of the workflow run
+	override?: {
+		rule:         string
+		url:          string
+		rewrittenURL: string
+	}
+	// the result of the request skipped in the dry-run mode, the response is empty with the status 200
+	result?: string
 	response: {
 		body: string
 		header?: [string]: [...string]
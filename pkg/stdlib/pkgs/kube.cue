@@ -1,8 +1,70 @@
+#ApplyResult: {
+	name:      string
+	namespace: string
+	kind:      string
+	action:    "created" | "updated" | "unchanged"
+	// the cluster of the object if the clusters are selected by the cluster selector
+	cluster?: string
+	// the paths of the fields changed by the apply
+	diff?: [...string]
+}
+
+#ClusterSelector: {
+	// select the clusters whose labels contain all the labels
+	matchLabels?: [string]: string
+	// select the clusters in the names, the local cluster is named "local"
+	matchNames?: [...string]
+	// the clusters not to select
+	exclude?: [...string]
+	// allow selecting no cluster, otherwise the step fails
+	allowEmpty: *false | bool
+}
+
 #Apply: {
 	#do:       "apply"
 	#provider: "kube"
 	cluster:   *"" | string
+	// apply to the clusters selected at execution time instead of the cluster
+	clusterSelector?: #ClusterSelector
+	// the clusters selected by the cluster selector
+	clusters?: [...string]
+	// patch the value applied to the matching clusters, the overrides are patched in order
+	overrides?: [...{
+		cluster?:         string
+		clusterSelector?: #ClusterSelector
+		patch: {...}
+	}]
+	// the final manifests applied to the clusters keyed by the clusters, it's set if there are overrides
+	manifests?: {...}
+	// apply to the clusters batch by batch, one batch per reconcile, the gate of the batch runs after it's applied
+	// and the next batch is applied after the gate is passed, the clusters left are applied in the last batch
+	batches?: [...{
+		// the number of the clusters in the batch or the percentage of all the clusters, e.g. "25%"
+		weight: int | =~"^[0-9]+%$"
+		after?: {
+			// suspend the workflow, the next batch is applied after it's resumed
+			suspend?: bool
+			// wait for the condition of the object to be True in the clusters of the batch
+			healthCheck?: {
+				conditionType: *"Ready" | string
+			}
+		}
+	}]
+	// the progress of the batches
+	progress?: {
+		// the index of the batch in progress
+		batch: int
+		batches: [...{
+			clusters: [...string]
+			phase:    "succeeded" | "running" | "failed" | "pending"
+		}]
+	}
 	value: {...}
+	result?: [...#ApplyResult]
+	// whether the object is created or updated
+	changed?: bool
+	// the list items of the patch appended without being merged as they miss the patch keys
+	warnings?: [...string]
 	...
 }
 
@@ -10,7 +72,24 @@
 	#do:       "apply-in-parallel"
 	#provider: "kube"
 	cluster:   *"" | string
+	// apply to the clusters selected at execution time instead of the cluster
+	clusterSelector?: #ClusterSelector
+	// the clusters selected by the cluster selector
+	clusters?: [...string]
+	// patch each of the values applied to the matching clusters, the overrides are patched in order
+	overrides?: [...{
+		cluster?:         string
+		clusterSelector?: #ClusterSelector
+		patch: {...}
+	}]
+	// the final manifests applied to the clusters keyed by the clusters, it's set if there are overrides
+	manifests?: {...}
 	value: [...{...}]
+	result?: [...#ApplyResult]
+	// whether any object is created or updated
+	changed?: bool
+	// the list items of the patch appended without being merged as they miss the patch keys
+	warnings?: [...string]
 	...
 }
 
@@ -19,12 +98,101 @@
 	#provider: "kube"
 	cluster:   *"" | string
 	value?: {...}
+	// read multiple objects, the objects and the errors of reading them are returned in values and errors
+	objects?: [...{
+		apiVersion: string
+		kind:       string
+		name:       string
+		namespace:  *"default" | string
+		// the key of the object in values and errors
+		key: *name | string
+	}]
+	values?: {...}
+	errors?: {...}
+	err?:    string
+	// the type of the error of reading the single object, the NotFound is distinguished
+	errType?: "NotFound" | "Unknown"
+	...
+}
+
+#Patch: {
+	#do:       "patch"
+	#provider: "kube"
+	cluster:   *"" | string
+	resource: {
+		apiVersion: string
+		kind:       string
+		name:       string
+		namespace:  *"default" | string
+	}
+	patchType: *"merge" | "json" | "strategic"
+	patch:     {...} | [...]
+	// the patched object
+	value?: {...}
+	err?:   string
+	// the type of the error, the NotFound and the TestFailed of the json patch are distinguished
+	errType?: "NotFound" | "TestFailed" | "Unknown"
+	...
+}
+
+#WaitAll: {
+	#do:       "wait-all"
+	#provider: "kube"
+	#Strict
+	// the objects in the local cluster are watched, their changes reconcile the waiting step without the backoff
+	cluster:   *"" | string
+	// the objects to wait for, the ones not found are not ready
+	objects: [...{
+		apiVersion: string
+		kind:       string
+		name:       string
+		namespace:  *"default" | string
+		// the key of the object in ready
+		key: *name | string
+	}]
+	// the CUE expression evaluated on each object referred as object, e.g. object.status.readyReplicas == object.spec.replicas
+	condition?: string
+	// the CUE expression evaluated on all the objects referred as resources, e.g.
+	// len([for r in resources if r.status.availableReplicas == r.spec.replicas {r}]) == len(resources)
+	expression?: string
+	// fail the step with the unready objects if they are not ready in the duration since the op starts waiting
+	timeout?: string
+	stepID:   context.stepSessionID
+	// the readiness of the objects keyed by their keys
+	ready?: [string]: bool
+	...
+}
+
+#WaitJob: {
+	#do:       "wait-job"
+	#provider: "kube"
+	#Strict
+	// the job in the local cluster is watched, its changes reconcile the waiting step without the backoff
+	cluster:   *"" | string
+	name:      string
+	namespace: *"default" | string
+	// the number of the last lines of the logs of the terminated container to read
+	tailLines: *20 | int
+	// the result of the finished job, the step keeps waiting until the job is completed or failed and fails with
+	// the reason of the job if it's failed
+	result?: {
+		phase: "Complete" | "Failed"
+		// the reason of the failed job, e.g. BackoffLimitExceeded or DeadlineExceeded
+		reason?:    string
+		message?:   string
+		pod?:       string
+		container?: string
+		// the exit code of the terminated container, the failed one is preferred if the job is failed
+		exitCode?: int
+		logs?:     string
+	}
 	...
 }
 
 #List: {
 	#do:       "list"
 	#provider: "kube"
+	#Strict
 	cluster:   *"" | string
 	resource: {
 		apiVersion: string
@@ -41,6 +209,7 @@
 #Delete: {
 	#do:       "delete"
 	#provider: "kube"
+	#Strict
 	cluster:   *"" | string
 	value: {
 		apiVersion: string
@@ -56,3 +225,93 @@
 	}
 	...
 }
+
+#ExportToConfigMap: {
+	#do:       "export2config"
+	#provider: "kube"
+	#Strict
+	cluster:   *"" | string
+	name:      string
+	namespace: *"default" | string
+	data: [string]: string
+	// replace the whole data of the existing ConfigMap instead of merging the keys
+	replace: *false | bool
+	// the resource version of the ConfigMap after it's created or updated
+	resourceVersion?: string
+	...
+}
+
+#ExportToSecret: {
+	#do:       "export2secret"
+	#provider: "kube"
+	#Strict
+	cluster:   *"" | string
+	name:      string
+	namespace: *"default" | string
+	// the plain text of the data, it's encoded by base64 when it's stored
+	data: [string]: string
+	// replace the whole data of the existing Secret instead of merging the keys
+	replace: *false | bool
+	// the resource version of the Secret after it's created or updated
+	resourceVersion?: string
+	...
+}
+
+#ApplyComponent: {
+	#do:       "apply-component"
+	#provider: "kube"
+	// the name of the component in the workflow context
+	component: string
+	cluster:   *"" | string
+	// patch the workload before it's applied
+	patch?: {...}
+	// the results of the workload and the auxiliaries in the applied order
+	result?: [...#ApplyResult]
+	changed?: bool
+	// the workload in the cluster, the step waits until it's healthy
+	workload?: {...}
+	healthy?: bool
+	...
+}
+
+#GC: {
+	#do:       "gc"
+	#provider: "kube"
+	// the objects applied by the last run of the same name but not applied by this run, the objects are tracked
+	// only if the resource gc or the track-resources annotation of the run is set
+	deleted?: [...{
+		apiVersion: string
+		kind:       string
+		namespace?: string
+		name:       string
+		cluster?:   string
+	}]
+	...
+}
+
+#Prune: {
+	#do:       "prune"
+	#provider: "kube"
+	#Strict
+	// the objects in the local cluster are watched while waiting for them to be deleted
+	cluster: *"" | string
+	// the resources to prune, the kinds qualified by the groups, e.g. Deployment.apps, must be allowed by the
+	// controller flag --prune-allowed-kinds
+	resources: [...{
+		apiVersion: string
+		kind:       string
+	}]
+	namespace: *"default" | string
+	// the label selector of the objects to prune, e.g. app=web,tier!=db, it's required
+	labelSelector: string
+	// fill the objects that would be deleted into deleted without deleting them
+	dryRun: *false | bool
+	// the grace period of the deletion, e.g. 30s, it must not be negative
+	grace?: string
+	// wait until the deleted objects are gone
+	wait:   *true | bool
+	stepID: context.stepSessionID
+	// the deleted objects as kind/name
+	deleted?: [...string]
+	...
+}
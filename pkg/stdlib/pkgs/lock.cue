@@ -0,0 +1,26 @@
+#Lock: {
+	#do:       "lock"
+	#provider: "lock"
+	#Strict
+
+	// the name of the lock shared by the workflow runs in the namespace
+	name: string
+	// the lock is taken over by other runs if it's not refreshed within the ttl, e.g. the run crashed
+	ttl: *"5m" | string
+	// wait for the lock if it's held by another run, otherwise fail the step
+	wait: *true | bool
+
+	// the uid of the workflow run holding the lock
+	holder?: string
+	...
+}
+
+#Unlock: {
+	#do:       "unlock"
+	#provider: "lock"
+	#Strict
+
+	// the name of the lock to release
+	name: string
+	...
+}
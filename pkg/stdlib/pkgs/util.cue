@@ -16,6 +16,69 @@
 	...
 }
 
+#Diff: {
+	#do:       "diff"
+	#provider: "util"
+
+	left:  _
+	right: _
+	// the paths to ignore, e.g. "metadata.managedFields" or "status"
+	ignore?: [...string]
+	changed?: bool
+	changes?: [...{
+		path:    string
+		type:    "added" | "removed" | "modified"
+		before?: _
+		after?:  _
+	}]
+	...
+}
+
+#Lookup: {
+	#do:       "lookup"
+	#provider: "util"
+
+	value: _
+	// the path of the field, e.g. "spec.template.spec.containers[0].image"
+	path:    string
+	result?: _
+	// whether the field exists in the value
+	found?: bool
+	...
+}
+
+#Concrete: {
+	#do:       "concrete"
+	#provider: "util"
+
+	value: _
+	// the value if it's concrete
+	result?: _
+	// the paths of the incomplete fields if the value is not concrete
+	incomplete?: [...string]
+	...
+}
+
+#Render: {
+	#do:       "render"
+	#provider: "util"
+	#Strict
+
+	// either the template in the configmap or the inline template is required
+	templateRef?: {
+		configMap: string
+		key:       string
+		// the namespace of the configmap, it's the namespace of the workflow run if not set
+		namespace?: string
+	}
+	inline?: string
+	// the values unified with the template
+	values?: {...}
+	// the rendered template, it's required to be concrete
+	result?: _
+	...
+}
+
 #Log: {
 	#do:       "log"
 	#provider: "util"
@@ -34,3 +97,74 @@
 		}]
 	})
 }
+
+#CollectLogs: {
+	#do:       "collect-logs"
+	#provider: "util"
+	#Strict
+
+	// the logs are collected when the step is finished, declare it before the ops that may fail the step.
+	// either podSelector or resource is required.
+	podSelector?: {
+		cluster?:   string
+		namespace?: string
+		matchLabels: [string]: string
+	}
+	// the pods are selected by the spec.selector of the resource, e.g. a Job, or the resource is the pod itself
+	resource?: {
+		apiVersion: string
+		kind:       string
+		name:       string
+		namespace?: string
+		cluster?:   string
+	}
+	// the container to collect the logs from, all the containers are collected if it's not set
+	container?: string
+	tailLines?: int
+	...
+}
+
+#Format: {
+	#do:       "format"
+	#provider: "util"
+	#Strict
+
+	// the go template, e.g. "waiting for {{.ready}}/{{.total}} replicas"
+	template: string
+	// the data referred in the template
+	data?: _
+	// fail the op if the template refers to a missing key, or render it as "<no value>" with "default"
+	missingKey: *"error" | "default"
+	// escape the data for HTML with "html", e.g. the messages shown in the web pages
+	escape: *"none" | "html"
+	// the rendered string
+	result?: string
+	...
+}
+
+#Validate: {
+	#do:       "validate"
+	#provider: "util"
+	#Strict
+
+	// the value to validate, e.g. the deployment values provided by the user
+	value: _
+	// either the inline CUE schema unified with the value or the JSON Schema in the configmap is required,
+	// the value is required to be concrete after the unification with the CUE schema
+	schema?: string
+	// the JSON Schema of draft-07, the keywords of the types, required, enum, pattern, the properties and the items are supported
+	jsonSchemaRef?: {
+		configMap: string
+		key:       string
+		// the namespace of the configmap, it's the namespace of the workflow run if not set
+		namespace?: string
+	}
+	// fail the step listing all the violations if the value is invalid
+	failOnInvalid: *false | bool
+	valid?:        bool
+	errors?: [...{
+		path:    string
+		message: string
+	}]
+	...
+}
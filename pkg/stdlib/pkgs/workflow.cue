@@ -0,0 +1,80 @@
+#Create: {
+	#do:       "create"
+	#provider: "workflow"
+	#Strict
+
+	// the name of the child workflow run
+	name: string
+	// the namespace of the child workflow run, it's the namespace of the parent run if not set
+	namespace?: string
+	labels?: [string]:      string
+	annotations?: [string]: string
+	// the spec of the child workflow run with either the inline workflowSpec or the workflowRef
+	spec: {...}
+	// the child run is deleted with the parent run if it's owned by the parent run
+	owner: *true | bool
+	...
+}
+
+#Read: {
+	#do:       "read"
+	#provider: "workflow"
+	#Strict
+
+	// the name of the workflow run
+	name: string
+	// the namespace of the workflow run, it's the namespace of the current run if not set
+	namespace?: string
+
+	// the phase and the step summaries of the workflow run
+	status?: {...}
+	// the outputs exported to the config map by the workflow run
+	outputs?: [string]: string
+	...
+}
+
+#Wait: {
+	#do:       "wait"
+	#provider: "workflow"
+	#Strict
+
+	// the name of the workflow run to wait for its terminal phase
+	name: string
+	// the namespace of the workflow run, it's the namespace of the current run if not set
+	namespace?: string
+
+	// the phase and the step summaries of the workflow run
+	status?: {...}
+	// the outputs exported to the config map by the workflow run
+	outputs?: [string]: string
+	...
+}
+
+#GetRunMetadata: {
+	#do:       "get-run-metadata"
+	#provider: "workflow"
+
+	// the labels and the annotations of the current workflow run
+	result?: {
+		labels: [string]:      string
+		annotations: [string]: string
+	}
+	...
+}
+
+#PatchRunMetadata: {
+	#do:       "patch-run-metadata"
+	#provider: "workflow"
+	#Strict
+
+	// only the keys under custom.workflow.oam.dev/ are allowed, null removes the key
+	labels?: [string]:      string | null
+	annotations?: [string]: string | null
+
+	// the labels and the annotations of the current workflow run after the patch
+	result?: {
+		labels: [string]:      string
+		annotations: [string]: string
+	}
+	...
+}
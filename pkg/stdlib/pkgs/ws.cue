@@ -1,14 +1,24 @@
 #Load: {
 	#do:        "load"
 	component?: string
+	// the subset of the components to load, the components not found are reported in the errors
+	components?: [...string]
 	value?: {...}
+	errors?: [string]: string
 	...
 }
 
+#LoadComponentNames: {
+	#do:    "load-component-names"
+	names?: [...string]
+}
+
 #Export: {
 	#do:       "export"
 	component: string
 	value:     _
+	// the list items appended without being merged as they miss the patch keys
+	warnings?: [...string]
 }
 
 #DoVar: {
@@ -17,3 +27,17 @@
 	path:   string
 	value?: _
 }
+
+#ReadSelf: {
+	#do: "read-self"
+
+	// the spec and the status of the current workflow run, it's read once in a reconcile and never recorded in the
+	// workflow context. The urls and the headers of the notifications and the properties of the other steps are
+	// redacted unless the redaction is disabled by the controller.
+	value?: {
+		metadata: {...}
+		spec: {...}
+		status: {...}
+	}
+	...
+}
@@ -101,9 +101,23 @@ func (tr *stepGroupTaskRunner) Run(ctx wfContext.Context, options *types.TaskRun
 		if result.Skip {
 			status.Phase = v1alpha1.WorkflowStepPhaseSkipped
 			status.Reason = types.StatusReasonSkip
+			status.Message = result.Message
 			options.StepStatus[tr.step.Name] = status
 			break
 		}
+		if result.Failed {
+			status.Phase = v1alpha1.WorkflowStepPhaseFailed
+			status.Reason = types.StatusReasonCondition
+			status.Message = result.Message
+			return status, &types.Operation{Terminated: true}, nil
+		}
+		if result.Wait {
+			// the sub steps are not executed until the step group is in its execute window
+			status.Phase = v1alpha1.WorkflowStepPhaseRunning
+			status.Reason = types.StatusReasonExecuteWindow
+			status.Message = result.Message
+			return status, &types.Operation{Waiting: true}, nil
+		}
 		if result.Timeout {
 			status.Phase = v1alpha1.WorkflowStepPhaseFailed
 			status.Reason = types.StatusReasonTimeout
@@ -136,9 +150,24 @@ func (tr *stepGroupTaskRunner) Run(ctx wfContext.Context, options *types.TaskRun
 	return status, operations, nil
 }
 
+// finishedFailureReason returns the reason of the first failed sub step which is finished
+func finishedFailureReason(subStepsStatus []v1alpha1.StepStatus) string {
+	for _, ss := range subStepsStatus {
+		if ss.Phase == v1alpha1.WorkflowStepPhaseFailed && ss.Reason != "" && ss.Reason != types.StatusReasonExecute {
+			return ss.Reason
+		}
+	}
+	return ""
+}
+
 func getStepGroupStatus(status v1alpha1.StepStatus, stepStatus v1alpha1.WorkflowStepStatus, operation *types.Operation, subTaskRunners int) (v1alpha1.StepStatus, *types.Operation) {
 	subStepCounts := make(map[string]int)
 	for _, subStepsStatus := range stepStatus.SubStepsStatus {
+		// the sub step with the ignored failure doesn't fail the step group
+		if types.IsStepFailureIgnored(subStepsStatus) {
+			subStepCounts[string(v1alpha1.WorkflowStepPhaseSucceeded)]++
+			continue
+		}
 		subStepCounts[string(subStepsStatus.Phase)]++
 		subStepCounts[subStepsStatus.Reason]++
 	}
@@ -162,10 +191,15 @@ func getStepGroupStatus(status v1alpha1.StepStatus, stepStatus v1alpha1.Workflow
 			status.Reason = types.StatusReasonFailedAfterRetries
 		case subStepCounts[types.StatusReasonTimeout] > 0:
 			status.Reason = types.StatusReasonTimeout
+		case subStepCounts[types.StatusReasonApprovalTimeout] > 0:
+			status.Reason = types.StatusReasonApprovalTimeout
 		case subStepCounts[types.StatusReasonAction] > 0:
 			status.Reason = types.StatusReasonAction
 		case subStepCounts[types.StatusReasonTerminate] > 0:
 			status.Reason = types.StatusReasonTerminate
+		default:
+			// the reasons failing the sub steps by the ops, e.g. the reason of the failed job, finish the step group
+			status.Reason = finishedFailureReason(stepStatus.SubStepsStatus)
 		}
 	case subStepCounts[string(v1alpha1.WorkflowStepPhaseSkipped)] > 0 && subStepCounts[string(v1alpha1.WorkflowStepPhaseSkipped)] == subTaskRunners:
 		status.Phase = v1alpha1.WorkflowStepPhaseSkipped
@@ -107,9 +107,10 @@ func TestStepGroupStep(t *testing.T) {
 
 	// test run
 	testCases := []struct {
-		name          string
-		engine        *testEngine
-		expectedPhase v1alpha1.WorkflowStepPhase
+		name           string
+		engine         *testEngine
+		expectedPhase  v1alpha1.WorkflowStepPhase
+		expectedReason string
 	}{
 		{
 			name: "running1",
@@ -167,6 +168,42 @@ func TestStepGroupStep(t *testing.T) {
 			},
 			expectedPhase: v1alpha1.WorkflowStepPhaseFailed,
 		},
+		{
+			name: "failed job",
+			engine: &testEngine{
+				stepStatus: v1alpha1.WorkflowStepStatus{
+					SubStepsStatus: []v1alpha1.StepStatus{
+						{
+							Name:   "run-job",
+							Type:   "run-job",
+							Phase:  v1alpha1.WorkflowStepPhaseFailed,
+							Reason: "BackoffLimitExceeded",
+						},
+						{
+							Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+						},
+					},
+				},
+				operation: &types.Operation{Terminated: true},
+			},
+			expectedPhase:  v1alpha1.WorkflowStepPhaseFailed,
+			expectedReason: "BackoffLimitExceeded",
+		},
+		{
+			name: "retrying",
+			engine: &testEngine{
+				stepStatus: v1alpha1.WorkflowStepStatus{
+					SubStepsStatus: []v1alpha1.StepStatus{
+						{
+							Phase:  v1alpha1.WorkflowStepPhaseFailed,
+							Reason: types.StatusReasonExecute,
+						},
+					},
+				},
+				operation: &types.Operation{},
+			},
+			expectedPhase: v1alpha1.WorkflowStepPhaseFailed,
+		},
 		{
 			name: "success",
 			engine: &testEngine{
@@ -211,6 +248,10 @@ func TestStepGroupStep(t *testing.T) {
 			r.Equal(status.Name, "test")
 			r.Equal(act.Suspend, tc.engine.operation.Suspend)
 			r.Equal(status.Phase, tc.expectedPhase)
+			r.Equal(tc.expectedReason, status.Reason)
+			if tc.expectedReason != "" {
+				r.True(types.IsStepFinish(status.Phase, status.Reason))
+			}
 		})
 	}
 }
@@ -20,7 +20,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -37,6 +36,21 @@ import (
 	"github.com/kubevela/workflow/pkg/types"
 )
 
+const (
+	// OnTimeoutFail fails the timed out suspend step and the workflow run
+	OnTimeoutFail = "fail"
+	// OnTimeoutSkip skips the timed out suspend step and proceeds
+	OnTimeoutSkip = "skip"
+	// OnTimeoutContinue regards the timeout of the suspend step as an implicit approval
+	OnTimeoutContinue = "continue"
+)
+
+type suspendProperties struct {
+	Duration  string `json:"duration"`
+	OnTimeout string `json:"onTimeout"`
+}
+
+// Suspend create a suspend task runner.
 func Suspend(step v1alpha1.WorkflowStep, opt *types.TaskGeneratorOptions) (types.TaskRunner, error) {
 	tr := &suspendTaskRunner{
 		id:   opt.ID,
@@ -88,6 +102,12 @@ func (tr *suspendTaskRunner) Run(ctx wfContext.Context, options *types.TaskRunOp
 	}
 	defer handleOutput(ctx, status, operations, tr.step, options.PostStopHooks, basicVal)
 
+	props, err := getSuspendProperties(tr.step)
+	if err != nil {
+		failInvalidSuspend(status, operations, fmt.Sprintf("invalid suspend properties: %s", err.Error()))
+		return stepStatus, operations, nil
+	}
+
 	for _, hook := range options.PreCheckHooks {
 		result, err := hook(tr.step, &types.PreCheckOptions{
 			PackageDiscover: tr.pd,
@@ -106,13 +126,22 @@ func (tr *suspendTaskRunner) Run(ctx wfContext.Context, options *types.TaskRunOp
 		case result.Skip:
 			stepStatus.Phase = v1alpha1.WorkflowStepPhaseSkipped
 			stepStatus.Reason = types.StatusReasonSkip
+			stepStatus.Message = result.Message
 			operations.Suspend = false
 			operations.Skip = true
-		case result.Timeout:
+		case result.Failed:
 			stepStatus.Phase = v1alpha1.WorkflowStepPhaseFailed
-			stepStatus.Reason = types.StatusReasonTimeout
+			stepStatus.Reason = types.StatusReasonCondition
+			stepStatus.Message = result.Message
 			operations.Suspend = false
 			operations.Terminated = true
+		case result.Wait:
+			stepStatus.Reason = types.StatusReasonExecuteWindow
+			stepStatus.Message = result.Message
+			operations.Suspend = false
+			operations.Waiting = true
+		case result.Timeout:
+			handleSuspendTimeout(status, operations, tr.step, props.OnTimeout, result.ParentTimeout)
 		default:
 			continue
 		}
@@ -121,7 +150,7 @@ func (tr *suspendTaskRunner) Run(ctx wfContext.Context, options *types.TaskRunOp
 
 	for _, input := range tr.step.Inputs {
 		if input.ParameterKey == "duration" {
-			inputValue, err := ctx.GetVar(strings.Split(input.From, ".")...)
+			inputValue, err := wfContext.LookupVar(ctx, value.SplitPath(input.From)...)
 			if err != nil {
 				return v1alpha1.StepStatus{}, nil, errors.WithMessagef(err, "do preStartHook: get input from [%s]", input.From)
 			}
@@ -134,16 +163,20 @@ func (tr *suspendTaskRunner) Run(ctx wfContext.Context, options *types.TaskRunOp
 	}
 	d, err := GetSuspendStepDurationWaiting(tr.step)
 	if err != nil {
-		stepStatus.Message = fmt.Sprintf("invalid suspend duration: %s", err.Error())
+		failInvalidSuspend(status, operations, fmt.Sprintf("invalid suspend duration: %s", err.Error()))
 		return stepStatus, operations, nil
 	}
 	if d != 0 {
 		e := options.Engine
-		firstExecuteTime := time.Now()
+		now := time.Now()
+		if options.Clock != nil {
+			now = options.Clock.Now()
+		}
+		firstExecuteTime := now
 		if ss := e.GetCommonStepStatus(tr.step.Name); !ss.FirstExecuteTime.IsZero() {
 			firstExecuteTime = ss.FirstExecuteTime.Time
 		}
-		if time.Now().After(firstExecuteTime.Add(d)) {
+		if now.After(firstExecuteTime.Add(d)) {
 			stepStatus.Phase = v1alpha1.WorkflowStepPhaseSucceeded
 			operations.Suspend = false
 		}
@@ -157,25 +190,69 @@ func (tr *suspendTaskRunner) Pending(ctx monitorContext.Context, wfCtx wfContext
 	return custom.CheckPending(wfCtx, tr.step, tr.id, stepStatus, basicVal)
 }
 
-// GetSuspendStepDurationWaiting get suspend step wait duration
-func GetSuspendStepDurationWaiting(step v1alpha1.WorkflowStep) (time.Duration, error) {
+// failInvalidSuspend fails the suspend step with the invalid properties, which can never be resumed as expected
+func failInvalidSuspend(status *v1alpha1.StepStatus, operations *types.Operation, message string) {
+	status.Phase = v1alpha1.WorkflowStepPhaseFailed
+	status.Reason = types.StatusReasonParameter
+	status.Message = message
+	operations.Suspend = false
+	operations.Terminated = true
+}
+
+// handleSuspendTimeout handles the suspend step which is not resumed in the timeout, the step fails with the workflow
+// run by default and it's skipped or succeeded according to the onTimeout property.
+func handleSuspendTimeout(status *v1alpha1.StepStatus, operations *types.Operation, step v1alpha1.WorkflowStep, onTimeout string, parentTimeout bool) {
+	operations.Suspend = false
+	// the step timed out with its parent step group is not waiting for the approval, the onTimeout property only
+	// applies to the timeout of the step itself
+	if onTimeout == "" || step.Timeout == "" || parentTimeout {
+		status.Phase = v1alpha1.WorkflowStepPhaseFailed
+		status.Reason = types.StatusReasonTimeout
+		operations.Terminated = true
+		return
+	}
+	status.Reason = types.StatusReasonApprovalTimeout
+	status.Message = fmt.Sprintf(types.MessageApprovalTimeout, step.Timeout)
+	switch onTimeout {
+	case OnTimeoutSkip:
+		status.Phase = v1alpha1.WorkflowStepPhaseSkipped
+		operations.Skip = true
+	case OnTimeoutContinue:
+		status.Phase = v1alpha1.WorkflowStepPhaseSucceeded
+	default:
+		status.Phase = v1alpha1.WorkflowStepPhaseFailed
+		operations.Terminated = true
+	}
+}
+
+func getSuspendProperties(step v1alpha1.WorkflowStep) (suspendProperties, error) {
+	var props suspendProperties
 	if step.Properties.Size() > 0 {
-		o := struct {
-			Duration string `json:"duration"`
-		}{}
 		js, err := step.Properties.MarshalJSON()
 		if err != nil {
-			return 0, err
-		}
-		if err := json.Unmarshal(js, &o); err != nil {
-			return 0, err
+			return props, err
 		}
-		if o.Duration != "" {
-			waitDuration, err := time.ParseDuration(o.Duration)
-			return waitDuration, err
+		if err := json.Unmarshal(js, &props); err != nil {
+			return props, err
 		}
 	}
+	switch props.OnTimeout {
+	case "", OnTimeoutFail, OnTimeoutSkip, OnTimeoutContinue:
+		return props, nil
+	default:
+		return props, fmt.Errorf("onTimeout must be one of %s, %s and %s", OnTimeoutFail, OnTimeoutSkip, OnTimeoutContinue)
+	}
+}
 
+// GetSuspendStepDurationWaiting get suspend step wait duration
+func GetSuspendStepDurationWaiting(step v1alpha1.WorkflowStep) (time.Duration, error) {
+	props, err := getSuspendProperties(step)
+	if err != nil {
+		return 0, err
+	}
+	if props.Duration != "" {
+		return time.ParseDuration(props.Duration)
+	}
 	return 0, nil
 }
 
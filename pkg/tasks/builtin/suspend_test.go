@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 	"github.com/kubevela/workflow/api/v1alpha1"
@@ -87,3 +88,104 @@ func TestSuspendStep(t *testing.T) {
 	r.Equal(status.Name, "test")
 	r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseRunning)
 }
+
+func TestSuspendStepOnTimeout(t *testing.T) {
+	testCases := map[string]struct {
+		onTimeout     string
+		parentTimeout bool
+		phase         v1alpha1.WorkflowStepPhase
+		reason        string
+		skip          bool
+		terminated    bool
+	}{
+		"default": {
+			phase:      v1alpha1.WorkflowStepPhaseFailed,
+			reason:     types.StatusReasonTimeout,
+			terminated: true,
+		},
+		"fail": {
+			onTimeout:  OnTimeoutFail,
+			phase:      v1alpha1.WorkflowStepPhaseFailed,
+			reason:     types.StatusReasonApprovalTimeout,
+			terminated: true,
+		},
+		"skip": {
+			onTimeout: OnTimeoutSkip,
+			phase:     v1alpha1.WorkflowStepPhaseSkipped,
+			reason:    types.StatusReasonApprovalTimeout,
+			skip:      true,
+		},
+		"continue": {
+			onTimeout: OnTimeoutContinue,
+			phase:     v1alpha1.WorkflowStepPhaseSucceeded,
+			reason:    types.StatusReasonApprovalTimeout,
+		},
+		"timed out with the parent step group": {
+			onTimeout:     OnTimeoutContinue,
+			parentTimeout: true,
+			phase:         v1alpha1.WorkflowStepPhaseFailed,
+			reason:        types.StatusReasonTimeout,
+			terminated:    true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			ctx := newWorkflowContextForTest(t)
+			runner, err := Suspend(v1alpha1.WorkflowStep{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name:       "approve",
+					Timeout:    "1h",
+					Properties: &runtime.RawExtension{Raw: []byte(`{"onTimeout":"` + tc.onTimeout + `"}`)},
+				},
+			}, &types.TaskGeneratorOptions{ID: "approve"})
+			r.NoError(err)
+			status, operations, err := runner.Run(ctx, &types.TaskRunOptions{
+				PreCheckHooks: []types.TaskPreCheckHook{
+					func(step v1alpha1.WorkflowStep, options *types.PreCheckOptions) (*types.PreCheckResult, error) {
+						return &types.PreCheckResult{Timeout: true, ParentTimeout: tc.parentTimeout}, nil
+					},
+				},
+			})
+			r.NoError(err)
+			r.Equal(tc.phase, status.Phase)
+			r.Equal(tc.reason, status.Reason)
+			r.False(operations.Suspend)
+			r.Equal(tc.skip, operations.Skip)
+			r.Equal(tc.terminated, operations.Terminated)
+		})
+	}
+
+	_, err := GetSuspendStepDurationWaiting(v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Properties: &runtime.RawExtension{Raw: []byte(`{"onTimeout":"retry"}`)},
+		},
+	})
+	require.Error(t, err)
+}
+
+func TestSuspendStepInvalidProperties(t *testing.T) {
+	for name, props := range map[string]string{
+		"invalid onTimeout": `{"onTimeout":"retry"}`,
+		"invalid duration":  `{"duration":"1 hour"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			ctx := newWorkflowContextForTest(t)
+			runner, err := Suspend(v1alpha1.WorkflowStep{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name:       "approve",
+					Properties: &runtime.RawExtension{Raw: []byte(props)},
+				},
+			}, &types.TaskGeneratorOptions{ID: "approve"})
+			r.NoError(err)
+			status, operations, err := runner.Run(ctx, &types.TaskRunOptions{})
+			r.NoError(err)
+			r.Equal(v1alpha1.WorkflowStepPhaseFailed, status.Phase)
+			r.Equal(types.StatusReasonParameter, status.Reason)
+			r.Contains(status.Message, "invalid suspend")
+			r.False(operations.Suspend)
+			r.True(operations.Terminated)
+		})
+	}
+}
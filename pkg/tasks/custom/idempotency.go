@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package custom
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// fieldIdempotencyKey is the field of the http op to send the request with the idempotency key
+	fieldIdempotencyKey = "idempotencyKey"
+	// fieldIdempotencyHeader is the field of the http op to specify the header of the idempotency key
+	fieldIdempotencyHeader = "idempotencyHeader"
+	// fieldForce is the field of the http op to send the request even if a response is recorded for the key
+	fieldForce = "force"
+
+	// idempotencyKeyAuto derives the idempotency key from the workflow run, the step and the op
+	idempotencyKeyAuto = "auto"
+	// defaultIdempotencyHeader is the default header of the idempotency key
+	defaultIdempotencyHeader = "Idempotency-Key"
+)
+
+// idempotencyKey returns the idempotency key of the http op, the key of "auto" is derived from the uid of the
+// workflow run, the name of the step and the index of the op, so it's stable across the reconciles.
+func idempotencyKey(provider, do string, v *value.Value, runUID, stepName string, index int) string {
+	if provider != "http" || do != "do" {
+		return ""
+	}
+	key, err := v.GetString(fieldIdempotencyKey)
+	if err != nil || key == "" {
+		return ""
+	}
+	if key == idempotencyKeyAuto {
+		return fmt.Sprintf("%s-%s-%d", runUID, stepName, index)
+	}
+	return key
+}
+
+// fillIdempotencyHeader fills the idempotency key in the header of the request
+func fillIdempotencyHeader(v *value.Value, key string) error {
+	header, err := v.GetString(fieldIdempotencyHeader)
+	if err != nil || header == "" {
+		header = defaultIdempotencyHeader
+	}
+	fields := fmt.Sprintf("%q: %q", header, key)
+	if _, err := v.LookupValue("request", "header"); err != nil {
+		// keep the default content type of the request without the header
+		fields += `, "Content-Type": "application/json"`
+	}
+	return v.FillRaw(fmt.Sprintf("header: {%s}", fields), "request")
+}
+
+// succeededResponse returns true if the http op gets a successful response
+func succeededResponse(v *value.Value) bool {
+	code, err := v.GetInt64("response", "statusCode")
+	return err == nil && code >= 200 && code < 300
+}
+
+// getIdempotentResult returns the result recorded by the op with the idempotency key, it's empty if no
+// successful response is recorded
+func getIdempotentResult(wfCtx wfContext.Context, key string) string {
+	return wfCtx.GetMutableValue(types.ContextPrefixIdempotentResults, idempotencyKeyHash(key))
+}
+
+// setIdempotentResult records the first successful result of the op with the idempotency key
+func setIdempotentResult(wfCtx wfContext.Context, key, result string) {
+	wfCtx.SetMutableValue(result, types.ContextPrefixIdempotentResults, idempotencyKeyHash(key))
+}
+
+// idempotencyKeyHash hashes the idempotency key to be used in the key of the workflow context
+func idempotencyKeyHash(key string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+}
+
+// handleIdempotent sends the request of the op with the idempotency key, the first successful response is recorded
+// for the key and filled to the later executions instead of sending the request again, unless the op is forced.
+func (exec *executor) handleIdempotent(ctx monitorContext.Context, wfCtx wfContext.Context, h types.Handler, provider, do string, v *value.Value, key string) error {
+	recorded := getIdempotentResult(wfCtx, key)
+	if force, err := v.GetBool(fieldForce); recorded != "" && (err != nil || !force) {
+		return errors.WithMessage(fillOpResult(v, recorded), "fill the recorded result of the idempotency key")
+	}
+	if err := fillIdempotencyHeader(v, key); err != nil {
+		return errors.WithMessage(err, "fill the idempotency key")
+	}
+	before := concreteFields(v)
+	if err := exec.handle(ctx, wfCtx, h, provider, do, v); err != nil {
+		return err
+	}
+	if recorded != "" || !succeededResponse(v) {
+		return nil
+	}
+	result, err := opResult(before, v)
+	if err != nil {
+		return err
+	}
+	setIdempotentResult(wfCtx, key, result)
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package custom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/cue/process"
+	"github.com/kubevela/workflow/pkg/providers"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestIdempotentOps(t *testing.T) {
+	r := require.New(t)
+	var keys []string
+	statusCode := 500
+	discover := providers.NewProviders()
+	discover.Register("http", map[string]types.Handler{
+		"do": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			hv, err := v.LookupValue("request", "header")
+			r.NoError(err)
+			header := map[string]string{}
+			r.NoError(hv.UnmarshalTo(&header))
+			r.Equal("application/json", header["Content-Type"])
+			keys = append(keys, header["X-Request-Key"])
+			return v.FillObject(map[string]interface{}{"body": "created", "statusCode": statusCode}, "response")
+		},
+	})
+	force := false
+	loadTemplate := func(_ context.Context, name string) (string, error) {
+		forced := "false"
+		if force {
+			forced = "true"
+		}
+		return `
+post: {
+	#provider:         "http"
+	#do:               "do"
+	method:            "POST"
+	url:               "https://example.com"
+	idempotencyKey:    "auto"
+	idempotencyHeader: "X-Request-Key"
+	force:             ` + forced + `
+}
+`, nil
+	}
+	pCtx := process.NewContext(process.ContextData{Name: "app", Namespace: "default", Data: map[string]interface{}{model.ContextRunUID: "uid"}})
+	tasksLoader := NewTaskLoader(loadTemplate, nil, discover, 0, pCtx)
+	wfCtx := newWorkflowContextForTest(t)
+	step := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "step", Type: "test"}}
+	run := func() {
+		gen, err := tasksLoader.GetTaskGenerator(context.Background(), step.Type)
+		r.NoError(err)
+		runner, err := gen(step, &types.TaskGeneratorOptions{ID: "step-id"})
+		r.NoError(err)
+		_, _, err = runner.Run(wfCtx, &types.TaskRunOptions{})
+		r.NoError(err)
+	}
+
+	// the failed response is not recorded
+	run()
+	r.Equal([]string{"uid-step-0"}, keys)
+	r.Equal("", getIdempotentResult(wfCtx, "uid-step-0"))
+
+	statusCode = 201
+	run()
+	r.Equal([]string{"uid-step-0", "uid-step-0"}, keys)
+	r.Contains(getIdempotentResult(wfCtx, "uid-step-0"), `"statusCode":201`)
+
+	// the recorded response is returned instead of sending the request again
+	run()
+	r.Len(keys, 2)
+
+	force = true
+	statusCode = 200
+	run()
+	r.Len(keys, 3)
+	r.Contains(getIdempotentResult(wfCtx, "uid-step-0"), `"statusCode":201`)
+}
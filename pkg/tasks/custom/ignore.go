@@ -0,0 +1,50 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package custom
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+// ignoreError returns true if the error of the op is recorded instead of failing the step, it's set by
+// `ignoreError` in the op
+func ignoreError(v *value.Value) bool {
+	ignore, err := v.GetBool(value.FieldIgnoreError)
+	return err == nil && ignore
+}
+
+// recordIgnoredError fills the error in the `error` field of the op and records it in the message history of the
+// step, so that the later ops are executed as if the op succeeded
+func (exec *executor) recordIgnoredError(ctx monitorContext.Context, provider, do string, v *value.Value, err error) error {
+	ctx.Info("ignore the error of the op", "provider", provider, "do", do, "error", err.Error())
+	if fillErr := v.FillObject(err.Error(), value.FieldError); fillErr != nil {
+		return errors.WithMessagef(fillErr, "fill the ignored error %s", err.Error())
+	}
+	exec.wfStatus.MessageHistory = AppendMessageHistory(exec.wfStatus.MessageHistory, v1alpha1.StepMessage{
+		Message: fmt.Sprintf("ignored the error of %s.%s: %s", provider, do, err.Error()),
+		Time:    metav1.Now(),
+	})
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package custom
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/cue/process"
+	"github.com/kubevela/workflow/pkg/providers"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestIgnoreError(t *testing.T) {
+	applied := 0
+	discover := providers.NewProviders()
+	discover.Register("http", map[string]types.Handler{
+		"do": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			return errors.New("connection refused")
+		},
+	})
+	discover.Register("kube", map[string]types.Handler{
+		"apply": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			applied++
+			return nil
+		},
+		"fail": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			act.Fail("invalid object")
+			return errors.New("invalid object")
+		},
+	})
+	testCases := map[string]struct {
+		template string
+		phase    v1alpha1.WorkflowStepPhase
+		applied  int
+		message  string
+	}{
+		"ignore the error": {
+			template: `
+notify: {
+	#provider:   "http"
+	#do:         "do"
+	ignoreError: true
+}
+apply: {
+	#provider: "kube"
+	#do:       "apply"
+	notified:  notify.error
+}
+`,
+			phase:   v1alpha1.WorkflowStepPhaseSucceeded,
+			applied: 1,
+			message: "ignored the error of http.do: connection refused",
+		},
+		"not ignored": {
+			template: `
+notify: {
+	#provider: "http"
+	#do:       "do"
+}
+apply: {
+	#provider: "kube"
+	#do:       "apply"
+}
+`,
+			phase: v1alpha1.WorkflowStepPhaseFailed,
+		},
+		"failed by the action": {
+			template: `
+check: {
+	#provider:   "kube"
+	#do:         "fail"
+	ignoreError: true
+}
+apply: {
+	#provider: "kube"
+	#do:       "apply"
+}
+`,
+			phase: v1alpha1.WorkflowStepPhaseFailed,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			applied = 0
+			loadTemplate := func(_ context.Context, name string) (string, error) {
+				return tc.template, nil
+			}
+			pCtx := process.NewContext(process.ContextData{Name: "app", Namespace: "default"})
+			tasksLoader := NewTaskLoader(loadTemplate, nil, discover, 0, pCtx)
+			gen, err := tasksLoader.GetTaskGenerator(context.Background(), "test")
+			r.NoError(err)
+			runner, err := gen(v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "step", Type: "test"}}, &types.TaskGeneratorOptions{ID: "step-id"})
+			r.NoError(err)
+			status, _, err := runner.Run(newWorkflowContextForTest(t), &types.TaskRunOptions{})
+			r.NoError(err)
+			r.Equal(tc.phase, status.Phase)
+			r.Equal(tc.applied, applied)
+			if tc.message != "" {
+				r.Len(status.MessageHistory, 1)
+				r.Equal(tc.message, status.MessageHistory[0].Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package custom
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// runOnce returns true if the op is executed only once in a step, the later executions of the step
+// fill the recorded result instead of executing the op again. It's set by `once` in the op, and defaults to
+// true for the ops which are not idempotent, e.g. the http POST request and sending email. The read-only ops with the
+// large outputs, e.g. read-self, are always executed.
+func runOnce(provider, do string, v *value.Value) bool {
+	// the workflow run read by the op is too large to record in the workflow context
+	if provider == "builtin" && do == "read-self" {
+		return false
+	}
+	if once, err := v.GetBool(value.FieldOnce); err == nil {
+		return once
+	}
+	switch {
+	case provider == "http" && do == "do":
+		method, err := v.GetString("method")
+		return err == nil && method == "POST"
+	case provider == "email" && do == "send":
+		return true
+	default:
+		return false
+	}
+}
+
+// concreteFields returns the concrete regular fields of the op in JSON
+func concreteFields(v *value.Value) map[string]string {
+	fields := map[string]string{}
+	iter, err := v.CueValue().Fields()
+	if err != nil {
+		return fields
+	}
+	for iter.Next() {
+		if !iter.Value().IsConcrete() {
+			continue
+		}
+		if b, err := iter.Value().MarshalJSON(); err == nil {
+			fields[iter.Label()] = string(b)
+		}
+	}
+	return fields
+}
+
+// opResult returns the fields filled by the op in JSON, which are recorded in the completion marker of the op
+func opResult(before map[string]string, v *value.Value) (string, error) {
+	result := map[string]json.RawMessage{}
+	for label, field := range concreteFields(v) {
+		if before[label] != field {
+			result[label] = json.RawMessage(field)
+		}
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// fillOpResult fills the result recorded in the completion marker to the op
+func fillOpResult(v *value.Value, result string) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(result), &fields); err != nil {
+		return err
+	}
+	for label, field := range fields {
+		if err := v.FillRaw(string(field), label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getOpMarker returns the result recorded by the op of the step, it's empty if the op is not completed
+func getOpMarker(wfCtx wfContext.Context, stepID string, index int) string {
+	return wfCtx.GetMutableValue(types.ContextPrefixOpMarkers, stepID, strconv.Itoa(index))
+}
+
+// setOpMarker records the completion of the op of the step with its result in the workflow context
+func setOpMarker(wfCtx wfContext.Context, stepID string, index int, result string) {
+	wfCtx.SetMutableValue(result, types.ContextPrefixOpMarkers, stepID, strconv.Itoa(index))
+}
+
+// clearOpMarkers removes the completion markers of the step, so that the ops are executed again when the step is retried
+func clearOpMarkers(wfCtx wfContext.Context, stepID string) {
+	prefix := strings.Join([]string{types.ContextPrefixOpMarkers, stepID, ""}, ".")
+	for key := range wfCtx.GetStore().Data {
+		if strings.HasPrefix(key, prefix) {
+			wfCtx.DeleteMutableValue(key)
+		}
+	}
+}
+
+// PruneOpMarkers removes the completion markers of the steps which are not in the status, e.g. the restarted steps.
+func PruneOpMarkers(wfCtx wfContext.Context, status v1alpha1.WorkflowRunStatus) {
+	ids := map[string]bool{}
+	for _, ss := range status.Steps {
+		ids[ss.ID] = true
+		for _, sub := range ss.SubStepsStatus {
+			ids[sub.ID] = true
+		}
+	}
+	for key := range wfCtx.GetStore().Data {
+		if id, ok := opMarkerStepID(key); ok && !ids[id] {
+			wfCtx.DeleteMutableValue(key)
+		}
+	}
+}
+
+// OpMarkerStepIDs returns the ids of the steps with the completion markers in the data of the workflow context
+func OpMarkerStepIDs(data map[string]string) map[string]bool {
+	ids := map[string]bool{}
+	for key := range data {
+		if id, ok := opMarkerStepID(key); ok {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// opMarkerStepID returns the id of the step from the key of the completion marker
+func opMarkerStepID(key string) (string, bool) {
+	prefix := types.ContextPrefixOpMarkers + "."
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+	id := strings.TrimPrefix(key, prefix)
+	if i := strings.LastIndex(id, "."); i >= 0 {
+		id = id[:i]
+	}
+	return id, true
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package custom
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/cue/process"
+	"github.com/kubevela/workflow/pkg/providers"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestOnceOps(t *testing.T) {
+	r := require.New(t)
+	posted, ready := 0, false
+	discover := providers.NewProviders()
+	discover.Register("test", map[string]types.Handler{
+		"post": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			posted++
+			return v.FillObject(fmt.Sprintf("response-%d", posted), "response")
+		},
+		"wait": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			if !ready {
+				act.Wait("not ready")
+			}
+			return nil
+		},
+	})
+	loadTemplate := func(_ context.Context, name string) (string, error) {
+		return `
+post: {
+	#provider: "test"
+	#do:       "post"
+	once:      true
+}
+wait: {
+	#provider: "test"
+	#do:       "wait"
+	response:  post.response
+}
+`, nil
+	}
+	pCtx := process.NewContext(process.ContextData{Name: "app", Namespace: "default"})
+	tasksLoader := NewTaskLoader(loadTemplate, nil, discover, 0, pCtx)
+	wfCtx := newWorkflowContextForTest(t)
+	step := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "step", Type: "test"}}
+	run := func() v1alpha1.StepStatus {
+		gen, err := tasksLoader.GetTaskGenerator(context.Background(), step.Type)
+		r.NoError(err)
+		runner, err := gen(step, &types.TaskGeneratorOptions{ID: "step-id"})
+		r.NoError(err)
+		status, _, err := runner.Run(wfCtx, &types.TaskRunOptions{})
+		r.NoError(err)
+		return status
+	}
+
+	status := run()
+	r.Equal(v1alpha1.WorkflowStepPhaseRunning, status.Phase)
+	r.Equal(1, posted)
+	r.Equal(`{"response":"response-1"}`, wfCtx.GetMutableValue(types.ContextPrefixOpMarkers, "step-id", "0"))
+
+	// the completed op is not executed again while the step is waiting for the later ops
+	status = run()
+	r.Equal(v1alpha1.WorkflowStepPhaseRunning, status.Phase)
+	r.Equal(1, posted)
+
+	ready = true
+	status = run()
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, status.Phase)
+	r.Equal(1, posted)
+	r.Equal("", wfCtx.GetMutableValue(types.ContextPrefixOpMarkers, "step-id", "0"))
+}
+
+func TestRunOnce(t *testing.T) {
+	testCases := map[string]struct {
+		provider string
+		do       string
+		op       string
+		expected bool
+	}{
+		"http post": {
+			provider: "http",
+			do:       "do",
+			op:       `method: "POST"`,
+			expected: true,
+		},
+		"http get": {
+			provider: "http",
+			do:       "do",
+			op:       `method: "GET"`,
+		},
+		"http post with once false": {
+			provider: "http",
+			do:       "do",
+			op:       `method: "POST", once: false`,
+		},
+		"email": {
+			provider: "email",
+			do:       "send",
+			op:       `to: ["a@b.c"]`,
+			expected: true,
+		},
+		"apply with once": {
+			provider: "kube",
+			do:       "apply",
+			op:       `once: true`,
+			expected: true,
+		},
+		"apply": {
+			provider: "kube",
+			do:       "apply",
+			op:       `cluster: ""`,
+		},
+		"read self with once": {
+			provider: "builtin",
+			do:       "read-self",
+			op:       `once: true`,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			v, err := value.NewValue(tc.op, nil, "")
+			r.NoError(err)
+			r.Equal(tc.expected, runOnce(tc.provider, tc.do, v))
+		})
+	}
+}
+
+func TestPruneOpMarkers(t *testing.T) {
+	r := require.New(t)
+	wfCtx := newWorkflowContextForTest(t)
+	setOpMarker(wfCtx, "s1", 0, "{}")
+	setOpMarker(wfCtx, "sub1", 1, "{}")
+	setOpMarker(wfCtx, "restarted", 0, "{}")
+	PruneOpMarkers(wfCtx, v1alpha1.WorkflowRunStatus{
+		Steps: []v1alpha1.WorkflowStepStatus{{
+			StepStatus:     v1alpha1.StepStatus{ID: "s1"},
+			SubStepsStatus: []v1alpha1.StepStatus{{ID: "sub1"}},
+		}},
+	})
+	r.Equal("{}", getOpMarker(wfCtx, "s1", 0))
+	r.Equal("{}", getOpMarker(wfCtx, "sub1", 1))
+	r.Equal("", getOpMarker(wfCtx, "restarted", 0))
+}
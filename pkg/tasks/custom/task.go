@@ -18,17 +18,24 @@ package custom
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/parser"
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
 	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
+	wfCue "github.com/kubevela/workflow/pkg/cue"
 	"github.com/kubevela/workflow/pkg/cue/model"
 	"github.com/kubevela/workflow/pkg/cue/model/sets"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
@@ -97,6 +104,7 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 
 		if genOpt != nil {
 			exec.wfStatus.ID = genOpt.ID
+			exec.wfStatus.DefinitionRevision = genOpt.DefinitionRevision
 			if genOpt.StepConvertor != nil {
 				wfStep, err = genOpt.StepConvertor(wfStep)
 				if err != nil {
@@ -110,6 +118,11 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 			return nil, err
 		}
 
+		profile, err := value.GetProfile(wfStep.CUEProfile)
+		if err != nil {
+			return nil, err
+		}
+
 		tRunner := new(taskRunner)
 		tRunner.name = wfStep.Name
 		tRunner.checkPending = func(ctx monitorContext.Context, wfCtx wfContext.Context, stepStatus map[string]v1alpha1.StepStatus) (bool, v1alpha1.StepStatus) {
@@ -135,6 +148,11 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 			if t.runOptionsProcess != nil {
 				t.runOptionsProcess(options)
 			}
+			exec.enableTrace = options.Debug != nil && (options.DebugStep == nil || options.DebugStep(wfStep.Name))
+			exec.audit = options.Audit
+			if options.PCtx != nil {
+				exec.runUID, _ = options.PCtx.GetData(model.ContextRunUID).(string)
+			}
 
 			basicVal, basicTemplate, err := MakeBasicValue(tracer, ctx, t.pd, wfStep.Name, exec.wfStatus.ID, paramsStr, options.PCtx)
 			if err != nil {
@@ -151,13 +169,19 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 					return
 				}
 				if taskv == nil {
-					taskv, err = value.NewValue(strings.Join([]string{templ, basicTemplate}, "\n"), t.pd, "", value.ProcessScript, value.TagFieldOrder)
+					taskv, err = value.NewValueWithProfile(strings.Join([]string{templ, basicTemplate}, "\n"), t.pd, "", profile, value.ProcessScript, value.TagFieldOrder)
 					if err != nil {
+						// the template can not be rendered, record the error along with the basic value instead
+						if options.Debug != nil && exec.stepErr != nil {
+							if err := options.Debug(exec.wfStatus.Name, basicVal, exec.stepErr, exec.trace); err != nil {
+								tracer.Error(err, "failed to debug")
+							}
+						}
 						return
 					}
 				}
 				if options.Debug != nil {
-					if err := options.Debug(exec.wfStatus.Name, taskv); err != nil {
+					if err := options.Debug(exec.wfStatus.Name, taskv, exec.stepErr, exec.trace); err != nil {
 						tracer.Error(err, "failed to debug")
 					}
 				}
@@ -183,7 +207,15 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 					return exec.status(), exec.operation(), nil
 				}
 				if result.Skip {
-					exec.Skip("")
+					exec.Skip(result.Message)
+					return exec.status(), exec.operation(), nil
+				}
+				if result.Failed {
+					exec.conditionFailed(result.Message)
+					return exec.status(), exec.operation(), nil
+				}
+				if result.Wait {
+					exec.outOfWindow(result.Message)
 					return exec.status(), exec.operation(), nil
 				}
 				if result.Timeout {
@@ -191,6 +223,22 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 				}
 			}
 
+			if hooks.HasPatchInputs(wfStep) {
+				patched := wfStep
+				properties, err := hooks.PatchProperties(ctx, wfStep)
+				if err == nil {
+					patched.Properties = properties
+					var patchedParams string
+					if patchedParams, err = GetParameterTemplate(patched); err == nil {
+						basicVal, basicTemplate, err = MakeBasicValue(tracer, ctx, t.pd, wfStep.Name, exec.wfStatus.ID, patchedParams, options.PCtx)
+					}
+				}
+				if err != nil {
+					exec.err(ctx, false, err, types.StatusReasonInput)
+					return exec.status(), exec.operation(), nil
+				}
+			}
+
 			for _, hook := range options.PreStartHooks {
 				if err := hook(ctx, basicVal, wfStep); err != nil {
 					tracer.Error(err, "do preStartHook")
@@ -205,8 +253,12 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 				return exec.status(), exec.operation(), nil
 			}
 
-			taskv, err = value.NewValue(strings.Join([]string{templ, basicTemplate}, "\n"), t.pd, "", value.ProcessScript, value.TagFieldOrder)
+			taskv, err = value.NewValueWithProfile(strings.Join([]string{templ, basicTemplate}, "\n"), t.pd, "", profile, value.ProcessScript, value.TagFieldOrder)
 			if err != nil {
+				var importErr *packages.ImportNotFoundError
+				if errors.As(err, &importErr) {
+					importErr.Definition = wfStep.Type
+				}
 				exec.err(ctx, false, err, types.StatusReasonRendering)
 				return exec.status(), exec.operation(), nil
 			}
@@ -217,11 +269,28 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 				defer exec.printStep("workflowStepEnd", "workflow", "", taskv)
 			}
 
+			exec.opIndex = 0
+			exec.watches, exec.wfStatus.Watches = nil, nil
 			if err := exec.doSteps(tracer, ctx, taskv); err != nil {
 				tracer.Error(err, "do steps")
+				// the step is retried from a clean context
+				clearOpMarkers(ctx, exec.wfStatus.ID)
+				// the forbidden and the invalid errors of the kube client won't be fixed by the retries
+				if clientErr, ok := types.AsTerminalClientError(err); ok {
+					exec.err(ctx, false, err, string(clientErr.Class))
+					return exec.status(), exec.operation(), nil
+				}
 				exec.err(ctx, true, err, types.StatusReasonExecute)
 				return exec.status(), exec.operation(), nil
 			}
+			// the completion markers are only kept for the step waiting for the later ops
+			if !exec.wait && !exec.suspend {
+				clearOpMarkers(ctx, exec.wfStatus.ID)
+			}
+			// the watches are only kept for the step waiting on the objects, they are dropped once it completes
+			if exec.wait && exec.wfStatus.Phase == v1alpha1.WorkflowStepPhaseRunning {
+				exec.wfStatus.Watches = exec.watches
+			}
 
 			return exec.status(), exec.operation(), nil
 		}
@@ -231,23 +300,150 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 
 // ValidateIfValue validates the if value
 func ValidateIfValue(ctx wfContext.Context, step v1alpha1.WorkflowStep, stepStatus map[string]v1alpha1.StepStatus, options *types.PreCheckOptions) (bool, error) {
+	check, _, err := EvaluateIfValue(ctx, step, stepStatus, options)
+	return check, err
+}
+
+// EvaluateIfValue evaluates the if value and explains the result with the values referenced in it,
+// e.g. `if "status.build.succeeded" evaluated to false (status.build.succeeded=false)`.
+// If the if value can not be evaluated because of an incomplete field, the explanation tells the field with the error.
+// The if value can import the packages of CUE standard library before the expression, e.g.
+// `import "strings"` followed by `strings.HasPrefix(context.labels.env, "prod")`.
+func EvaluateIfValue(ctx wfContext.Context, step v1alpha1.WorkflowStep, stepStatus map[string]v1alpha1.StepStatus, options *types.PreCheckOptions) (bool, string, error) {
 	if options == nil {
 		options = &types.PreCheckOptions{}
 	}
-	template := fmt.Sprintf("if: %s", step.If)
+	imports, expr := SplitImports(step.If)
+	refs := ifReferences(expr)
+	template := fmt.Sprintf("%s\nif: %s", imports, expr)
 	value, err := buildValueForStatus(ctx, step, template, stepStatus, options)
 	if err != nil {
-		return false, errors.WithMessage(err, "invalid if value")
+		// build the value without the if expression to check whether the error is caused by an incomplete field
+		if base, baseErr := buildValueForStatus(ctx, step, "", stepStatus, options); baseErr == nil {
+			if ref := incompleteReference(base, refs); ref != "" {
+				return false, fmt.Sprintf("condition could not be evaluated: field %s is incomplete", ref), errors.WithMessage(err, "invalid if value")
+			}
+		}
+		return false, "", errors.WithMessage(err, "invalid if value")
 	}
 	check, err := value.GetBool("if")
 	if err != nil {
-		return false, err
+		if ref := incompleteReference(value, refs); ref != "" {
+			return false, fmt.Sprintf("condition could not be evaluated: field %s is incomplete", ref), err
+		}
+		return false, "", err
+	}
+	var resolved []string
+	for _, ref := range refs {
+		v := value.CueValue().LookupPath(cue.ParsePath(ref))
+		if v.Kind() == cue.BoolKind || v.Kind() == cue.StringKind || v.Kind()&cue.NumberKind != 0 {
+			resolved = append(resolved, fmt.Sprintf("%s=%v", ref, v))
+		}
+	}
+	message := fmt.Sprintf("if %q evaluated to %t", expr, check)
+	if len(resolved) > 0 {
+		message += fmt.Sprintf(" (%s)", strings.Join(resolved, ", "))
+	}
+	return check, message, nil
+}
+
+// incompleteReference returns the first reference that does not exist or is not concrete in the value
+func incompleteReference(v *value.Value, refs []string) string {
+	for _, ref := range refs {
+		if field := v.CueValue().LookupPath(cue.ParsePath(ref)); !field.Exists() || !field.IsConcrete() {
+			return ref
+		}
+	}
+	return ""
+}
+
+// SplitImports splits the import declarations from the expression, the imports are placed at the top of the template.
+// The expression is returned as it is if it has no imports or can not be parsed, so that the error is reported in evaluation.
+func SplitImports(expr string) (string, string) {
+	f, err := parser.ParseFile("if", expr)
+	if err != nil || len(f.Imports) == 0 {
+		return "", expr
+	}
+	var imports []string
+	var body ast.Expr
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.ImportDecl:
+			b, err := format.Node(d)
+			if err != nil {
+				return "", expr
+			}
+			imports = append(imports, string(b))
+		case *ast.EmbedDecl:
+			if body != nil {
+				return "", expr
+			}
+			body = d.Expr
+		default:
+			return "", expr
+		}
+	}
+	if body == nil {
+		return "", expr
+	}
+	b, err := format.Node(body)
+	if err != nil {
+		return "", expr
 	}
-	return check, nil
+	return strings.Join(imports, "\n"), string(b)
+}
+
+// ifReferences returns the references to the status, inputs, steps, context and parameter in the if expression
+func ifReferences(expr string) []string {
+	node, err := parser.ParseExpr("if", expr)
+	if err != nil {
+		return nil
+	}
+	var refs []string
+	seen := map[string]bool{}
+	ast.Walk(node, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.SelectorExpr, *ast.IndexExpr:
+		default:
+			return true
+		}
+		root := n
+		for {
+			if sel, ok := root.(*ast.SelectorExpr); ok {
+				root = sel.X
+				continue
+			}
+			if index, ok := root.(*ast.IndexExpr); ok {
+				root = index.X
+				continue
+			}
+			break
+		}
+		ident, ok := root.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		switch ident.Name {
+		case "status", "inputs", "steps", "context", model.ParameterFieldName:
+		default:
+			return true
+		}
+		b, err := format.Node(n)
+		if err == nil && !seen[string(b)] {
+			seen[string(b)] = true
+			refs = append(refs, string(b))
+		}
+		return false
+	}, nil)
+	return refs
 }
 
 func buildValueForStatus(ctx wfContext.Context, step v1alpha1.WorkflowStep, template string, stepStatus map[string]v1alpha1.StepStatus, options *types.PreCheckOptions) (*value.Value, error) {
-	inputsTemplate := getInputsTemplate(ctx, step, options.BasicValue)
+	inputsTemplate := getInputsTemplate(ctx, withParentInputs(step, options.ParentStep), options.BasicValue)
+	stepsTemplate, err := getStepsTemplate(ctx, options.Steps, stepStatus)
+	if err != nil {
+		return nil, err
+	}
 	statusTemplate := "\n"
 	statusMap := make(map[string]interface{})
 	for name, ss := range stepStatus {
@@ -264,7 +460,7 @@ func buildValueForStatus(ctx wfContext.Context, step v1alpha1.WorkflowStep, temp
 			Failed:             ss.Phase == v1alpha1.WorkflowStepPhaseFailed,
 			Succeeded:          ss.Phase == v1alpha1.WorkflowStepPhaseSucceeded,
 			Skipped:            ss.Phase == v1alpha1.WorkflowStepPhaseSkipped,
-			Timeout:            ss.Reason == types.StatusReasonTimeout,
+			Timeout:            ss.Reason == types.StatusReasonTimeout || ss.Reason == types.StatusReasonApprovalTimeout,
 			FailedAfterRetries: ss.Reason == types.StatusReasonFailedAfterRetries,
 			Terminate:          ss.Reason == types.StatusReasonTerminate,
 		}
@@ -274,7 +470,7 @@ func buildValueForStatus(ctx wfContext.Context, step v1alpha1.WorkflowStep, temp
 	if err != nil {
 		return nil, err
 	}
-	statusTemplate = strings.Join([]string{statusTemplate, fmt.Sprintf("status: %s\n", status), options.BasicTemplate, inputsTemplate}, "\n")
+	statusTemplate = strings.Join([]string{statusTemplate, fmt.Sprintf("status: %s\n", status), options.BasicTemplate, inputsTemplate, stepsTemplate}, "\n")
 	v, err := value.NewValue(template+"\n"+statusTemplate, options.PackageDiscover, "")
 	if err != nil {
 		return nil, err
@@ -285,6 +481,75 @@ func buildValueForStatus(ctx wfContext.Context, step v1alpha1.WorkflowStep, temp
 	return v, nil
 }
 
+// withParentInputs returns the sub step with the inputs of its step group, so that the if of the sub step can refer
+// to the inputs bound on the step group. The inputs of the sub step take precedence over the ones of the step group.
+func withParentInputs(step v1alpha1.WorkflowStep, parent *v1alpha1.WorkflowStep) v1alpha1.WorkflowStep {
+	if parent == nil || len(parent.Inputs) == 0 {
+		return step
+	}
+	declared := map[string]bool{}
+	for _, input := range step.Inputs {
+		declared[input.From] = true
+	}
+	inputs := append(v1alpha1.StepInputs{}, step.Inputs...)
+	for _, input := range parent.Inputs {
+		if !declared[input.From] {
+			inputs = append(inputs, input)
+		}
+	}
+	step.Inputs = inputs
+	return step
+}
+
+// getStepsTemplate returns the outputs of the succeeded steps as steps.<step>.output.<output> and the ones of the
+// succeeded sub steps as steps.<step group>.<sub step>.output.<output>. The outputs of the skipped and the unfinished
+// steps are absent, so that the if can tell them with `steps.<step>.output.<output> != _|_`.
+func getStepsTemplate(ctx wfContext.Context, steps []v1alpha1.WorkflowStep, stepStatus map[string]v1alpha1.StepStatus) (string, error) {
+	qualified := hooks.QualifiedOutputs(steps)
+	outputs := func(step v1alpha1.WorkflowStepBase) map[string]interface{} {
+		if stepStatus[step.Name].Phase != v1alpha1.WorkflowStepPhaseSucceeded || len(step.Outputs) == 0 {
+			return nil
+		}
+		values := map[string]interface{}{}
+		for _, output := range step.Outputs {
+			key := output.Name
+			if name, ok := qualified[step.Name][output.Name]; ok {
+				key = name
+			}
+			v, err := wfContext.LookupVar(ctx, value.SplitPath(key)...)
+			if err != nil {
+				continue
+			}
+			b, err := v.CueValue().MarshalJSON()
+			if err != nil {
+				continue
+			}
+			values[output.Name] = json.RawMessage(b)
+		}
+		return map[string]interface{}{"output": values}
+	}
+	stepsMap := map[string]interface{}{}
+	for _, step := range steps {
+		s := outputs(step.WorkflowStepBase)
+		for _, sub := range step.SubSteps {
+			if o := outputs(sub); o != nil {
+				if s == nil {
+					s = map[string]interface{}{}
+				}
+				s[sub.Name] = o
+			}
+		}
+		if s != nil {
+			stepsMap[step.Name] = s
+		}
+	}
+	b, err := json.Marshal(stepsMap)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("steps: %s\n", b), nil
+}
+
 // MakeBasicValue makes basic value
 func MakeBasicValue(ctx monitorContext.Context, wfCtx wfContext.Context, pd *packages.PackageDiscover, step, id, parameterTemplate string, pCtx process.Context) (*value.Value, string, error) {
 	paramStr := model.ParameterFieldName + ": {}\n"
@@ -312,6 +577,21 @@ func getContextTemplate(ctx monitorContext.Context, wfCtx wfContext.Context, ste
 		}
 		contextTempl = fmt.Sprintf("\ncontext: {%s}\ncontext: stepSessionID: \"%s\"", ms, id)
 	}
+	if vars, err := wfCtx.GetVar(types.ContextKeyVars); err == nil && vars != nil {
+		vs, err := vars.String()
+		if err != nil {
+			return ""
+		}
+		contextTempl += fmt.Sprintf("\ncontext: %s: {%s}", model.ContextVar, vs)
+	}
+	// the sensitive vars are only kept in memory, they are never written into the context ConfigMap
+	if vars, ok := wfCtx.GetValueInMemory(types.ContextKeySensitiveVars); ok {
+		b, err := json.Marshal(vars)
+		if err != nil {
+			return ""
+		}
+		contextTempl += fmt.Sprintf("\ncontext: %s: %s", model.ContextVar, b)
+	}
 	if pCtx == nil {
 		return ""
 	}
@@ -346,16 +626,47 @@ func GetParameterTemplate(step v1alpha1.WorkflowStep) (string, error) {
 	return "", nil
 }
 
+// GetPropertiesHash returns the hash of the properties of the step, it's recorded in the step status
+// to detect the changes of the properties after the step is started.
+func GetPropertiesHash(step v1alpha1.WorkflowStepBase) (string, error) {
+	params, err := GetParameterTemplate(v1alpha1.WorkflowStep{WorkflowStepBase: step})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(params))), nil
+}
+
+// AppendMessageHistory appends the messages to the message history of the step, the message identical to the latest
+// one or older than it is dropped, only the last MaxStepMessageHistory messages are kept.
+func AppendMessageHistory(history []v1alpha1.StepMessage, messages ...v1alpha1.StepMessage) []v1alpha1.StepMessage {
+	if types.MaxStepMessageHistory <= 0 {
+		return nil
+	}
+	history = append([]v1alpha1.StepMessage(nil), history...)
+	for _, msg := range messages {
+		if n := len(history); n > 0 && (history[n-1].Message == msg.Message || msg.Time.Before(&history[n-1].Time)) {
+			continue
+		}
+		history = append(history, msg)
+	}
+	if len(history) > types.MaxStepMessageHistory {
+		history = history[len(history)-types.MaxStepMessageHistory:]
+	}
+	return history
+}
+
 func getInputsTemplate(ctx wfContext.Context, step v1alpha1.WorkflowStep, basicVal *value.Value) string {
 	var inputsTempl string
 	for _, input := range step.Inputs {
-		inputValue, err := ctx.GetVar(strings.Split(input.From, ".")...)
+		inputValue, err := wfContext.LookupVar(ctx, value.SplitPath(input.From)...)
 		if err != nil {
-			if basicVal == nil {
-				continue
+			if basicVal != nil {
+				inputValue, err = basicVal.LookupValue(input.From)
 			}
-			inputValue, err = basicVal.LookupValue(input.From)
 			if err != nil {
+				if input.Default != nil {
+					inputsTempl += fmt.Sprintf("\ninputs: \"%s\": %s", input.From, string(input.Default.Raw))
+				}
 				continue
 			}
 		}
@@ -377,6 +688,21 @@ type executor struct {
 	failedAfterRetries bool
 	wait               bool
 	skip               bool
+	stepErr            error
+	// opIndex is the index of the op to execute in the step, it keys the completion marker of the op
+	opIndex int
+	// runUID is the uid of the workflow run, it derives the idempotency keys of the ops
+	runUID string
+	// trace records the ops executed in the step if the debug is enabled
+	trace       []types.OpTrace
+	enableTrace bool
+	// audit receives the audit records of the ops, auditTargets are the targets recorded by the running op
+	audit        func(record types.AuditRecord)
+	auditTargets []types.AuditTarget
+	// watches are the objects watched by the ops of the step
+	watches []v1alpha1.WatchedObject
+	// runningOp is the op being handled, the failure of the step raised by it is attributed to it
+	runningOp *v1alpha1.StepOp
 
 	tracer monitorContext.Context
 }
@@ -385,9 +711,7 @@ type executor struct {
 func (exec *executor) Suspend(message string) {
 	exec.suspend = true
 	exec.wfStatus.Phase = v1alpha1.WorkflowStepPhaseSucceeded
-	if message != "" {
-		exec.wfStatus.Message = message
-	}
+	exec.setMessage(message)
 	exec.wfStatus.Reason = types.StatusReasonSuspend
 }
 
@@ -395,9 +719,7 @@ func (exec *executor) Suspend(message string) {
 func (exec *executor) Terminate(message string) {
 	exec.terminated = true
 	exec.wfStatus.Phase = v1alpha1.WorkflowStepPhaseSucceeded
-	if message != "" {
-		exec.wfStatus.Message = message
-	}
+	exec.setMessage(message)
 	exec.wfStatus.Reason = types.StatusReasonTerminate
 }
 
@@ -407,9 +729,7 @@ func (exec *executor) Wait(message string) {
 	if exec.wfStatus.Phase != v1alpha1.WorkflowStepPhaseFailed {
 		exec.wfStatus.Phase = v1alpha1.WorkflowStepPhaseRunning
 		exec.wfStatus.Reason = types.StatusReasonWait
-		if message != "" {
-			exec.wfStatus.Message = message
-		}
+		exec.setMessage(message)
 	}
 }
 
@@ -418,16 +738,33 @@ func (exec *executor) Fail(message string) {
 	exec.terminated = true
 	exec.wfStatus.Phase = v1alpha1.WorkflowStepPhaseFailed
 	exec.wfStatus.Reason = types.StatusReasonAction
-	if message != "" {
-		exec.wfStatus.Message = message
+	exec.setMessage(message)
+	if exec.runningOp != nil {
+		op := *exec.runningOp
+		exec.wfStatus.FailedOp = &op
 	}
 }
 
-// Message writes message to step status, note that the message will be overwritten by the next message.
+// FailWithReason fails the step like Fail with the reason instead of Action, e.g. the reason of the failed job.
+func (exec *executor) FailWithReason(reason, message string) {
+	exec.Fail(message)
+	exec.wfStatus.Reason = reason
+}
+
+// Message writes message to step status, the previous messages are kept in the message history of the step.
 func (exec *executor) Message(message string) {
-	if message != "" {
-		exec.wfStatus.Message = message
+	exec.setMessage(message)
+}
+
+func (exec *executor) setMessage(message string) {
+	if message == "" {
+		return
 	}
+	exec.wfStatus.Message = message
+	exec.wfStatus.MessageHistory = AppendMessageHistory(exec.wfStatus.MessageHistory, v1alpha1.StepMessage{
+		Message: message,
+		Time:    metav1.Now(),
+	})
 }
 
 func (exec *executor) Skip(message string) {
@@ -444,10 +781,30 @@ func (exec *executor) timeout(message string) {
 	exec.wfStatus.Message = message
 }
 
+func (exec *executor) outOfWindow(message string) {
+	exec.wait = true
+	exec.wfStatus.Phase = v1alpha1.WorkflowStepPhaseRunning
+	exec.wfStatus.Reason = types.StatusReasonExecuteWindow
+	exec.wfStatus.Message = message
+}
+
+func (exec *executor) conditionFailed(message string) {
+	exec.terminated = true
+	exec.wfStatus.Phase = v1alpha1.WorkflowStepPhaseFailed
+	exec.wfStatus.Reason = types.StatusReasonCondition
+	exec.wfStatus.Message = message
+}
+
 func (exec *executor) err(ctx wfContext.Context, wait bool, err error, reason string) {
 	exec.wait = wait
+	exec.stepErr = err
 	exec.wfStatus.Phase = v1alpha1.WorkflowStepPhaseFailed
 	exec.wfStatus.Message = err.Error()
+	if evalErr := wfCue.NewEvaluationError(exec.wfStatus.Type, err); evalErr != nil {
+		// the debug context records the full error while the status only keeps the truncated message
+		exec.stepErr = evalErr
+		exec.wfStatus.Message = evalErr.Message()
+	}
 	if exec.wfStatus.Reason == "" {
 		exec.wfStatus.Reason = reason
 		if reason != types.StatusReasonExecute {
@@ -495,17 +852,99 @@ func (exec *executor) Handle(ctx monitorContext.Context, wfCtx wfContext.Context
 	if !exist {
 		return errors.Errorf("handler not found")
 	}
-	return h(ctx, wfCtx, v, exec)
+	exec.runningOp = &v1alpha1.StepOp{Provider: provider, Op: do}
+	defer func() { exec.runningOp = nil }()
+	err := exec.handleOp(ctx, wfCtx, h, provider, do, v)
+	if err == nil || !ignoreError(v) || exec.terminated {
+		return err
+	}
+	return exec.recordIgnoredError(ctx, provider, do, v, err)
+}
+
+func (exec *executor) handleOp(ctx monitorContext.Context, wfCtx wfContext.Context, h types.Handler, provider string, do string, v *value.Value) error {
+	index := exec.opIndex
+	exec.opIndex++
+	if key := idempotencyKey(provider, do, v, exec.runUID, exec.wfStatus.Name, index); key != "" {
+		return exec.handleIdempotent(ctx, wfCtx, h, provider, do, v, key)
+	}
+	if !runOnce(provider, do, v) {
+		return exec.handle(ctx, wfCtx, h, provider, do, v)
+	}
+	if result := getOpMarker(wfCtx, exec.wfStatus.ID, index); result != "" {
+		return errors.WithMessage(fillOpResult(v, result), "fill the result of the completed op")
+	}
+	before := concreteFields(v)
+	if err := exec.handle(ctx, wfCtx, h, provider, do, v); err != nil {
+		return err
+	}
+	if exec.wait || exec.suspend || exec.terminated {
+		return nil
+	}
+	result, err := opResult(before, v)
+	if err != nil {
+		return err
+	}
+	setOpMarker(wfCtx, exec.wfStatus.ID, index, result)
+	return nil
+}
+
+// AuditTarget records the target of the running op in its audit record.
+func (exec *executor) AuditTarget(target types.AuditTarget) {
+	exec.auditTargets = append(exec.auditTargets, target)
+}
+
+// Watch records the object watched by the running op, the duplicated objects are recorded once.
+func (exec *executor) Watch(obj v1alpha1.WatchedObject) {
+	for _, w := range exec.watches {
+		if w == obj {
+			return
+		}
+	}
+	exec.watches = append(exec.watches, obj)
+}
+
+func (exec *executor) handle(ctx monitorContext.Context, wfCtx wfContext.Context, h types.Handler, provider string, do string, v *value.Value) error {
+	if exec.audit == nil {
+		return exec.call(ctx, wfCtx, h, provider, do, v)
+	}
+	exec.auditTargets = nil
+	start := time.Now()
+	err := exec.call(ctx, wfCtx, h, provider, do, v)
+	record := types.AuditRecord{
+		Timestamp:      start,
+		Step:           exec.wfStatus.Name,
+		Op:             opName(provider, do),
+		Targets:        exec.auditTargets,
+		Outcome:        types.AuditOutcomeSucceeded,
+		DurationMillis: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		record.Outcome = types.AuditOutcomeFailed
+	}
+	exec.audit(record)
+	return err
+}
+
+func (exec *executor) call(ctx monitorContext.Context, wfCtx wfContext.Context, h types.Handler, provider string, do string, v *value.Value) error {
+	if !exec.enableTrace {
+		return h(ctx, wfCtx, v, exec)
+	}
+	trace := types.OpTrace{Op: opName(provider, do), Timestamp: time.Now()}
+	trace.Input, _ = v.String()
+	err := h(ctx, wfCtx, v, exec)
+	if err != nil {
+		trace.Error = err.Error()
+	} else {
+		trace.Output, _ = v.String()
+	}
+	exec.trace = append(exec.trace, trace)
+	return err
 }
 
 func (exec *executor) doSteps(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value) error {
 	do := OpTpy(v)
 	if do != "" && do != "steps" {
-		provider := opProvider(v)
-		if err := exec.Handle(ctx, wfCtx, provider, do, v); err != nil {
-			return errors.WithMessagef(err, "run step(provider=%s,do=%s)", provider, do)
-		}
-		return nil
+		return exec.handleStep(ctx, wfCtx, v)
 	}
 	return v.StepByFields(func(fieldName string, in *value.Value) (bool, error) {
 		if in.CueValue().IncompleteKind() == cue.BottomKind {
@@ -513,6 +952,9 @@ func (exec *executor) doSteps(ctx monitorContext.Context, wfCtx wfContext.Contex
 			if err != nil {
 				errInfo = "value is _|_"
 			}
+			if cueErr := in.CueValue().Err(); cueErr != nil {
+				return true, errors.WithMessage(cueErr, errInfo+"(bottom kind)")
+			}
 			return true, errors.New(errInfo + "(bottom kind)")
 		}
 		if retErr := in.CueValue().Err(); retErr != nil {
@@ -540,11 +982,8 @@ func (exec *executor) doSteps(ctx monitorContext.Context, wfCtx wfContext.Contex
 			if err := exec.doSteps(ctx, wfCtx, in); err != nil {
 				return false, err
 			}
-		} else {
-			provider := opProvider(in)
-			if err := exec.Handle(ctx, wfCtx, provider, do, in); err != nil {
-				return false, errors.WithMessagef(err, "run step(provider=%s,do=%s)", provider, do)
-			}
+		} else if err := exec.handleStep(ctx, wfCtx, in); err != nil {
+			return false, err
 		}
 
 		if exec.suspend || exec.terminated || exec.wait {
@@ -554,6 +993,22 @@ func (exec *executor) doSteps(ctx monitorContext.Context, wfCtx wfContext.Contex
 	})
 }
 
+// handleStep handles the op and attributes its error to it, the error is prefixed by the op name like `kube/apply: `
+// and the op is recorded as the failed op of the step.
+func (exec *executor) handleStep(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value) error {
+	provider, do := opProvider(v), OpTpy(v)
+	if err := exec.Handle(ctx, wfCtx, provider, do, v); err != nil {
+		exec.wfStatus.FailedOp = &v1alpha1.StepOp{Provider: provider, Op: do}
+		return errors.WithMessage(err, opName(provider, do))
+	}
+	return nil
+}
+
+// opName is the name of the op identifying it in the errors, the debug traces and the audit records
+func opName(provider, do string) string {
+	return provider + "/" + do
+}
+
 func isStepList(fieldName string) bool {
 	if fieldName == "#up" {
 		return true
@@ -628,7 +1083,16 @@ func CheckPending(ctx wfContext.Context, step v1alpha1.WorkflowStep, id string,
 	}
 	for _, input := range step.Inputs {
 		pStatus.Message = fmt.Sprintf("Pending on Input: %s", input.From)
-		if _, err := ctx.GetVar(strings.Split(input.From, ".")...); err != nil {
+		if _, err := ctx.GetVar(value.SplitPath(input.From)...); err != nil {
+			if input.Optional || input.Default != nil {
+				// the optional input only waits for the step producing it
+				if producer := hooks.ProducerOf(ctx, input.From); producer != "" {
+					if status, ok := stepStatus[producer]; !ok || !types.IsStepFinish(status.Phase, status.Reason) {
+						return true, pStatus
+					}
+				}
+				continue
+			}
 			if basicValue == nil {
 				return true, pStatus
 			}
@@ -20,23 +20,37 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/yaml"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
 	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
+	wfCue "github.com/kubevela/workflow/pkg/cue"
+	"github.com/kubevela/workflow/pkg/cue/model"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
 	"github.com/kubevela/workflow/pkg/cue/process"
 	"github.com/kubevela/workflow/pkg/providers"
+	"github.com/kubevela/workflow/pkg/providers/kube"
+	"github.com/kubevela/workflow/pkg/providers/util"
+	"github.com/kubevela/workflow/pkg/providers/workspace"
+	"github.com/kubevela/workflow/pkg/tasks/template"
 	"github.com/kubevela/workflow/pkg/types"
 )
 
@@ -195,6 +209,13 @@ close({
 		"error": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
 			return errors.New("mock error")
 		},
+		"forbidden": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			err := kerrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "web", errors.New("no permission"))
+			return types.NewClientError(err, "get", "Deployment web", "default")
+		},
+		"throttled": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			return types.NewClientError(kerrors.NewTooManyRequests("slow down", 1), "patch", "Deployment web", "default")
+		},
 	})
 	pCtx := process.NewContext(process.ContextData{
 		Name:      "app",
@@ -254,6 +275,18 @@ close({
 				Type: "error",
 			},
 		},
+		{
+			WorkflowStepBase: v1alpha1.WorkflowStepBase{
+				Name: "forbidden",
+				Type: "forbidden",
+			},
+		},
+		{
+			WorkflowStepBase: v1alpha1.WorkflowStepBase{
+				Name: "throttled",
+				Type: "throttled",
+			},
+		},
 	}
 	for _, step := range steps {
 		gen, err := tasksLoader.GetTaskGenerator(context.Background(), step.Type)
@@ -286,6 +319,17 @@ close({
 			r.Equal(operation.FailedAfterRetries, true)
 			r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
 			r.Equal(status.Reason, types.StatusReasonFailedAfterRetries)
+		case "forbidden":
+			r.NoError(err)
+			r.Equal(operation.Waiting, false)
+			r.Equal(operation.Terminated, true)
+			r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
+			r.Equal(status.Reason, string(types.ClientErrorForbidden))
+			r.Contains(status.Message, "Forbidden: failed to get Deployment web in namespace default")
+		case "throttled":
+			r.Equal(operation.Waiting, true)
+			r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
+			r.Equal(status.Reason, types.StatusReasonExecute)
 		default:
 			r.Equal(operation.Waiting, true)
 			r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
@@ -539,6 +583,19 @@ func TestSkip(t *testing.T) {
 	r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseSkipped)
 	r.Equal(status.Reason, types.StatusReasonSkip)
 	r.Equal(operations.Skip, true)
+
+	status, operations, err = runner.Run(wfCtx, &types.TaskRunOptions{
+		PreCheckHooks: []types.TaskPreCheckHook{
+			func(step v1alpha1.WorkflowStep, options *types.PreCheckOptions) (*types.PreCheckResult, error) {
+				return &types.PreCheckResult{Failed: true, Message: "if \"x\" could not be evaluated"}, nil
+			},
+		},
+	})
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStepPhaseFailed, status.Phase)
+	r.Equal(types.StatusReasonCondition, status.Reason)
+	r.Equal(`if "x" could not be evaluated`, status.Message)
+	r.True(operations.Terminated)
 }
 
 func TestTimeout(t *testing.T) {
@@ -577,6 +634,248 @@ func TestTimeout(t *testing.T) {
 	r.Equal(status.Reason, types.StatusReasonTimeout)
 }
 
+func TestDebugTrace(t *testing.T) {
+	discover := providers.NewProviders()
+	discover.Register("test", map[string]types.Handler{
+		"ok": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			return nil
+		},
+		"executeFailed": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			return errors.New("execute error")
+		},
+	})
+	pCtx := process.NewContext(process.ContextData{
+		Name:      "app",
+		Namespace: "default",
+	})
+	tasksLoader := NewTaskLoader(mockLoadTemplate, nil, discover, 0, pCtx)
+	testCases := map[string]struct {
+		stepType   string
+		unselected bool
+		trace      types.OpTrace
+		err        string
+		failedOp   *v1alpha1.StepOp
+	}{
+		"succeeded": {
+			stepType: "ok",
+			trace:    types.OpTrace{Op: "test/ok"},
+		},
+		"not selected": {
+			stepType:   "ok",
+			unselected: true,
+		},
+		"failed": {
+			stepType: "executeFailed",
+			trace:    types.OpTrace{Op: "test/executeFailed", Error: "execute error"},
+			err:      "test/executeFailed: execute error",
+			failedOp: &v1alpha1.StepOp{Provider: "test", Op: "executeFailed"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			gen, err := tasksLoader.GetTaskGenerator(context.Background(), tc.stepType)
+			r.NoError(err)
+			runner, err := gen(v1alpha1.WorkflowStep{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: name,
+					Type: tc.stepType,
+				},
+			}, &types.TaskGeneratorOptions{})
+			r.NoError(err)
+			var (
+				debugStep  string
+				debugErr   error
+				debugTrace []types.OpTrace
+			)
+			status, _, err := runner.Run(newWorkflowContextForTest(t), &types.TaskRunOptions{
+				Debug: func(step string, v *value.Value, err error, trace []types.OpTrace) error {
+					debugStep, debugErr, debugTrace = step, err, trace
+					return nil
+				},
+				DebugStep: func(step string) bool {
+					return !tc.unselected
+				},
+			})
+			r.NoError(err)
+			r.Equal(tc.failedOp, status.FailedOp)
+			r.Equal(name, debugStep)
+			if tc.unselected {
+				r.Empty(debugTrace)
+				return
+			}
+			if tc.err != "" {
+				r.EqualError(debugErr, tc.err)
+			} else {
+				r.NoError(debugErr)
+			}
+			r.Equal(1, len(debugTrace))
+			r.Equal(tc.trace.Op, debugTrace[0].Op)
+			r.Equal(tc.trace.Error, debugTrace[0].Error)
+			r.Contains(debugTrace[0].Input, `"`+tc.stepType+`"`)
+			r.False(debugTrace[0].Timestamp.IsZero())
+			if tc.err == "" {
+				r.NotEmpty(debugTrace[0].Output)
+			}
+		})
+	}
+}
+
+func TestWatches(t *testing.T) {
+	watched := v1alpha1.WatchedObject{APIVersion: "batch/v1", Kind: "Job", Namespace: "default", Name: "build"}
+	discover := providers.NewProviders()
+	discover.Register("test", map[string]types.Handler{
+		"ok": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			types.RecordWatch(act, watched)
+			return nil
+		},
+		"wait": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			types.RecordWatch(act, watched)
+			types.RecordWatch(act, watched)
+			act.Wait("waiting for job build")
+			return nil
+		},
+	})
+	tasksLoader := NewTaskLoader(mockLoadTemplate, nil, discover, 0, process.NewContext(process.ContextData{
+		Name:      "app",
+		Namespace: "default",
+	}))
+	for stepType, expected := range map[string][]v1alpha1.WatchedObject{
+		"ok":   nil,
+		"wait": {watched},
+	} {
+		t.Run(stepType, func(t *testing.T) {
+			r := require.New(t)
+			gen, err := tasksLoader.GetTaskGenerator(context.Background(), stepType)
+			r.NoError(err)
+			runner, err := gen(v1alpha1.WorkflowStep{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: stepType, Type: stepType},
+			}, &types.TaskGeneratorOptions{})
+			r.NoError(err)
+			status, _, err := runner.Run(newWorkflowContextForTest(t), &types.TaskRunOptions{})
+			r.NoError(err)
+			r.Equal(expected, status.Watches)
+		})
+	}
+}
+
+func TestEvaluationError(t *testing.T) {
+	r := require.New(t)
+	discover := providers.NewProviders()
+	discover.Register("test", map[string]types.Handler{
+		"ok": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			return nil
+		},
+	})
+	pCtx := process.NewContext(process.ContextData{
+		Name:      "app",
+		Namespace: "default",
+	})
+	tasksLoader := NewTaskLoader(mockLoadTemplate, nil, discover, 0, pCtx)
+	gen, err := tasksLoader.GetTaskGenerator(context.Background(), "conflict")
+	r.NoError(err)
+	runner, err := gen(v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name:       "conflict",
+			Type:       "conflict",
+			Properties: &runtime.RawExtension{Raw: []byte(`{"replicas":"3"}`)},
+		},
+	}, &types.TaskGeneratorOptions{})
+	r.NoError(err)
+	var debugErr error
+	status, _, err := runner.Run(newWorkflowContextForTest(t), &types.TaskRunOptions{
+		Debug: func(step string, v *value.Value, err error, trace []types.OpTrace) error {
+			debugErr = err
+			return nil
+		},
+	})
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStepPhaseFailed, status.Phase)
+	r.Contains(status.Message, "failed to evaluate definition conflict:\n- parameter.replicas: conflicting values int and \"3\" (mismatched types int and string) (at ")
+
+	evalErr := &wfCue.EvaluationError{}
+	r.True(errors.As(debugErr, &evalErr))
+	r.Equal("conflict", evalErr.Definition)
+	r.Equal("parameter.replicas", evalErr.Issues[0].Path)
+	r.Equal([]string{"int", `"3"`}, evalErr.Issues[0].ConflictingValues)
+	r.NotEmpty(evalErr.Issues[0].Positions)
+
+	defer func(n int) { wfCue.MaxEvaluationErrorMessageLength = n }(wfCue.MaxEvaluationErrorMessageLength)
+	wfCue.MaxEvaluationErrorMessageLength = 10
+	r.Equal("failed to ...(truncated)", evalErr.Message())
+	r.True(strings.HasPrefix(evalErr.Error(), "failed to evaluate definition conflict:"))
+}
+
+func TestPatchInputs(t *testing.T) {
+	r := require.New(t)
+	discover := providers.NewProviders()
+	discover.Register("test", map[string]types.Handler{
+		"ok": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			return nil
+		},
+	})
+	pCtx := process.NewContext(process.ContextData{
+		Name:      "app",
+		Namespace: "default",
+	})
+	tasksLoader := NewTaskLoader(mockLoadTemplate, nil, discover, 0, pCtx)
+	gen, err := tasksLoader.GetTaskGenerator(context.Background(), "conflict")
+	r.NoError(err)
+	wfCtx := newWorkflowContextForTest(t)
+	for name, patch := range map[string]string{
+		"merge": `{replicas: 3}`,
+		"json":  `[{op: "remove", path: "/not-found"}]`,
+	} {
+		v, err := value.NewValue(patch, nil, "")
+		r.NoError(err)
+		r.NoError(wfCtx.SetVar(v, name))
+	}
+	run := func(from string) v1alpha1.StepStatus {
+		runner, err := gen(v1alpha1.WorkflowStep{
+			WorkflowStepBase: v1alpha1.WorkflowStepBase{
+				Name:       "patch-" + from,
+				Type:       "conflict",
+				Properties: &runtime.RawExtension{Raw: []byte(`{"replicas":"3"}`)},
+				Inputs:     v1alpha1.StepInputs{{From: from, Patch: true}},
+			},
+		}, &types.TaskGeneratorOptions{ID: "patch-" + from})
+		r.NoError(err)
+		status, _, err := runner.Run(wfCtx, &types.TaskRunOptions{})
+		r.NoError(err)
+		return status
+	}
+	// the conflicting property is patched before the template is rendered
+	status := run("merge")
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, status.Phase)
+
+	status = run("json")
+	r.Equal(v1alpha1.WorkflowStepPhaseFailed, status.Phase)
+	r.Equal(types.StatusReasonInput, status.Reason)
+	r.Contains(status.Message, "apply the op 0 of the json patch")
+}
+
+func TestMissingImport(t *testing.T) {
+	r := require.New(t)
+	pCtx := process.NewContext(process.ContextData{
+		Name:      "app",
+		Namespace: "default",
+	})
+	tasksLoader := NewTaskLoader(mockLoadTemplate, nil, providers.NewProviders(), 0, pCtx)
+	gen, err := tasksLoader.GetTaskGenerator(context.Background(), "missing-import")
+	r.NoError(err)
+	runner, err := gen(v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name: "step",
+			Type: "missing-import",
+		},
+	}, &types.TaskGeneratorOptions{})
+	r.NoError(err)
+	status, _, err := runner.Run(newWorkflowContextForTest(t), &types.TaskRunOptions{})
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStepPhaseFailed, status.Phase)
+	r.Equal(`package "mycorp.com/helpers" imported by definition missing-import is not found`, status.Message)
+}
+
 func TestValidateIfValue(t *testing.T) {
 	ctx := newWorkflowContextForTest(t)
 	pCtx := process.NewContext(process.ContextData{
@@ -748,6 +1047,204 @@ func TestValidateIfValue(t *testing.T) {
 	}
 }
 
+func TestEvaluateIfValue(t *testing.T) {
+	ctx := newWorkflowContextForTest(t)
+	pCtx := process.NewContext(process.ContextData{
+		Name:      "app",
+		Namespace: "default",
+	})
+	logCtx := monitorContext.NewTraceContext(context.Background(), "test-app")
+	basicVal, basicTemplate, err := MakeBasicValue(logCtx, ctx, nil, "test-step", "id", `key: "value"`, pCtx)
+	r := require.New(t)
+	r.NoError(err)
+	options := &types.PreCheckOptions{BasicTemplate: basicTemplate, BasicValue: basicVal}
+	status := map[string]v1alpha1.StepStatus{
+		"build": {Phase: v1alpha1.WorkflowStepPhaseSucceeded},
+	}
+
+	step := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+		If: `status.build.failed || context.name != "app"`,
+	}}
+	check, message, err := EvaluateIfValue(ctx, step, status, options)
+	r.NoError(err)
+	r.False(check)
+	r.Equal(`if "status.build.failed || context.name != \"app\"" evaluated to false (status.build.failed=false, context.name="app")`, message)
+
+	step.If = `parameter.key == "value" && status.build.succeeded`
+	check, message, err = EvaluateIfValue(ctx, step, status, options)
+	r.NoError(err)
+	r.True(check)
+	r.Equal(`if "parameter.key == \"value\" && status.build.succeeded" evaluated to true (parameter.key="value", status.build.succeeded=true)`, message)
+
+	step.If = `inputs.changed`
+	check, message, err = EvaluateIfValue(ctx, step, status, options)
+	r.Error(err)
+	r.False(check)
+	r.Equal("condition could not be evaluated: field inputs.changed is incomplete", message)
+
+	step.If = `status.build.phase + 1`
+	check, message, err = EvaluateIfValue(ctx, step, status, options)
+	r.Error(err)
+	r.False(check)
+	r.Equal("", message)
+}
+
+func TestEvaluateSubStepIfValue(t *testing.T) {
+	r := require.New(t)
+	ctx := newWorkflowContextForTest(t)
+	setVar := func(v string, path ...string) {
+		val, err := value.NewValue(v, nil, "")
+		r.NoError(err)
+		r.NoError(ctx.SetVar(val, path...))
+	}
+	setVar(`"us-west"`, "region")
+	setVar(`{replicas: 3, image: "web:v2"}`, "deploy", "plan", "result")
+	setVar(`"canary"`, "strategy")
+	setVar(`null`, "skipped-result")
+	steps := []v1alpha1.WorkflowStep{{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name:   "deploy",
+			Type:   "step-group",
+			Inputs: v1alpha1.StepInputs{{From: "region"}},
+		},
+		SubSteps: []v1alpha1.WorkflowStepBase{
+			{Name: "plan", Outputs: v1alpha1.StepOutputs{{Name: "result", ValueFrom: "output"}}},
+			{Name: "choose", Outputs: v1alpha1.StepOutputs{{Name: "strategy", ValueFrom: "output"}}},
+			{Name: "probe", Outputs: v1alpha1.StepOutputs{{Name: "skipped-result", ValueFrom: "output"}}},
+			{Name: "rollout"},
+		},
+	}, {
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name:    "verify",
+			Outputs: v1alpha1.StepOutputs{{Name: "result", ValueFrom: "output"}},
+		},
+	}}
+	status := map[string]v1alpha1.StepStatus{
+		"deploy": {Phase: v1alpha1.WorkflowStepPhaseRunning},
+		"plan":   {Phase: v1alpha1.WorkflowStepPhaseSucceeded},
+		"choose": {Phase: v1alpha1.WorkflowStepPhaseSucceeded},
+		"probe":  {Phase: v1alpha1.WorkflowStepPhaseSkipped},
+	}
+	pCtx := process.NewContext(process.ContextData{Name: "app", Namespace: "default"})
+	logCtx := monitorContext.NewTraceContext(context.Background(), "test-app")
+	basicVal, basicTemplate, err := MakeBasicValue(logCtx, ctx, nil, "rollout", "id", "", pCtx)
+	r.NoError(err)
+	options := &types.PreCheckOptions{
+		BasicTemplate: basicTemplate,
+		BasicValue:    basicVal,
+		ParentStep:    &steps[0],
+		Steps:         steps,
+	}
+	step := v1alpha1.WorkflowStep{WorkflowStepBase: steps[0].SubSteps[3]}
+
+	testCases := map[string]struct {
+		expr    string
+		check   bool
+		message string
+		err     bool
+	}{
+		"group inputs": {
+			expr:    `inputs.region == "us-west"`,
+			check:   true,
+			message: `if "inputs.region == \"us-west\"" evaluated to true (inputs.region="us-west")`,
+		},
+		"sibling outputs": {
+			expr:    `steps.deploy.plan.output.result.replicas > 1 && steps.deploy.choose.output.strategy == "canary"`,
+			check:   true,
+			message: `if "steps.deploy.plan.output.result.replicas > 1 && steps.deploy.choose.output.strategy == \"canary\"" evaluated to true (steps.deploy.plan.output.result.replicas=3, steps.deploy.choose.output.strategy="canary")`,
+		},
+		"context": {
+			expr:    `context.name == "app" && steps.deploy.plan.output.result.image == "web:v2"`,
+			check:   true,
+			message: `if "context.name == \"app\" && steps.deploy.plan.output.result.image == \"web:v2\"" evaluated to true (context.name="app", steps.deploy.plan.output.result.image="web:v2")`,
+		},
+		"skipped sibling": {
+			expr:    `steps.deploy.probe.output."skipped-result" == _|_`,
+			check:   true,
+			message: `if "steps.deploy.probe.output.\"skipped-result\" == _|_" evaluated to true`,
+		},
+		"unfinished step": {
+			expr:    `steps.verify.output.result.replicas > 1`,
+			message: "condition could not be evaluated: field steps.verify.output.result.replicas is incomplete",
+			err:     true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			step.If = tc.expr
+			check, message, err := EvaluateIfValue(ctx, step, status, options)
+			if tc.err {
+				r.Error(err)
+			} else {
+				r.NoError(err)
+			}
+			r.Equal(tc.check, check)
+			r.Equal(tc.message, message)
+		})
+	}
+
+	// the inputs of the step group are not referable without the parent step
+	step.If = `inputs.region == "us-west"`
+	_, message, err := EvaluateIfValue(ctx, step, status, &types.PreCheckOptions{BasicTemplate: basicTemplate, BasicValue: basicVal})
+	r.Error(err)
+	r.Equal("condition could not be evaluated: field inputs.region is incomplete", message)
+}
+
+func TestEvaluateIfValueWithRunMetadata(t *testing.T) {
+	r := require.New(t)
+	ctx := newWorkflowContextForTest(t)
+	vars, err := value.NewValue(`suffix: "abcde"`, nil, "")
+	r.NoError(err)
+	r.NoError(ctx.SetVar(vars, types.ContextKeyVars))
+	pCtx := process.NewContext(process.ContextData{
+		Name:      "app",
+		Namespace: "default",
+		Data: map[string]interface{}{
+			model.ContextLabels:      map[string]string{"env": "prod-us"},
+			model.ContextAnnotations: map[string]string{"app.oam.dev/owner": "team-a"},
+			model.ContextStartTime:   "2022-10-01T08:00:00Z",
+		},
+	})
+	logCtx := monitorContext.NewTraceContext(context.Background(), "test-app")
+	basicVal, basicTemplate, err := MakeBasicValue(logCtx, ctx, nil, "test-step", "id", "", pCtx)
+	r.NoError(err)
+	options := &types.PreCheckOptions{BasicTemplate: basicTemplate, BasicValue: basicVal}
+
+	step := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+		If: `context.annotations["app.oam.dev/owner"] == "team-a" && context.var.suffix == "abcde"`,
+	}}
+	check, _, err := EvaluateIfValue(ctx, step, nil, options)
+	r.NoError(err)
+	r.True(check)
+
+	// the vars are read-only in the steps
+	v, err := value.NewValue(basicTemplate+"\ncontext: var: suffix: \"changed\"", nil, "")
+	r.NoError(err)
+	r.Error(v.Error())
+
+	step.If = `import "strings"
+strings.HasPrefix(context.labels.env, "prod")`
+	check, message, err := EvaluateIfValue(ctx, step, nil, options)
+	r.NoError(err)
+	r.True(check)
+	r.Equal(`if "strings.HasPrefix(context.labels.env, \"prod\")" evaluated to true (context.labels.env="prod-us")`, message)
+
+	step.If = `import (
+	"strings"
+	"time"
+)
+strings.HasPrefix(context.labels.env, "prod") && time.Parse(time.RFC3339, context.startTime) < "2023-01-01T00:00:00Z"`
+	check, _, err = EvaluateIfValue(ctx, step, nil, options)
+	r.NoError(err)
+	r.True(check)
+
+	step.If = `import "time"
+time.Unix(context.startTime, 0) != ""`
+	_, _, err = EvaluateIfValue(ctx, step, nil, options)
+	r.Error(err)
+}
+
 func newWorkflowContextForTest(t *testing.T) wfContext.Context {
 	r := require.New(t)
 	cm := corev1.ConfigMap{}
@@ -809,6 +1306,25 @@ output: xx
 		return fmt.Sprintf(templ, "ok"), nil
 	case "error":
 		return fmt.Sprintf(templ, "error"), nil
+	case "forbidden":
+		return fmt.Sprintf(templ, "forbidden"), nil
+	case "throttled":
+		return fmt.Sprintf(templ, "throttled"), nil
+	case "conflict":
+		return `
+parameter: replicas: int
+process: {
+	#provider: "test"
+	#do: "ok"
+	replicas: parameter.replicas
+}
+`, nil
+	case "missing-import":
+		return `
+import "mycorp.com/helpers"
+
+name: helpers.#Name
+`, nil
 	case "steps":
 		return `
 #do: "steps"
@@ -831,3 +1347,239 @@ metadata:
   name: app-v1
 `
 )
+
+func TestValuesTemplate(t *testing.T) {
+	r := require.New(t)
+	pCtx := process.NewContext(process.ContextData{Name: "app", Namespace: "default"})
+	discover := providers.NewProviders()
+	util.Install(discover, nil, "default", pCtx)
+	workspace.Install(discover, nil, types.WorkflowMeta{}, nil)
+	tasksLoader := NewTaskLoader(template.NewWorkflowStepTemplateLoader(nil).LoadTemplate, nil, discover, 0, pCtx)
+	wfCtx := newWorkflowContextForTest(t)
+	v, err := value.NewValue(`{name: string, port: 80}`, nil, "")
+	r.NoError(err)
+	r.NoError(wfCtx.SetVar(v, "service"))
+
+	step := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+		Name:       "values",
+		Type:       "values",
+		Properties: &runtime.RawExtension{Raw: []byte(`{"replicas":1}`)},
+		Inputs:     v1alpha1.StepInputs{{From: "service", ParameterKey: "service"}},
+		Outputs:    v1alpha1.StepOutputs{{Name: "values", ValueFrom: "values"}},
+	}}
+	gen, err := tasksLoader.GetTaskGenerator(context.Background(), step.Type)
+	r.NoError(err)
+	run, err := gen(step, &types.TaskGeneratorOptions{})
+	r.NoError(err)
+	status, _, err := run.Run(wfCtx, &types.TaskRunOptions{})
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStepPhaseFailed, status.Phase)
+	r.Equal("incomplete values: service.name", status.Message)
+}
+
+func TestReadObjectTemplate(t *testing.T) {
+	cli := fake.NewClientBuilder().WithObjects(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "staging", Labels: map[string]string{"version": "v1"}},
+	}).Build()
+	pCtx := process.NewContext(process.ContextData{Name: "app", Namespace: "staging"})
+	discover := providers.NewProviders()
+	kube.Install(discover, cli, nil, nil, nil, nil)
+	util.Install(discover, nil, "default", pCtx)
+	workspace.Install(discover, nil, types.WorkflowMeta{}, nil)
+	tasksLoader := NewTaskLoader(template.NewWorkflowStepTemplateLoader(nil).LoadTemplate, nil, discover, 0, pCtx)
+
+	testCases := map[string]struct {
+		properties string
+		phase      v1alpha1.WorkflowStepPhase
+		message    string
+		output     string
+	}{
+		"read the object": {
+			properties: `{"apiVersion":"apps/v1","kind":"Deployment","name":"app"}`,
+			phase:      v1alpha1.WorkflowStepPhaseSucceeded,
+			output:     `"app"`,
+		},
+		"read the path": {
+			properties: `{"apiVersion":"apps/v1","kind":"Deployment","name":"app","path":"metadata.labels.version"}`,
+			phase:      v1alpha1.WorkflowStepPhaseSucceeded,
+			output:     `"v1"`,
+		},
+		"path not found": {
+			properties: `{"apiVersion":"apps/v1","kind":"Deployment","name":"app","path":"metadata.labels.missing"}`,
+			phase:      v1alpha1.WorkflowStepPhaseFailed,
+			message:    "path metadata.labels.missing is not found in Deployment app",
+		},
+		"object not found": {
+			properties: `{"apiVersion":"apps/v1","kind":"Deployment","name":"missing"}`,
+			phase:      v1alpha1.WorkflowStepPhaseFailed,
+			message:    `deployments.apps "missing" not found`,
+		},
+		"allow missing": {
+			properties: `{"apiVersion":"apps/v1","kind":"Deployment","name":"missing","allowMissing":true}`,
+			phase:      v1alpha1.WorkflowStepPhaseSucceeded,
+			output:     `{}`,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			wfCtx := newWorkflowContextForTest(t)
+			step := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+				Name:       "read",
+				Type:       "read-object",
+				Properties: &runtime.RawExtension{Raw: []byte(tc.properties)},
+				Outputs:    v1alpha1.StepOutputs{{Name: "object", ValueFrom: "output"}},
+			}}
+			gen, err := tasksLoader.GetTaskGenerator(context.Background(), step.Type)
+			r.NoError(err)
+			run, err := gen(step, &types.TaskGeneratorOptions{})
+			r.NoError(err)
+			status, _, err := run.Run(wfCtx, &types.TaskRunOptions{})
+			r.NoError(err)
+			r.Equal(tc.phase, status.Phase)
+			r.Equal(tc.message, status.Message)
+			if tc.output == "" {
+				return
+			}
+			output, err := wfCtx.GetVar("object")
+			r.NoError(err)
+			if name == "read the object" {
+				output, err = output.LookupValue("metadata", "name")
+				r.NoError(err)
+			}
+			b, err := output.CueValue().MarshalJSON()
+			r.NoError(err)
+			r.Equal(tc.output, string(b))
+		})
+	}
+}
+
+func TestRunJobTemplate(t *testing.T) {
+	job := func(name string, conds ...batchv1.JobCondition) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "staging"},
+			Status:     batchv1.JobStatus{Conditions: conds},
+		}
+	}
+	cli := fake.NewClientBuilder().WithObjects(
+		job("test", batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}),
+		job("migrate", batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue,
+			Reason: "DeadlineExceeded", Message: "Job was active longer than specified deadline"}),
+	).Build()
+	pCtx := process.NewContext(process.ContextData{Name: "app", Namespace: "staging"})
+	discover := providers.NewProviders()
+	kube.Install(discover, cli, nil, nil, nil, nil)
+	workspace.Install(discover, nil, types.WorkflowMeta{}, nil)
+	tasksLoader := NewTaskLoader(template.NewWorkflowStepTemplateLoader(nil).LoadTemplate, nil, discover, 0, pCtx)
+
+	testCases := map[string]struct {
+		properties string
+		phase      v1alpha1.WorkflowStepPhase
+		reason     string
+		message    string
+		failedOp   *v1alpha1.StepOp
+		output     string
+	}{
+		"apply the job": {
+			properties: `{"job":{"metadata":{"name":"seed"},"spec":{"template":{"spec":{"restartPolicy":"Never","containers":[{"name":"seed","image":"busybox"}]}}}}}`,
+			phase:      v1alpha1.WorkflowStepPhaseRunning,
+			reason:     types.StatusReasonWait,
+			message:    "waiting for job seed to complete: 0 active, 0 succeeded, 0 failed",
+		},
+		"completed job": {
+			properties: `{"name":"test"}`,
+			phase:      v1alpha1.WorkflowStepPhaseSucceeded,
+			output:     `{"phase":"Complete"}`,
+		},
+		"failed job": {
+			properties: `{"name":"migrate"}`,
+			phase:      v1alpha1.WorkflowStepPhaseFailed,
+			reason:     "DeadlineExceeded",
+			message:    "job migrate failed with DeadlineExceeded: Job was active longer than specified deadline",
+			failedOp:   &v1alpha1.StepOp{Provider: "kube", Op: "wait-job"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			wfCtx := newWorkflowContextForTest(t)
+			step := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+				Name:       "job",
+				Type:       "run-job",
+				Properties: &runtime.RawExtension{Raw: []byte(tc.properties)},
+				Outputs:    v1alpha1.StepOutputs{{Name: "result", ValueFrom: "output"}},
+			}}
+			gen, err := tasksLoader.GetTaskGenerator(context.Background(), step.Type)
+			r.NoError(err)
+			run, err := gen(step, &types.TaskGeneratorOptions{})
+			r.NoError(err)
+			status, _, err := run.Run(wfCtx, &types.TaskRunOptions{})
+			r.NoError(err)
+			r.Equal(tc.phase, status.Phase)
+			r.Equal(tc.reason, status.Reason)
+			r.Equal(tc.message, status.Message)
+			r.Equal(tc.failedOp, status.FailedOp)
+			if tc.output == "" {
+				return
+			}
+			output, err := wfCtx.GetVar("result")
+			r.NoError(err)
+			b, err := output.CueValue().MarshalJSON()
+			r.NoError(err)
+			r.Equal(tc.output, string(b))
+		})
+	}
+	applied := &batchv1.Job{}
+	require.NoError(t, cli.Get(context.Background(), client.ObjectKey{Namespace: "staging", Name: "seed"}, applied))
+}
+
+func TestAppendMessageHistory(t *testing.T) {
+	now := time.Now()
+	msg := func(message string, seconds int) v1alpha1.StepMessage {
+		return v1alpha1.StepMessage{Message: message, Time: metav1.NewTime(now.Add(time.Duration(seconds) * time.Second))}
+	}
+	testCases := map[string]struct {
+		history  []v1alpha1.StepMessage
+		messages []v1alpha1.StepMessage
+		max      int
+		expected []v1alpha1.StepMessage
+	}{
+		"append": {
+			history:  []v1alpha1.StepMessage{msg("waiting for 2/5 replicas", 0)},
+			messages: []v1alpha1.StepMessage{msg("waiting for 3/5 replicas", 1)},
+			max:      10,
+			expected: []v1alpha1.StepMessage{msg("waiting for 2/5 replicas", 0), msg("waiting for 3/5 replicas", 1)},
+		},
+		"identical messages": {
+			history:  []v1alpha1.StepMessage{msg("waiting for 2/5 replicas", 0)},
+			messages: []v1alpha1.StepMessage{msg("waiting for 2/5 replicas", 1), msg("waiting for 2/5 replicas", 2)},
+			max:      10,
+			expected: []v1alpha1.StepMessage{msg("waiting for 2/5 replicas", 0)},
+		},
+		"older messages": {
+			history:  []v1alpha1.StepMessage{msg("a", 0), msg("b", 2)},
+			messages: []v1alpha1.StepMessage{msg("a", 0), msg("b", 2), msg("c", 3)},
+			max:      10,
+			expected: []v1alpha1.StepMessage{msg("a", 0), msg("b", 2), msg("c", 3)},
+		},
+		"bounded": {
+			history:  []v1alpha1.StepMessage{msg("a", 0), msg("b", 1)},
+			messages: []v1alpha1.StepMessage{msg("c", 2), msg("d", 3)},
+			max:      3,
+			expected: []v1alpha1.StepMessage{msg("b", 1), msg("c", 2), msg("d", 3)},
+		},
+		"disabled": {
+			history:  []v1alpha1.StepMessage{msg("a", 0)},
+			messages: []v1alpha1.StepMessage{msg("b", 1)},
+			max:      0,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			defer func(max int) { types.MaxStepMessageHistory = max }(types.MaxStepMessageHistory)
+			types.MaxStepMessageHistory = tc.max
+			r.Equal(tc.expected, AppendMessageHistory(tc.history, tc.messages...))
+		})
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ReasonInvalidBuiltinOverride is the reason of the event on the override ConfigMap whose override is rejected
+	ReasonInvalidBuiltinOverride = "InvalidBuiltinOverride"
+)
+
+var (
+	// BuiltinOverridesConfigMap is the ConfigMap(namespace/name) whose keys override the builtin templates of the
+	// same names, the namespace is vela-system if not specified, it's disabled if empty
+	BuiltinOverridesConfigMap string
+
+	builtinOverrides = &overrideStore{}
+)
+
+// overrideStore keeps the overrides of the builtin templates, the overrides are replaced as a whole on reload
+type overrideStore struct {
+	mutex     sync.RWMutex
+	templates map[string]string
+}
+
+func (s *overrideStore) get(name string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	templ, ok := s.templates[name]
+	return templ, ok
+}
+
+func (s *overrideStore) set(templates map[string]string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.templates = templates
+}
+
+// builtinTemplateNames returns the names of the builtin templates
+func builtinTemplateNames() (map[string]bool, error) {
+	files, err := templateFS.ReadDir(templateDir)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(files))
+	for _, file := range files {
+		names[strings.TrimSuffix(file.Name(), ".cue")] = true
+	}
+	return names, nil
+}
+
+// loadBuiltinOverrides replaces the overrides with the data of the ConfigMap. The key not naming a builtin template
+// and the template failing the validation are rejected with an event on the ConfigMap, the builtin template is used
+// for them instead.
+func loadBuiltinOverrides(cm *corev1.ConfigMap, validate func(string) error, recorder event.Recorder) {
+	builtins, err := builtinTemplateNames()
+	if err != nil {
+		klog.ErrorS(err, "Failed to list the builtin templates")
+		return
+	}
+	var keys []string
+	for key := range cm.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	templates := map[string]string{}
+	for _, key := range keys {
+		if !builtins[key] {
+			recorder.Event(cm, event.Warning(ReasonInvalidBuiltinOverride, errors.Errorf("reject the override of %s: not a builtin template", key)))
+			continue
+		}
+		if validate != nil {
+			if err := validate(cm.Data[key]); err != nil {
+				recorder.Event(cm, event.Warning(ReasonInvalidBuiltinOverride, errors.WithMessagef(err, "reject the override of the builtin template %s", key)))
+				continue
+			}
+		}
+		templates[key] = cm.Data[key]
+	}
+	builtinOverrides.set(templates)
+	klog.InfoS("Loaded the overrides of the builtin templates", "namespace", cm.Namespace, "name", cm.Name, "overrides", len(templates))
+}
+
+// WatchBuiltinOverrides loads the overrides of the builtin templates from BuiltinOverridesConfigMap and reloads them
+// when the ConfigMap is changed, the overrides are removed if the ConfigMap is deleted. The overrides are validated
+// by the validate function. It blocks until the context is done.
+func WatchBuiltinOverrides(ctx context.Context, cli kubernetes.Interface, validate func(string) error, recorder event.Recorder) error {
+	namespace, name := systemDefinitionNamespace, BuiltinOverridesConfigMap
+	if i := strings.Index(BuiltinOverridesConfigMap, "/"); i >= 0 {
+		namespace, name = BuiltinOverridesConfigMap[:i], BuiltinOverridesConfigMap[i+1:]
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(cli, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}))
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if cm, ok := obj.(*corev1.ConfigMap); ok {
+				loadBuiltinOverrides(cm, validate, recorder)
+			}
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			if cm, ok := newObj.(*corev1.ConfigMap); ok {
+				loadBuiltinOverrides(cm, validate, recorder)
+			}
+		},
+		DeleteFunc: func(interface{}) {
+			builtinOverrides.set(nil)
+			klog.InfoS("Removed the overrides of the builtin templates", "namespace", namespace, "name", name)
+		},
+	})
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return errors.New("failed to sync the ConfigMap of the builtin template overrides")
+	}
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type recordedEvents []event.Event
+
+func (r *recordedEvents) Event(_ runtime.Object, e event.Event) {
+	*r = append(*r, e)
+}
+
+func (r *recordedEvents) WithAnnotations(_ ...string) event.Recorder {
+	return r
+}
+
+func TestLoadBuiltinOverrides(t *testing.T) {
+	defer builtinOverrides.set(nil)
+	validate := func(templ string) error {
+		if strings.Contains(templ, "invalid") {
+			return errors.New("invalid template")
+		}
+		return nil
+	}
+	loader := NewWorkflowStepTemplateLoader(nil)
+	builtin, err := loader.LoadTemplate(context.Background(), "values")
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		data     map[string]string
+		expected map[string]string
+		events   int
+	}{
+		"override the builtin template": {
+			data:     map[string]string{"read-object": `read: "overridden"`},
+			expected: map[string]string{"read-object": `read: "overridden"`, "values": builtin},
+		},
+		"reject the invalid override": {
+			data:     map[string]string{"values": "invalid", "read-object": `read: "overridden"`},
+			expected: map[string]string{"read-object": `read: "overridden"`, "values": builtin},
+			events:   1,
+		},
+		"reject the key not naming a builtin template": {
+			data:     map[string]string{"not-builtin": `custom: true`},
+			expected: map[string]string{"values": builtin},
+			events:   1,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			events := &recordedEvents{}
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "overrides", Namespace: "vela-system"}, Data: tc.data}
+			loadBuiltinOverrides(cm, validate, events)
+			r.Len(*events, tc.events)
+			for typ, expected := range tc.expected {
+				templ, err := loader.LoadTemplate(context.Background(), typ)
+				r.NoError(err)
+				r.Equal(expected, templ)
+			}
+			_, err := loader.LoadTemplate(context.Background(), "not-builtin")
+			r.Error(err)
+		})
+	}
+}
+
+func TestWatchBuiltinOverrides(t *testing.T) {
+	r := require.New(t)
+	defer builtinOverrides.set(nil)
+	BuiltinOverridesConfigMap = "vela-system/overrides"
+	defer func() { BuiltinOverridesConfigMap = "" }()
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "overrides", Namespace: "vela-system"},
+		Data:       map[string]string{"values": `v: 1`},
+	}
+	cli := fake.NewSimpleClientset(cm)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = WatchBuiltinOverrides(ctx, cli, nil, &recordedEvents{})
+	}()
+	loader := NewWorkflowStepTemplateLoader(nil)
+	loaded := func(expected string) func() bool {
+		return func() bool {
+			templ, err := loader.LoadTemplate(context.Background(), "values")
+			return err == nil && templ == expected
+		}
+	}
+	r.Eventually(loaded(`v: 1`), 5*time.Second, 10*time.Millisecond)
+
+	cm.Data["values"] = `v: 2`
+	_, err := cli.CoreV1().ConfigMaps("vela-system").Update(ctx, cm, metav1.UpdateOptions{})
+	r.NoError(err)
+	r.Eventually(loaded(`v: 2`), 5*time.Second, 10*time.Millisecond)
+
+	r.NoError(cli.CoreV1().ConfigMaps("vela-system").Delete(ctx, "overrides", metav1.DeleteOptions{}))
+	r.Eventually(func() bool {
+		templ, err := loader.LoadTemplate(context.Background(), "values")
+		return err == nil && strings.Contains(templ, "parameter")
+	}, 5*time.Second, 10*time.Millisecond)
+}
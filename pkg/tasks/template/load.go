@@ -20,22 +20,35 @@ import (
 	"context"
 	"embed"
 	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var (
 	//go:embed static
 	templateFS embed.FS
+	// DefinitionCacheSize is the max number of the definition templates cached, 0 disables the cache
+	DefinitionCacheSize = 1000
+
+	definitionCache     *cache.LRUExpireCache
+	definitionCacheOnce sync.Once
 )
 
 const (
 	templateDir = "static"
+	// definitionCacheTTL is the ttl of the cached template, the entries of the old resource versions
+	// are evicted by the lru or expired after it
+	definitionCacheTTL = time.Hour
 )
 
 type namespaceContextKey int
@@ -53,15 +66,91 @@ type Loader interface {
 }
 
 // WorkflowStepLoader load workflowStep task definition template.
+// The template is resolved in the order of the run-local overrides, the filesystem,
+// the builtin templates shadowed by the overrides in BuiltinOverridesConfigMap and
+// the definitions in the cluster. The step type pinned to
+// a revision like `deploy@v3` is always loaded from the revisions of the definition.
 type WorkflowStepLoader struct {
-	loadDefinition func(ctx context.Context, capName string) (string, error)
+	overrides map[string]string
+	fs        fs.FS
+	cli       client.Client
+}
+
+// LoaderOption is the option of the workflow step template loader
+type LoaderOption func(loader *WorkflowStepLoader)
+
+// WithOverrides sets the run-local templates keyed by the step type, they take precedence over the others.
+func WithOverrides(templates map[string]string) LoaderOption {
+	return func(loader *WorkflowStepLoader) {
+		loader.overrides = templates
+	}
+}
+
+// WithFS loads the templates from the filesystem, the template of the step type `foo` is read from `foo.cue`.
+func WithFS(fsys fs.FS) LoaderOption {
+	return func(loader *WorkflowStepLoader) {
+		loader.fs = fsys
+	}
+}
+
+// WithDir loads the templates from the directory, see WithFS.
+func WithDir(dir string) LoaderOption {
+	return WithFS(os.DirFS(dir))
 }
 
 // LoadTemplate gets the workflow step definition.
 func (loader *WorkflowStepLoader) LoadTemplate(ctx context.Context, name string) (string, error) {
+	if definitionName, revision := SplitRevision(name); revision != "" {
+		if loader.cli == nil {
+			return "", errors.Errorf("revision %s of workflow step definition %s not found", revision, definitionName)
+		}
+		return getRevisionTemplate(ctx, loader.cli, definitionName, revision)
+	}
+	templ, found, err := loader.loadLocalTemplate(name)
+	if err != nil || found {
+		return templ, err
+	}
+	if loader.cli == nil {
+		return "", errors.Errorf("workflow step definition %s not found", name)
+	}
+	return getDefinitionTemplate(ctx, loader.cli, name)
+}
+
+// ResolveRevision resolves the step type to the latest revision of the definition in the cluster, the revision
+// is created if the definition is changed after the latest one. The pinned step type is returned as is, and
+// the empty string is returned if the template is not loaded from the cluster.
+func (loader *WorkflowStepLoader) ResolveRevision(ctx context.Context, name string) (string, error) {
+	if _, revision := SplitRevision(name); revision != "" {
+		return name, nil
+	}
+	_, found, err := loader.loadLocalTemplate(name)
+	if err != nil || found || loader.cli == nil {
+		return "", err
+	}
+	return resolveLatestRevision(ctx, loader.cli, name)
+}
+
+// loadLocalTemplate loads the template from the overrides, the filesystem or the builtin templates
+func (loader *WorkflowStepLoader) loadLocalTemplate(name string) (string, bool, error) {
+	if tmpl, ok := loader.overrides[name]; ok {
+		return tmpl, true, nil
+	}
+	if loader.fs != nil {
+		content, err := fs.ReadFile(loader.fs, name+".cue")
+		if err == nil {
+			return string(content), true, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", false, errors.WithMessagef(err, "read template of %s", name)
+		}
+	}
+
+	if tmpl, ok := builtinOverrides.get(name); ok {
+		return tmpl, true, nil
+	}
 	files, err := templateFS.ReadDir(templateDir)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	staticFilename := name + ".cue"
@@ -69,20 +158,20 @@ func (loader *WorkflowStepLoader) LoadTemplate(ctx context.Context, name string)
 		if staticFilename == file.Name() {
 			fileName := fmt.Sprintf("%s/%s", templateDir, file.Name())
 			content, err := templateFS.ReadFile(fileName)
-			return string(content), err
+			return string(content), true, err
 		}
 	}
-
-	return loader.loadDefinition(ctx, name)
+	return "", false, nil
 }
 
-// NewWorkflowStepTemplateLoader create a task template loader.
-func NewWorkflowStepTemplateLoader(client client.Client) Loader {
-	return &WorkflowStepLoader{
-		loadDefinition: func(ctx context.Context, capName string) (string, error) {
-			return getDefinitionTemplate(ctx, client, capName)
-		},
+// NewWorkflowStepTemplateLoader create a task template loader, the definitions in the cluster
+// are not loaded if the client is nil.
+func NewWorkflowStepTemplateLoader(client client.Client, opts ...LoaderOption) Loader {
+	loader := &WorkflowStepLoader{cli: client}
+	for _, opt := range opts {
+		opt(loader)
 	}
+	return loader
 }
 
 type def struct {
@@ -96,6 +185,15 @@ type def struct {
 }
 
 func getDefinitionTemplate(ctx context.Context, cli client.Client, definitionName string) (string, error) {
+	definition, err := getDefinition(ctx, cli, definitionName)
+	if err != nil {
+		return "", err
+	}
+	return definitionTemplate(definition)
+}
+
+// getDefinition gets the definition from the namespace of the run, or the system namespace if not found
+func getDefinition(ctx context.Context, cli client.Client, definitionName string) (*unstructured.Unstructured, error) {
 	const (
 		definitionAPIVersion       = "core.oam.dev/v1beta1"
 		kindWorkflowStepDefinition = "WorkflowStepDefinition"
@@ -107,19 +205,44 @@ func getDefinitionTemplate(ctx context.Context, cli client.Client, definitionNam
 	if err := cli.Get(ctx, types.NamespacedName{Name: definitionName, Namespace: ns}, definition); err != nil {
 		if apierrors.IsNotFound(err) {
 			if err := cli.Get(ctx, types.NamespacedName{Name: definitionName, Namespace: systemDefinitionNamespace}, definition); err != nil {
-				return "", err
+				return nil, err
 			}
 		} else {
-			return "", err
+			return nil, err
+		}
+	}
+	return definition, nil
+}
+
+// definitionTemplate gets the template of the definition, the templates are cached by the resource versions
+func definitionTemplate(definition *unstructured.Unstructured) (string, error) {
+	// the resource version changes when the definition is edited, so the stale entry is never hit
+	key := fmt.Sprintf("%s/%s@%s", definition.GetNamespace(), definition.GetName(), definition.GetResourceVersion())
+	c := getDefinitionCache()
+	if c != nil {
+		if templ, ok := c.Get(key); ok {
+			return templ.(string), nil
 		}
 	}
 	d := new(def)
 	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(definition.Object, d); err != nil {
 		return "", errors.Wrap(err, "invalid workflow step definition")
 	}
+	if c != nil && definition.GetResourceVersion() != "" {
+		c.Add(key, d.Spec.Schematic.CUE.Template, definitionCacheTTL)
+	}
 	return d.Spec.Schematic.CUE.Template, nil
 }
 
+func getDefinitionCache() *cache.LRUExpireCache {
+	definitionCacheOnce.Do(func() {
+		if DefinitionCacheSize > 0 {
+			definitionCache = cache.NewLRUExpireCache(DefinitionCacheSize)
+		}
+	})
+	return definitionCache
+}
+
 func getDefinitionNamespaceWithCtx(ctx context.Context) string {
 	var ns string
 	if run := ctx.Value(DefinitionNamespace); run == nil {
@@ -21,10 +21,12 @@ import (
 	"encoding/json"
 	"os"
 	"testing"
+	"testing/fstest"
 
 	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 )
@@ -73,6 +75,99 @@ parameter: {
 }`)
 }
 
+func TestLoadWithFS(t *testing.T) {
+	r := require.New(t)
+	fsys := fstest.MapFS{
+		"custom.cue":     {Data: []byte(`custom: "fs"`)},
+		"overridden.cue": {Data: []byte(`overridden: "fs"`)},
+	}
+	loader := NewWorkflowStepTemplateLoader(nil, WithFS(fsys), WithOverrides(map[string]string{
+		"overridden": `overridden: "run"`,
+	}))
+	ctx := context.Background()
+
+	tmpl, err := loader.LoadTemplate(ctx, "custom")
+	r.NoError(err)
+	r.Equal(`custom: "fs"`, tmpl)
+	tmpl, err = loader.LoadTemplate(ctx, "overridden")
+	r.NoError(err)
+	r.Equal(`overridden: "run"`, tmpl)
+	tmpl, err = loader.LoadTemplate(ctx, "builtin-apply-component")
+	r.NoError(err)
+	expected, err := os.ReadFile("./static/builtin-apply-component.cue")
+	r.NoError(err)
+	r.Equal(string(expected), tmpl)
+	_, err = loader.LoadTemplate(ctx, "not-found")
+	r.EqualError(err, "workflow step definition not-found not found")
+
+	fsys["builtin-apply-component.cue"] = &fstest.MapFile{Data: []byte(`apply: "fs"`)}
+	tmpl, err = loader.LoadTemplate(ctx, "builtin-apply-component")
+	r.NoError(err)
+	r.Equal(`apply: "fs"`, tmpl)
+}
+
+func TestLoadWithCache(t *testing.T) {
+	r := require.New(t)
+	resourceVersion, templ := "1", `apply: "v1"`
+	cli := &test.MockClient{
+		MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+			o := obj.(*unstructured.Unstructured)
+			o.SetNamespace(key.Namespace)
+			o.SetName(key.Name)
+			o.SetResourceVersion(resourceVersion)
+			return unstructured.SetNestedField(o.Object, templ, "spec", "schematic", "cue", "template")
+		},
+	}
+	loader := NewWorkflowStepTemplateLoader(cli)
+	ctx := context.Background()
+
+	tmpl, err := loader.LoadTemplate(ctx, "cached")
+	r.NoError(err)
+	r.Equal(`apply: "v1"`, tmpl)
+	_, ok := getDefinitionCache().Get("vela-system/cached@1")
+	r.True(ok)
+
+	// the definition is edited in the middle of the run
+	resourceVersion, templ = "2", `apply: "v2"`
+	tmpl, err = loader.LoadTemplate(ctx, "cached")
+	r.NoError(err)
+	r.Equal(`apply: "v2"`, tmpl)
+
+	// the cached template is used when the resource version is not changed
+	templ = `apply: "v3"`
+	tmpl, err = loader.LoadTemplate(ctx, "cached")
+	r.NoError(err)
+	r.Equal(`apply: "v2"`, tmpl)
+}
+
+// BenchmarkDefinitionTemplate compares resolving the template of an unchanged definition with and without the cache
+func BenchmarkDefinitionTemplate(b *testing.B) {
+	definition := &unstructured.Unstructured{}
+	require.NoError(b, yaml.Unmarshal([]byte(stepDefYaml), &definition.Object))
+	definition.SetResourceVersion("1")
+	getDefinitionCache()
+	for _, bc := range []struct {
+		name  string
+		cache *cache.LRUExpireCache
+	}{
+		{name: "cached", cache: definitionCache},
+		{name: "uncached"},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			defer func(c *cache.LRUExpireCache) {
+				definitionCache = c
+			}(definitionCache)
+			definitionCache = bc.cache
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := definitionTemplate(definition); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 var (
 	stepDefYaml = `apiVersion: core.oam.dev/v1beta1
 kind: WorkflowStepDefinition
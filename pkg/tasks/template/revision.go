@@ -0,0 +1,196 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// LabelStepDefinition is the label of the definition revisions with the name of the step definition
+	LabelStepDefinition = "workflow.oam.dev/step-definition"
+	// LabelTemplateHash is the label of the definition revisions with the hash of the template
+	LabelTemplateHash = "workflow.oam.dev/template-hash"
+
+	revisionSeparator = "@"
+	revisionPrefix    = "v"
+)
+
+// RevisionHistoryLimit is the max number of the revisions kept for each definition, the oldest revisions beyond it
+// are deleted when a new revision is created, 0 keeps all of them
+var RevisionHistoryLimit = 10
+
+// RevisionResolver resolves the step types to the revisions of the step definitions
+type RevisionResolver interface {
+	ResolveRevision(ctx context.Context, name string) (string, error)
+}
+
+// SplitRevision splits the step type like `deploy@v3` into the name and the revision of the definition,
+// the revision is empty if the step type is not pinned.
+func SplitRevision(typ string) (string, string) {
+	if i := strings.LastIndex(typ, revisionSeparator); i >= 0 {
+		return typ[:i], typ[i+1:]
+	}
+	return typ, ""
+}
+
+// RevisionName returns the name of the ControllerRevision storing the revision of the definition
+func RevisionName(definitionName string, revision int64) string {
+	return fmt.Sprintf("%s-%s%d", definitionName, revisionPrefix, revision)
+}
+
+func pinnedType(definitionName string, revision int64) string {
+	return fmt.Sprintf("%s%s%s%d", definitionName, revisionSeparator, revisionPrefix, revision)
+}
+
+type revisionData struct {
+	Template string `json:"template"`
+}
+
+func parseRevision(revision string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimPrefix(revision, revisionPrefix), 10, 64)
+	if err != nil || !strings.HasPrefix(revision, revisionPrefix) || n <= 0 {
+		return 0, errors.Errorf("invalid revision %s, expect a revision like v1", revision)
+	}
+	return n, nil
+}
+
+func templateHash(templ string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(templ)))[:16]
+}
+
+// getRevisionTemplate gets the template of the revision of the definition, the revisions are looked up like the definitions
+func getRevisionTemplate(ctx context.Context, cli client.Client, definitionName string, revision string) (string, error) {
+	n, err := parseRevision(revision)
+	if err != nil {
+		return "", err
+	}
+	name := RevisionName(definitionName, n)
+	rev := &appsv1.ControllerRevision{}
+	if err := cli.Get(ctx, types.NamespacedName{Name: name, Namespace: getDefinitionNamespaceWithCtx(ctx)}, rev); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", err
+		}
+		if err := cli.Get(ctx, types.NamespacedName{Name: name, Namespace: systemDefinitionNamespace}, rev); err != nil {
+			if apierrors.IsNotFound(err) {
+				return "", errors.Errorf("revision %s of workflow step definition %s not found", revision, definitionName)
+			}
+			return "", err
+		}
+	}
+	data := &revisionData{}
+	if err := json.Unmarshal(rev.Data.Raw, data); err != nil {
+		return "", errors.Wrapf(err, "invalid revision %s of workflow step definition %s", revision, definitionName)
+	}
+	return data.Template, nil
+}
+
+// resolveLatestRevision returns the step type pinned to the latest revision of the definition, a new revision
+// owned by the definition is created next to it if its template is changed after the latest revision.
+func resolveLatestRevision(ctx context.Context, cli client.Client, definitionName string) (string, error) {
+	definition, err := getDefinition(ctx, cli, definitionName)
+	if err != nil {
+		return "", err
+	}
+	templ, err := definitionTemplate(definition)
+	if err != nil {
+		return "", err
+	}
+	revs := &appsv1.ControllerRevisionList{}
+	if err := cli.List(ctx, revs, client.InNamespace(definition.GetNamespace()), client.MatchingLabels{LabelStepDefinition: definitionName}); err != nil {
+		return "", errors.WithMessagef(err, "list the revisions of workflow step definition %s", definitionName)
+	}
+	var latest *appsv1.ControllerRevision
+	for i := range revs.Items {
+		if latest == nil || revs.Items[i].Revision > latest.Revision {
+			latest = &revs.Items[i]
+		}
+	}
+	hash := templateHash(templ)
+	if latest != nil && latest.Labels[LabelTemplateHash] == hash {
+		return pinnedType(definitionName, latest.Revision), nil
+	}
+	var n int64 = 1
+	if latest != nil {
+		n = latest.Revision + 1
+	}
+	raw, err := json.Marshal(revisionData{Template: templ})
+	if err != nil {
+		return "", err
+	}
+	rev := &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      RevisionName(definitionName, n),
+			Namespace: definition.GetNamespace(),
+			Labels: map[string]string{
+				LabelStepDefinition: definitionName,
+				LabelTemplateHash:   hash,
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: definition.GetAPIVersion(),
+				Kind:       definition.GetKind(),
+				Name:       definition.GetName(),
+				UID:        definition.GetUID(),
+			}},
+		},
+		Data:     runtime.RawExtension{Raw: raw},
+		Revision: n,
+	}
+	if err := cli.Create(ctx, rev); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			// created by another run at the same time, it's reused if the template is the same
+			existing := &appsv1.ControllerRevision{}
+			if err := cli.Get(ctx, client.ObjectKeyFromObject(rev), existing); err == nil && existing.Labels[LabelTemplateHash] == hash {
+				return pinnedType(definitionName, n), nil
+			}
+		}
+		return "", errors.WithMessagef(err, "create the revision of workflow step definition %s", definitionName)
+	}
+	if err := pruneRevisions(ctx, cli, revs.Items, n); err != nil {
+		return "", errors.WithMessagef(err, "prune the revisions of workflow step definition %s", definitionName)
+	}
+	return pinnedType(definitionName, n), nil
+}
+
+// pruneRevisions deletes the revisions beyond the history limit after the latest revision is created
+func pruneRevisions(ctx context.Context, cli client.Client, revs []appsv1.ControllerRevision, latest int64) error {
+	if RevisionHistoryLimit <= 0 {
+		return nil
+	}
+	for i := range revs {
+		if revs[i].Revision > latest-int64(RevisionHistoryLimit) {
+			continue
+		}
+		if err := cli.Delete(ctx, &revs[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSplitRevision(t *testing.T) {
+	r := require.New(t)
+	name, revision := SplitRevision("deploy@v3")
+	r.Equal("deploy", name)
+	r.Equal("v3", revision)
+	name, revision = SplitRevision("deploy")
+	r.Equal("deploy", name)
+	r.Equal("", revision)
+	_, err := parseRevision("3")
+	r.Error(err)
+	_, err = parseRevision("v0")
+	r.Error(err)
+}
+
+func TestResolveRevision(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	def := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "core.oam.dev/v1beta1",
+		"kind":       "WorkflowStepDefinition",
+		"metadata":   map[string]interface{}{"name": "deploy", "namespace": "vela-system"},
+		"spec": map[string]interface{}{"schematic": map[string]interface{}{"cue": map[string]interface{}{
+			"template": "v: 1",
+		}}},
+	}}
+	cli := fake.NewClientBuilder().WithObjects(def).Build()
+	loader := NewWorkflowStepTemplateLoader(cli, WithOverrides(map[string]string{"local": "v: 0"})).(*WorkflowStepLoader)
+
+	// the local templates are not revisioned
+	typ, err := loader.ResolveRevision(ctx, "local")
+	r.NoError(err)
+	r.Equal("", typ)
+	typ, err = loader.ResolveRevision(ctx, "builtin-apply-component")
+	r.NoError(err)
+	r.Equal("", typ)
+
+	typ, err = loader.ResolveRevision(ctx, "deploy")
+	r.NoError(err)
+	r.Equal("deploy@v1", typ)
+	typ, err = loader.ResolveRevision(ctx, "deploy")
+	r.NoError(err)
+	r.Equal("deploy@v1", typ)
+
+	r.NoError(unstructured.SetNestedField(def.Object, "v: 2", "spec", "schematic", "cue", "template"))
+	r.NoError(cli.Update(ctx, def))
+	typ, err = loader.ResolveRevision(ctx, "deploy")
+	r.NoError(err)
+	r.Equal("deploy@v2", typ)
+
+	templ, err := loader.LoadTemplate(ctx, "deploy@v1")
+	r.NoError(err)
+	r.Equal("v: 1", templ)
+	templ, err = loader.LoadTemplate(ctx, "deploy@v2")
+	r.NoError(err)
+	r.Equal("v: 2", templ)
+	_, err = loader.LoadTemplate(ctx, "deploy@v3")
+	r.Error(err)
+
+	// the revisions are owned by the definition
+	rev := &appsv1.ControllerRevision{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "vela-system", Name: RevisionName("deploy", 2)}, rev))
+	r.Len(rev.OwnerReferences, 1)
+	r.Equal("WorkflowStepDefinition", rev.OwnerReferences[0].Kind)
+	r.Equal("deploy", rev.OwnerReferences[0].Name)
+
+	// the oldest revisions beyond the history limit are deleted
+	defer func(limit int) { RevisionHistoryLimit = limit }(RevisionHistoryLimit)
+	RevisionHistoryLimit = 2
+	r.NoError(cli.Get(ctx, client.ObjectKeyFromObject(def), def))
+	r.NoError(unstructured.SetNestedField(def.Object, "v: 3", "spec", "schematic", "cue", "template"))
+	r.NoError(cli.Update(ctx, def))
+	typ, err = loader.ResolveRevision(ctx, "deploy")
+	r.NoError(err)
+	r.Equal("deploy@v3", typ)
+	_, err = loader.LoadTemplate(ctx, "deploy@v1")
+	r.Error(err)
+	templ, err = loader.LoadTemplate(ctx, "deploy@v2")
+	r.NoError(err)
+	r.Equal("v: 2", templ)
+}
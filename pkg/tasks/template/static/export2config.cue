@@ -0,0 +1,31 @@
+import (
+	"vela/op"
+)
+
+// create the ConfigMap with the data or merge the data into the existing one
+export: op.#ExportToConfigMap & {
+	name: parameter.name
+	if parameter.namespace != _|_ {
+		namespace: parameter.namespace
+	}
+	if parameter.namespace == _|_ {
+		namespace: context.namespace
+	}
+	data:    parameter.data
+	replace: parameter.replace
+	cluster: parameter.cluster
+}
+
+resourceVersion: export.resourceVersion
+parameter: {
+	// +usage=Specify the name of the ConfigMap
+	name: string
+	// +usage=Specify the namespace of the ConfigMap, default to the namespace of the workflow run
+	namespace?: string
+	// +usage=Specify the data of the ConfigMap
+	data: [string]: string
+	// +usage=Specify whether to replace the whole data of the existing ConfigMap instead of merging the keys
+	replace: *false | bool
+	// +usage=Specify the cluster of the ConfigMap
+	cluster: *"" | string
+}
@@ -0,0 +1,31 @@
+import (
+	"vela/op"
+)
+
+// create the Secret with the data or merge the data into the existing one
+export: op.#ExportToSecret & {
+	name: parameter.name
+	if parameter.namespace != _|_ {
+		namespace: parameter.namespace
+	}
+	if parameter.namespace == _|_ {
+		namespace: context.namespace
+	}
+	data:    parameter.data
+	replace: parameter.replace
+	cluster: parameter.cluster
+}
+
+resourceVersion: export.resourceVersion
+parameter: {
+	// +usage=Specify the name of the Secret
+	name: string
+	// +usage=Specify the namespace of the Secret, default to the namespace of the workflow run
+	namespace?: string
+	// +usage=Specify the data of the Secret in plain text, it is encoded by base64 when stored
+	data: [string]: string
+	// +usage=Specify whether to replace the whole data of the existing Secret instead of merging the keys
+	replace: *false | bool
+	// +usage=Specify the cluster of the Secret
+	cluster: *"" | string
+}
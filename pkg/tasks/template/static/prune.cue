@@ -0,0 +1,45 @@
+import (
+	"vela/op"
+)
+
+// delete the objects of the resources matching the label selector, the kinds must be allowed by the controller
+prune: op.#Prune & {
+	resources: parameter.resources
+	if parameter.namespace != _|_ {
+		namespace: parameter.namespace
+	}
+	if parameter.namespace == _|_ {
+		namespace: context.namespace
+	}
+	labelSelector: parameter.labelSelector
+	dryRun:        parameter.dryRun
+	if parameter.grace != _|_ {
+		grace: parameter.grace
+	}
+	wait:    parameter.wait
+	cluster: parameter.cluster
+}
+
+if prune.deleted != _|_ {
+	output: deleted: prune.deleted
+}
+
+parameter: {
+	// +usage=Specify the resources of the objects to prune
+	resources: [...{
+		apiVersion: string
+		kind:       string
+	}]
+	// +usage=Specify the namespace of the objects, default to the namespace of the workflow run
+	namespace?: string
+	// +usage=Specify the label selector of the objects, e.g. app=web,tier!=db
+	labelSelector: string
+	// +usage=Specify whether to output the objects that would be deleted without deleting them
+	dryRun: *false | bool
+	// +usage=Specify the grace period of the deletion, e.g. 30s
+	grace?: string
+	// +usage=Specify whether to wait until the deleted objects are gone
+	wait: *true | bool
+	// +usage=Specify the cluster of the objects
+	cluster: *"" | string
+}
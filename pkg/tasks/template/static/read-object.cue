@@ -0,0 +1,70 @@
+import (
+	"vela/op"
+)
+
+// read the object from the cluster
+read: op.#Read & {
+	value: {
+		apiVersion: parameter.apiVersion
+		kind:       parameter.kind
+		metadata: {
+			name: parameter.name
+			if parameter.namespace != _|_ {
+				namespace: parameter.namespace
+			}
+			if parameter.namespace == _|_ {
+				namespace: context.namespace
+			}
+		}
+	}
+	cluster: parameter.cluster
+}
+
+if read.err != _|_ {
+	// the empty output also keeps the message of the failure from being overwritten by the missing output
+	output: {}
+	if read.errType != "NotFound" || !parameter.allowMissing {
+		fail: op.#Fail & {
+			message: read.err
+		}
+	}
+}
+
+if read.err == _|_ && parameter.path == _|_ {
+	output: read.value
+}
+
+if read.err == _|_ && parameter.path != _|_ {
+	lookup: op.#Lookup & {
+		value: read.value
+		path:  parameter.path
+	}
+	if lookup.result != _|_ {
+		output: lookup.result
+	}
+	if lookup.found != _|_ {
+		if !lookup.found {
+			output: {}
+			fail: op.#Fail & {
+				message: "path \(parameter.path) is not found in \(parameter.kind) \(parameter.name)"
+			}
+		}
+	}
+}
+
+parameter: {
+	// +usage=Specify the apiVersion of the object
+	apiVersion: string
+	// +usage=Specify the kind of the object
+	kind: string
+	// +usage=Specify the name of the object
+	name: string
+	// +usage=Specify the namespace of the object, default to the namespace of the workflow run
+	namespace?: string
+	// +usage=Specify the cluster of the object
+	cluster: *"" | string
+	// +usage=Specify the path of the field to output, e.g. spec.template.spec.containers[0].image, the whole object is output if not set
+	path?: string
+	// +usage=Specify whether to succeed with an empty output if the object is not found
+	allowMissing: *false | bool
+}
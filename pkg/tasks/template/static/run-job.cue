@@ -0,0 +1,65 @@
+import (
+	"vela/op"
+)
+
+// apply the Job if the manifest is given, otherwise wait for the existing Job
+if parameter.job != _|_ {
+	apply: op.#Apply & {
+		value: parameter.job & {
+			apiVersion: "batch/v1"
+			kind:       "Job"
+			if parameter.job.metadata.namespace == _|_ {
+				metadata: namespace: context.namespace
+			}
+		}
+		cluster: parameter.cluster
+	}
+}
+
+// wait for the Job to complete, the step fails with the reason of the Job and the last logs if it's failed
+wait: op.#WaitJob & {
+	if parameter.job != _|_ {
+		name: parameter.job.metadata.name
+		if parameter.job.metadata.namespace != _|_ {
+			namespace: parameter.job.metadata.namespace
+		}
+		if parameter.job.metadata.namespace == _|_ {
+			namespace: context.namespace
+		}
+	}
+	if parameter.job == _|_ {
+		name: parameter.name
+		if parameter.namespace != _|_ {
+			namespace: parameter.namespace
+		}
+		if parameter.namespace == _|_ {
+			namespace: context.namespace
+		}
+	}
+	cluster:   parameter.cluster
+	tailLines: parameter.tailLines
+}
+
+if wait.result != _|_ {
+	output: wait.result
+}
+
+parameter: {
+	// +usage=Specify the manifest of the Job to apply, either job or name is required
+	job?: {
+		metadata: {
+			name:       string
+			namespace?: string
+			...
+		}
+		...
+	}
+	// +usage=Specify the name of the existing Job to wait for
+	name?: string
+	// +usage=Specify the namespace of the existing Job, default to the namespace of the workflow run
+	namespace?: string
+	// +usage=Specify the cluster of the Job
+	cluster: *"" | string
+	// +usage=Specify the number of the last lines of the logs of the terminated container to output
+	tailLines: *20 | int
+}
@@ -0,0 +1,23 @@
+import (
+	"strings"
+	"vela/op"
+)
+
+// make the properties concrete and output them as values
+concrete: op.#Concrete & {
+	value: parameter
+}
+
+if concrete.incomplete != _|_ {
+	// the empty values also keep the message of the failure from being overwritten by the missing output
+	values: {}
+	fail: op.#Fail & {
+		message: "incomplete values: " + strings.Join(concrete.incomplete, ", ")
+	}
+}
+
+if concrete.result != _|_ {
+	values: concrete.result
+}
+
+parameter: {...}
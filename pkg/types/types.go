@@ -18,10 +18,18 @@ package types
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
 
 	"cuelang.org/go/cue"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/clock"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
@@ -40,10 +48,39 @@ type WorkflowInstance struct {
 	WorkflowMeta
 	OwnerInfo []metav1.OwnerReference
 	Debug     bool
-	Context   map[string]interface{}
-	Mode      *v1alpha1.WorkflowExecuteMode
-	Steps     []v1alpha1.WorkflowStep
-	Status    v1alpha1.WorkflowRunStatus
+	// DebugSteps is the steps to debug, all steps will be debugged if it's empty and Debug is true
+	DebugSteps []string
+	// LiveProgress indicates whether to report the live progress of the steps
+	LiveProgress bool
+	// DryRun indicates whether the run is executed without mutating the cluster and the external systems
+	DryRun bool
+	// TrackResources indicates whether the objects applied by the run are recorded for the gc
+	TrackResources bool
+	// MaxConsecutiveFailures is the consecutive failures of a step to suspend the run, 0 disables it
+	MaxConsecutiveFailures int
+	// Correlation is the correlation ids captured from the annotations of the workflow run
+	Correlation map[string]string
+	// Vars are the CUE expressions of spec.var evaluated once when the workflow run starts
+	Vars map[string]string
+	// ContextFromVars and SensitiveVars are the vars imported by spec.contextFrom before the first step runs, the
+	// sensitive ones are read from the Secrets and kept out of the context ConfigMap
+	ContextFromVars map[string]string
+	SensitiveVars   map[string]string
+	// SkippedContextSources are the messages of the optional sources of spec.contextFrom which are not found
+	SkippedContextSources []string
+	// ResourceLabels and ResourceAnnotations are merged into the metadata of the objects applied by the steps
+	ResourceLabels      map[string]string
+	ResourceAnnotations map[string]string
+	// ResourceMetadataOverride overrides the existing metadata of the applied objects
+	ResourceMetadataOverride bool
+	Context                  map[string]interface{}
+	Mode                     *v1alpha1.WorkflowExecuteMode
+	// Timeout is the effective timeout of the workflow run since it starts, empty means no timeout
+	Timeout string
+	// ProviderOverrides override the endpoints dialed by the providers, nil if they are disallowed by the controller
+	ProviderOverrides *v1alpha1.ProviderOverrides
+	Steps             []v1alpha1.WorkflowStep
+	Status            v1alpha1.WorkflowRunStatus
 }
 
 // WorkflowMeta is the meta information for workflow instance
@@ -52,6 +89,7 @@ type WorkflowMeta struct {
 	Namespace            string
 	Annotations          map[string]string
 	Labels               map[string]string
+	UID                  k8stypes.UID
 	ChildOwnerReferences []metav1.OwnerReference
 }
 
@@ -85,15 +123,30 @@ type TaskRunOptions struct {
 	PostStopHooks []TaskPostStopHook
 	GetTracer     func(id string, step v1alpha1.WorkflowStep) monitorContext.Context
 	RunSteps      func(isDag bool, runners ...TaskRunner) (*v1alpha1.WorkflowRunStatus, error)
-	Debug         func(step string, v *value.Value) error
-	StepStatus    map[string]v1alpha1.StepStatus
-	Engine        Engine
+	Debug         func(step string, v *value.Value, err error, trace []OpTrace) error
+	// DebugStep returns whether the step is selected to debug, the ops of the step are traced only if it's selected
+	DebugStep  func(step string) bool
+	StepStatus map[string]v1alpha1.StepStatus
+	Engine     Engine
+	// Clock is the clock to check the durations of the steps, the real clock is used if it's nil
+	Clock clock.Clock
+	// Audit receives the audit records of the ops with the step, the op, the targets, the outcome and the duration
+	// filled, the ops are not audited if it's nil
+	Audit func(record AuditRecord)
 }
 
 // PreCheckResult is the result of pre check.
 type PreCheckResult struct {
 	Skip    bool
 	Timeout bool
+	// ParentTimeout marks the timeout of the sub step inherited from its timed out step group
+	ParentTimeout bool
+	// Failed marks the step as failed, e.g. the if condition of the step could not be evaluated
+	Failed bool
+	// Wait keeps the step waiting without executing it, e.g. the step is out of its execute window
+	Wait bool
+	// Message explains why the step is skipped, failed or waiting
+	Message string
 }
 
 // PreCheckOptions is the options for pre check.
@@ -101,6 +154,11 @@ type PreCheckOptions struct {
 	PackageDiscover *packages.PackageDiscover
 	BasicTemplate   string
 	BasicValue      *value.Value
+	// ParentStep is the step group of the sub step, its inputs are referable in the if of the sub step
+	ParentStep *v1alpha1.WorkflowStep
+	// Steps is the steps of the workflow, the outputs of the succeeded steps are referable in the if as
+	// steps.<step>.output.<output> and steps.<step group>.<sub step>.output.<output>
+	Steps []v1alpha1.WorkflowStep
 }
 
 // TaskPreCheckHook is the hook for pre check.
@@ -112,6 +170,90 @@ type TaskPreStartHook func(ctx wfContext.Context, paramValue *value.Value, step
 // TaskPostStopHook  run after task execution.
 type TaskPostStopHook func(ctx wfContext.Context, taskValue *value.Value, step v1alpha1.WorkflowStep, status v1alpha1.StepStatus, stepStatus map[string]v1alpha1.StepStatus) error
 
+// OpTrace is the trace of an op executed in the step.
+type OpTrace struct {
+	// Op is the name of the op as `provider/op`, e.g. `kube/apply`, the same as in the audit records and the
+	// failed op of the step.
+	Op        string    `json:"op"`
+	Input     string    `json:"input,omitempty"`
+	Output    string    `json:"output,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+const (
+	// AuditOutcomeSucceeded is the outcome of the op returning no error
+	AuditOutcomeSucceeded = "succeeded"
+	// AuditOutcomeFailed is the outcome of the op returning an error
+	AuditOutcomeFailed = "failed"
+)
+
+// AuditTarget is the summary of the target of an op, e.g. the identity of the object for the kube ops
+// or the method and the host for the http ops. It must not contain the sensitive values.
+type AuditTarget map[string]string
+
+// AuditRecord is the audit record of an op executed in a step.
+type AuditRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	RunUID    string    `json:"runUID"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Creator   string    `json:"creator,omitempty"`
+	// Correlation is the correlation ids captured from the annotations of the workflow run
+	Correlation    map[string]string `json:"correlation,omitempty"`
+	Step           string            `json:"step"`
+	Op             string            `json:"op"`
+	Targets        []AuditTarget     `json:"targets,omitempty"`
+	Outcome        string            `json:"outcome"`
+	DurationMillis int64             `json:"durationMillis"`
+}
+
+// AuditSink receives the audit records of the ops, it's called synchronously after each op.
+type AuditSink interface {
+	Write(record AuditRecord) error
+}
+
+// Auditor is implemented by the actions of the steps recording the targets of the ops in the audit records.
+type Auditor interface {
+	// AuditTarget adds the target of the running op, the providers redact the sensitive values before calling it.
+	AuditTarget(target AuditTarget)
+}
+
+// RecordAuditTarget records the target of the running op if the action supports the audit.
+func RecordAuditTarget(act Action, target AuditTarget) {
+	if auditor, ok := act.(Auditor); ok {
+		auditor.AuditTarget(target)
+	}
+}
+
+// Watcher is implemented by the actions of the steps watching the objects they are waiting on, the changes of the
+// watched objects trigger the reconcile of the run instead of the periodic backoff.
+type Watcher interface {
+	// Watch adds the object to the watches of the step, they are kept only if the step is waiting after the op.
+	Watch(obj v1alpha1.WatchedObject)
+}
+
+// RecordWatch records the object watched by the waiting step if the action supports the watches.
+func RecordWatch(act Action, obj v1alpha1.WatchedObject) {
+	if watcher, ok := act.(Watcher); ok {
+		watcher.Watch(obj)
+	}
+}
+
+// ReasonedFailer is implemented by the actions of the steps failing the steps with the specific reasons.
+type ReasonedFailer interface {
+	FailWithReason(reason, message string)
+}
+
+// FailWithReason fails the step with the reason if the action supports it, otherwise the reason is Action.
+func FailWithReason(act Action, reason, message string) {
+	if failer, ok := act.(ReasonedFailer); ok {
+		failer.FailWithReason(reason, message)
+		return
+	}
+	act.Fail(message)
+}
+
 // Operation is workflow operation object.
 type Operation struct {
 	Suspend            bool
@@ -133,6 +275,8 @@ type TaskGeneratorOptions struct {
 	SubStepExecuteMode v1alpha1.WorkflowMode
 	PackageDiscover    *packages.PackageDiscover
 	ProcessContext     process.Context
+	// DefinitionRevision is the step type pinned to the revision of the definition, it's recorded in the step status
+	DefinitionRevision string
 }
 
 // Handler is provider's processing method.
@@ -144,15 +288,38 @@ type Providers interface {
 	Register(provider string, m map[string]Handler)
 }
 
+// ProviderRegistry installs the providers registered by the users who embed the workflow engine.
+type ProviderRegistry interface {
+	InstallTo(p Providers)
+}
+
 // StepGeneratorOptions is the options for generate step.
 type StepGeneratorOptions struct {
-	Providers       Providers
-	PackageDiscover *packages.PackageDiscover
-	ProcessCtx      process.Context
-	TemplateLoader  template.Loader
-	Client          client.Client
-	StepConvertor   map[string]func(step v1alpha1.WorkflowStep) (v1alpha1.WorkflowStep, error)
-	LogLevel        int
+	Providers Providers
+	// ProviderRegistry is the registry of the extra providers, the default registry is used if it's nil
+	ProviderRegistry ProviderRegistry
+	PackageDiscover  *packages.PackageDiscover
+	ProcessCtx       process.Context
+	TemplateLoader   template.Loader
+	// TemplateFS is the filesystem to load the step templates from, it's used when the TemplateLoader is nil
+	TemplateFS fs.FS
+	// TemplateOverrides is the run-local step templates keyed by the step type, it's used when the TemplateLoader is nil
+	TemplateOverrides map[string]string
+	Client            client.Client
+	// KubeClient reads the logs of the pods for the ops, e.g. the logs of the jobs, the logs are not read if it's nil
+	KubeClient    kubernetes.Interface
+	StepConvertor map[string]func(step v1alpha1.WorkflowStep) (v1alpha1.WorkflowStep, error)
+	LogLevel      int
+}
+
+// StepHook is notified synchronously with the lifecycle of the steps and the workflow.
+type StepHook interface {
+	// OnStepStart is called before the step is executed for the first time.
+	OnStepStart(ctx monitorContext.Context, step v1alpha1.WorkflowStep, wfCtx wfContext.ReadOnlyContext) error
+	// OnStepComplete is called when the step is finished, the phase of the status tells whether it's succeeded, failed or skipped.
+	OnStepComplete(ctx monitorContext.Context, step v1alpha1.WorkflowStep, status v1alpha1.StepStatus, wfCtx wfContext.ReadOnlyContext) error
+	// OnWorkflowComplete is called when the workflow is finished.
+	OnWorkflowComplete(ctx monitorContext.Context, instance *WorkflowInstance, phase v1alpha1.WorkflowRunPhase, wfCtx wfContext.ReadOnlyContext) error
 }
 
 // Action is that workflow provider can do.
@@ -195,23 +362,68 @@ type LogSource struct {
 type LogConfig struct {
 	Data   bool       `json:"data,omitempty"`
 	Source *LogSource `json:"source,omitempty"`
+	// Collect is the config to collect the logs of the pods into the logs config map when the step is finished
+	Collect *CollectLogs `json:"collect,omitempty"`
+}
+
+// CollectLogs is the config to collect the logs of the pods selected by the labels or by the selector of the resource
+type CollectLogs struct {
+	PodSelector *PodSelector    `json:"podSelector,omitempty"`
+	Resource    *LogResourceRef `json:"resource,omitempty"`
+	// Container is the container to collect the logs from, all the containers of the pods are collected if it's empty
+	Container string `json:"container,omitempty"`
+	TailLines *int64 `json:"tailLines,omitempty"`
+}
+
+// PodSelector selects the pods by labels
+type PodSelector struct {
+	Cluster     string            `json:"cluster,omitempty"`
+	Namespace   string            `json:"namespace,omitempty"`
+	MatchLabels map[string]string `json:"matchLabels"`
+}
+
+// LogResourceRef refers to the resource whose pods are selected by its spec.selector, e.g. a Job, or the pod itself
+type LogResourceRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+	Cluster    string `json:"cluster,omitempty"`
 }
 
 const (
 	// ContextKeyMetadata is key that refer to workflow metadata.
 	ContextKeyMetadata = "metadata__"
+	// ContextKeyVars is key that refer to the values of spec.var evaluated when the workflow run starts.
+	ContextKeyVars = "vars__"
+	// ContextKeySensitiveVars is key that refer to the sensitive vars imported from the Secrets in the memory store.
+	ContextKeySensitiveVars = "sensitive_vars__"
 	// ContextPrefixFailedTimes is the prefix that refer to the failed times of the step in workflow context config map.
 	ContextPrefixFailedTimes = "failed_times"
 	// ContextPrefixBackoffTimes is the prefix that refer to the backoff times in workflow context config map.
 	ContextPrefixBackoffTimes = "backoff_times"
 	// ContextPrefixBackoffReason is the prefix that refer to the current backoff reason in workflow context config map
 	ContextPrefixBackoffReason = "backoff_reason"
+	// ContextPrefixOpMarkers is the prefix that refer to the completion markers of the ops in workflow context config map.
+	ContextPrefixOpMarkers = "op_markers"
+	// ContextPrefixIdempotentResults is the prefix that refer to the results of the ops with the idempotency keys in workflow context config map.
+	ContextPrefixIdempotentResults = "idempotent_results"
+	// ContextPrefixInjectedFailures is the prefix that refer to the injected failures of the step in workflow context config map.
+	ContextPrefixInjectedFailures = "injected_failures"
 	// ContextKeyLastExecuteTime is the key that refer to the last execute time in workflow context config map.
 	ContextKeyLastExecuteTime = "last_execute_time"
 	// ContextKeyNextExecuteTime is the key that refer to the next execute time in workflow context config map.
 	ContextKeyNextExecuteTime = "next_execute_time"
+	// ContextKeyOutputProducers is the key that refer to the steps producing the outputs in memory.
+	ContextKeyOutputProducers = "output_producers"
+	// ContextKeySkippedSteps is the key that refer to the skipped steps and sub steps in memory.
+	ContextKeySkippedSteps = "skipped_steps"
+	// ContextKeyQualifiedOutputs is the key that refer to the qualified names of the sub step outputs in memory.
+	ContextKeyQualifiedOutputs = "qualified_outputs"
 	// ContextKeyLogConfig is key for log config.
 	ContextKeyLogConfig = "logConfig"
+	// ContextKeyAppliedResources is the key that refer to the resources applied by the workflow run in workflow context config map.
+	ContextKeyAppliedResources = "appliedResources"
 )
 
 const (
@@ -230,11 +442,42 @@ const (
 	LabelWorkflowRunName = "workflowrun.oam.dev/name"
 	// LabelWorkflowRunNamespace is the label key for workflow run namespace
 	LabelWorkflowRunNamespace = "workflowrun.oam.dev/namespace"
+	// LabelWorkflowRunStep is the label key for the name of the step applying the object
+	LabelWorkflowRunStep = "workflowrun.oam.dev/step"
+)
+
+const (
+	// FinalizerResourceGC is the finalizer of the workflow run to delete the resources applied by it
+	FinalizerResourceGC = "workflowrun.oam.dev/resource-gc"
+)
+
+const (
+	// DefaultKubeVelaNS is the default namespace of the KubeVela system
+	DefaultKubeVelaNS = "vela-system"
 )
 
 var (
 	// MaxWorkflowStepErrorRetryTimes is the max retry times of the failed workflow step.
 	MaxWorkflowStepErrorRetryTimes = 10
+	// MaxConsecutiveFailures is the consecutive failures of a step to suspend the workflow run, 0 disables it.
+	MaxConsecutiveFailures = 0
+	// MaxStepMessageHistory is the max number of the distinct messages kept in the message history of a step, 0 disables it.
+	MaxStepMessageHistory = 10
+	// MaxStepMessageSize is the max size in bytes of the message of a step, the full message beyond it is stored in
+	// the overflow ConfigMap of the workflow context, 0 means no limit.
+	MaxStepMessageSize = 1024
+	// AllowProviderOverrides indicates whether the workflow runs are allowed to override the endpoints of the providers
+	// with spec.providerOverrides, it should be disabled in the production clusters.
+	AllowProviderOverrides = true
+	// DryRunMode indicates whether all the workflow runs are executed in the dry-run mode, the writes to the cluster
+	// are dry-run by the server, the calls to the external systems are skipped and the workflow contexts are kept in memory.
+	DryRunMode = false
+	// MaxWatchesPerRun is the max number of the objects watched by the waiting steps of a run, the step whose watches
+	// are beyond it is reconciled with the periodic backoff instead, 0 disables the watches.
+	MaxWatchesPerRun = 16
+	// WatchResyncPeriod is the period to reconcile the run whose running steps are all watching the objects, it
+	// replaces the shorter backoff of the waiting steps. 0 keeps the backoff.
+	WatchResyncPeriod time.Duration
 	// MaxWorkflowWaitBackoffTime is the max time to wait before reconcile wait workflow again
 	MaxWorkflowWaitBackoffTime = 60
 	// MaxWorkflowFailedBackoffTime is the max time to wait before reconcile failed workflow again
@@ -258,12 +501,26 @@ const (
 	StatusReasonParameter = "ProcessParameter"
 	// StatusReasonOutput is the reason of the workflow progress condition which is Output.
 	StatusReasonOutput = "Output"
+	// StatusReasonInput is the reason of the workflow progress condition which is Input.
+	StatusReasonInput = "Input"
 	// StatusReasonFailedAfterRetries is the reason of the workflow progress condition which is FailedAfterRetries.
 	StatusReasonFailedAfterRetries = "FailedAfterRetries"
 	// StatusReasonTimeout is the reason of the workflow progress condition which is Timeout.
 	StatusReasonTimeout = "Timeout"
+	// StatusReasonApprovalTimeout is the reason of the suspend step which is not resumed in the timeout.
+	StatusReasonApprovalTimeout = "ApprovalTimeout"
 	// StatusReasonAction is the reason of the workflow progress condition which is Action.
 	StatusReasonAction = "Action"
+	// StatusReasonHook is the reason of the workflow progress condition which is Hook.
+	StatusReasonHook = "Hook"
+	// StatusReasonFailingRepeatedly is the reason of the workflow run condition which is FailingRepeatedly.
+	StatusReasonFailingRepeatedly = "FailingRepeatedly"
+	// StatusReasonCondition is the reason of the workflow progress condition which is Condition.
+	StatusReasonCondition = "Condition"
+	// StatusReasonIgnored is the reason of the failed step whose failure is ignored by its onFailure policy.
+	StatusReasonIgnored = "Ignored"
+	// StatusReasonExecuteWindow is the reason of the step waiting for its execute window.
+	StatusReasonExecuteWindow = "ExecuteWindow"
 )
 
 const (
@@ -271,15 +528,91 @@ const (
 	MessageTerminated = "The workflow terminates because of the failed steps"
 	// MessageSuspendFailedAfterRetries is the message of failed after retries
 	MessageSuspendFailedAfterRetries = "The workflow suspends automatically because the failed times of steps have reached the limit"
+	// MessageSuspendFailingRepeatedly is the message of the step failing consecutively for the max times
+	MessageSuspendFailingRepeatedly = "step %s failing repeatedly, manual intervention required"
+	// MessageStepInterrupted is the message of the step interrupted by the graceful termination after the grace period
+	MessageStepInterrupted = "interrupted: the step is not finished in the grace period %s of the termination"
+	// MessageApprovalTimeout is the message of the suspend step which is not resumed in the timeout
+	MessageApprovalTimeout = "the step is not resumed in the timeout %s"
+	// MessageWorkflowTimeout is the message of the step interrupted as the workflow run is not finished in the timeout
+	MessageWorkflowTimeout = "interrupted: the workflow run is not finished in the timeout %s"
+	// MessageStepNotStarted is the message of the step which is not started before the workflow run is terminated
+	MessageStepNotStarted = "skipped: the workflow run is terminated"
+	// MessageTruncated is the suffix of the truncated step message referring to the ConfigMap and the key of the full message
+	MessageTruncated = "...[truncated, see %s/%s]"
+)
+
+const (
+	// DefaultTerminateGracePeriod is the grace period of the graceful termination if it's not specified
+	DefaultTerminateGracePeriod = 10 * time.Minute
 )
 
 const (
 	// AnnotationWorkflowRunDebug is the annotation for debug
 	AnnotationWorkflowRunDebug = "workflowrun.oam.dev/debug"
+	// AnnotationWorkflowRunDebugOp is the annotation for enabling the debug op to record the values even if the run
+	// is not debugged
+	AnnotationWorkflowRunDebugOp = "workflowrun.oam.dev/debug-op"
+	// AnnotationWorkflowRunLiveProgress is the annotation for enabling live progress of the workflow run
+	AnnotationWorkflowRunLiveProgress = "workflowrun.oam.dev/live-progress"
+	// AnnotationWorkflowRunBackedUp is the annotation indicates the workflow run has been persisted by the backup controller
+	AnnotationWorkflowRunBackedUp = "workflowrun.oam.dev/backed-up"
+	// AnnotationWorkflowRunRestartOnSpecChange is the annotation for restarting the started steps whose properties are changed,
+	// the workflow run refuses to proceed with the changed properties if it's not set
+	AnnotationWorkflowRunRestartOnSpecChange = "workflowrun.oam.dev/restart-on-spec-change"
+	// AnnotationWorkflowRunMaxConsecutiveFailures is the annotation for overriding the consecutive failures of a step
+	// to suspend the workflow run, 0 disables it
+	AnnotationWorkflowRunMaxConsecutiveFailures = "workflowrun.oam.dev/max-consecutive-failures"
+	// AnnotationWorkflowRunParent is the annotation of the child workflow run with the `namespace/name` of the
+	// parent run creating it
+	AnnotationWorkflowRunParent = "workflowrun.oam.dev/parent"
+	// AnnotationWorkflowRunInjectFailure is the annotation to fail the first executions of the steps, e.g. `step-b:2`,
+	// it takes effect only if the fault injection is enabled
+	AnnotationWorkflowRunInjectFailure = "workflowrun.oam.dev/inject-failure"
+	// AnnotationWorkflowRunInjectLatency is the annotation to delay the executions of the steps, e.g. `step-c:10s`,
+	// it takes effect only if the fault injection is enabled
+	AnnotationWorkflowRunInjectLatency = "workflowrun.oam.dev/inject-latency"
+	// AnnotationWorkflowRunCreator is the annotation of the user creating the workflow run, it's recorded in the
+	// audit records of the ops and stamped by the mutating webhook, which rejects the values set by the users
+	AnnotationWorkflowRunCreator = "workflowrun.oam.dev/creator"
+	// AnnotationWorkflowRunDryRun is the annotation for executing the workflow run in the dry-run mode even if the
+	// dry-run mode of the controller is disabled
+	AnnotationWorkflowRunDryRun = "workflowrun.oam.dev/dry-run"
+	// AnnotationWorkflowRunTrackResources is the annotation for recording the objects applied by the workflow run
+	// so that the gc op can prune them, it's implied by the resource gc of the run
+	AnnotationWorkflowRunTrackResources = "workflowrun.oam.dev/track-resources"
+	// AnnotationWorkflowRunTerminateGracefully is the annotation for terminating the workflow run gracefully, its
+	// value is the grace period, e.g. `5m`, or `true` for the default one. It's removed once the termination starts.
+	AnnotationWorkflowRunTerminateGracefully = "workflowrun.oam.dev/terminate-gracefully"
+	// CustomRunMetadataPrefix is the prefix of the labels and the annotations of the workflow run that the steps
+	// are allowed to patch, the others are owned by the controller or the users
+	CustomRunMetadataPrefix = "custom.workflow.oam.dev/"
 )
 
+// IsDryRun returns whether the workflow run with the annotations is executed in the dry-run mode
+func IsDryRun(annotations map[string]string) bool {
+	return DryRunMode || annotations[AnnotationWorkflowRunDryRun] == "true"
+}
+
+// IsRunDryRun returns whether the workflow run is executed in the dry-run mode, the mode is latched in the status when
+// the run starts so that the later changes of the annotation and the controller flag don't take effect
+func IsRunDryRun(run *v1alpha1.WorkflowRun) bool {
+	if !run.Status.StartTime.IsZero() {
+		return run.Status.DryRun
+	}
+	return IsDryRun(run.Annotations)
+}
+
+// IsResourceTracked returns whether the objects applied by the workflow run are tracked
+func IsResourceTracked(run *v1alpha1.WorkflowRun) bool {
+	return run.Spec.ResourceGC || run.Annotations[AnnotationWorkflowRunTrackResources] == "true"
+}
+
 // IsStepFinish will decide whether step is finish.
 func IsStepFinish(phase v1alpha1.WorkflowStepPhase, reason string) bool {
+	if phase == v1alpha1.WorkflowStepPhaseFailed && reason == StatusReasonIgnored {
+		return true
+	}
 	if feature.DefaultMutableFeatureGate.Enabled(features.EnableSuspendOnFailure) {
 		return phase == v1alpha1.WorkflowStepPhaseSucceeded
 	}
@@ -295,6 +628,12 @@ func IsStepFinish(phase v1alpha1.WorkflowStepPhase, reason string) bool {
 	}
 }
 
+// IsStepFailureIgnored returns whether the step is failed but its failure is ignored by the onFailure policy,
+// such step doesn't fail the workflow run and the steps depending on it.
+func IsStepFailureIgnored(status v1alpha1.StepStatus) bool {
+	return status.Phase == v1alpha1.WorkflowStepPhaseFailed && status.Reason == StatusReasonIgnored
+}
+
 // SetNamespaceInCtx set namespace in context.
 func SetNamespaceInCtx(ctx context.Context, namespace string) context.Context {
 	if namespace == "" {
@@ -304,3 +643,79 @@ func SetNamespaceInCtx(ctx context.Context, namespace string) context.Context {
 	ctx = context.WithValue(ctx, template.DefinitionNamespace, namespace)
 	return ctx
 }
+
+// ClientErrorClass is the class of the errors returned by the kube client calls of the providers
+type ClientErrorClass string
+
+const (
+	// ClientErrorForbidden means the controller is not permitted to do the call, it's terminal
+	ClientErrorForbidden ClientErrorClass = "Forbidden"
+	// ClientErrorInvalid means the object is rejected by the validation of the api server, it's terminal
+	ClientErrorInvalid ClientErrorClass = "Invalid"
+	// ClientErrorConflict means the object is modified concurrently, it's retried
+	ClientErrorConflict ClientErrorClass = "Conflict"
+	// ClientErrorServerTimeout means the api server can not complete the call in time, it's retried
+	ClientErrorServerTimeout ClientErrorClass = "ServerTimeout"
+	// ClientErrorTooManyRequests means the call is throttled by the api server, it's retried
+	ClientErrorTooManyRequests ClientErrorClass = "TooManyRequests"
+)
+
+// ClientError is the classified error of a kube client call, the custom failure strategies can match the class
+// with errors.As
+type ClientError struct {
+	Class     ClientErrorClass
+	Verb      string
+	Resource  string
+	Namespace string
+	Err       error
+}
+
+// Error returns the message naming the verb, the resource and the namespace of the call
+func (e *ClientError) Error() string {
+	namespace := e.Namespace
+	if namespace == "" {
+		namespace = "<cluster scope>"
+	}
+	return fmt.Sprintf("%s: failed to %s %s in namespace %s: %s", e.Class, e.Verb, e.Resource, namespace, e.Err.Error())
+}
+
+// Unwrap returns the original error of the call
+func (e *ClientError) Unwrap() error {
+	return e.Err
+}
+
+// Terminal returns whether the step should fail immediately instead of being retried
+func (e *ClientError) Terminal() bool {
+	return e.Class == ClientErrorForbidden || e.Class == ClientErrorInvalid
+}
+
+// NewClientError classifies the error of the client call, the errors out of the classes are returned as is
+func NewClientError(err error, verb, resource, namespace string) error {
+	var class ClientErrorClass
+	switch {
+	case err == nil:
+		return nil
+	case kerrors.IsForbidden(err):
+		class = ClientErrorForbidden
+	case kerrors.IsInvalid(err):
+		class = ClientErrorInvalid
+	case kerrors.IsConflict(err):
+		class = ClientErrorConflict
+	case kerrors.IsServerTimeout(err):
+		class = ClientErrorServerTimeout
+	case kerrors.IsTooManyRequests(err):
+		class = ClientErrorTooManyRequests
+	default:
+		return err
+	}
+	return &ClientError{Class: class, Verb: verb, Resource: resource, Namespace: namespace, Err: err}
+}
+
+// AsTerminalClientError returns the terminal client error in the chain of the error
+func AsTerminalClientError(err error) (*ClientError, bool) {
+	var clientErr *ClientError
+	if errors.As(err, &clientErr) && clientErr.Terminal() {
+		return clientErr, true
+	}
+	return nil, false
+}
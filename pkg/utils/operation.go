@@ -34,13 +34,13 @@ import (
 	"github.com/kubevela/workflow/pkg/types"
 )
 
-// GetDataFromContext get data from workflow context
-func GetDataFromContext(ctx context.Context, cli client.Client, ctxName, name, ns string, paths ...string) (*value.Value, error) {
-	wfCtx, err := wfContext.LoadContext(cli, ns, name, ctxName)
+// GetDataFromContext get data from workflow context referred by the context backend in the status of the workflow run
+func GetDataFromContext(ctx context.Context, cli client.Client, ref *corev1.ObjectReference, name, ns string, paths ...string) (*value.Value, error) {
+	wfCtx, err := wfContext.LoadContextFromRef(cli, ns, name, ref)
 	if err != nil {
 		return nil, err
 	}
-	v, err := wfCtx.GetVar(paths...)
+	v, err := wfContext.LookupVar(wfCtx, paths...)
 	if err != nil {
 		return nil, err
 	}
@@ -50,9 +50,10 @@ func GetDataFromContext(ctx context.Context, cli client.Client, ctxName, name, n
 	return v, nil
 }
 
-// GetLogConfigFromStep get log config from step
-func GetLogConfigFromStep(ctx context.Context, cli client.Client, ctxName, name, ns, step string) (*types.LogConfig, error) {
-	wfCtx, err := wfContext.LoadContext(cli, ns, name, ctxName)
+// GetLogConfigFromStep get log config from step, the workflow context is referred by the context backend in the status
+// of the workflow run
+func GetLogConfigFromStep(ctx context.Context, cli client.Client, ref *corev1.ObjectReference, name, ns, step string) (*types.LogConfig, error) {
+	wfCtx, err := wfContext.LoadContextFromRef(cli, ns, name, ref)
 	if err != nil {
 		return nil, err
 	}
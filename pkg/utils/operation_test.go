@@ -24,6 +24,7 @@ import (
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	clientfake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -39,6 +40,7 @@ func TestGetWorkflowContextData(t *testing.T) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "workflow-test-context",
 			Namespace: "default",
+			UID:       "test-uid",
 		},
 		Data: map[string]string{
 			"vars": `{"test-test": "test"}`,
@@ -49,6 +51,7 @@ func TestGetWorkflowContextData(t *testing.T) {
 
 	testCases := map[string]struct {
 		name        string
+		uid         string
 		paths       string
 		expected    string
 		expectedErr string
@@ -57,8 +60,14 @@ func TestGetWorkflowContextData(t *testing.T) {
 			name:        "not-found",
 			expectedErr: "not found",
 		},
+		"stale": {
+			name:        "workflow-test-context",
+			uid:         "stale-uid",
+			expectedErr: "is stale",
+		},
 		"found": {
 			name:     "workflow-test-context",
+			uid:      "test-uid",
 			expected: "\"test-test\": \"test\"\n",
 		},
 		"found with path": {
@@ -75,7 +84,8 @@ func TestGetWorkflowContextData(t *testing.T) {
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
 			r := require.New(t)
-			v, err := GetDataFromContext(ctx, cli, tc.name, tc.name, "default", tc.paths)
+			ref := &corev1.ObjectReference{Name: tc.name, Namespace: "default", UID: k8stypes.UID(tc.uid)}
+			v, err := GetDataFromContext(ctx, cli, ref, tc.name, "default", tc.paths)
 			if tc.expectedErr != "" {
 				r.Contains(err.Error(), tc.expectedErr)
 				return
@@ -156,7 +166,7 @@ func TestGetStepLogConfig(t *testing.T) {
 					r.NoError(err)
 				}()
 			}
-			v, err := GetLogConfigFromStep(ctx, cli, tc.name, tc.name, "default", tc.step)
+			v, err := GetLogConfigFromStep(ctx, cli, &corev1.ObjectReference{Name: tc.name}, tc.name, "default", tc.step)
 			if tc.expectedErr != "" {
 				r.Contains(err.Error(), tc.expectedErr)
 				return
@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package definition
+
+import (
+	"context"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kubevela/workflow/pkg/cue/packages"
+	"github.com/kubevela/workflow/pkg/definition"
+)
+
+// Path is the path of the validating webhook of the workflow step definitions
+const Path = "/validate-core-oam-dev-v1beta1-workflowstepdefinition"
+
+// Validator validates the templates of the workflow step definitions
+type Validator struct {
+	validator *definition.Validator
+}
+
+// Register registers the validating webhook of the workflow step definitions to the manager
+func Register(mgr ctrl.Manager, pd *packages.PackageDiscover) {
+	mgr.GetWebhookServer().Register(Path, &webhook.Admission{Handler: &Validator{validator: definition.NewValidator(pd, nil)}})
+}
+
+// Handle validates the template of the workflow step definition on creation and update
+func (v *Validator) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+	def := &unstructured.Unstructured{}
+	if err := def.UnmarshalJSON(req.Object.Raw); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if err := v.validator.ValidateDefinition(def); err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
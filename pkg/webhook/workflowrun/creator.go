@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// CreatorMutator stamps the creator annotation of the workflow runs with the user creating them, the annotation set
+// by the users is rejected so that the creator recorded in the audit records can be trusted.
+type CreatorMutator struct {
+	decoder *admission.Decoder
+}
+
+// InjectDecoder injects the decoder of the admission requests
+func (m *CreatorMutator) InjectDecoder(d *admission.Decoder) error {
+	m.decoder = d
+	return nil
+}
+
+// Handle stamps the creator of the workflow run on creation and rejects the changes of it on update
+func (m *CreatorMutator) Handle(_ context.Context, req admission.Request) admission.Response {
+	run := &v1alpha1.WorkflowRun{}
+	if err := m.decoder.Decode(req, run); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	creator, ok := run.Annotations[types.AnnotationWorkflowRunCreator]
+	switch req.Operation {
+	case admissionv1.Create:
+		if ok && creator != req.UserInfo.Username {
+			return admission.Denied(fmt.Sprintf("annotation %s is set to the user creating the run", types.AnnotationWorkflowRunCreator))
+		}
+		if ok {
+			return admission.Allowed("")
+		}
+		if run.Annotations == nil {
+			run.Annotations = map[string]string{}
+		}
+		run.Annotations[types.AnnotationWorkflowRunCreator] = req.UserInfo.Username
+		marshalled, err := json.Marshal(run)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		return admission.PatchResponseFromRaw(req.Object.Raw, marshalled)
+	case admissionv1.Update:
+		oldRun := &v1alpha1.WorkflowRun{}
+		if err := m.decoder.DecodeRaw(req.OldObject, oldRun); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		oldCreator, oldOK := oldRun.Annotations[types.AnnotationWorkflowRunCreator]
+		if ok != oldOK || creator != oldCreator {
+			return admission.Denied(fmt.Sprintf("annotation %s is immutable", types.AnnotationWorkflowRunCreator))
+		}
+	}
+	return admission.Allowed("")
+}
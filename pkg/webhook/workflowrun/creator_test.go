@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowrun
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestCreatorMutator(t *testing.T) {
+	r := require.New(t)
+	scheme := runtime.NewScheme()
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	decoder, err := admission.NewDecoder(scheme)
+	r.NoError(err)
+	mutator := &CreatorMutator{}
+	r.NoError(mutator.InjectDecoder(decoder))
+	raw := func(creator string) runtime.RawExtension {
+		run := &v1alpha1.WorkflowRun{
+			TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: v1alpha1.WorkflowRunKind},
+			ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"},
+		}
+		if creator != "" {
+			run.Annotations = map[string]string{types.AnnotationWorkflowRunCreator: creator}
+		}
+		b, err := json.Marshal(run)
+		r.NoError(err)
+		return runtime.RawExtension{Raw: b}
+	}
+	request := func(op admissionv1.Operation, object, oldObject runtime.RawExtension) admission.Request {
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: op,
+			UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+			Object:    object,
+			OldObject: oldObject,
+		}}
+	}
+
+	// the creator is stamped on creation
+	resp := mutator.Handle(context.Background(), request(admissionv1.Create, raw(""), runtime.RawExtension{}))
+	r.True(resp.Allowed)
+	r.Len(resp.Patches, 1)
+	r.Equal("/metadata/annotations", resp.Patches[0].Path)
+	r.Equal(map[string]interface{}{types.AnnotationWorkflowRunCreator: "alice"}, resp.Patches[0].Value)
+
+	// the creator set by the users is rejected unless it's the user creating the run
+	resp = mutator.Handle(context.Background(), request(admissionv1.Create, raw("bob"), runtime.RawExtension{}))
+	r.False(resp.Allowed)
+	resp = mutator.Handle(context.Background(), request(admissionv1.Create, raw("alice"), runtime.RawExtension{}))
+	r.True(resp.Allowed)
+	r.Empty(resp.Patches)
+
+	// the creator is immutable
+	resp = mutator.Handle(context.Background(), request(admissionv1.Update, raw("bob"), raw("alice")))
+	r.False(resp.Allowed)
+	resp = mutator.Handle(context.Background(), request(admissionv1.Update, raw(""), raw("alice")))
+	r.False(resp.Allowed)
+	resp = mutator.Handle(context.Background(), request(admissionv1.Update, raw("alice"), raw("alice")))
+	r.True(resp.Allowed)
+}
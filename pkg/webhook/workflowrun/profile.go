@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowrun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// profileWarner warns the workflow runs admitted by the validator if their steps rely on the deprecated cue profiles
+type profileWarner struct {
+	admission.Handler
+	decoder *admission.Decoder
+}
+
+// InjectDecoder injects the decoder of the admission requests into the warner and the validator
+func (w *profileWarner) InjectDecoder(d *admission.Decoder) error {
+	w.decoder = d
+	_, err := admission.InjectDecoderInto(d, w.Handler)
+	return err
+}
+
+// Handle validates the workflow run and adds the warnings of the deprecated cue profiles to the allowed response
+func (w *profileWarner) Handle(ctx context.Context, req admission.Request) admission.Response {
+	resp := w.Handler.Handle(ctx, req)
+	if !resp.Allowed || (req.Operation != admissionv1.Create && req.Operation != admissionv1.Update) {
+		return resp
+	}
+	run := &v1alpha1.WorkflowRun{}
+	if err := w.decoder.Decode(req, run); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	return resp.WithWarnings(deprecatedProfileWarnings(run)...)
+}
+
+// deprecatedProfileWarnings returns the warnings of the steps and the sub steps using the deprecated cue profiles, the
+// steps without the profile use the default profile, the builtin steps are not rendered by cue
+func deprecatedProfileWarnings(run *v1alpha1.WorkflowRun) []string {
+	if run.Spec.WorkflowSpec == nil {
+		return nil
+	}
+	var warnings []string
+	warn := func(step v1alpha1.WorkflowStepBase) {
+		if step.Type == types.WorkflowStepTypeSuspend || step.Type == types.WorkflowStepTypeStepGroup {
+			return
+		}
+		if profile, err := value.GetProfile(step.CUEProfile); err == nil && profile.Deprecated {
+			warnings = append(warnings, fmt.Sprintf("step %s uses the cue profile %s which is deprecated and scheduled for removal", step.Name, profile.Name))
+		}
+	}
+	for _, step := range run.Spec.WorkflowSpec.Steps {
+		warn(step.WorkflowStepBase)
+		for _, sub := range step.SubSteps {
+			warn(sub)
+		}
+	}
+	return warnings
+}
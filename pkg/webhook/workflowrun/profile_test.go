@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowrun
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+func TestProfileWarner(t *testing.T) {
+	r := require.New(t)
+	scheme := runtime.NewScheme()
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	decoder, err := admission.NewDecoder(scheme)
+	r.NoError(err)
+	warner := &profileWarner{Handler: admission.WithCustomValidator(&v1alpha1.WorkflowRun{}, &Validator{}).Handler}
+	r.NoError(warner.InjectDecoder(decoder))
+	raw := func(ctx string, profiles ...string) runtime.RawExtension {
+		run := &v1alpha1.WorkflowRun{
+			TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: v1alpha1.WorkflowRunKind},
+			ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"},
+			Spec:       v1alpha1.WorkflowRunSpec{WorkflowSpec: &v1alpha1.WorkflowSpec{}},
+		}
+		if ctx != "" {
+			run.Spec.Context = &runtime.RawExtension{Raw: []byte(ctx)}
+		}
+		step := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "group", Type: "step-group"}}
+		for i, profile := range profiles {
+			step.SubSteps = append(step.SubSteps, v1alpha1.WorkflowStepBase{Name: "sub-" + strconv.Itoa(i), Type: "apply", CUEProfile: profile})
+		}
+		run.Spec.WorkflowSpec.Steps = []v1alpha1.WorkflowStep{step}
+		b, err := json.Marshal(run)
+		r.NoError(err)
+		return runtime.RawExtension{Raw: b}
+	}
+	request := func(object runtime.RawExtension) admission.Request {
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Create, Object: object}}
+	}
+
+	resp := warner.Handle(context.Background(), request(raw("", value.ProfileLatest, value.ProfileV06Compat)))
+	r.True(resp.Allowed)
+	r.Equal([]string{"step sub-1 uses the cue profile v0.6-compat which is deprecated and scheduled for removal"}, resp.Warnings)
+
+	// the steps without the profile use the default profile
+	resp = warner.Handle(context.Background(), request(raw("", value.ProfileLatest, "")))
+	r.True(resp.Allowed)
+	r.Equal([]string{"step sub-1 uses the cue profile v0.6-compat which is deprecated and scheduled for removal"}, resp.Warnings)
+
+	defer func(profile string) {
+		value.DefaultProfile = profile
+	}(value.DefaultProfile)
+	value.DefaultProfile = value.ProfileLatest
+	resp = warner.Handle(context.Background(), request(raw("", value.ProfileLatest, "")))
+	r.True(resp.Allowed)
+	r.Empty(resp.Warnings)
+
+	// the denied runs are not warned
+	resp = warner.Handle(context.Background(), request(raw(`["dev"]`, value.ProfileV06Compat)))
+	r.False(resp.Allowed)
+	r.Empty(resp.Warnings)
+}
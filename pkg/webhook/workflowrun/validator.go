@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowrun
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// Validator validates the workflow runs
+type Validator struct{}
+
+// Register registers the validating webhook of the workflow runs to the manager,
+// the path of the webhook is /validate-core-oam-dev-v1alpha1-workflowrun, the runs relying on the deprecated cue
+// profiles are warned by it. The mutating webhook stamping the creator of the runs is registered at
+// /mutate-core-oam-dev-v1alpha1-workflowrun.
+func Register(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register("/mutate-core-oam-dev-v1alpha1-workflowrun", &webhook.Admission{Handler: &CreatorMutator{}})
+	validating := admission.WithCustomValidator(&v1alpha1.WorkflowRun{}, &Validator{})
+	validating.Handler = &profileWarner{Handler: validating.Handler}
+	mgr.GetWebhookServer().Register("/validate-core-oam-dev-v1alpha1-workflowrun", validating)
+	return nil
+}
+
+// ValidateCreate validates the workflow run on creation
+func (v *Validator) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	run, ok := obj.(*v1alpha1.WorkflowRun)
+	if !ok {
+		return errors.Errorf("expect a workflow run but got %T", obj)
+	}
+	if _, err := decodeContext(run); err != nil {
+		return err
+	}
+	return validateProviderOverrides(run)
+}
+
+// ValidateUpdate rejects the changes of the context of the workflow run after the run is started,
+// so that the steps are always executed with the same context.
+func (v *Validator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) error {
+	oldRun, ok := oldObj.(*v1alpha1.WorkflowRun)
+	if !ok {
+		return errors.Errorf("expect a workflow run but got %T", oldObj)
+	}
+	newRun, ok := newObj.(*v1alpha1.WorkflowRun)
+	if !ok {
+		return errors.Errorf("expect a workflow run but got %T", newObj)
+	}
+	newContext, err := decodeContext(newRun)
+	if err != nil {
+		return err
+	}
+	// the existing overrides are kept untouched so that the runs created before they are disallowed can be updated
+	if !reflect.DeepEqual(oldRun.Spec.ProviderOverrides, newRun.Spec.ProviderOverrides) {
+		if err := validateProviderOverrides(newRun); err != nil {
+			return err
+		}
+	}
+	if oldRun.Status.StartTime.IsZero() {
+		return nil
+	}
+	oldContext, err := decodeContext(oldRun)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(oldContext, newContext) {
+		return errors.New("spec.context is immutable after the workflow run is started")
+	}
+	return nil
+}
+
+// ValidateDelete validates the workflow run on deletion
+func (v *Validator) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+func validateProviderOverrides(run *v1alpha1.WorkflowRun) error {
+	if run.Spec.ProviderOverrides != nil && !types.AllowProviderOverrides {
+		return errors.New("spec.providerOverrides is not allowed by the controller")
+	}
+	return nil
+}
+
+func decodeContext(run *v1alpha1.WorkflowRun) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	if run.Spec.Context == nil || len(run.Spec.Context.Raw) == 0 {
+		return data, nil
+	}
+	if err := json.Unmarshal(run.Spec.Context.Raw, &data); err != nil {
+		return nil, errors.WithMessage(err, "spec.context must be an object")
+	}
+	return data, nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowrun
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestValidateUpdate(t *testing.T) {
+	run := func(ctx string, started bool) *v1alpha1.WorkflowRun {
+		r := &v1alpha1.WorkflowRun{}
+		if ctx != "" {
+			r.Spec.Context = &runtime.RawExtension{Raw: []byte(ctx)}
+		}
+		if started {
+			r.Status.StartTime = metav1.Now()
+		}
+		return r
+	}
+	testCases := map[string]struct {
+		old *v1alpha1.WorkflowRun
+		new *v1alpha1.WorkflowRun
+		err string
+	}{
+		"update context before started": {
+			old: run(`{"env":"dev"}`, false),
+			new: run(`{"env":"prod"}`, false),
+		},
+		"update context after started": {
+			old: run(`{"env":"dev"}`, true),
+			new: run(`{"env":"prod"}`, true),
+			err: "spec.context is immutable after the workflow run is started",
+		},
+		"reorder context after started": {
+			old: run(`{"env":"dev","region":"us"}`, true),
+			new: run(`{"region": "us", "env": "dev"}`, true),
+		},
+		"invalid context": {
+			old: run("", false),
+			new: run(`["dev"]`, false),
+			err: "spec.context must be an object",
+		},
+	}
+	v := &Validator{}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			err := v.ValidateUpdate(context.Background(), tc.old, tc.new)
+			if tc.err == "" {
+				r.NoError(err)
+				return
+			}
+			r.Error(err)
+			r.Contains(err.Error(), tc.err)
+		})
+	}
+}
+
+func TestValidateProviderOverrides(t *testing.T) {
+	r := require.New(t)
+	v := &Validator{}
+	overridden := &v1alpha1.WorkflowRun{Spec: v1alpha1.WorkflowRunSpec{ProviderOverrides: &v1alpha1.ProviderOverrides{
+		Email: &v1alpha1.EmailOverride{SMTPHost: "mailhog.sandbox", SMTPPort: 1025},
+	}}}
+	r.NoError(v.ValidateCreate(context.Background(), overridden))
+
+	types.AllowProviderOverrides = false
+	defer func() {
+		types.AllowProviderOverrides = true
+	}()
+	err := v.ValidateCreate(context.Background(), overridden)
+	r.Error(err)
+	r.Equal("spec.providerOverrides is not allowed by the controller", err.Error())
+	r.NoError(v.ValidateCreate(context.Background(), &v1alpha1.WorkflowRun{}))
+
+	err = v.ValidateUpdate(context.Background(), &v1alpha1.WorkflowRun{}, overridden)
+	r.Error(err)
+	r.Equal("spec.providerOverrides is not allowed by the controller", err.Error())
+	// the existing overrides don't block the updates of the run
+	r.NoError(v.ValidateUpdate(context.Background(), overridden, overridden.DeepCopy()))
+}